@@ -2,6 +2,7 @@ package age
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -11,8 +12,15 @@ import (
 	"filippo.io/age/armor"
 )
 
-// LoadIdentities loads AGE identities from the specified file path.
+// LoadIdentities loads AGE identities from the specified file path: an age
+// identity file (plugin identities included), or an OpenSSH ed25519/RSA
+// private key, either of them possibly wrapped by a cloud KMS (see
+// KMSProvider). The path may also be StdinIdentities or EnvIdentities (see
+// loadInline).
 func LoadIdentities(path string) ([]age.Identity, error) {
+	if IsInline(path) {
+		return loadInline(path)
+	}
 	b, err := os.ReadFile(path)
 	if err != nil {
 		return nil, fmt.Errorf("\nCould not read AGE key at %s\n"+
@@ -20,22 +28,44 @@ func LoadIdentities(path string) ([]age.Identity, error) {
 			"- Or point to another key: --identities /path/to/key.txt\nOriginal error: %w",
 			path, path, err)
 	}
-	ids, err := age.ParseIdentities(bytes.NewReader(b))
+	return parseIdentityBytes(path, b)
+}
+
+// parseIdentityBytes parses the contents of an identities source; name
+// appears in errors and SSH passphrase prompts.
+func parseIdentityBytes(name string, b []byte) ([]age.Identity, error) {
+	if isKMSWrapped(b) {
+		plain, err := unwrapKMS(b)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		if !isSSHPrivateKey(plain) {
+			defer clear(plain)
+		}
+		return parseIdentityBytes(name, plain)
+	}
+	if isSSHPrivateKey(b) {
+		id, err := parseSSHIdentity(name, b)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse SSH key %s: %w", name, err)
+		}
+		return []age.Identity{id}, nil
+	}
+	ids, err := ParseIdentities(bytes.NewReader(b))
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse identities in %s: %w", path, err)
+		return nil, fmt.Errorf("failed to parse identities in %s: %w", name, err)
 	}
 	return ids, nil
 }
 
-// LoadRecipients loads AGE recipients from the specified file path.
+// LoadRecipients loads AGE recipients from the specified file path (see
+// ParseRecipients for the accepted lines).
 func LoadRecipients(path string) ([]age.Recipient, error) {
 	b, err := os.ReadFile(path)
 	if err != nil {
-		return nil, fmt.Errorf("\nRecipients file not found: %s\n"+
-			"- Create one and commit it to your repo (recommended).\n"+
-			"- Example (one public key per line): age1xxxx...\nOriginal error: %w", path, err)
+		return nil, recipientsFileMissing(path, err)
 	}
-	rs, err := age.ParseRecipients(bytes.NewReader(b))
+	rs, err := ParseRecipients(bytes.NewReader(b))
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse recipients in %s: %w", path, err)
 	}
@@ -45,33 +75,67 @@ func LoadRecipients(path string) ([]age.Recipient, error) {
 	return rs, nil
 }
 
+func recipientsFileMissing(path string, err error) error {
+	return fmt.Errorf("\nRecipients file not found: %s\n"+
+		"- Create one and commit it to your repo (recommended).\n"+
+		"- Example (one public key per line): age1xxxx... or ssh-ed25519 AAAA...\nOriginal error: %w", path, err)
+}
+
+// LoadRecipientsSkipping loads a recipients file as LoadRecipients does,
+// but leaves out the lines ParseLabeledRecipientsSkipping sets aside and
+// returns them. It still fails when no usable recipient is left.
+func LoadRecipientsSkipping(path string) ([]age.Recipient, []*RecipientLineError, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, recipientsFileMissing(path, err)
+	}
+	ls, bad, err := ParseLabeledRecipientsSkipping(bytes.NewReader(b))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse recipients in %s: %w", path, err)
+	}
+	if len(ls) == 0 {
+		if len(bad) > 0 {
+			return nil, bad, fmt.Errorf("no usable recipients in %s; every line was skipped, starting with %w", path, bad[0])
+		}
+		return nil, nil, fmt.Errorf("no recipients in %s; add at least one age public key", path)
+	}
+	rs := make([]age.Recipient, len(ls))
+	for i, l := range ls {
+		rs[i] = l.Recipient
+	}
+	return rs, bad, nil
+}
+
 // DecryptToMemory decrypts an AGE-encrypted file to memory.
 func DecryptToMemory(cipherPath string, ids []age.Identity) (string, error) {
-	f, err := os.Open(cipherPath)
+	b, err := os.ReadFile(cipherPath)
 	if err != nil {
 		return "", fmt.Errorf("open ciphertext: %w", err)
 	}
-	defer f.Close()
+	return Decrypt(b, ids)
+}
 
-	// Try to unwrap armor if present
-	reader := io.Reader(f)
-	armoredReader := armor.NewReader(f)
-	if _, err := armoredReader.Read(make([]byte, 1)); err == nil {
-		// Reset file pointer and use armored reader
-		f.Seek(0, 0)
-		reader = armor.NewReader(f)
-	} else {
-		// Not armored, reset and use plain reader
-		f.Seek(0, 0)
+// Decrypt decrypts armored or binary ciphertext held in memory (for example
+// a file's content at a git revision). See unwrap for the layouts accepted.
+func Decrypt(cipher []byte, ids []age.Identity) (string, error) {
+	reader, _, err := unwrap(cipher)
+	if err != nil {
+		return "", err
 	}
+	return decrypt(reader, ids)
+}
 
+func decrypt(reader io.Reader, ids []age.Identity) (string, error) {
 	r, err := age.Decrypt(reader, ids...)
 	if err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF) {
+			return "", fmt.Errorf("%w: the header ends early: %v", ErrTruncated, err)
+		}
 		return "", fmt.Errorf("decrypt: %w", err)
 	}
 	plain, err := io.ReadAll(r)
 	if err != nil {
-		return "", fmt.Errorf("read plaintext: %w", err)
+		return "", fmt.Errorf("%w: the payload is truncated or corrupted: %v", ErrTruncated, err)
 	}
 	return string(plain), nil
 }
@@ -109,8 +173,38 @@ func EncryptToMemory(plaintext []byte, recips []age.Recipient, useArmor bool) ([
 	return buf.Bytes(), nil
 }
 
+// EncryptedSize estimates, from above, the size of plain bytes encrypted to
+// n recipients, for checking free space before a batch write. Stanzas are
+// counted at the size of an ssh-rsa one, the largest built-in type.
+func EncryptedSize(plain int64, n int, useArmor bool) int64 {
+	const (
+		chunk  = 64 << 10
+		stanza = 600                                // "-> ssh-rsa" with a 4096-bit wrapped key
+		header = len(headerIntro) + 1 + 48          // intro line and "--- <MAC>" line
+		frame  = len(armor.Header+armor.Footer) + 2 // armor begin and end lines
+	)
+	size := int64(header+n*stanza) + 16 // header and payload nonce
+	size += plain + 16*max(1, (plain+chunk-1)/chunk)
+	if useArmor {
+		b64 := (size + 2) / 3 * 4
+		size = b64 + (b64+63)/64 + int64(frame)
+	}
+	return size
+}
+
 // AtomicEncryptWrite encrypts and writes data to a file atomically.
 func AtomicEncryptWrite(dstPath string, b []byte, recips []age.Recipient, useArmor bool) error {
+	cipher, err := EncryptToMemory(b, recips, useArmor)
+	if err != nil {
+		return fmt.Errorf("encrypt: %w", err)
+	}
+	return AtomicWrite(dstPath, cipher)
+}
+
+// AtomicWrite writes already-encrypted bytes to dstPath through a synced
+// temp file in the same directory and a rename, so readers see either the
+// old file or the new one.
+func AtomicWrite(dstPath string, cipher []byte) error {
 	dir := filepath.Dir(dstPath)
 	tmp, err := os.CreateTemp(dir, ".agepad-tmp-*")
 	if err != nil {
@@ -119,34 +213,12 @@ func AtomicEncryptWrite(dstPath string, b []byte, recips []age.Recipient, useArm
 	tmpPath := tmp.Name()
 	defer func() { _ = os.Remove(tmpPath) }()
 
-	if useArmor {
-		aw := armor.NewWriter(tmp)
-		w, err := age.Encrypt(aw, recips...)
-		if err != nil {
-			return fmt.Errorf("encrypt: %w", err)
-		}
-		if _, err := w.Write(b); err != nil {
-			return err
-		}
-		if err := w.Close(); err != nil {
-			return err
-		}
-		if err := aw.Close(); err != nil {
-			return err
-		}
-	} else {
-		w, err := age.Encrypt(tmp, recips...)
-		if err != nil {
-			return fmt.Errorf("encrypt: %w", err)
-		}
-		if _, err := w.Write(b); err != nil {
-			return err
-		}
-		if err := w.Close(); err != nil {
-			return err
-		}
+	if _, err := tmp.Write(cipher); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("write temp: %w", err)
 	}
 	if err := tmp.Sync(); err != nil {
+		_ = tmp.Close()
 		return fmt.Errorf("sync: %w", err)
 	}
 	if err := tmp.Close(); err != nil {
@@ -2,9 +2,11 @@ package age
 
 import (
 	"bytes"
+	"fmt"
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"filippo.io/age"
 	"filippo.io/age/armor"
@@ -115,6 +117,29 @@ func TestEncryptDecryptRoundtrip(t *testing.T) {
 	})
 }
 
+func TestEncryptedSize(t *testing.T) {
+	var recips []age.Recipient
+	for range 3 {
+		identity, err := age.GenerateX25519Identity()
+		if err != nil {
+			t.Fatalf("failed to generate identity: %v", err)
+		}
+		recips = append(recips, identity.Recipient())
+	}
+	for _, n := range []int{0, 1, 64 << 10, 200 << 10} {
+		for _, armored := range []bool{false, true} {
+			cipher, err := EncryptToMemory(bytes.Repeat([]byte("x"), n), recips, armored)
+			if err != nil {
+				t.Fatalf("encrypt failed: %v", err)
+			}
+			est := EncryptedSize(int64(n), len(recips), armored)
+			if est < int64(len(cipher)) || est > int64(len(cipher))+4096 {
+				t.Errorf("%d bytes (armor %v): estimated %d, got %d", n, armored, est, len(cipher))
+			}
+		}
+	}
+}
+
 func TestAtomicEncryptWrite(t *testing.T) {
 	identity, err := age.GenerateX25519Identity()
 	if err != nil {
@@ -180,6 +205,39 @@ func TestAtomicEncryptWrite(t *testing.T) {
 	})
 }
 
+func TestWriteRetrying(t *testing.T) {
+	t.Run("classifies busy and locked files as transient", func(t *testing.T) {
+		for _, err := range transientErrors {
+			wrapped := fmt.Errorf("create temp: %w", &os.PathError{Op: "open", Path: "x", Err: err})
+			if !IsTransient(wrapped) {
+				t.Errorf("expected %v to be transient", err)
+			}
+		}
+		if IsTransient(os.ErrNotExist) {
+			t.Error("expected a missing directory not to be transient")
+		}
+	})
+
+	t.Run("does not retry permanent failures", func(t *testing.T) {
+		retried := false
+		p := RetryPolicy{Attempts: 3, Backoff: time.Millisecond, OnRetry: func(int, time.Duration, error) { retried = true }}
+		err := WriteRetrying(filepath.Join(t.TempDir(), "missing", "f.age"), []byte("x"), p)
+		if err == nil || retried {
+			t.Errorf("expected an immediate failure, got err=%v retried=%v", err, retried)
+		}
+	})
+
+	t.Run("writes the ciphertext", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "f.age")
+		if err := WriteRetrying(path, []byte("cipher"), DefaultRetry); err != nil {
+			t.Fatal(err)
+		}
+		if b, _ := os.ReadFile(path); string(b) != "cipher" {
+			t.Errorf("expected the bytes to be written as-is, got %q", b)
+		}
+	})
+}
+
 func TestLoadIdentities(t *testing.T) {
 	t.Run("loads valid identity file", func(t *testing.T) {
 		tmpDir := t.TempDir()
@@ -213,6 +271,49 @@ func TestLoadIdentities(t *testing.T) {
 			t.Error("expected error for missing identity file")
 		}
 	})
+
+	t.Run("reads the key from AGEPAD_IDENTITY once", func(t *testing.T) {
+		inline.ids = nil
+		identity, _ := age.GenerateX25519Identity()
+		t.Setenv(IdentityEnv, "# ci key\n"+identity.String()+"\n")
+		ids, err := LoadIdentities(EnvIdentities)
+		if err != nil || len(ids) != 1 {
+			t.Fatalf("expected 1 identity, got %d, %v", len(ids), err)
+		}
+		if _, ok := os.LookupEnv(IdentityEnv); ok {
+			t.Error("expected the variable to be removed from the environment")
+		}
+		again, err := LoadIdentities(EnvIdentities)
+		if err != nil || len(again) != 1 || again[0] != ids[0] {
+			t.Errorf("expected the cached identity on reload, got %v, %v", again, err)
+		}
+	})
+
+	t.Run("reads the key from stdin", func(t *testing.T) {
+		inline.ids = nil
+		identity, _ := age.GenerateX25519Identity()
+		r, w, err := os.Pipe()
+		if err != nil {
+			t.Fatal(err)
+		}
+		fmt.Fprintln(w, identity.String())
+		w.Close()
+		stdin := os.Stdin
+		os.Stdin = r
+		defer func() { os.Stdin = stdin }()
+		ids, err := LoadIdentities(StdinIdentities)
+		if err != nil || len(ids) != 1 {
+			t.Fatalf("expected 1 identity, got %d, %v", len(ids), err)
+		}
+	})
+
+	t.Run("rejects an empty source", func(t *testing.T) {
+		inline.ids = nil
+		t.Setenv(IdentityEnv, " \n")
+		if _, err := LoadIdentities(EnvIdentities); err == nil {
+			t.Error("expected an error for an empty AGEPAD_IDENTITY")
+		}
+	})
 }
 
 func TestLoadRecipients(t *testing.T) {
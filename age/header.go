@@ -0,0 +1,78 @@
+package age
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+const headerIntro = "age-encryption.org/v1"
+
+// Stanza is one recipient stanza from an age header: its type (X25519,
+// scrypt, ssh-ed25519, a plugin name, ...) and arguments. The wrapped file
+// key body is not retained.
+type Stanza struct {
+	Type string
+	Args []string
+}
+
+// Header summarises the plaintext header of an age file. Reading it needs
+// no identities.
+type Header struct {
+	Armored bool
+	Stanzas []Stanza
+}
+
+// StanzaTypes returns the type of each stanza, in header order.
+func (h Header) StanzaTypes() []string {
+	types := make([]string, len(h.Stanzas))
+	for i, s := range h.Stanzas {
+		types[i] = s.Type
+	}
+	return types
+}
+
+// InspectHeader reads the header of the age file at path.
+func InspectHeader(path string) (Header, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return Header{}, fmt.Errorf("open ciphertext: %w", err)
+	}
+	return InspectHeaderBytes(b)
+}
+
+// InspectHeaderBytes reads the header of an armored or binary age file.
+func InspectHeaderBytes(b []byte) (Header, error) {
+	var h Header
+	r, armored, err := unwrap(b)
+	h.Armored = armored
+	if err != nil {
+		return h, err
+	}
+	br := bufio.NewReader(r)
+	intro, err := br.ReadString('\n')
+	if err != nil || strings.TrimSuffix(intro, "\n") != headerIntro {
+		return h, ErrNotAge
+	}
+	for {
+		line, err := br.ReadString('\n')
+		if err != nil {
+			return h, fmt.Errorf("%w: the header ends early: %v", ErrTruncated, err)
+		}
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case strings.HasPrefix(line, "---"):
+			return h, nil
+		case strings.HasPrefix(line, "-> "):
+			fields := strings.Fields(strings.TrimPrefix(line, "-> "))
+			if len(fields) == 0 {
+				return h, errors.New("malformed age header: empty stanza")
+			}
+			h.Stanzas = append(h.Stanzas, Stanza{Type: fields[0], Args: fields[1:]})
+		default:
+			// Stanza body line; skipped.
+		}
+	}
+}
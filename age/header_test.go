@@ -0,0 +1,212 @@
+package age
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"filippo.io/age"
+	"github.com/andreweick/agepad/model"
+)
+
+func TestInspectHeader(t *testing.T) {
+	id1, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatalf("failed to generate identity: %v", err)
+	}
+	id2, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatalf("failed to generate identity: %v", err)
+	}
+	recips := []age.Recipient{id1.Recipient(), id2.Recipient()}
+
+	t.Run("lists stanzas of a binary file", func(t *testing.T) {
+		cipher, err := EncryptToMemory([]byte("x"), recips, false)
+		if err != nil {
+			t.Fatalf("encrypt failed: %v", err)
+		}
+		h, err := InspectHeaderBytes(cipher)
+		if err != nil {
+			t.Fatalf("inspect failed: %v", err)
+		}
+		if h.Armored {
+			t.Error("expected binary file to not be reported as armored")
+		}
+		if got := strings.Join(h.StanzaTypes(), ","); got != "X25519,X25519" {
+			t.Errorf("expected two X25519 stanzas, got %s", got)
+		}
+	})
+
+	t.Run("reads through armor", func(t *testing.T) {
+		cipher, err := EncryptToMemory([]byte("x"), recips[:1], true)
+		if err != nil {
+			t.Fatalf("encrypt failed: %v", err)
+		}
+		h, err := InspectHeaderBytes(cipher)
+		if err != nil {
+			t.Fatalf("inspect failed: %v", err)
+		}
+		if !h.Armored || len(h.Stanzas) != 1 {
+			t.Errorf("expected armored file with one stanza, got %+v", h)
+		}
+	})
+
+	t.Run("identifies scrypt stanzas", func(t *testing.T) {
+		r, err := age.NewScryptRecipient("passphrase")
+		if err != nil {
+			t.Fatalf("scrypt recipient failed: %v", err)
+		}
+		r.SetWorkFactor(10)
+		cipher, err := EncryptToMemory([]byte("x"), []age.Recipient{r}, false)
+		if err != nil {
+			t.Fatalf("encrypt failed: %v", err)
+		}
+		h, err := InspectHeaderBytes(cipher)
+		if err != nil {
+			t.Fatalf("inspect failed: %v", err)
+		}
+		if len(h.Stanzas) != 1 || h.Stanzas[0].Type != "scrypt" {
+			t.Errorf("expected one scrypt stanza, got %+v", h.Stanzas)
+		}
+	})
+
+	t.Run("rejects non-age content", func(t *testing.T) {
+		if _, err := InspectHeaderBytes([]byte("KEY=plaintext\n")); err == nil {
+			t.Error("expected error for plaintext input")
+		}
+	})
+
+	t.Run("rejects truncated headers", func(t *testing.T) {
+		if _, err := InspectHeaderBytes([]byte("age-encryption.org/v1\n-> X25519 abc\n")); err == nil {
+			t.Error("expected error for truncated header")
+		}
+	})
+}
+
+func TestDecrypt(t *testing.T) {
+	id, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatalf("failed to generate identity: %v", err)
+	}
+
+	t.Run("decrypts armored and binary ciphertext from memory", func(t *testing.T) {
+		for _, armored := range []bool{true, false} {
+			cipher, err := EncryptToMemory([]byte("hello"), []age.Recipient{id.Recipient()}, armored)
+			if err != nil {
+				t.Fatalf("encrypt failed: %v", err)
+			}
+			plain, err := Decrypt(cipher, []age.Identity{id})
+			if err != nil {
+				t.Fatalf("decrypt (armor=%v) failed: %v", armored, err)
+			}
+			if plain != "hello" {
+				t.Errorf("expected hello, got %q", plain)
+			}
+		}
+	})
+}
+
+func TestUnwrapEdgeCases(t *testing.T) {
+	id, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatalf("failed to generate identity: %v", err)
+	}
+	ids := []age.Identity{id}
+	encrypt := func(armored bool) []byte {
+		t.Helper()
+		cipher, err := EncryptToMemory([]byte("hello"), []age.Recipient{id.Recipient()}, armored)
+		if err != nil {
+			t.Fatalf("encrypt failed: %v", err)
+		}
+		return cipher
+	}
+	armored := string(encrypt(true))
+	binary := encrypt(false)
+
+	for name, input := range map[string]string{
+		"leading whitespace":              "\n\n  \t" + armored,
+		"leading garbage":                 "Here is the file you asked for:\n\n" + armored,
+		"CRLF line endings":               strings.ReplaceAll(armored, "\n", "\r\n"),
+		"appended signature":              armored + "-----BEGIN SSH SIGNATURE-----\nU1NIU0lH\n-----END SSH SIGNATURE-----\n",
+		"binary with leading blank lines": "\n\n" + string(binary),
+	} {
+		t.Run("decrypts with "+name, func(t *testing.T) {
+			plain, err := Decrypt([]byte(input), ids)
+			if err != nil {
+				t.Fatalf("decrypt failed: %v", err)
+			}
+			if plain != "hello" {
+				t.Errorf("expected hello, got %q", plain)
+			}
+			if _, err := InspectHeaderBytes([]byte(input)); err != nil {
+				t.Errorf("inspect failed: %v", err)
+			}
+		})
+	}
+
+	t.Run("decrypts files on disk with CRLF endings", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "crlf.age")
+		if err := os.WriteFile(path, []byte(strings.ReplaceAll(armored, "\n", "\r\n")), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if plain, err := DecryptToMemory(path, ids); err != nil || plain != "hello" {
+			t.Errorf("expected hello, got %q (err %v)", plain, err)
+		}
+	})
+
+	t.Run("rejects multiple armor blocks", func(t *testing.T) {
+		_, err := Decrypt([]byte(armored+armored), ids)
+		if err == nil || !strings.Contains(err.Error(), "2 age armor blocks") {
+			t.Errorf("expected multiple-block error, got %v", err)
+		}
+	})
+
+	t.Run("reports a missing armor footer as truncated", func(t *testing.T) {
+		cut := armored[:strings.Index(armored, "-----END")]
+		if _, err := Decrypt([]byte(cut), ids); !errors.Is(err, ErrTruncated) {
+			t.Errorf("expected ErrTruncated, got %v", err)
+		}
+	})
+
+	t.Run("reports truncated binary ciphertext", func(t *testing.T) {
+		for _, n := range []int{len(binary) - 5, 40} {
+			if _, err := Decrypt(binary[:n], ids); !errors.Is(err, ErrTruncated) {
+				t.Errorf("expected ErrTruncated at %d bytes, got %v", n, err)
+			}
+		}
+	})
+
+	t.Run("reports non-age input", func(t *testing.T) {
+		for _, input := range []string{"", "KEY=value\n", "-----BEGIN PGP MESSAGE-----\n"} {
+			if _, err := Decrypt([]byte(input), ids); !errors.Is(err, ErrNotAge) {
+				t.Errorf("expected ErrNotAge for %q, got %v", input, err)
+			}
+		}
+	})
+}
+
+func TestCheckStanzas(t *testing.T) {
+	h := Header{Stanzas: []Stanza{{Type: "X25519"}, {Type: "scrypt"}, {Type: "ssh-ed25519"}, {Type: "scrypt"}}}
+
+	t.Run("allows everything by default", func(t *testing.T) {
+		if err := CheckStanzas(h, model.StanzaPolicy{}); err != nil {
+			t.Errorf("expected no error, got %v", err)
+		}
+	})
+
+	t.Run("forbids scrypt", func(t *testing.T) {
+		got := StanzaViolations(h.StanzaTypes(), model.StanzaPolicy{ForbidScrypt: true})
+		if strings.Join(got, ",") != "scrypt" {
+			t.Errorf("expected scrypt once, got %v", got)
+		}
+	})
+
+	t.Run("requires X25519", func(t *testing.T) {
+		err := CheckStanzas(h, model.StanzaPolicy{RequireX25519: true})
+		if err == nil || !strings.Contains(err.Error(), "scrypt, ssh-ed25519") {
+			t.Errorf("expected scrypt and ssh-ed25519 to be named, got %v", err)
+		}
+	})
+}
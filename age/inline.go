@@ -0,0 +1,79 @@
+package age
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"filippo.io/age"
+	"github.com/charmbracelet/x/term"
+)
+
+// Identities that do not live in a file, for CI runners that get the key
+// from their secret store and should not write it to disk.
+const (
+	StdinIdentities = "-"                  // --identities -: read from stdin
+	EnvIdentities   = "env:" + IdentityEnv // the key material in $AGEPAD_IDENTITY
+	IdentityEnv     = "AGEPAD_IDENTITY"
+)
+
+// IsInline reports whether path names stdin or $AGEPAD_IDENTITY rather than
+// a file.
+func IsInline(path string) bool {
+	return path == StdinIdentities || path == EnvIdentities
+}
+
+// inline caches identities read from stdin or the environment, which can
+// only be read once.
+var inline struct {
+	sync.Mutex
+	ids map[string][]age.Identity
+}
+
+// loadInline reads identities from stdin or $AGEPAD_IDENTITY on first use
+// and returns the same identities afterwards (for example when the editor
+// re-authenticates). Stdin is consumed, and the variable is removed from
+// the environment so commands started by `agepad run` never see the key.
+func loadInline(path string) ([]age.Identity, error) {
+	inline.Lock()
+	defer inline.Unlock()
+	if ids, ok := inline.ids[path]; ok {
+		return ids, nil
+	}
+	var (
+		name string
+		b    []byte
+	)
+	switch path {
+	case StdinIdentities:
+		name = "stdin"
+		if term.IsTerminal(os.Stdin.Fd()) {
+			return nil, fmt.Errorf("--identities - reads the key from a pipe, but stdin is a terminal")
+		}
+		var err error
+		if b, err = io.ReadAll(os.Stdin); err != nil {
+			return nil, fmt.Errorf("reading identities from stdin: %w", err)
+		}
+	case EnvIdentities:
+		name = "$" + IdentityEnv
+		b = []byte(os.Getenv(IdentityEnv))
+		os.Unsetenv(IdentityEnv)
+	}
+	if len(bytes.TrimSpace(b)) == 0 {
+		return nil, fmt.Errorf("no identities in %s", name)
+	}
+	ids, err := parseIdentityBytes(name, b)
+	if !isSSHPrivateKey(b) {
+		clear(b) // encrypted SSH keys keep b until their passphrase is asked
+	}
+	if err != nil {
+		return nil, err
+	}
+	if inline.ids == nil {
+		inline.ids = map[string][]age.Identity{}
+	}
+	inline.ids[path] = ids
+	return ids, nil
+}
@@ -0,0 +1,206 @@
+package age
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// A KMS-wrapped identities file holds an age key encrypted by a cloud KMS
+// key, so the raw key never exists on the server's disk:
+//
+//	# agepad KMS-wrapped identity
+//	kms://arn:aws:kms:us-east-1:111122223333:key/1234abcd-...
+//	AQICAHh...base64 ciphertext...
+//
+// The kms:// line names the KMS key and the rest is the base64 ciphertext.
+// LoadIdentities unwraps it at runtime through the provider's CLI (aws or
+// gcloud), which uses the same credential chain as the provider's SDK
+// (instance roles, workload identity, environment variables).
+const kmsScheme = "kms://"
+
+// KMSProvider wraps and unwraps key material with one cloud KMS. Match
+// reports whether a key reference (without "kms://") belongs to it.
+type KMSProvider struct {
+	Name   string
+	Match  func(ref string) bool
+	Wrap   func(ref string, plain []byte) ([]byte, error)
+	Unwrap func(ref string, cipher []byte) ([]byte, error)
+}
+
+// kmsProviders are tried in order; RegisterKMS adds to the front, so a
+// program can replace a CLI-based provider with an SDK-based one.
+var kmsProviders = []KMSProvider{
+	{
+		Name:   "AWS KMS",
+		Match:  func(ref string) bool { return strings.HasPrefix(ref, "arn:aws") },
+		Wrap:   awsKMSWrap,
+		Unwrap: awsKMSUnwrap,
+	},
+	{
+		Name:   "GCP Cloud KMS",
+		Match:  func(ref string) bool { return strings.HasPrefix(ref, "projects/") },
+		Wrap:   gcpKMSWrap,
+		Unwrap: gcpKMSUnwrap,
+	},
+}
+
+// RegisterKMS adds p ahead of the built-in providers.
+func RegisterKMS(p KMSProvider) {
+	kmsProviders = append([]KMSProvider{p}, kmsProviders...)
+}
+
+func kmsProvider(ref string) (KMSProvider, error) {
+	for _, p := range kmsProviders {
+		if p.Match(ref) {
+			return p, nil
+		}
+	}
+	return KMSProvider{}, fmt.Errorf("unsupported KMS key %s%s (want an AWS KMS arn:aws:kms:... or a GCP projects/.../cryptoKeys/... name)", kmsScheme, ref)
+}
+
+// isKMSWrapped reports whether an identities file is a KMS-wrapped key:
+// its first line that is not blank or a comment starts with "kms://".
+func isKMSWrapped(b []byte) bool {
+	sc := bufio.NewScanner(bytes.NewReader(b))
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			return strings.HasPrefix(line, kmsScheme)
+		}
+	}
+	return false
+}
+
+// parseKMSFile splits a wrapped identities file into the key reference and
+// the ciphertext. Comments and blank lines are skipped.
+func parseKMSFile(b []byte) (ref string, cipher []byte, err error) {
+	var body strings.Builder
+	sc := bufio.NewScanner(bytes.NewReader(b))
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		switch {
+		case line == "" || strings.HasPrefix(line, "#"):
+		case ref == "":
+			var ok bool
+			if ref, ok = strings.CutPrefix(line, kmsScheme); !ok || ref == "" {
+				return "", nil, errors.New("not a KMS-wrapped identity")
+			}
+		default:
+			body.WriteString(line)
+		}
+	}
+	if ref == "" {
+		return "", nil, errors.New("not a KMS-wrapped identity")
+	}
+	cipher, err = base64.StdEncoding.DecodeString(body.String())
+	if err != nil || len(cipher) == 0 {
+		return "", nil, fmt.Errorf("KMS-wrapped identity for %s has no valid base64 ciphertext", ref)
+	}
+	return ref, cipher, nil
+}
+
+// unwrapKMS returns the age key material in a wrapped identities file.
+func unwrapKMS(b []byte) ([]byte, error) {
+	ref, cipher, err := parseKMSFile(b)
+	if err != nil {
+		return nil, err
+	}
+	p, err := kmsProvider(ref)
+	if err != nil {
+		return nil, err
+	}
+	plain, err := p.Unwrap(ref, cipher)
+	if err != nil {
+		return nil, fmt.Errorf("%s could not unwrap the identity with %s: %w", p.Name, ref, err)
+	}
+	return plain, nil
+}
+
+// WrapKMS encrypts identities (age key material) with the KMS key at ref
+// ("kms://..." or the bare reference) and returns a wrapped identities
+// file for LoadIdentities.
+func WrapKMS(ref string, identities []byte) ([]byte, error) {
+	ref = strings.TrimPrefix(ref, kmsScheme)
+	if _, err := ParseIdentities(bytes.NewReader(identities)); err != nil {
+		return nil, fmt.Errorf("refusing to wrap: %w", err)
+	}
+	p, err := kmsProvider(ref)
+	if err != nil {
+		return nil, err
+	}
+	cipher, err := p.Wrap(ref, identities)
+	if err == nil && len(cipher) == 0 {
+		err = errors.New("empty ciphertext")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s could not wrap the identity with %s: %w", p.Name, ref, err)
+	}
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "# agepad KMS-wrapped identity (%s); agepad unwraps it at runtime\n", p.Name)
+	fmt.Fprintf(&out, "%s%s\n", kmsScheme, ref)
+	enc := base64.StdEncoding.EncodeToString(cipher)
+	for len(enc) > 64 {
+		out.WriteString(enc[:64] + "\n")
+		enc = enc[64:]
+	}
+	out.WriteString(enc + "\n")
+	return out.Bytes(), nil
+}
+
+// kmsCLI runs a cloud CLI with stdin and returns its stdout; stderr is
+// kept for the error.
+var kmsCLI = func(stdin []byte, name string, args ...string) ([]byte, error) {
+	cmd := exec.Command(name, args...)
+	cmd.Stdin = bytes.NewReader(stdin)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if errors.Is(err, exec.ErrNotFound) {
+		return nil, fmt.Errorf("%s CLI not found in $PATH", name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w: %s", name, err, strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
+
+// AWS CLI v2 takes and prints blobs as base64 text. The ciphertext is not
+// secret and goes on the command line, which works on every platform; the
+// plaintext to wrap is read from /dev/stdin so it stays out of argv.
+func awsKMSUnwrap(ref string, cipher []byte) ([]byte, error) {
+	out, err := kmsCLI(nil, "aws", "kms", "decrypt", "--key-id", ref,
+		"--ciphertext-blob", base64.StdEncoding.EncodeToString(cipher), "--output", "text", "--query", "Plaintext")
+	if err != nil {
+		return nil, err
+	}
+	defer clear(out)
+	b64 := bytes.TrimSpace(out)
+	plain := make([]byte, base64.StdEncoding.DecodedLen(len(b64)))
+	n, err := base64.StdEncoding.Decode(plain, b64)
+	return plain[:n], err
+}
+
+func awsKMSWrap(ref string, plain []byte) ([]byte, error) {
+	out, err := kmsCLI(plain, "aws", "kms", "encrypt", "--key-id", ref,
+		"--plaintext", "fileb:///dev/stdin", "--output", "text", "--query", "CiphertextBlob")
+	if err != nil {
+		return nil, err
+	}
+	return base64.StdEncoding.DecodeString(strings.TrimSpace(string(out)))
+}
+
+// gcloud reads and writes raw bytes through "-".
+func gcpKMSUnwrap(ref string, cipher []byte) ([]byte, error) {
+	return kmsCLI(cipher, "gcloud", "kms", "decrypt", "--key", ref,
+		"--ciphertext-file", "-", "--plaintext-file", "-")
+}
+
+func gcpKMSWrap(ref string, plain []byte) ([]byte, error) {
+	return kmsCLI(plain, "gcloud", "kms", "encrypt", "--key", ref,
+		"--plaintext-file", "-", "--ciphertext-file", "-")
+}
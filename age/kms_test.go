@@ -0,0 +1,117 @@
+package age
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"filippo.io/age"
+)
+
+func TestKMSWrappedIdentities(t *testing.T) {
+	// A provider that "wraps" by reversing the bytes stands in for a cloud
+	// KMS.
+	reverse := func(b []byte) []byte {
+		out := bytes.Clone(b)
+		for i, j := 0, len(out)-1; i < j; i, j = i+1, j-1 {
+			out[i], out[j] = out[j], out[i]
+		}
+		return out
+	}
+	unwraps := 0
+	saved := kmsProviders
+	defer func() { kmsProviders = saved }()
+	RegisterKMS(KMSProvider{
+		Name:  "test KMS",
+		Match: func(ref string) bool { return strings.HasPrefix(ref, "test/") },
+		Wrap: func(ref string, plain []byte) ([]byte, error) {
+			return reverse(plain), nil
+		},
+		Unwrap: func(ref string, cipher []byte) ([]byte, error) {
+			unwraps++
+			if ref != "test/key-1" {
+				return nil, errors.New("access denied")
+			}
+			return reverse(cipher), nil
+		},
+	})
+
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatalf("failed to generate identity: %v", err)
+	}
+	wrapped, err := WrapKMS("kms://test/key-1", []byte(identity.String()+"\n"))
+	if err != nil {
+		t.Fatalf("WrapKMS: %v", err)
+	}
+	if bytes.Contains(wrapped, []byte("AGE-SECRET-KEY")) {
+		t.Fatal("wrapped file contains the raw key")
+	}
+	path := filepath.Join(t.TempDir(), "key.kms")
+	if err := os.WriteFile(path, wrapped, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("unwraps at load time", func(t *testing.T) {
+		ids, err := LoadIdentities(path)
+		if err != nil || len(ids) != 1 || unwraps != 1 {
+			t.Fatalf("expected 1 identity from 1 unwrap, got %d, %d, %v", len(ids), unwraps, err)
+		}
+		cipher, _ := EncryptToMemory([]byte("A=1"), []age.Recipient{identity.Recipient()}, false)
+		if plain, err := Decrypt(cipher, ids); err != nil || plain != "A=1" {
+			t.Errorf("unwrapped identity does not decrypt: %q, %v", plain, err)
+		}
+	})
+
+	t.Run("reports KMS failures", func(t *testing.T) {
+		other := strings.Replace(string(wrapped), "test/key-1", "test/key-2", 1)
+		if err := os.WriteFile(path, []byte(other), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadIdentities(path); err == nil || !strings.Contains(err.Error(), "access denied") {
+			t.Errorf("expected the KMS error, got %v", err)
+		}
+	})
+
+	t.Run("rejects unknown key references", func(t *testing.T) {
+		if err := os.WriteFile(path, []byte("kms://azure/vault/key\nAAAA\n"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadIdentities(path); err == nil || !strings.Contains(err.Error(), "unsupported KMS key") {
+			t.Errorf("expected an unsupported-key error, got %v", err)
+		}
+	})
+
+	t.Run("unwraps through the aws CLI", func(t *testing.T) {
+		var argv []string
+		savedCLI := kmsCLI
+		defer func() { kmsCLI = savedCLI }()
+		kmsCLI = func(stdin []byte, name string, args ...string) ([]byte, error) {
+			argv = append([]string{name}, args...)
+			return []byte(base64.StdEncoding.EncodeToString([]byte(identity.String())) + "\n"), nil
+		}
+		ref := "arn:aws:kms:us-east-1:111122223333:key/k"
+		file := "kms://" + ref + "\n" + base64.StdEncoding.EncodeToString([]byte("blob")) + "\n"
+		if err := os.WriteFile(path, []byte(file), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		ids, err := LoadIdentities(path)
+		if err != nil || len(ids) != 1 {
+			t.Fatalf("expected 1 identity, got %d, %v", len(ids), err)
+		}
+		want := "aws kms decrypt --key-id " + ref + " --ciphertext-blob YmxvYg== --output text --query Plaintext"
+		if got := strings.Join(argv, " "); got != want {
+			t.Errorf("unexpected command\n got %s\nwant %s", got, want)
+		}
+	})
+
+	t.Run("refuses to wrap something that is not a key", func(t *testing.T) {
+		if _, err := WrapKMS("kms://test/key-1", []byte("hello\n")); err == nil {
+			t.Error("expected WrapKMS to refuse non-identities")
+		}
+	})
+}
@@ -0,0 +1,49 @@
+package age
+
+import (
+	"fmt"
+
+	"filippo.io/age"
+	"github.com/andreweick/agepad/platform"
+	"github.com/charmbracelet/x/term"
+)
+
+// ReadPassphrase prompts on the controlling terminal without echo.
+func ReadPassphrase(prompt string) ([]byte, error) {
+	tty, err := platform.OpenTTY()
+	if err != nil {
+		return nil, fmt.Errorf("cannot ask for the passphrase: %w", err)
+	}
+	defer tty.Close()
+	fmt.Fprint(tty.Out, prompt)
+	defer fmt.Fprintln(tty.Out)
+	return term.ReadPassword(tty.In.Fd())
+}
+
+// Scrypt work factors (log2 N) of --passphrase: files are encrypted at
+// ScryptWorkFactor and opened up to ScryptMaxWorkFactor. They match the
+// age library's defaults, set here so version --security reports what is
+// used rather than what the library happens to default to.
+const (
+	ScryptWorkFactor    = 18
+	ScryptMaxWorkFactor = 22
+)
+
+// Passphrase returns the scrypt identity and recipient for pass, used to
+// open and save a passphrase-encrypted file.
+func Passphrase(pass string) (age.Identity, age.Recipient, error) {
+	if pass == "" {
+		return nil, nil, fmt.Errorf("empty passphrase")
+	}
+	id, err := age.NewScryptIdentity(pass)
+	if err != nil {
+		return nil, nil, err
+	}
+	id.SetMaxWorkFactor(ScryptMaxWorkFactor)
+	r, err := age.NewScryptRecipient(pass)
+	if err != nil {
+		return nil, nil, err
+	}
+	r.SetWorkFactor(ScryptWorkFactor)
+	return id, r, nil
+}
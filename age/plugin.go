@@ -0,0 +1,121 @@
+package age
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"filippo.io/age"
+	"filippo.io/age/plugin"
+	"github.com/andreweick/agepad/platform"
+)
+
+// pluginUI is shared by every plugin identity and recipient. SetPluginUI
+// swaps its callbacks in place, so keys parsed before the editor started
+// prompt through the editor afterwards.
+var pluginUI = func() *plugin.ClientUI {
+	ui := TerminalPluginUI()
+	return &ui
+}()
+
+// SetPluginUI replaces the callbacks age plugins (age-plugin-yubikey,
+// age-plugin-tpm, ...) use for messages, PIN prompts, confirmations, and
+// touch notices.
+func SetPluginUI(ui plugin.ClientUI) {
+	*pluginUI = ui
+}
+
+// TerminalPluginUI prompts on the controlling terminal and prints notices
+// to stderr. It is the default until SetPluginUI is called.
+func TerminalPluginUI() plugin.ClientUI {
+	return plugin.ClientUI{
+		DisplayMessage: func(name, message string) error {
+			fmt.Fprintf(os.Stderr, "age-plugin-%s: %s\n", name, message)
+			return nil
+		},
+		RequestValue: func(name, prompt string, secret bool) (string, error) {
+			prompt = fmt.Sprintf("age-plugin-%s: %s ", name, prompt)
+			if secret {
+				b, err := ReadPassphrase(prompt)
+				return string(b), err
+			}
+			return readLine(prompt)
+		},
+		Confirm: func(name, prompt, yes, no string) (bool, error) {
+			choices := yes
+			if no != "" {
+				choices += "/" + no
+			}
+			answer, err := readLine(fmt.Sprintf("age-plugin-%s: %s [%s] ", name, prompt, choices))
+			if err != nil {
+				return false, err
+			}
+			if no == "" {
+				return true, nil
+			}
+			return answer != "" && strings.HasPrefix(strings.ToLower(yes), strings.ToLower(answer)), nil
+		},
+		WaitTimer: func(name string) {
+			fmt.Fprintf(os.Stderr, "age-plugin-%s: waiting on the plugin (touch your security key?)\n", name)
+		},
+	}
+}
+
+// readLine prompts on the controlling terminal with echo.
+func readLine(prompt string) (string, error) {
+	tty, err := platform.OpenTTY()
+	if err != nil {
+		return "", fmt.Errorf("cannot answer the plugin: %w", err)
+	}
+	defer tty.Close()
+	fmt.Fprint(tty.Out, prompt)
+	line, err := bufio.NewReader(tty.In).ReadString('\n')
+	if err != nil && line == "" {
+		return "", err
+	}
+	return strings.TrimSpace(line), nil
+}
+
+// ParseIdentities reads an identities file: age secret keys and plugin
+// identities ("AGE-PLUGIN-YUBIKEY-1..."), one per line. Blank lines and "#"
+// comments are skipped. Plugin identities run age-plugin-<name> from $PATH
+// when a file is decrypted, not when they are parsed.
+func ParseIdentities(r io.Reader) ([]age.Identity, error) {
+	var ids []age.Identity
+	sc := bufio.NewScanner(r)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasPrefix(line, "AGE-PLUGIN-") {
+			id, err := plugin.NewIdentity(line, pluginUI)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", n, err)
+			}
+			ids = append(ids, id)
+			continue
+		}
+		parsed, err := age.ParseIdentities(strings.NewReader(line))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		ids = append(ids, parsed...)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return nil, fmt.Errorf("no identities found")
+	}
+	return ids, nil
+}
+
+// isPluginRecipient reports whether s is a plugin recipient
+// ("age1yubikey1..."), as opposed to an X25519 one ("age1...").
+func isPluginRecipient(s string) bool {
+	_, _, err := plugin.ParseRecipient(s)
+	return err == nil
+}
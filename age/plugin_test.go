@@ -0,0 +1,99 @@
+package age
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"filippo.io/age"
+	"filippo.io/age/plugin"
+)
+
+func TestPluginKeys(t *testing.T) {
+	x, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	recipient := plugin.EncodeRecipient("agepadtest", []byte{1, 2, 3})
+	identity := plugin.EncodeIdentity("agepadtest", []byte{4, 5, 6})
+
+	t.Run("parses plugin recipients next to age and ssh ones", func(t *testing.T) {
+		rs, err := ParseRecipients(strings.NewReader(x.Recipient().String() + "\n# hardware key\n" + recipient + "\n"))
+		if err != nil {
+			t.Fatalf("ParseRecipients: %v", err)
+		}
+		if len(rs) != 2 {
+			t.Fatalf("expected 2 recipients, got %d", len(rs))
+		}
+		if p, ok := rs[1].(*plugin.Recipient); !ok || p.Name() != "agepadtest" {
+			t.Errorf("expected a plugin recipient for agepadtest, got %T", rs[1])
+		}
+	})
+
+	t.Run("loads plugin identities from an identities file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "keys.txt")
+		content := "# created: today\n" + x.String() + "\n" + identity + "\n"
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		ids, err := LoadIdentities(path)
+		if err != nil {
+			t.Fatalf("LoadIdentities: %v", err)
+		}
+		if len(ids) != 2 {
+			t.Fatalf("expected 2 identities, got %d", len(ids))
+		}
+		if p, ok := ids[1].(*plugin.Identity); !ok || p.Name() != "agepadtest" {
+			t.Errorf("expected a plugin identity for agepadtest, got %T", ids[1])
+		}
+	})
+
+	t.Run("names the line of a bad identity", func(t *testing.T) {
+		_, err := ParseIdentities(strings.NewReader(x.String() + "\nAGE-PLUGIN-BROKEN\n"))
+		if err == nil || !strings.Contains(err.Error(), "line 2") {
+			t.Errorf("expected a line 2 error, got %v", err)
+		}
+	})
+
+	t.Run("names the missing plugin binary", func(t *testing.T) {
+		t.Setenv("PATH", t.TempDir())
+		rs, err := ParseRecipients(strings.NewReader(recipient))
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = EncryptToMemory([]byte("A=1"), rs, false)
+		if err == nil || !strings.Contains(err.Error(), "age-plugin-agepadtest") {
+			t.Errorf("expected an error naming age-plugin-agepadtest, got %v", err)
+		}
+	})
+
+	t.Run("skips unusable lines when asked", func(t *testing.T) {
+		t.Setenv("PATH", t.TempDir())
+		path := filepath.Join(t.TempDir(), "recipients")
+		content := x.Recipient().String() + " # alice\nage1notakey\n" + recipient + " # yubikey\n"
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadRecipients(path); err == nil || !strings.Contains(err.Error(), "line 2 (age1notakey)") {
+			t.Errorf("expected a strict load to name line 2, got %v", err)
+		}
+		rs, bad, err := LoadRecipientsSkipping(path)
+		if err != nil {
+			t.Fatalf("LoadRecipientsSkipping: %v", err)
+		}
+		if len(rs) != 1 || len(bad) != 2 || bad[0].Line != 2 || bad[1].Line != 3 {
+			t.Fatalf("expected 1 recipient and lines 2 and 3 skipped, got %d and %v", len(rs), bad)
+		}
+		if !strings.Contains(bad[1].Error(), "age-plugin-agepadtest not found") {
+			t.Errorf("expected the missing plugin to be named, got %v", bad[1])
+		}
+
+		if err := os.WriteFile(path, []byte("age1notakey\n"), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if _, _, err := LoadRecipientsSkipping(path); err == nil || !strings.Contains(err.Error(), "no usable recipients") {
+			t.Errorf("expected a file of only bad lines to fail, got %v", err)
+		}
+	})
+}
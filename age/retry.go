@@ -0,0 +1,62 @@
+package age
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"filippo.io/age"
+)
+
+// RetryPolicy controls how WriteRetrying retries transient write failures.
+type RetryPolicy struct {
+	Attempts int           // total tries, including the first
+	Backoff  time.Duration // wait before the second try, doubled for each one after
+	// OnRetry, when set, is called before each wait.
+	OnRetry func(attempt int, wait time.Duration, err error)
+}
+
+// DefaultRetry rides out a few seconds of a busy network mount or a
+// scanner holding the file.
+var DefaultRetry = RetryPolicy{Attempts: 5, Backoff: 250 * time.Millisecond}
+
+// IsTransient reports whether a write failed for a reason that may clear on
+// its own: a busy or locked file (network mounts, antivirus scanners on
+// Windows), an interrupted call, or a stale NFS handle.
+func IsTransient(err error) bool {
+	for _, t := range transientErrors {
+		if errors.Is(err, t) {
+			return true
+		}
+	}
+	return false
+}
+
+// WriteRetrying is AtomicWrite, retried with backoff while the failure is
+// transient. The ciphertext is the same on every try.
+func WriteRetrying(dstPath string, cipher []byte, p RetryPolicy) error {
+	wait := p.Backoff
+	for attempt := 1; ; attempt++ {
+		err := AtomicWrite(dstPath, cipher)
+		if err == nil || !IsTransient(err) {
+			return err
+		}
+		if attempt >= p.Attempts {
+			return fmt.Errorf("%w (gave up after %d attempts)", err, attempt)
+		}
+		if p.OnRetry != nil {
+			p.OnRetry(attempt, wait, err)
+		}
+		time.Sleep(wait)
+		wait *= 2
+	}
+}
+
+// EncryptWriteRetrying encrypts b once and writes it with WriteRetrying.
+func EncryptWriteRetrying(dstPath string, b []byte, recips []age.Recipient, useArmor bool, p RetryPolicy) error {
+	cipher, err := EncryptToMemory(b, recips, useArmor)
+	if err != nil {
+		return fmt.Errorf("encrypt: %w", err)
+	}
+	return WriteRetrying(dstPath, cipher, p)
+}
@@ -0,0 +1,205 @@
+package age
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+
+	"filippo.io/age"
+	"filippo.io/age/agessh"
+	"filippo.io/age/plugin"
+	"golang.org/x/crypto/ssh"
+)
+
+// ParseRecipients reads one recipient per line: age public keys (and
+// anything else age.ParseRecipients accepts), plugin recipients such as
+// "age1yubikey1...", or OpenSSH "ssh-ed25519" and "ssh-rsa" public key
+// lines, as found in authorized_keys. Blank lines and "#" comments are
+// skipped, and a line may end in a "# label" comment (see
+// ParseLabeledRecipients).
+func ParseRecipients(r io.Reader) ([]age.Recipient, error) {
+	ls, err := ParseLabeledRecipients(r)
+	if err != nil {
+		return nil, err
+	}
+	rs := make([]age.Recipient, len(ls))
+	for i, l := range ls {
+		rs[i] = l.Recipient
+	}
+	return rs, nil
+}
+
+// LabeledRecipient is one line of a recipients file.
+type LabeledRecipient struct {
+	Label     string // the line's "# label" comment, else an SSH key's own comment
+	Key       string // the line without its label comment
+	Recipient age.Recipient
+}
+
+// ParseLabeledRecipients reads recipient lines as ParseRecipients does and
+// keeps the name each one goes by:
+//
+//	age1ql3z7hjy54pw3hyww5ayyfg7zqgvc7w3j2elw8zmrj2kg5sfn9aqmcac8p # alice
+//	ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAI... bob@laptop
+//
+// Labels may be empty; nothing requires them to be unique. A line that does
+// not parse fails the whole file with a *RecipientLineError.
+func ParseLabeledRecipients(r io.Reader) ([]LabeledRecipient, error) {
+	out, bad, err := parseRecipientLines(r, false)
+	if err != nil {
+		return nil, err
+	}
+	if len(bad) > 0 {
+		return nil, bad[0]
+	}
+	return out, nil
+}
+
+// ParseLabeledRecipientsSkipping reads recipient lines as
+// ParseLabeledRecipients does, but sets aside the lines it cannot use
+// instead of failing: lines that do not parse, and plugin recipients whose
+// age-plugin-<name> is not on $PATH (which would only fail at encryption).
+func ParseLabeledRecipientsSkipping(r io.Reader) ([]LabeledRecipient, []*RecipientLineError, error) {
+	return parseRecipientLines(r, true)
+}
+
+// RecipientLineError is a recipients file line that cannot be used.
+type RecipientLineError struct {
+	Line int
+	Key  string // the line without its label comment
+	Err  error
+}
+
+func (e *RecipientLineError) Error() string {
+	key := e.Key
+	if r := []rune(key); len(r) > 24 {
+		key = string(r[:20]) + "…"
+	}
+	return fmt.Sprintf("line %d (%s): %v", e.Line, key, e.Err)
+}
+
+func (e *RecipientLineError) Unwrap() error { return e.Err }
+
+func parseRecipientLines(r io.Reader, checkPlugins bool) ([]LabeledRecipient, []*RecipientLineError, error) {
+	var (
+		out []LabeledRecipient
+		bad []*RecipientLineError
+	)
+	sc := bufio.NewScanner(r)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		var label string
+		// Keys never contain '#', so whitespace then '#' starts the label.
+		if i := strings.IndexByte(line, '#'); i > 0 && (line[i-1] == ' ' || line[i-1] == '\t') {
+			line, label = strings.TrimSpace(line[:i]), strings.TrimSpace(line[i+1:])
+		}
+		var (
+			parsed []age.Recipient
+			err    error
+		)
+		switch {
+		case strings.HasPrefix(line, "ssh-"):
+			var rcpt age.Recipient
+			if rcpt, err = agessh.ParseRecipient(line); err == nil {
+				parsed = []age.Recipient{rcpt}
+			}
+			if f := strings.Fields(line); label == "" && len(f) > 2 {
+				label = strings.Join(f[2:], " ")
+			}
+		case isPluginRecipient(line):
+			var rcpt *plugin.Recipient
+			if rcpt, err = plugin.NewRecipient(line, pluginUI); err == nil {
+				parsed = []age.Recipient{rcpt}
+			}
+			if err == nil && checkPlugins {
+				if _, lerr := exec.LookPath("age-plugin-" + rcpt.Name()); lerr != nil {
+					err = fmt.Errorf("age-plugin-%s not found in $PATH", rcpt.Name())
+				}
+			}
+		default:
+			// age's own error counts lines from this one; say what it is
+			// instead.
+			if parsed, err = age.ParseRecipients(strings.NewReader(line)); err != nil {
+				err = errors.New("not a valid age, SSH, or plugin recipient (a typo, or a kind this version of age does not know)")
+			}
+		}
+		if err != nil {
+			bad = append(bad, &RecipientLineError{Line: n, Key: line, Err: err})
+			continue
+		}
+		for _, rcpt := range parsed {
+			out = append(out, LabeledRecipient{Label: label, Key: line, Recipient: rcpt})
+		}
+	}
+	return out, bad, sc.Err()
+}
+
+// Fingerprint identifies the recipient for people comparing keys: an SSH
+// key's "SHA256:..." fingerprint as ssh-keygen -l prints it, and for other
+// keys the same hash of the key text, shortened.
+func (l LabeledRecipient) Fingerprint() string {
+	if pub, _, _, _, err := ssh.ParseAuthorizedKey([]byte(l.Key)); err == nil {
+		return ssh.FingerprintSHA256(pub)
+	}
+	sum := sha256.Sum256([]byte(l.Key))
+	return "SHA256:" + base64.RawStdEncoding.EncodeToString(sum[:])[:16]
+}
+
+// InHeader reports whether the recipient has a stanza in h. Only SSH
+// stanzas name their key (by a short tag of it); for other recipients
+// known is false.
+func (l LabeledRecipient) InHeader(h Header) (found, known bool) {
+	pub, _, _, _, err := ssh.ParseAuthorizedKey([]byte(l.Key))
+	if err != nil {
+		return false, false
+	}
+	sum := sha256.Sum256(pub.Marshal())
+	tag := base64.RawStdEncoding.EncodeToString(sum[:4])
+	for _, s := range h.Stanzas {
+		if s.Type == pub.Type() && len(s.Args) > 0 && s.Args[0] == tag {
+			return true, true
+		}
+	}
+	return false, true
+}
+
+// isSSHPrivateKey reports whether b is a PEM-encoded private key (OpenSSH,
+// PKCS#1, or PKCS#8), rather than an age identity file.
+func isSSHPrivateKey(b []byte) bool {
+	return bytes.Contains(b, []byte("-----BEGIN ")) && bytes.Contains(b, []byte("PRIVATE KEY-----"))
+}
+
+// parseSSHIdentity reads an ed25519 or RSA private key. A passphrase-
+// protected key is asked for its passphrase on the terminal, only once a
+// file actually has a stanza for it.
+func parseSSHIdentity(path string, pemBytes []byte) (age.Identity, error) {
+	id, err := agessh.ParseIdentity(pemBytes)
+	var missing *ssh.PassphraseMissingError
+	if !errors.As(err, &missing) {
+		return id, err
+	}
+	pub := missing.PublicKey
+	if pub == nil {
+		// Older PEM formats do not carry the public key; use the .pub file.
+		b, err := os.ReadFile(path + ".pub")
+		if err != nil {
+			return nil, fmt.Errorf("%s is passphrase-protected and has no readable %s.pub: %w", path, path, err)
+		}
+		if pub, _, _, _, err = ssh.ParseAuthorizedKey(b); err != nil {
+			return nil, fmt.Errorf("%s.pub: %w", path, err)
+		}
+	}
+	return agessh.NewEncryptedSSHIdentity(pub, pemBytes, func() ([]byte, error) {
+		return ReadPassphrase("Enter passphrase for SSH key " + path + ": ")
+	})
+}
@@ -0,0 +1,163 @@
+package age
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"filippo.io/age"
+	"golang.org/x/crypto/ssh"
+)
+
+// writeSSHKey writes key as an OpenSSH private key (passphrase-protected
+// when passphrase is set) and returns its path and authorized_keys line.
+func writeSSHKey(t *testing.T, key any, passphrase string) (string, string) {
+	t.Helper()
+	var (
+		block *pem.Block
+		err   error
+	)
+	if passphrase == "" {
+		block, err = ssh.MarshalPrivateKey(key, "")
+	} else {
+		block, err = ssh.MarshalPrivateKeyWithPassphrase(key, "", []byte(passphrase))
+	}
+	if err != nil {
+		t.Fatalf("marshal key failed: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "id")
+	if err := os.WriteFile(path, pem.EncodeToMemory(block), 0600); err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatalf("signer failed: %v", err)
+	}
+	return path, strings.TrimSpace(string(ssh.MarshalAuthorizedKey(signer.PublicKey())))
+}
+
+func TestSSHKeys(t *testing.T) {
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generate ed25519 failed: %v", err)
+	}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate rsa failed: %v", err)
+	}
+
+	for name, key := range map[string]any{"ed25519": edKey, "rsa": rsaKey} {
+		t.Run("round trips with an "+name+" key", func(t *testing.T) {
+			keyPath, pub := writeSSHKey(t, key, "")
+			recipPath := filepath.Join(t.TempDir(), ".age-recipients")
+			if err := os.WriteFile(recipPath, []byte("# team\n"+pub+" alice@laptop\n"), 0644); err != nil {
+				t.Fatal(err)
+			}
+			recips, err := LoadRecipients(recipPath)
+			if err != nil || len(recips) != 1 {
+				t.Fatalf("expected one recipient, got %d (%v)", len(recips), err)
+			}
+			ids, err := LoadIdentities(keyPath)
+			if err != nil {
+				t.Fatalf("load identities failed: %v", err)
+			}
+			cipher, err := EncryptToMemory([]byte("S=1"), recips, true)
+			if err != nil {
+				t.Fatalf("encrypt failed: %v", err)
+			}
+			if plain, err := Decrypt(cipher, ids); err != nil || plain != "S=1" {
+				t.Errorf("expected S=1, got %q (%v)", plain, err)
+			}
+		})
+	}
+
+	t.Run("finds ssh recipients in a header", func(t *testing.T) {
+		_, pub := writeSSHKey(t, edKey, "")
+		_, other := writeSSHKey(t, rsaKey, "")
+		x, _ := age.GenerateX25519Identity()
+		ls, err := ParseLabeledRecipients(strings.NewReader(pub + " # alice\n" + other + "\n" + x.Recipient().String() + "\n"))
+		if err != nil || len(ls) != 3 {
+			t.Fatalf("expected three recipients, got %d (%v)", len(ls), err)
+		}
+		cipher, err := EncryptToMemory([]byte("S=1"), []age.Recipient{ls[0].Recipient, ls[2].Recipient}, false)
+		if err != nil {
+			t.Fatalf("encrypt failed: %v", err)
+		}
+		h, err := InspectHeaderBytes(cipher)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if found, known := ls[0].InHeader(h); !found || !known {
+			t.Errorf("expected alice's key found, got found=%v known=%v", found, known)
+		}
+		if found, known := ls[1].InHeader(h); found || !known {
+			t.Errorf("expected the rsa key known absent, got found=%v known=%v", found, known)
+		}
+		if _, known := ls[2].InHeader(h); known {
+			t.Error("expected an X25519 recipient to be unknown from the header")
+		}
+		want, _, _, _, _ := ssh.ParseAuthorizedKey([]byte(pub))
+		if got := ls[0].Fingerprint(); got != ssh.FingerprintSHA256(want) {
+			t.Errorf("got fingerprint %q", got)
+		}
+		if got := ls[2].Fingerprint(); !strings.HasPrefix(got, "SHA256:") || len(got) != 23 || got == ls[1].Fingerprint() {
+			t.Errorf("got fingerprint %q for an age key", got)
+		}
+	})
+
+	t.Run("mixes age and ssh recipients", func(t *testing.T) {
+		_, pub := writeSSHKey(t, edKey, "")
+		id, _ := age.GenerateX25519Identity()
+		rs, err := ParseRecipients(strings.NewReader(id.Recipient().String() + "\n\n" + pub + "\n"))
+		if err != nil || len(rs) != 2 {
+			t.Errorf("expected two recipients, got %d (%v)", len(rs), err)
+		}
+	})
+
+	t.Run("labels recipients", func(t *testing.T) {
+		_, pub := writeSSHKey(t, edKey, "")
+		id, _ := age.GenerateX25519Identity()
+		in := id.Recipient().String() + "  # alice\n" + pub + " bob@laptop\n" + pub + " bob@laptop # bob\n"
+		ls, err := ParseLabeledRecipients(strings.NewReader(in))
+		if err != nil || len(ls) != 3 {
+			t.Fatalf("expected three recipients, got %d (%v)", len(ls), err)
+		}
+		for i, want := range []string{"alice", "bob@laptop", "bob"} {
+			if ls[i].Label != want {
+				t.Errorf("line %d: expected label %q, got %q", i+1, want, ls[i].Label)
+			}
+		}
+		if ls[0].Key != id.Recipient().String() {
+			t.Errorf("expected the bare key, got %q", ls[0].Key)
+		}
+	})
+
+	t.Run("names the line of a bad recipient", func(t *testing.T) {
+		_, err := ParseRecipients(strings.NewReader("# c\nssh-ed25519 AAAAnotbase64\n"))
+		if err == nil || !strings.Contains(err.Error(), "line 2") {
+			t.Errorf("expected a line 2 error, got %v", err)
+		}
+	})
+
+	t.Run("defers the passphrase until a file needs the key", func(t *testing.T) {
+		keyPath, _ := writeSSHKey(t, edKey, "hunter2")
+		ids, err := LoadIdentities(keyPath)
+		if err != nil {
+			t.Fatalf("load identities failed: %v", err)
+		}
+		other, _ := age.GenerateX25519Identity()
+		cipher, err := EncryptToMemory([]byte("x"), []age.Recipient{other.Recipient()}, false)
+		if err != nil {
+			t.Fatalf("encrypt failed: %v", err)
+		}
+		// No stanza matches the key, so no prompt is attempted.
+		if _, err := Decrypt(cipher, ids); err == nil || strings.Contains(err.Error(), "passphrase") {
+			t.Errorf("expected a no-match error without a passphrase prompt, got %v", err)
+		}
+	})
+}
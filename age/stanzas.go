@@ -0,0 +1,111 @@
+package age
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"fmt"
+	"slices"
+	"strings"
+
+	"filippo.io/age"
+	"filippo.io/age/plugin"
+	"github.com/andreweick/agepad/model"
+	"golang.org/x/crypto/ssh"
+)
+
+// probeRSAKey is a throwaway 2048-bit public key, so RecipientTypes does not
+// generate one at every call.
+const probeRSAKey = "ssh-rsa AAAAB3NzaC1yc2EAAAADAQABAAABAQD17Gb43HekeYtuNH6ts/beb3432d559uS8ftrSXmvRAJ3Dra6lKOq8U27zfGI/pHoh/SM/JCCi7bjvh+U8Mc7grvQttot5pdD9KLkui5rxoN5Dzs1YBpqXp6WZEnqoa6bmtwBdUY2QpIeOZxU7eXKzmReVnCcOUJFY35gfA74r4QuPO7kGsuAppYebTs/LfiyeLJl4UoSFrgqoZdBHO2Q22CA8PFFZ8r+GUyOjc1LDGkEq0LGIeJxYeLJSgf7Jv4XJ9Mk0rM43fanD3+uGui65JPAgwrPpfb0c+cJwcMPGrX15uBQaLOl2P9sjR56YXSVNAVQ1ywL9E6Rm5TtPKBuT probe"
+
+// RecipientTypes lists the stanza types agepad can encrypt to. It puts a
+// probe key of each kind through the recipients file parser and wraps a
+// file key to what it returns, so the list is what this build accepts and
+// writes. Plugin recipients would run their plugin to wrap, so they are
+// listed as "plugin" once they parse; "scrypt" is --passphrase.
+func RecipientTypes() ([]string, error) {
+	x, err := age.GenerateX25519Identity()
+	if err != nil {
+		return nil, err
+	}
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	sshPub, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		return nil, err
+	}
+	probes := []string{
+		x.Recipient().String(),
+		strings.TrimSpace(string(ssh.MarshalAuthorizedKey(sshPub))),
+		probeRSAKey,
+		plugin.EncodeRecipient("probe", []byte{0}),
+	}
+	ls, _, err := parseRecipientLines(strings.NewReader(strings.Join(probes, "\n")), false)
+	if err != nil {
+		return nil, err
+	}
+	rs := make([]age.Recipient, 0, len(ls)+1)
+	var out []string
+	for _, l := range ls {
+		if _, ok := l.Recipient.(*plugin.Recipient); ok {
+			out = append(out, "plugin")
+			continue
+		}
+		rs = append(rs, l.Recipient)
+	}
+	_, scrypt, err := Passphrase("probe")
+	if err != nil {
+		return nil, err
+	}
+	scrypt.(*age.ScryptRecipient).SetWorkFactor(1) // only the stanza type is read
+	rs = append(rs, scrypt)
+
+	fileKey := make([]byte, 16)
+	for _, r := range rs {
+		ss, err := r.Wrap(fileKey)
+		if err != nil {
+			return nil, err
+		}
+		for _, s := range ss {
+			if !slices.Contains(out, s.Type) {
+				out = append(out, s.Type)
+			}
+		}
+	}
+	slices.Sort(out)
+	return out, nil
+}
+
+// StanzaViolations returns the stanza types (as from Header.StanzaTypes)
+// that p disallows, once each, in header order.
+func StanzaViolations(types []string, p model.StanzaPolicy) []string {
+	var out []string
+	seen := map[string]bool{}
+	for _, t := range types {
+		bad := (p.ForbidScrypt && t == "scrypt") || (p.RequireX25519 && t != "X25519")
+		if bad && !seen[t] {
+			seen[t] = true
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+// CheckStanzas returns an error naming the stanza types in h that p
+// disallows, or nil when the header complies.
+func CheckStanzas(h Header, p model.StanzaPolicy) error {
+	bad := StanzaViolations(h.StanzaTypes(), p)
+	if len(bad) == 0 {
+		return nil
+	}
+	var rules []string
+	if p.RequireX25519 {
+		rules = append(rules, "--require-x25519")
+	}
+	if p.ForbidScrypt {
+		rules = append(rules, "--forbid-scrypt")
+	}
+	return fmt.Errorf("header has disallowed stanza type(s) %s (%s)",
+		strings.Join(bad, ", "), strings.Join(rules, ", "))
+}
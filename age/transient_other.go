@@ -0,0 +1,5 @@
+//go:build !unix && !windows
+
+package age
+
+var transientErrors []error
@@ -0,0 +1,13 @@
+//go:build unix
+
+package age
+
+import "syscall"
+
+var transientErrors = []error{
+	syscall.EBUSY,
+	syscall.EAGAIN,
+	syscall.EINTR,
+	syscall.ETXTBSY,
+	syscall.ESTALE,
+}
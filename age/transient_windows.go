@@ -0,0 +1,18 @@
+//go:build windows
+
+package age
+
+import "syscall"
+
+// Raised while another process (often an antivirus scanner or indexer)
+// holds the file or a byte range of it open.
+const (
+	errorSharingViolation syscall.Errno = 32
+	errorLockViolation    syscall.Errno = 33
+)
+
+var transientErrors = []error{
+	errorSharingViolation,
+	errorLockViolation,
+	syscall.EBUSY,
+}
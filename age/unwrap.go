@@ -0,0 +1,51 @@
+package age
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+
+	"filippo.io/age/armor"
+)
+
+var (
+	// ErrNotAge means the input holds neither an age header nor an armor block.
+	ErrNotAge = errors.New("not an age file: no " + headerIntro + " header or " + armor.Header + " block")
+	// ErrTruncated means the ciphertext ends early or fails to decode.
+	ErrTruncated = errors.New("corrupted or truncated ciphertext")
+)
+
+// unwrap finds the age ciphertext in b and reports whether it was armored.
+//
+// Binary files may have leading whitespace. Armored files may also have
+// anything before the BEGIN line or after the END line (a pasted
+// preamble, an appended signature), and CRLF line endings. A file with
+// more than one age armor block is rejected rather than guessing which
+// one is meant.
+func unwrap(b []byte) (io.Reader, bool, error) {
+	if bytes.HasPrefix(b, []byte(headerIntro+"\n")) {
+		return bytes.NewReader(b), false, nil
+	}
+	begin := bytes.Index(b, []byte(armor.Header))
+	if begin < 0 {
+		trimmed := bytes.TrimLeft(b, " \t\r\n")
+		if bytes.HasPrefix(trimmed, []byte(headerIntro+"\n")) {
+			return bytes.NewReader(trimmed), false, nil
+		}
+		if len(bytes.TrimSpace(b)) == 0 {
+			return nil, false, fmt.Errorf("%w (file is empty)", ErrNotAge)
+		}
+		return nil, false, ErrNotAge
+	}
+	block := b[begin:]
+	end := bytes.Index(block, []byte(armor.Footer))
+	if end < 0 {
+		return nil, true, fmt.Errorf("%w: armored file has no %s line", ErrTruncated, armor.Footer)
+	}
+	if n := bytes.Count(block[end:], []byte(armor.Header)); n > 0 {
+		return nil, true, fmt.Errorf("found %d age armor blocks; expected one per file", n+1)
+	}
+	block = bytes.ReplaceAll(block[:end+len(armor.Footer)], []byte("\r\n"), []byte("\n"))
+	return armor.NewReader(bytes.NewReader(append(block, '\n'))), true, nil
+}
@@ -0,0 +1,94 @@
+// Package audit appends plaintext-free records of sensitive agepad actions
+// (shares, for example) to a local JSON-lines log.
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/andreweick/agepad/platform"
+)
+
+// Event is one audit record. Details must never carry secret values.
+type Event struct {
+	Time    time.Time         `json:"time"`
+	Action  string            `json:"action"`
+	File    string            `json:"file,omitempty"`
+	User    string            `json:"user,omitempty"`
+	Details map[string]string `json:"details,omitempty"`
+}
+
+// DefaultPath returns $AGEPAD_AUDIT_LOG, or audit.log under
+// $XDG_STATE_HOME/agepad (default ~/.local/state/agepad; %LocalAppData%\agepad
+// on Windows).
+func DefaultPath() string {
+	if p := os.Getenv("AGEPAD_AUDIT_LOG"); p != "" {
+		return p
+	}
+	return filepath.Join(platform.StateDir(), "agepad", "audit.log")
+}
+
+// Log is an append-only JSON-lines audit log.
+type Log struct {
+	Path string
+}
+
+// Record appends e, filling in the time and user when unset. The log and
+// its directory are created private to the current user.
+func (l Log) Record(e Event) error {
+	if e.Time.IsZero() {
+		e.Time = time.Now().UTC()
+	}
+	if e.User == "" {
+		e.User = os.Getenv("USER")
+	}
+	b, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("audit: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(l.Path), 0700); err != nil {
+		return fmt.Errorf("audit: %w", err)
+	}
+	f, err := os.OpenFile(l.Path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return fmt.Errorf("audit: %w", err)
+	}
+	// Concurrent agepad processes must not interleave records. Appends are
+	// not atomic on Windows; elsewhere the lock costs next to nothing.
+	if err := platform.LockFile(f); err == nil {
+		defer platform.UnlockFile(f)
+	}
+	if _, err := f.Write(append(b, '\n')); err != nil {
+		f.Close()
+		return fmt.Errorf("audit: %w", err)
+	}
+	return f.Close()
+}
+
+// Read returns all events in the log, oldest first. A missing log is empty.
+func (l Log) Read() ([]Event, error) {
+	f, err := os.Open(l.Path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("audit: %w", err)
+	}
+	defer f.Close()
+
+	var out []Event
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for n := 1; sc.Scan(); n++ {
+		var e Event
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("audit: %s line %d: %w", l.Path, n, err)
+		}
+		out = append(out, e)
+	}
+	return out, sc.Err()
+}
@@ -0,0 +1,62 @@
+package audit
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLog(t *testing.T) {
+	t.Run("appends and reads events", func(t *testing.T) {
+		l := Log{Path: filepath.Join(t.TempDir(), "state", "audit.log")}
+		if err := l.Record(Event{Action: "share", File: "a.age", Details: map[string]string{"to": "age1x"}}); err != nil {
+			t.Fatalf("record failed: %v", err)
+		}
+		if err := l.Record(Event{Action: "share", File: "b.age"}); err != nil {
+			t.Fatalf("record failed: %v", err)
+		}
+
+		events, err := l.Read()
+		if err != nil {
+			t.Fatalf("read failed: %v", err)
+		}
+		if len(events) != 2 || events[0].File != "a.age" || events[0].Details["to"] != "age1x" {
+			t.Errorf("unexpected events: %+v", events)
+		}
+		if events[0].Time.IsZero() {
+			t.Error("expected time to be filled in")
+		}
+
+		info, err := os.Stat(l.Path)
+		if err != nil {
+			t.Fatalf("stat failed: %v", err)
+		}
+		if info.Mode().Perm() != 0600 {
+			t.Errorf("expected 0600 log, got %v", info.Mode().Perm())
+		}
+	})
+
+	t.Run("treats a missing log as empty", func(t *testing.T) {
+		events, err := Log{Path: filepath.Join(t.TempDir(), "none.log")}.Read()
+		if err != nil || len(events) != 0 {
+			t.Errorf("expected no events, got %v (%v)", events, err)
+		}
+	})
+}
+
+func TestDefaultPath(t *testing.T) {
+	t.Run("honors AGEPAD_AUDIT_LOG", func(t *testing.T) {
+		t.Setenv("AGEPAD_AUDIT_LOG", "/tmp/custom.log")
+		if got := DefaultPath(); got != "/tmp/custom.log" {
+			t.Errorf("expected override, got %s", got)
+		}
+	})
+
+	t.Run("uses XDG_STATE_HOME", func(t *testing.T) {
+		t.Setenv("AGEPAD_AUDIT_LOG", "")
+		t.Setenv("XDG_STATE_HOME", "/state")
+		if got := DefaultPath(); got != "/state/agepad/audit.log" {
+			t.Errorf("unexpected path %s", got)
+		}
+	})
+}
@@ -0,0 +1,162 @@
+// Package buildinfo reports what a given agepad binary was built with, so a
+// security review can pin the exact age library and cryptographic defaults
+// without reading the source.
+package buildinfo
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"strings"
+
+	"filippo.io/age"
+	"filippo.io/age/armor"
+	agepkg "github.com/andreweick/agepad/age"
+)
+
+// Version is the agepad release version, set at build time with
+// -ldflags "-X github.com/andreweick/agepad/buildinfo.Version=v1.2.3".
+var Version = "dev"
+
+// AgeModule is the module path of the linked age library.
+const AgeModule = "filippo.io/age"
+
+// MinAgeVersion is the oldest age release agepad supports. v1.1.0 added the
+// plugin package and the X25519 and armor behavior agepad relies on.
+const MinAgeVersion = "v1.1.0"
+
+// ArmorDefault reports whether agepad writes ASCII-armored output unless
+// told otherwise.
+const ArmorDefault = true
+
+// Info describes the binary.
+type Info struct {
+	Version                 string   `json:"version"`
+	GoVersion               string   `json:"go_version"`
+	AgeVersion              string   `json:"age_version"`
+	AgeSum                  string   `json:"age_sum,omitempty"`
+	Revision                string   `json:"vcs_revision,omitempty"`
+	Modified                bool     `json:"vcs_modified,omitempty"`
+	ArmorDefault            bool     `json:"armor_default"`
+	ScryptEncryptWorkFactor int      `json:"scrypt_encrypt_work_factor"` // what --passphrase sets (age.ScryptWorkFactor)
+	ScryptMaxWorkFactor     int      `json:"scrypt_max_work_factor"`
+	RecipientTypes          []string `json:"recipient_types"` // probed from the recipients parser (age.RecipientTypes)
+}
+
+// Read collects Info from the embedded module build information. Fields that
+// are unavailable (for example under `go test`) are reported as "unknown".
+func Read() Info {
+	info := Info{
+		Version:                 Version,
+		GoVersion:               runtime.Version(),
+		AgeVersion:              "unknown",
+		ArmorDefault:            ArmorDefault,
+		ScryptEncryptWorkFactor: agepkg.ScryptWorkFactor,
+		ScryptMaxWorkFactor:     agepkg.ScryptMaxWorkFactor,
+	}
+	if types, err := agepkg.RecipientTypes(); err == nil {
+		info.RecipientTypes = types
+	} else {
+		info.RecipientTypes = []string{"unknown"}
+	}
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		return info
+	}
+	for _, dep := range bi.Deps {
+		if dep.Path != AgeModule {
+			continue
+		}
+		if dep.Replace != nil {
+			dep = dep.Replace
+		}
+		info.AgeVersion = dep.Version
+		info.AgeSum = dep.Sum
+	}
+	for _, s := range bi.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			info.Revision = s.Value
+		case "vcs.modified":
+			info.Modified = s.Value == "true"
+		}
+	}
+	return info
+}
+
+// CheckAge verifies the linked age library is recent enough and can complete
+// an armored X25519 round trip in memory. It is cheap enough to run at every
+// startup.
+func CheckAge() error {
+	if v := Read().AgeVersion; v != "unknown" && v != "(devel)" && compareVersions(v, MinAgeVersion) < 0 {
+		return fmt.Errorf("linked %s %s is older than the required %s", AgeModule, v, MinAgeVersion)
+	}
+	return selfTest()
+}
+
+func selfTest() error {
+	id, err := age.GenerateX25519Identity()
+	if err != nil {
+		return fmt.Errorf("age self-test: generate identity: %w", err)
+	}
+	const probe = "agepad self-test"
+
+	var buf bytes.Buffer
+	aw := armor.NewWriter(&buf)
+	w, err := age.Encrypt(aw, id.Recipient())
+	if err != nil {
+		return fmt.Errorf("age self-test: encrypt: %w", err)
+	}
+	if _, err := io.WriteString(w, probe); err != nil {
+		return fmt.Errorf("age self-test: encrypt: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("age self-test: encrypt: %w", err)
+	}
+	if err := aw.Close(); err != nil {
+		return fmt.Errorf("age self-test: armor: %w", err)
+	}
+
+	r, err := age.Decrypt(armor.NewReader(&buf), id)
+	if err != nil {
+		return fmt.Errorf("age self-test: decrypt: %w", err)
+	}
+	got, err := io.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("age self-test: decrypt: %w", err)
+	}
+	if string(got) != probe {
+		return fmt.Errorf("age self-test: round trip mismatch")
+	}
+	return nil
+}
+
+// compareVersions compares two "vMAJOR.MINOR.PATCH[-pre]" strings numerically,
+// ignoring pre-release and build suffixes.
+func compareVersions(a, b string) int {
+	pa, pb := versionParts(a), versionParts(b)
+	for i := range pa {
+		if pa[i] != pb[i] {
+			if pa[i] < pb[i] {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+func versionParts(v string) [3]int {
+	v = strings.TrimPrefix(v, "v")
+	if i := strings.IndexAny(v, "-+"); i >= 0 {
+		v = v[:i]
+	}
+	var out [3]int
+	for i, p := range strings.SplitN(v, ".", 3) {
+		out[i], _ = strconv.Atoi(p)
+	}
+	return out
+}
@@ -0,0 +1,47 @@
+package buildinfo
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCompareVersions(t *testing.T) {
+	t.Run("orders numerically", func(t *testing.T) {
+		if compareVersions("v1.10.0", "v1.2.0") <= 0 {
+			t.Error("expected v1.10.0 > v1.2.0")
+		}
+		if compareVersions("v1.0.9", "v1.1.0") >= 0 {
+			t.Error("expected v1.0.9 < v1.1.0")
+		}
+	})
+
+	t.Run("ignores pre-release suffixes", func(t *testing.T) {
+		if compareVersions("v1.2.1-rc.1", "v1.2.1") != 0 {
+			t.Error("expected pre-release to compare equal to its release")
+		}
+	})
+}
+
+func TestCheckAge(t *testing.T) {
+	t.Run("linked library passes the self-test", func(t *testing.T) {
+		if err := CheckAge(); err != nil {
+			t.Fatalf("expected self-test to pass, got %v", err)
+		}
+	})
+}
+
+func TestRead(t *testing.T) {
+	t.Run("reports build defaults", func(t *testing.T) {
+		info := Read()
+		if info.Version == "" || info.GoVersion == "" {
+			t.Errorf("expected version fields, got %+v", info)
+		}
+		if !info.ArmorDefault {
+			t.Error("expected armor to be on by default")
+		}
+		want := []string{"X25519", "plugin", "scrypt", "ssh-ed25519", "ssh-rsa"}
+		if strings.Join(info.RecipientTypes, ",") != strings.Join(want, ",") {
+			t.Errorf("expected recipient types %v, got %v", want, info.RecipientTypes)
+		}
+	})
+}
@@ -0,0 +1,99 @@
+// Package bundle joins several decrypted files into one virtual document
+// with marked section boundaries, and splits an edited document back into
+// its files.
+package bundle
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	markerPrefix = "#### agepad file: "
+	markerSuffix = " ####"
+)
+
+// Part is one file's path and plaintext.
+type Part struct {
+	Path    string
+	Content string
+}
+
+// Marker returns the boundary line that starts path's section.
+func Marker(path string) string {
+	return markerPrefix + path + markerSuffix
+}
+
+// Join renders parts as one document, each introduced by its marker line.
+func Join(parts []Part) string {
+	var b strings.Builder
+	for i, p := range parts {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		b.WriteString(Marker(p.Path) + "\n")
+		b.WriteString(strings.TrimSuffix(p.Content, "\n"))
+	}
+	return b.String()
+}
+
+// Split parses a document produced by Join (and then edited) back into
+// parts, in document order. The set of sections must match paths exactly:
+// markers may not be removed, added, renamed, or duplicated, and nothing
+// may precede the first one.
+func Split(doc string, paths []string) ([]Part, error) {
+	want := map[string]bool{}
+	for _, p := range paths {
+		want[p] = true
+	}
+	seen := map[string]bool{}
+
+	var (
+		parts []Part
+		cur   *Part
+		lines []string
+	)
+	flush := func() {
+		if cur != nil {
+			cur.Content = strings.Join(lines, "\n") + "\n"
+			parts = append(parts, *cur)
+		}
+	}
+	for i, line := range strings.Split(doc, "\n") {
+		if path, ok := parseMarker(line); ok {
+			if !want[path] {
+				return nil, fmt.Errorf("line %d: section for unknown file %q", i+1, path)
+			}
+			if seen[path] {
+				return nil, fmt.Errorf("line %d: duplicate section for %q", i+1, path)
+			}
+			seen[path] = true
+			flush()
+			cur, lines = &Part{Path: path}, nil
+			continue
+		}
+		if cur == nil {
+			if strings.TrimSpace(line) != "" {
+				return nil, fmt.Errorf("line %d: text before the first file marker", i+1)
+			}
+			continue
+		}
+		lines = append(lines, line)
+	}
+	flush()
+
+	for _, p := range paths {
+		if !seen[p] {
+			return nil, fmt.Errorf("section marker for %q is missing; restore %q", p, Marker(p))
+		}
+	}
+	return parts, nil
+}
+
+func parseMarker(line string) (string, bool) {
+	t := strings.TrimRight(line, " \t\r")
+	if !strings.HasPrefix(t, markerPrefix) || !strings.HasSuffix(t, markerSuffix) {
+		return "", false
+	}
+	return strings.TrimSuffix(strings.TrimPrefix(t, markerPrefix), markerSuffix), true
+}
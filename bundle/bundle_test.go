@@ -0,0 +1,61 @@
+package bundle
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestJoinSplit(t *testing.T) {
+	parts := []Part{
+		{Path: "svc/db.env.age", Content: "DB_USER=u\nDB_PASS=p\n"},
+		{Path: "svc/api.env.age", Content: "TOKEN=t\n"},
+	}
+	paths := []string{"svc/db.env.age", "svc/api.env.age"}
+
+	t.Run("round-trips parts", func(t *testing.T) {
+		got, err := Split(Join(parts), paths)
+		if err != nil {
+			t.Fatalf("split failed: %v", err)
+		}
+		if len(got) != 2 {
+			t.Fatalf("expected 2 parts, got %d", len(got))
+		}
+		for i := range parts {
+			if got[i] != parts[i] {
+				t.Errorf("part %d: expected %+v, got %+v", i, parts[i], got[i])
+			}
+		}
+	})
+
+	t.Run("marks section boundaries", func(t *testing.T) {
+		doc := Join(parts)
+		if !strings.HasPrefix(doc, Marker("svc/db.env.age")+"\n") || !strings.Contains(doc, "\n"+Marker("svc/api.env.age")+"\n") {
+			t.Errorf("unexpected document:\n%s", doc)
+		}
+	})
+
+	t.Run("keeps edits in their section", func(t *testing.T) {
+		doc := strings.Replace(Join(parts), "TOKEN=t", "TOKEN=t2\nNEW=1", 1)
+		got, err := Split(doc, paths)
+		if err != nil {
+			t.Fatalf("split failed: %v", err)
+		}
+		if got[1].Content != "TOKEN=t2\nNEW=1\n" || got[0] != parts[0] {
+			t.Errorf("unexpected parts: %+v", got)
+		}
+	})
+
+	t.Run("rejects broken markers", func(t *testing.T) {
+		cases := map[string]string{
+			"missing":   strings.Replace(Join(parts), Marker("svc/api.env.age")+"\n", "", 1),
+			"unknown":   Join(parts) + "\n" + Marker("other.age"),
+			"duplicate": Join(parts) + "\n" + Marker("svc/db.env.age"),
+			"preamble":  "STRAY=1\n" + Join(parts),
+		}
+		for name, doc := range cases {
+			if _, err := Split(doc, paths); err == nil {
+				t.Errorf("%s: expected error", name)
+			}
+		}
+	})
+}
@@ -0,0 +1,195 @@
+// Package cireport builds a markdown summary of how encrypted files changed
+// between two revisions: key names added/removed/changed, recipient stanza
+// changes, and recipients-file membership. Values never appear in reports.
+package cireport
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/andreweick/agepad/convert"
+)
+
+// FileReport describes one changed .age file.
+type FileReport struct {
+	Path          string
+	Status        string // A, M, D
+	KeysAdded     []string
+	KeysRemoved   []string
+	KeysChanged   []string // value changed
+	StanzasBefore []string
+	StanzasAfter  []string
+	Violations    []string // head stanza types the stanza policy disallows
+	Error         string   // set when the file could not be decrypted/inspected
+}
+
+// RecipientsReport describes membership changes of the recipients file.
+type RecipientsReport struct {
+	Path    string
+	Added   []string
+	Removed []string
+}
+
+// KeyValues flattens plaintext into key -> value using the format implied
+// by path (anything unrecognised is treated as .env).
+func KeyValues(path, plain string) (map[string]string, error) {
+	f, err := convert.FormatFromPath(path)
+	if err != nil {
+		f = convert.Env
+	}
+	data, err := convert.Decode(plain, f)
+	if err != nil {
+		return nil, err
+	}
+	return convert.Flatten(data, convert.Options{Separator: "."}), nil
+}
+
+// KeyDiff compares two key/value maps and returns sorted key names.
+func KeyDiff(before, after map[string]string) (added, removed, changed []string) {
+	for k, v := range before {
+		w, ok := after[k]
+		switch {
+		case !ok:
+			removed = append(removed, k)
+		case w != v:
+			changed = append(changed, k)
+		}
+	}
+	for k := range after {
+		if _, ok := before[k]; !ok {
+			added = append(added, k)
+		}
+	}
+	sort.Strings(added)
+	sort.Strings(removed)
+	sort.Strings(changed)
+	return added, removed, changed
+}
+
+// RecipientLines returns the non-comment, non-blank lines of a recipients
+// file.
+func RecipientLines(content string) []string {
+	var out []string
+	for _, line := range strings.Split(content, "\n") {
+		t := strings.TrimSpace(line)
+		if t == "" || strings.HasPrefix(t, "#") {
+			continue
+		}
+		out = append(out, t)
+	}
+	return out
+}
+
+// DiffLines returns entries only in b (added) and only in a (removed).
+func DiffLines(a, b []string) (added, removed []string) {
+	inA, inB := map[string]bool{}, map[string]bool{}
+	for _, l := range a {
+		inA[l] = true
+	}
+	for _, l := range b {
+		inB[l] = true
+		if !inA[l] {
+			added = append(added, l)
+		}
+	}
+	for _, l := range a {
+		if !inB[l] {
+			removed = append(removed, l)
+		}
+	}
+	return added, removed
+}
+
+// Markdown renders the report for posting as a PR comment.
+func Markdown(base, head string, files []FileReport, recips *RecipientsReport) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "## agepad report: `%s` → `%s`\n\n", base, head)
+
+	if recips != nil && (len(recips.Added) > 0 || len(recips.Removed) > 0) {
+		fmt.Fprintf(&b, "### Recipients (`%s`)\n\n", recips.Path)
+		for _, r := range recips.Added {
+			fmt.Fprintf(&b, "- ➕ `%s`\n", r)
+		}
+		for _, r := range recips.Removed {
+			fmt.Fprintf(&b, "- ➖ `%s`\n", r)
+		}
+		b.WriteString("\n")
+	}
+
+	if len(files) == 0 {
+		b.WriteString("No encrypted files changed.\n")
+		return b.String()
+	}
+	b.WriteString("### Encrypted files\n\n")
+	b.WriteString("| File | Status | Keys added | Keys removed | Keys changed | Recipient stanzas |\n")
+	b.WriteString("|---|---|---|---|---|---|\n")
+	for _, f := range files {
+		if f.Error != "" {
+			fmt.Fprintf(&b, "| `%s` | %s | ⚠️ %s | | | |\n", f.Path, statusName(f.Status), f.Error)
+			continue
+		}
+		fmt.Fprintf(&b, "| `%s` | %s | %s | %s | %s | %s |\n",
+			f.Path, statusName(f.Status),
+			names(f.KeysAdded), names(f.KeysRemoved), names(f.KeysChanged),
+			stanzaChange(f.StanzasBefore, f.StanzasAfter))
+	}
+
+	var violations []string
+	for _, f := range files {
+		if len(f.Violations) > 0 {
+			violations = append(violations, fmt.Sprintf("- ⛔ `%s`: %s\n", f.Path, names(f.Violations)))
+		}
+	}
+	if len(violations) > 0 {
+		b.WriteString("\n### Stanza policy violations\n\n")
+		b.WriteString(strings.Join(violations, ""))
+	}
+	return b.String()
+}
+
+func statusName(s string) string {
+	switch s {
+	case "A":
+		return "added"
+	case "D":
+		return "deleted"
+	case "M":
+		return "modified"
+	}
+	return s
+}
+
+func names(keys []string) string {
+	if len(keys) == 0 {
+		return "—"
+	}
+	quoted := make([]string, len(keys))
+	for i, k := range keys {
+		quoted[i] = "`" + k + "`"
+	}
+	return strings.Join(quoted, ", ")
+}
+
+func stanzaChange(before, after []string) string {
+	summary := func(types []string) string {
+		if len(types) == 0 {
+			return "none"
+		}
+		counts := map[string]int{}
+		for _, t := range types {
+			counts[t]++
+		}
+		var parts []string
+		for t, n := range counts {
+			parts = append(parts, fmt.Sprintf("%d× %s", n, t))
+		}
+		sort.Strings(parts)
+		return strings.Join(parts, ", ")
+	}
+	a, z := summary(before), summary(after)
+	if a == z {
+		return a
+	}
+	return a + " → " + z
+}
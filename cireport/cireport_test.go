@@ -0,0 +1,90 @@
+package cireport
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestKeyDiff(t *testing.T) {
+	t.Run("classifies added, removed, and changed keys", func(t *testing.T) {
+		added, removed, changed := KeyDiff(
+			map[string]string{"A": "1", "B": "2", "C": "3"},
+			map[string]string{"A": "1", "B": "9", "D": "4"},
+		)
+		if strings.Join(added, ",") != "D" || strings.Join(removed, ",") != "C" || strings.Join(changed, ",") != "B" {
+			t.Errorf("unexpected diff: added=%v removed=%v changed=%v", added, removed, changed)
+		}
+	})
+}
+
+func TestKeyValues(t *testing.T) {
+	t.Run("flattens structured formats with dotted paths", func(t *testing.T) {
+		kv, err := KeyValues("config.yaml.age", "db:\n  host: x\n")
+		if err != nil {
+			t.Fatalf("key values failed: %v", err)
+		}
+		if kv["db.host"] != "x" {
+			t.Errorf("expected db.host, got %v", kv)
+		}
+	})
+
+	t.Run("treats unknown formats as env", func(t *testing.T) {
+		kv, err := KeyValues("secrets.age", "A=1\n")
+		if err != nil {
+			t.Fatalf("key values failed: %v", err)
+		}
+		if kv["A"] != "1" {
+			t.Errorf("expected A, got %v", kv)
+		}
+	})
+}
+
+func TestRecipients(t *testing.T) {
+	t.Run("diffs recipients ignoring comments", func(t *testing.T) {
+		a := RecipientLines("# team\nage1aaa\nage1bbb\n")
+		b := RecipientLines("age1bbb\n\nage1ccc # new\n")
+		added, removed := DiffLines(a, b)
+		if strings.Join(added, ",") != "age1ccc # new" || strings.Join(removed, ",") != "age1aaa" {
+			t.Errorf("unexpected diff: added=%v removed=%v", added, removed)
+		}
+	})
+}
+
+func TestMarkdown(t *testing.T) {
+	t.Run("renders key names and stanza changes without values", func(t *testing.T) {
+		md := Markdown("main", "feature", []FileReport{{
+			Path:          "secrets/app.env.age",
+			Status:        "M",
+			KeysAdded:     []string{"NEW_KEY"},
+			StanzasBefore: []string{"X25519"},
+			StanzasAfter:  []string{"X25519", "X25519"},
+		}}, &RecipientsReport{Path: ".age-recipients", Added: []string{"age1new"}})
+
+		for _, want := range []string{"`NEW_KEY`", "1× X25519 → 2× X25519", "➕ `age1new`", "modified"} {
+			if !strings.Contains(md, want) {
+				t.Errorf("expected report to contain %q:\n%s", want, md)
+			}
+		}
+	})
+
+	t.Run("reports when nothing changed", func(t *testing.T) {
+		md := Markdown("a", "b", nil, nil)
+		if !strings.Contains(md, "No encrypted files changed.") {
+			t.Errorf("unexpected report:\n%s", md)
+		}
+	})
+
+	t.Run("surfaces per-file errors", func(t *testing.T) {
+		md := Markdown("a", "b", []FileReport{{Path: "x.age", Status: "A", Error: "decrypt failed"}}, nil)
+		if !strings.Contains(md, "⚠️ decrypt failed") {
+			t.Errorf("expected error in report:\n%s", md)
+		}
+	})
+
+	t.Run("lists stanza policy violations", func(t *testing.T) {
+		md := Markdown("a", "b", []FileReport{{Path: "team.age", Status: "M", Violations: []string{"scrypt"}}}, nil)
+		if !strings.Contains(md, "Stanza policy violations") || !strings.Contains(md, "`team.age`: `scrypt`") {
+			t.Errorf("expected violation in report:\n%s", md)
+		}
+	})
+}
@@ -0,0 +1,99 @@
+// Package clipboard copies secrets out of the editor with as little spread
+// as possible: the system backend marks each copy for clipboard managers to
+// skip where the platform allows, only ever targets the CLIPBOARD selection
+// (never the X PRIMARY selection, which any middle-click pastes), and OSC52
+// is strictly opt-in because it sends the text through the terminal, which
+// for SSH sessions means across the network to the remote clipboard.
+package clipboard
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/atotto/clipboard"
+)
+
+// Clipboard writes text to, and clears, a clipboard.
+type Clipboard interface {
+	Write(text string) error
+	// Clear empties the clipboard if it still holds the last text written.
+	Clear() error
+}
+
+// New returns the OSC52 backend writing to out when osc52 is set, and the
+// system clipboard otherwise.
+func New(osc52 bool, out io.Writer) Clipboard {
+	if osc52 {
+		return &OSC52{Out: out}
+	}
+	return &System{Warn: os.Stderr}
+}
+
+// System uses the platform clipboard, marking each copy so clipboard
+// managers and history leave it out: NSPasteboard's ConcealedType and
+// TransientType on macOS, and the ExcludeClipboardContentFromMonitorProcessing,
+// CanIncludeInClipboardHistory, and CanUploadToCloudClipboard formats on
+// Windows. Elsewhere it uses wl-copy, xclip, or xsel on the CLIPBOARD
+// selection, which cannot carry such a hint, and says so on Warn once.
+type System struct {
+	Warn   io.Writer
+	last   [sha256.Size]byte
+	set    bool
+	warned bool
+}
+
+// Write copies text to the system clipboard.
+func (s *System) Write(text string) error {
+	note, err := writeConcealed(text)
+	if err != nil {
+		return fmt.Errorf("clipboard: %w", err)
+	}
+	if note != "" && !s.warned && s.Warn != nil {
+		fmt.Fprintf(s.Warn, "warning: clipboard: %s; clipboard managers may keep the copy until it is cleared\n", note)
+		s.warned = true
+	}
+	s.last, s.set = sha256.Sum256([]byte(text)), true
+	return nil
+}
+
+// Clear empties the clipboard unless something else was copied since.
+func (s *System) Clear() error {
+	if !s.set {
+		return nil
+	}
+	cur, err := clipboard.ReadAll()
+	if err != nil {
+		return fmt.Errorf("clipboard: %w", err)
+	}
+	if sha256.Sum256([]byte(cur)) != s.last {
+		s.set = false
+		return nil
+	}
+	if err := clipboard.WriteAll(""); err != nil {
+		return fmt.Errorf("clipboard: %w", err)
+	}
+	s.set = false
+	return nil
+}
+
+// OSC52 asks the terminal to set its clipboard via the OSC 52 escape
+// sequence. Terminals cannot be queried reliably, so Clear always clears.
+type OSC52 struct {
+	Out io.Writer
+}
+
+// Write emits an OSC 52 "set clipboard" sequence for text.
+func (o *OSC52) Write(text string) error {
+	_, err := fmt.Fprintf(o.Out, "\x1b]52;c;%s\x07", base64.StdEncoding.EncodeToString([]byte(text)))
+	return err
+}
+
+// Clear emits an OSC 52 sequence with an invalid payload, which terminals
+// treat as "clear the selection".
+func (o *OSC52) Clear() error {
+	_, err := io.WriteString(o.Out, "\x1b]52;c;!\x07")
+	return err
+}
@@ -0,0 +1,59 @@
+package clipboard
+
+import (
+	"bytes"
+	"encoding/base64"
+	"testing"
+)
+
+func TestOSC52(t *testing.T) {
+	t.Run("writes base64 payload to the clipboard selection", func(t *testing.T) {
+		var buf bytes.Buffer
+		c := New(true, &buf)
+
+		if err := c.Write("s3cret"); err != nil {
+			t.Fatalf("write failed: %v", err)
+		}
+
+		want := "\x1b]52;c;" + base64.StdEncoding.EncodeToString([]byte("s3cret")) + "\x07"
+		if buf.String() != want {
+			t.Errorf("expected %q, got %q", want, buf.String())
+		}
+	})
+
+	t.Run("clears with an invalid payload", func(t *testing.T) {
+		var buf bytes.Buffer
+		c := New(true, &buf)
+
+		if err := c.Clear(); err != nil {
+			t.Fatalf("clear failed: %v", err)
+		}
+		if buf.String() != "\x1b]52;c;!\x07" {
+			t.Errorf("unexpected clear sequence %q", buf.String())
+		}
+	})
+
+	t.Run("never targets the primary selection", func(t *testing.T) {
+		var buf bytes.Buffer
+		c := New(true, &buf)
+		_ = c.Write("x")
+
+		if bytes.Contains(buf.Bytes(), []byte("]52;p;")) {
+			t.Error("expected OSC52 output to avoid the primary selection")
+		}
+	})
+}
+
+func TestNew(t *testing.T) {
+	t.Run("uses the system clipboard unless OSC52 is requested", func(t *testing.T) {
+		if _, ok := New(false, nil).(*System); !ok {
+			t.Error("expected system clipboard backend")
+		}
+	})
+
+	t.Run("clearing an untouched system clipboard is a no-op", func(t *testing.T) {
+		if err := New(false, nil).Clear(); err != nil {
+			t.Errorf("expected no error, got %v", err)
+		}
+	})
+}
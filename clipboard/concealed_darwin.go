@@ -0,0 +1,32 @@
+package clipboard
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// concealScript writes stdin to the general NSPasteboard with the
+// nspasteboard.org ConcealedType and TransientType markers, which
+// clipboard managers honour by not showing or not recording the copy.
+// The text goes through stdin, never argv.
+const concealScript = `ObjC.import('AppKit');
+var data = $.NSFileHandle.fileHandleWithStandardInput.readDataToEndOfFile;
+var text = $.NSString.alloc.initWithDataEncoding(data, $.NSUTF8StringEncoding);
+var pb = $.NSPasteboard.generalPasteboard;
+pb.clearContents;
+if (!pb.setStringForType(text, $.NSPasteboardTypeString) ||
+    !pb.setStringForType($(''), 'org.nspasteboard.ConcealedType') ||
+    !pb.setStringForType($(''), 'org.nspasteboard.TransientType')) {
+  throw new Error('NSPasteboard refused the copy');
+}`
+
+// writeConcealed copies text through NSPasteboard by way of osascript.
+func writeConcealed(text string) (string, error) {
+	cmd := exec.Command("osascript", "-l", "JavaScript", "-e", concealScript)
+	cmd.Stdin = strings.NewReader(text)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return "", fmt.Errorf("osascript: %v: %s", err, strings.TrimSpace(string(out)))
+	}
+	return "", nil
+}
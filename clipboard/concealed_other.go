@@ -0,0 +1,14 @@
+//go:build !darwin && !windows
+
+package clipboard
+
+import "github.com/atotto/clipboard"
+
+// writeConcealed copies text with wl-copy, xclip, or xsel, which target
+// the CLIPBOARD selection unless clipboard.Primary is set (agepad never
+// sets it). Each offers the text under a single type, so the
+// x-kde-passwordManagerHint target cannot be added next to it; the
+// returned note says so.
+func writeConcealed(text string) (string, error) {
+	return "wl-copy, xclip, and xsel cannot add the x-kde-passwordManagerHint target", clipboard.WriteAll(text)
+}
@@ -0,0 +1,113 @@
+package clipboard
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+const (
+	cfUnicodeText = 13
+	gmemMoveable  = 0x0002
+)
+
+var (
+	user32                  = windows.NewLazySystemDLL("user32.dll")
+	openClipboard           = user32.NewProc("OpenClipboard")
+	closeClipboard          = user32.NewProc("CloseClipboard")
+	emptyClipboard          = user32.NewProc("EmptyClipboard")
+	setClipboardData        = user32.NewProc("SetClipboardData")
+	registerClipboardFormat = user32.NewProc("RegisterClipboardFormatW")
+
+	kernel32      = windows.NewLazySystemDLL("kernel32.dll")
+	globalAlloc   = kernel32.NewProc("GlobalAlloc")
+	globalFree    = kernel32.NewProc("GlobalFree")
+	globalLock    = kernel32.NewProc("GlobalLock")
+	globalUnlock  = kernel32.NewProc("GlobalUnlock")
+	rtlMoveMemory = kernel32.NewProc("RtlMoveMemory")
+)
+
+// hintFormats are the registered formats that keep clipboard monitors,
+// Win+V history, and cloud sync away from the copy; each holds a zero
+// DWORD.
+var hintFormats = []string{
+	"ExcludeClipboardContentFromMonitorProcessing",
+	"CanIncludeInClipboardHistory",
+	"CanUploadToCloudClipboard",
+}
+
+// writeConcealed puts text on the clipboard as CF_UNICODETEXT next to the
+// hint formats, in one OpenClipboard session so no monitor sees the text
+// without them.
+func writeConcealed(text string) (string, error) {
+	u, err := windows.UTF16FromString(text)
+	if err != nil {
+		return "", err
+	}
+	defer clear(u)
+	// OpenClipboard and CloseClipboard must run on the same thread.
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	if err := waitOpenClipboard(); err != nil {
+		return "", fmt.Errorf("OpenClipboard: %w", err)
+	}
+	defer closeClipboard.Call()
+	if r, _, err := emptyClipboard.Call(); r == 0 {
+		return "", fmt.Errorf("EmptyClipboard: %w", err)
+	}
+	for _, name := range hintFormats {
+		p, err := windows.UTF16PtrFromString(name)
+		if err != nil {
+			return "", err
+		}
+		f, _, err := registerClipboardFormat.Call(uintptr(unsafe.Pointer(p)))
+		if f == 0 {
+			return "", fmt.Errorf("RegisterClipboardFormat %s: %w", name, err)
+		}
+		if err := setData(f, make([]byte, 4)); err != nil {
+			return "", err
+		}
+	}
+	if err := setData(cfUnicodeText, unsafe.Slice((*byte)(unsafe.Pointer(&u[0])), len(u)*2)); err != nil {
+		return "", err
+	}
+	return "", nil
+}
+
+// waitOpenClipboard opens the clipboard, waiting up to a second for
+// another program to close it.
+func waitOpenClipboard() error {
+	err := errors.New("clipboard busy")
+	for limit := time.Now().Add(time.Second); time.Now().Before(limit); time.Sleep(time.Millisecond) {
+		var r uintptr
+		if r, _, err = openClipboard.Call(0); r != 0 {
+			return nil
+		}
+	}
+	return err
+}
+
+// setData copies data into a movable global block and hands it to the
+// clipboard, which owns it from then on.
+func setData(format uintptr, data []byte) error {
+	h, _, err := globalAlloc.Call(gmemMoveable, uintptr(len(data)))
+	if h == 0 {
+		return fmt.Errorf("GlobalAlloc: %w", err)
+	}
+	p, _, err := globalLock.Call(h)
+	if p == 0 {
+		globalFree.Call(h)
+		return fmt.Errorf("GlobalLock: %w", err)
+	}
+	rtlMoveMemory.Call(p, uintptr(unsafe.Pointer(&data[0])), uintptr(len(data)))
+	globalUnlock.Call(h)
+	if r, _, err := setClipboardData.Call(format, h); r == 0 {
+		globalFree.Call(h)
+		return fmt.Errorf("SetClipboardData: %w", err)
+	}
+	return nil
+}
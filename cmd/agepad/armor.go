@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/andreweick/agepad/buildinfo"
+	"github.com/urfave/cli/v3"
+)
+
+// armorAuto is the --armor value that keeps each file's existing format.
+const armorAuto = "auto"
+
+// armorValue holds --armor: true, false, or auto. Like a bool flag, a bare
+// --armor means true, so auto is given as --armor=auto.
+type armorValue struct{ mode string }
+
+func (a *armorValue) Set(s string) error {
+	if strings.EqualFold(s, armorAuto) {
+		a.mode = armorAuto
+		return nil
+	}
+	b, err := strconv.ParseBool(s)
+	if err != nil {
+		return fmt.Errorf("want true, false, or auto, got %q", s)
+	}
+	a.mode = strconv.FormatBool(b)
+	return nil
+}
+
+func (a *armorValue) Get() any         { return a.mode }
+func (a *armorValue) String() string   { return a.mode }
+func (a *armorValue) IsBoolFlag() bool { return true }
+
+// armorFlag is the editor's and rotate's --armor, which accepts auto.
+func armorFlag() cli.Flag {
+	return &cli.GenericFlag{
+		Name:  "armor",
+		Usage: "Write ASCII-armored .age output: true, false, or auto to keep each file's existing format (--armor=auto)",
+		Value: &armorValue{mode: strconv.FormatBool(buildinfo.ArmorDefault)},
+	}
+}
+
+// armorFromFlags reads --armor. auto reports the auto mode; armor is then
+// the default for files that have no format yet.
+func armorFromFlags(cmd *cli.Command) (armor, auto bool) {
+	switch mode, _ := cmd.Value("armor").(string); mode {
+	case armorAuto:
+		return buildinfo.ArmorDefault, true
+	default:
+		return mode == "true", false
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"filippo.io/age"
+	agepkg "github.com/andreweick/agepad/age"
+	"github.com/andreweick/agepad/bundle"
+	"github.com/andreweick/agepad/model"
+	"github.com/andreweick/agepad/walk"
+)
+
+// loadBundle decrypts every .age file under cfg.FilePath (a directory) and
+// resolves each file's recipients from the nearest .age-recipients.
+func loadBundle(cfg model.Config, ids []age.Identity) ([]bundle.Part, map[string][]age.Recipient, error) {
+	files, err := walk.AgeFiles(cfg.FilePath)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(files) == 0 {
+		return nil, nil, fmt.Errorf("no .age files found under %s", cfg.FilePath)
+	}
+	var parts []bundle.Part
+	recips := map[string][]age.Recipient{}
+	for _, f := range files {
+		if err := checkStanzas(f, cfg.Stanzas); err != nil {
+			return nil, nil, err
+		}
+		plain, err := agepkg.DecryptToMemory(f, ids)
+		if err != nil {
+			return nil, nil, err
+		}
+		if _, shared, err := openShared(plain); err != nil || shared {
+			return nil, nil, fmt.Errorf("%s is a guest share; open it on its own with --file", f)
+		}
+		parts = append(parts, bundle.Part{Path: f, Content: plain})
+
+		rf := nearestRecipients(filepath.Dir(f), cfg.FilePath, cfg.RecipientsFile)
+		r, err := agepkg.LoadRecipients(rf)
+		if err != nil && !cfg.ViewOnly {
+			return nil, nil, fmt.Errorf("%s: %w", f, err)
+		}
+		recips[f] = r
+	}
+	return parts, recips, nil
+}
+
+// nearestRecipients returns the closest .age-recipients file from dir up to
+// root, or fallback when there is none.
+func nearestRecipients(dir, root, fallback string) string {
+	root = filepath.Clean(root)
+	for {
+		p := filepath.Join(dir, defaultRecipientsFile)
+		if _, err := os.Stat(p); err == nil {
+			return p
+		}
+		if filepath.Clean(dir) == root {
+			return fallback
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return fallback
+		}
+		dir = parent
+	}
+}
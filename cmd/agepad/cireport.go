@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"filippo.io/age"
+	agepkg "github.com/andreweick/agepad/age"
+	"github.com/andreweick/agepad/cireport"
+	"github.com/andreweick/agepad/gitutil"
+	"github.com/andreweick/agepad/model"
+	"github.com/urfave/cli/v3"
+)
+
+func ciReportCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "ci-report",
+		Usage: "Markdown report of encrypted-file key and recipient changes between two git revisions",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:     "base",
+				Usage:    "Base git revision (e.g. origin/main)",
+				Required: true,
+			},
+			&cli.StringFlag{
+				Name:  "head",
+				Usage: "Head git revision",
+				Value: "HEAD",
+			},
+			&cli.StringFlag{
+				Name:  "recipients-file",
+				Usage: "Recipients file (relative to the repository root) to compare",
+				Value: defaultRecipientsFile,
+			},
+			&cli.StringFlag{
+				Name:  "identities",
+				Usage: "AGE identities for reading key names (without them only headers are compared)",
+				Value: defaultIdentitiesPath(),
+			},
+			&cli.StringFlag{
+				Name:  "out",
+				Usage: "Write the report to this file instead of stdout",
+			},
+		},
+		Action: runCIReport,
+	}
+}
+
+func runCIReport(ctx context.Context, cmd *cli.Command) error {
+	cfg := model.CIReportConfig{
+		Base:           cmd.String("base"),
+		Head:           cmd.String("head"),
+		RecipientsFile: cmd.String("recipients-file"),
+		IdentitiesPath: cmd.String("identities"),
+		OutPath:        cmd.String("out"),
+		Stanzas:        stanzaPolicy(cmd),
+	}
+
+	top, err := gitutil.Repo{}.TopLevel()
+	if err != nil {
+		return fmt.Errorf("ci-report: %w", err)
+	}
+	repo := gitutil.Repo{Dir: top}
+	changes, err := repo.ChangedFiles(cfg.Base, cfg.Head)
+	if err != nil {
+		return fmt.Errorf("ci-report: %w", err)
+	}
+
+	// Key names need identities; without them the report still covers headers.
+	ids, idErr := agepkg.LoadIdentities(cfg.IdentitiesPath)
+
+	var files []cireport.FileReport
+	for _, c := range changes {
+		if !strings.HasSuffix(strings.ToLower(c.Path), ".age") {
+			continue
+		}
+		fr := cireport.FileReport{Path: c.Path, Status: c.Status}
+		before, berr := revisionKeys(repo, cfg.Base, c.Path, ids, idErr, &fr.StanzasBefore)
+		after, aerr := revisionKeys(repo, cfg.Head, c.Path, ids, idErr, &fr.StanzasAfter)
+		fr.Violations = agepkg.StanzaViolations(fr.StanzasAfter, cfg.Stanzas)
+		if berr != nil || aerr != nil {
+			fr.Error = errorsText(berr, aerr)
+		} else {
+			fr.KeysAdded, fr.KeysRemoved, fr.KeysChanged = cireport.KeyDiff(before, after)
+		}
+		files = append(files, fr)
+	}
+
+	recips := &cireport.RecipientsReport{Path: cfg.RecipientsFile}
+	recips.Added, recips.Removed = cireport.DiffLines(
+		revisionLines(repo, cfg.Base, cfg.RecipientsFile),
+		revisionLines(repo, cfg.Head, cfg.RecipientsFile))
+
+	md := cireport.Markdown(cfg.Base, cfg.Head, files, recips)
+	if cfg.OutPath != "" {
+		if err := os.WriteFile(cfg.OutPath, []byte(md), 0644); err != nil {
+			return err
+		}
+	} else {
+		fmt.Print(md)
+	}
+	// Fail the CI step after the report is out so reviewers see why.
+	var bad int
+	for _, f := range files {
+		if len(f.Violations) > 0 {
+			bad++
+		}
+	}
+	if bad > 0 {
+		return fmt.Errorf("ci-report: %d file(s) break the stanza policy", bad)
+	}
+	return nil
+}
+
+// revisionKeys decrypts path at ref and returns its flattened key/values,
+// recording the header stanza types. A path missing at ref has no keys.
+func revisionKeys(repo gitutil.Repo, ref, path string, ids []age.Identity, idErr error, stanzas *[]string) (map[string]string, error) {
+	if !repo.Exists(ref, path) {
+		return map[string]string{}, nil
+	}
+	cipher, err := repo.Show(ref, path)
+	if err != nil {
+		return nil, err
+	}
+	h, err := agepkg.InspectHeaderBytes(cipher)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", ref, err)
+	}
+	*stanzas = h.StanzaTypes()
+	if idErr != nil {
+		return nil, fmt.Errorf("no identities to read key names")
+	}
+	plain, err := agepkg.Decrypt(cipher, ids)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", ref, err)
+	}
+	return cireport.KeyValues(path, plain)
+}
+
+func revisionLines(repo gitutil.Repo, ref, path string) []string {
+	if !repo.Exists(ref, path) {
+		return nil
+	}
+	b, err := repo.Show(ref, path)
+	if err != nil {
+		return nil
+	}
+	return cireport.RecipientLines(string(b))
+}
+
+func errorsText(errs ...error) string {
+	var parts []string
+	for _, err := range errs {
+		if err != nil && !slices.Contains(parts, err.Error()) {
+			parts = append(parts, err.Error())
+		}
+	}
+	return strings.Join(parts, "; ")
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/andreweick/agepad/model"
+	"github.com/charmbracelet/x/term"
+	"github.com/urfave/cli/v3"
+)
+
+// Confirmation flags shared by subcommands that write files outside the TUI.
+var (
+	yesFlag = &cli.BoolFlag{
+		Name:    "yes",
+		Aliases: []string{"no-confirm"},
+		Usage:   "Do not ask for confirmation before writing",
+	}
+	confirmPromptFlag = &cli.BoolFlag{
+		Name:  "confirm-prompt",
+		Usage: "Ask for y/N confirmation before writing (interactive terminals only)",
+	}
+)
+
+var errAborted = errors.New("aborted; nothing was written")
+
+func confirmFromFlags(cmd *cli.Command) model.Confirm {
+	return model.Confirm{
+		Yes:    cmd.Bool("yes"),
+		Prompt: cmd.Bool("confirm-prompt"),
+	}
+}
+
+// confirm applies the shared confirmation semantics: --yes always proceeds,
+// --confirm-prompt asks on a terminal and refuses when nobody can answer,
+// and with neither flag the operation proceeds as before.
+func confirm(c model.Confirm, question string) error {
+	if c.Yes || !c.Prompt {
+		return nil
+	}
+	if !isInteractive() {
+		return errors.New("confirmation required but stdin is not a terminal; rerun with --yes")
+	}
+	if !askYes(question) {
+		return errAborted
+	}
+	return nil
+}
+
+// askYes asks question on the terminal and reports whether it was answered
+// y or yes.
+func askYes(question string) bool {
+	fmt.Fprintf(os.Stderr, "%s [y/N] ", question)
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return false
+	}
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true
+	}
+	return false
+}
+
+// isInteractive reports whether a human can answer prompts on stdin.
+func isInteractive() bool {
+	if os.Getenv("CI") == "true" {
+		return false
+	}
+	return term.IsTerminal(os.Stdin.Fd())
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	agepkg "github.com/andreweick/agepad/age"
+	"github.com/andreweick/agepad/buildinfo"
+	"github.com/andreweick/agepad/convert"
+	"github.com/andreweick/agepad/model"
+	"github.com/andreweick/agepad/validator"
+	"github.com/urfave/cli/v3"
+)
+
+func convertCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "convert",
+		Usage: "Convert an encrypted file between env, json, yaml, and toml without plaintext on disk",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:     "file",
+				Usage:    "Encrypted source file",
+				Required: true,
+			},
+			&cli.StringFlag{
+				Name:  "from",
+				Usage: "Source format (default: inferred from --file, e.g. app.env.age)",
+			},
+			&cli.StringFlag{
+				Name:     "to",
+				Usage:    "Target format: env, json, yaml, or toml",
+				Required: true,
+			},
+			&cli.StringFlag{
+				Name:     "out",
+				Usage:    "Encrypted output file",
+				Required: true,
+			},
+			&cli.StringFlag{
+				Name:  "separator",
+				Usage: "Separator joining nested keys when flattening to env (and splitting when --nest)",
+				Value: "_",
+			},
+			&cli.BoolFlag{
+				Name:  "nest",
+				Usage: "Split env keys on --separator into nested tables",
+			},
+			&cli.BoolFlag{
+				Name:  "upper",
+				Usage: "Upper-case keys when flattening to env",
+			},
+			&cli.StringFlag{
+				Name:  "recipients-file",
+				Usage: "Path to recipients file",
+				Value: defaultRecipientsFile,
+			},
+			&cli.StringFlag{
+				Name:  "identities",
+				Usage: "Path to AGE identities",
+				Value: defaultIdentitiesPath(),
+			},
+			&cli.BoolFlag{
+				Name:  "armor",
+				Usage: "Write ASCII-armored .age output",
+				Value: buildinfo.ArmorDefault,
+			},
+			yesFlag,
+			confirmPromptFlag,
+		},
+		Action: runConvert,
+	}
+}
+
+func runConvert(ctx context.Context, cmd *cli.Command) error {
+	cfg := model.ConvertConfig{
+		FilePath:       cmd.String("file"),
+		From:           cmd.String("from"),
+		To:             cmd.String("to"),
+		OutPath:        cmd.String("out"),
+		Separator:      cmd.String("separator"),
+		Nest:           cmd.Bool("nest"),
+		Upper:          cmd.Bool("upper"),
+		RecipientsFile: cmd.String("recipients-file"),
+		IdentitiesPath: cmd.String("identities"),
+		Armor:          cmd.Bool("armor"),
+		Confirm:        confirmFromFlags(cmd),
+	}
+
+	from, err := convert.FormatFromPath(cfg.FilePath)
+	if cfg.From != "" {
+		from, err = convert.ParseFormat(cfg.From)
+	}
+	if err != nil {
+		return fmt.Errorf("convert: %w", err)
+	}
+	to, err := convert.ParseFormat(cfg.To)
+	if err != nil {
+		return fmt.Errorf("convert: %w", err)
+	}
+
+	ids, err := agepkg.LoadIdentities(cfg.IdentitiesPath)
+	if err != nil {
+		return err
+	}
+	recips, err := agepkg.LoadRecipients(cfg.RecipientsFile)
+	if err != nil {
+		return err
+	}
+	plain, err := agepkg.DecryptToMemory(cfg.FilePath, ids)
+	if err != nil {
+		return err
+	}
+
+	out, err := convert.Convert(plain, from, to, convert.Options{
+		Separator: cfg.Separator,
+		Nest:      cfg.Nest,
+		Upper:     cfg.Upper,
+	})
+	if err != nil {
+		return fmt.Errorf("convert: %w", err)
+	}
+	if err := validator.ValidateByExt("converted."+string(to), out); err != nil {
+		return fmt.Errorf("convert: converted output does not validate: %w", err)
+	}
+
+	if _, err := os.Stat(cfg.OutPath); err == nil {
+		if err := confirm(cfg.Confirm, fmt.Sprintf("Overwrite %s?", cfg.OutPath)); err != nil {
+			return fmt.Errorf("convert: %w", err)
+		}
+	}
+	if err := encryptWrite(cfg.OutPath, []byte(out), recips, cfg.Armor); err != nil {
+		return fmt.Errorf("convert: %w", err)
+	}
+	fmt.Printf("converted %s (%s) -> %s (%s)\n", cfg.FilePath, from, cfg.OutPath, to)
+	return nil
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/andreweick/agepad/control"
+	"github.com/andreweick/agepad/tui"
+	"github.com/charmbracelet/x/term"
+	"github.com/urfave/cli/v3"
+)
+
+func ctlCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "ctl",
+		Usage: "Drive an editor started with --control (status, save, insert, diff)",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  "socket",
+				Usage: "The editor's control socket ($AGEPAD_CONTROL)",
+				Value: control.SocketPath(),
+			},
+		},
+		Commands: []*cli.Command{
+			{
+				Name:  "status",
+				Usage: "Print the buffer's state as JSON (key names, never values)",
+				Action: func(ctx context.Context, cmd *cli.Command) error {
+					return ctlStatus(cmd, "status", nil)
+				},
+			},
+			{
+				Name:  "save",
+				Usage: "Press Ctrl+S in the editor; call twice when it asks for confirmation",
+				Action: func(ctx context.Context, cmd *cli.Command) error {
+					return ctlStatus(cmd, "save", nil)
+				},
+			},
+			{
+				Name:      "insert",
+				Usage:     "Set KEY=VALUE in the editor's .env buffer (VALUE is read from stdin when omitted)",
+				ArgsUsage: "KEY [VALUE]",
+				Action:    runCtlInsert,
+			},
+			{
+				Name:  "diff",
+				Usage: "Print the unsaved changes as a unified diff",
+				Action: func(ctx context.Context, cmd *cli.Command) error {
+					var res struct {
+						Diff string `json:"diff"`
+					}
+					if err := control.Call(cmd.String("socket"), "diff", nil, &res); err != nil {
+						return err
+					}
+					fmt.Print(res.Diff)
+					return nil
+				},
+			},
+		},
+	}
+}
+
+// ctlStatus calls method and prints the editor's status as JSON.
+func ctlStatus(cmd *cli.Command, method string, params any) error {
+	var st tui.Status
+	if err := control.Call(cmd.String("socket"), method, params, &st); err != nil {
+		return err
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(st)
+}
+
+func runCtlInsert(ctx context.Context, cmd *cli.Command) error {
+	args := cmd.Args().Slice()
+	if len(args) < 1 || len(args) > 2 {
+		return fmt.Errorf("usage: agepad ctl insert KEY [VALUE]")
+	}
+	p := tui.InsertParams{Key: args[0]}
+	if len(args) == 2 {
+		p.Value = args[1]
+	} else {
+		// Keeps the value out of the process list and shell history.
+		if term.IsTerminal(os.Stdin.Fd()) {
+			return fmt.Errorf("pipe the value on stdin, or pass it after the key")
+		}
+		b, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return err
+		}
+		p.Value = strings.TrimSuffix(string(b), "\n")
+	}
+	return ctlStatus(cmd, "insert", p)
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"filippo.io/age"
+	agepkg "github.com/andreweick/agepad/age"
+	"github.com/andreweick/agepad/audit"
+	"github.com/andreweick/agepad/daemon"
+	"github.com/andreweick/agepad/model"
+	"github.com/urfave/cli/v3"
+)
+
+func daemonCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "daemon",
+		Usage: "Cache decrypted files in locked memory and serve them to `run` over a Unix socket",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  "identities",
+				Usage: "Path to AGE identities",
+				Value: defaultIdentitiesPath(),
+			},
+			&cli.DurationFlag{
+				Name:  "ttl",
+				Usage: "How long a decrypted file stays cached",
+				Value: 15 * time.Minute,
+			},
+			&cli.StringFlag{
+				Name:  "allow",
+				Usage: "YAML allowlist of binaries (and their files and keys) that may read from the daemon",
+			},
+			&cli.StringFlag{
+				Name:  "audit-log",
+				Usage: "Audit log that records every request",
+				Value: audit.DefaultPath(),
+			},
+			&cli.StringFlag{
+				Name:  "socket",
+				Usage: "Unix socket path (also used by status and stop)",
+				Value: daemon.SocketPath(),
+			},
+		},
+		Action: runDaemon,
+		Commands: []*cli.Command{
+			{
+				Name:  "status",
+				Usage: "Report whether a daemon is running and how many files it holds",
+				Action: func(ctx context.Context, cmd *cli.Command) error {
+					n, err := daemon.Stats(cmd.String("socket"))
+					if err != nil {
+						return err
+					}
+					fmt.Printf("daemon: running at %s, %d file(s) cached\n", cmd.String("socket"), n)
+					return nil
+				},
+			},
+			{
+				Name:  "stop",
+				Usage: "Wipe the daemon's cache and stop it",
+				Action: func(ctx context.Context, cmd *cli.Command) error {
+					return daemon.Stop(cmd.String("socket"))
+				},
+			},
+		},
+	}
+}
+
+func runDaemon(ctx context.Context, cmd *cli.Command) error {
+	cfg := model.DaemonConfig{
+		IdentitiesPath: cmd.String("identities"),
+		Socket:         cmd.String("socket"),
+		TTL:            cmd.Duration("ttl"),
+		PolicyPath:     cmd.String("allow"),
+		AuditLog:       cmd.String("audit-log"),
+	}
+	ids, err := agepkg.LoadIdentities(cfg.IdentitiesPath)
+	if err != nil {
+		return err
+	}
+	var policy *daemon.Policy
+	if cfg.PolicyPath != "" {
+		if policy, err = daemon.LoadPolicy(cfg.PolicyPath); err != nil {
+			return err
+		}
+	}
+	self, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	ln, err := daemon.Listen(cfg.Socket)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(cfg.Socket)
+
+	ctx, cancel := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+	s := &daemon.Server{
+		TTL: cfg.TTL,
+		Decrypt: func(cipher []byte) (string, error) {
+			return agepkg.Decrypt(cipher, ids)
+		},
+		Policy: policy,
+		Self:   self,
+		Audit:  &audit.Log{Path: cfg.AuditLog},
+	}
+	fmt.Fprintf(os.Stderr, "daemon: listening on %s (ttl %s)\n", cfg.Socket, cfg.TTL)
+	return s.Serve(ctx, ln)
+}
+
+// decryptVia returns the plaintext of file from a running daemon, or
+// decrypts it in-process when there is none. forExe is the binary the
+// plaintext is for, which the daemon's allowlist is checked against. ids
+// holds the identities once loaded, so several files ask only once.
+func decryptVia(cfg model.RunConfig, file, forExe string, ids *[]age.Identity) (string, error) {
+	if !cfg.NoDaemon {
+		plain, err := daemon.Get(daemon.SocketPath(), file, forExe)
+		if !errors.Is(err, daemon.ErrNotRunning) {
+			return plain, err
+		}
+	}
+	if *ids == nil {
+		loaded, err := agepkg.LoadIdentities(cfg.IdentitiesPath)
+		if err != nil {
+			return "", err
+		}
+		*ids = loaded
+	}
+	return agepkg.DecryptToMemory(file, *ids)
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+
+	agepkg "github.com/andreweick/agepad/age"
+	"github.com/andreweick/agepad/config"
+	"github.com/andreweick/agepad/derived"
+	"github.com/andreweick/agepad/filetype"
+	"github.com/andreweick/agepad/model"
+	"github.com/andreweick/agepad/validator"
+	"github.com/urfave/cli/v3"
+)
+
+func deriveCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "derive",
+		Usage: "Regenerate the derived files declared in .agepad.toml (SHA256SUMS, key-name manifest, redacted catalog) from the whole tree",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  "identities",
+				Usage: "Path to AGE identities",
+				Value: defaultIdentitiesPath(),
+			},
+			&cli.BoolFlag{
+				Name:  "check",
+				Usage: "Write nothing; fail if a derived file is missing or out of date (for CI)",
+			},
+		},
+		Action: runDerive,
+	}
+}
+
+func runDerive(ctx context.Context, cmd *cli.Command) error {
+	cfg := model.DeriveConfig{
+		IdentitiesPath: cmd.String("identities"),
+		Check:          cmd.Bool("check"),
+	}
+	var err error
+	if cfg.TypeRules, err = filetype.ParseRules(cmd.StringSlice("type-map")); err != nil {
+		return err
+	}
+	conf, err := config.LoadAll()
+	if err != nil {
+		return err
+	}
+	if conf, err = withSealed(conf, cfg.IdentitiesPath); err != nil {
+		return err
+	}
+	if len(conf.Derived) == 0 {
+		return errors.New("derive: no [[derived]] files declared in .agepad.toml")
+	}
+
+	// SHA256SUMS needs no key; load identities only for the others.
+	var open func(path string) (string, string, error)
+	if slices.ContainsFunc(conf.Derived, func(a derived.Artifact) bool { return a.Kind != derived.SHA256Sums }) {
+		ids, err := agepkg.LoadIdentities(cfg.IdentitiesPath)
+		if err != nil {
+			return err
+		}
+		open = func(path string) (string, string, error) {
+			plain, err := agepkg.DecryptToMemory(path, ids)
+			if err != nil {
+				return "", "", err
+			}
+			return plain, validator.Format(path, plain, cfg.TypeRules), nil
+		}
+	}
+
+	var stale []string
+	for _, a := range conf.Derived {
+		changed, err := a.Rebuild(open, cfg.Check)
+		if err != nil {
+			return fmt.Errorf("derive: %w", err)
+		}
+		switch {
+		case changed && cfg.Check:
+			stale = append(stale, a.Out)
+			fmt.Println(a.Out)
+		case changed:
+			fmt.Fprintf(os.Stderr, "derive: updated %s (%s)\n", a.Out, a.Kind)
+		}
+	}
+	if len(stale) > 0 {
+		return fmt.Errorf("derive: %d derived file(s) out of date; rerun without --check", len(stale))
+	}
+	return nil
+}
+
+// afterSaveHook returns the editor's after-save hook, which brings the
+// derived files covering a saved file up to date, or nil if none are
+// declared.
+func afterSaveHook(artifacts []derived.Artifact, rules []model.TypeRule) func(path, plain string) error {
+	if len(artifacts) == 0 {
+		return nil
+	}
+	return func(path, plain string) error {
+		format := validator.Format(path, plain, rules)
+		var errs []error
+		for _, a := range artifacts {
+			if _, err := a.Saved(path, plain, format); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		return errors.Join(errs...)
+	}
+}
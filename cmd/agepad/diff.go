@@ -0,0 +1,25 @@
+package main
+
+import (
+	"os"
+
+	"github.com/andreweick/agepad/diff"
+	"github.com/charmbracelet/x/term"
+)
+
+// unifiedDiff renders a unified diff of a and b for CLI previews. Changed
+// words are shown in reverse video on a terminal and as [-old-]{+new+}
+// when piped.
+func unifiedDiff(a, b, filename string, alg diff.Algorithm) string {
+	mark := diff.PlainMark
+	if term.IsTerminal(os.Stdout.Fd()) {
+		mark = diff.ANSIMark
+	}
+	return diff.Unified(a, b, diff.Options{
+		FromFile:  filename + " (current)",
+		ToFile:    filename + " (proposed)",
+		Context:   3,
+		Algorithm: alg,
+		Mark:      mark,
+	})
+}
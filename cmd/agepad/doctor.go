@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+
+	"filippo.io/age"
+	"filippo.io/age/plugin"
+	agepkg "github.com/andreweick/agepad/age"
+	"github.com/andreweick/agepad/daemon"
+	"github.com/andreweick/agepad/harden"
+	"github.com/andreweick/agepad/platform"
+	"github.com/urfave/cli/v3"
+)
+
+func doctorCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "doctor",
+		Usage: "Check identities and recipients, or (--platform) which OS-dependent features work here",
+		Flags: []cli.Flag{
+			&cli.BoolFlag{
+				Name:  "platform",
+				Usage: "Report the platform capabilities agepad uses (process replacement, locks, hardening, daemon peer checks)",
+			},
+		},
+		Action: runDoctor,
+	}
+}
+
+func runDoctor(ctx context.Context, cmd *cli.Command) error {
+	if cmd.Bool("platform") {
+		fmt.Print(platformReport())
+		return nil
+	}
+	ids, err := agepkg.LoadIdentities(cmd.String("identities"))
+	if err != nil {
+		return err
+	}
+	fmt.Printf("identities:  %s (%d)\n", cmd.String("identities"), len(ids))
+	recips, err := agepkg.LoadRecipients(cmd.String("recipients-file"))
+	if err != nil {
+		return err
+	}
+	fmt.Printf("recipients:  %s (%d)\n", cmd.String("recipients-file"), len(recips))
+	for _, name := range pluginNames(ids, recips) {
+		bin := "age-plugin-" + name
+		if path, err := exec.LookPath(bin); err == nil {
+			fmt.Printf("plugin:      %s\n", path)
+		} else {
+			fmt.Printf("plugin:      %s not found in $PATH\n", bin)
+		}
+	}
+	// The same round trip the editor runs before every save.
+	cipher, err := agepkg.EncryptToMemory([]byte("agepad doctor"), recips, false)
+	if err != nil {
+		return fmt.Errorf("doctor: encrypt to recipients: %w", err)
+	}
+	if _, err := agepkg.Decrypt(cipher, ids); err != nil {
+		return fmt.Errorf("doctor: none of your identities can decrypt for these recipients; saves would lock you out: %w", err)
+	}
+	fmt.Println("preflight:   ok (your identities decrypt what the recipients file encrypts to)")
+	return nil
+}
+
+// pluginNames lists the age plugins the identities and recipients need.
+func pluginNames(ids []age.Identity, recips []age.Recipient) []string {
+	seen := map[string]bool{}
+	var names []string
+	add := func(name string) {
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	for _, id := range ids {
+		if p, ok := id.(*plugin.Identity); ok {
+			add(p.Name())
+		}
+	}
+	for _, r := range recips {
+		if p, ok := r.(*plugin.Recipient); ok {
+			add(p.Name())
+		}
+	}
+	return names
+}
+
+// platformReport lists platform capabilities, then the --harden measures
+// as applied to this process, then the daemon's client check.
+func platformReport() string {
+	caps := platform.Capabilities()
+	caps = append(caps, platform.Capability{
+		Name:   "daemon identifies its clients",
+		Active: daemon.PeerCheck != "",
+		Detail: daemon.PeerCheck,
+	})
+	probe := make([]byte, 4096)
+	lockErr := harden.Lock(probe)
+	if lockErr == nil {
+		_ = harden.Unlock(probe)
+	}
+	caps = append(caps, platform.Capability{Name: "buffers kept out of swap (mlock)", Active: lockErr == nil, Detail: errDetail(lockErr)})
+
+	r := harden.Apply()
+	r.ExcludeFromDumps("decrypted buffer", string(probe))
+	return platform.String(caps) + "\n--harden:\n" + r.String()
+}
+
+func errDetail(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	agepkg "github.com/andreweick/agepad/age"
+	"github.com/andreweick/agepad/convert"
+	"github.com/andreweick/agepad/dotenv"
+	"github.com/andreweick/agepad/dupes"
+	"github.com/andreweick/agepad/filetype"
+	"github.com/andreweick/agepad/model"
+	"github.com/andreweick/agepad/validator"
+	"github.com/andreweick/agepad/walk"
+	"github.com/urfave/cli/v3"
+)
+
+func dupesCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "dupes",
+		Usage: "Report files and values that are identical across a tree (values are never printed)",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  "root",
+				Usage: "Directory tree of .age files to compare",
+				Value: ".",
+			},
+			&cli.StringFlag{
+				Name:  "identities",
+				Usage: "Path to AGE identities",
+				Value: defaultIdentitiesPath(),
+			},
+			&cli.IntFlag{
+				Name:  "min-files",
+				Usage: "Report values found in at least this many files",
+				Value: 2,
+			},
+			&cli.IntFlag{
+				Name:  "min-length",
+				Usage: "Ignore values shorter than this many characters (ports, flags, regions)",
+				Value: 8,
+			},
+		},
+		Action: runDupes,
+	}
+}
+
+func runDupes(ctx context.Context, cmd *cli.Command) error {
+	cfg := model.DupesConfig{
+		Root:           cmd.String("root"),
+		IdentitiesPath: cmd.String("identities"),
+		MinFiles:       int(cmd.Int("min-files")),
+		MinLength:      int(cmd.Int("min-length")),
+	}
+	var err error
+	if cfg.TypeRules, err = filetype.ParseRules(cmd.StringSlice("type-map")); err != nil {
+		return err
+	}
+
+	ids, err := agepkg.LoadIdentities(cfg.IdentitiesPath)
+	if err != nil {
+		return err
+	}
+	files, err := walk.AgeFiles(cfg.Root)
+	if err != nil {
+		return err
+	}
+
+	finder := dupes.New(cfg.MinLength)
+	failed := 0
+	for _, f := range files {
+		plain, err := agepkg.DecryptToMemory(f, ids)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "dupes: %s: %v\n", f, err)
+			failed++
+			continue
+		}
+		values, err := keyValues(f, plain, cfg.TypeRules)
+		if err != nil {
+			// Still compared as a whole file.
+			fmt.Fprintf(os.Stderr, "dupes: %s: values not compared: %v\n", f, err)
+		}
+		finder.Add(f, plain, values)
+	}
+
+	same := finder.Files()
+	for _, group := range same {
+		fmt.Printf("identical content in %d files:\n", len(group))
+		for _, f := range group {
+			fmt.Printf("  %s\n", f)
+		}
+	}
+	values := finder.Values(cfg.MinFiles)
+	for _, g := range values {
+		fmt.Printf("value %s (%d chars) in %d files:\n", g.Fingerprint, g.Length, g.Files)
+		for _, l := range g.Locations {
+			fmt.Printf("  %s  %s\n", l.File, l.Key)
+		}
+	}
+	fmt.Fprintf(os.Stderr, "dupes: %d file(s), %d identical set(s), %d repeated value(s)\n", len(files), len(same), len(values))
+	if failed > 0 {
+		return fmt.Errorf("dupes: %d file(s) could not be decrypted", failed)
+	}
+	return nil
+}
+
+// keyValues returns a decrypted file's values by key: env names, or dotted
+// paths for JSON, YAML, and TOML.
+func keyValues(path, plain string, rules []model.TypeRule) (map[string]string, error) {
+	switch f := validator.Format(path, plain, rules); f {
+	case "env":
+		return dotenv.Map(plain)
+	case "":
+		return nil, fmt.Errorf("unknown format; name it with --type-map")
+	default:
+		data, err := convert.Decode(plain, convert.Format(f))
+		if err != nil {
+			return nil, err
+		}
+		return convert.Flatten(data, convert.Options{Separator: "."}), nil
+	}
+}
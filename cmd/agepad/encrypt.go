@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+
+	"filippo.io/age"
+	agepkg "github.com/andreweick/agepad/age"
+	"github.com/andreweick/agepad/buildinfo"
+	"github.com/andreweick/agepad/model"
+	"github.com/andreweick/agepad/validator"
+	"github.com/andreweick/agepad/valuecrypt"
+	"github.com/charmbracelet/x/term"
+	"github.com/urfave/cli/v3"
+)
+
+func encryptCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "encrypt",
+		Usage: "Validate plaintext from stdin or a file and encrypt it to the recipients file",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  "in",
+				Usage: "Plaintext file to read (default: stdin)",
+			},
+			&cli.StringFlag{
+				Name:     "out",
+				Usage:    "Encrypted file to write; its name before .age picks the format to validate",
+				Required: true,
+			},
+			&cli.BoolFlag{
+				Name:  "force",
+				Usage: "Replace --out if it already exists",
+			},
+			&cli.StringFlag{
+				Name:  "recipients-file",
+				Usage: "Path to recipients file",
+				Value: defaultRecipientsFile,
+			},
+			&cli.StringSliceFlag{
+				Name:  "recipient-user",
+				Usage: "Encrypt to this user's keys from --directory instead of the recipients file (repeatable)",
+			},
+			&cli.StringSliceFlag{
+				Name:  "recipient-group",
+				Usage: "Encrypt to the keys of this group's members from --directory instead of the recipients file (repeatable)",
+			},
+			directoryFlag,
+			&cli.BoolFlag{
+				Name:  "armor",
+				Usage: "Write ASCII-armored .age output",
+				Value: buildinfo.ArmorDefault,
+			},
+			&cli.BoolFlag{
+				Name:  "values",
+				Usage: "Encrypt each JSON/YAML value on its own, keeping keys and YAML comments readable so diffs show which keys changed (--out keeps its .json/.yaml name; the editor opens it as usual)",
+			},
+			yesFlag,
+			confirmPromptFlag,
+		},
+		Action: runEncrypt,
+	}
+}
+
+func runEncrypt(ctx context.Context, cmd *cli.Command) error {
+	cfg := model.EncryptConfig{
+		InPath:         cmd.String("in"),
+		OutPath:        cmd.String("out"),
+		RecipientsFile: cmd.String("recipients-file"),
+		Users:          cmd.StringSlice("recipient-user"),
+		Groups:         cmd.StringSlice("recipient-group"),
+		Directory:      cmd.String("directory"),
+		Armor:          cmd.Bool("armor"),
+		Values:         cmd.Bool("values"),
+		Force:          cmd.Bool("force"),
+		Schema:         cmd.String("schema"),
+		Stanzas:        stanzaPolicy(cmd),
+		Confirm:        confirmFromFlags(cmd),
+	}
+	var err error
+	if cfg.Validation, cfg.TypeRules, err = validationFromFlags(cmd); err != nil {
+		return err
+	}
+	return encryptFile(ctx, cfg)
+}
+
+// encryptFile reads, validates, and encrypts cfg.InPath to cfg.OutPath,
+// for the recipients file or, if any are named, the directory's users and
+// groups. Nothing of the plaintext is printed.
+func encryptFile(ctx context.Context, cfg model.EncryptConfig) error {
+	if _, err := os.Stat(cfg.OutPath); err == nil && !cfg.Force {
+		return fmt.Errorf("encrypt: %s exists; pass --force to replace it", cfg.OutPath)
+	} else if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("encrypt: %w", err)
+	}
+
+	var (
+		plain []byte
+		err   error
+	)
+	if cfg.InPath == "" || cfg.InPath == "-" {
+		if term.IsTerminal(os.Stdin.Fd()) {
+			return fmt.Errorf("encrypt: pass --in or pipe the plaintext on stdin")
+		}
+		plain, err = io.ReadAll(os.Stdin)
+	} else {
+		plain, err = os.ReadFile(cfg.InPath)
+	}
+	if err != nil {
+		return fmt.Errorf("encrypt: read plaintext: %w", err)
+	}
+
+	format := validator.Format(cfg.OutPath, string(plain), cfg.TypeRules)
+	if cfg.Values && format != "json" && format != "yaml" {
+		return fmt.Errorf("encrypt: --values encrypts JSON or YAML; name --out *.json or *.yaml")
+	}
+	sev, err := validator.CheckWithSchema(cfg.OutPath, string(plain), cfg.Schema, cfg.Validation, cfg.TypeRules)
+	if err != nil {
+		if sev != model.SeverityWarn {
+			return fmt.Errorf("encrypt: %w", err)
+		}
+		fmt.Fprintln(os.Stderr, "warning:", err)
+	}
+
+	var recips []age.Recipient
+	source := cfg.RecipientsFile
+	if len(cfg.Users)+len(cfg.Groups) > 0 {
+		source = cfg.Directory
+		if _, recips, err = resolveRecipients(ctx, cfg.Directory, cfg.Users, cfg.Groups); err != nil {
+			return fmt.Errorf("encrypt: %w", err)
+		}
+	} else if recips, err = agepkg.LoadRecipients(cfg.RecipientsFile); err != nil {
+		return err
+	}
+	var cipher, keyBlock []byte
+	if cfg.Values {
+		var enc string
+		if enc, err = valuecrypt.Encrypt(string(plain), format, recips, nil); err == nil {
+			cipher = []byte(enc)
+			keyBlock, err = valuecrypt.KeyBlock(enc, format)
+		}
+	} else {
+		cipher, err = agepkg.EncryptToMemory(plain, recips, cfg.Armor)
+		keyBlock = cipher
+	}
+	if err != nil {
+		return fmt.Errorf("encrypt: %w", err)
+	}
+	h, err := agepkg.InspectHeaderBytes(keyBlock)
+	if err == nil {
+		err = agepkg.CheckStanzas(h, cfg.Stanzas)
+	}
+	if err != nil {
+		return fmt.Errorf("encrypt: recipients in %s: %w", source, err)
+	}
+
+	if err := confirm(cfg.Confirm, fmt.Sprintf("Write %s for %d recipient(s)?", cfg.OutPath, len(recips))); err != nil {
+		return err
+	}
+	if err := agepkg.WriteRetrying(cfg.OutPath, cipher, writeRetry); err != nil {
+		return err
+	}
+	if cfg.Values {
+		fmt.Printf("encrypted the values of %s to %s for %d recipient(s)\n", format, cfg.OutPath, len(recips))
+		return nil
+	}
+	fmt.Printf("encrypted %d bytes to %s for %d recipient(s) (armor=%v)\n", len(plain), cfg.OutPath, len(recips), cfg.Armor)
+	return nil
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"filippo.io/age"
+	agepkg "github.com/andreweick/agepad/age"
+	"github.com/andreweick/agepad/model"
+	"github.com/urfave/cli/v3"
+)
+
+// serverIdentitiesPath is where enroll --server keeps a host's key.
+const serverIdentitiesPath = "/etc/agepad/key.txt"
+
+func enrollCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "enroll",
+		Usage: "Create this machine's age identity, print the recipient line to add to the roster, and (run again once added) check it decrypts",
+		Flags: []cli.Flag{
+			&cli.BoolFlag{
+				Name:  "server",
+				Usage: "Enroll a host: --out defaults to " + serverIdentitiesPath + " and the label to the host name",
+			},
+			&cli.StringFlag{
+				Name:  "out",
+				Usage: "Identities file to create, or to check if it exists (default: your --identities path, or " + serverIdentitiesPath + " with --server)",
+			},
+			&cli.StringFlag{
+				Name:  "recipients-file",
+				Usage: "Recipients roster the key is added to",
+				Value: defaultRecipientsFile,
+			},
+			&cli.StringFlag{
+				Name:  "label",
+				Usage: "Name for the key's # comment in the roster (default: user@host, or the host name with --server)",
+			},
+			&cli.StringSliceFlag{
+				Name:  "check",
+				Usage: "Encrypted file this machine must be able to decrypt once enrolled; repeatable",
+			},
+		},
+		Action: runEnroll,
+	}
+}
+
+func runEnroll(ctx context.Context, cmd *cli.Command) error {
+	host, _ := os.Hostname()
+	cfg := model.EnrollConfig{
+		OutPath:        cmd.String("out"),
+		RecipientsFile: cmd.String("recipients-file"),
+		Label:          cmd.String("label"),
+		Check:          cmd.StringSlice("check"),
+	}
+	switch {
+	case cfg.OutPath != "":
+	case cmd.Bool("server"):
+		cfg.OutPath = serverIdentitiesPath
+	default:
+		cfg.OutPath = cmd.String("identities")
+	}
+	if agepkg.IsInline(cfg.OutPath) {
+		return fmt.Errorf("enroll: pass --out; the key is written to a file")
+	}
+	if cfg.Label == "" {
+		cfg.Label = host
+		if u := os.Getenv("USER"); u != "" && !cmd.Bool("server") {
+			cfg.Label = u + "@" + host
+		}
+	}
+
+	id, created, err := enrollIdentity(cfg.OutPath)
+	if err != nil {
+		return fmt.Errorf("enroll: %w", err)
+	}
+	pub := id.Recipient().String()
+	if created {
+		fmt.Printf("enroll: created %s (mode 0600)\n", cfg.OutPath)
+	} else {
+		fmt.Printf("enroll: using existing %s\n", cfg.OutPath)
+	}
+	fmt.Printf("\n%s # %s\n\n", pub, cfg.Label)
+
+	listed, err := enrollListed(cfg.RecipientsFile, pub)
+	if err != nil {
+		return fmt.Errorf("enroll: %w", err)
+	}
+	if !listed {
+		fmt.Printf("Add the line above to %s, have an admin re-encrypt the files this machine needs\n"+
+			"(agepad rotate --to %s ...), then run this command again to check.\n", cfg.RecipientsFile, cfg.RecipientsFile)
+		if created {
+			return nil
+		}
+		// Rerun to check: scripts can poll on the exit status.
+		return fmt.Errorf("enroll: %s is not in %s yet", pub, cfg.RecipientsFile)
+	}
+	fmt.Printf("roster:    listed in %s\n", cfg.RecipientsFile)
+
+	// The round trip a save runs, from this machine's side.
+	recips, err := agepkg.LoadRecipients(cfg.RecipientsFile)
+	if err != nil {
+		return err
+	}
+	probe, err := agepkg.EncryptToMemory([]byte("agepad enroll"), recips, false)
+	if err != nil {
+		return fmt.Errorf("enroll: encrypt to recipients: %w", err)
+	}
+	ids := []age.Identity{id}
+	if _, err := agepkg.Decrypt(probe, ids); err != nil {
+		return fmt.Errorf("enroll: this key cannot decrypt what %s encrypts to: %w", cfg.RecipientsFile, err)
+	}
+	fmt.Println("preflight: ok (files saved for this roster will decrypt here)")
+
+	var failed int
+	for _, path := range cfg.Check {
+		if _, err := agepkg.DecryptToMemory(path, ids); err != nil {
+			failed++
+			fmt.Printf("check:     FAIL %s: %v\n", path, err)
+			continue
+		}
+		fmt.Printf("check:     ok   %s\n", path)
+	}
+	if failed > 0 {
+		return fmt.Errorf("enroll: %d of %d file(s) not yet re-encrypted to this key; run agepad rotate --to %s", failed, len(cfg.Check), cfg.RecipientsFile)
+	}
+	return nil
+}
+
+// enrollIdentity loads the X25519 key at path, or creates it with the
+// layout age-keygen writes, readable only by its owner.
+func enrollIdentity(path string) (*age.X25519Identity, bool, error) {
+	b, err := os.ReadFile(path)
+	if err == nil {
+		defer clear(b)
+		if fi, err := os.Stat(path); err == nil && runtime.GOOS != "windows" && fi.Mode().Perm()&0o077 != 0 {
+			fmt.Fprintf(os.Stderr, "warning: %s is readable by others (mode %04o); chmod 600 it\n", path, fi.Mode().Perm())
+		}
+		ids, err := age.ParseIdentities(bytes.NewReader(b))
+		if err != nil {
+			return nil, false, fmt.Errorf("%s: %w", path, err)
+		}
+		if id, ok := ids[0].(*age.X25519Identity); ok && len(ids) == 1 {
+			return id, false, nil
+		}
+		return nil, false, fmt.Errorf("%s is not a single age X25519 key; pass --out for this machine's own", path)
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return nil, false, err
+	}
+
+	id, err := age.GenerateX25519Identity()
+	if err != nil {
+		return nil, false, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, false, err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return nil, false, err
+	}
+	_, err = fmt.Fprintf(f, "# created: %s\n# public key: %s\n%s\n",
+		time.Now().UTC().Format(time.RFC3339), id.Recipient(), id)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(path)
+		return nil, false, err
+	}
+	return id, true, nil
+}
+
+// enrollListed reports whether the roster at path holds pub. A missing
+// roster lists nobody.
+func enrollListed(path, pub string) (bool, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	recips, err := agepkg.ParseLabeledRecipients(f)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", path, err)
+	}
+	for _, r := range recips {
+		if strings.TrimSpace(r.Key) == pub {
+			return true, nil
+		}
+	}
+	return false, nil
+}
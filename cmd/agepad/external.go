@@ -0,0 +1,142 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"filippo.io/age"
+	agepkg "github.com/andreweick/agepad/age"
+	"github.com/andreweick/agepad/diff"
+	"github.com/andreweick/agepad/keyops"
+	"github.com/andreweick/agepad/model"
+	"github.com/andreweick/agepad/platform"
+	"github.com/andreweick/agepad/stats"
+	"github.com/andreweick/agepad/validator"
+)
+
+// editExternal edits plain in the user's own editor through a
+// memory-backed file, then validates, preflights, confirms, and saves it
+// as the TUI would. A failed validation offers to reopen the editor on the
+// edited text, so nothing typed is lost. afterSave, if set, runs after the
+// save as in the TUI.
+func editExternal(cfg model.Config, plain string, ids []age.Identity, recips []age.Recipient, afterSave func(path, plain string) error) error {
+	name := strings.TrimSuffix(filepath.Base(cfg.FilePath), ".age")
+	if cfg.ArmorAuto {
+		if h, err := agepkg.InspectHeader(cfg.FilePath); err == nil {
+			cfg.Armor = h.Armored
+		}
+	}
+	buf := plain
+	for {
+		edited, err := runExternalEditor(name, buf)
+		if err != nil {
+			return err
+		}
+		buf = edited
+		if buf == plain {
+			fmt.Fprintln(os.Stderr, "No changes; nothing saved.")
+			return nil
+		}
+		if cfg.ViewOnly {
+			return fmt.Errorf("view-only mode: changes discarded")
+		}
+		sev, err := validator.CheckWithSchema(cfg.FilePath, buf, cfg.Schema, cfg.Validation, cfg.TypeRules)
+		if err != nil && sev == model.SeverityWarn {
+			fmt.Fprintf(os.Stderr, "Validation warning: %v\n", err)
+		} else if err != nil {
+			fmt.Fprintf(os.Stderr, "Validation failed: %v\n", err)
+			if confirm(model.Confirm{Prompt: true}, "Edit again?") != nil {
+				return errors.New("not saved")
+			}
+			continue
+		}
+		break
+	}
+
+	cipher, err := agepkg.EncryptToMemory([]byte(buf), recips, cfg.Armor)
+	if err != nil {
+		return fmt.Errorf("preflight encrypt: %w", err)
+	}
+	h, err := agepkg.InspectHeaderBytes(cipher)
+	if err == nil {
+		err = agepkg.CheckStanzas(h, cfg.Stanzas)
+	}
+	if err != nil {
+		return fmt.Errorf("recipients: %w", err)
+	}
+	if _, err := agepkg.Decrypt(cipher, ids); err != nil {
+		return fmt.Errorf("preflight decrypt failed with current identities; "+
+			"you may lock yourself out: %w", err)
+	}
+
+	// The editor's screen is gone, so the preview lands in scrollback:
+	// show .env changes with values masked and other formats by size only.
+	if validator.Format(cfg.FilePath, buf, cfg.TypeRules) == "env" {
+		fmt.Fprint(os.Stderr, unifiedDiff(keyops.MaskValues(plain), keyops.MaskValues(buf),
+			filepath.Base(cfg.FilePath), diff.Algorithm(cfg.DiffAlgorithm)))
+	} else {
+		fmt.Fprintf(os.Stderr, "%s: %d bytes -> %d bytes\n", cfg.FilePath, len(plain), len(buf))
+	}
+	if err := confirm(model.Confirm{Prompt: true}, "Save "+cfg.FilePath+"?"); err != nil {
+		return err
+	}
+	if err := agepkg.WriteRetrying(cfg.FilePath, cipher, writeRetry); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Saved %s (armor=%v)\n", cfg.FilePath, cfg.Armor)
+	if afterSave != nil {
+		if err := afterSave(cfg.FilePath, buf); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		}
+	}
+	recordStats(stats.Save, filepath.Dir(cfg.FilePath), 1)
+	return nil
+}
+
+// runExternalEditor opens text in $VISUAL/$EDITOR and returns what the
+// user saved. The file exists only in memory and is wiped afterwards.
+func runExternalEditor(name, text string) (string, error) {
+	mf, err := platform.NewMemFile(name, []byte(text))
+	if err != nil {
+		return "", fmt.Errorf("--editor external: %w", err)
+	}
+	defer mf.Close()
+
+	argv := editorCommand(mf.Path)
+	c := exec.Command(argv[0], argv[1:]...)
+	c.Stdin, c.Stdout, c.Stderr = os.Stdin, os.Stdout, os.Stderr
+	c.ExtraFiles = mf.Files
+	if err := c.Run(); err != nil {
+		return "", fmt.Errorf("%s: %w; nothing saved", argv[0], err)
+	}
+	b, err := mf.Read()
+	if err != nil {
+		return "", err
+	}
+	s := string(b)
+	clear(b)
+	return s, nil
+}
+
+// editorCommand returns $VISUAL or $EDITOR split into words (vi when
+// neither is set) with path appended. Vim and Neovim also get options that
+// stop them writing swap, backup, undo, and history files, which would
+// otherwise put the plaintext back on disk.
+func editorCommand(path string) []string {
+	argv := strings.Fields(os.Getenv("VISUAL"))
+	if len(argv) == 0 {
+		argv = strings.Fields(os.Getenv("EDITOR"))
+	}
+	if len(argv) == 0 {
+		argv = []string{"vi"}
+	}
+	switch filepath.Base(argv[0]) {
+	case "vim", "nvim", "gvim":
+		argv = append(argv, "-n", "-i", "NONE", "--cmd", "set nobackup nowritebackup noundofile")
+	}
+	return append(argv, path)
+}
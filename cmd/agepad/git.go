@@ -0,0 +1,308 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	agepkg "github.com/andreweick/agepad/age"
+	"github.com/andreweick/agepad/gitutil"
+	"github.com/andreweick/agepad/model"
+	"github.com/andreweick/agepad/viewwindow"
+	"github.com/urfave/cli/v3"
+)
+
+// gitDriver names agepad's entries in .gitattributes and git config.
+const gitDriver = "agepad"
+
+func gitTextconvCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "git-textconv",
+		Usage:     "Print a decrypted .age file for git diff (a textconv driver; see git-setup)",
+		ArgsUsage: "<path>",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  "identities",
+				Usage: "Path to AGE identities",
+				Value: defaultIdentitiesPath(),
+			},
+		},
+		Action: runGitTextconv,
+	}
+}
+
+// runGitTextconv prints the plaintext of the file git names. It never
+// fails the diff: a file it cannot or may not show is printed as one line
+// saying why, with its recipient stanzas, so recipient changes still show.
+func runGitTextconv(ctx context.Context, cmd *cli.Command) error {
+	if cmd.Args().Len() != 1 {
+		return fmt.Errorf("git-textconv: want one path, got %d", cmd.Args().Len())
+	}
+	path := cmd.Args().First()
+	cipher, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("git-textconv: %w", err)
+	}
+	h, err := agepkg.InspectHeaderBytes(cipher)
+	if err != nil {
+		// Not age content (e.g. a mismatched pattern): show it as is.
+		_, err = os.Stdout.Write(cipher)
+		return err
+	}
+	text, err := textconv(cmd.String("identities"), cmd.String("view-window"), cipher)
+	if err != nil {
+		text = fmt.Sprintf("agepad: %d recipient stanza(s) (%s); %v\n", len(h.Stanzas), strings.Join(h.StanzaTypes(), ", "), err)
+	}
+	_, err = fmt.Print(text)
+	return err
+}
+
+// textconv decrypts cipher for a diff, refusing (without asking, as git
+// gives no terminal) expired shares and content outside its view window.
+func textconv(identities, policy string, cipher []byte) (string, error) {
+	ids, err := agepkg.LoadIdentities(identities)
+	if err != nil {
+		return "", fmt.Errorf("not decrypted; no usable identities at %s", identities)
+	}
+	plain, err := agepkg.Decrypt(cipher, ids)
+	if err != nil {
+		return "", fmt.Errorf("not decrypted with %s", identities)
+	}
+	if plain, _, err = openShared(plain); err != nil {
+		return "", err
+	}
+	var windows []viewwindow.Window
+	if policy != "" {
+		w, err := viewwindow.Parse(policy)
+		if err != nil {
+			return "", fmt.Errorf("--view-window: %w", err)
+		}
+		windows = append(windows, w)
+	}
+	found, err := viewwindow.Find(plain)
+	if err != nil {
+		return "", fmt.Errorf("not shown: %w", err)
+	}
+	if w, outside := viewwindow.Outside(append(windows, found...), time.Now()); outside {
+		return "", fmt.Errorf("not shown outside its view window (%s)", w)
+	}
+	return plain, nil
+}
+
+func gitCleanCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "git-clean",
+		Usage:     "Encrypt a working-tree file on its way into git (a clean filter; see git-setup --filter)",
+		ArgsUsage: "<path>",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  "recipients-file",
+				Usage: "Path to recipients file",
+				Value: defaultRecipientsFile,
+			},
+			&cli.StringFlag{
+				Name:  "identities",
+				Usage: "Path to AGE identities, to recognize unchanged content",
+				Value: defaultIdentitiesPath(),
+			},
+		},
+		Action: runGitClean,
+	}
+}
+
+// runGitClean encrypts the plaintext on stdin for the index. Content that
+// matches what is staged already gets the staged ciphertext back, since a
+// fresh encryption differs every time and git would see every filtered
+// file as modified.
+func runGitClean(ctx context.Context, cmd *cli.Command) error {
+	if cmd.Args().Len() != 1 {
+		return fmt.Errorf("git-clean: want one path, got %d", cmd.Args().Len())
+	}
+	path := cmd.Args().First()
+	plain, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		return fmt.Errorf("git-clean: %w", err)
+	}
+	if _, err := agepkg.InspectHeaderBytes(plain); err == nil {
+		// Still encrypted, as smudge leaves files it cannot decrypt.
+		_, err = os.Stdout.Write(plain)
+		return err
+	}
+	armor, auto := armorFromFlags(cmd)
+	if staged, err := (gitutil.Repo{}).Show("", path); err == nil {
+		if h, err := agepkg.InspectHeaderBytes(staged); err == nil {
+			if auto {
+				armor = h.Armored
+			}
+			if ids, err := agepkg.LoadIdentities(cmd.String("identities")); err == nil {
+				if old, err := agepkg.Decrypt(staged, ids); err == nil && old == string(plain) {
+					_, err = os.Stdout.Write(staged)
+					return err
+				}
+			}
+		}
+	}
+	recips, err := agepkg.LoadRecipients(cmd.String("recipients-file"))
+	if err != nil {
+		return fmt.Errorf("git-clean: %s: %w", path, err)
+	}
+	cipher, err := agepkg.EncryptToMemory(plain, recips, armor)
+	if err != nil {
+		return fmt.Errorf("git-clean: %s: %w", path, err)
+	}
+	_, err = os.Stdout.Write(cipher)
+	return err
+}
+
+func gitSmudgeCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "git-smudge",
+		Usage:     "Decrypt a file on its way out of git into the working tree (a smudge filter; see git-setup --filter)",
+		ArgsUsage: "<path>",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  "identities",
+				Usage: "Path to AGE identities",
+				Value: defaultIdentitiesPath(),
+			},
+		},
+		Action: runGitSmudge,
+	}
+}
+
+// runGitSmudge decrypts the ciphertext on stdin for the working tree. It
+// never fails a checkout: a file it cannot decrypt is left encrypted, with
+// a warning, and git-clean passes it back unchanged.
+func runGitSmudge(ctx context.Context, cmd *cli.Command) error {
+	path := cmd.Args().First()
+	cipher, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		return fmt.Errorf("git-smudge: %w", err)
+	}
+	out := cipher
+	if _, err := agepkg.InspectHeaderBytes(cipher); err == nil {
+		identities := cmd.String("identities")
+		ids, err := agepkg.LoadIdentities(identities)
+		if err == nil {
+			var plain string
+			if plain, err = agepkg.Decrypt(cipher, ids); err == nil {
+				out = []byte(plain)
+			}
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "agepad git-smudge: %s left encrypted; it does not decrypt with %s\n", path, identities)
+		}
+	}
+	_, err = os.Stdout.Write(out)
+	return err
+}
+
+func gitSetupCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "git-setup",
+		Usage: "Set git up for agepad: decrypted diffs of .age files and, with --filter, plaintext working-tree files stored encrypted; writes .gitattributes and .git/config",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  "pattern",
+				Usage: "Files the diff driver applies to, as a .gitattributes pattern",
+				Value: "*.age",
+			},
+			&cli.StringFlag{
+				Name:  "filter",
+				Usage: "Also keep files matching this .gitattributes pattern plaintext in the working tree and encrypted in git (clean/smudge filter)",
+			},
+			&cli.StringFlag{
+				Name:  "command",
+				Usage: "How git should run agepad (default: this executable's path)",
+			},
+		},
+		Action: runGitSetup,
+	}
+}
+
+func runGitSetup(ctx context.Context, cmd *cli.Command) error {
+	cfg := model.GitSetupConfig{
+		Pattern: cmd.String("pattern"),
+		Filter:  cmd.String("filter"),
+		Command: cmd.String("command"),
+	}
+	if cfg.Command == "" {
+		exe, err := os.Executable()
+		if err != nil {
+			return fmt.Errorf("git-setup: %w; pass --command", err)
+		}
+		cfg.Command = shellQuote(filepath.ToSlash(exe))
+	}
+	top, err := gitutil.Repo{}.TopLevel()
+	if err != nil {
+		return fmt.Errorf("git-setup: %w", err)
+	}
+	repo := gitutil.Repo{Dir: top}
+
+	attrs := filepath.Join(top, ".gitattributes")
+	lines := []string{cfg.Pattern + " diff=" + gitDriver}
+	settings := [][2]string{{"diff." + gitDriver + ".textconv", cfg.Command + " git-textconv"}}
+	if cfg.Filter != "" {
+		lines = append(lines, cfg.Filter+" filter="+gitDriver+" diff="+gitDriver)
+		// required: a filter that fails stops git add instead of
+		// letting the plaintext through.
+		settings = append(settings,
+			[2]string{"filter." + gitDriver + ".clean", cfg.Command + " git-clean %f"},
+			[2]string{"filter." + gitDriver + ".smudge", cfg.Command + " git-smudge %f"},
+			[2]string{"filter." + gitDriver + ".required", "true"})
+	}
+	for _, line := range lines {
+		added, err := addLine(attrs, line)
+		if err != nil {
+			return fmt.Errorf("git-setup: %w", err)
+		}
+		if added {
+			fmt.Printf("git-setup: added %q to %s; commit it so clones work the same way\n", line, attrs)
+		} else {
+			fmt.Printf("git-setup: %s already has %q\n", attrs, line)
+		}
+	}
+	for _, s := range settings {
+		if repo.Config(s[0]) == s[1] {
+			fmt.Printf("git-setup: %s is already %s\n", s[0], s[1])
+			continue
+		}
+		if err := repo.SetConfig(s[0], s[1]); err != nil {
+			return fmt.Errorf("git-setup: %w", err)
+		}
+		fmt.Printf("git-setup: set %s = %s in .git/config\n", s[0], s[1])
+	}
+	return nil
+}
+
+// addLine appends line to the file at path unless a line already equals
+// it, and reports whether it did.
+func addLine(path, line string) (bool, error) {
+	b, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return false, err
+	}
+	text := string(b)
+	if slices.Contains(strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n"), line) {
+		return false, nil
+	}
+	if text != "" && !strings.HasSuffix(text, "\n") {
+		text += "\n"
+	}
+	return true, os.WriteFile(path, []byte(text+line+"\n"), 0o644)
+}
+
+// shellQuote quotes s for the shell git runs drivers with.
+func shellQuote(s string) string {
+	if !strings.ContainsAny(s, " '\"\\$`!*?[]{}()<>|&;#~") {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"errors"
+	"path/filepath"
+
+	"filippo.io/age"
+	agepkg "github.com/andreweick/agepad/age"
+	"github.com/andreweick/agepad/filetype"
+	"github.com/andreweick/agepad/gitutil"
+	"github.com/andreweick/agepad/valuecrypt"
+)
+
+// headVersion decrypts path as committed at git HEAD. It reports false when
+// there is nothing to compare with: not in a repository, not committed yet,
+// no git binary, or a HEAD version these identities cannot open.
+func headVersion(path string, ids []age.Identity) (string, bool) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", false
+	}
+	// "./name" is resolved against Dir, so no repository-relative path is needed.
+	repo := gitutil.Repo{Dir: filepath.Dir(abs)}
+	rel := "./" + filepath.Base(abs)
+	if !repo.Exists("HEAD", rel) {
+		return "", false
+	}
+	cipher, err := repo.Show("HEAD", rel)
+	if err != nil {
+		return "", false
+	}
+	plain, err := agepkg.Decrypt(cipher, ids)
+	if errors.Is(err, agepkg.ErrNotAge) {
+		plain, _, err = valuecrypt.Decrypt(string(cipher), filetype.FromName(path, nil), ids)
+	}
+	if err != nil {
+		return "", false
+	}
+	return plain, true
+}
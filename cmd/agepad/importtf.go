@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/andreweick/agepad/outputs"
+	"github.com/urfave/cli/v3"
+)
+
+func importTerraformCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "import-terraform",
+		Usage: "Merge terraform/pulumi outputs into an encrypted file",
+		Flags: append([]cli.Flag{
+			&cli.StringFlag{
+				Name:  "state-cmd",
+				Usage: "Command printing outputs as JSON (split on whitespace, run without a shell)",
+				Value: "terraform output -json",
+			},
+			&cli.StringFlag{
+				Name:  "map",
+				Usage: "YAML file mapping output names to keys (default: import every output as-is)",
+			},
+		}, mergeTargetFlags()...),
+		Action: runImportTerraform,
+	}
+}
+
+func runImportTerraform(ctx context.Context, cmd *cli.Command) error {
+	cfg := mergeConfigFromFlags(cmd)
+	argv := strings.Fields(cmd.String("state-cmd"))
+	if len(argv) == 0 {
+		return fmt.Errorf("import-terraform: --state-cmd is empty")
+	}
+
+	var mapping map[string]string
+	if p := cmd.String("map"); p != "" {
+		var err error
+		if mapping, err = outputs.LoadMapping(p); err != nil {
+			return fmt.Errorf("import-terraform: %w", err)
+		}
+	}
+
+	// Outputs go straight from the child's stdout into memory.
+	var stdout bytes.Buffer
+	c := exec.CommandContext(ctx, argv[0], argv[1:]...)
+	c.Stdout = &stdout
+	c.Stderr = os.Stderr
+	if err := c.Run(); err != nil {
+		return fmt.Errorf("import-terraform: %s: %w", argv[0], err)
+	}
+	outs, err := outputs.Parse(stdout.Bytes())
+	if err != nil {
+		return fmt.Errorf("import-terraform: %w", err)
+	}
+
+	values, missing := outputs.Select(outs, mapping)
+	if len(missing) > 0 {
+		return fmt.Errorf("import-terraform: outputs not found: %s", strings.Join(missing, ", "))
+	}
+	if err := mergeIntoFile(cfg, values); err != nil {
+		return fmt.Errorf("import-terraform: %w", err)
+	}
+	return nil
+}
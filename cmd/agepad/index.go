@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"filippo.io/age"
+	agepkg "github.com/andreweick/agepad/age"
+	"github.com/andreweick/agepad/buildinfo"
+	"github.com/andreweick/agepad/convert"
+	"github.com/andreweick/agepad/filetype"
+	"github.com/andreweick/agepad/index"
+	"github.com/andreweick/agepad/model"
+	"github.com/andreweick/agepad/walk"
+	"github.com/urfave/cli/v3"
+)
+
+func indexCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "index",
+		Usage: "Refresh the encrypted key-name index under a tree, or find the files that contain a key",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  "root",
+				Usage: "Directory tree to index",
+				Value: ".",
+			},
+			&cli.StringFlag{
+				Name:  "find",
+				Usage: "Print the files that contain this key (env name or dotted path)",
+			},
+			&cli.BoolFlag{
+				Name:  "rebuild",
+				Usage: "Discard the cached index and re-index every file",
+			},
+			&cli.StringFlag{
+				Name:  "recipients-file",
+				Usage: "Recipients the index is encrypted to",
+				Value: defaultRecipientsFile,
+			},
+			&cli.StringFlag{
+				Name:  "identities",
+				Usage: "Path to AGE identities",
+				Value: defaultIdentitiesPath(),
+			},
+			&cli.BoolFlag{
+				Name:  "armor",
+				Usage: "Write the index ASCII-armored",
+				Value: buildinfo.ArmorDefault,
+			},
+		},
+		Action: runIndex,
+	}
+}
+
+func runIndex(ctx context.Context, cmd *cli.Command) error {
+	cfg := model.IndexConfig{
+		Root:           cmd.String("root"),
+		Find:           cmd.String("find"),
+		Rebuild:        cmd.Bool("rebuild"),
+		RecipientsFile: cmd.String("recipients-file"),
+		IdentitiesPath: cmd.String("identities"),
+		Armor:          cmd.Bool("armor"),
+	}
+
+	ids, err := agepkg.LoadIdentities(cfg.IdentitiesPath)
+	if err != nil {
+		return err
+	}
+	files, err := walk.AgeFiles(cfg.Root)
+	if err != nil {
+		return err
+	}
+
+	path := index.Path(cfg.Root)
+	ix := index.New()
+	if !cfg.Rebuild {
+		if ix, err = index.Load(path, ids); err != nil {
+			// Usually written for other recipients (after a rotate, say);
+			// the cache is disposable, so start over.
+			fmt.Fprintf(os.Stderr, "index: rebuilding: %v\n", err)
+			ix = index.New()
+		}
+	}
+
+	var rels []string
+	reindexed := 0
+	for _, f := range files {
+		rel, err := filepath.Rel(cfg.Root, f)
+		if err != nil {
+			return err
+		}
+		rels = append(rels, rel)
+		cipher, err := os.ReadFile(f)
+		if err != nil {
+			return err
+		}
+		hash := index.Hash(cipher)
+		if ix.Fresh(rel, hash) {
+			continue
+		}
+		keys, err := indexKeys(f, cipher, ids)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "index: skipping %s: %v\n", f, err)
+			continue
+		}
+		ix.Update(rel, hash, keys)
+		reindexed++
+	}
+	pruned := ix.Prune(rels)
+
+	if reindexed > 0 || pruned > 0 || cfg.Rebuild {
+		recips, err := agepkg.LoadRecipients(cfg.RecipientsFile)
+		if err != nil {
+			return err
+		}
+		if err := ix.Save(path, recips, cfg.Armor); err != nil {
+			return err
+		}
+	}
+	fmt.Fprintf(os.Stderr, "index: %d file(s), %d re-indexed, %d removed\n", len(ix.Files), reindexed, pruned)
+	if cfg.Find == "" {
+		return nil
+	}
+
+	// The filter may report false positives; confirm each candidate.
+	candidates := ix.Candidates(cfg.Find)
+	for _, rel := range candidates {
+		f := filepath.Join(cfg.Root, rel)
+		cipher, err := os.ReadFile(f)
+		if err != nil {
+			return err
+		}
+		keys, err := indexKeys(f, cipher, ids)
+		if err != nil {
+			return err
+		}
+		if slices.Contains(keys, cfg.Find) {
+			fmt.Println(f)
+		}
+	}
+	fmt.Fprintf(os.Stderr, "index: decrypted %d of %d file(s) to search\n", len(candidates), len(files))
+	return nil
+}
+
+// indexKeys decrypts cipher and returns its key names: env names for every
+// format, plus dotted paths for JSON, YAML, and TOML.
+func indexKeys(path string, cipher []byte, ids []age.Identity) ([]string, error) {
+	plain, err := agepkg.Decrypt(cipher, ids)
+	if err != nil {
+		return nil, err
+	}
+	vars, err := envVars(path, plain)
+	if err != nil {
+		return nil, err
+	}
+	var keys []string
+	for k := range vars {
+		keys = append(keys, k)
+	}
+	if f := filetype.FromName(path, nil); f != "" && f != "env" {
+		data, err := convert.Decode(plain, convert.Format(f))
+		if err != nil {
+			return nil, err
+		}
+		for k := range convert.Flatten(data, convert.Options{Separator: "."}) {
+			keys = append(keys, k)
+		}
+	}
+	return keys, nil
+}
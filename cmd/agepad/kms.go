@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+
+	agepkg "github.com/andreweick/agepad/age"
+	"github.com/charmbracelet/x/term"
+	"github.com/urfave/cli/v3"
+)
+
+func kmsWrapCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "kms-wrap",
+		Usage: "Encrypt an age key with a cloud KMS key, for servers where raw keys must not be on disk",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:     "key",
+				Usage:    "KMS key: kms://arn:aws:kms:... or kms://projects/.../cryptoKeys/...",
+				Required: true,
+			},
+			&cli.StringFlag{
+				Name:  "in",
+				Usage: "Identities to wrap (default: stdin, e.g. piped from age-keygen)",
+			},
+			&cli.StringFlag{
+				Name:     "out",
+				Usage:    "Wrapped identities file to write; pass it to --identities",
+				Required: true,
+			},
+			&cli.BoolFlag{
+				Name:  "force",
+				Usage: "Replace --out if it already exists",
+			},
+		},
+		Action: runKMSWrap,
+	}
+}
+
+func runKMSWrap(ctx context.Context, cmd *cli.Command) error {
+	out := cmd.String("out")
+	if _, err := os.Stat(out); err == nil && !cmd.Bool("force") {
+		return fmt.Errorf("kms-wrap: %s exists; pass --force to replace it", out)
+	} else if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("kms-wrap: %w", err)
+	}
+	var (
+		key []byte
+		err error
+	)
+	if in := cmd.String("in"); in != "" {
+		key, err = os.ReadFile(in)
+	} else if term.IsTerminal(os.Stdin.Fd()) {
+		return fmt.Errorf("kms-wrap: pipe the identities on stdin or pass --in")
+	} else {
+		key, err = io.ReadAll(os.Stdin)
+	}
+	if err != nil {
+		return fmt.Errorf("kms-wrap: %w", err)
+	}
+	defer clear(key)
+	wrapped, err := agepkg.WrapKMS(cmd.String("key"), key)
+	if err != nil {
+		return fmt.Errorf("kms-wrap: %w", err)
+	}
+	if err := agepkg.WriteRetrying(out, wrapped, writeRetry); err != nil {
+		return err
+	}
+	fmt.Printf("wrapped identities written to %s; use --identities %s\n", out, out)
+	return nil
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"os"
+
+	agepkg "github.com/andreweick/agepad/age"
+	"github.com/andreweick/agepad/buildinfo"
+	"github.com/andreweick/agepad/lsp"
+	"github.com/urfave/cli/v3"
+)
+
+func lspCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "lsp",
+		Usage: "Serve decrypt-on-open and encrypt-on-save to an IDE over stdio (LSP framing)",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  "identities",
+				Usage: "Path to AGE identities",
+				Value: defaultIdentitiesPath(),
+			},
+			&cli.StringFlag{
+				Name:  "recipients-file",
+				Usage: "Path to recipients file, re-read on every save",
+				Value: defaultRecipientsFile,
+			},
+			&cli.BoolFlag{
+				Name:  "armor",
+				Usage: "Write ASCII-armored .age output",
+				Value: buildinfo.ArmorDefault,
+			},
+		},
+		Action: runLSP,
+	}
+}
+
+// runLSP serves one IDE on stdin/stdout; retries and other notes go to
+// stderr, which IDEs show in the server's log.
+func runLSP(ctx context.Context, cmd *cli.Command) error {
+	ids, err := agepkg.LoadIdentities(cmd.String("identities"))
+	if err != nil {
+		return err
+	}
+	s := &lsp.Server{
+		Identities:     ids,
+		RecipientsFile: cmd.String("recipients-file"),
+		Armor:          cmd.Bool("armor"),
+		Stanzas:        stanzaPolicy(cmd),
+		Version:        buildinfo.Read().Version,
+		Write: func(path string, cipher []byte) error {
+			return agepkg.WriteRetrying(path, cipher, writeRetry)
+		},
+	}
+	if s.Validation, s.TypeRules, err = validationFromFlags(cmd); err != nil {
+		return err
+	}
+	return s.Serve(os.Stdin, os.Stdout)
+}
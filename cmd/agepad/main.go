@@ -2,34 +2,197 @@
 //
 // Highlights:
 // - Plaintext never touches disk; editing is in-process RAM via Bubble Tea textarea.
-// - Default ASCII-armored output (disable with --armor=false).
+// - Default ASCII-armored output (disable with --armor=false; --armor=auto keeps
+//   each file's existing format on save and rotate).
 // - Default identities: ~/.config/age/key.txt (friendly guidance if missing).
+// - age plugins (age-plugin-yubikey, age-plugin-tpm): plugin identities and
+//   recipients; the editor hands the terminal over for their PIN/touch prompts.
 // - Diff-before-save (Ctrl+D to preview; double Ctrl+S to confirm write).
-// - Syntax checks for .env, .json, .yaml/.yml, .toml before encrypting.
+// - Syntax checks for .env, .json, .yaml/.yml, .toml before encrypting; per-format
+//   severity (--validate json=warn) or --no-validate for intentionally odd content.
+//   The format comes from the name before .age, or --type-map '*.secrets=env'.
 // - Read-only view mode (--view) for peek-only sessions.
+// - Passphrase mode (--passphrase): age scrypt encryption instead of recipients;
+//   asked on open, chosen and confirmed in the TUI on a new file's first save.
+// - Scratchpad (--scratch): compose in an empty in-memory buffer with no file; Ctrl+S
+//   encrypts it to a new path, quitting without saving discards and zeroizes it.
+// - Stanza gating (--require-x25519, --forbid-scrypt): refuse to open or save files
+//   whose header carries disallowed stanza types, such as team-bypassing passphrases.
+// - Diffs (Ctrl+D, Ctrl+O, CLI previews) highlight the changed words within modified
+//   lines; --diff-algorithm picks myers, patience, or histogram line matching.
+// - Git HEAD badge: "[≠ HEAD]" while the buffer differs from the committed version
+//   (even before any edit); Ctrl+O shows that diff.
+// - Open at a key (--key DB_PASSWORD, or --path db.password for JSON/YAML/TOML).
+// - Directory mode (--dir): edit every .age file under a directory as one document;
+//   each marked section is saved back to its own file and nearest .age-recipients.
+// - Paranoid mode (--paranoid): the buffer being edited and its last-saved,
+//   snapshot, and HEAD copies stay sealed line by line in RAM under an ephemeral
+//   key. Only a window of about three screens around the cursor is plaintext;
+//   the rest is opened while saving, diffing, validating, or searching.
+// - Hardening (--harden): no core dumps, plaintext excluded from dumps, and a
+//   warning when swap could page plaintext to disk unencrypted.
+// - Copy value under cursor (Ctrl+Y) to the CLIPBOARD selection only, auto-cleared;
+//   OSC52 terminal copy is opt-in (--osc52) since it travels over SSH.
 // - Recipient "health" preflight: encrypt to memory and immediately decrypt with
 //   your identities to catch lock-out risks before writing.
+// - Save queue: a failed write keeps the checked ciphertext in RAM for Ctrl+S retry
+//   or Ctrl+X save-as; subcommands retry busy/locked files with backoff.
 // - Batch rotate subcommand: re-encrypt *.age files under a tree to a new recipients set.
 // - Crash guard: recover with a helpful message; buffer was only in RAM (never on disk).
+// - Scrollback hygiene: the final frame is blank so nothing lingers after the
+//   alt screen closes; --wipe-on-exit also clears the alt screen and title.
 // - Env-injection subcommand: `agepad run -- file.age -- cmd args...` exports KEY=VALs
-//   from the decrypted file into the child process env without creating temp files.
+//   from the decrypted file into the child process env without creating temp files;
+//   .json/.yaml/.toml files (by the extension before .age) are flattened to KEY_NAMEs.
+//   Several files layer in order, later ones overriding earlier keys.
+// - Daemon subcommand: caches decrypted files in locked memory for a TTL and serves
+//   them to `run` over a Unix socket, so hardware/plugin keys are asked once per TTL.
+// - Encrypt subcommand: validate plaintext from stdin or --in and write it encrypted
+//   to the recipients file, without opening the editor.
+// - Convert subcommand: re-encode an encrypted file between env/json/yaml/toml.
+// - Prefix subcommand: add/strip a key prefix with collision checks and a
+//   value-masked preview diff.
+// - Rename-key subcommand: rename one key across every encrypted file in a tree.
+// - Merge-stdin subcommand: merge key/values piped in as json/yaml/env into an
+//   encrypted file (created if missing) without temp files.
+// - Import-terraform subcommand: run `terraform output -json` (or pulumi) and
+//   merge selected outputs into an encrypted file.
+// - CI report subcommand: markdown summary of key names and recipient changes
+//   between two git revisions, for posting as a PR comment.
+// - Share subcommand: re-encrypt to a guest key with an expiry that the editor
+//   and run enforce (shares always open read-only); each share is audit-logged.
+// - Index subcommand: an encrypted cache of key names (Bloom filters, never
+//   values) under .agepad/ so `index --find KEY` only decrypts files that may match.
+// - Workspaces: `agepad ws use prod` applies a named bundle of root, recipients,
+//   identities, armor, and validation settings from workspace.yaml.
+// - Version subcommand: `agepad version --security` reports the linked age
+//   library version and crypto defaults; the age library is self-tested at startup.
+// - The editor is also a component: tui.NewEditorModel embeds it in other Bubble Tea
+//   programs with their own key map, size, validators, and save callback.
+// - Doctor subcommand: checks identities, recipients, plugins, and the save
+//   preflight; `doctor --platform` lists which OS-dependent features (process
+//   replacement, file locks, hardening, daemon peer checks) work on this machine.
+// - External editor: --editor external opens the buffer in $VISUAL/$EDITOR through
+//   a memfd (or $AGEPAD_RAMDISK), then validates, preflights, and saves as the TUI does.
+// - LSP companion: `agepad lsp` gives IDEs decrypt-on-open and encrypt-on-save over
+//   stdio, with the editor's validation, recipient preflight, and stanza policy.
+// - Control socket: with --control, `agepad ctl status|save|insert|diff` (or any
+//   JSON-RPC client) drives the open editor from scripts and editor tasks.
+// - KMS-wrapped identities: an identities file can be an age key encrypted by AWS
+//   KMS or GCP Cloud KMS (`agepad kms-wrap`), unwrapped at runtime via aws/gcloud.
+// - Redacted catalog (agepad redact-tree): mirror a secrets tree as unencrypted
+//   files with key names and comments only, every value replaced, for docs and CI.
+// - Required reviewers: .agepad-reviewers maps path globs to recipient labels whose
+//   SSH-signed acks (agepad ack) of each file version verify --strict requires.
+// - Syntax highlighting: the editor colors .env, JSON, YAML, and TOML line by line
+//   (package highlight); --highlight=false or NO_COLOR turns it off.
+// - Job notifications: rotate and verify runs longer than --notify-after ring the
+//   bell, and with --notify desktop post a desktop notification (or OSC 777).
+// - Write preflight: rotate and rename-key check free space and atomic rename in
+//   every target directory and stop before the first write when either fails.
+// - Repeated secrets (agepad dupes): files and values identical across a tree,
+//   reported by key and a run-scoped fingerprint, never by value.
+// - Config file: ~/.config/agepad/config.toml and a repository's .agepad.toml set
+//   default flags, the highlight theme, and editor key bindings.
+// - Leaked value revocation (agepad revoke-value): replaces one value in every
+//   file holding it, all or nothing, and lists the files and keys touched.
+// - Tabs: repeat --file to edit several files in one session, each in its own
+//   tab with its own dirty marker and save; Alt+N/Alt+P switch tabs.
+// - View windows: "# agepad:view-window=09:00-18:00 mon-fri" (or --view-window)
+//   makes opening a file outside those hours ask again, and audits the session.
+// - Recipients panel (Alt+R): lists the recipients a save uses and adds or
+//   removes lines in the recipients file mid-session.
+// - Validate (agepad validate): the editor's format and stanza checks on
+//   plaintext or .age files, exiting non-zero on failure, for CI.
+// - Auto-indent: in JSON, YAML, and TOML buffers Enter keeps the indentation and
+//   opens a level after "key:" or a bracket; Tab inserts spaces.
+// - Selection: Shift+arrows or Alt+V select; Alt+W/Alt+X/Alt+Y copy, cut, and
+//   paste through an in-process register, never the system clipboard.
+// - Named snapshots (Alt+S): in-memory copies of the buffer for this session,
+//   to diff the buffer against or restore.
+// - Usage statistics (agepad stats): opt-in, local-only counts of saves,
+//   rotations, and verifies per repository; never file names or values.
+// - --skip-invalid-recipients: recipients file lines agepad can't use are
+//   named and left out of saves, with a confirmation on every save.
+// - run --supervise/--report: run the command as a child, exit exactly as it did
+//   (code or signal), and optionally print its wall time, CPU, and peak memory.
+// - Encrypted repo settings: .agepad.toml.age, decrypted with the user's identities
+//   at startup and laid over the plain config files.
+// - Enrollment: `agepad enroll` creates a machine's key, prints its roster line,
+//   and on a second run checks that it is listed and decrypts.
+// - Web view: `agepad web` serves one decrypted Markdown/HTML file read-only on
+//   loopback through a one-time link, from memory, until it expires.
+// - run --only/--exclude/--minimal-env: export just the keys a command needs, and
+//   optionally little of agepad's own environment.
+// - run --prefix/--rename: namespace or rename decrypted keys before export.
+// - Sed subcommand: `agepad sed` replaces text across a tree's .age files, with a
+//   redacted per-file preview and an all-or-nothing write.
+// - Directory recipients: encrypt --recipient-user/--recipient-group and
+//   `agepad resolve` look keys up in a TOML file, HTTPS roster, LDAP, or SCIM.
+// - run --watch: restart the command with fresh values when a file changes.
+// - Derived files: [[derived]] in .agepad.toml keeps a SHA256SUMS, key-name
+//   manifest, or redacted catalog in step after each save; `agepad derive`
+//   rebuilds them (--check for CI).
+// - Render subcommand: `agepad render` fills a text/template config file with
+//   decrypted values, to stdout or --out-fd.
+// - rotate --replace-from/--commit: install the --to roster over --from after
+//   a timestamped backup, and git-commit it with the rotated files.
+// - Git diffs: `agepad git-setup` registers `agepad git-textconv` as a diff
+//   driver for .age files.
+// - Git filter: git-clean/git-smudge keep --filter files plaintext in the working
+//   tree and encrypted in the repository.
+// - Redacted view (--view --redact): keys, comments, and structure with every
+//   value replaced, for screen-sharing or checking which keys exist.
+// - Secret scan: `agepad scan --staged` is a pre-commit check for plaintext
+//   secrets and .age files that are not ciphertext.
+// - Value encryption: `encrypt --values` keeps a JSON/YAML file's keys and
+//   comments readable and encrypts each value, so diffs show which keys
+//   changed; the editor opens such files and saves only edited values anew.
+// - Secret lint (--lint, or lint = true in config): before a save, list pasted
+//   AWS or private keys, weak and placeholder passwords, and repeated values,
+//   with Enter jumping to each; Alt+L lints on demand.
+// - Schema validation: JSON/YAML saves (editor, encrypt, validate) are checked
+//   against --schema or a .agepad-schema.json next to the file, for required
+//   keys and value types.
 
 package main
 
 import (
-	"bufio"
 	"context"
+	"errors"
 	"fmt"
-	"io/fs"
+	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
-	"strings"
-	"syscall"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
 
+	"filippo.io/age"
 	agepkg "github.com/andreweick/agepad/age"
+	"github.com/andreweick/agepad/buildinfo"
+	"github.com/andreweick/agepad/bundle"
+	"github.com/andreweick/agepad/config"
+	"github.com/andreweick/agepad/control"
+	"github.com/andreweick/agepad/convert"
+	"github.com/andreweick/agepad/diff"
+	"github.com/andreweick/agepad/dotenv"
+	"github.com/andreweick/agepad/filetype"
+	"github.com/andreweick/agepad/gitutil"
+	"github.com/andreweick/agepad/harden"
+	"github.com/andreweick/agepad/highlight"
 	"github.com/andreweick/agepad/model"
+	"github.com/andreweick/agepad/platform"
+	"github.com/andreweick/agepad/redact"
+	"github.com/andreweick/agepad/rotation"
+	"github.com/andreweick/agepad/stats"
 	"github.com/andreweick/agepad/tui"
+	"github.com/andreweick/agepad/validator"
+	"github.com/andreweick/agepad/valuecrypt"
+	"github.com/andreweick/agepad/viewwindow"
+	"github.com/andreweick/agepad/walk"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/urfave/cli/v3"
 )
@@ -40,9 +203,13 @@ const (
 	defaultRecipientsFile = ".age-recipients"
 )
 
+// defaultIdentitiesPath is the key in $AGEPAD_IDENTITY when that is set,
+// otherwise age's usual key file.
 func defaultIdentitiesPath() string {
-	home, _ := os.UserHomeDir()
-	return filepath.Join(home, ".config", "age", "key.txt")
+	if os.Getenv(agepkg.IdentityEnv) != "" {
+		return agepkg.EnvIdentities
+	}
+	return filepath.Join(platform.ConfigDir(), "age", "key.txt")
 }
 
 func main() {
@@ -50,31 +217,150 @@ func main() {
 		Name:  appName,
 		Usage: "Securely edit AGE-encrypted files entirely in memory",
 		Flags: []cli.Flag{
+			&cli.StringSliceFlag{
+				Name:  "file",
+				Usage: "Path to the .age file to edit (required); repeat to open several files in tabs",
+			},
 			&cli.StringFlag{
-				Name:     "file",
-				Usage:    "Path to the .age file to edit",
-				Required: true,
+				Name:  "dir",
+				Usage: "Edit every .age file under DIR as one document; each file keeps the recipients of its nearest .age-recipients",
 			},
 			&cli.StringFlag{
 				Name:  "recipients-file",
 				Usage: "Path to recipients file",
 				Value: defaultRecipientsFile,
 			},
+			&cli.BoolFlag{
+				Name:  "skip-invalid-recipients",
+				Usage: "Open and save even if some recipients file lines cannot be used (malformed, or a plugin not on $PATH); saves then leave those recipients out, with a warning",
+			},
 			&cli.StringFlag{
 				Name:  "identities",
-				Usage: "Path to AGE identities",
+				Usage: "Path to AGE identities, or - to read them from stdin ($AGEPAD_IDENTITY may hold the key itself)",
 				Value: defaultIdentitiesPath(),
 			},
+			armorFlag(),
 			&cli.BoolFlag{
-				Name:  "armor",
-				Usage: "Write ASCII-armored .age output",
-				Value: true,
+				Name:  "scratch",
+				Usage: "Open an empty scratchpad with no backing file; Ctrl+S encrypts it to a new file, quitting discards and zeroizes it",
+			},
+			&cli.BoolFlag{
+				Name:  "passphrase",
+				Usage: "Encrypt with a passphrase (age scrypt) instead of the recipients file; asked on open, chosen and confirmed on a new file's first save",
 			},
 			&cli.BoolFlag{
 				Name:  "view",
 				Usage: "Open in read-only view mode (no edits)",
 				Value: false,
 			},
+			&cli.BoolFlag{
+				Name:  "redact",
+				Usage: "With --view, show only keys, comments, and structure, every value replaced by " + redact.Placeholder + " (for screen-sharing; env, JSON, YAML, TOML)",
+			},
+			&cli.BoolFlag{
+				Name:  "harden",
+				Usage: "Disable core dumps, exclude plaintext from dumps, and warn about unencrypted swap",
+				Value: false,
+			},
+			&cli.BoolFlag{
+				Name:  "osc52",
+				Usage: "Copy via the terminal (OSC 52) instead of the system clipboard; reaches remote clipboards over SSH",
+				Value: false,
+			},
+			&cli.DurationFlag{
+				Name:  "clipboard-clear",
+				Usage: "Clear copied values from the clipboard after this long (0 disables)",
+				Value: 30 * time.Second,
+			},
+			&cli.BoolFlag{
+				Name:  "wipe-on-exit",
+				Usage: "Clear the alternate screen and reset the terminal title on quit and crash",
+				Value: false,
+			},
+			&cli.DurationFlag{
+				Name:  "reauth-after",
+				Usage: "Re-load identities (fresh touch/PIN for plugin keys) before saving after this much inactivity; 0 disables",
+				Value: 0,
+			},
+			&cli.BoolFlag{
+				Name:  "summary",
+				Usage: "Print a plaintext-free session summary to stderr on exit",
+				Value: false,
+			},
+			&cli.BoolFlag{
+				Name:  "paranoid",
+				Usage: "Keep the buffer and its saved, snapshot, and HEAD copies sealed in RAM under an ephemeral key, with only a window around the cursor in plaintext",
+				Value: false,
+			},
+			&cli.StringFlag{
+				Name:  "diff-algorithm",
+				Usage: "Line matching for diffs: myers, patience (anchors on unique lines), or histogram",
+				Value: string(diff.Myers),
+			},
+			&cli.StringFlag{
+				Name:  "editor",
+				Usage: "tui, or external to edit in $VISUAL/$EDITOR through a memory-backed file",
+				Value: "tui",
+			},
+			&cli.BoolFlag{
+				Name:  "highlight",
+				Usage: "Color the buffer by format (.env, JSON, YAML, TOML); NO_COLOR also turns it off",
+				Value: true,
+			},
+			&cli.StringFlag{
+				Name:  "theme",
+				Usage: "Highlight theme: default, or mono (bold, italic, and faint only)",
+				Value: "default",
+			},
+			&cli.StringFlag{
+				Name:  "view-window",
+				Usage: "Hours files may be opened in, e.g. '09:00-18:00 mon-fri'; outside them (or a file's own # agepad:view-window=) opening asks again and is audited",
+			},
+			&cli.BoolFlag{
+				Name:  "control",
+				Usage: "Let scripts drive this session over a local socket (see `agepad ctl`)",
+			},
+			&cli.StringFlag{
+				Name:  "control-socket",
+				Usage: "Unix socket for --control ($AGEPAD_CONTROL)",
+				Value: control.SocketPath(),
+			},
+			&cli.BoolFlag{
+				Name:  "no-validate",
+				Usage: "Skip format validation before saving (same as --validate all=off)",
+			},
+			&cli.StringSliceFlag{
+				Name:  "validate",
+				Usage: "Per-format validation severity, e.g. json=warn,yaml=off (formats: env, json, yaml, toml, all; severities: error, warn, off)",
+			},
+			&cli.BoolFlag{
+				Name:  "lint",
+				Usage: "Before each save, list AWS keys, private keys, weak or placeholder passwords, and repeated values (Alt+L any time); Ctrl+S again saves anyway",
+			},
+			&cli.StringFlag{
+				Name:  "workspace",
+				Usage: "Apply this workspace from workspace.yaml (default: the one chosen with `ws use`)",
+			},
+			&cli.StringFlag{
+				Name:  "key",
+				Usage: "Open with the cursor on this .env key (e.g. DB_PASSWORD)",
+			},
+			&cli.StringFlag{
+				Name:  "path",
+				Usage: "Open with the cursor on this dotted JSON/YAML/TOML path (e.g. db.password)",
+			},
+			&cli.StringSliceFlag{
+				Name:  "type-map",
+				Usage: "Map filename patterns to formats for validation, e.g. '*.secrets=env,*.cfg=toml'",
+			},
+			&cli.StringFlag{
+				Name:  "schema",
+				Usage: "Check JSON/YAML content against this JSON Schema before saving (default: " + validator.SchemaFile + " next to the file, if any)",
+			},
+			requireX25519Flag,
+			forbidScryptFlag,
+			notifyFlag,
+			notifyAfterFlag,
 		},
 		Action: runEditor,
 		Commands: []*cli.Command{
@@ -89,132 +375,832 @@ func main() {
 					},
 					&cli.StringFlag{
 						Name:  "from",
-						Usage: "Current recipients file (for logging/documentation)",
+						Usage: "Current recipients file (for logging/documentation; the base for --add-recipient/--remove-recipient)",
 						Value: defaultRecipientsFile,
 					},
 					&cli.StringFlag{
-						Name:     "to",
-						Usage:    "NEW recipients file to use",
-						Required: true,
+						Name:  "to",
+						Usage: "NEW recipients file to use (required unless --apply or --add-recipient/--remove-recipient)",
+					},
+					&cli.StringSliceFlag{
+						Name:  "add-recipient",
+						Usage: "Rotate to --from plus this recipient (repeatable; \"age1... # name\" keeps a label), and add it to --from afterwards",
+					},
+					&cli.StringSliceFlag{
+						Name:  "remove-recipient",
+						Usage: "Rotate to --from without this recipient (repeatable), and remove it from --from afterwards",
 					},
 					&cli.StringFlag{
 						Name:  "identities",
 						Usage: "AGE identities used to decrypt during rotation",
 						Value: defaultIdentitiesPath(),
 					},
+					&cli.BoolFlag{
+						Name:  "prune",
+						Usage: "Offboarding: list recipients in --from missing from --to, require typing \"prune\" (or --yes), and verify each rewritten header",
+					},
+					&cli.StringFlag{
+						Name:  "plan",
+						Usage: "Write the intended changes as JSON to this file instead of rotating",
+					},
+					&cli.StringFlag{
+						Name:  "apply",
+						Usage: "Execute a plan written by --plan exactly as reviewed",
+					},
+					&cli.IntFlag{
+						Name:  "workers",
+						Usage: "Rotate this many files at once (keep 1 for identities that prompt)",
+						Value: 1,
+					},
+					&cli.StringFlag{
+						Name:  "io-limit",
+						Usage: "Cap disk IO across workers, in bytes per second (e.g. 20M, 512K)",
+					},
+					&cli.IntFlag{
+						Name:  "nice",
+						Usage: "Lower CPU priority by this niceness (1-19) for rotations on busy hosts",
+					},
+					&cli.BoolFlag{
+						Name:  "resume",
+						Usage: "Continue an interrupted rotation, skipping files its checkpoint lists (verified by hash and header)",
+					},
+					&cli.StringFlag{
+						Name:  "checkpoint",
+						Usage: "Progress file for --resume (default <root>/.agepad/rotate.checkpoint)",
+					},
+					&cli.BoolFlag{
+						Name:  "verify",
+						Usage: "After rotating, re-read every file and check its hash, header, and (when --identities can still open it) content",
+					},
+					&cli.BoolFlag{
+						Name:  "backup",
+						Usage: "Keep each original ciphertext as <name>.age.bak before replacing it; undo with --restore",
+					},
+					&cli.StringFlag{
+						Name:  "backup-dir",
+						Usage: "Keep the backups under this directory, by path under --root, instead of beside each file (implies --backup)",
+					},
+					&cli.BoolFlag{
+						Name:  "restore",
+						Usage: "Put back the originals kept by --backup (from --backup-dir if given), replacing the rotated files",
+					},
+					&cli.StringFlag{
+						Name:  "report",
+						Usage: "Also report each file's result (path, status, error, recipient counts, duration) as json, on stdout unless --report-file",
+					},
+					&cli.StringFlag{
+						Name:  "report-file",
+						Usage: "Write the --report output to this file; text output then stays on stdout",
+					},
+					&cli.BoolFlag{
+						Name:  "replace-from",
+						Usage: "Once every file is rotated, copy --to over --from (e.g. .age-recipients), keeping the old one as <from>.<UTC time>.bak",
+					},
+					&cli.BoolFlag{
+						Name:  "commit",
+						Usage: "Once every file is rotated, git-commit the rotated files and the updated recipients file together",
+					},
+					yesFlag,
+					confirmPromptFlag,
 				},
 				Action: runRotate,
 			},
 			{
 				Name:      "run",
-				Usage:     "Export KEY=VALs from decrypted file into child process env",
-				ArgsUsage: "-- <file.age> -- <command> [args...]",
-				Action:    runEnvExec,
+				Usage:     "Export KEY=VALs from decrypted files into child process env (later files override earlier keys)",
+				ArgsUsage: "-- <file.age> [override.age...] -- <command> [args...]",
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "no-daemon",
+						Usage: "Decrypt in-process even when `agepad daemon` is running",
+					},
+					&cli.BoolFlag{
+						Name:    "supervise",
+						Aliases: []string{"no-exec"},
+						Usage:   "Run the command as a child, passing on signals, and exit exactly as it did (code or signal) instead of replacing agepad; the default where a process cannot be replaced (Windows)",
+					},
+					&cli.BoolFlag{
+						Name:  "report",
+						Usage: "After the command exits, print its status, wall time, CPU time, and peak memory to stderr (implies --supervise)",
+					},
+					&cli.StringSliceFlag{
+						Name:  "only",
+						Usage: "Export only these decrypted keys (names or globs such as AWS_*, comma-separated or repeated)",
+					},
+					&cli.StringSliceFlag{
+						Name:  "exclude",
+						Usage: "Leave out decrypted keys matching these names or globs (comma-separated or repeated)",
+					},
+					&cli.StringFlag{
+						Name:  "prefix",
+						Usage: "Export the selected keys with this prefix (e.g. APP_), except those that already have it or are renamed",
+					},
+					&cli.StringSliceFlag{
+						Name:  "rename",
+						Usage: "Export a decrypted key under another name, OLD=NEW (comma-separated or repeated); applied after --only/--exclude",
+					},
+					&cli.BoolFlag{
+						Name:  "minimal-env",
+						Usage: "Pass the command only PATH, HOME, USER, SHELL, TERM, the locale, and temp-dir variables from agepad's environment, plus the selected keys",
+					},
+					&cli.BoolFlag{
+						Name:  "watch",
+						Usage: "Keep running: when an encrypted file changes, decrypt it again and restart the command with the new values (implies --supervise)",
+					},
+					&cli.DurationFlag{
+						Name:  "watch-interval",
+						Usage: "How often --watch checks the files for changes",
+						Value: time.Second,
+					},
+				},
+				Action: runEnvExec,
 			},
+			encryptCommand(),
+			convertCommand(),
+			prefixCommand(),
+			renameKeyCommand(),
+			mergeStdinCommand(),
+			importTerraformCommand(),
+			ciReportCommand(),
+			versionCommand(),
+			doctorCommand(),
+			shareCommand(),
+			wsCommand(),
+			indexCommand(),
+			daemonCommand(),
+			ctlCommand(),
+			lspCommand(),
+			kmsWrapCommand(),
+			enrollCommand(),
+			webCommand(),
+			redactTreeCommand(),
+			ackCommand(),
+			verifyCommand(),
+			statsCommand(),
+			dupesCommand(),
+			revokeValueCommand(),
+			sedCommand(),
+			resolveCommand(),
+			deriveCommand(),
+			renderCommand(),
+			gitTextconvCommand(),
+			gitCleanCommand(),
+			gitSmudgeCommand(),
+			gitSetupCommand(),
+			scanCommand(),
+			validateCommand(),
 		},
 	}
 
+	withDefaults(cmd)
+
 	// Crash guard: keep messaging kind, remind that plaintext never hit disk.
 	defer func() {
 		if r := recover(); r != nil {
-			fmt.Fprintln(os.Stderr, "\n[CRASH-GUARD] The editor hit a fatal error.")
-			fmt.Fprintln(os.Stderr, "Your edits were only in RAM; reopen the file and reapply recent changes.")
-			os.Exit(3)
+			crashGuard()
 		}
 	}()
 
+	if err := buildinfo.CheckAge(); err != nil {
+		fmt.Fprintln(os.Stderr, "error: age library check failed:", err)
+		os.Exit(1)
+	}
+
 	if err := cmd.Run(context.Background(), os.Args); err != nil {
 		fmt.Fprintln(os.Stderr, "error:", err)
 		os.Exit(1)
 	}
 }
 
+// wipeOnExit is set by runEditor so the crash guard can honour --wipe-on-exit.
+var wipeOnExit bool
+
+func crashGuard() {
+	if wipeOnExit {
+		tui.WipeTerminal(os.Stdout)
+	}
+	fmt.Fprintln(os.Stderr, "\n[CRASH-GUARD] The editor hit a fatal error.")
+	fmt.Fprintln(os.Stderr, "Your edits were only in RAM; reopen the file and reapply recent changes.")
+	os.Exit(3)
+}
+
 func runEditor(ctx context.Context, cmd *cli.Command) error {
 	cfg := model.Config{
-		FilePath:       cmd.String("file"),
 		RecipientsFile: cmd.String("recipients-file"),
 		IdentitiesPath: cmd.String("identities"),
-		Armor:          cmd.Bool("armor"),
 		ViewOnly:       cmd.Bool("view"),
-	}
+		Redact:         cmd.Bool("redact"),
+		Paranoid:       cmd.Bool("paranoid"),
+		Harden:         cmd.Bool("harden"),
+		OSC52:          cmd.Bool("osc52"),
+		ClipboardClear: cmd.Duration("clipboard-clear"),
+		WipeOnExit:     cmd.Bool("wipe-on-exit"),
+		ReauthAfter:    cmd.Duration("reauth-after"),
+		Summary:        cmd.Bool("summary"),
+		Stanzas:        stanzaPolicy(cmd),
+		Highlight:      cmd.Bool("highlight") && os.Getenv("NO_COLOR") == "",
+		Theme:          cmd.String("theme"),
+		ViewWindow:     cmd.String("view-window"),
 
-	// Friendly guidance if key missing
-	if _, err := os.Stat(cfg.IdentitiesPath); err != nil {
-		return fmt.Errorf("\nAGE private key not found at %s\n"+
-			"- Generate one: age-keygen --output %s\n"+
-			"- Or pass a different path: --identities /path/to/key.txt\n", cfg.IdentitiesPath, cfg.IdentitiesPath)
+		SkipInvalidRecipients: cmd.Bool("skip-invalid-recipients"),
+		Lint:                  cmd.Bool("lint"),
+		Schema:                cmd.String("schema"),
 	}
-
-	ids, err := agepkg.LoadIdentities(cfg.IdentitiesPath)
-	if err != nil {
+	cfg.Armor, cfg.ArmorAuto = armorFromFlags(cmd)
+	wipeOnExit = cfg.WipeOnExit
+	files := cmd.StringSlice("file")
+	if len(files) > 0 {
+		cfg.FilePath = files[0]
+	}
+	dir := cmd.String("dir")
+	if cfg.FilePath != "" && dir != "" {
+		return fmt.Errorf("pass either --file or --dir, not both")
+	}
+	if dir != "" {
+		cfg.FilePath = dir
+	}
+	cfg.Scratch = cmd.Bool("scratch")
+	if cfg.Scratch && cfg.FilePath != "" {
+		return fmt.Errorf("--scratch has no backing file; drop --file/--dir")
+	}
+	if cfg.FilePath == "" && !cfg.Scratch {
+		return fmt.Errorf("missing --file; pass the .age file to edit")
+	}
+	var err error
+	if cfg.Validation, cfg.TypeRules, err = validationFromFlags(cmd); err != nil {
 		return err
 	}
-	recips, err := agepkg.LoadRecipients(cfg.RecipientsFile)
+	if cfg.Schema != "" {
+		// Refuse a broken schema now rather than at the first save.
+		if _, err := validator.LoadSchema(cfg.Schema); err != nil {
+			return err
+		}
+	}
+	if cmd.IsSet("key") && cmd.IsSet("path") {
+		return fmt.Errorf("pass either --key or --path, not both")
+	}
+	cfg.OpenAt = cmd.String("key") + cmd.String("path")
+	if _, ok := highlight.Themes[cfg.Theme]; !ok {
+		return fmt.Errorf("unknown --theme %q (want default or mono)", cfg.Theme)
+	}
+	if cfg.ViewWindow != "" {
+		if _, err := viewwindow.Parse(cfg.ViewWindow); err != nil {
+			return fmt.Errorf("--view-window: %w", err)
+		}
+	}
+	conf, err := config.LoadAll()
 	if err != nil {
 		return err
 	}
-	plain, err := agepkg.DecryptToMemory(cfg.FilePath, ids)
+	if conf, err = withSealed(conf, cfg.IdentitiesPath); err != nil {
+		return err
+	}
+	keys := tui.DefaultKeyMap()
+	if err := keys.Rebind(conf.Keys); err != nil {
+		return fmt.Errorf("config: [keys]: %w", err)
+	}
+	cfg.KeyBindings = conf.Keys
+	switch cfg.Editor = cmd.String("editor"); {
+	case cfg.Editor == "tui":
+	case cfg.Editor != "external":
+		return fmt.Errorf("unknown --editor %q (want tui or external)", cfg.Editor)
+	case dir != "" || cfg.Scratch || cmd.Bool("passphrase") || cmd.Bool("control"):
+		return fmt.Errorf("--editor external edits one recipients-encrypted file; drop --dir, --scratch, --passphrase, and --control")
+	}
+	if cmd.Bool("control") {
+		cfg.ControlSocket = cmd.String("control-socket")
+	}
+	if len(files) > 1 && (dir != "" || cfg.Editor != "tui" || cmd.Bool("passphrase") || cmd.Bool("control") || cfg.OpenAt != "") {
+		return fmt.Errorf("several --file flags open tabs in the editor; drop --dir, --editor external, --passphrase, --control, --key, and --path")
+	}
+	alg, err := diff.ParseAlgorithm(cmd.String("diff-algorithm"))
 	if err != nil {
 		return err
 	}
+	cfg.DiffAlgorithm = string(alg)
+
+	if cfg.Passphrase = cmd.Bool("passphrase"); cfg.Passphrase {
+		switch {
+		case dir != "":
+			return fmt.Errorf("--passphrase edits a single file; drop --dir")
+		case cfg.Stanzas != (model.StanzaPolicy{}):
+			return fmt.Errorf("--passphrase writes a scrypt stanza, which --forbid-scrypt/--require-x25519 refuse")
+		case cfg.ReauthAfter > 0:
+			return fmt.Errorf("--reauth-after re-reads the identities file, which --passphrase does not use")
+		}
+		// The passphrase is the only recipient; there is no roster to watch.
+		cfg.RecipientsFile = ""
+	}
+	switch {
+	case cfg.Redact && !cfg.ViewOnly:
+		return fmt.Errorf("--redact shows a read-only copy; pass --view too")
+	case cfg.Redact && (dir != "" || cfg.Scratch || cfg.Editor != "tui"):
+		return fmt.Errorf("--redact views whole files in the TUI; drop --dir, --scratch, and --editor external")
+	}
+	if cfg.SkipInvalidRecipients && (dir != "" || cfg.Passphrase) {
+		return fmt.Errorf("--skip-invalid-recipients applies to --recipients-file; drop --dir and --passphrase")
+	}
+
+	var hardening harden.Report
+	if cfg.Harden {
+		hardening = harden.Apply()
+	}
 
-	m := tui.NewModel(cfg, plain, ids, recips)
-	if err := tea.NewProgram(m, tea.WithAltScreen()).Start(); err != nil {
+	var ids []age.Identity
+	if !cfg.Passphrase {
+		// Friendly guidance if key missing
+		if _, err := os.Stat(cfg.IdentitiesPath); err != nil && !agepkg.IsInline(cfg.IdentitiesPath) {
+			return fmt.Errorf("\nAGE private key not found at %s\n"+
+				"- Generate one: age-keygen --output %s\n"+
+				"- Or pass a different path: --identities /path/to/key.txt\n", cfg.IdentitiesPath, cfg.IdentitiesPath)
+		}
+		if ids, err = agepkg.LoadIdentities(cfg.IdentitiesPath); err != nil {
+			return err
+		}
+	}
+	afterSave := afterSaveHook(conf.Derived, cfg.TypeRules)
+	if len(files) > 1 {
+		return runTabs(cfg, files, ids, hardening, afterSave)
+	}
+	var (
+		plain  string
+		m      tui.Model
+		recips []age.Recipient
+	)
+	switch {
+	case cfg.Scratch:
+		// Recipients are only needed to save; the scratchpad reports a
+		// missing recipients file when asked to save.
+		var recips []age.Recipient
+		if !cfg.Passphrase {
+			recips, _ = loadEditorRecipients(cfg)
+		}
+		m = tui.NewScratchModel(cfg, ids, recips)
+	case dir != "":
+		parts, recips, err := loadBundle(cfg, ids)
+		if err != nil {
+			return err
+		}
+		m = tui.NewBundleModel(cfg, parts, ids, recips)
+		plain = bundle.Join(parts)
+	case cfg.Passphrase:
+		if plain, m, err = openWithPassphrase(cfg); err != nil {
+			return err
+		}
+	case valueEncrypted(cfg.FilePath, cfg.TypeRules):
+		if cfg.Editor == "external" {
+			return fmt.Errorf("%s has only its values encrypted, which --editor external cannot save; use the TUI editor", cfg.FilePath)
+		}
+		var key *valuecrypt.Key
+		if plain, key, err = openValues(cfg, ids); err != nil {
+			return err
+		}
+		cfg.Values = true
+		recips, err = loadEditorRecipients(cfg)
+		if err != nil && !cfg.ViewOnly {
+			return err
+		}
+		m = tui.NewModel(cfg, plain, ids, recips).WithValueKey(key)
+		if head, ok := headVersion(cfg.FilePath, ids); ok {
+			m = m.WithHead(head)
+		}
+	default:
+		if err := checkStanzas(cfg.FilePath, cfg.Stanzas); err != nil {
+			return err
+		}
+		plain, err = agepkg.DecryptToMemory(cfg.FilePath, ids)
+		if err != nil {
+			return passphraseHint(cfg.FilePath, err)
+		}
+		// Shares are read-only and refuse to open once expired.
+		body, shared, err := openShared(plain)
+		if err != nil {
+			return err
+		}
+		if shared {
+			plain, cfg.ViewOnly = body, true
+		}
+		// Recipients are only needed to save; view-only sessions (including
+		// guests opening a share) work without a recipients file.
+		recips, err = loadEditorRecipients(cfg)
+		if err != nil && !cfg.ViewOnly {
+			return err
+		}
+		m = tui.NewModel(cfg, plain, ids, recips)
+		if head, ok := headVersion(cfg.FilePath, ids); ok && !shared {
+			m = m.WithHead(head)
+		}
+	}
+	if !cfg.Scratch {
+		if err := checkViewWindow(cfg, cfg.FilePath, plain); err != nil {
+			return err
+		}
+	}
+	if cfg.Redact {
+		// Rebuilt from the redacted copy, so no value (nor the HEAD
+		// version) reaches the editor.
+		if plain, err = redactedView(cfg, cfg.FilePath, plain); err != nil {
+			return err
+		}
+		m = tui.NewModel(cfg, plain, ids, nil)
+	}
+	if cfg.Harden {
+		hardening.ExcludeFromDumps("decrypted buffer", plain)
+		fmt.Fprint(os.Stderr, "Hardening report:\n"+hardening.String())
+	}
+	if cfg.Editor == "external" {
+		return editExternal(cfg, plain, ids, recips, afterSave)
+	}
+	if afterSave != nil {
+		m = m.WithAfterSave(afterSave)
+	}
+
+	p := tea.NewProgram(m, tea.WithAltScreen())
+	agepkg.SetPluginUI(tui.PluginUI(p))
+	if cfg.ControlSocket != "" {
+		ln, err := control.Listen(cfg.ControlSocket)
+		if err != nil {
+			return err
+		}
+		defer os.Remove(cfg.ControlSocket)
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		go control.Serve(ctx, ln, tui.ControlHandler(p))
+	}
+	final, err := p.Run()
+	if errors.Is(err, tea.ErrProgramPanic) {
+		crashGuard()
+	}
+	if cfg.WipeOnExit {
+		tui.WipeTerminal(os.Stdout)
+	}
+	if fm, ok := final.(tui.Model); ok {
+		s := fm.Summary()
+		if cfg.Summary {
+			fmt.Fprint(os.Stderr, s.String())
+		}
+		recordStats(stats.Save, filepath.Dir(s.File), s.Saves)
+	}
+	if err != nil {
 		return fmt.Errorf("tui error: %w", err)
 	}
 	return nil
 }
 
+// loadEditorRecipients loads the session's recipients file. With
+// --skip-invalid-recipients, lines that cannot be used are left out and
+// named on stderr; the editor repeats the warning and the save
+// confirmation lists them.
+func loadEditorRecipients(cfg model.Config) ([]age.Recipient, error) {
+	if !cfg.SkipInvalidRecipients {
+		return agepkg.LoadRecipients(cfg.RecipientsFile)
+	}
+	recips, skipped, err := agepkg.LoadRecipientsSkipping(cfg.RecipientsFile)
+	for _, s := range skipped {
+		fmt.Fprintf(os.Stderr, "WARNING: %s: skipping %v; saves will NOT be encrypted to it\n", cfg.RecipientsFile, s)
+	}
+	return recips, err
+}
+
+// redactedView returns plain with every value replaced, for --view
+// --redact. Content whose format cannot be told is refused rather than
+// shown.
+func redactedView(cfg model.Config, path, plain string) (string, error) {
+	format := validator.Format(path, plain, cfg.TypeRules)
+	if format == "" {
+		return "", fmt.Errorf("--redact: cannot tell the format of %s; map it with --type-map", path)
+	}
+	out, err := redact.Redact(plain, format)
+	if err != nil {
+		return "", fmt.Errorf("--redact: %s: %w", path, err)
+	}
+	return out, nil
+}
+
+// validationFromFlags reads --validate, --no-validate, and --type-map.
+func validationFromFlags(cmd *cli.Command) (map[string]model.Severity, []model.TypeRule, error) {
+	severities := cmd.StringSlice("validate")
+	if cmd.Bool("no-validate") {
+		severities = append(severities, "all=off")
+	}
+	validation, err := validator.ParseSeverities(severities)
+	if err != nil {
+		return nil, nil, err
+	}
+	rules, err := filetype.ParseRules(cmd.StringSlice("type-map"))
+	if err != nil {
+		return nil, nil, err
+	}
+	return validation, rules, nil
+}
+
 func runRotate(ctx context.Context, cmd *cli.Command) error {
 	cfg := model.RotateConfig{
 		Root:               cmd.String("root"),
 		FromRecipientsFile: cmd.String("from"),
 		ToRecipientsFile:   cmd.String("to"),
+		AddRecipients:      cmd.StringSlice("add-recipient"),
+		RemoveRecipients:   cmd.StringSlice("remove-recipient"),
 		IdentitiesPath:     cmd.String("identities"),
+		Prune:              cmd.Bool("prune"),
+		PlanPath:           cmd.String("plan"),
+		ApplyPath:          cmd.String("apply"),
+		Workers:            int(cmd.Int("workers")),
+		Nice:               int(cmd.Int("nice")),
+		Resume:             cmd.Bool("resume"),
+		CheckpointPath:     cmd.String("checkpoint"),
+		Report:             cmd.String("report"),
+		ReportPath:         cmd.String("report-file"),
+		Backup:             cmd.Bool("backup") || cmd.String("backup-dir") != "",
+		BackupDir:          cmd.String("backup-dir"),
+		Restore:            cmd.Bool("restore"),
+		Verify:             cmd.Bool("verify"),
+		ReplaceFrom:        cmd.Bool("replace-from"),
+		Commit:             cmd.Bool("commit"),
+		Confirm:            confirmFromFlags(cmd),
 	}
-
-	ids, err := agepkg.LoadIdentities(cfg.IdentitiesPath)
-	if err != nil {
-		return err
+	if cfg.Restore {
+		if cfg.PlanPath != "" || cfg.ApplyPath != "" || cfg.ToRecipientsFile != "" || len(cfg.AddRecipients)+len(cfg.RemoveRecipients) > 0 || cfg.ReplaceFrom || cfg.Commit {
+			return fmt.Errorf("rotate --restore takes only --root, --backup-dir, and confirmation flags")
+		}
+		return runRestore(cfg)
 	}
-	newRecips, err := agepkg.LoadRecipients(cfg.ToRecipientsFile)
-	if err != nil {
-		return err
+	if v := cmd.String("io-limit"); v != "" {
+		limit, err := walk.ParseRate(v)
+		if err != nil {
+			return fmt.Errorf("rotate: --io-limit: %w", err)
+		}
+		cfg.IOLimit = limit
+	}
+	switch {
+	case cfg.Report != "" && cfg.Report != "json":
+		return fmt.Errorf("rotate: --report %q: only json is supported", cfg.Report)
+	case cfg.Report == "" && cfg.ReportPath != "":
+		return fmt.Errorf("rotate: --report-file needs --report json")
+	case cfg.Report != "" && cfg.PlanPath != "":
+		return fmt.Errorf("rotate: --report describes a rotation; it cannot be combined with --plan")
+	case (cfg.ReplaceFrom || cfg.Commit) && cfg.PlanPath != "":
+		return fmt.Errorf("rotate: --replace-from and --commit act on a rotation; pass them with --apply instead of --plan")
+	}
+	// A report on stdout keeps stdout parseable; the text goes to stderr.
+	out := io.Writer(os.Stdout)
+	if cfg.Report != "" && cfg.ReportPath == "" {
+		out = os.Stderr
 	}
 
-	var files []string
-	err = filepath.WalkDir(cfg.Root, func(path string, d fs.DirEntry, err error) error {
+	// Every rotation runs from a plan: read from --apply, or made now.
+	var plan *rotation.Plan
+	var err error
+	switch {
+	case cfg.PlanPath != "" && cfg.ApplyPath != "":
+		return fmt.Errorf("rotate: pass either --plan or --apply, not both")
+	case cfg.ApplyPath != "":
+		if plan, err = rotation.Read(cfg.ApplyPath); err != nil {
+			return fmt.Errorf("rotate: %w", err)
+		}
+	case len(cfg.AddRecipients)+len(cfg.RemoveRecipients) > 0 && cfg.ToRecipientsFile != "":
+		return fmt.Errorf("rotate: pass either --to or --add-recipient/--remove-recipient, not both")
+	case cfg.ToRecipientsFile == "" && len(cfg.AddRecipients)+len(cfg.RemoveRecipients) == 0:
+		return fmt.Errorf("rotate: missing --to; pass the new recipients file, --add-recipient/--remove-recipient, or --apply a plan")
+	default:
+		files, err := walk.AgeFiles(cfg.Root)
 		if err != nil {
 			return err
 		}
-		if !d.IsDir() && strings.HasSuffix(strings.ToLower(d.Name()), ".age") {
-			files = append(files, path)
+		if len(files) == 0 {
+			return fmt.Errorf("rotate: no .age files found under %s", cfg.Root)
+		}
+		var armor *bool // --armor=auto keeps each file's format
+		if a, auto := armorFromFlags(cmd); !auto {
+			armor = &a
+		}
+		if cfg.ToRecipientsFile != "" {
+			plan, err = rotation.New(cfg.Root, cfg.FromRecipientsFile, cfg.ToRecipientsFile, files, cfg.Prune, armor)
+		} else {
+			plan, err = rotation.NewDelta(cfg.Root, cfg.FromRecipientsFile, cfg.AddRecipients, cfg.RemoveRecipients, files, cfg.Prune, armor)
+		}
+		if err != nil {
+			return fmt.Errorf("rotate: %w", err)
+		}
+		if cfg.Prune && len(plan.Removed) == 0 {
+			return fmt.Errorf("rotate --prune: every recipient in %s is also in %s; nothing to prune",
+				cfg.FromRecipientsFile, cfg.ToRecipientsFile)
 		}
+	}
+	newRecips, err := plan.ParseRecipients()
+	if err != nil {
+		return fmt.Errorf("rotate: %w", err)
+	}
+	if cfg.ReplaceFrom && (plan.UpdateRecipientsFile || plan.From == plan.To) {
+		return fmt.Errorf("rotate: --replace-from copies --to over --from; this rotation already updates %s", plan.From)
+	}
+
+	if cfg.PlanPath != "" {
+		printPlan(out, plan)
+		if err := plan.Write(cfg.PlanPath); err != nil {
+			return fmt.Errorf("rotate: %w", err)
+		}
+		fmt.Printf("rotate: plan written to %s; review it, then run: %s rotate --apply %s\n",
+			cfg.PlanPath, appName, cfg.PlanPath)
 		return nil
-	})
+	}
+	if err := preflightRotate(plan, len(newRecips), cfg.Workers); err != nil {
+		return fmt.Errorf("rotate: %w; nothing was written", err)
+	}
+	if cfg.Commit {
+		if _, err := (gitutil.Repo{}).TopLevel(); err != nil {
+			return fmt.Errorf("rotate --commit: %w; nothing was written", err)
+		}
+	}
+	var backup func(string, []byte) error
+	if cfg.Backup {
+		if err := preflightBackups(plan, cfg.BackupDir, cfg.Resume); err != nil {
+			return fmt.Errorf("rotate: %w; nothing was written", err)
+		}
+		backup = func(path string, cipher []byte) error {
+			bak, err := backupPath(plan.Root, path, cfg.BackupDir)
+			if err != nil {
+				return err
+			}
+			return writeBackup(path, bak, cipher, cfg.Resume)
+		}
+	}
+	if plan.Prune {
+		printPlan(out, plan)
+		if err := typedConfirm(cfg.Confirm, "prune", fmt.Sprintf("Remove %d recipient(s) from %d file(s)?",
+			len(plan.Removed), len(plan.Files))); err != nil {
+			return fmt.Errorf("rotate: %w", err)
+		}
+	} else {
+		if cfg.ApplyPath != "" {
+			printPlan(out, plan)
+		}
+		if err := confirm(cfg.Confirm, fmt.Sprintf("Re-encrypt %d file(s) to %d recipient(s)?",
+			len(plan.Files), len(newRecips))); err != nil {
+			return fmt.Errorf("rotate: %w", err)
+		}
+	}
+
+	job, err := startJob(cmd)
+	if err != nil {
+		return fmt.Errorf("rotate: %w", err)
+	}
+	ids, err := agepkg.LoadIdentities(cfg.IdentitiesPath)
 	if err != nil {
 		return err
 	}
-	if len(files) == 0 {
-		return fmt.Errorf("rotate: no .age files found under %s", cfg.Root)
+	if cfg.Nice != 0 {
+		if err := walk.SetNice(cfg.Nice); err != nil {
+			return fmt.Errorf("rotate: --nice: %w", err)
+		}
 	}
-
-	ok, fail := 0, 0
-	for _, f := range files {
-		plain, err := agepkg.DecryptToMemory(f, ids)
+	if cfg.CheckpointPath == "" {
+		cfg.CheckpointPath = rotation.CheckpointPath(plan.Root)
+	}
+	cp, err := rotation.OpenCheckpoint(cfg.CheckpointPath, plan, cfg.Resume)
+	if err != nil {
+		return fmt.Errorf("rotate: %w", err)
+	}
+	lim := walk.NewLimiter(cfg.IOLimit)
+	var skipped atomic.Int64
+	var mu sync.Mutex
+	took := map[string]time.Duration{}
+	wasSkipped := map[string]bool{}
+	hashes := map[string][2]string{} // ciphertext and plaintext, for --verify
+	errs := walk.Each(ctx, plan.Files, cfg.Workers, func(f rotation.File) error {
+		start := time.Now()
+		defer func() {
+			mu.Lock()
+			took[f.Path] = time.Since(start)
+			mu.Unlock()
+		}()
+		if h, done := cp.Done(f.Path); done && alreadyRotated(f.Path, h, newRecips) {
+			skipped.Add(1)
+			mu.Lock()
+			wasSkipped[f.Path] = true
+			hashes[f.Path] = [2]string{h, ""}
+			mu.Unlock()
+			return nil
+		}
+		h, plain, err := rotateFile(ctx, f, ids, newRecips, plan.Prune, lim, backup)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "rotate: decrypt failed for %s: %v\n", f, err)
-			fail++
-			continue
+			return err
 		}
-		if err := agepkg.AtomicEncryptWrite(f, []byte(plain), newRecips, true /* keep armor on rotate */); err != nil {
-			fmt.Fprintf(os.Stderr, "rotate: re-encrypt failed for %s: %v\n", f, err)
+		mu.Lock()
+		hashes[f.Path] = [2]string{h, plain}
+		mu.Unlock()
+		return cp.Record(f.Path, h)
+	})
+	verified, headerOnly := 0, 0
+	if cfg.Verify {
+		var checked []rotation.File
+		var at []int
+		for i, err := range errs {
+			if err == nil {
+				checked = append(checked, plan.Files[i])
+				at = append(at, i)
+			}
+		}
+		var payloads atomic.Int64
+		verrs := walk.Each(ctx, checked, cfg.Workers, func(f rotation.File) error {
+			if info, err := os.Stat(f.Path); err == nil {
+				if err := lim.Wait(ctx, info.Size()); err != nil {
+					return err
+				}
+			}
+			h := hashes[f.Path]
+			opened, err := verifyRotated(f.Path, h[0], h[1], ids, newRecips)
+			if opened && err == nil {
+				payloads.Add(1)
+			}
+			return err
+		})
+		for j, err := range verrs {
+			if err != nil {
+				errs[at[j]] = fmt.Errorf("verify failed: %w", err)
+				continue
+			}
+			verified++
+		}
+		headerOnly = verified - int(payloads.Load())
+	}
+	ok, fail := 0, 0
+	report := rotation.NewReport(plan, len(newRecips))
+	for i, err := range errs {
+		f := plan.Files[i]
+		report.Add(f, wasSkipped[f.Path], err, took[f.Path])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "rotate: %s: %v\n", f.Path, err)
 			fail++
 			continue
 		}
 		ok++
 	}
-	fmt.Printf("rotate complete: %d success, %d failed\n", ok, fail)
+	if err := cp.Close(fail == 0); err != nil {
+		fmt.Fprintf(os.Stderr, "rotate: %v\n", err)
+	}
+	if cfg.Report != "" {
+		if err := writeReport(report, cfg.ReportPath); err != nil {
+			fmt.Fprintf(os.Stderr, "rotate: report: %v\n", err)
+		}
+	}
+	if n := skipped.Load(); n > 0 {
+		fmt.Fprintf(out, "rotate: resumed; %d file(s) were already rotated\n", n)
+	}
+	fmt.Fprintf(out, "rotate complete: %d success, %d failed\n", ok, fail)
+	recordStats(stats.Rotate, plan.Root, ok)
+	if cfg.Verify {
+		line := fmt.Sprintf("rotate: verified %d file(s)", verified)
+		if headerOnly > 0 {
+			line += fmt.Sprintf("; %d by hash and header only, as --identities is not among the new recipients", headerOnly)
+		}
+		fmt.Fprintln(out, line)
+	}
+	if cfg.Backup && ok > 0 {
+		where, undo := "beside each file as *.age"+backupExt, ""
+		if cfg.BackupDir != "" {
+			where, undo = "under "+cfg.BackupDir, " --backup-dir "+cfg.BackupDir
+		}
+		fmt.Fprintf(out, "rotate: originals kept %s; undo with: %s rotate --restore --root %s%s\n", where, appName, plan.Root, undo)
+	}
+	job.Done(appName+" rotate", fmt.Sprintf("%d success, %d failed", ok, fail))
+	if fail > 0 {
+		fmt.Fprintf(os.Stderr, "rotate: progress saved in %s; rerun with --resume to continue\n", cfg.CheckpointPath)
+	}
+	if plan.UpdateRecipientsFile && fail == 0 {
+		if err := plan.WriteRecipientsFile(); err != nil {
+			return fmt.Errorf("rotate: files rotated, but updating %s failed; make the change by hand: %w", plan.To, err)
+		}
+		fmt.Fprintf(out, "rotate: updated %s (+%d/-%d recipients)\n", plan.To, len(plan.Added), len(plan.Removed))
+	}
+	if cfg.ReplaceFrom && fail == 0 {
+		bak, err := plan.ReplaceFrom(time.Now())
+		if err != nil {
+			return fmt.Errorf("rotate: files rotated, but replacing %s with %s failed; make the change by hand: %w", plan.From, plan.To, err)
+		}
+		if bak != "" {
+			fmt.Fprintf(out, "rotate: replaced %s with %s; the old one is kept as %s\n", plan.From, plan.To, bak)
+		} else {
+			fmt.Fprintf(out, "rotate: copied %s to %s\n", plan.To, plan.From)
+		}
+	}
+	if cfg.Commit && fail == 0 {
+		var paths []string
+		for _, f := range plan.Files {
+			paths = append(paths, f.Path)
+		}
+		if plan.UpdateRecipientsFile || cfg.ReplaceFrom {
+			paths = append(paths, plan.From)
+		}
+		hash, err := (gitutil.Repo{}).Commit(commitMessage(plan), paths...)
+		if err != nil {
+			return fmt.Errorf("rotate: files rotated, but committing them failed; commit by hand: %w", err)
+		}
+		fmt.Fprintf(out, "rotate: committed %d file(s) as %.12s\n", len(paths), hash)
+	}
+	if plan.Prune && ok > 0 {
+		fmt.Fprintf(out, "prune verified: %d header(s) hold exactly %d recipient stanza(s), none for removed keys\n", ok, len(newRecips))
+	}
 	if fail > 0 {
 		return fmt.Errorf("rotate: some files failed (see stderr)")
 	}
@@ -223,79 +1209,108 @@ func runRotate(ctx context.Context, cmd *cli.Command) error {
 
 func runEnvExec(ctx context.Context, cmd *cli.Command) error {
 	args := cmd.Args().Slice()
-	// Syntax: agepad run -- <file.age> -- <command> [args...]
-	firstDash, secondDash := -1, -1
-	for i, a := range args {
-		if a == "--" {
-			if firstDash == -1 {
-				firstDash = i
-			} else {
-				secondDash = i
-				break
-			}
-		}
-	}
-	if firstDash == -1 || secondDash == -1 || secondDash == len(args)-1 {
-		return fmt.Errorf(`run usage: ` + appName + ` run -- <file.age> -- <command> [args...]`)
+	// Syntax: agepad run -- <file.age>... -- <command> [args...]. The flag
+	// parser consumes the first "--", so it may or may not still be here.
+	if len(args) > 0 && args[0] == "--" {
+		args = args[1:]
 	}
-	files := args[firstDash+1 : secondDash]
-	if len(files) != 1 {
-		return fmt.Errorf("run: expected exactly one AGE file after the first --")
+	sep := slices.Index(args, "--")
+	if sep < 1 || sep == len(args)-1 {
+		return fmt.Errorf(`run usage: ` + appName + ` run -- <file.age> [override.age...] -- <command> [args...]`)
 	}
-	runFile := files[0]
-	runArgs := args[secondDash+1:]
 
 	cfg := model.RunConfig{
-		FilePath:       runFile,
-		IdentitiesPath: defaultIdentitiesPath(),
-		Command:        runArgs,
+		Files:          args[:sep],
+		IdentitiesPath: cmd.String("identities"),
+		Command:        args[sep+1:],
+		NoDaemon:       cmd.Bool("no-daemon"),
+		Stanzas:        stanzaPolicy(cmd),
+		Supervise:      cmd.Bool("supervise") || cmd.Bool("report"),
+		Report:         cmd.Bool("report"),
+		Only:           cmd.StringSlice("only"),
+		Exclude:        cmd.StringSlice("exclude"),
+		MinimalEnv:     cmd.Bool("minimal-env"),
+		Prefix:         cmd.String("prefix"),
+		Watch:          cmd.Bool("watch"),
+		WatchInterval:  cmd.Duration("watch-interval"),
 	}
-
-	ids, err := agepkg.LoadIdentities(cfg.IdentitiesPath)
+	renames, err := parseRenames(cmd.StringSlice("rename"))
 	if err != nil {
 		return err
 	}
-	plain, err := agepkg.DecryptToMemory(cfg.FilePath, ids)
-	if err != nil {
+	cfg.Renames = renames
+	if err := checkKeyPatterns("only", cfg.Only); err != nil {
+		return err
+	}
+	if err := checkKeyPatterns("exclude", cfg.Exclude); err != nil {
 		return err
 	}
+	switch {
+	case cfg.Watch && cfg.Report:
+		return fmt.Errorf("run: --watch runs the command many times; --report cannot be combined with it")
+	case cfg.Watch && cfg.WatchInterval <= 0:
+		return fmt.Errorf("run: --watch-interval must be positive")
+	}
 
-	// Merge decrypted KEY=VAL lines into environment (simple .env semantics).
-	envMap := map[string]string{}
-	for _, kv := range os.Environ() {
-		parts := strings.SplitN(kv, "=", 2)
-		if len(parts) == 2 {
-			envMap[parts[0]] = parts[1]
-		}
+	cmdName := cfg.Command[0]
+	path, err := exec.LookPath(cmdName)
+	if err != nil {
+		return fmt.Errorf("run: command not found: %s", cmdName)
 	}
-	sc := bufio.NewScanner(strings.NewReader(plain))
-	for sc.Scan() {
-		line := strings.TrimSpace(sc.Text())
-		if line == "" || strings.HasPrefix(line, "#") || !strings.Contains(line, "=") {
-			continue
-		}
-		parts := strings.SplitN(line, "=", 2)
-		key := strings.TrimSpace(parts[0])
-		val := parts[1] // keep raw; allow spaces
-		if key != "" {
-			envMap[key] = val
-		}
+	// Resolved like the kernel reports it, for the daemon's allowlist.
+	if path, err = filepath.Abs(path); err != nil {
+		return err
+	}
+	if real, err := filepath.EvalSymlinks(path); err == nil {
+		path = real
 	}
-	if err := sc.Err(); err != nil {
+
+	var ids []age.Identity
+	sums := fileSums(cfg.Files) // before decrypting, so a change meanwhile is not missed
+	newEnv, err := runEnv(cfg, path, &ids)
+	if err != nil {
 		return err
 	}
+	if cfg.Watch {
+		return runWatching(cfg, path, newEnv, sums, &ids)
+	}
+	if !cfg.Supervise {
+		// Replace current process with target command (on Windows, run it
+		// and exit with its status)
+		return platform.Exec(path, cfg.Command, newEnv)
+	}
+	ps, usage, err := platform.Supervise(path, cfg.Command, newEnv)
+	if err != nil {
+		return fmt.Errorf("run: %w", err)
+	}
+	if cfg.Report {
+		fmt.Fprintln(os.Stderr, runReport(ps, usage))
+	}
+	platform.ExitLike(ps)
+	return nil
+}
 
-	// Convert to []string form for Exec
-	var newEnv []string
-	for k, v := range envMap {
-		newEnv = append(newEnv, k+"="+v)
+// runReport is the one-line run --report summary of a finished child.
+func runReport(ps *os.ProcessState, u platform.ChildUsage) string {
+	rss := "unknown"
+	if u.MaxRSS > 0 {
+		rss = walk.FormatSize(u.MaxRSS)
 	}
+	return fmt.Sprintf("%s run: %s; wall %s, user %s, sys %s, max RSS %s", appName, ps,
+		u.Wall.Round(time.Millisecond), u.User.Round(time.Millisecond), u.System.Round(time.Millisecond), rss)
+}
 
-	// Replace current process with target command (posix-style)
-	cmdName := cfg.Command[0]
-	path, err := exec.LookPath(cmdName)
+// envVars returns the variables run exports from a decrypted file: .env
+// content as written, structured formats (by inner extension) flattened to
+// upper-case, underscore-joined keys ({"db":{"host":..}} becomes DB_HOST).
+func envVars(path, plain string) (map[string]string, error) {
+	f := filetype.FromName(path, nil)
+	if f == "" || f == "env" {
+		return dotenv.Map(plain)
+	}
+	data, err := convert.Decode(plain, convert.Format(f))
 	if err != nil {
-		return fmt.Errorf("run: command not found: %s", cmdName)
+		return nil, err
 	}
-	return syscall.Exec(path, cfg.Command, newEnv)
+	return convert.Flatten(data, convert.Options{Upper: true}), nil
 }
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"strings"
+
+	agepkg "github.com/andreweick/agepad/age"
+	"github.com/andreweick/agepad/buildinfo"
+	"github.com/andreweick/agepad/convert"
+	"github.com/andreweick/agepad/model"
+	"github.com/andreweick/agepad/validator"
+	"github.com/urfave/cli/v3"
+)
+
+// mergeTargetFlags are shared by subcommands that merge values into an
+// encrypted file (merge-stdin, import-terraform).
+func mergeTargetFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:     "file",
+			Usage:    "Encrypted file to merge into (created if missing)",
+			Required: true,
+		},
+		&cli.StringFlag{
+			Name:  "separator",
+			Usage: "Separator joining nested keys when merging into an env file",
+			Value: "_",
+		},
+		&cli.BoolFlag{
+			Name:  "upper",
+			Usage: "Upper-case flattened keys when merging into an env file",
+		},
+		&cli.StringFlag{
+			Name:  "recipients-file",
+			Usage: "Path to recipients file",
+			Value: defaultRecipientsFile,
+		},
+		&cli.StringFlag{
+			Name:  "identities",
+			Usage: "Path to AGE identities",
+			Value: defaultIdentitiesPath(),
+		},
+		&cli.BoolFlag{
+			Name:  "armor",
+			Usage: "Write ASCII-armored .age output",
+			Value: buildinfo.ArmorDefault,
+		},
+		yesFlag,
+		confirmPromptFlag,
+	}
+}
+
+func mergeConfigFromFlags(cmd *cli.Command) model.MergeConfig {
+	return model.MergeConfig{
+		FilePath:       cmd.String("file"),
+		Format:         cmd.String("format"),
+		Separator:      cmd.String("separator"),
+		Upper:          cmd.Bool("upper"),
+		RecipientsFile: cmd.String("recipients-file"),
+		IdentitiesPath: cmd.String("identities"),
+		Armor:          cmd.Bool("armor"),
+		Confirm:        confirmFromFlags(cmd),
+	}
+}
+
+func mergeStdinCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "merge-stdin",
+		Usage: "Merge key/values from stdin (json, yaml, or env) into an encrypted file",
+		Flags: append([]cli.Flag{
+			&cli.StringFlag{
+				Name:  "format",
+				Usage: "Format of stdin: json, yaml, or env",
+				Value: "json",
+			},
+		}, mergeTargetFlags()...),
+		Action: runMergeStdin,
+	}
+}
+
+func runMergeStdin(ctx context.Context, cmd *cli.Command) error {
+	cfg := mergeConfigFromFlags(cmd)
+	if cfg.IdentitiesPath == agepkg.StdinIdentities {
+		return fmt.Errorf("merge-stdin reads values from stdin; pass the key in $%s instead of --identities -", agepkg.IdentityEnv)
+	}
+	in, err := convert.ParseFormat(cfg.Format)
+	if err != nil {
+		return fmt.Errorf("merge-stdin: %w", err)
+	}
+	b, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		return fmt.Errorf("merge-stdin: read stdin: %w", err)
+	}
+	values, err := convert.Decode(string(b), in)
+	if err != nil {
+		return fmt.Errorf("merge-stdin: %w", err)
+	}
+	return mergeIntoFile(cfg, values)
+}
+
+// mergeIntoFile decrypts cfg.FilePath (or starts empty when it does not
+// exist), merges values, validates, and re-encrypts. Only key names are
+// printed.
+func mergeIntoFile(cfg model.MergeConfig, values map[string]any) error {
+	if len(values) == 0 {
+		return fmt.Errorf("nothing to merge")
+	}
+	target, err := convert.FormatFromPath(cfg.FilePath)
+	if err != nil {
+		return err
+	}
+	recips, err := agepkg.LoadRecipients(cfg.RecipientsFile)
+	if err != nil {
+		return err
+	}
+
+	plain := ""
+	if _, err := os.Stat(cfg.FilePath); err == nil {
+		ids, err := agepkg.LoadIdentities(cfg.IdentitiesPath)
+		if err != nil {
+			return err
+		}
+		if plain, err = agepkg.DecryptToMemory(cfg.FilePath, ids); err != nil {
+			return err
+		}
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	out, res, err := convert.Merge(plain, target, values, convert.Options{
+		Separator: cfg.Separator,
+		Upper:     cfg.Upper,
+	})
+	if err != nil {
+		return err
+	}
+	if err := validator.ValidateByExt("merged."+string(target), out); err != nil {
+		return fmt.Errorf("merged content does not validate: %w", err)
+	}
+
+	fmt.Printf("added:   %s\nupdated: %s\n", strings.Join(res.Added, ", "), strings.Join(res.Updated, ", "))
+	if err := confirm(cfg.Confirm, fmt.Sprintf("Write %d key(s) to %s?",
+		len(res.Added)+len(res.Updated), cfg.FilePath)); err != nil {
+		return err
+	}
+	if err := encryptWrite(cfg.FilePath, []byte(out), recips, cfg.Armor); err != nil {
+		return err
+	}
+	fmt.Printf("merged %d key(s) into %s\n", len(res.Added)+len(res.Updated), cfg.FilePath)
+	return nil
+}
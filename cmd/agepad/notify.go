@@ -0,0 +1,38 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/andreweick/agepad/notify"
+	"github.com/charmbracelet/x/term"
+	"github.com/urfave/cli/v3"
+)
+
+var (
+	notifyFlag = &cli.StringFlag{
+		Name:  "notify",
+		Usage: "When rotate or verify outlasts --notify-after: bell, desktop (bell plus a desktop notification), or off",
+		Value: notify.Bell,
+	}
+	notifyAfterFlag = &cli.DurationFlag{
+		Name:  "notify-after",
+		Usage: "Announce the end of rotate or verify only when it ran at least this long",
+		Value: notify.DefaultAfter,
+	}
+)
+
+// startJob times a long job for --notify. The bell goes to stderr, and
+// only when that is a terminal.
+func startJob(cmd *cli.Command) (*notify.Job, error) {
+	mode, err := notify.ParseMode(cmd.String("notify"))
+	if err != nil {
+		return nil, fmt.Errorf("--notify: %w", err)
+	}
+	var w io.Writer
+	if term.IsTerminal(os.Stderr.Fd()) {
+		w = os.Stderr
+	}
+	return notify.Start(mode, cmd.Duration("notify-after"), w), nil
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"slices"
+
+	"filippo.io/age"
+	agepkg "github.com/andreweick/agepad/age"
+	"github.com/andreweick/agepad/model"
+	"github.com/andreweick/agepad/tui"
+)
+
+// openWithPassphrase decrypts cfg.FilePath with a passphrase asked on the
+// terminal. A file that does not exist yet opens empty; its passphrase is
+// chosen (and confirmed) on the first save.
+func openWithPassphrase(cfg model.Config) (string, tui.Model, error) {
+	if _, err := os.Stat(cfg.FilePath); errors.Is(err, fs.ErrNotExist) {
+		return "", tui.NewModel(cfg, "", nil, nil), nil
+	}
+	if err := checkStanzas(cfg.FilePath, cfg.Stanzas); err != nil {
+		return "", tui.Model{}, err
+	}
+	pass, err := agepkg.ReadPassphrase("Passphrase for " + cfg.FilePath + ": ")
+	if err != nil {
+		return "", tui.Model{}, err
+	}
+	id, r, err := agepkg.Passphrase(string(pass))
+	if err != nil {
+		return "", tui.Model{}, err
+	}
+	plain, err := agepkg.DecryptToMemory(cfg.FilePath, []age.Identity{id})
+	if err != nil {
+		return "", tui.Model{}, err
+	}
+	return plain, tui.NewModel(cfg, plain, []age.Identity{id}, []age.Recipient{r}), nil
+}
+
+// passphraseHint explains a failed decryption of a passphrase-encrypted
+// file opened without --passphrase.
+func passphraseHint(path string, err error) error {
+	if h, herr := agepkg.InspectHeader(path); herr == nil && slices.Contains(h.StanzaTypes(), "scrypt") {
+		return fmt.Errorf("%w\n%s is passphrase-encrypted; open it with --passphrase", err, path)
+	}
+	return err
+}
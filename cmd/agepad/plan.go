@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"filippo.io/age"
+	agepkg "github.com/andreweick/agepad/age"
+	"github.com/andreweick/agepad/rotation"
+	"github.com/andreweick/agepad/walk"
+)
+
+// printPlan shows what a rotation will change, file by file.
+func printPlan(w io.Writer, p *rotation.Plan) {
+	fmt.Fprintf(w, "Re-encrypt %d file(s) to %d recipient(s) from %s:\n", len(p.Files), len(p.Recipients), p.To)
+	for _, r := range p.Added {
+		fmt.Fprintf(w, "  + %s\n", r)
+	}
+	for _, r := range p.Removed {
+		fmt.Fprintf(w, "  - %s\n", r)
+	}
+	for _, f := range p.Files {
+		line := fmt.Sprintf("  %s: %d recipient stanza(s) (%s) -> %d", f.Path, len(f.StanzasBefore),
+			strings.Join(f.StanzasBefore, ", "), len(p.Recipients))
+		if c := f.FormatChange(); c != "" {
+			line += ", " + c
+		}
+		fmt.Fprintln(w, line)
+	}
+}
+
+// commitMessage describes a finished rotation for rotate --commit.
+func commitMessage(p *rotation.Plan) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Rotate %d file(s) under %s to %d recipient(s)\n\nRecipients from %s.\n", len(p.Files), p.Root, len(p.Recipients), p.To)
+	for _, r := range p.Added {
+		fmt.Fprintf(&b, "Added: %s\n", r)
+	}
+	for _, r := range p.Removed {
+		fmt.Fprintf(&b, "Removed: %s\n", r)
+	}
+	return b.String()
+}
+
+// rotateFile re-encrypts one planned file, refusing if its ciphertext has
+// changed since the plan was made. Reads and writes are paced by lim, and
+// backup (when not nil) is given the old ciphertext before it is
+// replaced. It returns the hashes of the new ciphertext and of the
+// plaintext, for --verify.
+func rotateFile(ctx context.Context, f rotation.File, ids []age.Identity, recips []age.Recipient, prune bool, lim *walk.Limiter, backup func(path string, cipher []byte) error) (string, string, error) {
+	if info, err := os.Stat(f.Path); err == nil {
+		if err := lim.Wait(ctx, info.Size()); err != nil {
+			return "", "", err
+		}
+	}
+	cipher, err := os.ReadFile(f.Path)
+	if err != nil {
+		return "", "", err
+	}
+	if rotation.Hash(cipher) != f.SHA256 {
+		return "", "", fmt.Errorf("changed since the plan was made; re-plan")
+	}
+	plain, err := agepkg.Decrypt(cipher, ids)
+	if err != nil {
+		return "", "", fmt.Errorf("decrypt failed: %w", err)
+	}
+	if err := lim.Wait(ctx, int64(len(cipher))); err != nil { // the rewrite is about as large
+		return "", "", err
+	}
+	if backup != nil {
+		if err := backup(f.Path, cipher); err != nil {
+			return "", "", fmt.Errorf("backup failed: %w", err)
+		}
+	}
+	if err := encryptWrite(f.Path, []byte(plain), recips, f.Armor); err != nil {
+		return "", "", fmt.Errorf("re-encrypt failed: %w", err)
+	}
+	if prune {
+		if err := verifyPruned(f.Path, recips); err != nil {
+			return "", "", fmt.Errorf("prune verification failed: %w", err)
+		}
+	}
+	written, err := os.ReadFile(f.Path)
+	if err != nil {
+		return "", "", err
+	}
+	return rotation.Hash(written), rotation.Hash([]byte(plain)), nil
+}
+
+// preflightRotate checks space and atomic rename in every directory the
+// plan writes to. The plaintext is no larger than the current ciphertext,
+// so that bounds each file's new size.
+func preflightRotate(plan *rotation.Plan, recipients, workers int) error {
+	writes := make([]walk.Write, 0, len(plan.Files))
+	for _, f := range plan.Files {
+		info, err := os.Stat(f.Path)
+		if err != nil {
+			return err
+		}
+		writes = append(writes, walk.Write{Path: f.Path, Size: agepkg.EncryptedSize(info.Size(), recipients, f.Armor)})
+	}
+	return walk.Preflight(writes, workers)
+}
+
+// alreadyRotated reports whether a file a checkpoint lists as done still
+// holds that ciphertext, with one header stanza per new recipient.
+func alreadyRotated(path, hash string, recips []age.Recipient) bool {
+	b, err := os.ReadFile(path)
+	if err != nil || rotation.Hash(b) != hash {
+		return false
+	}
+	h, err := agepkg.InspectHeaderBytes(b)
+	return err == nil && len(h.Stanzas) == len(recips)
+}
+
+// verifyRotated re-reads a rotated file and checks that it still holds the
+// ciphertext the rotation wrote, with one header stanza per new recipient.
+// When ids are among the new recipients the payload is decrypted too and,
+// if plain is set, compared with the plaintext hash from before the
+// rotation. It reports whether the payload was checked.
+func verifyRotated(path, hash, plain string, ids []age.Identity, recips []age.Recipient) (bool, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return false, err
+	}
+	if rotation.Hash(b) != hash {
+		return false, fmt.Errorf("changed after it was rotated")
+	}
+	h, err := agepkg.InspectHeaderBytes(b)
+	if err != nil {
+		return false, err
+	}
+	if len(h.Stanzas) != len(recips) {
+		return false, fmt.Errorf("header has %d recipient stanza(s), want %d", len(h.Stanzas), len(recips))
+	}
+	text, err := agepkg.Decrypt(b, ids)
+	var noMatch *age.NoIdentityMatchError
+	if errors.As(err, &noMatch) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if plain != "" && rotation.Hash([]byte(text)) != plain {
+		return true, fmt.Errorf("content differs from before the rotation")
+	}
+	return true, nil
+}
+
+// writeReport writes a rotate --report to path, or to stdout when path is
+// empty.
+func writeReport(r *rotation.Report, path string) error {
+	if path == "" {
+		return r.WriteJSON(os.Stdout)
+	}
+	var b bytes.Buffer
+	if err := r.WriteJSON(&b); err != nil {
+		return err
+	}
+	return os.WriteFile(path, b.Bytes(), 0o644)
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+
+	agepkg "github.com/andreweick/agepad/age"
+	"github.com/andreweick/agepad/buildinfo"
+	"github.com/andreweick/agepad/diff"
+	"github.com/andreweick/agepad/keyops"
+	"github.com/andreweick/agepad/model"
+	"github.com/urfave/cli/v3"
+)
+
+func prefixCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "prefix",
+		Usage: "Add or strip a key prefix across an encrypted .env file",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:     "file",
+				Usage:    "Encrypted .env file to edit",
+				Required: true,
+			},
+			&cli.StringFlag{
+				Name:  "add",
+				Usage: "Prefix to add to every key that lacks it (e.g. APP_)",
+			},
+			&cli.StringFlag{
+				Name:  "strip",
+				Usage: "Prefix to remove from every key that has it",
+			},
+			&cli.BoolFlag{
+				Name:  "dry-run",
+				Usage: "Only print the preview diff",
+			},
+			&cli.StringFlag{
+				Name:  "recipients-file",
+				Usage: "Path to recipients file",
+				Value: defaultRecipientsFile,
+			},
+			&cli.StringFlag{
+				Name:  "identities",
+				Usage: "Path to AGE identities",
+				Value: defaultIdentitiesPath(),
+			},
+			&cli.BoolFlag{
+				Name:  "armor",
+				Usage: "Write ASCII-armored .age output",
+				Value: buildinfo.ArmorDefault,
+			},
+			yesFlag,
+			confirmPromptFlag,
+		},
+		Action: runPrefix,
+	}
+}
+
+func runPrefix(ctx context.Context, cmd *cli.Command) error {
+	cfg := model.PrefixConfig{
+		FilePath:       cmd.String("file"),
+		Add:            cmd.String("add"),
+		Strip:          cmd.String("strip"),
+		DryRun:         cmd.Bool("dry-run"),
+		RecipientsFile: cmd.String("recipients-file"),
+		IdentitiesPath: cmd.String("identities"),
+		Armor:          cmd.Bool("armor"),
+		Confirm:        confirmFromFlags(cmd),
+	}
+	if (cfg.Add == "") == (cfg.Strip == "") {
+		return fmt.Errorf("prefix: pass exactly one of --add or --strip")
+	}
+	alg, err := diff.ParseAlgorithm(cmd.String("diff-algorithm"))
+	if err != nil {
+		return err
+	}
+
+	ids, err := agepkg.LoadIdentities(cfg.IdentitiesPath)
+	if err != nil {
+		return err
+	}
+	plain, err := agepkg.DecryptToMemory(cfg.FilePath, ids)
+	if err != nil {
+		return err
+	}
+
+	var out string
+	var ren keyops.Renaming
+	if cfg.Add != "" {
+		out, ren, err = keyops.AddPrefix(plain, cfg.Add)
+	} else {
+		out, ren, err = keyops.StripPrefix(plain, cfg.Strip)
+	}
+	if err != nil {
+		return fmt.Errorf("prefix: %w", err)
+	}
+	if len(ren) == 0 {
+		fmt.Println("prefix: no keys to rename")
+		return nil
+	}
+
+	// Preview with values masked: only key names are meant to change.
+	fmt.Print(unifiedDiff(keyops.MaskValues(plain), keyops.MaskValues(out), filepath.Base(cfg.FilePath), alg))
+	if cfg.DryRun {
+		return nil
+	}
+	if err := confirm(cfg.Confirm, fmt.Sprintf("Rename %d key(s) in %s?", len(ren), cfg.FilePath)); err != nil {
+		return fmt.Errorf("prefix: %w", err)
+	}
+
+	recips, err := agepkg.LoadRecipients(cfg.RecipientsFile)
+	if err != nil {
+		return err
+	}
+	if err := encryptWrite(cfg.FilePath, []byte(out), recips, cfg.Armor); err != nil {
+		return fmt.Errorf("prefix: %w", err)
+	}
+	fmt.Printf("prefix: renamed %d key(s) in %s\n", len(ren), cfg.FilePath)
+	return nil
+}
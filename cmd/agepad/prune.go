@@ -0,0 +1,44 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"filippo.io/age"
+	agepkg "github.com/andreweick/agepad/age"
+	"github.com/andreweick/agepad/model"
+)
+
+// verifyPruned checks a rewritten file's header: with one stanza per
+// recipient, a header with exactly len(recips) stanzas, all from the new
+// set, leaves no room for a pruned key.
+func verifyPruned(path string, recips []age.Recipient) error {
+	h, err := agepkg.InspectHeader(path)
+	if err != nil {
+		return err
+	}
+	if len(h.Stanzas) != len(recips) {
+		return fmt.Errorf("header has %d recipient stanza(s), expected %d", len(h.Stanzas), len(recips))
+	}
+	return nil
+}
+
+// typedConfirm requires the user to type word, unless --yes was given.
+// Unlike confirm it always asks, and refuses when nobody can answer.
+func typedConfirm(c model.Confirm, word, question string) error {
+	if c.Yes {
+		return nil
+	}
+	if !isInteractive() {
+		return errors.New("confirmation required but stdin is not a terminal; rerun with --yes")
+	}
+	fmt.Fprintf(os.Stderr, "%s Type %q to continue: ", question, word)
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil || strings.TrimSpace(answer) != word {
+		return errAborted
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"filippo.io/age"
+	agepkg "github.com/andreweick/agepad/age"
+	"github.com/andreweick/agepad/derived"
+	"github.com/andreweick/agepad/filetype"
+	"github.com/andreweick/agepad/model"
+	"github.com/andreweick/agepad/validator"
+	"github.com/andreweick/agepad/walk"
+	"github.com/urfave/cli/v3"
+)
+
+func redactTreeCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "redact-tree",
+		Usage: "Write an unencrypted catalog of a secrets tree: key names and comments, every value redacted",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  "root",
+				Usage: "Directory tree of .age files to catalog",
+				Value: ".",
+			},
+			&cli.StringFlag{
+				Name:     "out",
+				Usage:    "Catalog directory; app.env.age becomes app.env with redacted values",
+				Required: true,
+			},
+			&cli.StringFlag{
+				Name:  "identities",
+				Usage: "Path to AGE identities",
+				Value: defaultIdentitiesPath(),
+			},
+			&cli.BoolFlag{
+				Name:  "check",
+				Usage: "Write nothing; fail if the catalog is missing files or out of date (for CI)",
+			},
+		},
+		Action: runRedactTree,
+	}
+}
+
+func runRedactTree(ctx context.Context, cmd *cli.Command) error {
+	cfg := model.RedactTreeConfig{
+		Root:           cmd.String("root"),
+		OutDir:         cmd.String("out"),
+		IdentitiesPath: cmd.String("identities"),
+		Check:          cmd.Bool("check"),
+	}
+	var err error
+	if cfg.TypeRules, err = filetype.ParseRules(cmd.StringSlice("type-map")); err != nil {
+		return err
+	}
+
+	ids, err := agepkg.LoadIdentities(cfg.IdentitiesPath)
+	if err != nil {
+		return err
+	}
+	files, err := walk.AgeFiles(cfg.Root)
+	if err != nil {
+		return err
+	}
+
+	var failed, stale []string
+	written := 0
+	for _, f := range files {
+		rel, err := filepath.Rel(cfg.Root, f)
+		if err != nil {
+			return err
+		}
+		catalog, err := redactFile(f, rel, ids, cfg.TypeRules)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "redact-tree: %s: %v\n", f, err)
+			failed = append(failed, rel)
+			continue
+		}
+		out := filepath.Join(cfg.OutDir, strings.TrimSuffix(rel, filepath.Ext(rel)))
+		old, err := os.ReadFile(out)
+		if err == nil && bytes.Equal(old, catalog) {
+			continue
+		}
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("redact-tree: %w", err)
+		}
+		if cfg.Check {
+			stale = append(stale, out)
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(out), 0o755); err != nil {
+			return fmt.Errorf("redact-tree: %w", err)
+		}
+		if err := os.WriteFile(out, catalog, 0o644); err != nil {
+			return fmt.Errorf("redact-tree: %w", err)
+		}
+		written++
+	}
+
+	for _, s := range stale {
+		fmt.Println(s)
+	}
+	fmt.Fprintf(os.Stderr, "redact-tree: %d file(s), %d updated, %d out of date, %d failed\n",
+		len(files), written, len(stale), len(failed))
+	switch {
+	case len(failed) > 0:
+		return fmt.Errorf("redact-tree: %d file(s) could not be cataloged", len(failed))
+	case len(stale) > 0:
+		return fmt.Errorf("redact-tree: catalog in %s is out of date; rerun without --check", cfg.OutDir)
+	}
+	return nil
+}
+
+// redactFile decrypts path and returns its catalog copy (see
+// derived.CatalogEntry).
+func redactFile(path, rel string, ids []age.Identity, rules []model.TypeRule) ([]byte, error) {
+	cipher, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	plain, err := agepkg.Decrypt(cipher, ids)
+	if err != nil {
+		return nil, err
+	}
+	return derived.CatalogEntry(filepath.ToSlash(rel), plain, validator.Format(path, plain, rules))
+}
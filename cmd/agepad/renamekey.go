@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+
+	agepkg "github.com/andreweick/agepad/age"
+	"github.com/andreweick/agepad/buildinfo"
+	"github.com/andreweick/agepad/convert"
+	"github.com/andreweick/agepad/keyops"
+	"github.com/andreweick/agepad/model"
+	"github.com/andreweick/agepad/walk"
+	"github.com/urfave/cli/v3"
+)
+
+func renameKeyCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "rename-key",
+		Usage:     "Rename a key in every encrypted .env file under a tree",
+		ArgsUsage: "<OLD> <NEW>",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  "root",
+				Usage: "Root directory to scan for *.age files",
+				Value: ".",
+			},
+			&cli.BoolFlag{
+				Name:  "dry-run",
+				Usage: "Only report which files would change",
+			},
+			&cli.StringFlag{
+				Name:  "recipients-file",
+				Usage: "Recipients to re-encrypt changed files to",
+				Value: defaultRecipientsFile,
+			},
+			&cli.StringFlag{
+				Name:  "identities",
+				Usage: "Path to AGE identities",
+				Value: defaultIdentitiesPath(),
+			},
+			&cli.BoolFlag{
+				Name:  "armor",
+				Usage: "Write ASCII-armored .age output",
+				Value: buildinfo.ArmorDefault,
+			},
+			yesFlag,
+			confirmPromptFlag,
+		},
+		Action: runRenameKey,
+	}
+}
+
+func runRenameKey(ctx context.Context, cmd *cli.Command) error {
+	if cmd.Args().Len() != 2 {
+		return fmt.Errorf("rename-key usage: %s rename-key <OLD> <NEW> [--root dir]", appName)
+	}
+	cfg := model.RenameKeyConfig{
+		OldKey:         cmd.Args().Get(0),
+		NewKey:         cmd.Args().Get(1),
+		Root:           cmd.String("root"),
+		DryRun:         cmd.Bool("dry-run"),
+		RecipientsFile: cmd.String("recipients-file"),
+		IdentitiesPath: cmd.String("identities"),
+		Armor:          cmd.Bool("armor"),
+		Confirm:        confirmFromFlags(cmd),
+	}
+
+	ids, err := agepkg.LoadIdentities(cfg.IdentitiesPath)
+	if err != nil {
+		return err
+	}
+	files, err := walk.AgeFiles(cfg.Root)
+	if err != nil {
+		return err
+	}
+
+	// Decrypt and rename everything in memory first so a collision in any
+	// file aborts before a single write.
+	type change struct {
+		path string
+		out  string
+	}
+	var changes []change
+	fail := 0
+	for _, f := range files {
+		if ft, err := convert.FormatFromPath(f); err == nil && ft != convert.Env {
+			continue // structured formats are not renamed line-wise
+		}
+		plain, err := agepkg.DecryptToMemory(f, ids)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "rename-key: decrypt failed for %s: %v\n", f, err)
+			fail++
+			continue
+		}
+		if !slices.Contains(keyops.Keys(plain), cfg.OldKey) {
+			continue
+		}
+		out, err := keyops.Rename(plain, cfg.OldKey, cfg.NewKey)
+		if err != nil {
+			return fmt.Errorf("rename-key: %s: %w", f, err)
+		}
+		changes = append(changes, change{path: f, out: out})
+	}
+	if fail > 0 {
+		return fmt.Errorf("rename-key: %d file(s) could not be decrypted; nothing was written", fail)
+	}
+	if len(changes) == 0 {
+		fmt.Printf("rename-key: %s not found under %s\n", cfg.OldKey, cfg.Root)
+		return nil
+	}
+
+	for _, c := range changes {
+		fmt.Printf("  %s\n", c.path)
+	}
+	if cfg.DryRun {
+		fmt.Printf("rename-key: would rename %s -> %s in %d file(s)\n", cfg.OldKey, cfg.NewKey, len(changes))
+		return nil
+	}
+	recips, err := agepkg.LoadRecipients(cfg.RecipientsFile)
+	if err != nil {
+		return err
+	}
+	writes := make([]walk.Write, len(changes))
+	for i, c := range changes {
+		writes[i] = walk.Write{Path: c.path, Size: agepkg.EncryptedSize(int64(len(c.out)), len(recips), cfg.Armor)}
+	}
+	if err := walk.Preflight(writes, 1); err != nil {
+		return fmt.Errorf("rename-key: %w; nothing was written", err)
+	}
+	if err := confirm(cfg.Confirm, fmt.Sprintf("Rename %s -> %s in %d file(s)?",
+		cfg.OldKey, cfg.NewKey, len(changes))); err != nil {
+		return fmt.Errorf("rename-key: %w", err)
+	}
+
+	for _, c := range changes {
+		if err := encryptWrite(c.path, []byte(c.out), recips, cfg.Armor); err != nil {
+			return fmt.Errorf("rename-key: write %s: %w", c.path, err)
+		}
+		fmt.Printf("rename-key: updated %s\n", c.path)
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	agepkg "github.com/andreweick/agepad/age"
+	"github.com/andreweick/agepad/convert"
+	"github.com/andreweick/agepad/filetype"
+	"github.com/andreweick/agepad/model"
+	"github.com/andreweick/agepad/render"
+	"github.com/andreweick/agepad/validator"
+	"github.com/urfave/cli/v3"
+)
+
+func renderCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "render",
+		Usage: "Fill a Go text/template with values from an encrypted file and write the result to stdout or a file descriptor",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:     "template",
+				Usage:    "Template file, e.g. app.conf.tmpl ({{ .db.password }}; see the README for helpers)",
+				Required: true,
+			},
+			&cli.StringFlag{
+				Name:     "secrets",
+				Usage:    "Encrypted env, JSON, YAML, or TOML file holding the values",
+				Required: true,
+			},
+			&cli.IntFlag{
+				Name:  "out-fd",
+				Usage: "File descriptor to write to instead of stdout, e.g. 3 with 3>/run/app/app.conf",
+				Value: 1,
+			},
+			&cli.StringFlag{
+				Name:  "identities",
+				Usage: "Path to AGE identities",
+				Value: defaultIdentitiesPath(),
+			},
+		},
+		Action: runRender,
+	}
+}
+
+func runRender(ctx context.Context, cmd *cli.Command) error {
+	cfg := model.RenderConfig{
+		TemplatePath:   cmd.String("template"),
+		SecretsPath:    cmd.String("secrets"),
+		OutFD:          cmd.Int("out-fd"),
+		IdentitiesPath: cmd.String("identities"),
+		Stanzas:        stanzaPolicy(cmd),
+	}
+	var err error
+	if cfg.TypeRules, err = filetype.ParseRules(cmd.StringSlice("type-map")); err != nil {
+		return err
+	}
+	if cfg.OutFD < 1 {
+		return fmt.Errorf("render: --out-fd %d is not an output descriptor", cfg.OutFD)
+	}
+	text, err := os.ReadFile(cfg.TemplatePath)
+	if err != nil {
+		return fmt.Errorf("render: %w", err)
+	}
+
+	if err := checkStanzas(cfg.SecretsPath, cfg.Stanzas); err != nil {
+		return err
+	}
+	ids, err := agepkg.LoadIdentities(cfg.IdentitiesPath)
+	if err != nil {
+		return err
+	}
+	plain, err := agepkg.DecryptToMemory(cfg.SecretsPath, ids)
+	if err != nil {
+		return passphraseHint(cfg.SecretsPath, err)
+	}
+	if plain, _, err = openShared(plain); err != nil {
+		return err
+	}
+	format := validator.Format(cfg.SecretsPath, plain, cfg.TypeRules)
+	if format == "" {
+		return fmt.Errorf("render: %s: unknown format; name it with --type-map", cfg.SecretsPath)
+	}
+	data, err := convert.Decode(plain, convert.Format(format))
+	if err != nil {
+		return fmt.Errorf("render: %s: %w", cfg.SecretsPath, err)
+	}
+
+	out, err := render.Execute(cfg.TemplatePath, string(text), data)
+	if err != nil {
+		return fmt.Errorf("render: %w", err)
+	}
+	w := os.Stdout
+	if cfg.OutFD != 1 {
+		w = os.NewFile(uintptr(cfg.OutFD), fmt.Sprintf("fd %d", cfg.OutFD))
+		defer w.Close()
+	}
+	if _, err := w.Write(out); err != nil {
+		return fmt.Errorf("render: %w", err)
+	}
+	return nil
+}
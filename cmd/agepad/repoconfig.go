@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	agepkg "github.com/andreweick/agepad/age"
+	"github.com/andreweick/agepad/config"
+)
+
+// sealedConfigs holds each .agepad.toml.age decrypted so far, so the root
+// command and its subcommand ask a hardware identity only once.
+var sealedConfigs = map[string]config.Config{}
+
+// withSealed lays the settings of the repository's .agepad.toml.age, if it
+// has one, over c (the plain config files), decrypting it with identities.
+// A file this user cannot decrypt is skipped with a warning, so people
+// outside the team keep working with the plain files.
+func withSealed(c config.Config, identities string) (config.Config, error) {
+	path := config.FindSealed(".")
+	if path == "" {
+		return c, nil
+	}
+	sealed, ok := sealedConfigs[path]
+	if !ok {
+		plain, err := decryptConfig(path, identities)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "warning: config: %s not applied: %v\n", path, err)
+		} else if sealed, err = config.Parse(path, plain); err != nil {
+			return c, err
+		}
+		sealedConfigs[path] = sealed
+	}
+	return c.Merge(sealed), nil
+}
+
+// decryptConfig decrypts the encrypted repository config at path.
+func decryptConfig(path, identities string) ([]byte, error) {
+	cipher, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	ids, err := agepkg.LoadIdentities(identities)
+	if err != nil {
+		return nil, fmt.Errorf("no identities at %s", identities)
+	}
+	plain, err := agepkg.Decrypt(cipher, ids)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(plain), nil
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+
+	"filippo.io/age"
+	agepkg "github.com/andreweick/agepad/age"
+	"github.com/andreweick/agepad/directory"
+	"github.com/andreweick/agepad/model"
+	"github.com/urfave/cli/v3"
+)
+
+// directoryFlag names where people and groups are looked up; the
+// directory setting in a config file fills it in.
+var directoryFlag = &cli.StringFlag{
+	Name:  "directory",
+	Usage: "Where users and groups are looked up: a TOML file, https:// roster, ldap[s]://host/base-dn, or scim+https:// endpoint",
+}
+
+func resolveCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "resolve",
+		Usage: "Look users and groups up in the directory and print (or write) the recipients file lines for them",
+		Flags: []cli.Flag{
+			directoryFlag,
+			&cli.StringSliceFlag{
+				Name:  "user",
+				Usage: "User name to resolve (repeatable)",
+			},
+			&cli.StringSliceFlag{
+				Name:  "group",
+				Usage: "Group whose members to resolve (repeatable)",
+			},
+			&cli.StringFlag{
+				Name:  "out",
+				Usage: "Recipients file to write instead of printing, e.g. for rotate --to",
+			},
+			&cli.BoolFlag{
+				Name:  "force",
+				Usage: "Replace --out if it already exists",
+			},
+		},
+		Action: runResolve,
+	}
+}
+
+func runResolve(ctx context.Context, cmd *cli.Command) error {
+	cfg := model.ResolveConfig{
+		Directory: cmd.String("directory"),
+		Users:     cmd.StringSlice("user"),
+		Groups:    cmd.StringSlice("group"),
+		OutPath:   cmd.String("out"),
+		Force:     cmd.Bool("force"),
+	}
+	if len(cfg.Users)+len(cfg.Groups) == 0 {
+		return fmt.Errorf("resolve: pass --user or --group")
+	}
+	entries, _, err := resolveRecipients(ctx, cfg.Directory, cfg.Users, cfg.Groups)
+	if err != nil {
+		return fmt.Errorf("resolve: %w", err)
+	}
+	var b strings.Builder
+	for _, e := range entries {
+		b.WriteString(e.String() + "\n")
+	}
+	if cfg.OutPath == "" {
+		fmt.Print(b.String())
+		return nil
+	}
+	if _, err := os.Stat(cfg.OutPath); err == nil && !cfg.Force {
+		return fmt.Errorf("resolve: %s exists; pass --force to replace it", cfg.OutPath)
+	} else if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("resolve: %w", err)
+	}
+	header := fmt.Sprintf("# Resolved by agepad resolve from %s; rerun it rather than editing by hand.\n", cfg.Directory)
+	if err := agepkg.WriteRetrying(cfg.OutPath, []byte(header+b.String()), writeRetry); err != nil {
+		return fmt.Errorf("resolve: %w", err)
+	}
+	fmt.Fprintf(os.Stderr, "resolve: wrote %d recipient(s) to %s\n", len(entries), cfg.OutPath)
+	return nil
+}
+
+// resolveRecipients looks users and the members of groups up in the
+// directory at spec, and parses the recipients found.
+func resolveRecipients(ctx context.Context, spec string, users, groups []string) ([]directory.Entry, []age.Recipient, error) {
+	if spec == "" {
+		return nil, nil, fmt.Errorf("no directory to look users up in; pass --directory or set directory in .agepad.toml")
+	}
+	r, err := directory.Open(spec)
+	if err != nil {
+		return nil, nil, err
+	}
+	entries, err := directory.Resolve(ctx, r, users, groups)
+	if err != nil {
+		return nil, nil, err
+	}
+	var recips []age.Recipient
+	for _, e := range entries {
+		rs, err := agepkg.ParseRecipients(strings.NewReader(e.Line))
+		if err != nil {
+			return nil, nil, fmt.Errorf("user %s: %s has a recipient that cannot be used: %w", e.User, spec, err)
+		}
+		recips = append(recips, rs...)
+	}
+	return entries, recips, nil
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"filippo.io/age"
+	agepkg "github.com/andreweick/agepad/age"
+)
+
+// writeRetry retries transient write failures (a busy network mount, a
+// scanner holding the file) instead of failing the whole subcommand.
+var writeRetry = agepkg.RetryPolicy{
+	Attempts: agepkg.DefaultRetry.Attempts,
+	Backoff:  agepkg.DefaultRetry.Backoff,
+	OnRetry: func(attempt int, wait time.Duration, err error) {
+		fmt.Fprintf(os.Stderr, "agepad: %v; retrying in %s (attempt %d of %d)\n",
+			err, wait, attempt+1, agepkg.DefaultRetry.Attempts)
+	},
+}
+
+// encryptWrite encrypts b and writes it atomically to path, retrying
+// transient failures with backoff.
+func encryptWrite(path string, b []byte, recips []age.Recipient, armor bool) error {
+	return agepkg.EncryptWriteRetrying(path, b, recips, armor, writeRetry)
+}
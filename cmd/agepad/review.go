@@ -0,0 +1,305 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	agepkg "github.com/andreweick/agepad/age"
+	"github.com/andreweick/agepad/audit"
+	"github.com/andreweick/agepad/model"
+	"github.com/andreweick/agepad/review"
+	"github.com/andreweick/agepad/stats"
+	"github.com/andreweick/agepad/walk"
+	"github.com/urfave/cli/v3"
+	"golang.org/x/crypto/ssh"
+)
+
+func ackCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "ack",
+		Usage: "Decrypt a file and record a signed acknowledgement of this version in the audit log",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:     "file",
+				Usage:    "Encrypted file to acknowledge",
+				Required: true,
+			},
+			&cli.StringFlag{
+				Name:  "rules",
+				Usage: "Review rules file; the acked path is relative to its directory",
+				Value: review.DefaultRulesFile,
+			},
+			&cli.StringFlag{
+				Name:  "recipients-file",
+				Usage: "Recipients file that lists your SSH key with its label",
+				Value: defaultRecipientsFile,
+			},
+			&cli.StringFlag{
+				Name:  "identities",
+				Usage: "Path to AGE identities",
+				Value: defaultIdentitiesPath(),
+			},
+			&cli.StringFlag{
+				Name:  "sign-key",
+				Usage: "SSH private key to sign the ack with",
+				Value: defaultSignKey(),
+			},
+			&cli.StringFlag{
+				Name:  "audit-log",
+				Usage: "Audit log to record the ack in (commit a shared one for verify --strict in CI)",
+				Value: audit.DefaultPath(),
+			},
+		},
+		Action: runAck,
+	}
+}
+
+func runAck(ctx context.Context, cmd *cli.Command) error {
+	cfg := model.AckConfig{
+		FilePath:       cmd.String("file"),
+		RulesFile:      cmd.String("rules"),
+		RecipientsFile: cmd.String("recipients-file"),
+		IdentitiesPath: cmd.String("identities"),
+		SignKeyPath:    cmd.String("sign-key"),
+		AuditLog:       cmd.String("audit-log"),
+	}
+	signer, err := loadSigner(cfg.SignKeyPath)
+	if err != nil {
+		return fmt.Errorf("ack: %w", err)
+	}
+	label, err := signerLabel(cfg.RecipientsFile, signer.PublicKey())
+	if err != nil {
+		return fmt.Errorf("ack: %w", err)
+	}
+	rel, err := reviewPath(cfg.RulesFile, cfg.FilePath)
+	if err != nil {
+		return fmt.Errorf("ack: %w", err)
+	}
+
+	// Only someone who can read this version may acknowledge it.
+	cipher, err := os.ReadFile(cfg.FilePath)
+	if err != nil {
+		return err
+	}
+	ids, err := agepkg.LoadIdentities(cfg.IdentitiesPath)
+	if err != nil {
+		return err
+	}
+	if _, err := agepkg.Decrypt(cipher, ids); err != nil {
+		return fmt.Errorf("ack: %w", err)
+	}
+
+	event, err := review.Ack(signer, rel, label, review.Digest(cipher))
+	if err != nil {
+		return fmt.Errorf("ack: %w", err)
+	}
+	if err := (audit.Log{Path: cfg.AuditLog}).Record(event); err != nil {
+		return fmt.Errorf("ack: %w", err)
+	}
+	fmt.Fprintf(os.Stderr, "Acknowledged %s as %s in %s.\n", rel, label, cfg.AuditLog)
+	return nil
+}
+
+func verifyCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "verify",
+		Usage: "Check every .age file's header and stanza policy; --strict also requires the acks the review rules ask for",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  "root",
+				Usage: "Directory tree to verify",
+				Value: ".",
+			},
+			&cli.BoolFlag{
+				Name:  "strict",
+				Usage: "Fail files that lack an ack of their current version from each required reviewer",
+			},
+			&cli.StringFlag{
+				Name:  "rules",
+				Usage: "Review rules file (default: .agepad-reviewers under --root)",
+			},
+			&cli.StringFlag{
+				Name:  "recipients-file",
+				Usage: "Recipients file that labels the reviewers' SSH keys",
+				Value: defaultRecipientsFile,
+			},
+			&cli.StringFlag{
+				Name:  "audit-log",
+				Usage: "Audit log holding the acks",
+				Value: audit.DefaultPath(),
+			},
+		},
+		Action: runVerify,
+	}
+}
+
+func runVerify(ctx context.Context, cmd *cli.Command) error {
+	cfg := model.VerifyConfig{
+		Root:           cmd.String("root"),
+		Strict:         cmd.Bool("strict"),
+		RulesFile:      cmd.String("rules"),
+		RecipientsFile: cmd.String("recipients-file"),
+		AuditLog:       cmd.String("audit-log"),
+		Stanzas:        stanzaPolicy(cmd),
+	}
+	if cfg.RulesFile == "" {
+		cfg.RulesFile = filepath.Join(cfg.Root, review.DefaultRulesFile)
+	}
+	job, err := startJob(cmd)
+	if err != nil {
+		return fmt.Errorf("verify: %w", err)
+	}
+	files, err := walk.AgeFiles(cfg.Root)
+	if err != nil {
+		return err
+	}
+
+	var (
+		rules  review.Rules
+		events []audit.Event
+		keys   map[string]ssh.PublicKey
+	)
+	if cfg.Strict {
+		if rules, err = review.LoadRules(cfg.RulesFile); err != nil {
+			return err
+		}
+		if events, err = (audit.Log{Path: cfg.AuditLog}).Read(); err != nil {
+			return err
+		}
+		b, err := os.ReadFile(cfg.RecipientsFile)
+		if err != nil {
+			return fmt.Errorf("verify: %w", err)
+		}
+		ls, err := agepkg.ParseLabeledRecipients(strings.NewReader(string(b)))
+		if err != nil {
+			return fmt.Errorf("verify: %s: %w", cfg.RecipientsFile, err)
+		}
+		keys = review.SigningKeys(ls)
+	}
+
+	problems := 0
+	for _, f := range files {
+		cipher, err := os.ReadFile(f)
+		if err != nil {
+			return err
+		}
+		h, err := agepkg.InspectHeaderBytes(cipher)
+		if err == nil {
+			err = agepkg.CheckStanzas(h, cfg.Stanzas)
+		}
+		if err != nil {
+			fmt.Printf("%s: %v\n", f, err)
+			problems++
+			continue
+		}
+		if !cfg.Strict {
+			continue
+		}
+		rel, err := reviewPath(cfg.RulesFile, f)
+		if err != nil {
+			return err
+		}
+		required := rules.Required(rel)
+		missing := review.Missing(required, rel, review.Digest(cipher), events, keys)
+		if len(missing) == 0 {
+			continue
+		}
+		problems++
+		var unsignable []string
+		for _, l := range missing {
+			if keys[l] == nil {
+				unsignable = append(unsignable, l)
+			}
+		}
+		fmt.Printf("%s: needs an ack of this version from %s", f, strings.Join(missing, ", "))
+		if len(unsignable) > 0 {
+			fmt.Printf(" (no SSH recipient labeled %s to sign one)", strings.Join(unsignable, ", "))
+		}
+		fmt.Println()
+	}
+
+	fmt.Fprintf(os.Stderr, "verify: %d file(s), %d problem(s)\n", len(files), problems)
+	recordStats(stats.Verify, cfg.Root, len(files))
+	job.Done(appName+" verify", fmt.Sprintf("%d file(s), %d problem(s)", len(files), problems))
+	if problems > 0 {
+		return errors.New("verify failed")
+	}
+	return nil
+}
+
+func defaultSignKey() string {
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".ssh", "id_ed25519")
+}
+
+// loadSigner reads an SSH private key, asking for its passphrase if it
+// has one.
+func loadSigner(path string) (ssh.Signer, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	signer, err := ssh.ParsePrivateKey(b)
+	var missing *ssh.PassphraseMissingError
+	if errors.As(err, &missing) {
+		pass, perr := agepkg.ReadPassphrase("Enter passphrase for SSH key " + path + ": ")
+		if perr != nil {
+			return nil, perr
+		}
+		defer clear(pass)
+		signer, err = ssh.ParsePrivateKeyWithPassphrase(b, pass)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return signer, nil
+}
+
+// signerLabel returns the label the recipients file gives pub.
+func signerLabel(recipientsFile string, pub ssh.PublicKey) (string, error) {
+	b, err := os.ReadFile(recipientsFile)
+	if err != nil {
+		return "", err
+	}
+	ls, err := agepkg.ParseLabeledRecipients(strings.NewReader(string(b)))
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", recipientsFile, err)
+	}
+	var labels []string
+	for label, k := range review.SigningKeys(ls) {
+		if slices.Equal(k.Marshal(), pub.Marshal()) {
+			labels = append(labels, label)
+		}
+	}
+	switch len(labels) {
+	case 0:
+		return "", fmt.Errorf("%s does not list SSH key %s with a label", recipientsFile, ssh.FingerprintSHA256(pub))
+	case 1:
+		return labels[0], nil
+	}
+	slices.Sort(labels)
+	return "", fmt.Errorf("%s lists SSH key %s under several labels (%s)", recipientsFile, ssh.FingerprintSHA256(pub), strings.Join(labels, ", "))
+}
+
+// reviewPath returns file relative to the rules file's directory, with
+// forward slashes, as rules and acks name it.
+func reviewPath(rulesFile, file string) (string, error) {
+	dir, err := filepath.Abs(filepath.Dir(rulesFile))
+	if err != nil {
+		return "", err
+	}
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		return "", err
+	}
+	rel, err := filepath.Rel(dir, abs)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%s is outside %s, where the review rules apply", file, dir)
+	}
+	return filepath.ToSlash(rel), nil
+}
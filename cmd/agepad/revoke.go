@@ -0,0 +1,266 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	agepkg "github.com/andreweick/agepad/age"
+	"github.com/andreweick/agepad/audit"
+	"github.com/andreweick/agepad/model"
+	"github.com/andreweick/agepad/validator"
+	"github.com/andreweick/agepad/walk"
+	"github.com/charmbracelet/x/term"
+	"github.com/urfave/cli/v3"
+)
+
+// minRevokeLength keeps revoke-value from rewriting short, common text
+// ("true", a port) throughout a tree.
+const minRevokeLength = 8
+
+func revokeValueCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "revoke-value",
+		Usage: "Find every file holding a leaked value and replace it everywhere in one all-or-nothing pass",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  "root",
+				Usage: "Directory tree of .age files to search",
+				Value: ".",
+			},
+			&cli.BoolFlag{
+				Name:     "match-value-from-stdin",
+				Usage:    "Read the compromised value from stdin (first line), or ask for it on a terminal",
+				Required: true,
+			},
+			&cli.BoolFlag{
+				Name:  "replace-from-stdin",
+				Usage: "Read the new value from stdin (second line), or ask for it, and replace; without it only the files are listed",
+			},
+			&cli.StringFlag{
+				Name:  "recipients-file",
+				Usage: "Recipients to re-encrypt changed files to",
+				Value: defaultRecipientsFile,
+			},
+			&cli.StringFlag{
+				Name:  "identities",
+				Usage: "Path to AGE identities",
+				Value: defaultIdentitiesPath(),
+			},
+			&cli.StringFlag{
+				Name:  "audit-log",
+				Usage: "Audit log recording each rewritten file (never the values)",
+				Value: audit.DefaultPath(),
+			},
+			yesFlag,
+			confirmPromptFlag,
+		},
+		Action: runRevokeValue,
+	}
+}
+
+// revoked is a file holding the value: its old ciphertext, kept to roll
+// back, and its replacement.
+type revoked struct {
+	path        string
+	keys        []string
+	occurrences int
+	old, new    []byte
+}
+
+func runRevokeValue(ctx context.Context, cmd *cli.Command) error {
+	cfg := model.RevokeValueConfig{
+		Root:           cmd.String("root"),
+		Replace:        cmd.Bool("replace-from-stdin"),
+		RecipientsFile: cmd.String("recipients-file"),
+		IdentitiesPath: cmd.String("identities"),
+		AuditLog:       cmd.String("audit-log"),
+		Confirm:        confirmFromFlags(cmd),
+	}
+	var err error
+	if cfg.Validation, cfg.TypeRules, err = validationFromFlags(cmd); err != nil {
+		return err
+	}
+
+	oldValue, newValue, err := readRevokeValues(cfg.Replace)
+	if err != nil {
+		return fmt.Errorf("revoke-value: %w", err)
+	}
+	if len([]rune(oldValue)) < minRevokeLength {
+		return fmt.Errorf("revoke-value: the value is shorter than %d characters; too common to replace as text", minRevokeLength)
+	}
+	if cfg.Replace && newValue == oldValue {
+		return errors.New("revoke-value: the new value is the compromised one")
+	}
+
+	ids, err := agepkg.LoadIdentities(cfg.IdentitiesPath)
+	if err != nil {
+		return err
+	}
+	files, err := walk.AgeFiles(cfg.Root)
+	if err != nil {
+		return err
+	}
+
+	// Decrypt everything first; any file that cannot be read could hold the
+	// value too, so it stops the run before a write.
+	var found []revoked
+	var plains []string
+	for _, f := range files {
+		cipher, err := os.ReadFile(f)
+		if err != nil {
+			return err
+		}
+		plain, err := agepkg.Decrypt(cipher, ids)
+		if err != nil {
+			return fmt.Errorf("revoke-value: %s: %w; nothing was written", f, err)
+		}
+		n := strings.Count(plain, oldValue)
+		if n == 0 {
+			continue
+		}
+		r := revoked{path: f, occurrences: n, old: cipher}
+		if values, err := keyValues(f, plain, cfg.TypeRules); err == nil {
+			for k, v := range values {
+				if strings.Contains(v, oldValue) {
+					r.keys = append(r.keys, k)
+				}
+			}
+			slices.Sort(r.keys)
+		}
+		found = append(found, r)
+		plains = append(plains, plain)
+	}
+	if len(found) == 0 {
+		fmt.Fprintf(os.Stderr, "revoke-value: not found in %d file(s) under %s\n", len(files), cfg.Root)
+		return nil
+	}
+	if !cfg.Replace {
+		printRevoked(found)
+		fmt.Fprintf(os.Stderr, "revoke-value: found in %d of %d file(s); add --replace-from-stdin to replace it\n", len(found), len(files))
+		return nil
+	}
+
+	// Build and check every replacement before writing any.
+	recips, err := agepkg.LoadRecipients(cfg.RecipientsFile)
+	if err != nil {
+		return err
+	}
+	writes := make([]walk.Write, len(found))
+	for i := range found {
+		r := &found[i]
+		out := strings.ReplaceAll(plains[i], oldValue, newValue)
+		if sev, err := validator.Check(r.path, out, cfg.Validation, cfg.TypeRules); err != nil {
+			if sev != model.SeverityWarn {
+				return fmt.Errorf("revoke-value: %s would no longer parse: %w; nothing was written", r.path, err)
+			}
+			fmt.Fprintln(os.Stderr, "warning:", err)
+		}
+		h, err := agepkg.InspectHeaderBytes(r.old)
+		if err != nil {
+			return fmt.Errorf("revoke-value: %s: %w", r.path, err)
+		}
+		if r.new, err = agepkg.EncryptToMemory([]byte(out), recips, h.Armored); err != nil {
+			return fmt.Errorf("revoke-value: %s: %w", r.path, err)
+		}
+		writes[i] = walk.Write{Path: r.path, Size: int64(len(r.new))}
+	}
+	if err := walk.Preflight(writes, 1); err != nil {
+		return fmt.Errorf("revoke-value: %w; nothing was written", err)
+	}
+	printRevoked(found)
+	if err := confirm(cfg.Confirm, fmt.Sprintf("Replace the value in %d file(s)?", len(found))); err != nil {
+		return fmt.Errorf("revoke-value: %w", err)
+	}
+
+	for i, r := range found {
+		if err := agepkg.WriteRetrying(r.path, r.new, writeRetry); err != nil {
+			return fmt.Errorf("revoke-value: write %s: %w; %s", r.path, err, rollBack(found[:i]))
+		}
+	}
+	for _, r := range found {
+		event := audit.Event{
+			Action: "revoke-value",
+			File:   r.path,
+			Details: map[string]string{
+				"keys":        strings.Join(r.keys, ","),
+				"occurrences": strconv.Itoa(r.occurrences),
+			},
+		}
+		if err := (audit.Log{Path: cfg.AuditLog}).Record(event); err != nil {
+			fmt.Fprintf(os.Stderr, "revoke-value: %v\n", err)
+		}
+	}
+	fmt.Fprintf(os.Stderr, "revoke-value: replaced in %d file(s); now revoke the old value at its issuer\n", len(found))
+	return nil
+}
+
+// rollBack restores the files already rewritten and says how that went.
+func rollBack(done []revoked) string {
+	var failed []string
+	for _, r := range done {
+		if err := agepkg.WriteRetrying(r.path, r.old, writeRetry); err != nil {
+			failed = append(failed, r.path)
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Sprintf("could not restore %s; they hold the new value", strings.Join(failed, ", "))
+	}
+	return fmt.Sprintf("restored the %d file(s) already rewritten", len(done))
+}
+
+// printRevoked lists the files for the incident report.
+func printRevoked(found []revoked) {
+	for _, r := range found {
+		keys := strings.Join(r.keys, ", ")
+		if keys == "" {
+			keys = "-"
+		}
+		fmt.Printf("%s\t%d occurrence(s)\t%s\n", r.path, r.occurrences, keys)
+	}
+}
+
+// readRevokeValues reads the compromised value and, when replacing, the
+// new one: one per line from piped stdin, or asked for without echo on a
+// terminal.
+func readRevokeValues(replace bool) (oldValue, newValue string, err error) {
+	if term.IsTerminal(os.Stdin.Fd()) {
+		b, err := agepkg.ReadPassphrase("Compromised value: ")
+		if err != nil {
+			return "", "", err
+		}
+		oldValue = string(b)
+		if replace {
+			if b, err = agepkg.ReadPassphrase("New value: "); err != nil {
+				return "", "", err
+			}
+			newValue = string(b)
+		}
+		return oldValue, newValue, nil
+	}
+	want := 1
+	if replace {
+		want = 2
+	}
+	var lines []string
+	sc := bufio.NewScanner(io.LimitReader(os.Stdin, 1<<20))
+	for len(lines) < want && sc.Scan() {
+		lines = append(lines, strings.TrimSuffix(sc.Text(), "\r"))
+	}
+	if err := sc.Err(); err != nil {
+		return "", "", err
+	}
+	if len(lines) < want {
+		return "", "", fmt.Errorf("expected %d line(s) on stdin: the compromised value, then the new one", want)
+	}
+	if replace {
+		newValue = lines[1]
+	}
+	return lines[0], newValue, nil
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	agepkg "github.com/andreweick/agepad/age"
+	"github.com/andreweick/agepad/model"
+	"github.com/andreweick/agepad/platform"
+	"github.com/andreweick/agepad/rotation"
+	"github.com/andreweick/agepad/walk"
+)
+
+// backupExt names the copy rotate --backup keeps next to each file.
+const backupExt = ".bak"
+
+// backupPath is where rotate keeps path's original ciphertext: beside it
+// as <name>.age.bak, or under dir at its path relative to root.
+func backupPath(root, path, dir string) (string, error) {
+	if dir == "" {
+		return path + backupExt, nil
+	}
+	rel, err := filepath.Rel(root, path)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return "", fmt.Errorf("%s is outside %s", path, root)
+	}
+	return filepath.Join(dir, rel), nil
+}
+
+// errBackupExists reports a backup left by an earlier rotation, which may
+// be the only copy of the originals.
+var errBackupExists = errors.New("backup already exists; restore it with rotate --restore or move it away first (--resume keeps it)")
+
+// writeBackup copies the ciphertext about to be replaced to bak, with the
+// original's permissions. A resumed rotation keeps a backup an earlier run
+// made, since the file may already hold the new ciphertext; otherwise an
+// existing backup is never replaced.
+func writeBackup(path, bak string, cipher []byte, resume bool) error {
+	if _, err := os.Stat(bak); err == nil {
+		if resume {
+			return nil
+		}
+		return fmt.Errorf("%s: %w", bak, errBackupExists)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(bak), 0o700); err != nil {
+		return err
+	}
+	if err := agepkg.AtomicWrite(bak, cipher); err != nil {
+		return err
+	}
+	return os.Chmod(bak, info.Mode().Perm())
+}
+
+// checkBackupDir refuses a backup directory inside root: the backups keep
+// their .age names, so later runs over root would take them for secrets.
+func checkBackupDir(root, dir string) error {
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return err
+	}
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+	if rel, err := filepath.Rel(absRoot, absDir); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("--backup-dir %s is inside --root %s, where rotate, scan, and index would read the backups as secrets; choose a directory outside it", dir, root)
+	}
+	return nil
+}
+
+// preflightBackups checks that no backup would replace one an earlier run
+// left (unless resuming) and that the backups fit: unlike the temp copies
+// of the rewrites, every one of them stays.
+func preflightBackups(plan *rotation.Plan, dir string, resume bool) error {
+	if dir != "" {
+		if err := checkBackupDir(plan.Root, dir); err != nil {
+			return err
+		}
+	}
+	var total int64
+	for _, f := range plan.Files {
+		info, err := os.Stat(f.Path)
+		if err != nil {
+			return err
+		}
+		total += info.Size()
+		bak, err := backupPath(plan.Root, f.Path, dir)
+		if err != nil {
+			return err
+		}
+		if _, err := os.Stat(bak); err == nil && !resume {
+			return fmt.Errorf("%s: %w", bak, errBackupExists)
+		}
+	}
+	at := dir
+	if at == "" {
+		at = plan.Root
+	}
+	if err := os.MkdirAll(at, 0o700); err != nil {
+		return err
+	}
+	free, err := platform.FreeSpace(at)
+	if errors.Is(err, platform.ErrUnsupported) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if need := uint64(total + walk.Headroom); free < need {
+		return fmt.Errorf("not enough space in %s for backups: %s free, need %s plus %s headroom",
+			at, walk.FormatSize(int64(free)), walk.FormatSize(total), walk.FormatSize(walk.Headroom))
+	}
+	return nil
+}
+
+// runRestore puts the originals rotate --backup kept back in place,
+// replacing the rotated files, and removes the backups.
+func runRestore(cfg model.RotateConfig) error {
+	base := cfg.Root
+	if cfg.BackupDir != "" {
+		base = cfg.BackupDir
+	}
+	type restore struct{ bak, path string }
+	var todo []restore
+	err := filepath.WalkDir(base, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		switch {
+		case cfg.BackupDir == "" && strings.HasSuffix(p, ".age"+backupExt):
+			todo = append(todo, restore{bak: p, path: strings.TrimSuffix(p, backupExt)})
+		case cfg.BackupDir != "" && strings.HasSuffix(p, ".age"):
+			rel, err := filepath.Rel(cfg.BackupDir, p)
+			if err != nil {
+				return err
+			}
+			todo = append(todo, restore{bak: p, path: filepath.Join(cfg.Root, rel)})
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("rotate --restore: %w", err)
+	}
+	if len(todo) == 0 {
+		return fmt.Errorf("rotate --restore: no backups found under %s", base)
+	}
+	for _, r := range todo {
+		fmt.Printf("  %s <- %s\n", r.path, r.bak)
+	}
+	if err := confirm(cfg.Confirm, fmt.Sprintf("Restore %d file(s) from their backups?", len(todo))); err != nil {
+		return fmt.Errorf("rotate --restore: %w", err)
+	}
+	fail := 0
+	for _, r := range todo {
+		if err := restoreBackup(r.bak, r.path); err != nil {
+			fmt.Fprintf(os.Stderr, "rotate --restore: %s: %v\n", r.path, err)
+			fail++
+		}
+	}
+	fmt.Printf("restore complete: %d restored, %d failed\n", len(todo)-fail, fail)
+	if fail > 0 {
+		return fmt.Errorf("rotate --restore: some files failed (see stderr)")
+	}
+	return nil
+}
+
+// restoreBackup moves bak over path, copying when they are on different
+// file systems.
+func restoreBackup(bak, path string) error {
+	b, err := os.ReadFile(bak)
+	if err != nil {
+		return err
+	}
+	if _, err := agepkg.InspectHeaderBytes(b); err != nil {
+		return fmt.Errorf("backup %s: %w", bak, err)
+	}
+	if err := os.Rename(bak, path); err == nil {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	if err := agepkg.AtomicWrite(path, b); err != nil {
+		return err
+	}
+	return os.Remove(bak)
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"filippo.io/age"
+	agepkg "github.com/andreweick/agepad/age"
+	"github.com/andreweick/agepad/model"
+	"github.com/andreweick/agepad/rotation"
+)
+
+func TestBackupPath(t *testing.T) {
+	root := "secrets"
+	path := filepath.Join(root, "api", "app.env.age")
+
+	if got, err := backupPath(root, path, ""); err != nil || got != path+".bak" {
+		t.Errorf("beside the file: got %q, %v", got, err)
+	}
+	want := filepath.Join("backups", "api", "app.env.age")
+	if got, err := backupPath(root, path, "backups"); err != nil || got != want {
+		t.Errorf("under the backup dir: got %q, %v; want %q", got, err, want)
+	}
+	if _, err := backupPath(root, filepath.Join("other", "a.age"), "backups"); err == nil {
+		t.Error("expected an error for a file outside the root")
+	}
+}
+
+func TestCheckBackupDir(t *testing.T) {
+	for dir, inside := range map[string]bool{
+		filepath.Join("secrets", "backups"):    true,
+		"secrets":                              true,
+		filepath.Join("secrets", "..", "bak"):  false,
+		"secrets-backup":                       false,
+		filepath.Join("..", "secrets-backups"): false,
+	} {
+		if err := checkBackupDir("secrets", dir); (err != nil) != inside {
+			t.Errorf("%s: got %v, want inside=%v", dir, err, inside)
+		}
+	}
+}
+
+func TestWriteBackup(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.env.age")
+	if err := os.WriteFile(path, []byte("new"), 0o640); err != nil {
+		t.Fatal(err)
+	}
+	bak := filepath.Join(dir, "backups", "app.env.age")
+
+	if err := writeBackup(path, bak, []byte("original"), false); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(bak)
+	if err != nil || info.Mode().Perm() != 0o640 {
+		t.Fatalf("expected the backup with the original's mode, got %v, %v", info, err)
+	}
+
+	t.Run("never replaces an earlier backup", func(t *testing.T) {
+		err := writeBackup(path, bak, []byte("rotated"), false)
+		if !errors.Is(err, errBackupExists) {
+			t.Errorf("expected errBackupExists, got %v", err)
+		}
+		if b, _ := os.ReadFile(bak); string(b) != "original" {
+			t.Errorf("backup replaced with %q", b)
+		}
+	})
+
+	t.Run("keeps it when resuming", func(t *testing.T) {
+		if err := writeBackup(path, bak, []byte("rotated"), true); err != nil {
+			t.Fatal(err)
+		}
+		if b, _ := os.ReadFile(bak); string(b) != "original" {
+			t.Errorf("backup replaced with %q", b)
+		}
+	})
+
+	t.Run("preflight stops before the first write", func(t *testing.T) {
+		plan := &rotation.Plan{Root: dir, Files: []rotation.File{{Path: path}}}
+		if err := preflightBackups(plan, filepath.Join(dir, "backups"), false); err == nil {
+			t.Error("expected a backup dir inside the root to be refused")
+		}
+		if err := preflightBackups(plan, "", false); err != nil {
+			t.Errorf("no backup beside the file yet, got %v", err)
+		}
+		if err := os.WriteFile(path+backupExt, []byte("original"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if err := preflightBackups(plan, "", false); !errors.Is(err, errBackupExists) {
+			t.Errorf("expected errBackupExists, got %v", err)
+		}
+		if err := preflightBackups(plan, "", true); err != nil {
+			t.Errorf("expected --resume to keep the backup, got %v", err)
+		}
+	})
+}
+
+func TestRunRestore(t *testing.T) {
+	id, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	original, err := agepkg.EncryptToMemory([]byte("A=1\n"), []age.Recipient{id.Recipient()}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	write := func(t *testing.T, path string, b []byte) {
+		t.Helper()
+		if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, b, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	check := func(t *testing.T, path, bak string) {
+		t.Helper()
+		if b, err := os.ReadFile(path); err != nil || string(b) != string(original) {
+			t.Errorf("%s not restored: %v", path, err)
+		}
+		if _, err := os.Stat(bak); !os.IsNotExist(err) {
+			t.Errorf("backup %s still there", bak)
+		}
+	}
+
+	t.Run("beside each file", func(t *testing.T) {
+		root := t.TempDir()
+		path := filepath.Join(root, "api", "app.env.age")
+		write(t, path, []byte("rotated"))
+		write(t, path+backupExt, original)
+		if err := runRestore(model.RotateConfig{Root: root, Confirm: model.Confirm{Yes: true}}); err != nil {
+			t.Fatal(err)
+		}
+		check(t, path, path+backupExt)
+	})
+
+	t.Run("from a backup dir", func(t *testing.T) {
+		root, backups := t.TempDir(), t.TempDir()
+		path := filepath.Join(root, "api", "app.env.age")
+		bak := filepath.Join(backups, "api", "app.env.age")
+		write(t, path, []byte("rotated"))
+		write(t, bak, original)
+		if err := runRestore(model.RotateConfig{Root: root, BackupDir: backups, Confirm: model.Confirm{Yes: true}}); err != nil {
+			t.Fatal(err)
+		}
+		check(t, path, bak)
+	})
+
+	t.Run("refuses a backup that is not age", func(t *testing.T) {
+		root := t.TempDir()
+		path := filepath.Join(root, "app.env.age")
+		write(t, path, []byte("rotated"))
+		write(t, path+backupExt, []byte("A=1\n"))
+		if err := runRestore(model.RotateConfig{Root: root, Confirm: model.Confirm{Yes: true}}); err == nil {
+			t.Error("expected a failure")
+		}
+		if b, _ := os.ReadFile(path); string(b) != "rotated" {
+			t.Errorf("file replaced with %q", b)
+		}
+	})
+
+	t.Run("reports no backups", func(t *testing.T) {
+		if err := runRestore(model.RotateConfig{Root: t.TempDir(), Confirm: model.Confirm{Yes: true}}); err == nil {
+			t.Error("expected an error")
+		}
+	})
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"filippo.io/age"
+	agepkg "github.com/andreweick/agepad/age"
+	"github.com/andreweick/agepad/model"
+	"github.com/andreweick/agepad/platform"
+)
+
+// minimalEnv lists the inherited variables run --minimal-env keeps, with
+// the LC_* locale ones: what most programs need to find binaries, the home
+// directory, and the locale. The Windows names are compared upper-cased.
+var minimalEnv = []string{
+	"PATH", "HOME", "USER", "LOGNAME", "SHELL", "TERM", "LANG", "TZ", "TMPDIR",
+	"SYSTEMROOT", "COMSPEC", "PATHEXT", "TEMP", "TMP", "USERPROFILE", "APPDATA", "LOCALAPPDATA",
+}
+
+// checkKeyPatterns reports a malformed --only or --exclude glob before
+// anything is decrypted.
+func checkKeyPatterns(flag string, patterns []string) error {
+	for _, p := range patterns {
+		if _, err := path.Match(p, ""); err != nil {
+			return fmt.Errorf("run: --%s %q: %w", flag, p, err)
+		}
+	}
+	return nil
+}
+
+// selectKeys drops the decrypted keys --only does not name and those
+// --exclude does. Both take names or globs such as AWS_*. An --only name
+// without a glob that no file defines is reported on stderr, since it is
+// usually a typo the command will trip over later.
+func selectKeys(vars map[string]string, only, exclude []string) {
+	matches := func(patterns []string, k string) bool {
+		return slices.ContainsFunc(patterns, func(p string) bool {
+			ok, _ := path.Match(p, k)
+			return ok
+		})
+	}
+	for k := range vars {
+		if (len(only) > 0 && !matches(only, k)) || matches(exclude, k) {
+			delete(vars, k)
+		}
+	}
+	for _, p := range only {
+		if _, ok := vars[p]; !ok && !strings.ContainsAny(p, `*?[\`) && !matches(exclude, p) {
+			fmt.Fprintf(os.Stderr, "warning: run: --only %s: no such key in the decrypted files\n", p)
+		}
+	}
+}
+
+// inheritedEnv returns agepad's environment as a map, only the minimalEnv
+// variables of it when minimal is set. $AGEPAD_IDENTITY is always left
+// out: it is still set when the daemon or an identity file did the
+// decrypting, and the command must never see the key.
+func inheritedEnv(minimal bool) map[string]string {
+	env := map[string]string{}
+	for _, kv := range os.Environ() {
+		k, v, ok := strings.Cut(kv, "=")
+		if !ok {
+			continue
+		}
+		up := strings.ToUpper(k)
+		if up == agepkg.IdentityEnv || minimal && !slices.Contains(minimalEnv, up) && !strings.HasPrefix(up, "LC_") {
+			continue
+		}
+		env[k] = v
+	}
+	return env
+}
+
+// parseRenames reads --rename OLD=NEW pairs.
+func parseRenames(pairs []string) (map[string]string, error) {
+	renames := map[string]string{}
+	for _, p := range pairs {
+		old, new, ok := strings.Cut(p, "=")
+		old, new = strings.TrimSpace(old), strings.TrimSpace(new)
+		if !ok || old == "" || new == "" || strings.ContainsAny(new, "= \t\"'") {
+			return nil, fmt.Errorf("run: --rename %q: expected OLD=NEW with a valid variable name", p)
+		}
+		if _, dup := renames[old]; dup {
+			return nil, fmt.Errorf("run: --rename: %s is renamed twice", old)
+		}
+		renames[old] = new
+	}
+	return renames, nil
+}
+
+// renameKeys returns vars under the names the command expects: a --rename
+// key by its new name as written, every other key with prefix added unless
+// it already starts with it (as agepad prefix --add does). Two keys ending
+// up with one name is an error rather than a silent overwrite.
+func renameKeys(vars map[string]string, prefix string, renames map[string]string) (map[string]string, error) {
+	out := make(map[string]string, len(vars))
+	from := map[string]string{}
+	for k, v := range vars {
+		name, ok := renames[k]
+		if !ok {
+			name = k
+			if !strings.HasPrefix(k, prefix) {
+				name = prefix + k
+			}
+		}
+		if other, dup := from[name]; dup {
+			a, b := min(k, other), max(k, other)
+			return nil, fmt.Errorf("run: %s and %s would both be exported as %s", a, b, name)
+		}
+		from[name] = k
+		out[name] = v
+	}
+	for old := range renames {
+		if _, ok := vars[old]; !ok {
+			fmt.Fprintf(os.Stderr, "warning: run: --rename %s: no such key in the selected keys\n", old)
+		}
+	}
+	return out, nil
+}
+
+// runEnv checks and decrypts cfg.Files and returns the command's
+// environment: the decrypted variables (same .env grammar as the editor),
+// each file over the ones before it, selected and renamed, over what the
+// command inherits. exe is the resolved command, for the daemon.
+func runEnv(cfg model.RunConfig, exe string, ids *[]age.Identity) ([]string, error) {
+	for _, f := range cfg.Files {
+		if err := checkStanzas(f, cfg.Stanzas); err != nil {
+			return nil, err
+		}
+	}
+	secrets := map[string]string{}
+	for _, f := range cfg.Files {
+		plain, err := decryptVia(cfg, f, exe, ids)
+		if err != nil {
+			return nil, err
+		}
+		if plain, _, err = openShared(plain); err != nil {
+			return nil, err
+		}
+		vars, err := envVars(f, plain)
+		if err != nil {
+			return nil, fmt.Errorf("run: %s: %w", f, err)
+		}
+		for k, v := range vars {
+			secrets[k] = v
+		}
+	}
+	selectKeys(secrets, cfg.Only, cfg.Exclude)
+	secrets, err := renameKeys(secrets, cfg.Prefix, cfg.Renames)
+	if err != nil {
+		return nil, err
+	}
+	envMap := inheritedEnv(cfg.MinimalEnv)
+	for k, v := range secrets {
+		envMap[k] = v
+	}
+	env := make([]string, 0, len(envMap))
+	for k, v := range envMap {
+		env = append(env, k+"="+v)
+	}
+	return env, nil
+}
+
+// watchGrace is how long run --watch lets the command stop on SIGTERM
+// before killing it.
+const watchGrace = 10 * time.Second
+
+// fileSums fingerprints each file's ciphertext; a file that cannot be
+// read has the zero sum.
+func fileSums(files []string) [][sha256.Size]byte {
+	sums := make([][sha256.Size]byte, len(files))
+	for i, f := range files {
+		if b, err := os.ReadFile(f); err == nil {
+			sums[i] = sha256.Sum256(b)
+		}
+	}
+	return sums
+}
+
+// runWatching runs the command as a child and, each time one of the
+// files changes, builds the environment again and restarts it. A change
+// that does not decrypt or parse (a file caught half-written, a typo) is
+// reported and the command keeps running on the old values. Ctrl+C ends
+// both, and agepad exits as the last command did.
+func runWatching(cfg model.RunConfig, exe string, env []string, sums [][sha256.Size]byte, ids *[]age.Identity) error {
+	notice := func(msg string) { fmt.Fprintf(os.Stderr, "%s run --watch: %s\n", appName, msg) }
+	restart := make(chan []string)
+	go func() {
+		for range time.Tick(cfg.WatchInterval) {
+			now := fileSums(cfg.Files)
+			var changed []string
+			for i, f := range cfg.Files {
+				if now[i] != sums[i] && now[i] != [sha256.Size]byte{} {
+					changed = append(changed, f)
+				}
+			}
+			if len(changed) == 0 {
+				continue
+			}
+			sums = now
+			env, err := runEnv(cfg, exe, ids)
+			if err != nil {
+				notice(fmt.Sprintf("%s changed but was not applied: %v", strings.Join(changed, ", "), err))
+				continue
+			}
+			notice(strings.Join(changed, ", ") + " changed")
+			restart <- env
+		}
+	}()
+	ps, err := platform.SuperviseRestarting(exe, cfg.Command, env, restart, watchGrace, notice)
+	if err != nil {
+		return fmt.Errorf("run: %w", err)
+	}
+	platform.ExitLike(ps)
+	return nil
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"filippo.io/age"
+	agepkg "github.com/andreweick/agepad/age"
+	"github.com/andreweick/agepad/model"
+)
+
+func TestRunEnvHidesInlineIdentity(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("runs printenv")
+	}
+	id, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	keys := filepath.Join(dir, "key.txt")
+	if err := os.WriteFile(keys, []byte(id.String()+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(dir, "app.env.age")
+	if err := agepkg.AtomicEncryptWrite(file, []byte("A=1\n"), []age.Recipient{id.Recipient()}, true); err != nil {
+		t.Fatal(err)
+	}
+	// --identities names a file, so the inline key is never loaded or unset.
+	t.Setenv(agepkg.IdentityEnv, id.String())
+
+	var ids []age.Identity
+	env, err := runEnv(model.RunConfig{Files: []string{file}, IdentitiesPath: keys, NoDaemon: true}, "printenv", &ids)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command("printenv")
+	cmd.Env = env
+	out, err := cmd.Output()
+	if err != nil {
+		t.Skipf("printenv: %v", err)
+	}
+	if !strings.Contains(string(out), "A=1\n") {
+		t.Errorf("expected the decrypted variable, got %q", out)
+	}
+	if strings.Contains(string(out), agepkg.IdentityEnv) || strings.Contains(string(out), id.String()) {
+		t.Error("the command sees $" + agepkg.IdentityEnv)
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/andreweick/agepad/gitutil"
+	"github.com/andreweick/agepad/model"
+	"github.com/andreweick/agepad/scan"
+	"github.com/urfave/cli/v3"
+)
+
+func scanCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "scan",
+		Usage:     "Look for plaintext secrets about to be committed and .age files that are not encrypted (values are never printed)",
+		ArgsUsage: "[file or directory ...]",
+		Flags: []cli.Flag{
+			&cli.BoolFlag{
+				Name:  "staged",
+				Usage: "Scan the files staged for the next commit, as a pre-commit hook",
+			},
+			&cli.StringSliceFlag{
+				Name:  "exclude",
+				Usage: "Skip paths or file names matching this glob (repeatable), e.g. testdata/*",
+			},
+		},
+		Action: runScan,
+	}
+}
+
+func runScan(ctx context.Context, cmd *cli.Command) error {
+	cfg := model.ScanConfig{
+		Staged:  cmd.Bool("staged"),
+		Paths:   cmd.Args().Slice(),
+		Exclude: cmd.StringSlice("exclude"),
+	}
+	switch {
+	case cfg.Staged && len(cfg.Paths) > 0:
+		return fmt.Errorf("scan: pass --staged or name files, not both")
+	case !cfg.Staged && len(cfg.Paths) == 0:
+		return fmt.Errorf("scan: name files or directories to scan, or pass --staged")
+	}
+	for _, p := range cfg.Exclude {
+		if _, err := filepath.Match(p, ""); err != nil {
+			return fmt.Errorf("scan: --exclude %q: %w", p, err)
+		}
+	}
+	excluded := func(path string) bool {
+		return slices.ContainsFunc(cfg.Exclude, func(p string) bool {
+			m1, _ := filepath.Match(p, path)
+			m2, _ := filepath.Match(p, filepath.Base(path))
+			return m1 || m2
+		})
+	}
+
+	var (
+		paths   []string
+		read    func(string) ([]byte, error)
+		tracked func(string) bool
+	)
+	if cfg.Staged {
+		top, err := gitutil.Repo{}.TopLevel()
+		if err != nil {
+			return fmt.Errorf("scan: %w", err)
+		}
+		repo := gitutil.Repo{Dir: top}
+		if paths, err = repo.Staged(); err != nil {
+			return fmt.Errorf("scan: %w", err)
+		}
+		// The index holds what will be committed, which may differ from
+		// the working tree (and is ciphertext for git-clean files).
+		read = func(p string) ([]byte, error) { return repo.Show("", p) }
+		tracked = func(p string) bool { return repo.Exists("", p) }
+	} else {
+		for _, root := range cfg.Paths {
+			err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+				if err != nil {
+					return err
+				}
+				if d.IsDir() && d.Name() == ".git" {
+					return filepath.SkipDir
+				}
+				if d.Type().IsRegular() {
+					paths = append(paths, p)
+				}
+				return nil
+			})
+			if err != nil {
+				return fmt.Errorf("scan: %w", err)
+			}
+		}
+		read = os.ReadFile
+		tracked = func(p string) bool {
+			_, err := os.Stat(p)
+			return !errors.Is(err, fs.ErrNotExist)
+		}
+	}
+
+	var findings, files, scanned int
+	for _, p := range paths {
+		if excluded(p) {
+			continue
+		}
+		scanned++
+		content, err := read(p)
+		if err != nil {
+			return fmt.Errorf("scan: %w", err)
+		}
+		found := scan.File(p, content, tracked)
+		for _, f := range found {
+			fmt.Println(f)
+		}
+		if len(found) > 0 {
+			findings += len(found)
+			files++
+		}
+	}
+	if findings > 0 {
+		return fmt.Errorf("scan: %d finding(s) in %d of %d file(s); encrypt them with agepad, or mark a line %s if it is not a secret", findings, files, scanned, scan.Allow)
+	}
+	return nil
+}
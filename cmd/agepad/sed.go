@@ -0,0 +1,244 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	agepkg "github.com/andreweick/agepad/age"
+	"github.com/andreweick/agepad/audit"
+	"github.com/andreweick/agepad/model"
+	"github.com/andreweick/agepad/validator"
+	"github.com/andreweick/agepad/walk"
+	"github.com/urfave/cli/v3"
+)
+
+func sedCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "sed",
+		Usage: "Replace text in every .age file under a tree, with a redacted preview and one all-or-nothing write",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  "root",
+				Usage: "Directory tree of .age files to search",
+				Value: ".",
+			},
+			&cli.StringFlag{
+				Name:     "match",
+				Usage:    "Text to replace (a Go regular expression with --regexp); matched within each line",
+				Required: true,
+			},
+			&cli.StringFlag{
+				Name:     "replace",
+				Usage:    "Replacement text; with --regexp, $1 or ${name} insert submatches",
+				Required: true,
+			},
+			&cli.BoolFlag{
+				Name:  "regexp",
+				Usage: "Treat --match as a regular expression; the preview then shows whatever it matched",
+			},
+			&cli.BoolFlag{
+				Name:  "preview",
+				Usage: "Only print the redacted per-file changes",
+			},
+			&cli.StringFlag{
+				Name:  "recipients-file",
+				Usage: "Recipients to re-encrypt changed files to",
+				Value: defaultRecipientsFile,
+			},
+			&cli.StringFlag{
+				Name:  "identities",
+				Usage: "Path to AGE identities",
+				Value: defaultIdentitiesPath(),
+			},
+			&cli.StringFlag{
+				Name:  "audit-log",
+				Usage: "Audit log recording each rewritten file (never the text)",
+				Value: audit.DefaultPath(),
+			},
+			yesFlag,
+			confirmPromptFlag,
+		},
+		Action: runSed,
+	}
+}
+
+func runSed(ctx context.Context, cmd *cli.Command) error {
+	cfg := model.SedConfig{
+		Root:           cmd.String("root"),
+		Match:          cmd.String("match"),
+		Replace:        cmd.String("replace"),
+		Regexp:         cmd.Bool("regexp"),
+		Preview:        cmd.Bool("preview"),
+		RecipientsFile: cmd.String("recipients-file"),
+		IdentitiesPath: cmd.String("identities"),
+		AuditLog:       cmd.String("audit-log"),
+		Confirm:        confirmFromFlags(cmd),
+	}
+	var err error
+	if cfg.Validation, cfg.TypeRules, err = validationFromFlags(cmd); err != nil {
+		return err
+	}
+	if cfg.Match == "" || strings.ContainsAny(cfg.Match+cfg.Replace, "\n\r") {
+		return fmt.Errorf("sed: --match must be non-empty, and neither it nor --replace may span lines")
+	}
+	pattern := regexp.QuoteMeta(cfg.Match)
+	if cfg.Regexp {
+		pattern = cfg.Match
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return fmt.Errorf("sed: --match: %w", err)
+	}
+
+	ids, err := agepkg.LoadIdentities(cfg.IdentitiesPath)
+	if err != nil {
+		return err
+	}
+	files, err := walk.AgeFiles(cfg.Root)
+	if err != nil {
+		return err
+	}
+
+	// Decrypt everything first; a file that cannot be read might need the
+	// change too, so it stops the run before a write.
+	var found []revoked
+	var outs []string
+	var previews []string
+	for _, f := range files {
+		cipher, err := os.ReadFile(f)
+		if err != nil {
+			return err
+		}
+		plain, err := agepkg.Decrypt(cipher, ids)
+		if err != nil {
+			return fmt.Errorf("sed: %s: %w; nothing was written", f, err)
+		}
+		out, preview, n := sedText(plain, re, cfg.Replace, !cfg.Regexp)
+		if n == 0 {
+			continue
+		}
+		found = append(found, revoked{path: f, occurrences: n, old: cipher})
+		outs = append(outs, out)
+		previews = append(previews, preview)
+	}
+	if len(found) == 0 {
+		fmt.Fprintf(os.Stderr, "sed: no match in %d file(s) under %s\n", len(files), cfg.Root)
+		return nil
+	}
+	for i, r := range found {
+		fmt.Printf("%s (%d replacement(s))\n%s", r.path, r.occurrences, previews[i])
+	}
+	if cfg.Preview {
+		fmt.Fprintf(os.Stderr, "sed: would change %d of %d file(s); run without --preview to apply\n", len(found), len(files))
+		return nil
+	}
+
+	// Build and check every replacement before writing any.
+	recips, err := agepkg.LoadRecipients(cfg.RecipientsFile)
+	if err != nil {
+		return err
+	}
+	writes := make([]walk.Write, len(found))
+	for i := range found {
+		r := &found[i]
+		if sev, err := validator.Check(r.path, outs[i], cfg.Validation, cfg.TypeRules); err != nil {
+			if sev != model.SeverityWarn {
+				return fmt.Errorf("sed: %s would no longer parse: %w; nothing was written", r.path, err)
+			}
+			fmt.Fprintln(os.Stderr, "warning:", err)
+		}
+		h, err := agepkg.InspectHeaderBytes(r.old)
+		if err != nil {
+			return fmt.Errorf("sed: %s: %w", r.path, err)
+		}
+		if r.new, err = agepkg.EncryptToMemory([]byte(outs[i]), recips, h.Armored); err != nil {
+			return fmt.Errorf("sed: %s: %w", r.path, err)
+		}
+		writes[i] = walk.Write{Path: r.path, Size: int64(len(r.new))}
+	}
+	if err := walk.Preflight(writes, 1); err != nil {
+		return fmt.Errorf("sed: %w; nothing was written", err)
+	}
+	if err := confirm(cfg.Confirm, fmt.Sprintf("Rewrite %d file(s)?", len(found))); err != nil {
+		return fmt.Errorf("sed: %w", err)
+	}
+
+	for i, r := range found {
+		if err := agepkg.WriteRetrying(r.path, r.new, writeRetry); err != nil {
+			return fmt.Errorf("sed: write %s: %w; %s", r.path, err, rollBack(found[:i]))
+		}
+	}
+	for _, r := range found {
+		event := audit.Event{
+			Action:  "sed",
+			File:    r.path,
+			Details: map[string]string{"replacements": strconv.Itoa(r.occurrences)},
+		}
+		if err := (audit.Log{Path: cfg.AuditLog}).Record(event); err != nil {
+			fmt.Fprintf(os.Stderr, "sed: %v\n", err)
+		}
+	}
+	fmt.Fprintf(os.Stderr, "sed: rewrote %d file(s)\n", len(found))
+	return nil
+}
+
+// sedKeyRE is the key a line starts with: "KEY=", "key:", or "key =".
+var sedKeyRE = regexp.MustCompile(`^\s*(?:export\s+)?[-\w."']+\s*[:=]\s*`)
+
+// sedText replaces re's matches in each line of plain with repl (literally,
+// or expanding $1 submatches), and returns the result, the redacted
+// preview, and how many matches it replaced. The preview has a -/+ pair
+// per changed line: its key and the matched and replacement text, with
+// everything else between them shown as "…".
+func sedText(plain string, re *regexp.Regexp, repl string, literal bool) (string, string, int) {
+	lines := strings.Split(plain, "\n")
+	var preview strings.Builder
+	total := 0
+	for i, line := range lines {
+		matches := re.FindAllStringSubmatchIndex(line, -1)
+		var out, oldView, newView strings.Builder
+		key := sedKeyRE.FindString(line)
+		if len(matches) > 0 && matches[0][0] < len(key) {
+			key = "" // the key itself changes; the spans show it
+		}
+		oldView.WriteString(key)
+		newView.WriteString(key)
+		last, n := 0, 0
+		gap := func(s string) {
+			if s = strings.TrimPrefix(s, key); strings.TrimSpace(s) != "" {
+				oldView.WriteString("…")
+				newView.WriteString("…")
+			}
+			key = ""
+		}
+		for _, m := range matches {
+			if m[0] == m[1] {
+				continue // an empty match changes nothing visible
+			}
+			with := repl
+			if !literal {
+				with = string(re.ExpandString(nil, repl, line, m))
+			}
+			out.WriteString(line[last:m[0]])
+			out.WriteString(with)
+			gap(line[last:m[0]])
+			oldView.WriteString(line[m[0]:m[1]])
+			newView.WriteString(with)
+			last = m[1]
+			n++
+		}
+		if n == 0 {
+			continue
+		}
+		out.WriteString(line[last:])
+		gap(line[last:])
+		lines[i] = out.String()
+		total += n
+		fmt.Fprintf(&preview, "  %5d - %s\n  %5d + %s\n", i+1, oldView.String(), i+1, newView.String())
+	}
+	return strings.Join(lines, "\n"), preview.String(), total
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	agepkg "github.com/andreweick/agepad/age"
+	"github.com/andreweick/agepad/audit"
+	"github.com/andreweick/agepad/buildinfo"
+	"github.com/andreweick/agepad/model"
+	"github.com/andreweick/agepad/share"
+	"github.com/urfave/cli/v3"
+)
+
+func shareCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "share",
+		Usage: "Re-encrypt a file to a guest recipient with an expiry agepad enforces (read-only)",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:     "file",
+				Usage:    "Encrypted file to share",
+				Required: true,
+			},
+			&cli.StringSliceFlag{
+				Name:     "to",
+				Usage:    "Guest recipient public key (age1...); repeatable",
+				Required: true,
+			},
+			&cli.DurationFlag{
+				Name:  "expires",
+				Usage: "How long the share stays viewable",
+				Value: time.Hour,
+			},
+			&cli.StringFlag{
+				Name:  "out",
+				Usage: "Write the shared ciphertext here instead of stdout",
+			},
+			&cli.StringFlag{
+				Name:  "identities",
+				Usage: "Path to AGE identities",
+				Value: defaultIdentitiesPath(),
+			},
+			&cli.BoolFlag{
+				Name:  "armor",
+				Usage: "Write ASCII-armored .age output",
+				Value: buildinfo.ArmorDefault,
+			},
+			&cli.StringFlag{
+				Name:  "audit-log",
+				Usage: "Audit log that records the share",
+				Value: audit.DefaultPath(),
+			},
+		},
+		Action: runShare,
+	}
+}
+
+func runShare(ctx context.Context, cmd *cli.Command) error {
+	cfg := model.ShareConfig{
+		FilePath:       cmd.String("file"),
+		To:             cmd.StringSlice("to"),
+		Expires:        cmd.Duration("expires"),
+		OutPath:        cmd.String("out"),
+		IdentitiesPath: cmd.String("identities"),
+		Armor:          cmd.Bool("armor"),
+		AuditLog:       cmd.String("audit-log"),
+	}
+	if cfg.Expires <= 0 {
+		return fmt.Errorf("share: --expires must be positive")
+	}
+	recips, err := agepkg.ParseRecipients(strings.NewReader(strings.Join(cfg.To, "\n")))
+	if err != nil {
+		return fmt.Errorf("share: --to: %w", err)
+	}
+
+	ids, err := agepkg.LoadIdentities(cfg.IdentitiesPath)
+	if err != nil {
+		return err
+	}
+	plain, err := agepkg.DecryptToMemory(cfg.FilePath, ids)
+	if err != nil {
+		return err
+	}
+	// Re-sharing a share keeps the original, earlier expiry.
+	a, body, shared, err := share.Parse(plain)
+	if err != nil {
+		return err
+	}
+	expires := time.Now().Add(cfg.Expires).UTC().Truncate(time.Second)
+	if shared && a.Expires.Before(expires) {
+		expires = a.Expires
+	}
+	wrapped := share.Wrap(body, share.Annotation{Expires: expires, Source: filepath.Base(cfg.FilePath)})
+
+	cipher, err := agepkg.EncryptToMemory([]byte(wrapped), recips, cfg.Armor)
+	if err != nil {
+		return fmt.Errorf("share: encrypt: %w", err)
+	}
+	if cfg.OutPath == "" {
+		if _, err := os.Stdout.Write(cipher); err != nil {
+			return err
+		}
+	} else if err := os.WriteFile(cfg.OutPath, cipher, 0644); err != nil {
+		return fmt.Errorf("share: %w", err)
+	}
+
+	event := audit.Event{
+		Action: "share",
+		File:   cfg.FilePath,
+		Details: map[string]string{
+			"to":      strings.Join(cfg.To, ","),
+			"expires": expires.Format(time.RFC3339),
+			"out":     cfg.OutPath,
+		},
+	}
+	if err := (audit.Log{Path: cfg.AuditLog}).Record(event); err != nil {
+		return fmt.Errorf("share written, but %w", err)
+	}
+	fmt.Fprintf(os.Stderr, "Shared %s with %d recipient(s) until %s.\n",
+		cfg.FilePath, len(recips), expires.Local().Format(time.RFC1123))
+	return nil
+}
+
+// openShared strips a share annotation from plain and enforces its expiry.
+// shared reports whether plain was a share, which callers open read-only.
+func openShared(plain string) (body string, shared bool, err error) {
+	a, body, shared, err := share.Parse(plain)
+	if err != nil || !shared {
+		return body, shared, err
+	}
+	if err := a.Check(time.Now()); err != nil {
+		return "", true, err
+	}
+	return body, true, nil
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	agepkg "github.com/andreweick/agepad/age"
+	"github.com/andreweick/agepad/audit"
+	"github.com/andreweick/agepad/model"
+	"github.com/urfave/cli/v3"
+)
+
+var (
+	requireX25519Flag = &cli.BoolFlag{
+		Name:  "require-x25519",
+		Usage: "Refuse files whose header has any non-X25519 stanza (scrypt, ssh, plugins)",
+	}
+	forbidScryptFlag = &cli.BoolFlag{
+		Name:  "forbid-scrypt",
+		Usage: "Refuse files whose header has a passphrase (scrypt) stanza",
+	}
+)
+
+func stanzaPolicy(cmd *cli.Command) model.StanzaPolicy {
+	return model.StanzaPolicy{
+		RequireX25519: cmd.Bool("require-x25519"),
+		ForbidScrypt:  cmd.Bool("forbid-scrypt"),
+	}
+}
+
+// checkStanzas refuses path when its header breaks p. Refusals are
+// recorded in the audit log; a failure to record is reported but does not
+// change the outcome.
+func checkStanzas(path string, p model.StanzaPolicy) error {
+	if p == (model.StanzaPolicy{}) {
+		return nil
+	}
+	h, err := agepkg.InspectHeader(path)
+	if err != nil {
+		return err
+	}
+	return checkHeader(path, h, p)
+}
+
+// checkHeader is checkStanzas for a header already read, such as the data
+// key block of a value-encrypted file.
+func checkHeader(path string, h agepkg.Header, p model.StanzaPolicy) error {
+	if err := agepkg.CheckStanzas(h, p); err != nil {
+		event := audit.Event{
+			Action: "stanza-policy",
+			File:   path,
+			Details: map[string]string{
+				"stanzas": strings.Join(agepkg.StanzaViolations(h.StanzaTypes(), p), ","),
+				"result":  "refused",
+			},
+		}
+		if aerr := (audit.Log{Path: audit.DefaultPath()}).Record(event); aerr != nil {
+			fmt.Fprintln(os.Stderr, "warning:", aerr)
+		}
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/andreweick/agepad/config"
+	"github.com/andreweick/agepad/model"
+	"github.com/andreweick/agepad/stats"
+	"github.com/urfave/cli/v3"
+)
+
+func statsCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "stats",
+		Usage: "Print the local usage ledger: saves, rotations, and verifies per repository (opt-in with stats = true in the config file)",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  "ledger",
+				Usage: "Usage ledger to read",
+				Value: stats.DefaultPath(),
+			},
+			&cli.StringFlag{
+				Name:  "since",
+				Usage: "Count only days on or after this date (YYYY-MM-DD)",
+			},
+			&cli.BoolFlag{
+				Name:  "json",
+				Usage: "Print the totals as JSON",
+			},
+			&cli.BoolFlag{
+				Name:  "clear",
+				Usage: "Delete the ledger",
+			},
+			yesFlag,
+			confirmPromptFlag,
+		},
+		Action: runStats,
+	}
+}
+
+func runStats(ctx context.Context, cmd *cli.Command) error {
+	cfg := model.StatsConfig{
+		Ledger:  cmd.String("ledger"),
+		Since:   cmd.String("since"),
+		JSON:    cmd.Bool("json"),
+		Clear:   cmd.Bool("clear"),
+		Confirm: confirmFromFlags(cmd),
+	}
+	if cfg.Since != "" {
+		if _, err := time.Parse(time.DateOnly, cfg.Since); err != nil {
+			return fmt.Errorf("--since: want YYYY-MM-DD, got %q", cfg.Since)
+		}
+	}
+	if cfg.Clear {
+		if err := confirm(cfg.Confirm, "Delete the usage ledger "+cfg.Ledger+"?"); err != nil {
+			return fmt.Errorf("stats: %w", err)
+		}
+		if err := os.Remove(cfg.Ledger); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("stats: %w", err)
+		}
+		fmt.Printf("stats: removed %s\n", cfg.Ledger)
+		return nil
+	}
+
+	entries, err := stats.Ledger{Path: cfg.Ledger}.Read()
+	if err != nil {
+		return err
+	}
+	repos := stats.Summarize(entries, cfg.Since)
+	if cfg.JSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(repos)
+	}
+	if len(repos) == 0 {
+		fmt.Printf("stats: nothing counted in %s\n", cfg.Ledger)
+		if !statsEnabled() {
+			fmt.Printf("Counting is off. Add stats = true to %s (or set AGEPAD_STATS=1) to keep a local tally; nothing is ever sent anywhere.\n", config.UserPath())
+		}
+		return nil
+	}
+	for _, r := range repos {
+		fmt.Printf("%s\n", r.Repo)
+		fmt.Printf("  saves:     %d (%d session(s))\n", r.Counts[stats.Save], r.Runs[stats.Save])
+		fmt.Printf("  rotations: %d file(s) in %d run(s)\n", r.Counts[stats.Rotate], r.Runs[stats.Rotate])
+		fmt.Printf("  verifies:  %d file(s) in %d run(s)\n", r.Counts[stats.Verify], r.Runs[stats.Verify])
+		fmt.Printf("  active:    %d day(s), %s to %s\n", r.Days, r.First, r.Last)
+	}
+	return nil
+}
+
+// statsEnabled reports whether the user opted in to the usage ledger.
+func statsEnabled() bool {
+	c, err := config.Load(config.UserPath())
+	if err != nil {
+		return stats.Enabled(nil)
+	}
+	return stats.Enabled(c.Stats)
+}
+
+// recordStats adds n of action in the repository holding dir to the usage
+// ledger, when the user opted in. Counting never fails a command.
+func recordStats(action, dir string, n int) {
+	if n <= 0 || !statsEnabled() {
+		return
+	}
+	if err := (stats.Ledger{Path: stats.DefaultPath()}).Record(action, dir, n); err != nil {
+		fmt.Fprintln(os.Stderr, "warning:", err)
+	}
+}
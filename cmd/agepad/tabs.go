@@ -0,0 +1,92 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"filippo.io/age"
+	agepkg "github.com/andreweick/agepad/age"
+	"github.com/andreweick/agepad/harden"
+	"github.com/andreweick/agepad/model"
+	"github.com/andreweick/agepad/stats"
+	"github.com/andreweick/agepad/tui"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// runTabs opens each of files in its own editor tab. Every file is
+// decrypted before the editor starts, so a file that cannot be opened
+// stops the session instead of leaving a tab half open. afterSave, if
+// set, runs after each save as in the single-file editor.
+func runTabs(cfg model.Config, files []string, ids []age.Identity, hardening harden.Report, afterSave func(path, plain string) error) error {
+	recips, err := loadEditorRecipients(cfg)
+	if err != nil && !cfg.ViewOnly {
+		return err
+	}
+	keys := tui.DefaultKeyMap()
+	_ = keys.Rebind(cfg.KeyBindings) // checked by runEditor
+	var editors []tui.EditorModel
+	for _, path := range files {
+		fileCfg := cfg
+		fileCfg.FilePath = path
+		if err := checkStanzas(path, cfg.Stanzas); err != nil {
+			return err
+		}
+		plain, err := agepkg.DecryptToMemory(path, ids)
+		if err != nil {
+			return passphraseHint(path, err)
+		}
+		body, shared, err := openShared(plain)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		if shared {
+			plain, fileCfg.ViewOnly = body, true
+		}
+		if err := checkViewWindow(fileCfg, path, plain); err != nil {
+			return err
+		}
+		if cfg.Redact {
+			if plain, err = redactedView(fileCfg, path, plain); err != nil {
+				return err
+			}
+		}
+		var opts []tui.EditorOption
+		if afterSave != nil {
+			opts = append(opts, tui.AfterSave(afterSave))
+		}
+		if head, ok := headVersion(path, ids); ok && !shared && !cfg.Redact {
+			opts = append(opts, tui.WithHead(head))
+		}
+		editors = append(editors, tui.NewEditorModel(fileCfg, plain, ids, recips, opts...))
+		if cfg.Harden {
+			hardening.ExcludeFromDumps("decrypted buffer "+path, plain)
+		}
+	}
+	if cfg.Harden {
+		fmt.Fprint(os.Stderr, "Hardening report:\n"+hardening.String())
+	}
+
+	p := tea.NewProgram(tui.NewTabs(keys, editors...), tea.WithAltScreen())
+	agepkg.SetPluginUI(tui.PluginUI(p))
+	final, err := p.Run()
+	if errors.Is(err, tea.ErrProgramPanic) {
+		crashGuard()
+	}
+	if cfg.WipeOnExit {
+		tui.WipeTerminal(os.Stdout)
+	}
+	if tabs, ok := final.(tui.Tabs); ok {
+		for _, s := range tabs.Summaries() {
+			if cfg.Summary {
+				fmt.Fprint(os.Stderr, s.String())
+			}
+			recordStats(stats.Save, filepath.Dir(s.File), s.Saves)
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("tui error: %w", err)
+	}
+	return nil
+}
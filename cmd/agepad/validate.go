@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"filippo.io/age"
+	agepkg "github.com/andreweick/agepad/age"
+	"github.com/andreweick/agepad/model"
+	"github.com/andreweick/agepad/validator"
+	"github.com/urfave/cli/v3"
+)
+
+func validateCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "validate",
+		Usage:     "Check plaintext or .age files the way the editor does before a save; exits non-zero on failure",
+		ArgsUsage: "PATH...",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  "identities",
+				Usage: "Path to AGE identities, for .age paths",
+				Value: defaultIdentitiesPath(),
+			},
+		},
+		Action: runValidate,
+	}
+}
+
+func runValidate(ctx context.Context, cmd *cli.Command) error {
+	cfg := model.ValidateConfig{
+		Paths:          cmd.Args().Slice(),
+		IdentitiesPath: cmd.String("identities"),
+		Stanzas:        stanzaPolicy(cmd),
+		Schema:         cmd.String("schema"),
+	}
+	var err error
+	if cfg.Validation, cfg.TypeRules, err = validationFromFlags(cmd); err != nil {
+		return err
+	}
+	if len(cfg.Paths) == 0 {
+		return errors.New("validate: pass the files to check")
+	}
+
+	var ids []age.Identity
+	failed := 0
+	for _, path := range cfg.Paths {
+		if strings.HasSuffix(path, ".age") && ids == nil {
+			if ids, err = agepkg.LoadIdentities(cfg.IdentitiesPath); err != nil {
+				return err
+			}
+		}
+		format, sev, err := validatePath(cfg, path, ids)
+		schema := validator.SchemaFor(path, format, cfg.Schema)
+		switch {
+		case err != nil && sev == model.SeverityWarn:
+			fmt.Printf("warn  %s: %v\n", path, err)
+		case err != nil:
+			fmt.Printf("FAIL  %s: %v\n", path, err)
+			failed++
+		case format == "":
+			fmt.Printf("skip  %s (format unknown; name it with --type-map)\n", path)
+		case sev == model.SeverityOff:
+			fmt.Printf("skip  %s (%s validation off)\n", path, format)
+		case schema != "":
+			fmt.Printf("ok    %s (%s, schema %s)\n", path, format, schema)
+		default:
+			fmt.Printf("ok    %s (%s)\n", path, format)
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("validate: %d of %d file(s) failed", failed, len(cfg.Paths))
+	}
+	return nil
+}
+
+// validatePath reads path, decrypting .age files in memory after the
+// stanza policy check, and validates the content as the editor would
+// before saving it, against a JSON Schema too for JSON and YAML. The plaintext is not printed.
+func validatePath(cfg model.ValidateConfig, path string, ids []age.Identity) (format string, sev model.Severity, err error) {
+	var content string
+	if strings.HasSuffix(path, ".age") {
+		if err := checkStanzas(path, cfg.Stanzas); err != nil {
+			return "", model.SeverityError, err
+		}
+		if content, err = agepkg.DecryptToMemory(path, ids); err != nil {
+			return "", model.SeverityError, err
+		}
+	} else {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return "", model.SeverityError, err
+		}
+		content = string(b)
+	}
+	format = validator.Format(path, content, cfg.TypeRules)
+	sev, err = validator.CheckWithSchema(path, content, cfg.Schema, cfg.Validation, cfg.TypeRules)
+	return format, sev, err
+}
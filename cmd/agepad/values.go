@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"filippo.io/age"
+	agepkg "github.com/andreweick/agepad/age"
+	"github.com/andreweick/agepad/filetype"
+	"github.com/andreweick/agepad/model"
+	"github.com/andreweick/agepad/valuecrypt"
+)
+
+// valueEncrypted reports whether path is a JSON or YAML file with only its
+// values encrypted (written by encrypt --values). Unreadable files are
+// left to the usual open to report.
+func valueEncrypted(path string, rules []model.TypeRule) bool {
+	format := filetype.FromName(path, rules)
+	if format != "json" && format != "yaml" {
+		return false
+	}
+	b, err := os.ReadFile(path)
+	return err == nil && valuecrypt.IsEncrypted(string(b), format)
+}
+
+// openValues checks the stanza policy against a value-encrypted file's
+// data key block and decrypts the file, returning the key for saves.
+func openValues(cfg model.Config, ids []age.Identity) (string, *valuecrypt.Key, error) {
+	b, err := os.ReadFile(cfg.FilePath)
+	if err != nil {
+		return "", nil, fmt.Errorf("open ciphertext: %w", err)
+	}
+	format := filetype.FromName(cfg.FilePath, cfg.TypeRules)
+	block, err := valuecrypt.KeyBlock(string(b), format)
+	if err != nil {
+		return "", nil, fmt.Errorf("%s: %w", cfg.FilePath, err)
+	}
+	if cfg.Stanzas != (model.StanzaPolicy{}) {
+		h, err := agepkg.InspectHeaderBytes(block)
+		if err != nil {
+			return "", nil, fmt.Errorf("%s: data key: %w", cfg.FilePath, err)
+		}
+		if err := checkHeader(cfg.FilePath, h, cfg.Stanzas); err != nil {
+			return "", nil, err
+		}
+	}
+	plain, key, err := valuecrypt.Decrypt(string(b), format, ids)
+	if err != nil {
+		return "", nil, fmt.Errorf("%s: %w", cfg.FilePath, err)
+	}
+	return plain, key, nil
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/andreweick/agepad/buildinfo"
+	"github.com/urfave/cli/v3"
+)
+
+func versionCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "version",
+		Usage: "Print the agepad version",
+		Flags: []cli.Flag{
+			&cli.BoolFlag{
+				Name:  "security",
+				Usage: "Also print the linked age library version and crypto defaults",
+			},
+			&cli.BoolFlag{
+				Name:  "json",
+				Usage: "Print as JSON (for SBOMs and release manifests)",
+			},
+		},
+		Action: func(ctx context.Context, cmd *cli.Command) error {
+			info := buildinfo.Read()
+			if cmd.Bool("json") {
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				return enc.Encode(info)
+			}
+			fmt.Printf("%s %s\n", appName, info.Version)
+			if !cmd.Bool("security") {
+				return nil
+			}
+			fmt.Printf("  go:                  %s\n", info.GoVersion)
+			fmt.Printf("  %s:      %s %s\n", buildinfo.AgeModule, info.AgeVersion, info.AgeSum)
+			if info.Revision != "" {
+				rev := info.Revision
+				if info.Modified {
+					rev += " (modified)"
+				}
+				fmt.Printf("  revision:            %s\n", rev)
+			}
+			fmt.Printf("  armor default:       %t\n", info.ArmorDefault)
+			fmt.Printf("  scrypt work factor:  %d (encrypt), up to %d accepted\n", info.ScryptEncryptWorkFactor, info.ScryptMaxWorkFactor)
+			fmt.Printf("  recipient types:     %s\n", strings.Join(info.RecipientTypes, ", "))
+			return nil
+		},
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/andreweick/agepad/audit"
+	"github.com/andreweick/agepad/model"
+	"github.com/andreweick/agepad/viewwindow"
+)
+
+// checkViewWindow asks before opening path outside its view window: the
+// --view-window policy or a "# agepad:view-window=" line in plain. The
+// answer is recorded in the audit log either way; a failure to record is
+// reported but does not change the outcome. A malformed annotation counts
+// as outside, so a typo cannot lift the restriction.
+func checkViewWindow(cfg model.Config, path, plain string) error {
+	var windows []viewwindow.Window
+	if cfg.ViewWindow != "" {
+		w, err := viewwindow.Parse(cfg.ViewWindow)
+		if err != nil {
+			return fmt.Errorf("--view-window: %w", err)
+		}
+		windows = append(windows, w)
+	}
+	found, ferr := viewwindow.Find(plain)
+	windows = append(windows, found...)
+	now := time.Now()
+	w, outside := viewwindow.Outside(windows, now)
+	if !outside && ferr == nil {
+		return nil
+	}
+
+	window := w.String()
+	question := fmt.Sprintf("%s may be opened %s; it is %s now. Open anyway (this is audited)?",
+		path, window, now.Format("Mon 15:04"))
+	if ferr != nil {
+		fmt.Fprintln(os.Stderr, "warning:", ferr)
+		window = "malformed"
+		question = fmt.Sprintf("%s has a malformed view window. Open anyway (this is audited)?", path)
+	}
+	mode := "edit"
+	if cfg.ViewOnly {
+		mode = "view"
+	}
+	result, err := "confirmed", error(nil)
+	switch {
+	case !isInteractive():
+		result, err = "refused", errors.New("confirmation needed but stdin is not a terminal")
+	case !askYes(question):
+		result, err = "refused", errors.New("not opened")
+	}
+	event := audit.Event{
+		Action: "view-window",
+		File:   path,
+		Details: map[string]string{
+			"window": window,
+			"mode":   mode,
+			"result": result,
+		},
+	}
+	if aerr := (audit.Log{Path: audit.DefaultPath()}).Record(event); aerr != nil {
+		fmt.Fprintln(os.Stderr, "warning:", aerr)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: outside its view window (%s): %w", path, window, err)
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"time"
+
+	agepkg "github.com/andreweick/agepad/age"
+	"github.com/andreweick/agepad/audit"
+	"github.com/andreweick/agepad/model"
+	"github.com/andreweick/agepad/webview"
+	"github.com/urfave/cli/v3"
+)
+
+func webCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "web",
+		Usage: "Show one decrypted file (Markdown, HTML, or text) read-only in a local browser through a one-time link; nothing is written to disk",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:     "file",
+				Usage:    "Encrypted file to show, e.g. runbook.md.age",
+				Required: true,
+			},
+			&cli.StringFlag{
+				Name:  "listen",
+				Usage: "Loopback address to serve on; port 0 picks a free one",
+				Value: "127.0.0.1:0",
+			},
+			&cli.DurationFlag{
+				Name:  "expires",
+				Usage: "Stop serving the page and clear it from memory after this long",
+				Value: 10 * time.Minute,
+			},
+			&cli.StringFlag{
+				Name:  "audit-log",
+				Usage: "Audit log recording the view",
+				Value: audit.DefaultPath(),
+			},
+		},
+		Action: runWeb,
+	}
+}
+
+func runWeb(ctx context.Context, cmd *cli.Command) error {
+	cfg := model.WebConfig{
+		FilePath:       cmd.String("file"),
+		Listen:         cmd.String("listen"),
+		Expires:        cmd.Duration("expires"),
+		IdentitiesPath: cmd.String("identities"),
+		AuditLog:       cmd.String("audit-log"),
+	}
+	if cfg.Expires <= 0 {
+		return fmt.Errorf("web: --expires must be positive")
+	}
+	host, _, err := net.SplitHostPort(cfg.Listen)
+	if err != nil {
+		return fmt.Errorf("web: --listen: %w", err)
+	}
+	if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+		return fmt.Errorf("web: --listen %s: the page is plaintext, so only loopback addresses (127.0.0.1, ::1, localhost) are served", cfg.Listen)
+	}
+	if err := checkStanzas(cfg.FilePath, stanzaPolicy(cmd)); err != nil {
+		return err
+	}
+
+	ids, err := agepkg.LoadIdentities(cfg.IdentitiesPath)
+	if err != nil {
+		return err
+	}
+	plain, err := agepkg.DecryptToMemory(cfg.FilePath, ids)
+	if err != nil {
+		return err
+	}
+	if plain, _, err = openShared(plain); err != nil {
+		return err
+	}
+	view := model.Config{ViewWindow: cmd.String("view-window"), ViewOnly: true}
+	if err := checkViewWindow(view, cfg.FilePath, plain); err != nil {
+		return err
+	}
+
+	ln, err := net.Listen("tcp", cfg.Listen)
+	if err != nil {
+		return fmt.Errorf("web: %w", err)
+	}
+	srv := webview.New(webview.Page(cfg.FilePath, plain, time.Now().Add(cfg.Expires)), cfg.Expires)
+	defer srv.Close()
+	event := audit.Event{
+		Action: "web",
+		File:   cfg.FilePath,
+		Details: map[string]string{
+			"listen":  ln.Addr().String(),
+			"expires": srv.Expires().UTC().Format(time.RFC3339),
+		},
+	}
+	if err := (audit.Log{Path: cfg.AuditLog}).Record(event); err != nil {
+		ln.Close()
+		return fmt.Errorf("web: not serving: %w", err)
+	}
+
+	hs := &http.Server{Handler: srv, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		if err := hs.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			fmt.Fprintln(os.Stderr, "web:", err)
+			srv.Close()
+		}
+	}()
+	fmt.Fprintf(os.Stderr, "Open this link once (it stops working after the first visit):\n\n  %s\n\n"+
+		"Serving %s read-only until %s; Ctrl+C stops sooner.\n",
+		srv.URL(ln.Addr()), cfg.FilePath, srv.Expires().Local().Format("15:04:05"))
+
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt)
+	defer stop()
+	select {
+	case <-ctx.Done():
+	case <-srv.Done():
+	}
+	srv.Close()
+	shutdown, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	_ = hs.Shutdown(shutdown)
+	fmt.Fprintln(os.Stderr, "web: page cleared")
+	return nil
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"sort"
+
+	"github.com/andreweick/agepad/config"
+	"github.com/andreweick/agepad/workspace"
+	"github.com/urfave/cli/v3"
+)
+
+func wsCommand() *cli.Command {
+	return &cli.Command{
+		Name:    "ws",
+		Aliases: []string{"workspace"},
+		Usage:   "Switch between named workspaces from workspace.yaml",
+		Commands: []*cli.Command{
+			{
+				Name:  "list",
+				Usage: "List workspaces, marking the active one",
+				Action: func(ctx context.Context, cmd *cli.Command) error {
+					f, err := workspace.Load(workspace.DefaultPath())
+					if err != nil {
+						return err
+					}
+					active, _ := activeWorkspace(cmd)
+					if len(f.Workspaces) == 0 {
+						fmt.Printf("No workspaces defined in %s\n", workspace.DefaultPath())
+						return nil
+					}
+					for _, n := range f.Names() {
+						mark := "  "
+						if n == active {
+							mark = "* "
+						}
+						fmt.Println(mark + n)
+					}
+					return nil
+				},
+			},
+			{
+				Name:      "use",
+				Usage:     "Make a workspace active for later invocations",
+				ArgsUsage: "NAME",
+				Action: func(ctx context.Context, cmd *cli.Command) error {
+					if cmd.NArg() != 1 {
+						return fmt.Errorf("ws use: expected one workspace name")
+					}
+					name := cmd.Args().First()
+					f, err := workspace.Load(workspace.DefaultPath())
+					if err != nil {
+						return err
+					}
+					if _, err := f.Get(name); err != nil {
+						return err
+					}
+					if err := workspace.Use(name); err != nil {
+						return err
+					}
+					fmt.Printf("Using workspace %q\n", name)
+					return nil
+				},
+			},
+			{
+				Name:  "show",
+				Usage: "Print the settings the active workspace applies",
+				Action: func(ctx context.Context, cmd *cli.Command) error {
+					name, w, err := loadActiveWorkspace(cmd)
+					if err != nil {
+						return err
+					}
+					if name == "" {
+						fmt.Println("No active workspace")
+						return nil
+					}
+					fmt.Printf("Workspace %q (%s)\n", name, workspace.DefaultPath())
+					flags := w.Flags()
+					names := make([]string, 0, len(flags))
+					for n := range flags {
+						names = append(names, n)
+					}
+					sort.Strings(names)
+					for _, n := range names {
+						for _, v := range flags[n] {
+							fmt.Printf("  --%s %s\n", n, v)
+						}
+					}
+					return nil
+				},
+			},
+			{
+				Name:  "clear",
+				Usage: "Stop using a workspace",
+				Action: func(ctx context.Context, cmd *cli.Command) error {
+					return workspace.Use("")
+				},
+			},
+		},
+	}
+}
+
+// activeWorkspace returns --workspace when given, else the remembered one.
+func activeWorkspace(cmd *cli.Command) (string, error) {
+	if name := cmd.String("workspace"); name != "" {
+		return name, nil
+	}
+	return workspace.Active()
+}
+
+func loadActiveWorkspace(cmd *cli.Command) (string, workspace.Workspace, error) {
+	name, err := activeWorkspace(cmd)
+	if err != nil || name == "" {
+		return "", workspace.Workspace{}, err
+	}
+	f, err := workspace.Load(workspace.DefaultPath())
+	if err != nil {
+		return "", workspace.Workspace{}, err
+	}
+	w, err := f.Get(name)
+	return name, w, err
+}
+
+// applyDefaults fills in the command's own flags from the active
+// workspace, then from the config files. Flags given on the command line
+// always win.
+func applyDefaults(ctx context.Context, cmd *cli.Command) (context.Context, error) {
+	_, w, err := loadActiveWorkspace(cmd)
+	if err != nil {
+		return ctx, err
+	}
+	c, err := config.LoadAll()
+	if err != nil {
+		return ctx, err
+	}
+	settings := c.Flags()
+	maps.Copy(settings, w.Flags())
+	identities := defaultIdentitiesPath()
+	if v, ok := settings["identities"]; ok {
+		identities = v[0]
+	}
+	if cmd.IsSet("identities") {
+		identities = cmd.String("identities")
+	}
+	if c, err = withSealed(c, identities); err != nil {
+		return ctx, err
+	}
+	settings = c.Flags()
+	maps.Copy(settings, w.Flags())
+	for _, f := range cmd.Flags {
+		name := f.Names()[0]
+		values, ok := settings[name]
+		if !ok || cmd.IsSet(name) {
+			continue
+		}
+		for _, v := range values {
+			if err := cmd.Set(name, v); err != nil {
+				return ctx, fmt.Errorf("workspace: --%s: %w", name, err)
+			}
+		}
+	}
+	return ctx, nil
+}
+
+// withDefaults installs applyDefaults on cmd and every subcommand.
+func withDefaults(cmd *cli.Command) {
+	before := cmd.Before
+	cmd.Before = func(ctx context.Context, c *cli.Command) (context.Context, error) {
+		ctx, err := applyDefaults(ctx, c)
+		if err != nil || before == nil {
+			return ctx, err
+		}
+		return before(ctx, c)
+	}
+	for _, sub := range cmd.Commands {
+		withDefaults(sub)
+	}
+}
@@ -0,0 +1,249 @@
+// Package config loads agepad's defaults from TOML files, so the same
+// --recipients-file and --identities need not be typed on every run:
+//
+//	identities = "~/.config/age/work.txt"
+//	recipients_file = "secrets/.age-recipients"
+//	armor = true
+//	lint = true
+//	theme = "mono"
+//	view_window = "09:00-18:00 mon-fri"
+//	directory = "ldaps://ldap.example.com/dc=example,dc=com"
+//
+//	[keys]
+//	save = "ctrl+s"
+//	quit = "ctrl+q,esc"
+//
+//	[[derived]]
+//	kind = "sha256sums"
+//	root = "secrets"
+//	out = "secrets/SHA256SUMS"
+//
+// The user's file is config.toml under $XDG_CONFIG_HOME/agepad (default
+// ~/.config/agepad; %AppData%\agepad on Windows). A repository can add
+// .agepad.toml at its top, whose settings win over the user's, and an
+// age-encrypted .agepad.toml.age for settings only its team should read,
+// which wins over both. Flags on the command line, and then the active
+// workspace, win over all of them.
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/andreweick/agepad/derived"
+	"github.com/andreweick/agepad/platform"
+	"github.com/pelletier/go-toml/v2"
+)
+
+// FileName is the user config file in the agepad config directory.
+const FileName = "config.toml"
+
+// LocalFileName is the repository config file, looked for in the working
+// directory and its parents up to the repository root.
+const LocalFileName = ".agepad.toml"
+
+// SealedFileName is the encrypted repository config file, found like
+// LocalFileName and decrypted by the caller (see Parse).
+const SealedFileName = LocalFileName + ".age"
+
+// Config holds the settings. Empty fields leave the corresponding default
+// alone.
+type Config struct {
+	Identities     string             `toml:"identities"`
+	RecipientsFile string             `toml:"recipients_file"`
+	Armor          *bool              `toml:"armor"`
+	Lint           *bool              `toml:"lint"` // the editor's secret lint before saves (package lint)
+	Theme          string             `toml:"theme"`
+	ViewWindow     string             `toml:"view_window"`
+	Directory      string             `toml:"directory"` // where --recipient-user/--recipient-group are looked up (package directory)
+	Keys           map[string]string  `toml:"keys"`      // editor action -> comma-separated keys
+	Stats          *bool              `toml:"stats"`     // keep the local usage ledger (package stats); only the user's file counts
+	Derived        []derived.Artifact `toml:"derived"`   // files regenerated after each save (package derived)
+}
+
+// UserPath returns the user config file path.
+func UserPath() string {
+	return filepath.Join(platform.ConfigDir(), "agepad", FileName)
+}
+
+// FindLocal returns the nearest .agepad.toml in dir or its parents,
+// stopping at the first directory holding .git, or "" if there is none.
+func FindLocal(dir string) string {
+	return findUp(dir, LocalFileName)
+}
+
+// FindSealed is FindLocal for .agepad.toml.age.
+func FindSealed(dir string) string {
+	return findUp(dir, SealedFileName)
+}
+
+func findUp(dir, name string) string {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return ""
+	}
+	for {
+		p := filepath.Join(dir, name)
+		if _, err := os.Stat(p); err == nil {
+			return p
+		}
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return ""
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// Load parses the config file at path. A missing file has no settings.
+// Relative paths in a repository file are taken from its directory, so
+// they work from anywhere in the repository.
+func Load(path string) (Config, error) {
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return Config{}, nil
+	}
+	if err != nil {
+		return Config{}, fmt.Errorf("config: %w", err)
+	}
+	return Parse(path, b)
+}
+
+// Parse parses the settings b read from path, as Load does; it is how the
+// decrypted content of .agepad.toml.age is read.
+func Parse(path string, b []byte) (Config, error) {
+	var c Config
+	dec := toml.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&c); err != nil {
+		var strict *toml.StrictMissingError
+		if errors.As(err, &strict) {
+			var names []string
+			for _, e := range strict.Errors {
+				names = append(names, strings.Join(e.Key(), "."))
+			}
+			return c, fmt.Errorf("config: %s: unknown setting %s", path, strings.Join(names, ", "))
+		}
+		return c, fmt.Errorf("config: %s: %w", path, err)
+	}
+	if base := filepath.Base(path); base == LocalFileName || base == SealedFileName {
+		dir := filepath.Dir(path)
+		paths := []*string{&c.Identities, &c.RecipientsFile}
+		if !strings.Contains(c.Directory, "://") {
+			paths = append(paths, &c.Directory)
+		}
+		for i := range c.Derived {
+			paths = append(paths, &c.Derived[i].Root, &c.Derived[i].Out)
+		}
+		for _, p := range paths {
+			if *p != "" && !filepath.IsAbs(*p) && !strings.HasPrefix(*p, "~") {
+				*p = filepath.Join(dir, *p)
+			}
+		}
+		for i := range c.Derived {
+			if c.Derived[i].Root == "" {
+				c.Derived[i].Root = dir
+			}
+		}
+	}
+	for _, a := range c.Derived {
+		if err := a.Check(); err != nil {
+			return c, fmt.Errorf("config: %s: %w", path, err)
+		}
+	}
+	return c, nil
+}
+
+// LoadAll loads the user file and the repository file for the working
+// directory, the latter's settings winning.
+func LoadAll() (Config, error) {
+	user, err := Load(UserPath())
+	if err != nil {
+		return user, err
+	}
+	local := FindLocal(".")
+	if local == "" {
+		return user, nil
+	}
+	repo, err := Load(local)
+	if err != nil {
+		return user, err
+	}
+	return user.Merge(repo), nil
+}
+
+// Merge returns c with the settings of over laid on top; derived files
+// declared in over replace c's. Stats is a personal choice, so a
+// repository file cannot turn it on or off.
+func (c Config) Merge(over Config) Config {
+	for _, f := range []struct{ dst, src *string }{
+		{&c.Identities, &over.Identities},
+		{&c.RecipientsFile, &over.RecipientsFile},
+		{&c.Theme, &over.Theme},
+		{&c.ViewWindow, &over.ViewWindow},
+		{&c.Directory, &over.Directory},
+	} {
+		if *f.src != "" {
+			*f.dst = *f.src
+		}
+	}
+	if over.Armor != nil {
+		c.Armor = over.Armor
+	}
+	if over.Lint != nil {
+		c.Lint = over.Lint
+	}
+	if len(over.Keys) > 0 {
+		keys := maps.Clone(c.Keys)
+		if keys == nil {
+			keys = map[string]string{}
+		}
+		maps.Copy(keys, over.Keys)
+		c.Keys = keys
+	}
+	if len(over.Derived) > 0 {
+		c.Derived = over.Derived
+	}
+	return c
+}
+
+// Flags maps the settings onto agepad flag names, with "~/" expanded in
+// paths. Key bindings and derived files are not flags.
+func (c Config) Flags() map[string][]string {
+	out := map[string][]string{}
+	for name, v := range map[string]string{
+		"identities":      expand(c.Identities),
+		"recipients-file": expand(c.RecipientsFile),
+		"theme":           c.Theme,
+		"view-window":     c.ViewWindow,
+		"directory":       c.Directory,
+	} {
+		if v != "" {
+			out[name] = []string{v}
+		}
+	}
+	if c.Armor != nil {
+		out["armor"] = []string{strconv.FormatBool(*c.Armor)}
+	}
+	if c.Lint != nil {
+		out["lint"] = []string{strconv.FormatBool(*c.Lint)}
+	}
+	return out
+}
+
+func expand(p string) string {
+	if p == "~" || strings.HasPrefix(p, "~/") {
+		home, _ := os.UserHomeDir()
+		return filepath.Join(home, strings.TrimPrefix(p, "~"))
+	}
+	return p
+}
@@ -0,0 +1,142 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoad(t *testing.T) {
+	write := func(t *testing.T, path, content string) {
+		t.Helper()
+		if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Run("maps settings to flags", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), FileName)
+		write(t, path, "identities = \"~/keys/work.txt\"\narmor = false\ntheme = \"mono\"\nview_window = \"09:00-18:00\"\n\n[keys]\nsave = \"ctrl+w\"\n")
+		c, err := Load(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		home, _ := os.UserHomeDir()
+		flags := c.Flags()
+		if flags["identities"][0] != filepath.Join(home, "keys/work.txt") || flags["armor"][0] != "false" || flags["theme"][0] != "mono" || flags["view-window"][0] != "09:00-18:00" {
+			t.Errorf("unexpected flags: %v", flags)
+		}
+		if _, ok := flags["recipients-file"]; ok {
+			t.Error("expected unset fields to be left out")
+		}
+		if c.Keys["save"] != "ctrl+w" {
+			t.Errorf("unexpected keys: %v", c.Keys)
+		}
+	})
+
+	t.Run("treats a missing file as empty", func(t *testing.T) {
+		c, err := Load(filepath.Join(t.TempDir(), "none.toml"))
+		if err != nil || len(c.Flags()) != 0 {
+			t.Errorf("expected no settings, got %+v (%v)", c, err)
+		}
+	})
+
+	t.Run("refuses unknown settings", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), FileName)
+		write(t, path, "recipient_file = \"typo\"\n")
+		if _, err := Load(path); err == nil {
+			t.Error("expected an error for a misspelled setting")
+		}
+	})
+
+	t.Run("finds the repository file and resolves its paths", func(t *testing.T) {
+		repo := t.TempDir()
+		write(t, filepath.Join(repo, ".git", "HEAD"), "ref: refs/heads/main\n")
+		write(t, filepath.Join(repo, LocalFileName), "recipients_file = \"secrets/.age-recipients\"\n")
+		sub := filepath.Join(repo, "services", "api")
+		if err := os.MkdirAll(sub, 0o700); err != nil {
+			t.Fatal(err)
+		}
+		path := FindLocal(sub)
+		if path != filepath.Join(repo, LocalFileName) {
+			t.Fatalf("FindLocal = %q", path)
+		}
+		c, err := Load(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := filepath.Join(repo, "secrets", ".age-recipients"); c.RecipientsFile != want {
+			t.Errorf("got %q, want %q", c.RecipientsFile, want)
+		}
+		if FindLocal(filepath.Join(t.TempDir())) != "" {
+			t.Error("expected no repository file outside the repository")
+		}
+	})
+
+	t.Run("parses the decrypted repository file like the plain one", func(t *testing.T) {
+		repo := t.TempDir()
+		write(t, filepath.Join(repo, ".git", "HEAD"), "ref: refs/heads/main\n")
+		write(t, filepath.Join(repo, SealedFileName), "ciphertext")
+		path := FindSealed(filepath.Join(repo))
+		if path != filepath.Join(repo, SealedFileName) || FindLocal(repo) != "" {
+			t.Fatalf("FindSealed = %q, FindLocal = %q", path, FindLocal(repo))
+		}
+		c, err := Parse(path, []byte("recipients_file = \"team.recips\"\n"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := filepath.Join(repo, "team.recips"); c.RecipientsFile != want {
+			t.Errorf("got %q, want %q", c.RecipientsFile, want)
+		}
+		if _, err := Parse(path, []byte("webhook = \"https://x\"\n")); err == nil {
+			t.Error("expected an error for an unknown setting")
+		}
+	})
+
+	t.Run("resolves a directory file but not a directory URL", func(t *testing.T) {
+		repo := t.TempDir()
+		path := filepath.Join(repo, LocalFileName)
+		c, err := Parse(path, []byte("directory = \"team/directory.toml\"\n"))
+		if err != nil || c.Directory != filepath.Join(repo, "team/directory.toml") {
+			t.Errorf("got %q, %v", c.Directory, err)
+		}
+		url := "ldaps://ldap.example.com/dc=example,dc=com"
+		if c, err = Parse(path, []byte("directory = \""+url+"\"\n")); err != nil || c.Flags()["directory"][0] != url {
+			t.Errorf("got %q, %v", c.Directory, err)
+		}
+	})
+
+	t.Run("resolves derived files from the repository", func(t *testing.T) {
+		repo := t.TempDir()
+		path := filepath.Join(repo, LocalFileName)
+		c, err := Parse(path, []byte("[[derived]]\nkind = \"manifest\"\nout = \"docs/manifest.json\"\n"))
+		if err != nil || len(c.Derived) != 1 {
+			t.Fatalf("got %+v, %v", c.Derived, err)
+		}
+		if a := c.Derived[0]; a.Root != repo || a.Out != filepath.Join(repo, "docs/manifest.json") {
+			t.Errorf("got %+v", a)
+		}
+		if _, err := Parse(path, []byte("[[derived]]\nkind = \"zip\"\nout = \"x\"\n")); err == nil {
+			t.Error("expected an unknown kind to be refused")
+		}
+	})
+
+	t.Run("lets the repository file win", func(t *testing.T) {
+		yes := true
+		user := Config{Identities: "user.txt", Theme: "mono", Keys: map[string]string{"save": "ctrl+w", "quit": "ctrl+q"}}
+		repo := Config{Identities: "repo.txt", Armor: &yes, Lint: &yes, Stats: &yes, Keys: map[string]string{"save": "ctrl+s"}}
+		c := user.Merge(repo)
+		if c.Identities != "repo.txt" || c.Theme != "mono" || c.Armor == nil || !*c.Armor || c.Lint == nil || !*c.Lint || c.Stats != nil {
+			t.Errorf("unexpected merge: %+v", c)
+		}
+		if c.Keys["save"] != "ctrl+s" || c.Keys["quit"] != "ctrl+q" {
+			t.Errorf("unexpected keys: %v", c.Keys)
+		}
+		if user.Keys["save"] != "ctrl+w" {
+			t.Error("merge changed the user config")
+		}
+	})
+}
@@ -0,0 +1,220 @@
+// Package control lets scripts and editor integrations drive a running
+// agepad session over a local Unix socket. Requests and responses are
+// JSON-RPC 2.0 objects, one per line, so a shell script can speak the
+// protocol with socat or nc -U as easily as a program can with Call.
+//
+// The socket is 0600, in a directory that must belong to the user and not
+// be writable by others, and on Linux clients running as another uid are
+// refused. Anyone who can connect can read diffs of the buffer, so keep
+// it somewhere private.
+package control
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/andreweick/agepad/platform"
+)
+
+// ErrNotRunning is returned by Call when no editor is listening.
+var ErrNotRunning = errors.New("no editor listening on the control socket")
+
+// SocketPath returns $AGEPAD_CONTROL, or control.sock under the platform's
+// runtime directory. Set AGEPAD_CONTROL per session to run several
+// controlled editors at once.
+func SocketPath() string {
+	if p := os.Getenv("AGEPAD_CONTROL"); p != "" {
+		return p
+	}
+	return filepath.Join(platform.RuntimeDir(), "control.sock")
+}
+
+// Error codes from the JSON-RPC 2.0 specification.
+const (
+	CodeParse          = -32700
+	CodeMethodNotFound = -32601
+	CodeInvalidParams  = -32602
+	CodeFailed         = -32000 // the editor refused or failed the request
+)
+
+// Request is one call.
+type Request struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+// Response answers a Request with the same ID.
+type Response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  any             `json:"result,omitempty"`
+	Error   *Error          `json:"error,omitempty"`
+}
+
+// Error is a JSON-RPC error object.
+type Error struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Errorf returns an error that reaches the client with code.
+func Errorf(code int, format string, args ...any) error {
+	return &Error{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+// Handler answers one method call. Errors other than *Error are reported
+// with CodeFailed.
+type Handler func(method string, params json.RawMessage) (any, error)
+
+// Listen creates the socket at path. A leftover socket from an editor that
+// has exited is replaced; a live one is an error. The directory must be
+// the user's own and not writable by others.
+func Listen(path string) (net.Listener, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, fmt.Errorf("control socket: %w", err)
+	}
+	if err := platform.CheckSocketDir(filepath.Dir(path)); err != nil {
+		return nil, fmt.Errorf("control socket: %w", err)
+	}
+	if c, err := net.Dial("unix", path); err == nil {
+		c.Close()
+		return nil, fmt.Errorf("control socket: another editor is listening at %s", path)
+	}
+	_ = os.Remove(path)
+	ln, err := platform.ListenUnix(path)
+	if err != nil {
+		return nil, fmt.Errorf("control socket: %w", err)
+	}
+	if err := os.Chmod(path, 0o600); err != nil {
+		ln.Close()
+		return nil, fmt.Errorf("control socket: %w", err)
+	}
+	return ln, nil
+}
+
+// Serve answers requests on ln with h until ctx is done. Each connection
+// may send any number of requests.
+func Serve(ctx context.Context, ln net.Listener, h Handler) error {
+	go func() {
+		<-ctx.Done()
+		ln.Close()
+	}()
+	for {
+		c, err := ln.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("control socket: %w", err)
+		}
+		go serveConn(c, h)
+	}
+}
+
+func serveConn(c net.Conn, h Handler) {
+	defer c.Close()
+	if err := checkPeer(c); err != nil {
+		_ = json.NewEncoder(c).Encode(Response{JSONRPC: "2.0", ID: json.RawMessage("null"), Error: &Error{Code: CodeFailed, Message: err.Error()}})
+		return
+	}
+	sc := bufio.NewScanner(c)
+	sc.Buffer(nil, 1<<20)
+	enc := json.NewEncoder(c)
+	for sc.Scan() {
+		if len(sc.Bytes()) == 0 {
+			continue
+		}
+		if err := enc.Encode(answer(sc.Bytes(), h)); err != nil {
+			return
+		}
+	}
+}
+
+// checkPeer refuses a client running as another uid. Where the kernel
+// cannot name the peer, the socket's file permissions guard it alone.
+func checkPeer(c net.Conn) error {
+	uid, err := platform.PeerUID(c)
+	if errors.Is(err, platform.ErrUnsupported) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("cannot identify the connecting process: %v", err)
+	}
+	if uid != os.Getuid() {
+		return fmt.Errorf("uid %d may not control this editor", uid)
+	}
+	return nil
+}
+
+// answer runs the request in line through h.
+func answer(line []byte, h Handler) Response {
+	resp := Response{JSONRPC: "2.0", ID: json.RawMessage("null")}
+	var req Request
+	if err := json.Unmarshal(line, &req); err != nil {
+		resp.Error = &Error{Code: CodeParse, Message: err.Error()}
+		return resp
+	}
+	if req.ID != nil {
+		resp.ID = req.ID
+	}
+	res, err := h(req.Method, req.Params)
+	if err != nil {
+		var e *Error
+		if !errors.As(err, &e) {
+			e = &Error{Code: CodeFailed, Message: err.Error()}
+		}
+		resp.Error = e
+		return resp
+	}
+	resp.Result = res
+	return resp
+}
+
+// Call sends one request to the editor at socket and decodes its result
+// into result, which may be nil. It returns ErrNotRunning when nothing is
+// listening and the editor's *Error when it refused the call.
+func Call(socket, method string, params, result any) error {
+	c, err := net.DialTimeout("unix", socket, time.Second)
+	if err != nil {
+		return ErrNotRunning
+	}
+	defer c.Close()
+	// A save may wait for a plugin's touch or PIN in the editor.
+	_ = c.SetDeadline(time.Now().Add(2 * time.Minute))
+	req := Request{JSONRPC: "2.0", ID: json.RawMessage("1"), Method: method}
+	if params != nil {
+		if req.Params, err = json.Marshal(params); err != nil {
+			return err
+		}
+	}
+	if err := json.NewEncoder(c).Encode(req); err != nil {
+		return fmt.Errorf("control socket: %w", err)
+	}
+	var resp struct {
+		Result json.RawMessage `json:"result"`
+		Error  *Error          `json:"error"`
+	}
+	if err := json.NewDecoder(c).Decode(&resp); err != nil {
+		return fmt.Errorf("control socket: %w", err)
+	}
+	if resp.Error != nil {
+		return resp.Error
+	}
+	if result == nil || resp.Result == nil {
+		return nil
+	}
+	return json.Unmarshal(resp.Result, result)
+}
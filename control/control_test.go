@@ -0,0 +1,110 @@
+package control
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "run", "control.sock")
+	ln, err := Listen(path)
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- Serve(ctx, ln, func(method string, params json.RawMessage) (any, error) {
+			switch method {
+			case "echo":
+				var p map[string]string
+				if err := json.Unmarshal(params, &p); err != nil {
+					return nil, Errorf(CodeInvalidParams, "echo: %v", err)
+				}
+				return p, nil
+			case "fail":
+				return nil, fmt.Errorf("nothing to save")
+			}
+			return nil, Errorf(CodeMethodNotFound, "unknown method %q", method)
+		})
+	}()
+
+	t.Run("round-trips params and results", func(t *testing.T) {
+		var got map[string]string
+		if err := Call(path, "echo", map[string]string{"key": "A"}, &got); err != nil {
+			t.Fatalf("Call failed: %v", err)
+		}
+		if got["key"] != "A" {
+			t.Errorf("unexpected result %v", got)
+		}
+	})
+
+	t.Run("returns editor errors with their code", func(t *testing.T) {
+		var e *Error
+		if err := Call(path, "fail", nil, nil); !errors.As(err, &e) || e.Code != CodeFailed || e.Message != "nothing to save" {
+			t.Errorf("unexpected error %v", err)
+		}
+		if err := Call(path, "nope", nil, nil); !errors.As(err, &e) || e.Code != CodeMethodNotFound {
+			t.Errorf("unexpected error %v", err)
+		}
+	})
+
+	t.Run("answers several requests per connection", func(t *testing.T) {
+		c, err := net.Dial("unix", path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer c.Close()
+		if _, err := c.Write([]byte("{\"jsonrpc\":\"2.0\",\"id\":7,\"method\":\"echo\",\"params\":{}}\nnot json\n")); err != nil {
+			t.Fatal(err)
+		}
+		dec := json.NewDecoder(c)
+		var first, second Response
+		if err := dec.Decode(&first); err != nil || string(first.ID) != "7" || first.Error != nil {
+			t.Errorf("unexpected first response %+v, %v", first, err)
+		}
+		if err := dec.Decode(&second); err != nil || second.Error == nil || second.Error.Code != CodeParse {
+			t.Errorf("unexpected second response %+v, %v", second, err)
+		}
+	})
+
+	t.Run("keeps the socket private", func(t *testing.T) {
+		if runtime.GOOS == "windows" {
+			t.Skip("no file modes on Windows")
+		}
+		if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0o600 {
+			t.Errorf("expected a 0600 socket, got %v, %v", info, err)
+		}
+		shared := filepath.Join(t.TempDir(), "shared")
+		if err := os.Mkdir(shared, 0o700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chmod(shared, 0o777); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := Listen(filepath.Join(shared, "control.sock")); err == nil {
+			t.Error("expected a world-writable directory to be refused")
+		}
+	})
+
+	t.Run("refuses a second listener", func(t *testing.T) {
+		if _, err := Listen(path); err == nil {
+			t.Error("expected Listen to refuse a live socket")
+		}
+	})
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("Serve returned %v", err)
+	}
+	if err := Call(path, "echo", nil, nil); !errors.Is(err, ErrNotRunning) {
+		t.Errorf("expected ErrNotRunning after shutdown, got %v", err)
+	}
+}
@@ -0,0 +1,255 @@
+// Package convert translates key/value content between the formats agepad
+// validates (.env, JSON, YAML, TOML), flattening or nesting keys as needed.
+package convert
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/andreweick/agepad/dotenv"
+	"github.com/andreweick/agepad/filetype"
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// Format names a supported content format.
+type Format string
+
+const (
+	Env  Format = "env"
+	JSON Format = "json"
+	YAML Format = "yaml"
+	TOML Format = "toml"
+)
+
+// ParseFormat accepts a format name or file extension (with or without dot).
+func ParseFormat(s string) (Format, error) {
+	switch strings.ToLower(strings.TrimPrefix(s, ".")) {
+	case "env", "dotenv":
+		return Env, nil
+	case "json":
+		return JSON, nil
+	case "yaml", "yml":
+		return YAML, nil
+	case "toml":
+		return TOML, nil
+	}
+	return "", fmt.Errorf("unsupported format %q (want env, json, yaml, or toml)", s)
+}
+
+// FormatFromPath infers the format from a file name, ignoring a trailing
+// .age suffix (app.env.age is env, config.yaml.age is yaml).
+func FormatFromPath(path string) (Format, error) {
+	f := filetype.FromName(path, nil)
+	if f == "" {
+		return "", fmt.Errorf("cannot infer format from %q; pass it explicitly", path)
+	}
+	return Format(f), nil
+}
+
+// Options controls how keys are mapped between flat and nested formats.
+type Options struct {
+	// Separator joins nested keys when flattening to env and splits env keys
+	// when nesting. Defaults to "_".
+	Separator string
+	// Nest splits env keys on Separator into nested tables when converting
+	// to JSON/YAML/TOML; otherwise keys stay flat.
+	Nest bool
+	// Upper upper-cases flattened env keys.
+	Upper bool
+}
+
+func (o Options) sep() string {
+	if o.Separator == "" {
+		return "_"
+	}
+	return o.Separator
+}
+
+// Convert decodes content in from and re-encodes it in to.
+func Convert(content string, from, to Format, opts Options) (string, error) {
+	data, err := Decode(content, from)
+	if err != nil {
+		return "", err
+	}
+	if to == Env {
+		return encodeEnv(Flatten(data, opts)), nil
+	}
+	if from == Env && opts.Nest {
+		data, err = nest(data, opts.sep())
+		if err != nil {
+			return "", err
+		}
+	}
+	return Encode(data, to)
+}
+
+// Decode parses content into a generic map.
+func Decode(content string, f Format) (map[string]any, error) {
+	out := map[string]any{}
+	switch f {
+	case Env:
+		kv, err := dotenv.Map(content)
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range kv {
+			out[k] = v
+		}
+	case JSON:
+		dec := json.NewDecoder(strings.NewReader(content))
+		dec.UseNumber()
+		if err := dec.Decode(&out); err != nil {
+			return nil, fmt.Errorf("JSON parse error: %w", err)
+		}
+		out = numbers(out).(map[string]any)
+	case YAML:
+		if err := yaml.Unmarshal([]byte(content), &out); err != nil {
+			return nil, fmt.Errorf("YAML parse error: %w", err)
+		}
+	case TOML:
+		if err := toml.Unmarshal([]byte(content), &out); err != nil {
+			return nil, fmt.Errorf("TOML parse error: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported format %q", f)
+	}
+	return out, nil
+}
+
+// Encode renders a generic map in the requested format. Env output requires
+// flat, scalar values (see Convert for flattening).
+func Encode(data map[string]any, f Format) (string, error) {
+	switch f {
+	case Env:
+		return encodeEnv(Flatten(data, Options{})), nil
+	case JSON:
+		b, err := json.MarshalIndent(data, "", "  ")
+		if err != nil {
+			return "", err
+		}
+		return string(b) + "\n", nil
+	case YAML:
+		b, err := yaml.Marshal(data)
+		return string(b), err
+	case TOML:
+		var buf bytes.Buffer
+		if err := toml.NewEncoder(&buf).Encode(data); err != nil {
+			return "", err
+		}
+		return buf.String(), nil
+	}
+	return "", fmt.Errorf("unsupported format %q", f)
+}
+
+// numbers replaces json.Number (kept exact while decoding) with int64 or
+// float64 so other encoders emit numbers rather than strings.
+func numbers(v any) any {
+	switch t := v.(type) {
+	case map[string]any:
+		for k, child := range t {
+			t[k] = numbers(child)
+		}
+	case []any:
+		for i, child := range t {
+			t[i] = numbers(child)
+		}
+	case json.Number:
+		if i, err := t.Int64(); err == nil {
+			return i
+		}
+		if f, err := t.Float64(); err == nil {
+			return f
+		}
+		return t.String()
+	}
+	return v
+}
+
+// Flatten turns nested maps and lists into KEY=value pairs joined by the
+// configured separator (lists use their index).
+func Flatten(data map[string]any, opts Options) map[string]string {
+	out := map[string]string{}
+	var walk func(prefix string, v any)
+	walk = func(prefix string, v any) {
+		switch t := v.(type) {
+		case map[string]any:
+			for k, child := range t {
+				walk(join(prefix, k, opts), child)
+			}
+		case []any:
+			for i, child := range t {
+				walk(join(prefix, strconv.Itoa(i), opts), child)
+			}
+		case nil:
+			out[prefix] = ""
+		default:
+			out[prefix] = fmt.Sprint(t)
+		}
+	}
+	for k, v := range data {
+		walk(join("", k, opts), v)
+	}
+	return out
+}
+
+func join(prefix, key string, opts Options) string {
+	if opts.Upper {
+		key = strings.ToUpper(key)
+	}
+	if prefix == "" {
+		return key
+	}
+	return prefix + opts.sep() + key
+}
+
+// nest splits flat keys on sep into nested maps.
+func nest(flat map[string]any, sep string) (map[string]any, error) {
+	out := map[string]any{}
+	keys := make([]string, 0, len(flat))
+	for k := range flat {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		parts := strings.Split(k, sep)
+		cur := out
+		for i, p := range parts[:len(parts)-1] {
+			next, ok := cur[p]
+			if !ok {
+				m := map[string]any{}
+				cur[p] = m
+				cur = m
+				continue
+			}
+			m, ok := next.(map[string]any)
+			if !ok {
+				return nil, fmt.Errorf("key %q conflicts with value at %q", k, strings.Join(parts[:i+1], sep))
+			}
+			cur = m
+		}
+		leaf := parts[len(parts)-1]
+		if _, ok := cur[leaf].(map[string]any); ok {
+			return nil, fmt.Errorf("key %q conflicts with nested keys under it", k)
+		}
+		cur[leaf] = flat[k]
+	}
+	return out, nil
+}
+
+func encodeEnv(kv map[string]string) string {
+	keys := make([]string, 0, len(kv))
+	for k := range kv {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	for _, k := range keys {
+		fmt.Fprintf(&b, "%s=%s\n", k, dotenv.Quote(kv[k]))
+	}
+	return b.String()
+}
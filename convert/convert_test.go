@@ -0,0 +1,112 @@
+package convert
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFormatFromPath(t *testing.T) {
+	t.Run("ignores the .age suffix", func(t *testing.T) {
+		cases := map[string]Format{
+			"secrets/app.env.age":      Env,
+			"secrets/.env.age":         Env,
+			"config.yaml.age":          YAML,
+			"config.yml":               YAML,
+			"settings.json.age":        JSON,
+			"/abs/path/infra.toml.age": TOML,
+		}
+		for path, want := range cases {
+			got, err := FormatFromPath(path)
+			if err != nil {
+				t.Errorf("FormatFromPath(%q) returned error: %v", path, err)
+				continue
+			}
+			if got != want {
+				t.Errorf("FormatFromPath(%q) = %q, want %q", path, got, want)
+			}
+		}
+	})
+
+	t.Run("rejects unknown extensions", func(t *testing.T) {
+		if _, err := FormatFromPath("notes.txt.age"); err == nil {
+			t.Error("expected error for unsupported extension")
+		}
+		if _, err := FormatFromPath("secrets.age"); err == nil {
+			t.Error("expected error when no inner extension is present")
+		}
+	})
+}
+
+func TestConvert(t *testing.T) {
+	t.Run("converts env to flat YAML", func(t *testing.T) {
+		out, err := Convert("DB_HOST=localhost\nDB_PASS=\"s3 cret\"\n", Env, YAML, Options{})
+		if err != nil {
+			t.Fatalf("convert failed: %v", err)
+		}
+		if !strings.Contains(out, "DB_HOST: localhost") || !strings.Contains(out, "DB_PASS: s3 cret") {
+			t.Errorf("unexpected YAML output:\n%s", out)
+		}
+	})
+
+	t.Run("nests env keys on the separator", func(t *testing.T) {
+		out, err := Convert("db__host=localhost\ndb__port=5432\n", Env, JSON, Options{Separator: "__", Nest: true})
+		if err != nil {
+			t.Fatalf("convert failed: %v", err)
+		}
+		if !strings.Contains(out, `"db": {`) || !strings.Contains(out, `"host": "localhost"`) {
+			t.Errorf("expected nested JSON, got:\n%s", out)
+		}
+	})
+
+	t.Run("reports nesting conflicts", func(t *testing.T) {
+		_, err := Convert("db=1\ndb_host=x\n", Env, YAML, Options{Nest: true})
+		if err == nil {
+			t.Error("expected conflict error")
+		}
+	})
+
+	t.Run("flattens nested YAML to env", func(t *testing.T) {
+		in := "db:\n  host: localhost\n  ports:\n    - 1\n    - 2\n"
+		out, err := Convert(in, YAML, Env, Options{Upper: true})
+		if err != nil {
+			t.Fatalf("convert failed: %v", err)
+		}
+		want := "DB_HOST=localhost\nDB_PORTS_0=1\nDB_PORTS_1=2\n"
+		if out != want {
+			t.Errorf("expected:\n%s\ngot:\n%s", want, out)
+		}
+	})
+
+	t.Run("quotes env values that need it", func(t *testing.T) {
+		out, err := Convert(`{"K": "a b"}`, JSON, Env, Options{})
+		if err != nil {
+			t.Fatalf("convert failed: %v", err)
+		}
+		if out != "K=\"a b\"\n" {
+			t.Errorf("unexpected env output %q", out)
+		}
+	})
+
+	t.Run("round-trips TOML through JSON", func(t *testing.T) {
+		json, err := Convert("[server]\nport = 8080\n", TOML, JSON, Options{})
+		if err != nil {
+			t.Fatalf("convert failed: %v", err)
+		}
+		back, err := Convert(json, JSON, TOML, Options{})
+		if err != nil {
+			t.Fatalf("convert back failed: %v", err)
+		}
+		if !strings.Contains(back, "[server]") || !strings.Contains(back, "port = 8080") {
+			t.Errorf("unexpected TOML output:\n%s", back)
+		}
+	})
+
+	t.Run("rejects malformed input", func(t *testing.T) {
+		if _, err := Convert("{not json", JSON, YAML, Options{}); err == nil {
+			t.Error("expected parse error")
+		}
+		if _, err := Convert("NOEQUALS\n", Env, YAML, Options{}); err == nil {
+			t.Error("expected env parse error")
+		}
+	})
+}
@@ -0,0 +1,64 @@
+package convert
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/andreweick/agepad/dotenv"
+	"github.com/andreweick/agepad/keyops"
+)
+
+// MergeResult lists the key names touched by Merge (never values).
+type MergeResult struct {
+	Added   []string
+	Updated []string
+}
+
+// Merge sets values into content of format f, creating keys that are
+// missing and overwriting those that exist. Env content is edited line by
+// line (nested values are flattened with opts); structured formats are
+// merged at the top level and re-encoded.
+func Merge(content string, f Format, values map[string]any, opts Options) (string, MergeResult, error) {
+	var res MergeResult
+	if f == Env {
+		flat := Flatten(values, opts)
+		keys := make([]string, 0, len(flat))
+		for k := range flat {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			var existed bool
+			content, existed = keyops.Set(content, k, dotenv.Quote(flat[k]))
+			if existed {
+				res.Updated = append(res.Updated, k)
+			} else {
+				res.Added = append(res.Added, k)
+			}
+		}
+		return content, res, nil
+	}
+
+	data := map[string]any{}
+	if strings.TrimSpace(content) != "" {
+		var err error
+		if data, err = Decode(content, f); err != nil {
+			return "", res, err
+		}
+	}
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if _, ok := data[k]; ok {
+			res.Updated = append(res.Updated, k)
+		} else {
+			res.Added = append(res.Added, k)
+		}
+		data[k] = values[k]
+	}
+	out, err := Encode(data, f)
+	return out, res, err
+}
@@ -0,0 +1,54 @@
+package convert
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMerge(t *testing.T) {
+	t.Run("merges into env content in place", func(t *testing.T) {
+		out, res, err := Merge("# app\nA=1\n", Env, map[string]any{"A": "2", "B": "x y"}, Options{})
+		if err != nil {
+			t.Fatalf("merge failed: %v", err)
+		}
+		if out != "# app\nA=2\nB=\"x y\"\n" {
+			t.Errorf("unexpected output %q", out)
+		}
+		if len(res.Added) != 1 || res.Added[0] != "B" || len(res.Updated) != 1 || res.Updated[0] != "A" {
+			t.Errorf("unexpected result %+v", res)
+		}
+	})
+
+	t.Run("flattens nested values for env", func(t *testing.T) {
+		out, _, err := Merge("", Env, map[string]any{"db": map[string]any{"host": "h"}}, Options{Upper: true})
+		if err != nil {
+			t.Fatalf("merge failed: %v", err)
+		}
+		if out != "DB_HOST=h\n" {
+			t.Errorf("unexpected output %q", out)
+		}
+	})
+
+	t.Run("merges top-level keys into YAML", func(t *testing.T) {
+		out, res, err := Merge("a: 1\n", YAML, map[string]any{"b": "2"}, Options{})
+		if err != nil {
+			t.Fatalf("merge failed: %v", err)
+		}
+		if !strings.Contains(out, "a: 1") || !strings.Contains(out, "b: \"2\"") {
+			t.Errorf("unexpected output:\n%s", out)
+		}
+		if len(res.Added) != 1 {
+			t.Errorf("expected one added key, got %+v", res)
+		}
+	})
+
+	t.Run("creates structured content from empty", func(t *testing.T) {
+		out, _, err := Merge("", JSON, map[string]any{"k": "v"}, Options{})
+		if err != nil {
+			t.Fatalf("merge failed: %v", err)
+		}
+		if !strings.Contains(out, `"k": "v"`) {
+			t.Errorf("unexpected output %q", out)
+		}
+	})
+}
@@ -0,0 +1,382 @@
+// Package daemon keeps decrypted files in locked memory for a limited time
+// and serves them to other agepad commands over a Unix socket, so plugin
+// and hardware identities are asked once per TTL instead of once per
+// command.
+//
+// The socket lives in a directory owned by the user that no one else may
+// write to, and is itself 0600. On Linux the daemon also checks each peer's uid and executable
+// (SO_PEERCRED), and an optional Policy limits which binaries may read
+// which files and keys.
+package daemon
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/andreweick/agepad/audit"
+	"github.com/andreweick/agepad/harden"
+	"github.com/andreweick/agepad/platform"
+)
+
+// ErrNotRunning is returned by clients when no daemon is listening.
+var ErrNotRunning = errors.New("daemon not running")
+
+// SocketPath returns $AGEPAD_SOCKET, or daemon.sock under the platform's
+// runtime directory ($XDG_RUNTIME_DIR/agepad, or a per-user directory in
+// the temp dir).
+func SocketPath() string {
+	if p := os.Getenv("AGEPAD_SOCKET"); p != "" {
+		return p
+	}
+	return filepath.Join(platform.RuntimeDir(), "daemon.sock")
+}
+
+type request struct {
+	Op   string `json:"op"` // "get", "stats", or "stop"
+	Path string `json:"path,omitempty"`
+	// For names the binary agepad run is about to exec. It is only
+	// honoured when the peer is this agepad binary itself.
+	For string `json:"for,omitempty"`
+}
+
+// peer is the process on the other end of a connection.
+type peer struct {
+	PID int
+	UID int
+	Exe string
+}
+
+type response struct {
+	Plain  string `json:"plain,omitempty"`
+	Cached int    `json:"cached,omitempty"`
+	Err    string `json:"error,omitempty"`
+}
+
+type entry struct {
+	hash    [sha256.Size]byte
+	plain   []byte
+	expires time.Time
+}
+
+// wipe zeroes and unlocks the cached plaintext.
+func (e *entry) wipe() {
+	clear(e.plain)
+	_ = harden.Unlock(e.plain)
+}
+
+// Server caches decrypted files for TTL, or less when the file carries a
+// caching hint (see cacheTTL). An entry is dropped early when the file's
+// ciphertext changes.
+type Server struct {
+	TTL     time.Duration
+	Decrypt func(cipher []byte) (string, error)
+	Now     func() time.Time // for tests; defaults to time.Now
+	Policy  *Policy          // nil allows any process of the same user
+	Self    string           // this binary's path, trusted to name a For binary
+	Audit   *audit.Log       // nil disables per-request audit records
+
+	mu    sync.Mutex
+	cache map[string]*entry
+	stop  context.CancelFunc
+}
+
+func (s *Server) now() time.Time {
+	if s.Now != nil {
+		return s.Now()
+	}
+	return time.Now()
+}
+
+// Get returns the plaintext of path, decrypting it unless a fresh copy is
+// cached.
+func (s *Server) Get(path string) (string, error) {
+	cipher, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	hash := sha256.Sum256(cipher)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cache == nil {
+		s.cache = map[string]*entry{}
+	}
+	if e, ok := s.cache[path]; ok {
+		if e.hash == hash && s.now().Before(e.expires) {
+			return string(e.plain), nil
+		}
+		e.wipe()
+		delete(s.cache, path)
+	}
+	// Decrypting under the lock serializes prompts from plugin identities.
+	plain, err := s.Decrypt(cipher)
+	if err != nil {
+		return "", err
+	}
+	ttl := cacheTTL(plain, s.TTL)
+	if ttl <= 0 {
+		return plain, nil
+	}
+	e := &entry{hash: hash, plain: []byte(plain), expires: s.now().Add(ttl)}
+	_ = harden.Lock(e.plain) // best effort; RLIMIT_MEMLOCK may be small
+	s.cache[path] = e
+	return plain, nil
+}
+
+// Sweep drops expired entries and returns how many remain.
+func (s *Server) Sweep() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for p, e := range s.cache {
+		if !s.now().Before(e.expires) {
+			e.wipe()
+			delete(s.cache, p)
+		}
+	}
+	return len(s.cache)
+}
+
+// Close wipes every cached entry.
+func (s *Server) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for p, e := range s.cache {
+		e.wipe()
+		delete(s.cache, p)
+	}
+}
+
+// Listen creates the socket at path. A leftover socket from a daemon that
+// is no longer running is replaced; a live one is an error. The directory
+// must be the user's own and not writable by others.
+func Listen(path string) (net.Listener, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, fmt.Errorf("daemon: %w", err)
+	}
+	if err := platform.CheckSocketDir(filepath.Dir(path)); err != nil {
+		return nil, fmt.Errorf("daemon: %w", err)
+	}
+	if c, err := net.Dial("unix", path); err == nil {
+		c.Close()
+		return nil, fmt.Errorf("daemon: already running at %s", path)
+	}
+	_ = os.Remove(path)
+	ln, err := platform.ListenUnix(path)
+	if err != nil {
+		return nil, fmt.Errorf("daemon: %w", err)
+	}
+	if err := os.Chmod(path, 0o600); err != nil {
+		ln.Close()
+		return nil, fmt.Errorf("daemon: %w", err)
+	}
+	return ln, nil
+}
+
+// Serve answers requests on ln until ctx is done or a client asks it to
+// stop, then wipes the cache.
+func (s *Server) Serve(ctx context.Context, ln net.Listener) error {
+	ctx, s.stop = context.WithCancel(ctx)
+	defer s.Close()
+	go func() {
+		<-ctx.Done()
+		ln.Close()
+	}()
+	go func() {
+		t := time.NewTicker(time.Minute)
+		defer t.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-t.C:
+				s.Sweep()
+			}
+		}
+	}()
+	for {
+		c, err := ln.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("daemon: %w", err)
+		}
+		go s.handle(c)
+	}
+}
+
+func (s *Server) handle(c net.Conn) {
+	defer c.Close()
+	_ = c.SetDeadline(time.Now().Add(time.Minute)) // decryption may wait for a touch or PIN
+	var req request
+	if err := json.NewDecoder(c).Decode(&req); err != nil {
+		return
+	}
+	var resp response
+	switch req.Op {
+	case "get":
+		resp = s.serveGet(c, req)
+	case "stats", "stop":
+		resp = s.serveControl(c, req.Op)
+	default:
+		resp.Err = "unknown op " + strconv.Quote(req.Op)
+	}
+	_ = json.NewEncoder(c).Encode(resp)
+}
+
+// serveGet authorizes and audits a get request before answering it.
+func (s *Server) serveGet(c net.Conn, req request) response {
+	p, perr := peerOf(c)
+	exe := p.Exe
+	if req.For != "" && p.Exe != "" && p.Exe == s.Self {
+		exe = req.For // agepad run fetching for the command it replaces itself with
+	}
+	rule, err := s.authorize(p, perr, exe, req.Path)
+	if s.Audit != nil {
+		if aerr := s.record("daemon-get", p, exe, req.Path, err); aerr != nil && err == nil {
+			err = aerr // no audit record, no secret
+		}
+	}
+	if err != nil {
+		return response{Err: err.Error()}
+	}
+	plain, err := s.Get(req.Path)
+	if err == nil && len(rule.Keys) > 0 {
+		plain, err = filterKeys(req.Path, plain, rule.Keys)
+	}
+	if err != nil {
+		return response{Err: err.Error()}
+	}
+	return response{Plain: plain}
+}
+
+// serveControl authorizes a stats or stop request before answering it.
+// Stopping is audited like a get.
+func (s *Server) serveControl(c net.Conn, op string) response {
+	p, perr := peerOf(c)
+	err := s.authorizeControl(p, perr)
+	if op == "stop" && s.Audit != nil {
+		if aerr := s.record("daemon-stop", p, p.Exe, "", err); aerr != nil && err == nil {
+			err = aerr
+		}
+	}
+	if err != nil {
+		return response{Err: err.Error()}
+	}
+	if op == "stats" {
+		return response{Cached: s.Sweep()}
+	}
+	s.stop()
+	return response{}
+}
+
+// checkPeer refuses a peer from another uid, or one that cannot be
+// identified when there is a Policy.
+func (s *Server) checkPeer(p peer, perr error) error {
+	if perr != nil {
+		if s.Policy != nil {
+			return fmt.Errorf("cannot identify the requesting process: %v", perr)
+		}
+		return nil
+	}
+	if p.UID != os.Getuid() {
+		return fmt.Errorf("uid %d may not use this daemon", p.UID)
+	}
+	return nil
+}
+
+// authorizeControl checks a stats or stop request. With a Policy, only
+// agepad itself or a binary some rule names may send one.
+func (s *Server) authorizeControl(p peer, perr error) error {
+	if err := s.checkPeer(p, perr); err != nil {
+		return err
+	}
+	if s.Policy == nil || (p.Exe != "" && p.Exe == s.Self) || s.Policy.Names(p.Exe) {
+		return nil
+	}
+	return fmt.Errorf("%s is not allowed to control this daemon", p.Exe)
+}
+
+// authorize checks the peer against the daemon's uid and Policy.
+func (s *Server) authorize(p peer, perr error, exe, path string) (Rule, error) {
+	if err := s.checkPeer(p, perr); err != nil {
+		return Rule{}, err
+	}
+	if s.Policy == nil {
+		return Rule{}, nil
+	}
+	r, ok := s.Policy.Match(exe, path)
+	if !ok {
+		return Rule{}, fmt.Errorf("%s is not allowed to read %s", exe, path)
+	}
+	return r, nil
+}
+
+// record writes one audit event for a get or stop request; denied
+// carries the reason a request was refused, if it was.
+func (s *Server) record(action string, p peer, exe, path string, denied error) error {
+	d := map[string]string{"exe": exe, "result": "allowed"}
+	if p.PID != 0 {
+		d["pid"] = strconv.Itoa(p.PID)
+	}
+	if exe != p.Exe {
+		d["via"] = p.Exe
+	}
+	if denied != nil {
+		d["result"] = "denied: " + denied.Error()
+	}
+	return s.Audit.Record(audit.Event{Action: action, File: path, Details: d})
+}
+
+// call sends one request to the daemon at socket.
+func call(socket string, req request) (response, error) {
+	c, err := net.DialTimeout("unix", socket, time.Second)
+	if err != nil {
+		return response{}, ErrNotRunning
+	}
+	defer c.Close()
+	if err := json.NewEncoder(c).Encode(req); err != nil {
+		return response{}, fmt.Errorf("daemon: %w", err)
+	}
+	var resp response
+	if err := json.NewDecoder(c).Decode(&resp); err != nil {
+		return response{}, fmt.Errorf("daemon: %w", err)
+	}
+	if resp.Err != "" {
+		return resp, fmt.Errorf("daemon: %s", resp.Err)
+	}
+	return resp, nil
+}
+
+// Get asks the daemon at socket for the plaintext of path. forExe, when
+// set, is the binary the caller will exec with it. It returns
+// ErrNotRunning when nothing is listening.
+func Get(socket, path, forExe string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	resp, err := call(socket, request{Op: "get", Path: abs, For: forExe})
+	return resp.Plain, err
+}
+
+// Stats returns how many files the daemon at socket has cached.
+func Stats(socket string) (int, error) {
+	resp, err := call(socket, request{Op: "stats"})
+	return resp.Cached, err
+}
+
+// Stop asks the daemon at socket to wipe its cache and exit.
+func Stop(socket string) error {
+	_, err := call(socket, request{Op: "stop"})
+	return err
+}
@@ -0,0 +1,210 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"filippo.io/age"
+	agepkg "github.com/andreweick/agepad/age"
+)
+
+func TestServerCache(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.env.age")
+	if err := os.WriteFile(path, []byte("cipher-1"), 0o600); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	now := time.Now()
+	decrypts := 0
+	s := &Server{
+		TTL: time.Minute,
+		Now: func() time.Time { return now },
+		Decrypt: func(cipher []byte) (string, error) {
+			decrypts++
+			return "plain:" + string(cipher), nil
+		},
+	}
+
+	t.Run("decrypts once within the TTL", func(t *testing.T) {
+		for i := 0; i < 3; i++ {
+			got, err := s.Get(path)
+			if err != nil || got != "plain:cipher-1" {
+				t.Fatalf("unexpected result %q, %v", got, err)
+			}
+		}
+		if decrypts != 1 {
+			t.Errorf("expected 1 decrypt, got %d", decrypts)
+		}
+	})
+
+	t.Run("re-decrypts when the ciphertext changes", func(t *testing.T) {
+		if err := os.WriteFile(path, []byte("cipher-2"), 0o600); err != nil {
+			t.Fatalf("failed to write file: %v", err)
+		}
+		got, err := s.Get(path)
+		if err != nil || got != "plain:cipher-2" {
+			t.Fatalf("unexpected result %q, %v", got, err)
+		}
+		if decrypts != 2 {
+			t.Errorf("expected 2 decrypts, got %d", decrypts)
+		}
+	})
+
+	t.Run("expires entries after the TTL", func(t *testing.T) {
+		now = now.Add(2 * time.Minute)
+		if n := s.Sweep(); n != 0 {
+			t.Errorf("expected an empty cache after sweep, got %d", n)
+		}
+		if _, err := s.Get(path); err != nil {
+			t.Fatalf("Get failed: %v", err)
+		}
+		if decrypts != 3 {
+			t.Errorf("expected 3 decrypts, got %d", decrypts)
+		}
+	})
+}
+
+func TestSocket(t *testing.T) {
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatalf("failed to generate identity: %v", err)
+	}
+	dir := t.TempDir()
+	file := filepath.Join(dir, "app.env.age")
+	if err := agepkg.AtomicEncryptWrite(file, []byte("KEY=value\n"), []age.Recipient{identity.Recipient()}, true); err != nil {
+		t.Fatalf("failed to encrypt: %v", err)
+	}
+	socket := filepath.Join(dir, "run", "d.sock")
+
+	t.Run("reports a missing daemon", func(t *testing.T) {
+		if _, err := Get(socket, file, ""); !errors.Is(err, ErrNotRunning) {
+			t.Errorf("expected ErrNotRunning, got %v", err)
+		}
+	})
+
+	ln, err := Listen(socket)
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	s := &Server{
+		TTL: time.Minute,
+		Decrypt: func(cipher []byte) (string, error) {
+			return agepkg.Decrypt(cipher, []age.Identity{identity})
+		},
+	}
+	done := make(chan error, 1)
+	go func() { done <- s.Serve(context.Background(), ln) }()
+
+	t.Run("keeps the socket private", func(t *testing.T) {
+		info, err := os.Stat(socket)
+		if err != nil {
+			t.Fatalf("stat failed: %v", err)
+		}
+		if info.Mode().Perm() != 0o600 {
+			t.Errorf("expected 0600 socket, got %v", info.Mode().Perm())
+		}
+	})
+
+	t.Run("refuses a directory others can write to", func(t *testing.T) {
+		if runtime.GOOS == "windows" {
+			t.Skip("no directory modes on Windows")
+		}
+		shared := filepath.Join(dir, "shared")
+		if err := os.Mkdir(shared, 0o700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chmod(shared, 0o777); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := Listen(filepath.Join(shared, "d.sock")); err == nil {
+			t.Error("expected a world-writable directory to be refused")
+		}
+	})
+
+	t.Run("refuses a second daemon", func(t *testing.T) {
+		if _, err := Listen(socket); err == nil {
+			t.Error("expected an error while a daemon is running")
+		}
+	})
+
+	t.Run("serves decrypted files", func(t *testing.T) {
+		got, err := Get(socket, file, "")
+		if err != nil || got != "KEY=value\n" {
+			t.Fatalf("unexpected result %q, %v", got, err)
+		}
+		if n, err := Stats(socket); err != nil || n != 1 {
+			t.Errorf("expected 1 cached file, got %d (%v)", n, err)
+		}
+	})
+
+	t.Run("reports decryption errors", func(t *testing.T) {
+		if _, err := Get(socket, filepath.Join(dir, "missing.age"), ""); err == nil || errors.Is(err, ErrNotRunning) {
+			t.Errorf("expected a daemon error, got %v", err)
+		}
+	})
+
+	t.Run("stops on request", func(t *testing.T) {
+		if err := Stop(socket); err != nil {
+			t.Fatalf("Stop failed: %v", err)
+		}
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Errorf("Serve returned %v", err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("daemon did not stop")
+		}
+	})
+}
+
+func TestCacheHints(t *testing.T) {
+	tests := []struct {
+		name  string
+		plain string
+		want  time.Duration
+	}{
+		{"no hints uses the daemon TTL", "A=1\n", time.Hour},
+		{"the shortest ttl wins", "# agepad:ttl=10m\nA=1\n# agepad:ttl=60s\nB=2\n", time.Minute},
+		{"hints cannot extend the TTL", "# agepad:ttl=5h\nA=1\n", time.Hour},
+		{"no-cache disables caching", "# agepad:ttl=10m\nA=1\n# agepad:no-cache\nB=2\n", 0},
+		{"ttl=0 disables caching", "# agepad:ttl=0\nA=1\n", 0},
+		{"a malformed ttl disables caching", "# agepad:ttl=soon\nA=1\n", 0},
+		{"indented yaml comments count", "db:\n  # agepad:ttl=30s\n  password: x\n", 30 * time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := cacheTTL(tt.plain, time.Hour); got != tt.want {
+				t.Errorf("cacheTTL = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("always-fresh files are decrypted on every request", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "token.env.age")
+		if err := os.WriteFile(path, []byte("cipher"), 0o600); err != nil {
+			t.Fatalf("failed to write file: %v", err)
+		}
+		decrypts := 0
+		s := &Server{TTL: time.Hour, Decrypt: func([]byte) (string, error) {
+			decrypts++
+			return "# agepad:no-cache\nTOKEN=x\n", nil
+		}}
+		for i := 0; i < 2; i++ {
+			if _, err := s.Get(path); err != nil {
+				t.Fatalf("Get failed: %v", err)
+			}
+		}
+		if decrypts != 2 {
+			t.Errorf("expected 2 decrypts, got %d", decrypts)
+		}
+		if n := s.Sweep(); n != 0 {
+			t.Errorf("expected nothing cached, got %d", n)
+		}
+	})
+}
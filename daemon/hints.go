@@ -0,0 +1,36 @@
+package daemon
+
+import (
+	"strings"
+	"time"
+)
+
+// hintPrefix starts a caching hint comment, e.g. "# agepad:ttl=60s" above a
+// rotating credential, or "# agepad:no-cache" for one that must always be
+// read fresh.
+const hintPrefix = "# agepad:"
+
+// cacheTTL returns how long plain may be cached: the shortest ttl hint in
+// it, capped at max. A no-cache hint, ttl=0, or a malformed ttl disables
+// caching, erring on the side of re-reading the file.
+func cacheTTL(plain string, max time.Duration) time.Duration {
+	ttl := max
+	for _, line := range strings.Split(plain, "\n") {
+		hint, ok := strings.CutPrefix(strings.TrimSpace(line), hintPrefix)
+		if !ok {
+			continue
+		}
+		hint = strings.TrimSpace(hint)
+		switch {
+		case hint == "no-cache":
+			return 0
+		case strings.HasPrefix(hint, "ttl="):
+			d, err := time.ParseDuration(strings.TrimPrefix(hint, "ttl="))
+			if err != nil || d <= 0 {
+				return 0
+			}
+			ttl = min(ttl, d)
+		}
+	}
+	return ttl
+}
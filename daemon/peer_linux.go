@@ -0,0 +1,41 @@
+package daemon
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+
+	"golang.org/x/sys/unix"
+)
+
+// PeerCheck names how the daemon identifies its clients on this platform.
+const PeerCheck = "SO_PEERCRED"
+
+// peerOf identifies the process on the other end of c from the kernel's
+// SO_PEERCRED and /proc/<pid>/exe, which the peer cannot forge.
+func peerOf(c net.Conn) (peer, error) {
+	uc, ok := c.(*net.UnixConn)
+	if !ok {
+		return peer{}, fmt.Errorf("not a unix socket")
+	}
+	raw, err := uc.SyscallConn()
+	if err != nil {
+		return peer{}, err
+	}
+	var cred *unix.Ucred
+	var credErr error
+	if err := raw.Control(func(fd uintptr) {
+		cred, credErr = unix.GetsockoptUcred(int(fd), unix.SOL_SOCKET, unix.SO_PEERCRED)
+	}); err != nil {
+		return peer{}, err
+	}
+	if credErr != nil {
+		return peer{}, credErr
+	}
+	exe, err := os.Readlink("/proc/" + strconv.Itoa(int(cred.Pid)) + "/exe")
+	if err != nil {
+		return peer{}, err
+	}
+	return peer{PID: int(cred.Pid), UID: int(cred.Uid), Exe: exe}, nil
+}
@@ -0,0 +1,18 @@
+//go:build !linux
+
+package daemon
+
+import (
+	"errors"
+	"net"
+)
+
+// PeerCheck is empty: clients cannot be identified here, so only the
+// socket's file permissions guard it.
+const PeerCheck = ""
+
+// peerOf is only implemented on Linux; elsewhere a policy refuses every
+// request.
+func peerOf(c net.Conn) (peer, error) {
+	return peer{}, errors.New("peer identification is not supported on this platform")
+}
@@ -0,0 +1,109 @@
+package daemon
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/andreweick/agepad/dotenv"
+	"github.com/andreweick/agepad/filetype"
+	"gopkg.in/yaml.v3"
+)
+
+// Rule allows one executable to read matching files, optionally limited
+// to some keys of .env content.
+type Rule struct {
+	Exe   string   `yaml:"exe"`   // absolute path or absolute glob of the requesting binary
+	Files []string `yaml:"files"` // globs; bare names match the base name; empty allows every file
+	Keys  []string `yaml:"keys"`  // keys returned from .env files; empty returns the whole file
+}
+
+// Policy is the daemon's allowlist. With a policy, requests that match no
+// rule are refused.
+type Policy struct {
+	Allow []Rule `yaml:"allow"`
+}
+
+// LoadPolicy reads a policy file:
+//
+//	allow:
+//	  - exe: /usr/local/bin/kubectl
+//	    files: [k8s.env.age]
+//	    keys: [KUBE_TOKEN]
+func LoadPolicy(path string) (*Policy, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("daemon policy: %w", err)
+	}
+	var p Policy
+	if err := yaml.Unmarshal(b, &p); err != nil {
+		return nil, fmt.Errorf("daemon policy: %s: %w", path, err)
+	}
+	for i, r := range p.Allow {
+		if r.Exe == "" {
+			return nil, fmt.Errorf("daemon policy: %s: rule %d has no exe", path, i+1)
+		}
+		if !filepath.IsAbs(r.Exe) {
+			return nil, fmt.Errorf("daemon policy: %s: rule %d: exe %q must be an absolute path", path, i+1, r.Exe)
+		}
+	}
+	return &p, nil
+}
+
+// Match returns the first rule allowing exe to read file.
+func (p *Policy) Match(exe, file string) (Rule, bool) {
+	for _, r := range p.Allow {
+		if !matchExe(r.Exe, exe) {
+			continue
+		}
+		if len(r.Files) == 0 || slices.ContainsFunc(r.Files, func(pat string) bool { return match(pat, file) }) {
+			return r, true
+		}
+	}
+	return Rule{}, false
+}
+
+// Names reports whether some rule allows exe to read anything.
+func (p *Policy) Names(exe string) bool {
+	return slices.ContainsFunc(p.Allow, func(r Rule) bool { return matchExe(r.Exe, exe) })
+}
+
+// matchExe reports whether exe matches an absolute pattern. Unlike files,
+// a bare name never matches, or any binary of that name would pass.
+func matchExe(pattern, exe string) bool {
+	if !filepath.IsAbs(pattern) {
+		return false
+	}
+	ok, _ := filepath.Match(pattern, exe)
+	return ok
+}
+
+// match reports whether name matches pattern; patterns without a slash
+// are matched against the base name.
+func match(pattern, name string) bool {
+	if !strings.Contains(pattern, "/") {
+		name = filepath.Base(name)
+	}
+	ok, _ := filepath.Match(pattern, name)
+	return ok
+}
+
+// filterKeys keeps only keys of .env content, dropping comments.
+func filterKeys(path, plain string, keys []string) (string, error) {
+	if f := filetype.FromName(path, nil); f != "" && f != "env" {
+		return "", fmt.Errorf("key allowlists only apply to .env files, not %s", f)
+	}
+	entries, err := dotenv.Parse(plain)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	for _, e := range entries {
+		if slices.Contains(keys, e.Key) {
+			b.WriteString(e.Key + "=" + dotenv.Quote(e.Value) + "\n")
+		}
+	}
+	return b.String(), nil
+}
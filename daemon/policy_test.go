@@ -0,0 +1,195 @@
+package daemon
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/andreweick/agepad/audit"
+)
+
+func TestPolicy(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "allow.yaml")
+	content := `allow:
+  - exe: /usr/bin/kubectl
+    files: [k8s.env.age]
+    keys: [KUBE_TOKEN]
+  - exe: /opt/app/*
+    files: ["/srv/secrets/*"]
+`
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("failed to write policy: %v", err)
+	}
+	p, err := LoadPolicy(path)
+	if err != nil {
+		t.Fatalf("LoadPolicy failed: %v", err)
+	}
+
+	t.Run("matches exe and file", func(t *testing.T) {
+		r, ok := p.Match("/usr/bin/kubectl", "/home/me/infra/k8s.env.age")
+		if !ok || len(r.Keys) != 1 {
+			t.Errorf("expected kubectl rule, got %+v, %v", r, ok)
+		}
+		if _, ok := p.Match("/opt/app/server", "/srv/secrets/app.env.age"); !ok {
+			t.Error("expected glob exe and full-path file pattern to match")
+		}
+	})
+
+	t.Run("refuses other binaries and files", func(t *testing.T) {
+		if _, ok := p.Match("/usr/bin/env", "/home/me/infra/k8s.env.age"); ok {
+			t.Error("expected /usr/bin/env to be refused")
+		}
+		if _, ok := p.Match("/usr/bin/kubectl", "/home/me/infra/db.env.age"); ok {
+			t.Error("expected kubectl to be refused db.env.age")
+		}
+	})
+
+	t.Run("denies a binary of the same name elsewhere", func(t *testing.T) {
+		for _, exe := range []string{"/tmp/kubectl", "/home/me/evil/kubectl"} {
+			if _, ok := p.Match(exe, "/home/me/infra/k8s.env.age"); ok {
+				t.Errorf("expected %s to be refused", exe)
+			}
+		}
+		bare := &Policy{Allow: []Rule{{Exe: "kubectl"}}}
+		if _, ok := bare.Match("/tmp/kubectl", "k8s.env.age"); ok || bare.Names("/tmp/kubectl") {
+			t.Error("expected a bare exe name to match nothing")
+		}
+	})
+
+	t.Run("requires an absolute exe on every rule", func(t *testing.T) {
+		bad := filepath.Join(dir, "bad.yaml")
+		if err := os.WriteFile(bad, []byte("allow:\n  - files: [x]\n"), 0o600); err != nil {
+			t.Fatalf("failed to write policy: %v", err)
+		}
+		if _, err := LoadPolicy(bad); err == nil {
+			t.Error("expected an error for a rule without exe")
+		}
+		if err := os.WriteFile(bad, []byte("allow:\n  - exe: kubectl\n"), 0o600); err != nil {
+			t.Fatalf("failed to write policy: %v", err)
+		}
+		if _, err := LoadPolicy(bad); err == nil {
+			t.Error("expected an error for an exe that is not an absolute path")
+		}
+	})
+}
+
+func TestFilterKeys(t *testing.T) {
+	t.Run("keeps only allowed keys", func(t *testing.T) {
+		got, err := filterKeys("app.env.age", "# comment\nA=1\nB=\"two words\"\nC=3\n", []string{"B", "C"})
+		if err != nil {
+			t.Fatalf("filterKeys failed: %v", err)
+		}
+		if strings.Contains(got, "A=") || !strings.Contains(got, "C=3") || !strings.Contains(got, "B=") {
+			t.Errorf("unexpected result: %q", got)
+		}
+	})
+
+	t.Run("refuses structured formats", func(t *testing.T) {
+		if _, err := filterKeys("app.json.age", `{"A":1}`, []string{"A"}); err == nil {
+			t.Error("expected an error for JSON")
+		}
+	})
+}
+
+func TestPeerPolicy(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("peer identification is Linux-only")
+	}
+	self, err := os.Executable()
+	if err != nil {
+		t.Fatalf("os.Executable failed: %v", err)
+	}
+	dir := t.TempDir()
+	file := filepath.Join(dir, "app.env.age")
+	if err := os.WriteFile(file, []byte("cipher"), 0o600); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	log := &audit.Log{Path: filepath.Join(dir, "audit.log")}
+	socket := filepath.Join(dir, "d.sock")
+	ln, err := Listen(socket)
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	s := &Server{
+		TTL:     time.Minute,
+		Decrypt: func([]byte) (string, error) { return "A=1\nB=2\n", nil },
+		Policy:  &Policy{Allow: []Rule{{Exe: "/usr/bin/kubectl", Keys: []string{"B"}}}},
+		Audit:   log,
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.Serve(ctx, ln)
+
+	t.Run("refuses binaries outside the policy", func(t *testing.T) {
+		if _, err := Get(socket, file, ""); err == nil || !strings.Contains(err.Error(), "not allowed") {
+			t.Errorf("expected a policy refusal, got %v", err)
+		}
+	})
+
+	t.Run("ignores For from other binaries", func(t *testing.T) {
+		if _, err := Get(socket, file, "/usr/bin/kubectl"); err == nil {
+			t.Error("expected For to be ignored when the peer is not agepad")
+		}
+	})
+
+	t.Run("honours For from agepad itself", func(t *testing.T) {
+		s.Self = self
+		got, err := Get(socket, file, "/usr/bin/kubectl")
+		if err != nil {
+			t.Fatalf("Get failed: %v", err)
+		}
+		if got != "B=2\n" {
+			t.Errorf("expected only allowed keys, got %q", got)
+		}
+	})
+
+	t.Run("audits every request", func(t *testing.T) {
+		events, err := log.Read()
+		if err != nil {
+			t.Fatalf("failed to read audit log: %v", err)
+		}
+		if len(events) != 3 {
+			t.Fatalf("expected 3 events, got %d", len(events))
+		}
+		if !strings.HasPrefix(events[0].Details["result"], "denied") || events[2].Details["result"] != "allowed" {
+			t.Errorf("unexpected results: %v / %v", events[0].Details, events[2].Details)
+		}
+		if events[2].Details["via"] != self {
+			t.Errorf("expected via to name the agepad binary, got %q", events[2].Details["via"])
+		}
+	})
+	t.Run("checks the peer for stats and stop", func(t *testing.T) {
+		s.Self = ""
+		if _, err := Stats(socket); err == nil || !strings.Contains(err.Error(), "not allowed") {
+			t.Errorf("expected stats to be refused, got %v", err)
+		}
+		if err := Stop(socket); err == nil {
+			t.Fatal("expected stop to be refused")
+		}
+		s.Self = self
+		if _, err := Stats(socket); err != nil {
+			t.Errorf("expected stats from agepad itself, got %v", err)
+		}
+		if err := Stop(socket); err != nil {
+			t.Fatalf("expected stop from agepad itself, got %v", err)
+		}
+		events, err := log.Read()
+		if err != nil {
+			t.Fatalf("failed to read audit log: %v", err)
+		}
+		var stops []string
+		for _, e := range events {
+			if e.Action == "daemon-stop" {
+				stops = append(stops, e.Details["result"])
+			}
+		}
+		if len(stops) != 2 || !strings.HasPrefix(stops[0], "denied") || stops[1] != "allowed" {
+			t.Errorf("expected a denied and an allowed stop, got %q", stops)
+		}
+	})
+}
@@ -0,0 +1,311 @@
+// Package derived keeps files that are generated from a tree of .age
+// files in step with it. A repository declares them in .agepad.toml:
+//
+//	[[derived]]
+//	kind = "sha256sums"   # SHA256SUMS of the ciphertexts
+//	root = "secrets"
+//	out = "secrets/SHA256SUMS"
+//
+//	[[derived]]
+//	kind = "manifest"     # key names per file, as JSON
+//	root = "secrets"
+//	out = "docs/secrets-manifest.json"
+//
+//	[[derived]]
+//	kind = "catalog"      # redacted copies, as agepad redact-tree writes
+//	root = "secrets"
+//	out = "docs/secrets-catalog"
+//
+// The editor updates the artifacts covering a file after each save, from
+// the plaintext it already holds; Rebuild regenerates one from the whole
+// tree. No artifact holds a value.
+package derived
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/andreweick/agepad/outline"
+	"github.com/andreweick/agepad/redact"
+	"github.com/andreweick/agepad/walk"
+)
+
+// The kinds of artifact.
+const (
+	SHA256Sums = "sha256sums"
+	Manifest   = "manifest"
+	Catalog    = "catalog"
+)
+
+// Artifact is one derived file (a directory, for a catalog) and the tree
+// it is generated from.
+type Artifact struct {
+	Kind string `toml:"kind"`
+	Root string `toml:"root"` // default "."
+	Out  string `toml:"out"`
+}
+
+// Check reports a malformed declaration.
+func (a Artifact) Check() error {
+	switch {
+	case a.Kind != SHA256Sums && a.Kind != Manifest && a.Kind != Catalog:
+		return fmt.Errorf("derived: unknown kind %q (want %s, %s, or %s)", a.Kind, SHA256Sums, Manifest, Catalog)
+	case a.Out == "":
+		return fmt.Errorf("derived: %s needs an out path", a.Kind)
+	}
+	return nil
+}
+
+func (a Artifact) root() string {
+	if a.Root == "" {
+		return "."
+	}
+	return a.Root
+}
+
+// rel returns path relative to the artifact's root, in slash form, and
+// whether the root holds it.
+func (a Artifact) rel(path string) (string, bool) {
+	root, err := filepath.Abs(a.root())
+	if err != nil {
+		return "", false
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", false
+	}
+	rel, err := filepath.Rel(root, abs)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	return filepath.ToSlash(rel), true
+}
+
+// Saved brings a up to date after path was saved with plain, whose format
+// is format ("env", "json", "yaml", or "toml"). A file outside a's root
+// changes nothing. It reports whether anything was written.
+func (a Artifact) Saved(path, plain, format string) (bool, error) {
+	rel, ok := a.rel(path)
+	if !ok {
+		return false, nil
+	}
+	switch a.Kind {
+	case SHA256Sums:
+		return a.writeSums()
+	case Manifest:
+		keys, err := keyNames(plain, format)
+		if err != nil {
+			return false, fmt.Errorf("derived %s: %s: %w", a.Out, rel, err)
+		}
+		m, err := a.readManifest()
+		if err != nil {
+			return false, err
+		}
+		m[rel] = keys
+		return a.writeManifest(m)
+	case Catalog:
+		return a.writeCatalogEntry(rel, plain, format)
+	}
+	return false, a.Check()
+}
+
+// Rebuild regenerates a from every .age file under its root; open returns
+// a file's plaintext and format. With check, nothing is written and
+// Rebuild reports whether a is out of date.
+func (a Artifact) Rebuild(open func(path string) (plain, format string, err error), check bool) (bool, error) {
+	if err := a.Check(); err != nil {
+		return false, err
+	}
+	if a.Kind == SHA256Sums {
+		b, err := a.sums()
+		if err != nil {
+			return false, err
+		}
+		return write(a.Out, b, check)
+	}
+	files, err := walk.AgeFiles(a.root())
+	if err != nil {
+		return false, fmt.Errorf("derived %s: %w", a.Out, err)
+	}
+	m := map[string][]string{}
+	changed := false
+	for _, f := range files {
+		rel, _ := a.rel(f)
+		plain, format, err := open(f)
+		if err != nil {
+			return changed, fmt.Errorf("derived %s: %s: %w", a.Out, rel, err)
+		}
+		switch a.Kind {
+		case Manifest:
+			if m[rel], err = keyNames(plain, format); err != nil {
+				return changed, fmt.Errorf("derived %s: %s: %w", a.Out, rel, err)
+			}
+		case Catalog:
+			b, err := CatalogEntry(rel, plain, format)
+			if err != nil {
+				return changed, fmt.Errorf("derived %s: %s: %w", a.Out, rel, err)
+			}
+			c, err := write(a.catalogPath(rel), b, check)
+			if err != nil {
+				return changed, err
+			}
+			changed = changed || c
+		}
+	}
+	if a.Kind == Manifest {
+		b, err := encodeManifest(m)
+		if err != nil {
+			return false, err
+		}
+		return write(a.Out, b, check)
+	}
+	return changed, nil
+}
+
+// sums lists the SHA-256 of every ciphertext under the root, in the format
+// sha256sum -c reads from the root.
+func (a Artifact) sums() ([]byte, error) {
+	files, err := walk.AgeFiles(a.root())
+	if err != nil {
+		return nil, fmt.Errorf("derived %s: %w", a.Out, err)
+	}
+	var b bytes.Buffer
+	for _, f := range files {
+		cipher, err := os.ReadFile(f)
+		if err != nil {
+			return nil, fmt.Errorf("derived %s: %w", a.Out, err)
+		}
+		rel, _ := a.rel(f)
+		sum := sha256.Sum256(cipher)
+		fmt.Fprintf(&b, "%s  %s\n", hex.EncodeToString(sum[:]), rel)
+	}
+	return b.Bytes(), nil
+}
+
+func (a Artifact) writeSums() (bool, error) {
+	b, err := a.sums()
+	if err != nil {
+		return false, err
+	}
+	return write(a.Out, b, false)
+}
+
+// keyNames lists the keys (dotted paths for structured formats) content
+// defines, in document order.
+func keyNames(content, format string) ([]string, error) {
+	if format == "" {
+		return nil, errors.New("unknown format; name it with --type-map")
+	}
+	syms, err := outline.Symbols(content, format)
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]string, 0, len(syms))
+	for _, s := range syms {
+		keys = append(keys, s.Name)
+	}
+	return keys, nil
+}
+
+// readManifest reads the manifest, leaving out files no longer under the
+// root.
+func (a Artifact) readManifest() (map[string][]string, error) {
+	m := map[string][]string{}
+	b, err := os.ReadFile(a.Out)
+	if errors.Is(err, fs.ErrNotExist) {
+		return m, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("derived: %w", err)
+	}
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, fmt.Errorf("derived %s: %w; fix or delete it and run agepad derive", a.Out, err)
+	}
+	for rel := range m {
+		if _, err := os.Stat(filepath.Join(a.root(), filepath.FromSlash(rel))); errors.Is(err, fs.ErrNotExist) {
+			delete(m, rel)
+		}
+	}
+	return m, nil
+}
+
+func (a Artifact) writeManifest(m map[string][]string) (bool, error) {
+	b, err := encodeManifest(m)
+	if err != nil {
+		return false, err
+	}
+	return write(a.Out, b, false)
+}
+
+// encodeManifest writes the manifest as indented JSON, files in order.
+func encodeManifest(m map[string][]string) ([]byte, error) {
+	for rel, keys := range m {
+		if keys == nil {
+			m[rel] = []string{}
+		}
+	}
+	b, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(b, '\n'), nil
+}
+
+func (a Artifact) catalogPath(rel string) string {
+	return filepath.Join(a.Out, filepath.FromSlash(strings.TrimSuffix(rel, ".age")))
+}
+
+func (a Artifact) writeCatalogEntry(rel, plain, format string) (bool, error) {
+	b, err := CatalogEntry(rel, plain, format)
+	if err != nil {
+		return false, fmt.Errorf("derived %s: %s: %w", a.Out, rel, err)
+	}
+	return write(a.catalogPath(rel), b, false)
+}
+
+// CatalogEntry returns the redacted catalog copy of the file at rel (its
+// path under the catalog's root). Formats that keep comments get a header
+// naming the source, so nobody edits the copy.
+func CatalogEntry(rel, plain, format string) ([]byte, error) {
+	if format == "" {
+		return nil, errors.New("unknown format; name it with --type-map")
+	}
+	out, err := redact.Redact(plain, format)
+	if err != nil {
+		return nil, err
+	}
+	if format != "json" {
+		out = fmt.Sprintf("# Generated by agepad redact-tree from %s; values are redacted.\n", rel) + out
+	}
+	return []byte(out), nil
+}
+
+// write replaces path with b unless it already holds it, and reports
+// whether it differed. With check it only compares.
+func write(path string, b []byte, check bool) (bool, error) {
+	old, err := os.ReadFile(path)
+	if err == nil && bytes.Equal(old, b) {
+		return false, nil
+	}
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return false, fmt.Errorf("derived: %w", err)
+	}
+	if check {
+		return true, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return false, fmt.Errorf("derived: %w", err)
+	}
+	if err := os.WriteFile(path, b, 0o644); err != nil {
+		return false, fmt.Errorf("derived: %w", err)
+	}
+	return true, nil
+}
@@ -0,0 +1,110 @@
+package derived
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestArtifacts(t *testing.T) {
+	dir := t.TempDir()
+	root := filepath.Join(dir, "secrets")
+	if err := os.MkdirAll(filepath.Join(root, "prod"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	plains := map[string]string{
+		"app.env.age":      "DB_PASSWORD=hunter2\nAPI_KEY=s3cret\n",
+		"prod/db.yaml.age": "db:\n  password: hunter2\n",
+	}
+	for rel := range plains {
+		if err := os.WriteFile(filepath.Join(root, rel), []byte("cipher of "+rel), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	open := func(path string) (string, string, error) {
+		rel, _ := filepath.Rel(root, path)
+		format := "env"
+		if strings.Contains(rel, ".yaml") {
+			format = "yaml"
+		}
+		return plains[filepath.ToSlash(rel)], format, nil
+	}
+	read := func(t *testing.T, path string) string {
+		t.Helper()
+		b, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(b)
+	}
+
+	t.Run("rejects bad declarations", func(t *testing.T) {
+		if err := (Artifact{Kind: "zip", Out: "x"}).Check(); err == nil {
+			t.Error("expected an unknown kind to be refused")
+		}
+		if err := (Artifact{Kind: Manifest}).Check(); err == nil {
+			t.Error("expected a missing out to be refused")
+		}
+	})
+
+	t.Run("sums the ciphertexts", func(t *testing.T) {
+		a := Artifact{Kind: SHA256Sums, Root: root, Out: filepath.Join(root, "SHA256SUMS")}
+		if changed, err := a.Saved(filepath.Join(root, "app.env.age"), "", "env"); err != nil || !changed {
+			t.Fatalf("got %v, %v", changed, err)
+		}
+		got := read(t, a.Out)
+		if !strings.Contains(got, "  app.env.age\n") || !strings.Contains(got, "  prod/db.yaml.age\n") {
+			t.Errorf("unexpected sums:\n%s", got)
+		}
+		if changed, err := a.Rebuild(open, true); err != nil || changed {
+			t.Errorf("expected sums to be current, got %v, %v", changed, err)
+		}
+	})
+
+	t.Run("keeps a manifest of key names", func(t *testing.T) {
+		a := Artifact{Kind: Manifest, Root: root, Out: filepath.Join(dir, "manifest.json")}
+		if changed, err := a.Rebuild(open, true); err != nil || !changed {
+			t.Fatalf("expected a missing manifest to be out of date, got %v, %v", changed, err)
+		}
+		if _, err := a.Rebuild(open, false); err != nil {
+			t.Fatal(err)
+		}
+		got := read(t, a.Out)
+		for _, want := range []string{`"app.env.age": [`, `"DB_PASSWORD"`, `"db.password"`} {
+			if !strings.Contains(got, want) {
+				t.Errorf("expected %q in:\n%s", want, got)
+			}
+		}
+		if strings.Contains(got, "hunter2") {
+			t.Errorf("value leaked:\n%s", got)
+		}
+
+		os.Remove(filepath.Join(root, "prod", "db.yaml.age"))
+		if _, err := a.Saved(filepath.Join(root, "app.env.age"), "TOKEN=x\n", "env"); err != nil {
+			t.Fatal(err)
+		}
+		got = read(t, a.Out)
+		if strings.Contains(got, "db.yaml") || !strings.Contains(got, `"TOKEN"`) || strings.Contains(got, "API_KEY") {
+			t.Errorf("expected app.env.age updated and db.yaml.age dropped:\n%s", got)
+		}
+		if changed, err := a.Saved(filepath.Join(dir, "elsewhere.env.age"), "X=1\n", "env"); err != nil || changed {
+			t.Errorf("expected a file outside the root to change nothing, got %v, %v", changed, err)
+		}
+	})
+
+	t.Run("writes catalog entries", func(t *testing.T) {
+		a := Artifact{Kind: Catalog, Root: root, Out: filepath.Join(dir, "catalog")}
+		if _, err := a.Saved(filepath.Join(root, "app.env.age"), plains["app.env.age"], "env"); err != nil {
+			t.Fatal(err)
+		}
+		got := read(t, filepath.Join(dir, "catalog", "app.env"))
+		want := "# Generated by agepad redact-tree from app.env.age; values are redacted.\nDB_PASSWORD=<redacted>\nAPI_KEY=<redacted>\n"
+		if got != want {
+			t.Errorf("got:\n%s\nwant:\n%s", got, want)
+		}
+		if changed, err := a.Saved(filepath.Join(root, "app.env.age"), plains["app.env.age"], "env"); err != nil || changed {
+			t.Errorf("expected an unchanged entry not to be rewritten, got %v, %v", changed, err)
+		}
+	})
+}
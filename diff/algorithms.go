@@ -0,0 +1,193 @@
+package diff
+
+// match pairs line a of one side with the equal line b of the other.
+type match struct{ a, b int }
+
+// myers returns the matched lines of a shortest edit script between x and
+// y (Myers' O(ND) algorithm). offA and offB are added to the results so
+// the other algorithms can use it on sub-ranges.
+func myers(x, y []int, offA, offB int) []match {
+	// Common prefixes and suffixes are matched directly.
+	var head, tail []match
+	for len(x) > 0 && len(y) > 0 && x[0] == y[0] {
+		head = append(head, match{offA, offB})
+		x, y, offA, offB = x[1:], y[1:], offA+1, offB+1
+	}
+	for len(x) > 0 && len(y) > 0 && x[len(x)-1] == y[len(y)-1] {
+		tail = append([]match{{offA + len(x) - 1, offB + len(y) - 1}}, tail...)
+		x, y = x[:len(x)-1], y[:len(y)-1]
+	}
+	n, m := len(x), len(y)
+	if n == 0 || m == 0 {
+		return append(head, tail...)
+	}
+
+	maxD := n + m
+	v := make([]int, 2*maxD+2)
+	var trace [][]int
+search:
+	for d := 0; d <= maxD; d++ {
+		trace = append(trace, append([]int(nil), v...))
+		for k := -d; k <= d; k += 2 {
+			var i int
+			if k == -d || (k != d && v[maxD+k-1] < v[maxD+k+1]) {
+				i = v[maxD+k+1]
+			} else {
+				i = v[maxD+k-1] + 1
+			}
+			j := i - k
+			for i < n && j < m && x[i] == y[j] {
+				i, j = i+1, j+1
+			}
+			v[maxD+k] = i
+			if i >= n && j >= m {
+				break search
+			}
+		}
+	}
+
+	// Walk the trace back from (n, m), collecting the diagonal moves.
+	var mid []match
+	i, j := n, m
+	for d := len(trace) - 1; d >= 0 && (i > 0 || j > 0); d-- {
+		vd := trace[d]
+		k := i - j
+		var prevK int
+		if k == -d || (k != d && vd[maxD+k-1] < vd[maxD+k+1]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevI := vd[maxD+prevK]
+		prevJ := prevI - prevK
+		if d == 0 {
+			prevI, prevJ = 0, 0
+		}
+		for i > prevI && j > prevJ {
+			i, j = i-1, j-1
+			mid = append(mid, match{offA + i, offB + j})
+		}
+		i, j = prevI, prevJ
+	}
+	for l, r := 0, len(mid)-1; l < r; l, r = l+1, r-1 {
+		mid[l], mid[r] = mid[r], mid[l]
+	}
+	return append(append(head, mid...), tail...)
+}
+
+// patience matches the longest increasing run of lines unique to both
+// sides, then diffs the gaps between them the same way; gaps with no
+// unique lines fall back to Myers.
+func patience(x, y []int, offA, offB int) []match {
+	count := map[int][2]int{}
+	posB := map[int]int{}
+	for _, v := range x {
+		c := count[v]
+		c[0]++
+		count[v] = c
+	}
+	for j, v := range y {
+		c := count[v]
+		c[1]++
+		count[v] = c
+		posB[v] = j
+	}
+	var uniq []match
+	for i, v := range x {
+		if c := count[v]; c[0] == 1 && c[1] == 1 {
+			uniq = append(uniq, match{i, posB[v]})
+		}
+	}
+	if len(uniq) == 0 {
+		return myers(x, y, offA, offB)
+	}
+	return recurseAround(x, y, offA, offB, longestIncreasing(uniq), patience)
+}
+
+// longestIncreasing returns the longest subsequence of ms (ordered by a)
+// whose b values increase, by patience sorting.
+func longestIncreasing(ms []match) []match {
+	var tops []int // index into ms of the top card of each pile
+	prev := make([]int, len(ms))
+	for i, m := range ms {
+		lo, hi := 0, len(tops)
+		for lo < hi {
+			mid := (lo + hi) / 2
+			if ms[tops[mid]].b < m.b {
+				lo = mid + 1
+			} else {
+				hi = mid
+			}
+		}
+		prev[i] = -1
+		if lo > 0 {
+			prev[i] = tops[lo-1]
+		}
+		if lo == len(tops) {
+			tops = append(tops, i)
+		} else {
+			tops[lo] = i
+		}
+	}
+	out := make([]match, len(tops))
+	for i, k := len(tops)-1, tops[len(tops)-1]; i >= 0; i, k = i-1, prev[k] {
+		out[i] = ms[k]
+	}
+	return out
+}
+
+// histogramMaxChain skips lines repeated more often than this as anchors,
+// as git does.
+const histogramMaxChain = 64
+
+// histogram anchors on the common line that is rarest in x, extends the
+// match into the longest equal run around it, and recurses on both sides.
+// With no usable anchor it falls back to Myers.
+func histogram(x, y []int, offA, offB int) []match {
+	if len(x) == 0 || len(y) == 0 {
+		return nil
+	}
+	freq := map[int]int{}
+	for _, v := range x {
+		freq[v]++
+	}
+	best, bi, bj := histogramMaxChain+1, -1, -1
+	for j, v := range y {
+		if c := freq[v]; c > 0 && c < best {
+			for i, w := range x {
+				if w == v {
+					best, bi, bj = c, i, j
+					break
+				}
+			}
+		}
+	}
+	if bi < 0 {
+		return myers(x, y, offA, offB)
+	}
+	start, end := 0, 1
+	for bi-start > 0 && bj-start > 0 && x[bi-start-1] == y[bj-start-1] {
+		start++
+	}
+	for bi+end < len(x) && bj+end < len(y) && x[bi+end] == y[bj+end] {
+		end++
+	}
+	var anchors []match
+	for k := -start; k < end; k++ {
+		anchors = append(anchors, match{bi + k, bj + k})
+	}
+	return recurseAround(x, y, offA, offB, anchors, histogram)
+}
+
+// recurseAround keeps the ordered anchors and diffs the gaps before,
+// between, and after them with next.
+func recurseAround(x, y []int, offA, offB int, anchors []match, next func(x, y []int, offA, offB int) []match) []match {
+	var out []match
+	i, j := 0, 0
+	for _, a := range anchors {
+		out = append(out, next(x[i:a.a], y[j:a.b], offA+i, offB+j)...)
+		out = append(out, match{offA + a.a, offB + a.b})
+		i, j = a.a+1, a.b+1
+	}
+	return append(out, next(x[i:], y[j:], offA+i, offB+j)...)
+}
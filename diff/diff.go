@@ -0,0 +1,109 @@
+// Package diff computes line diffs of decrypted buffers with a choice of
+// algorithm (Myers, patience, histogram) and renders them as unified diffs,
+// optionally marking the changed words inside modified lines. Long tokens
+// (keys, hashes, base64 values) that differ in a few characters are marked
+// character by character, so a one-character edit stays readable.
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Algorithm selects how line matches are chosen.
+type Algorithm string
+
+const (
+	// Myers finds a shortest edit script; the usual default.
+	Myers Algorithm = "myers"
+	// Patience anchors on lines that occur once on each side, which keeps
+	// reordered blocks and repeated lines ("}", blank lines) from pairing up
+	// badly.
+	Patience Algorithm = "patience"
+	// Histogram anchors on the least frequent common lines, like git's
+	// histogram diff; it behaves like patience with repeated lines allowed.
+	Histogram Algorithm = "histogram"
+)
+
+// ParseAlgorithm accepts "myers", "patience", or "histogram"; empty means
+// Myers.
+func ParseAlgorithm(s string) (Algorithm, error) {
+	switch a := Algorithm(strings.ToLower(strings.TrimSpace(s))); a {
+	case "":
+		return Myers, nil
+	case Myers, Patience, Histogram:
+		return a, nil
+	}
+	return "", fmt.Errorf("unknown diff algorithm %q (want myers, patience, or histogram)", s)
+}
+
+// Op is the kind of an Edit.
+type Op int
+
+const (
+	Equal Op = iota
+	Delete
+	Insert
+)
+
+// Edit is one line of a diff. A and B are the 0-based line numbers on each
+// side; the one a line does not appear on is -1.
+type Edit struct {
+	Op   Op
+	Text string
+	A, B int
+}
+
+// Lines diffs a and b line by line with alg.
+func Lines(a, b []string, alg Algorithm) []Edit {
+	// Lines are compared as integers: equal lines share an id.
+	ids := map[string]int{}
+	intern := func(lines []string) []int {
+		out := make([]int, len(lines))
+		for i, l := range lines {
+			id, ok := ids[l]
+			if !ok {
+				id = len(ids)
+				ids[l] = id
+			}
+			out[i] = id
+		}
+		return out
+	}
+	x, y := intern(a), intern(b)
+
+	var pairs []match
+	switch alg {
+	case Patience:
+		pairs = patience(x, y, 0, 0)
+	case Histogram:
+		pairs = histogram(x, y, 0, 0)
+	default:
+		pairs = myers(x, y, 0, 0)
+	}
+
+	var edits []Edit
+	i, j := 0, 0
+	for _, p := range append(pairs, match{len(a), len(b)}) {
+		for ; i < p.a; i++ {
+			edits = append(edits, Edit{Op: Delete, Text: a[i], A: i, B: -1})
+		}
+		for ; j < p.b; j++ {
+			edits = append(edits, Edit{Op: Insert, Text: b[j], A: -1, B: j})
+		}
+		if p.a < len(a) {
+			edits = append(edits, Edit{Op: Equal, Text: a[i], A: i, B: j})
+			i, j = i+1, j+1
+		}
+	}
+	return edits
+}
+
+// SplitLines splits s into lines without their "\n". A trailing newline
+// does not produce an empty last line.
+func SplitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
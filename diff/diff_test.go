@@ -0,0 +1,153 @@
+package diff
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+func sides(edits []Edit) (string, string) {
+	var a, b []string
+	for _, e := range edits {
+		if e.Op != Insert {
+			a = append(a, e.Text)
+		}
+		if e.Op != Delete {
+			b = append(b, e.Text)
+		}
+	}
+	return strings.Join(a, "\n"), strings.Join(b, "\n")
+}
+
+func lcs(a, b []string) int {
+	dp := make([][]int, len(a)+1)
+	for i := range dp {
+		dp[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				dp[i][j] = dp[i+1][j+1] + 1
+			} else {
+				dp[i][j] = max(dp[i+1][j], dp[i][j+1])
+			}
+		}
+	}
+	return dp[0][0]
+}
+
+func TestLines(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	random := func() []string {
+		out := make([]string, rng.Intn(12))
+		for i := range out {
+			out[i] = string(rune('a' + rng.Intn(4)))
+		}
+		return out
+	}
+
+	for _, alg := range []Algorithm{Myers, Patience, Histogram} {
+		t.Run(string(alg)+" reproduces both sides", func(t *testing.T) {
+			for n := 0; n < 500; n++ {
+				a, b := random(), random()
+				gotA, gotB := sides(Lines(a, b, alg))
+				if gotA != strings.Join(a, "\n") || gotB != strings.Join(b, "\n") {
+					t.Fatalf("%v vs %v: edits rebuild %q / %q", a, b, gotA, gotB)
+				}
+			}
+		})
+	}
+
+	t.Run("myers keeps a longest common subsequence", func(t *testing.T) {
+		for n := 0; n < 500; n++ {
+			a, b := random(), random()
+			equal := 0
+			for _, e := range Lines(a, b, Myers) {
+				if e.Op == Equal {
+					equal++
+				}
+			}
+			if want := lcs(a, b); equal != want {
+				t.Fatalf("%v vs %v: %d equal lines, LCS is %d", a, b, equal, want)
+			}
+		}
+	})
+
+	t.Run("patience anchors on unique lines", func(t *testing.T) {
+		a := []string{"func a() {", "  x", "}", "", "func b() {", "  y", "}"}
+		b := []string{"func b() {", "  y", "}", "", "func a() {", "  x", "}"}
+		var kept []string
+		for _, e := range Lines(a, b, Patience) {
+			if e.Op == Equal {
+				kept = append(kept, e.Text)
+			}
+		}
+		if len(kept) == 0 || kept[0] != "func b() {" && kept[0] != "func a() {" {
+			t.Errorf("expected a function header as anchor, kept %q", kept)
+		}
+	})
+}
+
+func TestUnified(t *testing.T) {
+	t.Run("matches difflib for simple changes", func(t *testing.T) {
+		// No trailing newlines: difflib.SplitLines adds an empty last line
+		// after one.
+		cases := [][2]string{
+			{"a\nb\nc", "a\nB\nc"},
+			{"1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12", "1\n2\nx\n4\n5\n6\n7\n8\n9\n10\ny\n12"},
+			{"a\nb", "a"},
+			{"a", "b\nc\na"},
+		}
+		for _, c := range cases {
+			want, _ := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+				A: difflib.SplitLines(c[0]), B: difflib.SplitLines(c[1]),
+				FromFile: "a", ToFile: "b", Context: 3,
+			})
+			got := Unified(c[0], c[1], Options{FromFile: "a", ToFile: "b", Context: 3})
+			if got != want {
+				t.Errorf("%q -> %q:\ngot:\n%s\nwant:\n%s", c[0], c[1], got, want)
+			}
+		}
+	})
+
+	t.Run("numbers an empty side like diff(1)", func(t *testing.T) {
+		if got := Unified("", "new\n", Options{}); !strings.Contains(got, "@@ -0,0 +1 @@\n+new\n") {
+			t.Errorf("unexpected diff:\n%s", got)
+		}
+	})
+
+	t.Run("is empty for equal input", func(t *testing.T) {
+		if got := Unified("same\n", "same\n", Options{}); got != "" {
+			t.Errorf("expected no diff, got %q", got)
+		}
+	})
+
+	t.Run("marks changed words", func(t *testing.T) {
+		got := Unified("DB_HOST=old.example.com\n", "DB_HOST=new.example.com\n", Options{Mark: PlainMark})
+		for _, want := range []string{"-DB_HOST=[-old-].example.com", "+DB_HOST={+new+}.example.com"} {
+			if !strings.Contains(got, want) {
+				t.Errorf("expected %q in:\n%s", want, got)
+			}
+		}
+	})
+
+	t.Run("narrows long tokens to the changed characters", func(t *testing.T) {
+		got := Unified("TOKEN=ghp_abcdefghijklmnop\n", "TOKEN=ghp_abcdefgXijklmnop\n", Options{Mark: PlainMark})
+		if !strings.Contains(got, "-TOKEN=ghp_abcdefg[-h-]ijklmnop") || !strings.Contains(got, "+TOKEN=ghp_abcdefg{+X+}ijklmnop") {
+			t.Errorf("expected a one-character mark, got:\n%s", got)
+		}
+	})
+}
+
+func TestParseAlgorithm(t *testing.T) {
+	for in, want := range map[string]Algorithm{"": Myers, "Patience": Patience, "histogram": Histogram} {
+		if got, err := ParseAlgorithm(in); err != nil || got != want {
+			t.Errorf("%q: expected %s, got %s (%v)", in, want, got, err)
+		}
+	}
+	if _, err := ParseAlgorithm("minimal"); err == nil {
+		t.Error("expected an error for an unknown algorithm")
+	}
+}
@@ -0,0 +1,107 @@
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Options controls Unified.
+type Options struct {
+	FromFile, ToFile string
+	Context          int // unchanged lines around each change
+	Algorithm        Algorithm
+	// Mark, when set, marks the changed words of paired deleted and inserted
+	// lines; deleted tells which side the text is from.
+	Mark func(text string, deleted bool) string
+}
+
+// PlainMark marks changes the way `git diff --word-diff=plain` does:
+// [-removed-] and {+added+}.
+func PlainMark(text string, deleted bool) string {
+	if deleted {
+		return "[-" + text + "-]"
+	}
+	return "{+" + text + "+}"
+}
+
+// ANSIMark marks changes in reverse video, for terminals.
+func ANSIMark(text string, _ bool) string {
+	return "\x1b[7m" + text + "\x1b[27m"
+}
+
+// Unified renders a unified diff of a and b, or "" when they are equal.
+func Unified(a, b string, opts Options) string {
+	edits := Lines(SplitLines(a), SplitLines(b), opts.Algorithm)
+	if opts.Mark != nil {
+		markWords(edits, opts.Mark)
+	}
+	var out strings.Builder
+	for _, h := range hunks(edits, opts.Context) {
+		if out.Len() == 0 {
+			fmt.Fprintf(&out, "--- %s\n+++ %s\n", opts.FromFile, opts.ToFile)
+		}
+		fmt.Fprintf(&out, "@@ -%s +%s @@\n", span(h.a, h.aLen), span(h.b, h.bLen))
+		for _, e := range h.edits {
+			out.WriteString([]string{" ", "-", "+"}[e.Op] + e.Text + "\n")
+		}
+	}
+	return out.String()
+}
+
+// hunk is a run of edits shown together; a and b are the 0-based first
+// lines on each side, aLen and bLen how many lines it covers there.
+type hunk struct {
+	edits      []Edit
+	a, b       int
+	aLen, bLen int
+}
+
+// hunks groups changes with up to context unchanged lines around them;
+// changes closer than twice that share a hunk.
+func hunks(edits []Edit, context int) []hunk {
+	var out []hunk
+	a, b := make([]int, len(edits)+1), make([]int, len(edits)+1)
+	for i, e := range edits {
+		a[i+1], b[i+1] = a[i], b[i]
+		if e.Op != Insert {
+			a[i+1]++
+		}
+		if e.Op != Delete {
+			b[i+1]++
+		}
+	}
+	for i := 0; i < len(edits); {
+		if edits[i].Op == Equal {
+			i++
+			continue
+		}
+		start := max(0, i-context)
+		end := i // one past the last change in the hunk
+		for j := i; j < len(edits); j++ {
+			if edits[j].Op != Equal {
+				end = j + 1
+			} else if j-end >= 2*context {
+				break
+			}
+		}
+		stop := min(len(edits), end+context)
+		out = append(out, hunk{
+			edits: edits[start:stop],
+			a:     a[start], b: b[start],
+			aLen: a[stop] - a[start], bLen: b[stop] - b[start],
+		})
+		i = stop
+	}
+	return out
+}
+
+// span formats a hunk range; an empty range names the line before it.
+func span(start, n int) string {
+	switch n {
+	case 0:
+		return fmt.Sprintf("%d,0", start)
+	case 1:
+		return fmt.Sprint(start + 1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, n)
+}
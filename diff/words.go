@@ -0,0 +1,128 @@
+package diff
+
+import (
+	"strings"
+	"unicode"
+)
+
+// charLevelMin is the token length from which a changed token is compared
+// character by character instead of marked whole.
+const charLevelMin = 12
+
+// markWords pairs each run of deleted lines with the inserted lines that
+// follow it, line by line, and marks the words that differ in each pair.
+// Unpaired lines are left as they are.
+func markWords(edits []Edit, mark func(string, bool) string) {
+	for i := 0; i < len(edits); {
+		if edits[i].Op != Delete {
+			i++
+			continue
+		}
+		del := i
+		for i < len(edits) && edits[i].Op == Delete {
+			i++
+		}
+		ins := i
+		for i < len(edits) && edits[i].Op == Insert {
+			i++
+		}
+		for k := 0; k < ins-del && ins+k < i; k++ {
+			edits[del+k].Text, edits[ins+k].Text = markLine(edits[del+k].Text, edits[ins+k].Text, mark)
+		}
+	}
+}
+
+// markLine marks the tokens that differ between a and b. A long token
+// replaced by another long token is narrowed to the characters that
+// differ.
+func markLine(a, b string, mark func(string, bool) string) (string, string) {
+	x, y := tokens(a), tokens(b)
+	var outA, outB strings.Builder
+	var delRun, insRun []string
+	flush := func() {
+		if len(delRun) == 1 && len(insRun) == 1 && len([]rune(delRun[0])) >= charLevelMin && len([]rune(insRun[0])) >= charLevelMin {
+			ca, cb := markChars(delRun[0], insRun[0], mark)
+			outA.WriteString(ca)
+			outB.WriteString(cb)
+		} else {
+			if len(delRun) > 0 {
+				outA.WriteString(mark(strings.Join(delRun, ""), true))
+			}
+			if len(insRun) > 0 {
+				outB.WriteString(mark(strings.Join(insRun, ""), false))
+			}
+		}
+		delRun, insRun = nil, nil
+	}
+	for _, e := range Lines(x, y, Myers) {
+		switch e.Op {
+		case Equal:
+			flush()
+			outA.WriteString(e.Text)
+			outB.WriteString(e.Text)
+		case Delete:
+			delRun = append(delRun, e.Text)
+		case Insert:
+			insRun = append(insRun, e.Text)
+		}
+	}
+	flush()
+	return outA.String(), outB.String()
+}
+
+// markChars marks the characters that differ between two tokens.
+func markChars(a, b string, mark func(string, bool) string) (string, string) {
+	x, y := strings.Split(a, ""), strings.Split(b, "")
+	var outA, outB strings.Builder
+	var del, ins strings.Builder
+	flush := func() {
+		if del.Len() > 0 {
+			outA.WriteString(mark(del.String(), true))
+		}
+		if ins.Len() > 0 {
+			outB.WriteString(mark(ins.String(), false))
+		}
+		del.Reset()
+		ins.Reset()
+	}
+	for _, e := range Lines(x, y, Myers) {
+		switch e.Op {
+		case Equal:
+			flush()
+			outA.WriteString(e.Text)
+			outB.WriteString(e.Text)
+		case Delete:
+			del.WriteString(e.Text)
+		case Insert:
+			ins.WriteString(e.Text)
+		}
+	}
+	flush()
+	return outA.String(), outB.String()
+}
+
+// tokens splits a line into words (runs of letters and digits) and single
+// other characters, so KEY=value splits at "=".
+func tokens(s string) []string {
+	var out []string
+	word := -1
+	for i, r := range s {
+		isWord := unicode.IsLetter(r) || unicode.IsDigit(r)
+		if isWord && word < 0 {
+			word = i
+			continue
+		}
+		if isWord {
+			continue
+		}
+		if word >= 0 {
+			out = append(out, s[word:i])
+			word = -1
+		}
+		out = append(out, string(r))
+	}
+	if word >= 0 {
+		out = append(out, s[word:])
+	}
+	return out
+}
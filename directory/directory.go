@@ -0,0 +1,118 @@
+// Package directory resolves people and groups to the age recipients
+// registered for them, so a command can encrypt to "alice and the sre
+// group" instead of a hand-kept recipients file:
+//
+//	agepad encrypt --recipient-user alice --recipient-group sre --in app.env --out app.env.age
+//
+// A Resolver answers from one source. Open picks it from a spec: a TOML
+// file, an https:// roster in the same format, an ldap:// or ldaps://
+// directory (through ldapsearch), or a scim+https:// endpoint.
+package directory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// Resolver looks up one directory.
+type Resolver interface {
+	// User returns the recipient lines (as in a recipients file, without
+	// a label) registered for the user name; none if it has none. A name
+	// the directory does not know is an error.
+	User(ctx context.Context, name string) ([]string, error)
+	// Group returns the user names of a group's members. A name the
+	// directory does not know is an error.
+	Group(ctx context.Context, name string) ([]string, error)
+}
+
+// Entry is one resolved recipient and the user it belongs to.
+type Entry struct {
+	User string
+	Line string // the recipient, e.g. "age1..." or "ssh-ed25519 AAAA..."
+}
+
+// String formats e as a recipients file line labeled with its user.
+func (e Entry) String() string {
+	return e.Line + " # " + e.User
+}
+
+// Resolve returns the recipients of users and of every member of groups,
+// each line once, in the order first reached. A user without a
+// registered key is an error: encrypting without them would silently
+// lock them out.
+func Resolve(ctx context.Context, r Resolver, users, groups []string) ([]Entry, error) {
+	names := slices.Clone(users)
+	for _, g := range groups {
+		members, err := r.Group(ctx, g)
+		if err != nil {
+			return nil, fmt.Errorf("group %s: %w", g, err)
+		}
+		if len(members) == 0 {
+			return nil, fmt.Errorf("group %s has no members", g)
+		}
+		names = append(names, members...)
+	}
+	var out []Entry
+	seenUser := map[string]bool{}
+	seenLine := map[string]bool{}
+	for _, name := range names {
+		if seenUser[name] {
+			continue
+		}
+		seenUser[name] = true
+		lines, err := r.User(ctx, name)
+		if err != nil {
+			return nil, fmt.Errorf("user %s: %w", name, err)
+		}
+		found := false
+		for _, l := range lines {
+			if l = strings.TrimSpace(l); l == "" {
+				continue
+			}
+			found = true
+			if !seenLine[l] {
+				seenLine[l] = true
+				out = append(out, Entry{User: name, Line: l})
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("user %s has no registered age recipient", name)
+		}
+	}
+	return out, nil
+}
+
+// ErrNotFound is wrapped by lookups of names a directory does not know.
+var ErrNotFound = errors.New("not found in the directory")
+
+// Open returns the Resolver for spec:
+//
+//	team/directory.toml                            a file (see File)
+//	https://example.com/agepad/directory.toml      a roster (see Roster)
+//	ldaps://ldap.example.com/dc=example,dc=com     LDAP (see LDAP)
+//	scim+https://idp.example.com/scim/v2           SCIM 2.0 (see SCIM)
+func Open(spec string) (Resolver, error) {
+	scheme, _, found := strings.Cut(spec, "://")
+	if !found {
+		return &File{Path: spec}, nil
+	}
+	u, err := url.Parse(spec)
+	if err != nil {
+		return nil, fmt.Errorf("directory %s: %w", spec, err)
+	}
+	switch strings.ToLower(scheme) {
+	case "https":
+		return &Roster{URL: spec}, nil
+	case "ldap", "ldaps":
+		return newLDAP(u)
+	case "scim+https":
+		return newSCIM(u)
+	case "http", "scim+http":
+		return nil, fmt.Errorf("directory %s: use https; recipients fetched over plain http could be swapped in transit", spec)
+	}
+	return nil, fmt.Errorf("directory %s: unsupported scheme %s (want a file, https://, ldap://, ldaps://, or scim+https://)", spec, scheme)
+}
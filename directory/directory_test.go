@@ -0,0 +1,169 @@
+package directory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+const testRoster = `
+[users]
+alice = ["age1alice"]
+bob = ["age1bob", "ssh-ed25519 AAAAbob bob@laptop"]
+carol = []
+
+[groups]
+sre = ["alice", "bob"]
+oncall = ["carol"]
+`
+
+func lines(es []Entry) []string {
+	var out []string
+	for _, e := range es {
+		out = append(out, e.String())
+	}
+	return out
+}
+
+func TestResolve(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "directory.toml")
+	if err := os.WriteFile(path, []byte(testRoster), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	r, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("expands groups and drops repeats", func(t *testing.T) {
+		es, err := Resolve(ctx, r, []string{"bob"}, []string{"sre"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := []string{"age1bob # bob", "ssh-ed25519 AAAAbob bob@laptop # bob", "age1alice # alice"}
+		if got := lines(es); !slices.Equal(got, want) {
+			t.Errorf("got %q, want %q", got, want)
+		}
+	})
+
+	t.Run("refuses unknown names and users without keys", func(t *testing.T) {
+		if _, err := Resolve(ctx, r, []string{"mallory"}, nil); !errors.Is(err, ErrNotFound) {
+			t.Errorf("expected ErrNotFound, got %v", err)
+		}
+		if _, err := Resolve(ctx, r, nil, []string{"oncall"}); err == nil || !strings.Contains(err.Error(), "carol has no registered") {
+			t.Errorf("expected carol to be refused, got %v", err)
+		}
+	})
+
+	t.Run("rejects groups naming unknown users", func(t *testing.T) {
+		bad := filepath.Join(t.TempDir(), "bad.toml")
+		os.WriteFile(bad, []byte("[users]\n[groups]\nsre = [\"alice\"]\n"), 0o644)
+		if _, err := (&File{Path: bad}).User(ctx, "alice"); err == nil {
+			t.Error("expected an error")
+		}
+	})
+
+	t.Run("picks a resolver by scheme", func(t *testing.T) {
+		for spec, want := range map[string]string{
+			"https://x.test/d.toml":               "*directory.Roster",
+			"ldaps://ldap.test/dc=example,dc=com": "*directory.LDAP",
+			"scim+https://idp.test/scim/v2":       "*directory.SCIM",
+		} {
+			r, err := Open(spec)
+			if err != nil || fmt.Sprintf("%T", r) != want {
+				t.Errorf("%s: got %T, %v", spec, r, err)
+			}
+		}
+		if _, err := Open("http://x.test/d.toml"); err == nil {
+			t.Error("expected plain http to be refused")
+		}
+	})
+}
+
+func TestRoster(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, testRoster)
+	}))
+	defer srv.Close()
+	r := &Roster{URL: srv.URL, Client: srv.Client()}
+	es, err := Resolve(context.Background(), r, nil, []string{"sre"})
+	if err != nil || len(es) != 3 {
+		t.Fatalf("got %v, %v", lines(es), err)
+	}
+}
+
+func TestLDAP(t *testing.T) {
+	saved := ldapCLI
+	defer func() { ldapCLI = saved }()
+	var searches []string
+	ldapCLI = func(ctx context.Context, args ...string) ([]byte, error) {
+		base, filter := args[slices.Index(args, "-b")+1], args[slices.Index(args, "-s")+2]
+		searches = append(searches, base+" "+filter)
+		switch {
+		case filter == "(uid=alice)":
+			// A folded line and a base64 value, as ldapsearch writes them.
+			return []byte("dn: uid=alice,ou=people,dc=example,dc=com\nsshPublicKey: ssh-ed25519 AAAA\n alice alice@laptop\nsshPublicKey:: YWdlMWFsaWNl\n\n"), nil
+		case strings.Contains(filter, "(cn=sre)"):
+			return []byte("dn: cn=sre,ou=groups,dc=example,dc=com\nmember: uid=alice,ou=people,dc=example,dc=com\n\n"), nil
+		case base == "uid=alice,ou=people,dc=example,dc=com":
+			return []byte("dn: uid=alice,ou=people,dc=example,dc=com\nuid: alice\n\n"), nil
+		}
+		return nil, nil
+	}
+	r, err := Open("ldap://ldap.test/dc=example,dc=com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	es, err := Resolve(context.Background(), r, nil, []string{"sre"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"ssh-ed25519 AAAAalice alice@laptop # alice", "age1alice # alice"}
+	if got := lines(es); !slices.Equal(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if _, err := r.User(context.Background(), "x)(uid=*"); !errors.Is(err, ErrNotFound) || !strings.Contains(searches[len(searches)-1], `(uid=x\29\28uid=\2a)`) {
+		t.Errorf("expected an escaped filter, got %v after %q", err, searches[len(searches)-1])
+	}
+}
+
+func TestSCIM(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer tok" {
+			http.Error(w, "no", http.StatusUnauthorized)
+			return
+		}
+		switch filter := r.URL.Query().Get("filter"); {
+		case r.URL.Path == "/scim/v2/Users" && filter == `userName eq "alice"`:
+			fmt.Fprint(w, `{"Resources":[{"userName":"alice","urn:ietf:params:scim:schemas:extension:agepad:2.0:User":{"ageRecipients":[{"value":"age1alice"}]}}]}`)
+		case r.URL.Path == "/scim/v2/Users":
+			fmt.Fprint(w, `{"Resources":[]}`)
+		case r.URL.Path == "/scim/v2/Groups" && filter == `displayName eq "sre"`:
+			fmt.Fprint(w, `{"Resources":[{"displayName":"sre","members":[{"value":"u1"},{"value":"gone"}]}]}`)
+		case r.URL.Path == "/scim/v2/Users/u1":
+			fmt.Fprint(w, `{"userName":"alice"}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	r := &SCIM{Base: srv.URL + "/scim/v2", Attribute: "ageRecipients", Token: "tok", Client: srv.Client()}
+	es, err := Resolve(context.Background(), r, nil, []string{"sre"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := lines(es); !slices.Equal(got, []string{"age1alice # alice"}) {
+		t.Errorf("got %q", got)
+	}
+	if _, err := r.User(context.Background(), "bob"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
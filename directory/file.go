@@ -0,0 +1,177 @@
+package directory
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pelletier/go-toml/v2"
+)
+
+// roster is the TOML document a File or Roster holds:
+//
+//	[users]
+//	alice = ["age1ql3z7hjy54pw3hyww5ayyfg7zqgvc7w3j2elw8zmrj2kg5sfn9aqmcac8p"]
+//	bob = ["ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAI... bob@laptop", "age1yubikey1..."]
+//
+//	[groups]
+//	sre = ["alice", "bob"]
+type roster struct {
+	Users  map[string][]string `toml:"users"`
+	Groups map[string][]string `toml:"groups"`
+}
+
+func parseRoster(name string, b []byte) (*roster, error) {
+	var r roster
+	dec := toml.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&r); err != nil {
+		return nil, fmt.Errorf("directory %s: %w", name, err)
+	}
+	for g, members := range r.Groups {
+		for _, m := range members {
+			if _, ok := r.Users[m]; !ok {
+				return nil, fmt.Errorf("directory %s: group %s lists %s, who has no [users] entry", name, g, m)
+			}
+		}
+	}
+	return &r, nil
+}
+
+func (r *roster) user(name string) ([]string, error) {
+	lines, ok := r.Users[name]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return lines, nil
+}
+
+func (r *roster) group(name string) ([]string, error) {
+	members, ok := r.Groups[name]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return members, nil
+}
+
+// File is a directory kept as a TOML file in the repository, mapping users
+// to their recipients and groups to their users.
+type File struct {
+	Path string
+
+	once sync.Once
+	r    *roster
+	err  error
+}
+
+func (f *File) load() (*roster, error) {
+	f.once.Do(func() {
+		b, err := os.ReadFile(f.Path)
+		if err != nil {
+			f.err = fmt.Errorf("directory: %w", err)
+			return
+		}
+		f.r, f.err = parseRoster(f.Path, b)
+	})
+	return f.r, f.err
+}
+
+// User implements Resolver.
+func (f *File) User(ctx context.Context, name string) ([]string, error) {
+	r, err := f.load()
+	if err != nil {
+		return nil, err
+	}
+	return r.user(name)
+}
+
+// Group implements Resolver.
+func (f *File) Group(ctx context.Context, name string) ([]string, error) {
+	r, err := f.load()
+	if err != nil {
+		return nil, err
+	}
+	return r.group(name)
+}
+
+// maxResponse bounds what is read from a roster or directory server.
+const maxResponse = 4 << 20
+
+// Roster is a File published over HTTPS, fetched once per run.
+type Roster struct {
+	URL    string
+	Client *http.Client // nil uses a client with a 30s timeout
+
+	once sync.Once
+	r    *roster
+	err  error
+}
+
+func (h *Roster) load(ctx context.Context) (*roster, error) {
+	h.once.Do(func() {
+		b, err := get(ctx, h.Client, h.URL, "")
+		if err != nil {
+			h.err = fmt.Errorf("directory %s: %w", h.URL, err)
+			return
+		}
+		h.r, h.err = parseRoster(h.URL, b)
+	})
+	return h.r, h.err
+}
+
+// User implements Resolver.
+func (h *Roster) User(ctx context.Context, name string) ([]string, error) {
+	r, err := h.load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return r.user(name)
+}
+
+// Group implements Resolver.
+func (h *Roster) Group(ctx context.Context, name string) ([]string, error) {
+	r, err := h.load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return r.group(name)
+}
+
+// errStatusNotFound is a 404 from get.
+var errStatusNotFound = errors.New("404 Not Found")
+
+// get fetches url, with a bearer token if one is given.
+func get(ctx context.Context, client *http.Client, url, token string) ([]byte, error) {
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, errStatusNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s", resp.Status)
+	}
+	b, err := io.ReadAll(io.LimitReader(resp.Body, maxResponse+1))
+	if err == nil && len(b) > maxResponse {
+		err = fmt.Errorf("response larger than %d bytes", maxResponse)
+	}
+	return b, err
+}
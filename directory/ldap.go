@@ -0,0 +1,198 @@
+package directory
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// LDAP looks people and groups up in an LDAP directory through the
+// OpenLDAP ldapsearch CLI, so it uses the system's LDAP configuration
+// (ldap.conf, CA certificates) as other tools on the machine do. The spec
+// is an LDAP URL naming the server, the base DN to search under, and
+// optionally the attribute holding recipients:
+//
+//	ldaps://ldap.example.com/dc=example,dc=com?sshPublicKey
+//
+// Users are found by uid, and groups by cn as posixGroup (memberUid) or
+// groupOfNames (member DNs). The attribute defaults to sshPublicKey, from
+// the openssh-lpk schema, as SSH ed25519 and RSA keys are also age
+// recipients. It binds anonymously unless $AGEPAD_LDAP_BIND_DN is set,
+// with the password read from the file in $AGEPAD_LDAP_PASSWORD_FILE.
+type LDAP struct {
+	Server    string // scheme://host[:port]
+	Base      string
+	Attribute string
+}
+
+func newLDAP(u *url.URL) (*LDAP, error) {
+	l := &LDAP{
+		Server:    u.Scheme + "://" + u.Host,
+		Base:      strings.TrimPrefix(u.Path, "/"),
+		Attribute: "sshPublicKey",
+	}
+	if u.RawQuery != "" {
+		attr, _, _ := strings.Cut(u.RawQuery, "?")
+		l.Attribute = attr
+	}
+	if u.Host == "" || l.Base == "" {
+		return nil, fmt.Errorf("directory %s: want ldap[s]://host/base-dn[?attribute]", u.Redacted())
+	}
+	return l, nil
+}
+
+// ldapCLI runs ldapsearch and returns its LDIF output.
+var ldapCLI = func(ctx context.Context, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, "ldapsearch", args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if errors.Is(err, exec.ErrNotFound) {
+		return nil, errors.New("ldapsearch not found in $PATH (install the OpenLDAP client tools)")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ldapsearch: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
+
+// search returns the entries under base matching filter, with attrs.
+func (l *LDAP) search(ctx context.Context, base, scope, filter string, attrs ...string) ([]ldapEntry, error) {
+	args := []string{"-LLL", "-x", "-H", l.Server, "-b", base, "-s", scope}
+	if dn := os.Getenv("AGEPAD_LDAP_BIND_DN"); dn != "" {
+		args = append(args, "-D", dn)
+		if pw := os.Getenv("AGEPAD_LDAP_PASSWORD_FILE"); pw != "" {
+			args = append(args, "-y", pw)
+		}
+	}
+	args = append(append(args, filter), attrs...)
+	out, err := ldapCLI(ctx, args...)
+	if err != nil {
+		return nil, fmt.Errorf("directory %s: %w", l.Server, err)
+	}
+	return parseLDIF(out)
+}
+
+// User implements Resolver.
+func (l *LDAP) User(ctx context.Context, name string) ([]string, error) {
+	es, err := l.search(ctx, l.Base, "sub", "(uid="+ldapEscape(name)+")", l.Attribute)
+	if err != nil {
+		return nil, err
+	}
+	switch len(es) {
+	case 0:
+		return nil, ErrNotFound
+	case 1:
+		return es[0].get(l.Attribute), nil
+	}
+	return nil, fmt.Errorf("%d entries have uid %s", len(es), name)
+}
+
+// Group implements Resolver.
+func (l *LDAP) Group(ctx context.Context, name string) ([]string, error) {
+	filter := "(&(|(objectClass=posixGroup)(objectClass=groupOfNames)(objectClass=groupOfUniqueNames))(cn=" + ldapEscape(name) + "))"
+	es, err := l.search(ctx, l.Base, "sub", filter, "memberUid", "member", "uniqueMember")
+	if err != nil {
+		return nil, err
+	}
+	switch len(es) {
+	case 0:
+		return nil, ErrNotFound
+	case 1:
+	default:
+		return nil, fmt.Errorf("%d groups have cn %s", len(es), name)
+	}
+	members := es[0].get("memberUid")
+	for _, dn := range append(es[0].get("member"), es[0].get("uniqueMember")...) {
+		users, err := l.search(ctx, dn, "base", "(objectClass=*)", "uid")
+		if err != nil {
+			return nil, err
+		}
+		if len(users) != 1 || len(users[0].get("uid")) == 0 {
+			return nil, fmt.Errorf("member %s has no uid", dn)
+		}
+		members = append(members, users[0].get("uid")[0])
+	}
+	return members, nil
+}
+
+// ldapEntry is one entry's attributes, keyed by lowercased name.
+type ldapEntry map[string][]string
+
+func (e ldapEntry) get(attr string) []string { return e[strings.ToLower(attr)] }
+
+// parseLDIF reads ldapsearch -LLL output: entries separated by blank
+// lines, "attr: value" or "attr:: base64" lines, folded lines continuing
+// with a leading space, and "#" comments.
+func parseLDIF(b []byte) ([]ldapEntry, error) {
+	var lines []string
+	sc := bufio.NewScanner(bytes.NewReader(b))
+	sc.Buffer(nil, maxResponse)
+	for sc.Scan() {
+		l := strings.TrimSuffix(sc.Text(), "\r")
+		if strings.HasPrefix(l, " ") && len(lines) > 0 && lines[len(lines)-1] != "" {
+			lines[len(lines)-1] += l[1:]
+			continue
+		}
+		lines = append(lines, l)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	var out []ldapEntry
+	var cur ldapEntry
+	for _, l := range append(lines, "") {
+		switch {
+		case l == "":
+			if cur != nil {
+				out = append(out, cur)
+				cur = nil
+			}
+			continue
+		case strings.HasPrefix(l, "#"):
+			continue
+		}
+		attr, v, ok := strings.Cut(l, ":")
+		if !ok {
+			return nil, fmt.Errorf("unexpected ldapsearch output line %q", l)
+		}
+		switch {
+		case strings.HasPrefix(v, ":"):
+			dec, err := base64.StdEncoding.DecodeString(strings.TrimSpace(v[1:]))
+			if err != nil {
+				return nil, fmt.Errorf("ldapsearch output: %s: %w", attr, err)
+			}
+			v = string(dec)
+		case strings.HasPrefix(v, "<"):
+			return nil, fmt.Errorf("ldapsearch output: %s: values by URL are not supported", attr)
+		default:
+			v = strings.TrimPrefix(v, " ")
+		}
+		if cur == nil {
+			cur = ldapEntry{}
+		}
+		cur[strings.ToLower(attr)] = append(cur[strings.ToLower(attr)], v)
+	}
+	return out, nil
+}
+
+// ldapEscape escapes a value for a search filter (RFC 4515).
+func ldapEscape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '*', '(', ')', '\\', 0:
+			fmt.Fprintf(&b, `\%02x`, c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
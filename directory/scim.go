@@ -0,0 +1,147 @@
+package directory
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// SCIM looks people and groups up through a SCIM 2.0 service (RFC 7644),
+// as identity providers such as Okta and Entra ID expose them. The spec is
+// the service's base URL with the scheme scim+https, and optionally the
+// user attribute holding recipients:
+//
+//	scim+https://idp.example.com/scim/v2?attribute=ageRecipients
+//
+// Users are found by userName and groups by displayName. SCIM has no
+// standard attribute for public keys; the attribute (default
+// ageRecipients) is looked for on the user and in its extension schemas,
+// as a string or a list of strings or {"value": ...} objects. The bearer
+// token is read from $AGEPAD_SCIM_TOKEN.
+type SCIM struct {
+	Base      string // https://.../scim/v2
+	Attribute string
+	Token     string
+	Client    *http.Client // nil uses a client with a 30s timeout
+}
+
+func newSCIM(u *url.URL) (*SCIM, error) {
+	s := &SCIM{Attribute: "ageRecipients", Token: os.Getenv("AGEPAD_SCIM_TOKEN")}
+	if a := u.Query().Get("attribute"); a != "" {
+		s.Attribute = a
+	}
+	base := *u
+	base.Scheme, base.RawQuery, base.Fragment = "https", "", ""
+	s.Base = strings.TrimSuffix(base.String(), "/")
+	if u.Host == "" {
+		return nil, fmt.Errorf("directory %s: want scim+https://host/base-path", u.Redacted())
+	}
+	return s, nil
+}
+
+type scimList struct {
+	Resources []map[string]any `json:"Resources"`
+}
+
+// find returns the one resource of kind ("Users" or "Groups") whose attr
+// equals value.
+func (s *SCIM) find(ctx context.Context, kind, attr, value string) (map[string]any, error) {
+	filter := fmt.Sprintf("%s eq %q", attr, value)
+	b, err := get(ctx, s.Client, s.Base+"/"+kind+"?filter="+url.QueryEscape(filter), s.Token)
+	if err != nil {
+		return nil, fmt.Errorf("directory %s: %w", s.Base, err)
+	}
+	var list scimList
+	if err := json.Unmarshal(b, &list); err != nil {
+		return nil, fmt.Errorf("directory %s: %s: %w", s.Base, kind, err)
+	}
+	switch len(list.Resources) {
+	case 0:
+		return nil, ErrNotFound
+	case 1:
+		return list.Resources[0], nil
+	}
+	return nil, fmt.Errorf("%d %s have %s %s", len(list.Resources), strings.ToLower(kind), attr, value)
+}
+
+// User implements Resolver.
+func (s *SCIM) User(ctx context.Context, name string) ([]string, error) {
+	u, err := s.find(ctx, "Users", "userName", name)
+	if err != nil {
+		return nil, err
+	}
+	v, ok := u[s.Attribute]
+	if !ok {
+		for k, ext := range u {
+			if m, isMap := ext.(map[string]any); isMap && strings.HasPrefix(k, "urn:") {
+				if v, ok = m[s.Attribute]; ok {
+					break
+				}
+			}
+		}
+	}
+	if !ok {
+		return nil, nil
+	}
+	return scimStrings(v)
+}
+
+// Group implements Resolver.
+func (s *SCIM) Group(ctx context.Context, name string) ([]string, error) {
+	g, err := s.find(ctx, "Groups", "displayName", name)
+	if err != nil {
+		return nil, err
+	}
+	raw, _ := g["members"].([]any)
+	var members []string
+	for _, m := range raw {
+		ref, _ := m.(map[string]any)
+		id, _ := ref["value"].(string)
+		if id == "" {
+			continue
+		}
+		b, err := get(ctx, s.Client, s.Base+"/Users/"+url.PathEscape(id), s.Token)
+		if errors.Is(err, errStatusNotFound) {
+			continue // a nested group or a deleted user
+		}
+		if err != nil {
+			return nil, fmt.Errorf("directory %s: member %s: %w", s.Base, id, err)
+		}
+		var u struct {
+			UserName string `json:"userName"`
+		}
+		if err := json.Unmarshal(b, &u); err != nil || u.UserName == "" {
+			return nil, fmt.Errorf("directory %s: member %s has no userName", s.Base, id)
+		}
+		members = append(members, u.UserName)
+	}
+	return members, nil
+}
+
+// scimStrings reads an attribute holding a string, or a list of strings
+// or {"value": ...} objects.
+func scimStrings(v any) ([]string, error) {
+	switch v := v.(type) {
+	case string:
+		return []string{v}, nil
+	case []any:
+		var out []string
+		for _, item := range v {
+			switch item := item.(type) {
+			case string:
+				out = append(out, item)
+			case map[string]any:
+				if s, ok := item["value"].(string); ok {
+					out = append(out, s)
+				}
+			}
+		}
+		return out, nil
+	}
+	return nil, fmt.Errorf("unexpected recipients attribute of type %T", v)
+}
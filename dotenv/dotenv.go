@@ -0,0 +1,197 @@
+// Package dotenv is the single .env parser shared by the editor, validator,
+// converters, and `agepad run`, so every part of agepad accepts and rejects
+// exactly the same files.
+//
+// Grammar:
+//   - blank lines and lines starting with '#' are ignored
+//   - an optional "export " prefix is allowed before the key
+//   - keys are non-empty and contain no whitespace, quotes, or '='
+//   - whitespace around '=' is ignored
+//   - unquoted values are trimmed; " #" (whitespace then '#') starts a comment
+//   - single-quoted values are literal and may span lines
+//   - double-quoted values may span lines and understand \n \r \t \" \\ ;
+//     other backslash sequences are kept as written
+//   - after a closing quote only whitespace or a comment may follow
+package dotenv
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Entry is one KEY=VALUE assignment.
+type Entry struct {
+	Key     string
+	Value   string
+	Export  bool
+	Line    int // 1-based line the assignment starts on
+	EndLine int // last line of the value (differs from Line for multiline values)
+}
+
+// SyntaxError reports where and why content is not valid .env.
+type SyntaxError struct {
+	Line       int
+	Msg        string
+	InvalidKey bool
+}
+
+func (e *SyntaxError) Error() string {
+	if e.InvalidKey {
+		return fmt.Sprintf(".env invalid key on line %d: %s", e.Line, e.Msg)
+	}
+	return fmt.Sprintf(".env parse error on line %d: %s", e.Line, e.Msg)
+}
+
+// Parse parses content into assignments in file order. Duplicate keys are
+// kept; use Map for last-one-wins lookups.
+func Parse(content string) ([]Entry, error) {
+	lines := strings.Split(content, "\n")
+	for i, l := range lines {
+		lines[i] = strings.TrimSuffix(l, "\r")
+	}
+
+	var out []Entry
+	for i := 0; i < len(lines); i++ {
+		lineNo := i + 1
+		t := strings.TrimSpace(lines[i])
+		if t == "" || strings.HasPrefix(t, "#") {
+			continue
+		}
+
+		e := Entry{Line: lineNo, EndLine: lineNo}
+		if rest, ok := cutExport(t); ok {
+			e.Export = true
+			t = rest
+		}
+		k, raw, ok := strings.Cut(t, "=")
+		e.Key = strings.TrimSpace(k)
+		if !ok || e.Key == "" {
+			return nil, &SyntaxError{Line: lineNo, Msg: "expected KEY=VALUE"}
+		}
+		if strings.ContainsFunc(e.Key, invalidKeyRune) {
+			return nil, &SyntaxError{Line: lineNo, Msg: fmt.Sprintf("%q", e.Key), InvalidKey: true}
+		}
+
+		v := strings.TrimLeft(raw, " \t")
+		if v == "" || (v[0] != '"' && v[0] != '\'') {
+			e.Value = unquoted(raw)
+			out = append(out, e)
+			continue
+		}
+
+		q := v[0]
+		buf := v[1:]
+		for {
+			end := closingQuote(buf, q)
+			if end >= 0 {
+				if tail := strings.TrimSpace(buf[end+1:]); tail != "" && !strings.HasPrefix(tail, "#") {
+					return nil, &SyntaxError{Line: i + 1, Msg: "unexpected text after quoted value"}
+				}
+				buf = buf[:end]
+				break
+			}
+			i++
+			if i >= len(lines) {
+				return nil, &SyntaxError{Line: lineNo, Msg: "unterminated quoted value"}
+			}
+			buf += "\n" + lines[i]
+		}
+		if q == '"' {
+			buf = unescape(buf)
+		}
+		e.Value = buf
+		e.EndLine = i + 1
+		out = append(out, e)
+	}
+	return out, nil
+}
+
+// Map parses content and returns its assignments as a map; later
+// assignments of the same key win.
+func Map(content string) (map[string]string, error) {
+	es, err := Parse(content)
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string]string, len(es))
+	for _, e := range es {
+		out[e.Key] = e.Value
+	}
+	return out, nil
+}
+
+// Quote renders v so that Parse returns it unchanged: bare when that is
+// unambiguous, otherwise double-quoted with escapes.
+func Quote(v string) string {
+	if v != "" && !strings.ContainsFunc(v, unicode.IsSpace) && !strings.ContainsAny(v, `"'#\`) {
+		return v
+	}
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`, "\t", `\t`)
+	return `"` + r.Replace(v) + `"`
+}
+
+func cutExport(t string) (string, bool) {
+	if len(t) > len("export") && strings.HasPrefix(t, "export") && (t[6] == ' ' || t[6] == '\t') {
+		return strings.TrimLeft(t[6:], " \t"), true
+	}
+	return t, false
+}
+
+func invalidKeyRune(r rune) bool {
+	return unicode.IsSpace(r) || r == '"' || r == '\''
+}
+
+// unquoted strips an inline comment (whitespace followed by '#') and
+// surrounding whitespace from a bare value.
+func unquoted(raw string) string {
+	for j := 1; j < len(raw); j++ {
+		if raw[j] == '#' && (raw[j-1] == ' ' || raw[j-1] == '\t') {
+			raw = raw[:j]
+			break
+		}
+	}
+	return strings.TrimSpace(raw)
+}
+
+// closingQuote returns the index of the quote closing s, honoring backslash
+// escapes inside double quotes, or -1.
+func closingQuote(s string, q byte) int {
+	for j := 0; j < len(s); j++ {
+		switch {
+		case q == '"' && s[j] == '\\':
+			j++
+		case s[j] == q:
+			return j
+		}
+	}
+	return -1
+}
+
+func unescape(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for j := 0; j < len(s); j++ {
+		if s[j] != '\\' || j+1 == len(s) {
+			b.WriteByte(s[j])
+			continue
+		}
+		j++
+		switch s[j] {
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 't':
+			b.WriteByte('\t')
+		case '"', '\\':
+			b.WriteByte(s[j])
+		default:
+			b.WriteByte('\\')
+			b.WriteByte(s[j])
+		}
+	}
+	return b.String()
+}
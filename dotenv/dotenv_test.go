@@ -0,0 +1,169 @@
+package dotenv
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	t.Run("parses bare, quoted, and exported values", func(t *testing.T) {
+		content := `# comment
+A=plain
+B = spaced value
+export C=exported
+D="double # not a comment"
+E='single $literal \n'
+F=bare # trailing comment
+G=#hash
+H=
+`
+		got, err := Map(content)
+		if err != nil {
+			t.Fatalf("parse failed: %v", err)
+		}
+		want := map[string]string{
+			"A": "plain",
+			"B": "spaced value",
+			"C": "exported",
+			"D": "double # not a comment",
+			"E": `single $literal \n`,
+			"F": "bare",
+			"G": "#hash",
+			"H": "",
+		}
+		for k, v := range want {
+			if got[k] != v {
+				t.Errorf("%s: expected %q, got %q", k, v, got[k])
+			}
+		}
+		if len(got) != len(want) {
+			t.Errorf("expected %d keys, got %d: %v", len(want), len(got), got)
+		}
+	})
+
+	t.Run("handles escapes in double quotes", func(t *testing.T) {
+		got, err := Map(`K="a\nb\t\"c\"\\d\q"`)
+		if err != nil {
+			t.Fatalf("parse failed: %v", err)
+		}
+		if want := "a\nb\t\"c\"\\d\\q"; got["K"] != want {
+			t.Errorf("expected %q, got %q", want, got["K"])
+		}
+	})
+
+	t.Run("supports multiline quoted values", func(t *testing.T) {
+		es, err := Parse("CERT=\"line1\nline2\"\nNEXT=1")
+		if err != nil {
+			t.Fatalf("parse failed: %v", err)
+		}
+		if len(es) != 2 || es[0].Value != "line1\nline2" {
+			t.Fatalf("unexpected entries: %+v", es)
+		}
+		if es[0].Line != 1 || es[0].EndLine != 2 || es[1].Line != 3 {
+			t.Errorf("unexpected line numbers: %+v", es)
+		}
+	})
+
+	t.Run("accepts CRLF line endings", func(t *testing.T) {
+		got, err := Map("A=1\r\nB=\"2\"\r\n")
+		if err != nil {
+			t.Fatalf("parse failed: %v", err)
+		}
+		if got["A"] != "1" || got["B"] != "2" {
+			t.Errorf("unexpected values: %v", got)
+		}
+	})
+
+	t.Run("later assignments win in Map", func(t *testing.T) {
+		got, _ := Map("A=1\nA=2")
+		if got["A"] != "2" {
+			t.Errorf("expected last value, got %q", got["A"])
+		}
+	})
+
+	t.Run("rejects malformed content with line numbers", func(t *testing.T) {
+		cases := map[string]string{
+			"A=1\nNOEQUALS":       "parse error on line 2",
+			"=value":              "parse error on line 1",
+			"BAD KEY=1":           "invalid key on line 1",
+			"A=\"open\nstill":     "unterminated quoted value",
+			"A=\"done\" trailing": "unexpected text after quoted value",
+		}
+		for in, want := range cases {
+			_, err := Parse(in)
+			if err == nil {
+				t.Errorf("expected error for %q", in)
+				continue
+			}
+			if !strings.Contains(err.Error(), want) {
+				t.Errorf("%q: expected %q in error, got %v", in, want, err)
+			}
+		}
+	})
+}
+
+func TestQuote(t *testing.T) {
+	t.Run("leaves simple values bare", func(t *testing.T) {
+		if got := Quote("abc=123"); got != "abc=123" {
+			t.Errorf("expected bare value, got %q", got)
+		}
+	})
+
+	t.Run("quotes values that need it", func(t *testing.T) {
+		for _, v := range []string{"", "a b", "a#b", `a"b`, "a\nb", `a\b`} {
+			if got := Quote(v); !strings.HasPrefix(got, `"`) {
+				t.Errorf("Quote(%q) = %q, expected double quotes", v, got)
+			}
+		}
+	})
+}
+
+func FuzzParse(f *testing.F) {
+	for _, s := range []string{
+		"A=1\nB=\"two\"\n",
+		"export A='x\ny'\n# c\n",
+		"K=\"a\\\"b\" # c",
+		"BAD KEY=1",
+		"A=\"unterminated",
+	} {
+		f.Add(s)
+	}
+	f.Fuzz(func(t *testing.T, content string) {
+		es, err := Parse(content)
+		if err != nil {
+			return
+		}
+		// Whatever parses must survive a re-render with Quote.
+		var b strings.Builder
+		for _, e := range es {
+			b.WriteString(e.Key + "=" + Quote(e.Value) + "\n")
+		}
+		again, err := Parse(b.String())
+		if err != nil {
+			t.Fatalf("re-rendered content failed to parse: %v\n%q", err, b.String())
+		}
+		if len(again) != len(es) {
+			t.Fatalf("expected %d entries after re-render, got %d", len(es), len(again))
+		}
+		for i := range es {
+			if again[i].Key != es[i].Key || again[i].Value != es[i].Value {
+				t.Fatalf("entry %d changed: %+v -> %+v", i, es[i], again[i])
+			}
+		}
+	})
+}
+
+func FuzzQuote(f *testing.F) {
+	for _, s := range []string{"", "plain", "with space", "a\"b'c#d\\e", "multi\nline\r\n", " lead"} {
+		f.Add(s)
+	}
+	f.Fuzz(func(t *testing.T, v string) {
+		got, err := Map("K=" + Quote(v))
+		if err != nil {
+			t.Fatalf("quoted value failed to parse: %v (%q)", err, Quote(v))
+		}
+		if got["K"] != v {
+			t.Fatalf("round trip: expected %q, got %q", v, got["K"])
+		}
+	})
+}
@@ -0,0 +1,113 @@
+// Package dupes finds secrets repeated across files: whole files with the
+// same content, and values (the same API key pasted into a dozen .env
+// files) that appear under keys in several files. It never reports a
+// value, only a fingerprint: an HMAC under a key made for the run, so
+// fingerprints line up within one report but cannot be matched against
+// guesses later.
+package dupes
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"slices"
+	"strings"
+)
+
+// Location is one place a value appears.
+type Location struct {
+	File string
+	Key  string
+}
+
+// Group is a value found in several files.
+type Group struct {
+	Fingerprint string
+	Length      int // in characters
+	Locations   []Location
+	Files       int // distinct files among Locations
+}
+
+// Finder collects the files of a tree.
+type Finder struct {
+	// MinLength skips values shorter than this many characters: ports,
+	// booleans, and "us-east-1" are shared on purpose.
+	MinLength int
+
+	key    []byte
+	files  map[string][]string // content fingerprint -> files
+	values map[string]*Group
+}
+
+// New returns a Finder with a fresh fingerprint key.
+func New(minLength int) *Finder {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		panic(err) // crypto/rand does not fail on supported platforms
+	}
+	return &Finder{MinLength: minLength, key: key, files: map[string][]string{}, values: map[string]*Group{}}
+}
+
+func (f *Finder) fingerprint(s string) string {
+	m := hmac.New(sha256.New, f.key)
+	m.Write([]byte(s))
+	return hex.EncodeToString(m.Sum(nil))[:12]
+}
+
+// Add records a decrypted file and its key/value pairs.
+func (f *Finder) Add(file, content string, values map[string]string) {
+	fp := f.fingerprint(content)
+	f.files[fp] = append(f.files[fp], file)
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	for _, k := range keys {
+		v := values[k]
+		if n := len([]rune(strings.TrimSpace(v))); n == 0 || n < f.MinLength {
+			continue
+		}
+		fp := f.fingerprint(v)
+		g := f.values[fp]
+		if g == nil {
+			g = &Group{Fingerprint: fp, Length: len([]rune(v))}
+			f.values[fp] = g
+		}
+		if n := len(g.Locations); n == 0 || g.Locations[n-1].File != file {
+			g.Files++
+		}
+		g.Locations = append(g.Locations, Location{File: file, Key: k})
+	}
+}
+
+// Values returns the values found in at least minFiles files, the most
+// widespread first.
+func (f *Finder) Values(minFiles int) []Group {
+	var out []Group
+	for _, g := range f.values {
+		if g.Files >= minFiles {
+			out = append(out, *g)
+		}
+	}
+	slices.SortFunc(out, func(a, b Group) int {
+		if a.Files != b.Files {
+			return b.Files - a.Files
+		}
+		return strings.Compare(a.Locations[0].File+a.Locations[0].Key, b.Locations[0].File+b.Locations[0].Key)
+	})
+	return out
+}
+
+// Files returns the sets of files whose decrypted content is identical.
+func (f *Finder) Files() [][]string {
+	var out [][]string
+	for _, files := range f.files {
+		if len(files) > 1 {
+			out = append(out, files)
+		}
+	}
+	slices.SortFunc(out, func(a, b []string) int { return strings.Compare(a[0], b[0]) })
+	return out
+}
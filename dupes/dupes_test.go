@@ -0,0 +1,56 @@
+package dupes
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestFinder(t *testing.T) {
+	leaked := "sk_live_0123456789abcdef"
+	f := New(8)
+	f.Add("a.env.age", "API_KEY="+leaked, map[string]string{"API_KEY": leaked, "PORT": "5432"})
+	f.Add("b.env.age", "STRIPE="+leaked, map[string]string{"STRIPE": leaked, "PORT": "5432", "OTHER": leaked})
+	f.Add("c.json.age", "{}", map[string]string{"stripe.key": leaked, "region": "eu-west-1a"})
+	f.Add("d.json.age", "{}", map[string]string{})
+
+	t.Run("groups a value across files", func(t *testing.T) {
+		groups := f.Values(2)
+		if len(groups) != 1 {
+			t.Fatalf("expected one repeated value, got %+v", groups)
+		}
+		g := groups[0]
+		if g.Files != 3 || len(g.Locations) != 4 || g.Length != len(leaked) {
+			t.Errorf("unexpected group: %+v", g)
+		}
+		if got := fmt.Sprint(g.Locations); !strings.Contains(got, "{b.env.age OTHER} {b.env.age STRIPE}") {
+			t.Errorf("unexpected locations: %s", got)
+		}
+		if strings.Contains(fmt.Sprintf("%+v", groups), leaked) {
+			t.Error("the value itself leaked into the report")
+		}
+	})
+
+	t.Run("skips short values and respects the file threshold", func(t *testing.T) {
+		if groups := f.Values(4); len(groups) != 0 {
+			t.Errorf("expected nothing in 4 files, got %+v", groups)
+		}
+		for _, g := range f.Values(1) {
+			if g.Locations[0].Key == "PORT" {
+				t.Error("expected values under MinLength to be skipped")
+			}
+		}
+	})
+
+	t.Run("finds identical files", func(t *testing.T) {
+		if got := f.Files(); len(got) != 1 || strings.Join(got[0], ",") != "c.json.age,d.json.age" {
+			t.Errorf("got %v", got)
+		}
+	})
+
+	t.Run("fingerprints differ between runs", func(t *testing.T) {
+		if New(8).fingerprint(leaked) == f.fingerprint(leaked) {
+			t.Error("expected a per-run fingerprint key")
+		}
+	})
+}
@@ -0,0 +1,115 @@
+// Package filetype decides which content format an agepad file holds from
+// its name, looking past the .age suffix (app.env.age is env) and honoring
+// user-supplied filename patterns (*.secrets=env).
+package filetype
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/andreweick/agepad/model"
+)
+
+// Formats lists the format names agepad understands.
+var Formats = []string{"env", "json", "yaml", "toml"}
+
+var extFormats = map[string]string{
+	".env":  "env",
+	".json": "json",
+	".yaml": "yaml",
+	".yml":  "yaml",
+	".toml": "toml",
+}
+
+// wrappers are extensions that wrap content without changing its format.
+var wrappers = map[string]bool{".age": true}
+
+// Inner returns the base name of path without a trailing .age suffix.
+func Inner(path string) string {
+	base := filepath.Base(path)
+	if strings.HasSuffix(strings.ToLower(base), ".age") {
+		base = base[:len(base)-len(".age")]
+	}
+	return base
+}
+
+// Extensions returns the lower-cased extension chain of path's base name,
+// outermost last: "app.prod.env.age" gives [".prod", ".env", ".age"]. A
+// leading dot (".env") is part of the name, not an extension.
+func Extensions(path string) []string {
+	name := strings.ToLower(filepath.Base(path))
+	var chain []string
+	for {
+		ext := filepath.Ext(name)
+		if ext == "" || ext == name {
+			break
+		}
+		chain = append([]string{ext}, chain...)
+		name = strings.TrimSuffix(name, ext)
+	}
+	if f, ok := extFormats[name]; ok && f == "env" {
+		// ".env" itself, possibly followed by wrappers (.env.age).
+		chain = append([]string{name}, chain...)
+	}
+	return chain
+}
+
+// FromName returns the format for path, or "" when it cannot be told from
+// the name. Rules are tried first, in order; their patterns match the base
+// name (with or without .age), or the whole slash-separated path when they
+// contain a '/'. Otherwise the extension chain is read from the outside in,
+// skipping wrappers such as .age, and the first remaining extension decides.
+func FromName(path string, rules []model.TypeRule) string {
+	slash := filepath.ToSlash(path)
+	for _, r := range rules {
+		if matches(r.Pattern, slash) {
+			return r.Format
+		}
+	}
+	chain := Extensions(path)
+	for i := len(chain) - 1; i >= 0; i-- {
+		if wrappers[chain[i]] {
+			continue
+		}
+		return extFormats[chain[i]]
+	}
+	return ""
+}
+
+func matches(pattern, slash string) bool {
+	if strings.Contains(pattern, "/") {
+		ok, _ := filepath.Match(pattern, slash)
+		return ok
+	}
+	base := filepath.Base(slash)
+	for _, name := range []string{base, Inner(base)} {
+		if ok, _ := filepath.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseRules parses "PATTERN=FORMAT" pairs such as "*.secrets=env" or
+// "config/*.cfg=toml".
+func ParseRules(pairs []string) ([]model.TypeRule, error) {
+	var out []model.TypeRule
+	for _, p := range pairs {
+		pattern, format, ok := strings.Cut(strings.TrimSpace(p), "=")
+		pattern = strings.TrimSpace(pattern)
+		format = strings.ToLower(strings.TrimSpace(format))
+		if !ok || pattern == "" {
+			return nil, fmt.Errorf("type mapping %q: expected PATTERN=FORMAT", p)
+		}
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("type mapping %q: %w", p, err)
+		}
+		if !slices.Contains(Formats, format) {
+			return nil, fmt.Errorf("type mapping %q: unknown format %q (want %s)", p, format, strings.Join(Formats, ", "))
+		}
+		out = append(out, model.TypeRule{Pattern: pattern, Format: format})
+	}
+	return out, nil
+}
@@ -0,0 +1,89 @@
+package filetype
+
+import (
+	"testing"
+
+	"github.com/andreweick/agepad/model"
+)
+
+func TestFromName(t *testing.T) {
+	t.Run("looks past the .age suffix", func(t *testing.T) {
+		cases := map[string]string{
+			"secrets/app.env.age":   "env",
+			"config.JSON.age":       "json",
+			"values.yml.age":        "yaml",
+			"settings.toml":         "toml",
+			".env.age":              "env",
+			"notes.age":             "",
+			"archive.tar.age":       "",
+			"secrets/app.env.age.x": "",
+			"app.prod.json.age":     "json",
+			"app.json.age.age":      "json",
+		}
+		for in, want := range cases {
+			if got := FromName(in, nil); got != want {
+				t.Errorf("FromName(%q) = %q, want %q", in, got, want)
+			}
+		}
+	})
+
+	t.Run("applies rules before extensions", func(t *testing.T) {
+		rules := []model.TypeRule{
+			{Pattern: "*.secrets", Format: "env"},
+			{Pattern: "legacy/*.json.age", Format: "yaml"},
+		}
+		cases := map[string]string{
+			"prod.secrets.age":     "env",
+			"prod.secrets":         "env",
+			"legacy/old.json.age":  "yaml",
+			"current/new.json.age": "json",
+		}
+		for in, want := range cases {
+			if got := FromName(in, rules); got != want {
+				t.Errorf("FromName(%q) = %q, want %q", in, got, want)
+			}
+		}
+	})
+}
+
+func TestExtensions(t *testing.T) {
+	t.Run("splits the extension chain", func(t *testing.T) {
+		cases := map[string][]string{
+			"dir/app.prod.env.age": {".prod", ".env", ".age"},
+			".env.age":             {".env", ".age"},
+			"plain":                nil,
+		}
+		for in, want := range cases {
+			got := Extensions(in)
+			if len(got) != len(want) {
+				t.Errorf("Extensions(%q) = %v, want %v", in, got, want)
+				continue
+			}
+			for i := range got {
+				if got[i] != want[i] {
+					t.Errorf("Extensions(%q) = %v, want %v", in, got, want)
+				}
+			}
+		}
+	})
+}
+
+func TestParseRules(t *testing.T) {
+	t.Run("parses pattern pairs", func(t *testing.T) {
+		rules, err := ParseRules([]string{"*.secrets=env", " *.cfg = TOML "})
+		if err != nil {
+			t.Fatalf("parse failed: %v", err)
+		}
+		if len(rules) != 2 || rules[1] != (model.TypeRule{Pattern: "*.cfg", Format: "toml"}) {
+			t.Errorf("unexpected rules: %+v", rules)
+		}
+	})
+
+	t.Run("rejects bad pairs", func(t *testing.T) {
+		for _, in := range []string{"*.x", "=env", "*.x=xml", "[=env"} {
+			if _, err := ParseRules([]string{in}); err == nil {
+				t.Errorf("expected error for %q", in)
+			}
+		}
+	})
+}
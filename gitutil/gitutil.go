@@ -0,0 +1,112 @@
+// Package gitutil reads file content and change lists from a git
+// repository by invoking the git binary. Content is returned in memory;
+// nothing is checked out or written to the working tree. Staged lists what
+// the next commit would record, Commit records files agepad has already
+// written, and SetConfig sets up agepad's diff driver.
+package gitutil
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// Repo runs git commands in Dir (the current directory when empty).
+type Repo struct {
+	Dir string
+}
+
+// Change is one file changed between two revisions.
+type Change struct {
+	Status string // A (added), M (modified), D (deleted), ...
+	Path   string
+}
+
+func (r Repo) git(args ...string) ([]byte, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = r.Dir
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("git %s: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.Bytes(), nil
+}
+
+// Exists reports whether path exists at ref.
+func (r Repo) Exists(ref, path string) bool {
+	_, err := r.git("cat-file", "-e", ref+":"+path)
+	return err == nil
+}
+
+// Show returns the content of path at ref.
+func (r Repo) Show(ref, path string) ([]byte, error) {
+	return r.git("show", ref+":"+path)
+}
+
+// ChangedFiles lists files that differ between base and head (renames are
+// reported as a delete plus an add).
+func (r Repo) ChangedFiles(base, head string) ([]Change, error) {
+	out, err := r.git("diff", "--name-status", "--no-renames", "-z", base, head)
+	if err != nil {
+		return nil, err
+	}
+	fields := strings.Split(strings.TrimSuffix(string(out), "\x00"), "\x00")
+	var changes []Change
+	for i := 0; i+1 < len(fields); i += 2 {
+		changes = append(changes, Change{Status: fields[i], Path: fields[i+1]})
+	}
+	return changes, nil
+}
+
+// Staged lists the files staged for the next commit, leaving out deletions.
+func (r Repo) Staged() ([]string, error) {
+	out, err := r.git("diff", "--cached", "--name-only", "--no-renames", "--diff-filter=d", "-z")
+	if err != nil {
+		return nil, err
+	}
+	return strings.FieldsFunc(string(out), func(r rune) bool { return r == 0 }), nil
+}
+
+// Commit records paths as they are in the working tree in a new commit
+// with message, leaving anything else that is staged out of it. It returns
+// the commit's hash.
+func (r Repo) Commit(message string, paths ...string) (string, error) {
+	if _, err := r.git(append([]string{"add", "--"}, paths...)...); err != nil {
+		return "", err
+	}
+	if _, err := r.git(append([]string{"commit", "-q", "-m", message, "--"}, paths...)...); err != nil {
+		return "", err
+	}
+	out, err := r.git("rev-parse", "HEAD")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// SetConfig sets key to value in the repository's own config
+// (.git/config).
+func (r Repo) SetConfig(key, value string) error {
+	_, err := r.git("config", "--local", key, value)
+	return err
+}
+
+// Config returns the value of key, or "" if it is not set.
+func (r Repo) Config(key string) string {
+	out, err := r.git("config", "--get", key)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// TopLevel returns the root directory of the working tree.
+func (r Repo) TopLevel() (string, error) {
+	out, err := r.git("rev-parse", "--show-toplevel")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
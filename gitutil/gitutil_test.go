@@ -0,0 +1,147 @@
+package gitutil
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+// newRepo creates a repository with two commits and returns it with the
+// first commit's hash.
+func newRepo(t *testing.T) (Repo, string) {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	dir := t.TempDir()
+	run := func(args ...string) string {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(),
+			"GIT_AUTHOR_NAME=t", "GIT_AUTHOR_EMAIL=t@example.com",
+			"GIT_COMMITTER_NAME=t", "GIT_COMMITTER_EMAIL=t@example.com")
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %v: %v: %s", args, err, out)
+		}
+		return string(out)
+	}
+	write := func(name, content string) {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+	run("init", "-q")
+	write("a.age", "one")
+	write("b.age", "gone")
+	run("add", ".")
+	run("commit", "-qm", "first")
+	base := run("rev-parse", "HEAD")
+	write("a.age", "two")
+	write("c.age", "new")
+	run("rm", "-q", "b.age")
+	run("add", ".")
+	run("commit", "-qm", "second")
+	return Repo{Dir: dir}, base[:len(base)-1]
+}
+
+func TestRepo(t *testing.T) {
+	repo, base := newRepo(t)
+
+	t.Run("shows content at a revision", func(t *testing.T) {
+		b, err := repo.Show(base, "a.age")
+		if err != nil {
+			t.Fatalf("show failed: %v", err)
+		}
+		if string(b) != "one" {
+			t.Errorf("expected old content, got %q", b)
+		}
+	})
+
+	t.Run("reports whether a path exists at a revision", func(t *testing.T) {
+		if !repo.Exists(base, "b.age") {
+			t.Error("expected b.age to exist at base")
+		}
+		if repo.Exists("HEAD", "b.age") {
+			t.Error("expected b.age to be gone at HEAD")
+		}
+	})
+
+	t.Run("lists changed files with status", func(t *testing.T) {
+		changes, err := repo.ChangedFiles(base, "HEAD")
+		if err != nil {
+			t.Fatalf("changed files failed: %v", err)
+		}
+		got := map[string]string{}
+		for _, c := range changes {
+			got[c.Path] = c.Status
+		}
+		if got["a.age"] != "M" || got["b.age"] != "D" || got["c.age"] != "A" {
+			t.Errorf("unexpected changes %v", changes)
+		}
+	})
+
+	t.Run("commits only the given paths", func(t *testing.T) {
+		for _, kv := range [][2]string{{"GIT_AUTHOR_NAME", "t"}, {"GIT_AUTHOR_EMAIL", "t@example.com"}, {"GIT_COMMITTER_NAME", "t"}, {"GIT_COMMITTER_EMAIL", "t@example.com"}} {
+			t.Setenv(kv[0], kv[1])
+		}
+		for name, content := range map[string]string{"a.age": "three", "d.age": "added", "other.txt": "staged"} {
+			if err := os.WriteFile(filepath.Join(repo.Dir, name), []byte(content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if _, err := repo.git("add", "other.txt"); err != nil {
+			t.Fatal(err)
+		}
+		hash, err := repo.Commit("rotate", "a.age", "d.age")
+		if err != nil {
+			t.Fatal(err)
+		}
+		changes, err := repo.ChangedFiles(hash+"~1", hash)
+		if err != nil || len(changes) != 2 {
+			t.Errorf("expected a.age and d.age only, got %v (%v)", changes, err)
+		}
+		if b, err := repo.Show(hash, "d.age"); err != nil || string(b) != "added" {
+			t.Errorf("expected the new file committed, got %q (%v)", b, err)
+		}
+	})
+
+	t.Run("lists staged files without deletions", func(t *testing.T) {
+		// other.txt is still staged from the commit above.
+		if err := os.WriteFile(filepath.Join(repo.Dir, "e.age"), []byte("e"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := repo.git("add", "e.age"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := repo.git("rm", "-q", "c.age"); err != nil {
+			t.Fatal(err)
+		}
+		got, err := repo.Staged()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != 2 || got[0] != "e.age" || got[1] != "other.txt" {
+			t.Errorf("got %q", got)
+		}
+	})
+
+	t.Run("sets repository config", func(t *testing.T) {
+		if err := repo.SetConfig("diff.agepad.textconv", "agepad git-textconv"); err != nil {
+			t.Fatal(err)
+		}
+		if got := repo.Config("diff.agepad.textconv"); got != "agepad git-textconv" {
+			t.Errorf("got %q", got)
+		}
+		if got := repo.Config("diff.agepad.nope"); got != "" {
+			t.Errorf("expected an unset key to be empty, got %q", got)
+		}
+	})
+
+	t.Run("fails for unknown revisions", func(t *testing.T) {
+		if _, err := repo.ChangedFiles("nope", "HEAD"); err == nil {
+			t.Error("expected error for unknown revision")
+		}
+	})
+}
@@ -0,0 +1,112 @@
+// Package harden applies best-effort process hardening so decrypted
+// plaintext is less likely to leak out of RAM via core dumps or swap.
+package harden
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unsafe"
+)
+
+var errUnsupported = errors.New("not supported on this platform")
+
+// Item describes the outcome of one hardening measure.
+type Item struct {
+	Name    string
+	Applied bool
+	Detail  string
+}
+
+// Report lists what was (and was not) applied on this platform.
+type Report struct {
+	Items []Item
+}
+
+func (r *Report) add(name string, detail string, err error) {
+	if err != nil {
+		r.Items = append(r.Items, Item{Name: name, Detail: err.Error()})
+		return
+	}
+	r.Items = append(r.Items, Item{Name: name, Applied: true, Detail: detail})
+}
+
+// String renders the report one measure per line.
+func (r Report) String() string {
+	var b strings.Builder
+	for _, it := range r.Items {
+		mark := "ok  "
+		if !it.Applied {
+			mark = "skip"
+		}
+		fmt.Fprintf(&b, "[%s] %s", mark, it.Name)
+		if it.Detail != "" {
+			fmt.Fprintf(&b, ": %s", it.Detail)
+		}
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// Apply disables core dumps, marks the process non-dumpable where possible,
+// and checks whether swap could receive plaintext pages.
+func Apply() Report {
+	var r Report
+	r.add("core dumps disabled (RLIMIT_CORE=0)", "", disableCoreDumps())
+	r.add("process marked non-dumpable", "", setNonDumpable())
+	detail, err := checkSwap()
+	r.add("swap check", detail, err)
+	return r
+}
+
+// DontDump excludes the memory backing s from core dumps where supported
+// (madvise MADV_DONTDUMP on Linux). The advice covers whole pages, so
+// neighbouring heap objects on the same pages are excluded too.
+func DontDump(s string) error {
+	if len(s) == 0 {
+		return nil
+	}
+	return dontDump(unsafe.Slice(unsafe.StringData(s), len(s)))
+}
+
+// ExcludeFromDumps applies DontDump to s and records the outcome under name.
+func (r *Report) ExcludeFromDumps(name string, s string) {
+	r.add(name+" excluded from core dumps (MADV_DONTDUMP)", "", DontDump(s))
+}
+
+// parseSwaps inspects /proc/swaps content and returns the swap areas that do
+// not look encrypted (dm-crypt mappings) or RAM-backed (zram).
+func parseSwaps(content string) (active int, unencrypted []string) {
+	lines := strings.Split(strings.TrimSpace(content), "\n")
+	for _, line := range lines[1:] { // skip header
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		active++
+		name := fields[0]
+		if strings.HasPrefix(name, "/dev/mapper/") || strings.HasPrefix(name, "/dev/dm-") ||
+			strings.HasPrefix(name, "/dev/zram") {
+			continue
+		}
+		unencrypted = append(unencrypted, name)
+	}
+	return active, unencrypted
+}
+
+// Lock keeps the pages backing b out of swap (mlock) where supported.
+// Callers should Unlock b, after zeroing it, once it is no longer needed.
+func Lock(b []byte) error {
+	if len(b) == 0 {
+		return nil
+	}
+	return lock(b)
+}
+
+// Unlock releases a Lock on b.
+func Unlock(b []byte) error {
+	if len(b) == 0 {
+		return nil
+	}
+	return unlock(b)
+}
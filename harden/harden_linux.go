@@ -0,0 +1,40 @@
+package harden
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+func setNonDumpable() error {
+	return unix.Prctl(unix.PR_SET_DUMPABLE, 0, 0, 0, 0)
+}
+
+func dontDump(b []byte) error {
+	page := uintptr(os.Getpagesize())
+	p := unsafe.Pointer(unsafe.SliceData(b))
+	lead := uintptr(p) & (page - 1)
+	n := (lead + uintptr(len(b)) + page - 1) &^ (page - 1)
+	aligned := unsafe.Slice((*byte)(unsafe.Add(p, -int(lead))), n)
+	return unix.Madvise(aligned, unix.MADV_DONTDUMP)
+}
+
+func checkSwap() (string, error) {
+	b, err := os.ReadFile("/proc/swaps")
+	if err != nil {
+		return "", fmt.Errorf("read /proc/swaps: %w", err)
+	}
+	active, plain := parseSwaps(string(b))
+	switch {
+	case active == 0:
+		return "no swap enabled", nil
+	case len(plain) == 0:
+		return "all swap areas are encrypted or RAM-backed", nil
+	default:
+		return "", fmt.Errorf("WARNING: swap may not be encrypted (%s); plaintext pages could reach disk",
+			strings.Join(plain, ", "))
+	}
+}
@@ -0,0 +1,9 @@
+//go:build !linux
+
+package harden
+
+func setNonDumpable() error { return errUnsupported }
+
+func dontDump(b []byte) error { return errUnsupported }
+
+func checkSwap() (string, error) { return "", errUnsupported }
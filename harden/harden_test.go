@@ -0,0 +1,76 @@
+package harden
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseSwaps(t *testing.T) {
+	header := "Filename\t\t\t\tType\t\tSize\t\tUsed\t\tPriority\n"
+
+	t.Run("reports no swap when only the header is present", func(t *testing.T) {
+		active, plain := parseSwaps(header)
+		if active != 0 || len(plain) != 0 {
+			t.Errorf("expected no swap, got active=%d plain=%v", active, plain)
+		}
+	})
+
+	t.Run("accepts dm-crypt and zram swap", func(t *testing.T) {
+		content := header +
+			"/dev/mapper/cryptswap partition 8388604 0 -2\n" +
+			"/dev/zram0 partition 4194300 0 100\n"
+		active, plain := parseSwaps(content)
+		if active != 2 {
+			t.Errorf("expected 2 active swap areas, got %d", active)
+		}
+		if len(plain) != 0 {
+			t.Errorf("expected no unencrypted swap, got %v", plain)
+		}
+	})
+
+	t.Run("flags plain partitions and swap files", func(t *testing.T) {
+		content := header +
+			"/dev/sda2 partition 8388604 0 -2\n" +
+			"/swapfile file 2097148 0 -3\n"
+		_, plain := parseSwaps(content)
+		if len(plain) != 2 || plain[0] != "/dev/sda2" || plain[1] != "/swapfile" {
+			t.Errorf("expected both areas flagged, got %v", plain)
+		}
+	})
+}
+
+func TestReport(t *testing.T) {
+	t.Run("renders applied and skipped measures", func(t *testing.T) {
+		r := Report{Items: []Item{
+			{Name: "core dumps disabled", Applied: true},
+			{Name: "swap check", Detail: "not supported on this platform"},
+		}}
+		out := r.String()
+
+		if !strings.Contains(out, "[ok  ] core dumps disabled") {
+			t.Errorf("expected applied item in report, got: %s", out)
+		}
+		if !strings.Contains(out, "[skip] swap check: not supported") {
+			t.Errorf("expected skipped item with detail, got: %s", out)
+		}
+	})
+}
+
+func TestDontDump(t *testing.T) {
+	t.Run("ignores empty input", func(t *testing.T) {
+		if err := DontDump(""); err != nil {
+			t.Errorf("expected no error for empty input, got %v", err)
+		}
+	})
+}
+
+func TestLock(t *testing.T) {
+	t.Run("ignores empty input", func(t *testing.T) {
+		if err := Lock(nil); err != nil {
+			t.Errorf("expected no error for empty input, got %v", err)
+		}
+		if err := Unlock(nil); err != nil {
+			t.Errorf("expected no error for empty input, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,6 @@
+//go:build !unix
+
+package harden
+
+func lock(b []byte) error   { return errUnsupported }
+func unlock(b []byte) error { return errUnsupported }
@@ -0,0 +1,8 @@
+//go:build unix
+
+package harden
+
+import "golang.org/x/sys/unix"
+
+func lock(b []byte) error   { return unix.Mlock(b) }
+func unlock(b []byte) error { return unix.Munlock(b) }
@@ -0,0 +1,271 @@
+// Package highlight colorizes lines of .env, JSON, YAML, and TOML content
+// for the editor. It reads one line at a time without parsing the whole
+// document, so it never fails: a broken line simply colors oddly (a string
+// that runs to the end of the line, say), which is the point — structural
+// mistakes show up while typing rather than at save time.
+package highlight
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Kind classifies a run of text.
+type Kind int
+
+const (
+	Plain   Kind = iota
+	Key          // env names, JSON/YAML/TOML keys
+	Section      // TOML [tables], YAML document markers
+	String       // quoted values
+	Literal      // numbers, booleans, null, YAML anchors and aliases
+	Comment
+)
+
+// Token is a run of a line's text and its kind.
+type Token struct {
+	Kind Kind
+	Text string
+}
+
+// Theme maps each kind to its SGR attributes; kinds it leaves out (Plain
+// always) are not styled.
+type Theme map[Kind]string
+
+// Themes are the built-in themes by name. Both use the terminal's own
+// palette, so light and dark backgrounds stay readable.
+var Themes = map[string]Theme{
+	"default": {
+		Key:     "34",   // blue
+		Section: "1;36", // bold cyan
+		String:  "32",   // green
+		Literal: "35",   // magenta
+		Comment: "90",   // bright black
+	},
+	// For monochrome terminals and color-blind users.
+	"mono": {
+		Key:     "1",   // bold
+		Section: "1;4", // bold underline
+		Literal: "3",   // italic
+		Comment: "2",   // faint
+	},
+}
+
+// Line returns line styled by theme for format ("env", "json", "yaml", or
+// "toml"); other formats are returned unchanged.
+func Line(theme Theme, format, line string) string {
+	toks := Tokens(format, line)
+	if toks == nil {
+		return line
+	}
+	var b strings.Builder
+	for _, t := range toks {
+		if sgr, ok := theme[t.Kind]; ok {
+			b.WriteString("\x1b[" + sgr + "m" + t.Text + "\x1b[0m")
+		} else {
+			b.WriteString(t.Text)
+		}
+	}
+	return b.String()
+}
+
+// Tokens splits line into tokens for format, or returns nil for formats
+// it does not know. Joining the tokens' text gives back line.
+func Tokens(format, line string) []Token {
+	switch format {
+	case "env":
+		return envTokens(line)
+	case "json":
+		return jsonTokens(line)
+	case "yaml":
+		return yamlTokens(line)
+	case "toml":
+		return tomlTokens(line)
+	}
+	return nil
+}
+
+// tokens accumulates a line's tokens, merging neighbours of one kind.
+type tokens []Token
+
+func (ts *tokens) add(k Kind, s string) {
+	if s == "" {
+		return
+	}
+	if n := len(*ts); n > 0 && (*ts)[n-1].Kind == k {
+		(*ts)[n-1].Text += s
+		return
+	}
+	*ts = append(*ts, Token{k, s})
+}
+
+var (
+	envKey     = regexp.MustCompile(`^(\s*)(export\s+)?([^\s=#'"]+)(\s*=)`)
+	yamlKey    = regexp.MustCompile(`^(\s*(?:-\s+)*)("(?:[^"\\]|\\.)*"|'[^']*'|[^\s#'"][^:#]*?)(\s*:)(\s|$)`)
+	tomlKey    = regexp.MustCompile(`^(\s*)([A-Za-z0-9_\-."']+?)(\s*=)`)
+	tomlHeader = regexp.MustCompile(`^\s*\[\[?[^\]]*\]\]?`)
+	literal    = regexp.MustCompile(`^(?:-?(?:0|[1-9][0-9_]*)(?:\.[0-9_]+)?(?:[eE][+-]?[0-9]+)?|0x[0-9A-Fa-f_]+|true|false|null|~|yes|no|on|off|inf|nan|[+-]inf|[+-]nan|\d{4}-\d\d-\d\d[T ]?[0-9:.Z+-]*)$`)
+)
+
+// value tokenizes a value: quoted strings, comments after whitespace, and
+// bare words that are literals in YAML or TOML. hash reports whether "#"
+// after whitespace starts a comment; without it (JSON) every ":" ends a
+// word.
+func (ts *tokens) value(s string, hash bool) {
+	word := func(w string) {
+		if literal.MatchString(strings.TrimSpace(w)) {
+			lead := w[:len(w)-len(strings.TrimLeft(w, " \t"))]
+			ts.add(Plain, lead)
+			rest := w[len(lead):]
+			trimmed := strings.TrimRight(rest, " \t")
+			ts.add(Literal, trimmed)
+			ts.add(Plain, rest[len(trimmed):])
+			return
+		}
+		ts.add(Plain, w)
+	}
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '"' || c == '\'':
+			word(s[start:i])
+			end := closeQuote(s, i)
+			ts.add(String, s[i:end])
+			i, start = end-1, end
+		case c == '#' && hash && (i == 0 || s[i-1] == ' ' || s[i-1] == '\t'):
+			word(s[start:i])
+			ts.add(Comment, s[i:])
+			return
+		case c == ',' || c == '[' || c == ']' || c == '{' || c == '}',
+			c == ':' && (!hash || i+1 == len(s) || s[i+1] == ' ' || s[i+1] == '\t'):
+			word(s[start:i])
+			ts.add(Plain, s[i:i+1])
+			start = i + 1
+		}
+	}
+	word(s[start:])
+}
+
+// closeQuote returns the index just past the quote closing the one at
+// open, or len(s) when the string runs off the line.
+func closeQuote(s string, open int) int {
+	q := s[open]
+	for i := open + 1; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && q == '"':
+			i++
+		case s[i] == q:
+			return i + 1
+		}
+	}
+	return len(s)
+}
+
+func envTokens(line string) []Token {
+	var ts tokens
+	if t := strings.TrimSpace(line); strings.HasPrefix(t, "#") {
+		ts.add(Comment, line)
+		return ts
+	}
+	m := envKey.FindStringSubmatch(line)
+	if m == nil {
+		ts.add(Plain, line)
+		return ts
+	}
+	ts.add(Plain, m[1])
+	ts.add(Literal, m[2])
+	ts.add(Key, m[3])
+	ts.add(Plain, m[4])
+	rest := line[len(m[0]):]
+	// Unquoted .env values are words, not YAML literals.
+	if t := strings.TrimLeft(rest, " \t"); t != "" && t[0] != '"' && t[0] != '\'' {
+		if i := strings.Index(rest, " #"); i >= 0 {
+			ts.add(String, rest[:i])
+			ts.add(Comment, rest[i:])
+		} else {
+			ts.add(String, rest)
+		}
+		return ts
+	}
+	ts.value(rest, true)
+	return ts
+}
+
+func jsonTokens(line string) []Token {
+	var ts tokens
+	start := 0
+	for i := 0; i < len(line); i++ {
+		if line[i] != '"' {
+			continue
+		}
+		ts.value(line[start:i], false)
+		end := closeQuote(line, i)
+		kind := String
+		if strings.HasPrefix(strings.TrimLeft(line[end:], " \t"), ":") {
+			kind = Key
+		}
+		ts.add(kind, line[i:end])
+		i, start = end-1, end
+	}
+	ts.value(line[start:], false)
+	return ts
+}
+
+func yamlTokens(line string) []Token {
+	var ts tokens
+	t := strings.TrimSpace(line)
+	switch {
+	case strings.HasPrefix(t, "#"):
+		ts.add(Comment, line)
+		return ts
+	case t == "---" || t == "...":
+		ts.add(Section, line)
+		return ts
+	}
+	rest := line
+	if m := yamlKey.FindStringSubmatch(line); m != nil {
+		ts.add(Plain, m[1])
+		ts.add(Key, m[2])
+		ts.add(Plain, m[3])
+		rest = line[len(m[1])+len(m[2])+len(m[3]):]
+	}
+	// Anchors, aliases, and tags before the value.
+	for {
+		lead := rest[:len(rest)-len(strings.TrimLeft(rest, " \t-"))]
+		w := strings.TrimLeft(rest, " \t-")
+		if w == "" || !strings.ContainsRune("&*!", rune(w[0])) {
+			break
+		}
+		end := strings.IndexAny(w, " \t")
+		if end < 0 {
+			end = len(w)
+		}
+		ts.add(Plain, lead)
+		ts.add(Literal, w[:end])
+		rest = w[end:]
+	}
+	ts.value(rest, true)
+	return ts
+}
+
+func tomlTokens(line string) []Token {
+	var ts tokens
+	if t := strings.TrimSpace(line); strings.HasPrefix(t, "#") {
+		ts.add(Comment, line)
+		return ts
+	}
+	if m := tomlHeader.FindString(line); m != "" {
+		ts.add(Section, m)
+		ts.value(line[len(m):], true)
+		return ts
+	}
+	rest := line
+	if m := tomlKey.FindStringSubmatch(line); m != nil {
+		ts.add(Plain, m[1])
+		ts.add(Key, m[2])
+		ts.add(Plain, m[3])
+		rest = line[len(m[0]):]
+	}
+	ts.value(rest, true)
+	return ts
+}
@@ -0,0 +1,68 @@
+package highlight
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTokens(t *testing.T) {
+	cases := []struct {
+		format, line string
+		want         []Token
+	}{
+		{"env", "export DB_PASSWORD=hunter2 # rotated", []Token{
+			{Literal, "export "}, {Key, "DB_PASSWORD"}, {Plain, "="}, {String, "hunter2"}, {Comment, " # rotated"},
+		}},
+		{"env", `API_KEY="s3cret"`, []Token{{Key, "API_KEY"}, {Plain, "="}, {String, `"s3cret"`}}},
+		{"env", "# comment", []Token{{Comment, "# comment"}}},
+		{"json", `  "port": 5432,`, []Token{
+			{Plain, "  "}, {Key, `"port"`}, {Plain, ": "}, {Literal, "5432"}, {Plain, ","},
+		}},
+		{"json", `  "a": ["x", true]`, []Token{
+			{Plain, "  "}, {Key, `"a"`}, {Plain, ": ["}, {String, `"x"`}, {Plain, ", "}, {Literal, "true"}, {Plain, "]"},
+		}},
+		{"yaml", "  password: hunter2 # old", []Token{
+			{Plain, "  "}, {Key, "password"}, {Plain, ": hunter2 "}, {Comment, "# old"},
+		}},
+		{"yaml", "base: &defaults", []Token{{Key, "base"}, {Plain, ": "}, {Literal, "&defaults"}}},
+		{"yaml", "---", []Token{{Section, "---"}}},
+		{"toml", "[[users]]", []Token{{Section, "[[users]]"}}},
+		{"toml", `name = "a#b" # note`, []Token{
+			{Key, "name"}, {Plain, " = "}, {String, `"a#b"`}, {Plain, " "}, {Comment, "# note"},
+		}},
+		{"toml", "port = 5432", []Token{{Key, "port"}, {Plain, " = "}, {Literal, "5432"}}},
+	}
+	for _, c := range cases {
+		t.Run(c.format+" "+c.line, func(t *testing.T) {
+			got := Tokens(c.format, c.line)
+			var b strings.Builder
+			for _, tok := range got {
+				b.WriteString(tok.Text)
+			}
+			if b.String() != c.line {
+				t.Errorf("tokens join to %q", b.String())
+			}
+			if len(got) != len(c.want) {
+				t.Fatalf("got %v, want %v", got, c.want)
+			}
+			for i := range got {
+				if got[i] != c.want[i] {
+					t.Errorf("token %d: got %v, want %v", i, got[i], c.want[i])
+				}
+			}
+		})
+	}
+
+	t.Run("unknown formats are left alone", func(t *testing.T) {
+		if Tokens("", "a=b") != nil || Line(Themes["default"], "", "a=b") != "a=b" {
+			t.Error("expected no highlighting")
+		}
+	})
+
+	t.Run("unterminated strings run to the end of the line", func(t *testing.T) {
+		got := Tokens("json", `  "key": "open, [1]`)
+		if last := got[len(got)-1]; last.Kind != String || last.Text != `"open, [1]` {
+			t.Errorf("got %v", got)
+		}
+	})
+}
@@ -0,0 +1,60 @@
+package index
+
+import "hash/fnv"
+
+// bitsPerKey and hashes give roughly a 1% false-positive rate.
+const (
+	bitsPerKey = 10
+	hashes     = 7
+)
+
+// Bloom is a fixed-size Bloom filter over key names. It can say a key is
+// definitely absent, or that it may be present.
+type Bloom struct {
+	Bits []byte `json:"bits"`
+	K    int    `json:"k"`
+}
+
+// NewBloom returns a filter sized for n keys.
+func NewBloom(n int) Bloom {
+	m := n * bitsPerKey
+	if m < 64 {
+		m = 64
+	}
+	return Bloom{Bits: make([]byte, (m+7)/8), K: hashes}
+}
+
+// Add records key in the filter.
+func (b Bloom) Add(key string) {
+	for _, i := range b.positions(key) {
+		b.Bits[i/8] |= 1 << (i % 8)
+	}
+}
+
+// MayContain reports whether key may have been added. False means it
+// definitely was not.
+func (b Bloom) MayContain(key string) bool {
+	if len(b.Bits) == 0 {
+		return false
+	}
+	for _, i := range b.positions(key) {
+		if b.Bits[i/8]&(1<<(i%8)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// positions derives K bit positions from two FNV hashes (double hashing).
+func (b Bloom) positions(key string) []uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	h1 := h.Sum64()
+	h2 := h1>>33 | h1<<31 | 1
+	m := uint64(len(b.Bits) * 8)
+	out := make([]uint64, b.K)
+	for i := range out {
+		out[i] = (h1 + uint64(i)*h2) % m
+	}
+	return out
+}
@@ -0,0 +1,141 @@
+// Package index caches which key names each encrypted file under a tree
+// contains, as one Bloom filter per file, so lookups only decrypt files
+// that may match. The cache holds key names only, never values, and is
+// itself age-encrypted. Entries are invalidated by the file's ciphertext
+// hash.
+package index
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"filippo.io/age"
+	agepkg "github.com/andreweick/agepad/age"
+	"github.com/andreweick/agepad/walk"
+)
+
+// Dir is the cache directory created under an indexed root.
+const Dir = walk.CacheDir
+
+// version changes whenever the on-disk layout does; older caches are
+// discarded.
+const version = 1
+
+// Path returns the index file for root.
+func Path(root string) string {
+	return filepath.Join(root, Dir, "index.age")
+}
+
+// Entry describes one indexed file.
+type Entry struct {
+	Hash   string `json:"hash"` // sha256 of the ciphertext
+	Filter Bloom  `json:"filter"`
+}
+
+// Index maps file paths (relative to the root) to their entries.
+type Index struct {
+	Version int              `json:"version"`
+	Files   map[string]Entry `json:"files"`
+}
+
+// New returns an empty index.
+func New() *Index {
+	return &Index{Version: version, Files: map[string]Entry{}}
+}
+
+// Hash returns the hash Entry.Hash is compared against.
+func Hash(ciphertext []byte) string {
+	sum := sha256.Sum256(ciphertext)
+	return hex.EncodeToString(sum[:])
+}
+
+// Load decrypts the index at path. A missing file, or one written by a
+// different version, yields an empty index.
+func Load(path string, ids []age.Identity) (*Index, error) {
+	plain, err := agepkg.DecryptToMemory(path, ids)
+	if errors.Is(err, fs.ErrNotExist) {
+		return New(), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("index: %w", err)
+	}
+	ix := New()
+	if err := json.Unmarshal([]byte(plain), ix); err != nil {
+		return nil, fmt.Errorf("index: %s: %w", path, err)
+	}
+	if ix.Version != version {
+		return New(), nil
+	}
+	if ix.Files == nil {
+		ix.Files = map[string]Entry{}
+	}
+	return ix, nil
+}
+
+// Save encrypts the index to recips and writes it to path, creating the
+// cache directory if needed.
+func (ix *Index) Save(path string, recips []age.Recipient, armor bool) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("index: %w", err)
+	}
+	b, err := json.Marshal(ix)
+	if err != nil {
+		return fmt.Errorf("index: %w", err)
+	}
+	if err := agepkg.EncryptWriteRetrying(path, b, recips, armor, agepkg.DefaultRetry); err != nil {
+		return fmt.Errorf("index: %w", err)
+	}
+	return nil
+}
+
+// Fresh reports whether file is indexed for exactly this ciphertext hash.
+func (ix *Index) Fresh(file, hash string) bool {
+	e, ok := ix.Files[file]
+	return ok && e.Hash == hash
+}
+
+// Update replaces file's entry with one built from keys.
+func (ix *Index) Update(file, hash string, keys []string) {
+	f := NewBloom(len(keys))
+	for _, k := range keys {
+		f.Add(k)
+	}
+	ix.Files[file] = Entry{Hash: hash, Filter: f}
+}
+
+// Prune drops entries for files not in present and reports how many were
+// removed.
+func (ix *Index) Prune(present []string) int {
+	keep := map[string]bool{}
+	for _, f := range present {
+		keep[f] = true
+	}
+	n := 0
+	for f := range ix.Files {
+		if !keep[f] {
+			delete(ix.Files, f)
+			n++
+		}
+	}
+	return n
+}
+
+// Candidates returns, sorted, the files that may contain key. Files not
+// in the list definitely do not.
+func (ix *Index) Candidates(key string) []string {
+	var out []string
+	for f, e := range ix.Files {
+		if e.Filter.MayContain(key) {
+			out = append(out, f)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
@@ -0,0 +1,123 @@
+package index
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"filippo.io/age"
+)
+
+func TestBloom(t *testing.T) {
+	t.Run("added keys are always found", func(t *testing.T) {
+		b := NewBloom(100)
+		for i := 0; i < 100; i++ {
+			b.Add(fmt.Sprintf("KEY_%d", i))
+		}
+		for i := 0; i < 100; i++ {
+			if !b.MayContain(fmt.Sprintf("KEY_%d", i)) {
+				t.Fatalf("expected KEY_%d to be found", i)
+			}
+		}
+	})
+
+	t.Run("false positives stay rare", func(t *testing.T) {
+		b := NewBloom(100)
+		for i := 0; i < 100; i++ {
+			b.Add(fmt.Sprintf("KEY_%d", i))
+		}
+		fp := 0
+		for i := 0; i < 10000; i++ {
+			if b.MayContain(fmt.Sprintf("OTHER_%d", i)) {
+				fp++
+			}
+		}
+		if fp > 300 {
+			t.Errorf("expected about 1%% false positives, got %d in 10000", fp)
+		}
+	})
+
+	t.Run("an empty filter contains nothing", func(t *testing.T) {
+		if (Bloom{}).MayContain("KEY") {
+			t.Error("expected zero Bloom to contain nothing")
+		}
+	})
+}
+
+func TestIndex(t *testing.T) {
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatalf("failed to generate identity: %v", err)
+	}
+	ids := []age.Identity{identity}
+	recips := []age.Recipient{identity.Recipient()}
+
+	t.Run("a missing index loads empty", func(t *testing.T) {
+		ix, err := Load(Path(t.TempDir()), ids)
+		if err != nil {
+			t.Fatalf("Load failed: %v", err)
+		}
+		if len(ix.Files) != 0 {
+			t.Errorf("expected empty index, got %d files", len(ix.Files))
+		}
+	})
+
+	t.Run("round-trips encrypted without values", func(t *testing.T) {
+		root := t.TempDir()
+		ix := New()
+		ix.Update("a.env.age", Hash([]byte("cipher-a")), []string{"DB_PASSWORD", "API_KEY"})
+		if err := ix.Save(Path(root), recips, true); err != nil {
+			t.Fatalf("Save failed: %v", err)
+		}
+		raw, err := os.ReadFile(Path(root))
+		if err != nil {
+			t.Fatalf("failed to read index: %v", err)
+		}
+		if strings.Contains(string(raw), "a.env.age") {
+			t.Error("expected index to be encrypted")
+		}
+		info, err := os.Stat(filepath.Join(root, Dir))
+		if err != nil || info.Mode().Perm() != 0o700 {
+			t.Errorf("expected private cache dir, got %v (err %v)", info.Mode().Perm(), err)
+		}
+
+		got, err := Load(Path(root), ids)
+		if err != nil {
+			t.Fatalf("Load failed: %v", err)
+		}
+		if !got.Fresh("a.env.age", Hash([]byte("cipher-a"))) {
+			t.Error("expected entry to be fresh for the same ciphertext")
+		}
+		if got.Fresh("a.env.age", Hash([]byte("changed"))) {
+			t.Error("expected entry to be stale after the ciphertext changed")
+		}
+		if c := got.Candidates("DB_PASSWORD"); len(c) != 1 || c[0] != "a.env.age" {
+			t.Errorf("unexpected candidates: %v", c)
+		}
+	})
+
+	t.Run("rejects an index others cannot decrypt", func(t *testing.T) {
+		root := t.TempDir()
+		if err := New().Save(Path(root), recips, false); err != nil {
+			t.Fatalf("Save failed: %v", err)
+		}
+		other, _ := age.GenerateX25519Identity()
+		if _, err := Load(Path(root), []age.Identity{other}); err == nil {
+			t.Error("expected an error for a foreign identity")
+		}
+	})
+
+	t.Run("prunes deleted files", func(t *testing.T) {
+		ix := New()
+		ix.Update("a.age", "h1", []string{"A"})
+		ix.Update("b.age", "h2", []string{"B"})
+		if n := ix.Prune([]string{"a.age"}); n != 1 {
+			t.Errorf("expected 1 pruned, got %d", n)
+		}
+		if _, ok := ix.Files["b.age"]; ok {
+			t.Error("expected b.age to be pruned")
+		}
+	})
+}
@@ -0,0 +1,196 @@
+// Package keyops performs key-level edits on .env content while preserving
+// everything else on each line (comments, spacing, export prefixes, values).
+package keyops
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/andreweick/agepad/dotenv"
+)
+
+// entry is a parsed KEY=VALUE line; other lines have key == "".
+type entry struct {
+	lead string // indentation and optional "export "
+	key  string
+	rest string // everything from "=" on, verbatim
+}
+
+func parse(content string) []entry {
+	// Continuation lines of multiline quoted values are never keys.
+	inner := map[int]bool{}
+	if es, err := dotenv.Parse(content); err == nil {
+		for _, e := range es {
+			for l := e.Line + 1; l <= e.EndLine; l++ {
+				inner[l-1] = true
+			}
+		}
+	}
+
+	lines := strings.Split(content, "\n")
+	out := make([]entry, len(lines))
+	for i, line := range lines {
+		t := strings.TrimLeft(line, " \t")
+		if t == "" || strings.HasPrefix(t, "#") || inner[i] {
+			out[i] = entry{rest: line}
+			continue
+		}
+		lead := line[:len(line)-len(t)]
+		if strings.HasPrefix(t, "export ") {
+			lead += "export "
+			t = strings.TrimLeft(t[len("export "):], " \t")
+		}
+		k, v, ok := strings.Cut(t, "=")
+		key := strings.TrimSpace(k)
+		if !ok || key == "" {
+			out[i] = entry{rest: line}
+			continue
+		}
+		out[i] = entry{lead: lead, key: key, rest: k[len(strings.TrimRight(k, " \t")):] + "=" + v}
+	}
+	return out
+}
+
+func render(es []entry) string {
+	lines := make([]string, len(es))
+	for i, e := range es {
+		lines[i] = e.lead + e.key + e.rest
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Keys returns the keys defined in content, in file order (duplicates kept).
+func Keys(content string) []string {
+	var keys []string
+	for _, e := range parse(content) {
+		if e.key != "" {
+			keys = append(keys, e.key)
+		}
+	}
+	return keys
+}
+
+// Renaming maps old key names to new ones.
+type Renaming map[string]string
+
+// Pairs returns the renaming as "OLD -> NEW" lines sorted by old name.
+func (r Renaming) Pairs() []string {
+	out := make([]string, 0, len(r))
+	for o, n := range r {
+		out = append(out, o+" -> "+n)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// Apply renames keys according to fn (returning "" or the same name leaves
+// a key alone). It fails without changing anything if a new name collides
+// with a key that already exists or with another renamed key.
+func Apply(content string, fn func(key string) string) (string, Renaming, error) {
+	es := parse(content)
+	existing := map[string]bool{}
+	for _, e := range es {
+		if e.key != "" {
+			existing[e.key] = true
+		}
+	}
+	ren := Renaming{}
+	target := map[string]string{} // new name -> old name
+	for _, e := range es {
+		if e.key == "" {
+			continue
+		}
+		n := fn(e.key)
+		if n == "" || n == e.key {
+			continue
+		}
+		if _, seen := ren[e.key]; seen {
+			continue
+		}
+		if existing[n] {
+			if m := fn(n); m == "" || m == n { // the existing key stays put
+				return "", nil, fmt.Errorf("renaming %s to %s collides with existing key %s", e.key, n, n)
+			}
+		}
+		if prev, dup := target[n]; dup {
+			return "", nil, fmt.Errorf("keys %s and %s would both be renamed to %s", prev, e.key, n)
+		}
+		target[n] = e.key
+		ren[e.key] = n
+	}
+	for i, e := range es {
+		if n, ok := ren[e.key]; ok {
+			es[i].key = n
+		}
+	}
+	return render(es), ren, nil
+}
+
+// Rename renames a single key. It reports an error if old is missing or new
+// already exists.
+func Rename(content, old, new string) (string, error) {
+	out, ren, err := Apply(content, func(k string) string {
+		if k == old {
+			return new
+		}
+		return k
+	})
+	if err != nil {
+		return "", err
+	}
+	if len(ren) == 0 {
+		return "", fmt.Errorf("key %s not found", old)
+	}
+	return out, nil
+}
+
+// AddPrefix prefixes every key that does not already carry prefix.
+func AddPrefix(content, prefix string) (string, Renaming, error) {
+	return Apply(content, func(k string) string {
+		if strings.HasPrefix(k, prefix) {
+			return k
+		}
+		return prefix + k
+	})
+}
+
+// StripPrefix removes prefix from every key that carries it.
+func StripPrefix(content, prefix string) (string, Renaming, error) {
+	return Apply(content, func(k string) string {
+		if s, ok := strings.CutPrefix(k, prefix); ok && s != "" {
+			return s
+		}
+		return k
+	})
+}
+
+// Set assigns the already-rendered value to key, updating the first
+// existing definition in place or appending a new line. It reports whether
+// the key already existed.
+func Set(content, key, value string) (string, bool) {
+	es := parse(content)
+	for i, e := range es {
+		if e.key == key {
+			es[i].rest = "=" + value
+			return render(es), true
+		}
+	}
+	if content != "" && !strings.HasSuffix(content, "\n") {
+		content += "\n"
+	}
+	return content + key + "=" + value + "\n", false
+}
+
+// MaskValues replaces every value with "***" so key-level diffs can be
+// previewed without printing secrets.
+func MaskValues(content string) string {
+	es := parse(content)
+	for i, e := range es {
+		if e.key != "" {
+			eq := strings.Index(e.rest, "=")
+			es[i].rest = e.rest[:eq+1] + "***"
+		}
+	}
+	return render(es)
+}
@@ -0,0 +1,130 @@
+package keyops
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRename(t *testing.T) {
+	t.Run("renames a key and preserves the rest of the line", func(t *testing.T) {
+		in := "# db\nexport DB_PASS = \"x y\" # inline\nOTHER=1"
+		out, err := Rename(in, "DB_PASS", "DATABASE_PASSWORD")
+		if err != nil {
+			t.Fatalf("rename failed: %v", err)
+		}
+		want := "# db\nexport DATABASE_PASSWORD = \"x y\" # inline\nOTHER=1"
+		if out != want {
+			t.Errorf("expected %q, got %q", want, out)
+		}
+	})
+
+	t.Run("fails when the key is missing", func(t *testing.T) {
+		if _, err := Rename("A=1", "B", "C"); err == nil {
+			t.Error("expected error for missing key")
+		}
+	})
+
+	t.Run("fails when the new name already exists", func(t *testing.T) {
+		if _, err := Rename("A=1\nB=2", "A", "B"); err == nil {
+			t.Error("expected collision error")
+		}
+	})
+}
+
+func TestPrefix(t *testing.T) {
+	t.Run("adds a prefix to unprefixed keys", func(t *testing.T) {
+		out, ren, err := AddPrefix("A=1\nAPP_B=2\n", "APP_")
+		if err != nil {
+			t.Fatalf("add prefix failed: %v", err)
+		}
+		if out != "APP_A=1\nAPP_B=2\n" {
+			t.Errorf("unexpected output %q", out)
+		}
+		if len(ren) != 1 || ren["A"] != "APP_A" {
+			t.Errorf("unexpected renaming %v", ren)
+		}
+	})
+
+	t.Run("detects collisions when adding a prefix", func(t *testing.T) {
+		_, _, err := AddPrefix("A=1\nAPP_A=2", "APP_")
+		if err == nil || !strings.Contains(err.Error(), "collides") {
+			t.Errorf("expected collision error, got %v", err)
+		}
+	})
+
+	t.Run("strips a prefix", func(t *testing.T) {
+		out, _, err := StripPrefix("APP_A=1\nB=2", "APP_")
+		if err != nil {
+			t.Fatalf("strip prefix failed: %v", err)
+		}
+		if out != "A=1\nB=2" {
+			t.Errorf("unexpected output %q", out)
+		}
+	})
+
+	t.Run("detects collisions when stripping a prefix", func(t *testing.T) {
+		if _, _, err := StripPrefix("APP_A=1\nA=2", "APP_"); err == nil {
+			t.Error("expected collision error")
+		}
+	})
+
+	t.Run("allows chained renames onto keys that move away", func(t *testing.T) {
+		out, _, err := Apply("A=1\nB=2", func(k string) string {
+			return map[string]string{"A": "B", "B": "C"}[k]
+		})
+		if err != nil {
+			t.Fatalf("apply failed: %v", err)
+		}
+		if out != "B=1\nC=2" {
+			t.Errorf("unexpected output %q", out)
+		}
+	})
+}
+
+func TestSet(t *testing.T) {
+	t.Run("updates an existing key in place", func(t *testing.T) {
+		out, existed := Set("export A=1\nB=2\n", "A", "9")
+		if !existed || out != "export A=9\nB=2\n" {
+			t.Errorf("unexpected result %q (existed=%v)", out, existed)
+		}
+	})
+
+	t.Run("appends a missing key", func(t *testing.T) {
+		out, existed := Set("A=1", "B", "2")
+		if existed || out != "A=1\nB=2\n" {
+			t.Errorf("unexpected result %q (existed=%v)", out, existed)
+		}
+	})
+
+	t.Run("creates content from empty", func(t *testing.T) {
+		out, _ := Set("", "A", "1")
+		if out != "A=1\n" {
+			t.Errorf("unexpected result %q", out)
+		}
+	})
+}
+
+func TestMaskValues(t *testing.T) {
+	t.Run("masks values but keeps keys and comments", func(t *testing.T) {
+		out := MaskValues("# c\nA=secret\nexport B=\"x\"")
+		if out != "# c\nA=***\nexport B=***" {
+			t.Errorf("unexpected output %q", out)
+		}
+	})
+}
+
+func TestKeys(t *testing.T) {
+	t.Run("ignores lines inside multiline values", func(t *testing.T) {
+		keys := Keys("CERT=\"line1\nNOT_A_KEY=x\"\nB=2")
+		if len(keys) != 2 || keys[0] != "CERT" || keys[1] != "B" {
+			t.Errorf("expected [CERT B], got %v", keys)
+		}
+	})
+
+	t.Run("lists keys in order", func(t *testing.T) {
+		keys := Keys("# c\nA=1\n\nexport B=2\nnot a pair")
+		if strings.Join(keys, ",") != "A,B" {
+			t.Errorf("unexpected keys %v", keys)
+		}
+	})
+}
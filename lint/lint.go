@@ -0,0 +1,190 @@
+// Package lint looks for decrypted content that is probably a mistake to
+// save as is: AWS access key IDs and private key blocks pasted in, weak or
+// placeholder passwords, and one value stored under several keys. It is
+// advisory; findings name the line, key, and rule, never the value.
+package lint
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/andreweick/agepad/convert"
+	"github.com/andreweick/agepad/dotenv"
+	"github.com/andreweick/agepad/outline"
+)
+
+// Rules a Finding may report.
+const (
+	RuleAWSKey       = "aws-access-key"  // an AWS access key ID (AKIA..., ASIA...)
+	RulePrivateKey   = "private-key"     // a PEM private key block or age identity
+	RuleWeakPassword = "weak-password"   // a password-like key with a short or common value
+	RulePlaceholder  = "placeholder"     // a value that still says "changeme"
+	RuleDuplicate    = "duplicate-value" // the value of an earlier key, repeated
+)
+
+// MinPasswordLength is the shortest value a password-like key may hold
+// without a weak-password finding.
+const MinPasswordLength = 12
+
+// MinDuplicateLength is the shortest value compared across keys; shorter
+// ones (ports, flags, regions) repeat legitimately.
+const MinDuplicateLength = 8
+
+// Finding is one suspicious line.
+type Finding struct {
+	Line   int    // 1-based
+	Key    string // the key on Line, when known
+	Rule   string
+	Detail string
+}
+
+func (f Finding) String() string {
+	if f.Key == "" {
+		return fmt.Sprintf("line %d: %s: %s", f.Line, f.Rule, f.Detail)
+	}
+	return fmt.Sprintf("line %d: %s: %s: %s", f.Line, f.Key, f.Rule, f.Detail)
+}
+
+var (
+	awsKeyRE     = regexp.MustCompile(`\b(AKIA|ASIA|ABIA|ACCA)[A-Z0-9]{16}\b`)
+	privateKeyRE = regexp.MustCompile(`-----BEGIN ([A-Z0-9]+ )*PRIVATE KEY( BLOCK)?-----|AGE-SECRET-KEY-1[0-9A-Z]{20,}`)
+	// passwordKeyRE marks a key as holding a password by its name.
+	passwordKeyRE = regexp.MustCompile(`(?i)(password|passwd|passphrase|(^|[._-])pwd($|[._-]))`)
+	// placeholders are compared with case and punctuation removed.
+	placeholders = []string{"changeme", "replaceme", "changethis"}
+)
+
+// commonPasswords are among the most used passwords in published breach
+// corpora, compared case-insensitively.
+var commonPasswords = []string{
+	"password", "password1", "password123", "passw0rd", "123456", "12345678",
+	"123456789", "1234567890", "qwerty", "qwerty123", "abc123", "letmein",
+	"welcome", "welcome1", "admin", "admin123", "root", "toor", "secret",
+	"iloveyou", "monkey", "dragon", "master", "sunshine", "trustno1", "test",
+	"test123", "default", "guest", "changeit",
+}
+
+// Check lints content of the given format (env, json, yaml, toml; anything
+// else is read as env). Pasted credentials are found on any line; the
+// rules about values need content that parses. Findings are in line order.
+func Check(content, format string) []Finding {
+	var out []Finding
+	for i, l := range strings.Split(content, "\n") {
+		if awsKeyRE.MatchString(l) {
+			out = append(out, Finding{Line: i + 1, Rule: RuleAWSKey, Detail: "AWS access key ID; prefer a role or short-lived credentials"})
+		}
+		if m := privateKeyRE.FindString(l); m != "" {
+			detail := "private key block"
+			if strings.HasPrefix(m, "AGE-") {
+				detail = "age identity"
+			}
+			out = append(out, Finding{Line: i + 1, Rule: RulePrivateKey, Detail: detail})
+		}
+	}
+	entries, err := values(content, format)
+	if err == nil {
+		out = append(out, valueFindings(entries)...)
+	}
+	// Name the key of the line rules where a value starts on that line.
+	keys := map[int]string{}
+	for _, e := range entries {
+		keys[e.line] = e.key
+	}
+	for i := range out {
+		if out[i].Key == "" {
+			out[i].Key = keys[out[i].Line]
+		}
+	}
+	slices.SortStableFunc(out, func(a, b Finding) int { return a.Line - b.Line })
+	return out
+}
+
+type entry struct {
+	key, value string
+	line       int
+}
+
+// values returns content's values with their keys (env names, dotted paths
+// otherwise) and lines, in line order.
+func values(content, format string) ([]entry, error) {
+	var out []entry
+	switch format {
+	case "json", "yaml", "toml":
+		data, err := convert.Decode(content, convert.Format(format))
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range convert.Flatten(data, convert.Options{Separator: "."}) {
+			line, err := outline.Find(content, format, k)
+			if err != nil {
+				continue
+			}
+			out = append(out, entry{key: k, value: v, line: line})
+		}
+	default:
+		es, err := dotenv.Parse(content)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range es {
+			out = append(out, entry{key: e.Key, value: e.Value, line: e.Line})
+		}
+	}
+	slices.SortStableFunc(out, func(a, b entry) int {
+		if a.line != b.line {
+			return a.line - b.line
+		}
+		return strings.Compare(a.key, b.key)
+	})
+	return out, nil
+}
+
+func valueFindings(entries []entry) []Finding {
+	var out []Finding
+	first := map[string]entry{}
+	for _, e := range entries {
+		if e.value == "" {
+			continue
+		}
+		norm := normalize(e.value)
+		if slices.ContainsFunc(placeholders, func(p string) bool { return strings.Contains(norm, p) }) {
+			out = append(out, Finding{Line: e.line, Key: e.key, Rule: RulePlaceholder, Detail: "value is still a placeholder"})
+			continue
+		}
+		if passwordKeyRE.MatchString(e.key) {
+			switch {
+			case slices.Contains(commonPasswords, strings.ToLower(e.value)):
+				out = append(out, Finding{Line: e.line, Key: e.key, Rule: RuleWeakPassword, Detail: "a commonly used password"})
+			case len([]rune(e.value)) < MinPasswordLength:
+				out = append(out, Finding{Line: e.line, Key: e.key, Rule: RuleWeakPassword,
+					Detail: fmt.Sprintf("%d characters, fewer than %d", len([]rune(e.value)), MinPasswordLength)})
+			}
+		}
+		if len(e.value) < MinDuplicateLength {
+			continue
+		}
+		if f, ok := first[e.value]; ok {
+			out = append(out, Finding{Line: e.line, Key: e.key, Rule: RuleDuplicate,
+				Detail: fmt.Sprintf("same value as %s (line %d)", f.key, f.line)})
+			continue
+		}
+		first[e.value] = e
+	}
+	return out
+}
+
+// normalize lower-cases s and drops everything but letters and digits, so
+// "CHANGE_ME" and "change-me" read as "changeme".
+func normalize(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r + 'a' - 'A'
+		}
+		return -1
+	}, s)
+}
@@ -0,0 +1,297 @@
+// Package lsp serves agepad's save pipeline to IDEs over stdio, using the
+// Language Server Protocol's framing and lifecycle (initialize, shutdown,
+// exit) with three agepad methods:
+//
+//	agepad/open      {"path"} -> {"text", "format"}: decrypt a file
+//	agepad/validate  {"path", "text"} -> {"diagnostics"}: check a buffer
+//	agepad/save      {"path", "text"} -> {"bytes", "recipients", "warnings"}:
+//	                 validate, encrypt, preflight, and write atomically
+//
+// "uri" (file://) is accepted wherever "path" is. An extension keeps the
+// plaintext in the IDE's memory and calls agepad/save instead of writing
+// the file, so the same validation, recipient preflight, and stanza policy
+// apply as in the agepad editor.
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"filippo.io/age"
+	agepkg "github.com/andreweick/agepad/age"
+	"github.com/andreweick/agepad/control"
+	"github.com/andreweick/agepad/dotenv"
+	"github.com/andreweick/agepad/model"
+	"github.com/andreweick/agepad/validator"
+)
+
+// Server answers one IDE over stdio.
+type Server struct {
+	Identities     []age.Identity
+	RecipientsFile string // loaded on every save, so roster changes apply at once
+	Armor          bool
+	Validation     map[string]model.Severity
+	TypeRules      []model.TypeRule
+	Stanzas        model.StanzaPolicy
+	Version        string                                 // reported in serverInfo
+	Write          func(path string, cipher []byte) error // defaults to agepkg.AtomicWrite
+
+	shutdown bool
+}
+
+// DocParams names a file and, for validate and save, its buffer.
+type DocParams struct {
+	Path string `json:"path,omitempty"`
+	URI  string `json:"uri,omitempty"`
+	Text string `json:"text,omitempty"`
+}
+
+// Diagnostic is one validation finding. Line is 1-based, or 0 when the
+// finding is not tied to a line.
+type Diagnostic struct {
+	Severity string `json:"severity"` // "error" or "warning"
+	Message  string `json:"message"`
+	Line     int    `json:"line,omitempty"`
+}
+
+// SaveResult reports a written file; it carries no plaintext.
+type SaveResult struct {
+	Bytes      int      `json:"bytes"`
+	Recipients int      `json:"recipients"`
+	Warnings   []string `json:"warnings,omitempty"`
+}
+
+// Serve reads framed requests from r and writes responses to w until the
+// client sends exit or closes r. Exit without a prior shutdown is an error,
+// as in LSP.
+func (s *Server) Serve(r io.Reader, w io.Writer) error {
+	br := bufio.NewReader(r)
+	for {
+		body, err := readMessage(br)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("lsp: %w", err)
+		}
+		var req control.Request
+		if err := json.Unmarshal(body, &req); err != nil {
+			if err := writeMessage(w, control.Response{JSONRPC: "2.0", ID: json.RawMessage("null"),
+				Error: &control.Error{Code: control.CodeParse, Message: err.Error()}}); err != nil {
+				return err
+			}
+			continue
+		}
+		if req.Method == "exit" {
+			if !s.shutdown {
+				return errors.New("lsp: exit before shutdown")
+			}
+			return nil
+		}
+		res, err := s.handle(req.Method, req.Params)
+		if req.ID == nil {
+			continue // notifications get no answer
+		}
+		resp := control.Response{JSONRPC: "2.0", ID: req.ID, Result: res}
+		if err != nil {
+			var e *control.Error
+			if !errors.As(err, &e) {
+				e = &control.Error{Code: control.CodeFailed, Message: err.Error()}
+			}
+			resp.Result, resp.Error = nil, e
+		} else if res == nil {
+			resp.Result = json.RawMessage("null")
+		}
+		if err := writeMessage(w, resp); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *Server) handle(method string, params json.RawMessage) (any, error) {
+	switch method {
+	case "initialize":
+		return map[string]any{
+			"capabilities": map[string]any{
+				"experimental": map[string]any{"agepad": []string{"open", "validate", "save"}},
+			},
+			"serverInfo": map[string]string{"name": "agepad", "version": s.Version},
+		}, nil
+	case "initialized", "$/cancelRequest", "$/setTrace":
+		return nil, nil
+	case "shutdown":
+		s.shutdown = true
+		return nil, nil
+	}
+	if s.shutdown {
+		return nil, control.Errorf(control.CodeFailed, "server is shutting down")
+	}
+	var p DocParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, control.Errorf(control.CodeInvalidParams, "%s: %v", method, err)
+	}
+	path, err := p.path()
+	if err != nil {
+		return nil, err
+	}
+	switch method {
+	case "agepad/open":
+		return s.open(path)
+	case "agepad/validate":
+		return map[string][]Diagnostic{"diagnostics": s.validate(path, p.Text)}, nil
+	case "agepad/save":
+		return s.save(path, p.Text)
+	}
+	return nil, control.Errorf(control.CodeMethodNotFound, "unknown method %q", method)
+}
+
+// path returns the file named by Path or a file:// URI.
+func (p DocParams) path() (string, error) {
+	if p.Path != "" {
+		return p.Path, nil
+	}
+	u, err := url.Parse(p.URI)
+	if err != nil || u.Scheme != "file" || u.Path == "" {
+		return "", control.Errorf(control.CodeInvalidParams, "need a path or a file:// uri")
+	}
+	return u.Path, nil
+}
+
+// open decrypts path, refusing headers the stanza policy forbids.
+func (s *Server) open(path string) (any, error) {
+	cipher, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	h, err := agepkg.InspectHeaderBytes(cipher)
+	if err == nil {
+		err = agepkg.CheckStanzas(h, s.Stanzas)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	plain, err := agepkg.Decrypt(cipher, s.Identities)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]string{"text": plain, "format": validator.Format(path, plain, s.TypeRules)}, nil
+}
+
+// validate checks text as a save of path would.
+func (s *Server) validate(path, text string) []Diagnostic {
+	sev, err := validator.Check(path, text, s.Validation, s.TypeRules)
+	if err == nil {
+		return []Diagnostic{}
+	}
+	d := Diagnostic{Severity: "error", Message: err.Error(), Line: errorLine(err)}
+	if sev == model.SeverityWarn {
+		d.Severity = "warning"
+	}
+	return []Diagnostic{d}
+}
+
+// save runs the editor's save pipeline on text and writes path.
+func (s *Server) save(path, text string) (any, error) {
+	var res SaveResult
+	for _, d := range s.validate(path, text) {
+		if d.Severity == "error" {
+			return nil, fmt.Errorf("validation failed; not saved: %s", d.Message)
+		}
+		res.Warnings = append(res.Warnings, d.Message)
+	}
+	recips, err := agepkg.LoadRecipients(s.RecipientsFile)
+	if err != nil {
+		return nil, err
+	}
+	cipher, err := agepkg.EncryptToMemory([]byte(text), recips, s.Armor)
+	if err != nil {
+		return nil, fmt.Errorf("preflight encrypt: %w", err)
+	}
+	h, err := agepkg.InspectHeaderBytes(cipher)
+	if err == nil {
+		err = agepkg.CheckStanzas(h, s.Stanzas)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("recipients: %w", err)
+	}
+	if _, err := agepkg.Decrypt(cipher, s.Identities); err != nil {
+		return nil, fmt.Errorf("preflight decrypt failed with current identities; "+
+			"you may lock yourself out: %w", err)
+	}
+	write := s.Write
+	if write == nil {
+		write = agepkg.AtomicWrite
+	}
+	if err := write(path, cipher); err != nil {
+		return nil, err
+	}
+	res.Bytes, res.Recipients = len(text), len(recips)
+	return res, nil
+}
+
+// errorLine returns the line a .env syntax error points at, or 0.
+func errorLine(err error) int {
+	var se *dotenv.SyntaxError
+	if errors.As(err, &se) {
+		return se.Line
+	}
+	return 0
+}
+
+// maxMessage bounds the body a client may announce, so a bad header
+// cannot make the server allocate without limit.
+const maxMessage = 64 << 20
+
+// readMessage reads one Content-Length framed message.
+func readMessage(r *bufio.Reader) ([]byte, error) {
+	length := -1
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			if line == "" && length < 0 {
+				return nil, io.EOF
+			}
+			return nil, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			break
+		}
+		name, value, ok := strings.Cut(line, ":")
+		if ok && strings.EqualFold(strings.TrimSpace(name), "Content-Length") {
+			if length, err = strconv.Atoi(strings.TrimSpace(value)); err != nil || length < 0 {
+				return nil, fmt.Errorf("bad Content-Length %q", value)
+			}
+			if length > maxMessage {
+				return nil, fmt.Errorf("message of %d bytes exceeds the %d byte limit", length, maxMessage)
+			}
+		}
+	}
+	if length < 0 {
+		return nil, errors.New("missing Content-Length header")
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+// writeMessage frames v as one message.
+func writeMessage(w io.Writer, v any) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(w, "Content-Length: %d\r\n\r\n%s", len(body), body); err != nil {
+		return fmt.Errorf("lsp: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+package lsp
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"filippo.io/age"
+	agepkg "github.com/andreweick/agepad/age"
+)
+
+// session frames each request, serves them, and returns the decoded
+// responses in order.
+func session(t *testing.T, s *Server, reqs ...string) ([]map[string]any, error) {
+	t.Helper()
+	var in, out bytes.Buffer
+	for _, r := range reqs {
+		fmt.Fprintf(&in, "Content-Length: %d\r\n\r\n%s", len(r), r)
+	}
+	err := s.Serve(&in, &out)
+	var resps []map[string]any
+	br := bufio.NewReader(&out)
+	for {
+		body, rerr := readMessage(br)
+		if rerr != nil {
+			break
+		}
+		var m map[string]any
+		if err := json.Unmarshal(body, &m); err != nil {
+			t.Fatalf("bad response %s: %v", body, err)
+		}
+		resps = append(resps, m)
+	}
+	return resps, err
+}
+
+func TestServer(t *testing.T) {
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatalf("failed to generate identity: %v", err)
+	}
+	dir := t.TempDir()
+	recipients := filepath.Join(dir, ".age-recipients")
+	if err := os.WriteFile(recipients, []byte(identity.Recipient().String()+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "app.env.age")
+	recips := []age.Recipient{identity.Recipient()}
+	if err := agepkg.AtomicEncryptWrite(path, []byte("A=1\n"), recips, true); err != nil {
+		t.Fatal(err)
+	}
+	newServer := func() *Server {
+		return &Server{Identities: []age.Identity{identity}, RecipientsFile: recipients, Armor: true}
+	}
+
+	t.Run("opens, validates, and saves through the pipeline", func(t *testing.T) {
+		resps, err := session(t, newServer(),
+			`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{}}`,
+			`{"jsonrpc":"2.0","method":"initialized","params":{}}`,
+			fmt.Sprintf(`{"jsonrpc":"2.0","id":2,"method":"agepad/open","params":{"uri":"file://%s"}}`, path),
+			fmt.Sprintf(`{"jsonrpc":"2.0","id":3,"method":"agepad/validate","params":{"path":%q,"text":"A=1\nnot an assignment\n"}}`, path),
+			fmt.Sprintf(`{"jsonrpc":"2.0","id":4,"method":"agepad/save","params":{"path":%q,"text":"A=1\nB=2\n"}}`, path),
+			`{"jsonrpc":"2.0","id":5,"method":"shutdown"}`,
+			`{"jsonrpc":"2.0","method":"exit"}`,
+		)
+		if err != nil {
+			t.Fatalf("Serve: %v", err)
+		}
+		if len(resps) != 5 {
+			t.Fatalf("expected 5 responses (none for notifications), got %v", resps)
+		}
+		if open := resps[1]["result"].(map[string]any); open["text"] != "A=1\n" || open["format"] != "env" {
+			t.Errorf("unexpected open result %v", open)
+		}
+		diags := resps[2]["result"].(map[string]any)["diagnostics"].([]any)
+		if len(diags) != 1 || diags[0].(map[string]any)["line"] != float64(2) {
+			t.Errorf("expected a diagnostic on line 2, got %v", diags)
+		}
+		if save := resps[3]["result"].(map[string]any); save["recipients"] != float64(1) {
+			t.Errorf("unexpected save result %v", resps[3])
+		}
+		plain, err := agepkg.DecryptToMemory(path, []age.Identity{identity})
+		if err != nil || plain != "A=1\nB=2\n" {
+			t.Errorf("expected the saved buffer on disk, got %q, %v", plain, err)
+		}
+	})
+
+	t.Run("refuses invalid content", func(t *testing.T) {
+		before, _ := os.ReadFile(path)
+		resps, _ := session(t, newServer(),
+			fmt.Sprintf(`{"jsonrpc":"2.0","id":1,"method":"agepad/save","params":{"path":%q,"text":"=oops\n"}}`, path))
+		e, ok := resps[0]["error"].(map[string]any)
+		if !ok || !strings.Contains(e["message"].(string), "not saved") {
+			t.Errorf("expected a validation error, got %v", resps[0])
+		}
+		if after, _ := os.ReadFile(path); !bytes.Equal(before, after) {
+			t.Error("expected the file to be left alone")
+		}
+	})
+
+	t.Run("refuses a save that would lock the user out", func(t *testing.T) {
+		other, _ := age.GenerateX25519Identity()
+		s := newServer()
+		s.Identities = []age.Identity{other}
+		resps, _ := session(t, s,
+			fmt.Sprintf(`{"jsonrpc":"2.0","id":1,"method":"agepad/save","params":{"path":%q,"text":"A=3\n"}}`, path))
+		if e, ok := resps[0]["error"].(map[string]any); !ok || !strings.Contains(e["message"].(string), "preflight decrypt") {
+			t.Errorf("expected a preflight error, got %v", resps[0])
+		}
+	})
+
+	t.Run("requires shutdown before exit", func(t *testing.T) {
+		if _, err := session(t, newServer(), `{"jsonrpc":"2.0","method":"exit"}`); err == nil {
+			t.Error("expected exit without shutdown to fail")
+		}
+	})
+}
+
+func TestReadMessage(t *testing.T) {
+	for _, header := range []string{"-1", "-5", "99999999999", fmt.Sprint(maxMessage + 1)} {
+		r := bufio.NewReader(strings.NewReader("Content-Length: " + header + "\r\n\r\n{}"))
+		if _, err := readMessage(r); err == nil {
+			t.Errorf("Content-Length %s: expected an error", header)
+		}
+	}
+	r := bufio.NewReader(strings.NewReader("Content-Length: 2\r\n\r\n{}"))
+	if body, err := readMessage(r); err != nil || string(body) != "{}" {
+		t.Errorf("expected {}, got %q, %v", body, err)
+	}
+}
@@ -1,25 +1,362 @@
 package model
 
+import "time"
+
 // Config holds the configuration for the TUI editor mode.
 type Config struct {
-	FilePath       string
+	FilePath              string
+	RecipientsFile        string
+	IdentitiesPath        string
+	Armor                 bool
+	ArmorAuto             bool // keep each file's existing armor on save; Armor is for new files
+	ViewOnly              bool
+	Redact                bool // with ViewOnly, the buffer holds a copy with every value replaced (package redact)
+	Paranoid              bool
+	Harden                bool
+	OSC52                 bool          // copy via the terminal (OSC 52) instead of the system clipboard
+	ClipboardClear        time.Duration // clear copied values after this long; 0 disables
+	WipeOnExit            bool
+	ReauthAfter           time.Duration       // re-load identities before saving after this much inactivity; 0 disables
+	Summary               bool                // print a plaintext-free session summary to stderr on exit
+	Validation            map[string]Severity // per-format ("env", "json", "yaml", "toml") validation severity; missing means error
+	TypeRules             []TypeRule          // filename patterns mapped to formats, tried before extensions
+	OpenAt                string              // key (env) or dotted path (json/yaml/toml) to place the cursor on
+	Stanzas               StanzaPolicy        // header stanza types refused on open and save
+	Scratch               bool                // no backing file until the first save; discarding zeroizes the buffer
+	Passphrase            bool                // encrypt to a passphrase (age scrypt) instead of the recipients file
+	DiffAlgorithm         string              // "myers" (default), "patience", or "histogram"
+	ControlSocket         string              // serve the control API (package control) here; "" disables
+	Editor                string              // "tui" (default) or "external": $VISUAL/$EDITOR on a memory-backed file
+	Highlight             bool                // color the buffer by format (package highlight)
+	Theme                 string              // highlight theme name (highlight.Themes); "" is the default
+	KeyBindings           map[string]string   // editor action -> comma-separated keys (tui.KeyMap.Rebind)
+	ViewWindow            string              // hours files may be opened in without an extra confirmation (package viewwindow)
+	SkipInvalidRecipients bool                // leave out recipients file lines that cannot be used, with a warning, instead of failing
+	Values                bool                // the JSON/YAML file has only its values encrypted (package valuecrypt); saves keep that form
+	Lint                  bool                // list suspicious content (package lint) before a save's preflight; Ctrl+S again saves anyway
+	Schema                string              // JSON Schema for JSON/YAML saves; "" uses validator.SchemaFile next to the file, if any
+}
+
+// StanzaPolicy restricts the recipient stanza types a file's header may
+// carry. The zero value allows every type.
+type StanzaPolicy struct {
+	RequireX25519 bool // every stanza must be X25519
+	ForbidScrypt  bool // no passphrase (scrypt) stanzas, which bypass the recipients roster
+}
+
+// TypeRule maps a filename glob (e.g. "*.secrets") to a content format.
+type TypeRule struct {
+	Pattern string
+	Format  string
+}
+
+// Severity controls how a validation failure affects saving.
+type Severity string
+
+const (
+	SeverityError Severity = "error" // block the save
+	SeverityWarn  Severity = "warn"  // report, but save anyway
+	SeverityOff   Severity = "off"   // do not validate
+)
+
+// IndexConfig holds the configuration for the index subcommand.
+type IndexConfig struct {
+	Root           string
+	Find           string // key name to look up; empty only refreshes the index
+	Rebuild        bool   // discard the cached index and re-index every file
 	RecipientsFile string
 	IdentitiesPath string
 	Armor          bool
-	ViewOnly       bool
 }
 
 // RotateConfig holds the configuration for the rotate subcommand.
 type RotateConfig struct {
-	Root             string
+	Root               string
 	FromRecipientsFile string
-	ToRecipientsFile string
-	IdentitiesPath   string
+	ToRecipientsFile   string
+	AddRecipients      []string // with RemoveRecipients, the new set as a change to FromRecipientsFile
+	RemoveRecipients   []string
+	IdentitiesPath     string
+	Prune              bool   // remove recipients in From but not To, and verify each rewritten header
+	PlanPath           string // write the plan here instead of rotating
+	ApplyPath          string // execute a plan written by --plan
+	Workers            int    // files rotated concurrently
+	IOLimit            int64  // bytes per second read and written across workers; 0 is unlimited
+	Nice               int    // scheduling priority to lower the process to; 0 leaves it
+	Resume             bool   // skip files an interrupted run already rotated
+	CheckpointPath     string // progress file; defaults to <root>/.agepad/rotate.checkpoint
+	Report             string // "json" for a per-file result report; "" prints text only
+	ReportPath         string // where the report goes; "" is stdout
+	Backup             bool   // keep each original ciphertext before replacing it
+	BackupDir          string // keep backups here (by path under Root) instead of as <name>.age.bak
+	Restore            bool   // put the backups back instead of rotating
+	Verify             bool   // re-read and decrypt every rotated file at the end
+	ReplaceFrom        bool   // afterwards, copy the --to file over the --from file, keeping a timestamped backup
+	Commit             bool   // afterwards, git-commit the rotated files and the recipients file together
+	Confirm            Confirm
+}
+
+// Confirm holds the confirmation semantics shared by non-TUI subcommands.
+type Confirm struct {
+	Yes    bool // --yes / --no-confirm: never prompt
+	Prompt bool // --confirm-prompt: ask on an interactive terminal, refuse otherwise
 }
 
 // RunConfig holds the configuration for the run subcommand.
 type RunConfig struct {
-	FilePath       string
+	Files          []string // layered in order: later files override earlier keys
 	IdentitiesPath string
 	Command        []string
+	NoDaemon       bool // decrypt in-process even if a daemon is running
+	Stanzas        StanzaPolicy
+	Supervise      bool              // run the command as a child and exit as it did, instead of replacing agepad
+	Report         bool              // after the child exits, print its wall time, CPU, and peak memory (implies Supervise)
+	Only           []string          // export only decrypted keys matching these names or globs
+	Exclude        []string          // leave out decrypted keys matching these names or globs
+	MinimalEnv     bool              // inherit only a few basic variables from agepad's environment
+	Prefix         string            // added to exported keys that lack it
+	Renames        map[string]string // decrypted key -> exported name, instead of Prefix
+	Watch          bool              // restart the command when a file changes (implies Supervise)
+	WatchInterval  time.Duration     // how often Watch looks at the files
+}
+
+// DaemonConfig holds the configuration for the daemon subcommand.
+type DaemonConfig struct {
+	IdentitiesPath string
+	Socket         string
+	TTL            time.Duration // how long a decrypted file stays cached
+	PolicyPath     string        // allowlist of binaries per file/key; empty allows any process of the user
+	AuditLog       string
+}
+
+// ConvertConfig holds the configuration for the convert subcommand.
+type ConvertConfig struct {
+	FilePath       string
+	From           string
+	To             string
+	OutPath        string
+	Separator      string
+	Nest           bool
+	Upper          bool
+	RecipientsFile string
+	IdentitiesPath string
+	Armor          bool
+	Confirm        Confirm
+}
+
+// PrefixConfig holds the configuration for the prefix subcommand.
+type PrefixConfig struct {
+	FilePath       string
+	Add            string
+	Strip          string
+	DryRun         bool
+	RecipientsFile string
+	IdentitiesPath string
+	Armor          bool
+	Confirm        Confirm
+}
+
+// RenameKeyConfig holds the configuration for the rename-key subcommand.
+type RenameKeyConfig struct {
+	OldKey         string
+	NewKey         string
+	Root           string
+	DryRun         bool
+	RecipientsFile string
+	IdentitiesPath string
+	Armor          bool
+	Confirm        Confirm
+}
+
+// MergeConfig holds the configuration for subcommands that merge values
+// into an encrypted file (merge-stdin, import-terraform).
+type MergeConfig struct {
+	FilePath       string
+	Format         string
+	Separator      string
+	Upper          bool
+	RecipientsFile string
+	IdentitiesPath string
+	Armor          bool
+	Confirm        Confirm
+}
+
+// EncryptConfig holds the configuration for the encrypt subcommand.
+type EncryptConfig struct {
+	InPath         string // plaintext file; "" or "-" reads stdin
+	OutPath        string
+	RecipientsFile string
+	Users          []string // resolved in Directory instead of RecipientsFile
+	Groups         []string
+	Directory      string
+	Armor          bool
+	Values         bool // keep JSON/YAML keys in plaintext and encrypt each value (package valuecrypt)
+	Force          bool // replace an existing OutPath
+	Validation     map[string]Severity
+	TypeRules      []TypeRule
+	Schema         string // JSON Schema for JSON/YAML plaintext; "" uses validator.SchemaFile next to OutPath, if any
+	Stanzas        StanzaPolicy
+	Confirm        Confirm
+}
+
+// ResolveConfig holds the configuration for the resolve subcommand.
+type ResolveConfig struct {
+	Directory string
+	Users     []string
+	Groups    []string
+	OutPath   string // "" prints the lines
+	Force     bool   // replace an existing OutPath
+}
+
+// CIReportConfig holds the configuration for the ci-report subcommand.
+type CIReportConfig struct {
+	Base           string
+	Head           string
+	RecipientsFile string
+	IdentitiesPath string
+	OutPath        string
+	Stanzas        StanzaPolicy // head headers breaking this are listed and fail the report
+}
+
+// ShareConfig holds the configuration for the share subcommand.
+type ShareConfig struct {
+	FilePath       string
+	To             []string
+	Expires        time.Duration
+	OutPath        string
+	IdentitiesPath string
+	Armor          bool
+	AuditLog       string
+}
+
+// RedactTreeConfig holds the configuration for the redact-tree subcommand.
+type RedactTreeConfig struct {
+	Root           string
+	OutDir         string
+	IdentitiesPath string
+	TypeRules      []TypeRule
+	Check          bool // report an out-of-date catalog instead of writing it
+}
+
+// DeriveConfig holds the configuration for the derive subcommand.
+type DeriveConfig struct {
+	IdentitiesPath string
+	TypeRules      []TypeRule
+	Check          bool // report out-of-date derived files instead of writing them
+}
+
+// RenderConfig holds the configuration for the render subcommand.
+type RenderConfig struct {
+	TemplatePath   string
+	SecretsPath    string
+	OutFD          int // 1 is stdout
+	IdentitiesPath string
+	TypeRules      []TypeRule
+	Stanzas        StanzaPolicy
+}
+
+// GitSetupConfig holds the configuration for the git-setup subcommand.
+type GitSetupConfig struct {
+	Pattern string // .gitattributes pattern for the diff driver, e.g. "*.age"
+	Filter  string // .gitattributes pattern for the clean/smudge filter; "" sets none up
+	Command string // how git runs agepad
+}
+
+// ScanConfig holds the configuration for the scan subcommand.
+type ScanConfig struct {
+	Staged  bool     // scan the index instead of Paths
+	Paths   []string // files and directories to scan
+	Exclude []string // globs matched against each path and its base name
+}
+
+// AckConfig holds the configuration for the ack subcommand.
+type AckConfig struct {
+	FilePath       string
+	RulesFile      string // paths in acks are relative to its directory
+	RecipientsFile string
+	IdentitiesPath string
+	SignKeyPath    string // SSH private key listed in the recipients file
+	AuditLog       string
+}
+
+// VerifyConfig holds the configuration for the verify subcommand.
+type VerifyConfig struct {
+	Root           string
+	Strict         bool // also require the acks the review rules ask for
+	RulesFile      string
+	RecipientsFile string
+	AuditLog       string
+	Stanzas        StanzaPolicy
+}
+
+// DupesConfig holds the configuration for the dupes subcommand.
+type DupesConfig struct {
+	Root           string
+	IdentitiesPath string
+	TypeRules      []TypeRule
+	MinFiles       int // report values found in at least this many files
+	MinLength      int // ignore values shorter than this
+}
+
+// RevokeValueConfig holds the configuration for the revoke-value subcommand.
+type RevokeValueConfig struct {
+	Root           string
+	Replace        bool // replace the value (else only report where it is)
+	RecipientsFile string
+	IdentitiesPath string
+	TypeRules      []TypeRule
+	Validation     map[string]Severity
+	AuditLog       string
+	Confirm        Confirm
+}
+
+// SedConfig holds the configuration for the sed subcommand.
+type SedConfig struct {
+	Root           string
+	Match          string // literal text, or a regular expression with Regexp
+	Replace        string
+	Regexp         bool
+	Preview        bool // only print the redacted changes
+	RecipientsFile string
+	IdentitiesPath string
+	TypeRules      []TypeRule
+	Validation     map[string]Severity
+	AuditLog       string
+	Confirm        Confirm
+}
+
+// ValidateConfig holds the configuration for the validate subcommand.
+type ValidateConfig struct {
+	Paths          []string // plaintext files, or .age files decrypted in memory
+	IdentitiesPath string
+	Stanzas        StanzaPolicy
+	Validation     map[string]Severity
+	TypeRules      []TypeRule
+	Schema         string // JSON Schema for every JSON/YAML path; "" uses validator.SchemaFile next to each, if any
+}
+
+// WebConfig holds the configuration for the web subcommand.
+type WebConfig struct {
+	FilePath       string
+	Listen         string        // loopback address; port 0 picks one
+	Expires        time.Duration // how long the page is served
+	IdentitiesPath string
+	AuditLog       string
+}
+
+// EnrollConfig holds the configuration for the enroll subcommand.
+type EnrollConfig struct {
+	OutPath        string   // this machine's identities file, created if missing
+	RecipientsFile string   // the roster its public key is added to
+	Label          string   // the key's # comment in the roster
+	Check          []string // encrypted files it must decrypt once enrolled
+}
+
+// StatsConfig holds the configuration for the stats subcommand.
+type StatsConfig struct {
+	Ledger  string // the usage ledger (package stats)
+	Since   string // YYYY-MM-DD; "" counts everything
+	JSON    bool
+	Clear   bool // delete the ledger instead of printing it
+	Confirm Confirm
 }
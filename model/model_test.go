@@ -37,6 +37,7 @@ func TestRotateConfig(t *testing.T) {
 			FromRecipientsFile: ".age-recipients",
 			ToRecipientsFile:   ".age-recipients.new",
 			IdentitiesPath:     "~/.config/age/key.txt",
+			Confirm:            Confirm{Prompt: true},
 		}
 
 		if cfg.Root != "." {
@@ -51,19 +52,22 @@ func TestRotateConfig(t *testing.T) {
 		if cfg.IdentitiesPath != "~/.config/age/key.txt" {
 			t.Errorf("expected IdentitiesPath to be '~/.config/age/key.txt', got %s", cfg.IdentitiesPath)
 		}
+		if cfg.Confirm.Yes || !cfg.Confirm.Prompt {
+			t.Errorf("expected Confirm to prompt, got %+v", cfg.Confirm)
+		}
 	})
 }
 
 func TestRunConfig(t *testing.T) {
 	t.Run("creates valid run config with all fields", func(t *testing.T) {
 		cfg := RunConfig{
-			FilePath:       "/path/to/secrets.env.age",
+			Files:          []string{"/path/to/base.env.age", "/path/to/prod.env.age"},
 			IdentitiesPath: "~/.config/age/key.txt",
 			Command:        []string{"myserver", "--port", "8080"},
 		}
 
-		if cfg.FilePath != "/path/to/secrets.env.age" {
-			t.Errorf("expected FilePath to be '/path/to/secrets.env.age', got %s", cfg.FilePath)
+		if len(cfg.Files) != 2 || cfg.Files[1] != "/path/to/prod.env.age" {
+			t.Errorf("expected Files to list base then prod, got %v", cfg.Files)
 		}
 		if cfg.IdentitiesPath != "~/.config/age/key.txt" {
 			t.Errorf("expected IdentitiesPath to be '~/.config/age/key.txt', got %s", cfg.IdentitiesPath)
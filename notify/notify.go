@@ -0,0 +1,107 @@
+// Package notify tells the user that a long job (rotate, verify) has
+// finished, since those tend to be left running in another tab: a terminal
+// bell, and optionally a desktop notification. Jobs shorter than a
+// threshold finish silently.
+package notify
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// Modes accepted by ParseMode.
+const (
+	Off     = "off"     // never notify
+	Bell    = "bell"    // ring the terminal bell (the default)
+	Desktop = "desktop" // bell plus a desktop notification
+)
+
+// DefaultAfter is how long a job must run before its end is announced.
+const DefaultAfter = 30 * time.Second
+
+// ParseMode checks a --notify value.
+func ParseMode(s string) (string, error) {
+	switch s {
+	case Off, Bell, Desktop:
+		return s, nil
+	case "":
+		return Bell, nil
+	}
+	return "", fmt.Errorf("unknown notify mode %q (want off, bell, or desktop)", s)
+}
+
+// Job is a running job whose end may be announced.
+type Job struct {
+	mode  string
+	after time.Duration
+	w     io.Writer // the terminal; nil when there is none
+	start time.Time
+}
+
+// Start begins timing a job. w is the terminal the bell and OSC 777
+// sequences go to, or nil when output is not a terminal.
+func Start(mode string, after time.Duration, w io.Writer) *Job {
+	return &Job{mode: mode, after: after, w: w, start: time.Now()}
+}
+
+// Done announces the job's end when it ran for at least the threshold.
+// Notifying is best effort; a notifier that fails is ignored.
+func (j *Job) Done(title, body string) {
+	if j == nil || j.mode == Off || time.Since(j.start) < j.after {
+		return
+	}
+	if j.w != nil {
+		fmt.Fprint(j.w, "\a")
+	}
+	if j.mode != Desktop {
+		return
+	}
+	if name, args, ok := desktopCommand(title, body); ok {
+		if exec.Command(name, args...).Run() == nil {
+			return
+		}
+	}
+	// No local notifier (or an SSH session): ask the terminal, which
+	// forwards OSC 777 to the desktop where it is supported (foot, kitty,
+	// WezTerm, VTE-based terminals).
+	if j.w != nil {
+		fmt.Fprintf(j.w, "\x1b]777;notify;%s;%s\x1b\\", sanitize(title), sanitize(body))
+	}
+}
+
+// desktopCommand returns the local notifier to run, if any. Over SSH the
+// desktop is at the other end, so only the terminal can reach it.
+var desktopCommand = func(title, body string) (string, []string, bool) {
+	if os.Getenv("SSH_CONNECTION") != "" {
+		return "", nil, false
+	}
+	switch runtime.GOOS {
+	case "darwin":
+		script := fmt.Sprintf("display notification %q with title %q", body, title)
+		return "osascript", []string{"-e", script}, true
+	case "windows":
+		return "", nil, false
+	}
+	if os.Getenv("DISPLAY") == "" && os.Getenv("WAYLAND_DISPLAY") == "" {
+		return "", nil, false
+	}
+	if _, err := exec.LookPath("notify-send"); err != nil {
+		return "", nil, false
+	}
+	return "notify-send", []string{"--app-name=agepad", title, body}, true
+}
+
+// sanitize keeps text from ending the OSC sequence or splitting its fields.
+func sanitize(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f || r == ';' {
+			return ' '
+		}
+		return r
+	}, s)
+}
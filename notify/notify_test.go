@@ -0,0 +1,56 @@
+package notify
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestDone(t *testing.T) {
+	noDesktop := func(string, string) (string, []string, bool) { return "", nil, false }
+	orig := desktopCommand
+	desktopCommand = noDesktop
+	defer func() { desktopCommand = orig }()
+
+	finished := func(mode string, ran time.Duration) string {
+		var buf bytes.Buffer
+		j := Start(mode, time.Minute, &buf)
+		j.start = j.start.Add(-ran)
+		j.Done("agepad rotate", "12 success; 0 failed")
+		return buf.String()
+	}
+
+	t.Run("stays quiet for short jobs", func(t *testing.T) {
+		if got := finished(Desktop, time.Second); got != "" {
+			t.Errorf("got %q", got)
+		}
+	})
+
+	t.Run("rings the bell after the threshold", func(t *testing.T) {
+		if got := finished(Bell, 2*time.Minute); got != "\a" {
+			t.Errorf("got %q", got)
+		}
+	})
+
+	t.Run("falls back to OSC 777 without a local notifier", func(t *testing.T) {
+		want := "\a\x1b]777;notify;agepad rotate;12 success  0 failed\x1b\\"
+		if got := finished(Desktop, 2*time.Minute); got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+	})
+
+	t.Run("off never notifies", func(t *testing.T) {
+		if got := finished(Off, time.Hour); got != "" {
+			t.Errorf("got %q", got)
+		}
+	})
+
+	t.Run("parses modes", func(t *testing.T) {
+		if m, err := ParseMode(""); err != nil || m != Bell {
+			t.Errorf("empty mode: got %q, %v", m, err)
+		}
+		if _, err := ParseMode("loud"); err == nil {
+			t.Error("expected an error for an unknown mode")
+		}
+	})
+}
@@ -0,0 +1,211 @@
+// Package outline locates keys inside decrypted content so the editor can
+// jump straight to them: KEY names in .env files and dotted paths
+// (db.password, servers.0.host) in JSON, YAML, and TOML.
+package outline
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/andreweick/agepad/dotenv"
+	"gopkg.in/yaml.v3"
+)
+
+// ErrNotFound reports that the key or path is not defined in the content.
+var ErrNotFound = errors.New("not found")
+
+// Find returns the 1-based line on which key (for env content) or dotted
+// path (for json, yaml, and toml) is defined. Unknown formats are treated as
+// env.
+func Find(content, format, path string) (int, error) {
+	var (
+		line int
+		err  error
+	)
+	switch format {
+	case "json":
+		line, err = findJSON(content, path)
+	case "yaml":
+		line, err = findYAML(content, path)
+	case "toml":
+		line, err = findTOML(content, path)
+	default:
+		line, err = findEnv(content, path)
+	}
+	if err == nil && line == 0 {
+		err = ErrNotFound
+	}
+	if err != nil {
+		return 0, fmt.Errorf("locate %q: %w", path, err)
+	}
+	return line, nil
+}
+
+func findEnv(content, key string) (int, error) {
+	es, err := dotenv.Parse(content)
+	if err != nil {
+		return 0, err
+	}
+	for _, e := range es {
+		if e.Key == key {
+			return e.Line, nil
+		}
+	}
+	return 0, nil
+}
+
+func findYAML(content, path string) (int, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte(content), &doc); err != nil {
+		return 0, err
+	}
+	if len(doc.Content) == 0 {
+		return 0, nil
+	}
+	n, line := doc.Content[0], 0
+	for _, seg := range strings.Split(path, ".") {
+		switch n.Kind {
+		case yaml.MappingNode:
+			var next *yaml.Node
+			for i := 0; i+1 < len(n.Content); i += 2 {
+				if n.Content[i].Value == seg {
+					next, line = n.Content[i+1], n.Content[i].Line
+				}
+			}
+			if next == nil {
+				return 0, nil
+			}
+			n = next
+		case yaml.SequenceNode:
+			i, err := strconv.Atoi(seg)
+			if err != nil || i < 0 || i >= len(n.Content) {
+				return 0, nil
+			}
+			n, line = n.Content[i], n.Content[i].Line
+		default:
+			return 0, nil
+		}
+	}
+	return line, nil
+}
+
+// jsonFrame tracks one open object or array while scanning JSON tokens.
+type jsonFrame struct {
+	object    bool
+	expectKey bool
+	key       string
+	index     int
+}
+
+func findJSON(content, path string) (int, error) {
+	dec := json.NewDecoder(strings.NewReader(content))
+	var stack []jsonFrame
+
+	at := func(last string) bool {
+		segs := make([]string, 0, len(stack))
+		for _, f := range stack[:len(stack)-1] {
+			segs = append(segs, f.segment())
+		}
+		return strings.Join(append(segs, last), ".") == path
+	}
+	lineAt := func() int {
+		return strings.Count(content[:dec.InputOffset()], "\n") + 1
+	}
+	// valueDone advances the innermost container past a finished value.
+	valueDone := func() {
+		if len(stack) == 0 {
+			return
+		}
+		top := &stack[len(stack)-1]
+		if top.object {
+			top.expectKey = true
+		} else {
+			top.index++
+		}
+	}
+
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return 0, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		closing := tok == json.Delim('}') || tok == json.Delim(']')
+		if len(stack) > 0 && !closing {
+			top := &stack[len(stack)-1]
+			if top.object && top.expectKey {
+				top.key, top.expectKey = tok.(string), false
+				if at(top.key) {
+					return lineAt(), nil
+				}
+				continue
+			}
+			if !top.object && at(strconv.Itoa(top.index)) {
+				return lineAt(), nil
+			}
+		}
+		switch tok {
+		case json.Delim('{'), json.Delim('['):
+			stack = append(stack, jsonFrame{object: tok == json.Delim('{'), expectKey: true})
+		case json.Delim('}'), json.Delim(']'):
+			stack = stack[:len(stack)-1]
+			valueDone()
+		default:
+			valueDone()
+		}
+	}
+}
+
+func (f jsonFrame) segment() string {
+	if f.object {
+		return f.key
+	}
+	return strconv.Itoa(f.index)
+}
+
+// findTOML scans table headers and key lines; it does not evaluate the
+// document, so keys inside multi-line strings could in theory match.
+func findTOML(content, path string) (int, error) {
+	table := ""
+	for i, line := range strings.Split(content, "\n") {
+		t := strings.TrimSpace(line)
+		switch {
+		case t == "" || strings.HasPrefix(t, "#"):
+			continue
+		case strings.HasPrefix(t, "["):
+			name := strings.Trim(strings.SplitN(t, "#", 2)[0], "[] \t")
+			table = tomlKey(name)
+			if table == path {
+				return i + 1, nil
+			}
+		default:
+			k, _, ok := strings.Cut(t, "=")
+			if !ok {
+				continue
+			}
+			full := tomlKey(k)
+			if table != "" {
+				full = table + "." + full
+			}
+			if full == path {
+				return i + 1, nil
+			}
+		}
+	}
+	return 0, nil
+}
+
+// tomlKey normalizes a (possibly dotted, possibly quoted) TOML key.
+func tomlKey(k string) string {
+	parts := strings.Split(strings.TrimSpace(k), ".")
+	for i, p := range parts {
+		parts[i] = strings.Trim(strings.TrimSpace(p), `"'`)
+	}
+	return strings.Join(parts, ".")
+}
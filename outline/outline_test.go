@@ -0,0 +1,106 @@
+package outline
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestFind(t *testing.T) {
+	t.Run("finds env keys", func(t *testing.T) {
+		content := "# header\nexport A=1\nDB_PASSWORD=\"x\"\n"
+		line, err := Find(content, "env", "DB_PASSWORD")
+		if err != nil || line != 3 {
+			t.Errorf("expected line 3, got %d (%v)", line, err)
+		}
+	})
+
+	t.Run("finds JSON paths", func(t *testing.T) {
+		content := "{\n  \"a\": 1,\n  \"db\": {\n    \"user\": \"u\",\n    \"password\": \"p\"\n  },\n  \"list\": [\n    {\"host\": \"h0\"},\n    {\"host\": \"h1\"}\n  ]\n}"
+		cases := map[string]int{"a": 2, "db": 3, "db.password": 5, "list.1.host": 9}
+		for path, want := range cases {
+			line, err := Find(content, "json", path)
+			if err != nil || line != want {
+				t.Errorf("%s: expected line %d, got %d (%v)", path, want, line, err)
+			}
+		}
+	})
+
+	t.Run("finds YAML paths", func(t *testing.T) {
+		content := "a: 1\ndb:\n  user: u\n  password: p\nlist:\n  - host: h0\n  - host: h1\n"
+		cases := map[string]int{"db.password": 4, "list.1.host": 7}
+		for path, want := range cases {
+			line, err := Find(content, "yaml", path)
+			if err != nil || line != want {
+				t.Errorf("%s: expected line %d, got %d (%v)", path, want, line, err)
+			}
+		}
+	})
+
+	t.Run("finds TOML paths", func(t *testing.T) {
+		content := "title = \"x\"\n\n[db]\nuser = \"u\"\npassword = \"p\"\n\n[server.http]\nport = 80\n"
+		cases := map[string]int{"title": 1, "db": 3, "db.password": 5, "server.http.port": 8}
+		for path, want := range cases {
+			line, err := Find(content, "toml", path)
+			if err != nil || line != want {
+				t.Errorf("%s: expected line %d, got %d (%v)", path, want, line, err)
+			}
+		}
+	})
+
+	t.Run("reports missing keys", func(t *testing.T) {
+		for _, format := range []string{"env", "json", "yaml", "toml"} {
+			content := map[string]string{"env": "A=1", "json": `{"a":1}`, "yaml": "a: 1", "toml": "a = 1"}[format]
+			if _, err := Find(content, format, "missing"); !errors.Is(err, ErrNotFound) {
+				t.Errorf("%s: expected ErrNotFound, got %v", format, err)
+			}
+		}
+	})
+}
+
+func TestSymbols(t *testing.T) {
+	names := func(syms []Symbol) []string {
+		var out []string
+		for _, s := range syms {
+			out = append(out, s.Name+":"+strconv.Itoa(s.Line))
+		}
+		return out
+	}
+	cases := map[string]struct {
+		content string
+		want    string
+	}{
+		"env":  {"# c\nA=1\nexport DB_PASSWORD=x\n", "A:2,DB_PASSWORD:3"},
+		"json": {"{\n  \"db\": {\n    \"user\": \"u\"\n  },\n  \"list\": [{\"host\": \"h\"}]\n}", "db:2,db.user:3,list:5,list.0.host:5"},
+		"yaml": {"a: 1\ndb:\n  password: p\n", "a:1,db:2,db.password:3"},
+		"toml": {"title = \"x\"\n[db]\nuser = \"u\"\n", "title:1,db:2,db.user:3"},
+	}
+	for format, c := range cases {
+		t.Run("lists "+format+" keys in order", func(t *testing.T) {
+			syms, err := Symbols(c.content, format)
+			if err != nil {
+				t.Fatalf("symbols failed: %v", err)
+			}
+			if got := strings.Join(names(syms), ","); got != c.want {
+				t.Errorf("expected %s, got %s", c.want, got)
+			}
+		})
+	}
+
+	t.Run("lists only names Find can locate", func(t *testing.T) {
+		content := "a: 1\nlist:\n  - host: h0\n  - host: h1\n"
+		syms, _ := Symbols(content, "yaml")
+		for _, s := range syms {
+			if line, err := Find(content, "yaml", s.Name); err != nil || line != s.Line {
+				t.Errorf("%s: Find gave line %d (%v), Symbols %d", s.Name, line, err, s.Line)
+			}
+		}
+	})
+
+	t.Run("reports parse errors", func(t *testing.T) {
+		if _, err := Symbols("a: [", "yaml"); err == nil {
+			t.Error("expected a parse error")
+		}
+	})
+}
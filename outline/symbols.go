@@ -0,0 +1,92 @@
+package outline
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/andreweick/agepad/dotenv"
+	"gopkg.in/yaml.v3"
+)
+
+// Symbol is one key defined in the content: its name as Find accepts it
+// and the 1-based line it is defined on.
+type Symbol struct {
+	Name string
+	Line int
+}
+
+// Symbols lists every key in content in document order: KEY names for env
+// content, dotted paths of every mapping key (list items by index) for
+// json, yaml, and toml.
+func Symbols(content, format string) ([]Symbol, error) {
+	switch format {
+	case "json", "yaml":
+		// JSON is read by the YAML parser too, which reports key lines.
+		return yamlSymbols(content)
+	case "toml":
+		return tomlSymbols(content), nil
+	}
+	es, err := dotenv.Parse(content)
+	if err != nil {
+		return nil, err
+	}
+	syms := make([]Symbol, 0, len(es))
+	for _, e := range es {
+		syms = append(syms, Symbol{Name: e.Key, Line: e.Line})
+	}
+	return syms, nil
+}
+
+func yamlSymbols(content string) ([]Symbol, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte(content), &doc); err != nil {
+		return nil, err
+	}
+	var syms []Symbol
+	var walk func(n *yaml.Node, prefix string)
+	walk = func(n *yaml.Node, prefix string) {
+		switch n.Kind {
+		case yaml.MappingNode:
+			for i := 0; i+1 < len(n.Content); i += 2 {
+				name := prefix + n.Content[i].Value
+				syms = append(syms, Symbol{Name: name, Line: n.Content[i].Line})
+				walk(n.Content[i+1], name+".")
+			}
+		case yaml.SequenceNode:
+			for i, item := range n.Content {
+				walk(item, prefix+strconv.Itoa(i)+".")
+			}
+		}
+	}
+	if len(doc.Content) > 0 {
+		walk(doc.Content[0], "")
+	}
+	return syms, nil
+}
+
+// tomlSymbols lists table headers and keys the way findTOML reads them.
+func tomlSymbols(content string) []Symbol {
+	var syms []Symbol
+	table := ""
+	for i, line := range strings.Split(content, "\n") {
+		t := strings.TrimSpace(line)
+		switch {
+		case t == "" || strings.HasPrefix(t, "#"):
+			continue
+		case strings.HasPrefix(t, "["):
+			table = tomlKey(strings.Trim(strings.SplitN(t, "#", 2)[0], "[] \t"))
+			syms = append(syms, Symbol{Name: table, Line: i + 1})
+		default:
+			k, _, ok := strings.Cut(t, "=")
+			if !ok {
+				continue
+			}
+			name := tomlKey(k)
+			if table != "" {
+				name = table + "." + name
+			}
+			syms = append(syms, Symbol{Name: name, Line: i + 1})
+		}
+	}
+	return syms
+}
@@ -0,0 +1,75 @@
+// Package outputs maps infrastructure tool outputs (terraform output -json,
+// pulumi stack output --json) onto keys for an encrypted file.
+package outputs
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Parse decodes JSON outputs. Terraform wraps each output as
+// {"value": ..., "sensitive": ..., "type": ...}; Pulumi emits bare values.
+// Both shapes are accepted and unwrapped.
+func Parse(b []byte) (map[string]any, error) {
+	var raw map[string]any
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	if err := dec.Decode(&raw); err != nil {
+		return nil, fmt.Errorf("parse outputs: %w", err)
+	}
+	out := make(map[string]any, len(raw))
+	for name, v := range raw {
+		if m, ok := v.(map[string]any); ok {
+			if val, ok := m["value"]; ok {
+				if _, typed := m["type"]; typed || len(m) <= 3 {
+					out[name] = val
+					continue
+				}
+			}
+		}
+		out[name] = v
+	}
+	return out, nil
+}
+
+// LoadMapping reads a YAML file mapping output names to destination keys:
+//
+//	db_password: DB_PASSWORD
+//	api_url: API_URL
+func LoadMapping(path string) (map[string]string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read output map: %w", err)
+	}
+	var m map[string]string
+	if err := yaml.Unmarshal(b, &m); err != nil {
+		return nil, fmt.Errorf("parse output map %s: %w", path, err)
+	}
+	return m, nil
+}
+
+// Select picks the mapped outputs and renames them to their destination
+// keys. A nil mapping selects every output under its own name. Outputs named
+// in the mapping but absent from outs are returned as missing.
+func Select(outs map[string]any, mapping map[string]string) (map[string]any, []string) {
+	if mapping == nil {
+		return outs, nil
+	}
+	sel := map[string]any{}
+	var missing []string
+	for name, key := range mapping {
+		v, ok := outs[name]
+		if !ok {
+			missing = append(missing, name)
+			continue
+		}
+		sel[key] = v
+	}
+	sort.Strings(missing)
+	return sel, missing
+}
@@ -0,0 +1,80 @@
+package outputs
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	t.Run("unwraps terraform outputs", func(t *testing.T) {
+		in := `{"db_password":{"sensitive":true,"type":"string","value":"s3cret"},"port":{"type":"number","value":5432}}`
+		outs, err := Parse([]byte(in))
+		if err != nil {
+			t.Fatalf("parse failed: %v", err)
+		}
+		if outs["db_password"] != "s3cret" {
+			t.Errorf("expected unwrapped value, got %v", outs["db_password"])
+		}
+		if fmt.Sprint(outs["port"]) != "5432" {
+			t.Errorf("expected port 5432, got %v", outs["port"])
+		}
+	})
+
+	t.Run("accepts pulumi bare values", func(t *testing.T) {
+		outs, err := Parse([]byte(`{"url":"https://x","cfg":{"a":1}}`))
+		if err != nil {
+			t.Fatalf("parse failed: %v", err)
+		}
+		if outs["url"] != "https://x" {
+			t.Errorf("unexpected url %v", outs["url"])
+		}
+		if _, ok := outs["cfg"].(map[string]any); !ok {
+			t.Errorf("expected nested map to be kept, got %T", outs["cfg"])
+		}
+	})
+
+	t.Run("rejects invalid JSON", func(t *testing.T) {
+		if _, err := Parse([]byte("not json")); err == nil {
+			t.Error("expected parse error")
+		}
+	})
+}
+
+func TestSelect(t *testing.T) {
+	outs := map[string]any{"db_password": "x", "url": "y"}
+
+	t.Run("renames mapped outputs and reports missing ones", func(t *testing.T) {
+		sel, missing := Select(outs, map[string]string{"db_password": "DB_PASSWORD", "gone": "GONE"})
+		if len(sel) != 1 || sel["DB_PASSWORD"] != "x" {
+			t.Errorf("unexpected selection %v", sel)
+		}
+		if len(missing) != 1 || missing[0] != "gone" {
+			t.Errorf("unexpected missing %v", missing)
+		}
+	})
+
+	t.Run("selects everything without a mapping", func(t *testing.T) {
+		sel, _ := Select(outs, nil)
+		if len(sel) != 2 {
+			t.Errorf("expected all outputs, got %v", sel)
+		}
+	})
+}
+
+func TestLoadMapping(t *testing.T) {
+	t.Run("loads a YAML mapping", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "outputs.yaml")
+		if err := os.WriteFile(path, []byte("db_password: DB_PASSWORD\n"), 0644); err != nil {
+			t.Fatalf("write failed: %v", err)
+		}
+		m, err := LoadMapping(path)
+		if err != nil {
+			t.Fatalf("load failed: %v", err)
+		}
+		if m["db_password"] != "DB_PASSWORD" {
+			t.Errorf("unexpected mapping %v", m)
+		}
+	})
+}
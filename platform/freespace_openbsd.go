@@ -0,0 +1,19 @@
+package platform
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+const freeSpaceDetail = "statfs"
+
+// FreeSpace returns the bytes available to this user on the file system
+// holding dir.
+func FreeSpace(dir string) (uint64, error) {
+	var st unix.Statfs_t
+	if err := unix.Statfs(dir, &st); err != nil {
+		return 0, &os.PathError{Op: "statfs", Path: dir, Err: err}
+	}
+	return uint64(st.F_bavail) * uint64(st.F_bsize), nil
+}
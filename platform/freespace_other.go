@@ -0,0 +1,8 @@
+//go:build !linux && !darwin && !freebsd && !dragonfly && !openbsd && !netbsd && !solaris && !illumos && !windows
+
+package platform
+
+const freeSpaceDetail = ""
+
+// FreeSpace is not implemented on this platform; callers skip the check.
+func FreeSpace(dir string) (uint64, error) { return 0, ErrUnsupported }
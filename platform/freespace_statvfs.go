@@ -0,0 +1,21 @@
+//go:build netbsd || solaris || illumos
+
+package platform
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+const freeSpaceDetail = "statvfs"
+
+// FreeSpace returns the bytes available to this user on the file system
+// holding dir.
+func FreeSpace(dir string) (uint64, error) {
+	var st unix.Statvfs_t
+	if err := unix.Statvfs(dir, &st); err != nil {
+		return 0, &os.PathError{Op: "statvfs", Path: dir, Err: err}
+	}
+	return uint64(st.Bavail) * uint64(st.Frsize), nil
+}
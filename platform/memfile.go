@@ -0,0 +1,99 @@
+package platform
+
+import (
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// MemFile holds plaintext in memory-backed storage so another program, such
+// as the user's $EDITOR, can edit it by path without it reaching a disk:
+// an anonymous memfd on Linux, and elsewhere a private directory on the
+// ramdisk named by $AGEPAD_RAMDISK. Start the program with Files as its
+// extra files (exec.Cmd.ExtraFiles) and Path as its argument.
+type MemFile struct {
+	Path  string
+	Files []*os.File
+
+	f   *os.File // the memfd
+	dir string   // the private ramdisk directory
+}
+
+// NewMemFile stores data in a new memory-backed file. name is only a hint
+// (editors pick their syntax highlighting from it); it is not a path.
+func NewMemFile(name string, data []byte) (*MemFile, error) {
+	return newMemFile(filepath.Base(name), data)
+}
+
+// Read returns the file's current contents.
+func (m *MemFile) Read() ([]byte, error) {
+	if m.f == nil {
+		return os.ReadFile(m.Path)
+	}
+	if _, err := m.f.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	return io.ReadAll(m.f)
+}
+
+// Close overwrites the contents with zeros and releases the storage,
+// including backup and swap files an editor left next to a ramdisk file.
+func (m *MemFile) Close() error {
+	if m.f != nil {
+		err := wipe(m.f)
+		if cerr := m.f.Close(); err == nil {
+			err = cerr
+		}
+		return err
+	}
+	_ = filepath.WalkDir(m.dir, func(p string, d fs.DirEntry, err error) error {
+		if err == nil && d.Type().IsRegular() {
+			if f, err := os.OpenFile(p, os.O_WRONLY, 0); err == nil {
+				_ = wipe(f)
+				f.Close()
+			}
+		}
+		return nil
+	})
+	return os.RemoveAll(m.dir)
+}
+
+// ramdiskDetail describes $AGEPAD_RAMDISK for Capabilities.
+func ramdiskDetail() string {
+	if root := os.Getenv("AGEPAD_RAMDISK"); root != "" {
+		return "AGEPAD_RAMDISK=" + root
+	}
+	return ""
+}
+
+// wipe zeroes f in place and truncates it.
+func wipe(f *os.File) error {
+	st, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if _, err := f.WriteAt(make([]byte, st.Size()), 0); err != nil {
+		return err
+	}
+	return f.Truncate(0)
+}
+
+// ramdiskFile creates name with data in a new 0700 directory under
+// $AGEPAD_RAMDISK.
+func ramdiskFile(name string, data []byte) (*MemFile, error) {
+	root := os.Getenv("AGEPAD_RAMDISK")
+	if root == "" {
+		return nil, ErrNoMemFS
+	}
+	dir, err := os.MkdirTemp(root, "agepad-")
+	if err != nil {
+		return nil, err
+	}
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+	return &MemFile{Path: path, dir: dir}, nil
+}
@@ -0,0 +1,26 @@
+package platform
+
+import (
+	"fmt"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// newMemFile creates an anonymous memfd. The child opens it as its first
+// extra file through /proc/self, so the path works however the parent
+// was hardened (a non-dumpable parent's /proc/<pid>/fd is closed to it).
+func newMemFile(name string, data []byte) (*MemFile, error) {
+	fd, err := unix.MemfdCreate("agepad:"+name, unix.MFD_CLOEXEC)
+	if err != nil {
+		return nil, fmt.Errorf("memfd_create: %w", err)
+	}
+	f := os.NewFile(uintptr(fd), name)
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &MemFile{Path: "/proc/self/fd/3", Files: []*os.File{f}, f: f}, nil
+}
+
+func memFileDetail() string { return "memfd_create" }
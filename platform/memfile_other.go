@@ -0,0 +1,11 @@
+//go:build !linux
+
+package platform
+
+// newMemFile uses the ramdisk named by $AGEPAD_RAMDISK; there is no
+// anonymous memory-backed file to hand to another program here.
+func newMemFile(name string, data []byte) (*MemFile, error) {
+	return ramdiskFile(name, data)
+}
+
+func memFileDetail() string { return ramdiskDetail() }
@@ -0,0 +1,116 @@
+// Package platform holds the parts of agepad that differ between operating
+// systems: replacing the process for `run`, advisory file locks, the
+// terminal used for passphrase and plugin prompts, and default directories.
+// Each has a Unix (Linux, macOS, BSD) and a Windows implementation, and
+// Capabilities says which are active, so a feature written on Linux shows
+// up as missing in `agepad doctor --platform` instead of quietly doing
+// nothing elsewhere.
+package platform
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// ErrUnsupported is returned by operations this platform cannot perform.
+var ErrUnsupported = errors.New("not supported on this platform")
+
+// ErrNoMemFS is returned by NewMemFile when there is no memory-backed
+// storage to use.
+var ErrNoMemFS = fmt.Errorf("no memory-backed file system; mount a ramdisk and set AGEPAD_RAMDISK to it: %w", ErrUnsupported)
+
+// Capability is one platform-dependent feature and whether it works here.
+type Capability struct {
+	Name   string
+	Active bool
+	Detail string
+}
+
+// Capabilities reports the features implemented in this package for the
+// running OS.
+func Capabilities() []Capability {
+	caps := []Capability{
+		{Name: "run replaces the agepad process", Active: execReplaces, Detail: execDetail},
+		{Name: "advisory file locks", Active: lockDetail != "", Detail: lockDetail},
+		{Name: "terminal prompts", Active: ttyAvailable(), Detail: ttyDetail},
+		{Name: "memory-backed files (--editor external)", Active: memFileDetail() != "", Detail: memFileDetail()},
+		{Name: "free space checks before batch writes", Active: freeSpaceDetail != "", Detail: freeSpaceDetail},
+		{Name: "run --supervise ends by the child's signal", Active: signalDetail != "", Detail: signalDetail},
+		{Name: "run --report peak memory", Active: rssDetail != "", Detail: rssDetail},
+	}
+	for _, d := range []struct{ name, dir string }{
+		{"config directory", ConfigDir()},
+		{"state directory", StateDir()},
+		{"runtime directory", RuntimeDir()},
+	} {
+		caps = append(caps, Capability{Name: d.name, Active: d.dir != "", Detail: d.dir})
+	}
+	return caps
+}
+
+// String renders caps one per line, in the same shape as the --harden
+// report.
+func String(caps []Capability) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "platform: %s/%s\n", runtime.GOOS, runtime.GOARCH)
+	for _, c := range caps {
+		mark := "ok  "
+		if !c.Active {
+			mark = "skip"
+		}
+		fmt.Fprintf(&b, "[%s] %s", mark, c.Name)
+		if c.Detail != "" {
+			fmt.Fprintf(&b, ": %s", c.Detail)
+		}
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// home is the user's home directory, or "" when it cannot be determined.
+func home() string {
+	h, _ := os.UserHomeDir()
+	return h
+}
+
+// xdg returns $env, or the path under the home directory when unset.
+func xdg(env string, fallback ...string) string {
+	if dir := os.Getenv(env); dir != "" {
+		return dir
+	}
+	h := home()
+	if h == "" {
+		return ""
+	}
+	return filepath.Join(append([]string{h}, fallback...)...)
+}
+
+func ttyAvailable() bool {
+	t, err := OpenTTY()
+	if err != nil {
+		return false
+	}
+	t.Close()
+	return true
+}
+
+// TTY is the controlling terminal, for prompts that must not go through
+// stdin and stdout (which may be piped).
+type TTY struct {
+	In, Out *os.File
+}
+
+// Close closes the terminal.
+func (t *TTY) Close() error {
+	err := t.In.Close()
+	if t.Out != t.In {
+		if oerr := t.Out.Close(); err == nil {
+			err = oerr
+		}
+	}
+	return err
+}
@@ -0,0 +1,33 @@
+//go:build !unix && !windows
+
+package platform
+
+import "os"
+
+const (
+	execReplaces = false
+	execDetail   = "not implemented"
+	lockDetail   = ""
+	ttyDetail    = "not implemented"
+)
+
+// Exec is not implemented on this platform.
+func Exec(path string, argv, env []string) error { return ErrUnsupported }
+
+// LockFile is not implemented on this platform; callers proceed unlocked.
+func LockFile(f *os.File) error { return ErrUnsupported }
+
+// UnlockFile is not implemented on this platform.
+func UnlockFile(f *os.File) error { return ErrUnsupported }
+
+// OpenTTY is not implemented on this platform.
+func OpenTTY() (*TTY, error) { return nil, ErrUnsupported }
+
+// ConfigDir is $XDG_CONFIG_HOME, default ~/.config.
+func ConfigDir() string { return xdg("XDG_CONFIG_HOME", ".config") }
+
+// StateDir is $XDG_STATE_HOME, default ~/.local/state.
+func StateDir() string { return xdg("XDG_STATE_HOME", ".local", "state") }
+
+// RuntimeDir is not defined on this platform.
+func RuntimeDir() string { return "" }
@@ -0,0 +1,147 @@
+package platform
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestPlatform(t *testing.T) {
+	t.Run("locks and unlocks a file", func(t *testing.T) {
+		f, err := os.Create(filepath.Join(t.TempDir(), "lock"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		if err := LockFile(f); err != nil {
+			t.Fatalf("LockFile: %v", err)
+		}
+		if err := UnlockFile(f); err != nil {
+			t.Fatalf("UnlockFile: %v", err)
+		}
+	})
+
+	t.Run("follows the XDG variables", func(t *testing.T) {
+		if runtime.GOOS == "windows" {
+			t.Skip("Windows uses %AppData% and %LocalAppData%")
+		}
+		t.Setenv("XDG_CONFIG_HOME", "/x/config")
+		t.Setenv("XDG_STATE_HOME", "/x/state")
+		t.Setenv("XDG_RUNTIME_DIR", "/x/run")
+		if ConfigDir() != "/x/config" || StateDir() != "/x/state" || RuntimeDir() != filepath.Join("/x/run", "agepad") {
+			t.Errorf("got %s, %s, %s", ConfigDir(), StateDir(), RuntimeDir())
+		}
+		t.Setenv("XDG_CONFIG_HOME", "")
+		t.Setenv("HOME", "/home/u")
+		if ConfigDir() != filepath.Join("/home/u", ".config") {
+			t.Errorf("expected ~/.config, got %s", ConfigDir())
+		}
+	})
+
+	t.Run("lets a child edit a memory-backed file", func(t *testing.T) {
+		if runtime.GOOS == "windows" {
+			t.Skip("needs sh")
+		}
+		t.Setenv("AGEPAD_RAMDISK", t.TempDir()) // used where there is no memfd
+		mf, err := NewMemFile("app.env", []byte("A=1\n"))
+		if err != nil {
+			t.Fatalf("NewMemFile: %v", err)
+		}
+		cmd := exec.Command("sh", "-c", `cat "$1" && printf 'B=2\n' > "$1"`, "sh", mf.Path)
+		cmd.ExtraFiles = mf.Files
+		out, err := cmd.Output()
+		if err != nil || string(out) != "A=1\n" {
+			t.Fatalf("child read %q, %v", out, err)
+		}
+		got, err := mf.Read()
+		if err != nil || string(got) != "B=2\n" {
+			t.Errorf("expected the child's edit, got %q, %v", got, err)
+		}
+		if err := mf.Close(); err != nil {
+			t.Errorf("Close: %v", err)
+		}
+	})
+
+	t.Run("supervises a child and its exit status", func(t *testing.T) {
+		if runtime.GOOS == "windows" {
+			t.Skip("needs sh")
+		}
+		sh, err := exec.LookPath("sh")
+		if err != nil {
+			t.Skip("needs sh")
+		}
+		ps, usage, err := Supervise(sh, []string{"sh", "-c", `test "$A" = 1 && exit 3`}, []string{"A=1"})
+		if err != nil {
+			t.Fatalf("Supervise: %v", err)
+		}
+		if ps.ExitCode() != 3 {
+			t.Errorf("expected exit status 3, got %s", ps)
+		}
+		if usage.Wall <= 0 {
+			t.Errorf("expected a wall time, got %v", usage.Wall)
+		}
+		if _, _, err := Supervise(filepath.Join(t.TempDir(), "missing"), []string{"missing"}, nil); err == nil {
+			t.Error("expected an error for a missing command")
+		}
+	})
+
+	t.Run("restarts a child with each new environment", func(t *testing.T) {
+		if runtime.GOOS == "windows" {
+			t.Skip("needs sh")
+		}
+		sh, err := exec.LookPath("sh")
+		if err != nil {
+			t.Skip("needs sh")
+		}
+		// Each child records its environment, then waits to be stopped.
+		out := filepath.Join(t.TempDir(), "out")
+		script := `echo "$A" >> ` + out + `; trap 'exit 7' TERM; while :; do sleep 0.01; done`
+		restart := make(chan []string)
+		ended := make(chan *os.ProcessState)
+		go func() {
+			ps, err := SuperviseRestarting(sh, []string{"sh", "-c", script}, []string{"A=1"}, restart, 5*time.Second, func(string) {})
+			if err != nil {
+				t.Errorf("SuperviseRestarting: %v", err)
+			}
+			ended <- ps
+		}()
+		waitFor := func(want string) {
+			t.Helper()
+			for i := 0; i < 500; i++ {
+				if b, _ := os.ReadFile(out); string(b) == want {
+					return
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+			b, _ := os.ReadFile(out)
+			t.Fatalf("got %q, want %q", b, want)
+		}
+		waitFor("1\n")
+		restart <- []string{"A=2"}
+		waitFor("1\n2\n")
+		self, _ := os.FindProcess(os.Getpid())
+		_ = self.Signal(syscall.SIGTERM)
+		select {
+		case ps := <-ended:
+			if ps == nil || ps.ExitCode() != 7 {
+				t.Errorf("expected the last child's exit status 7, got %v", ps)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("did not return after SIGTERM")
+		}
+	})
+
+	t.Run("reports every capability", func(t *testing.T) {
+		out := String(Capabilities())
+		for _, want := range []string{"platform: " + runtime.GOOS, "run replaces the agepad process", "advisory file locks", "config directory"} {
+			if !strings.Contains(out, want) {
+				t.Errorf("expected %q in:\n%s", want, out)
+			}
+		}
+	})
+}
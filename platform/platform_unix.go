@@ -0,0 +1,62 @@
+//go:build unix
+
+package platform
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	execReplaces = true
+	execDetail   = "execve"
+	lockDetail   = "flock"
+	ttyDetail    = "/dev/tty"
+)
+
+// Exec replaces the current process with path, so the secrets in env live
+// only in the child. It does not return on success.
+func Exec(path string, argv, env []string) error {
+	return syscall.Exec(path, argv, env)
+}
+
+// LockFile takes an exclusive advisory lock on f, waiting for other
+// agepad processes to release theirs.
+func LockFile(f *os.File) error {
+	return unix.Flock(int(f.Fd()), unix.LOCK_EX)
+}
+
+// UnlockFile releases a LockFile lock.
+func UnlockFile(f *os.File) error {
+	return unix.Flock(int(f.Fd()), unix.LOCK_UN)
+}
+
+// OpenTTY opens the controlling terminal.
+func OpenTTY() (*TTY, error) {
+	f, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return nil, fmt.Errorf("no terminal: %w", err)
+	}
+	return &TTY{In: f, Out: f}, nil
+}
+
+// ConfigDir is $XDG_CONFIG_HOME, default ~/.config (on macOS too, where
+// age and most command-line tools look).
+func ConfigDir() string { return xdg("XDG_CONFIG_HOME", ".config") }
+
+// StateDir is $XDG_STATE_HOME, default ~/.local/state.
+func StateDir() string { return xdg("XDG_STATE_HOME", ".local", "state") }
+
+// RuntimeDir is where agepad keeps its sockets: $XDG_RUNTIME_DIR/agepad, or
+// a per-user directory in the temp dir.
+func RuntimeDir() string {
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		return filepath.Join(dir, "agepad")
+	}
+	return filepath.Join(os.TempDir(), "agepad-"+strconv.Itoa(os.Getuid()))
+}
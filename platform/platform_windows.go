@@ -0,0 +1,75 @@
+//go:build windows
+
+package platform
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/sys/windows"
+)
+
+const (
+	execReplaces = false
+	execDetail   = "Windows cannot replace a process; agepad waits for the child and exits with its status"
+	lockDetail   = "LockFileEx"
+	ttyDetail    = "CONIN$/CONOUT$"
+)
+
+// Exec runs path with env attached to agepad's standard streams and exits
+// with its status once it finishes, as Supervise and ExitLike do; Ctrl+C
+// reaches the child and no longer ends agepad before it. It only returns
+// if the child could not be started.
+func Exec(path string, argv, env []string) error {
+	ps, _, err := Supervise(path, argv, env)
+	if err != nil {
+		return err
+	}
+	ExitLike(ps)
+	return nil
+}
+
+// LockFile takes an exclusive lock on f, waiting for other agepad
+// processes to release theirs.
+func LockFile(f *os.File) error {
+	var ol windows.Overlapped
+	return windows.LockFileEx(windows.Handle(f.Fd()), windows.LOCKFILE_EXCLUSIVE_LOCK, 0, 1, 0, &ol)
+}
+
+// UnlockFile releases a LockFile lock.
+func UnlockFile(f *os.File) error {
+	var ol windows.Overlapped
+	return windows.UnlockFileEx(windows.Handle(f.Fd()), 0, 1, 0, &ol)
+}
+
+// OpenTTY opens the console.
+func OpenTTY() (*TTY, error) {
+	in, err := os.OpenFile("CONIN$", os.O_RDWR, 0)
+	if err != nil {
+		return nil, fmt.Errorf("no console: %w", err)
+	}
+	out, err := os.OpenFile("CONOUT$", os.O_WRONLY, 0)
+	if err != nil {
+		in.Close()
+		return nil, fmt.Errorf("no console: %w", err)
+	}
+	return &TTY{In: in, Out: out}, nil
+}
+
+// ConfigDir is %AppData%.
+func ConfigDir() string {
+	dir, _ := os.UserConfigDir()
+	return dir
+}
+
+// StateDir is %LocalAppData%.
+func StateDir() string { return os.Getenv("LocalAppData") }
+
+// RuntimeDir is where agepad keeps its sockets: %LocalAppData%\agepad\run.
+func RuntimeDir() string {
+	if dir := StateDir(); dir != "" {
+		return filepath.Join(dir, "agepad", "run")
+	}
+	return ""
+}
@@ -0,0 +1,13 @@
+//go:build !unix
+
+package platform
+
+import "net"
+
+// ListenUnix creates a Unix socket; callers restrict it afterwards.
+func ListenUnix(path string) (net.Listener, error) {
+	return net.Listen("unix", path)
+}
+
+// CheckSocketDir has no owner or mode to check here.
+func CheckSocketDir(dir string) error { return nil }
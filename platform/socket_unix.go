@@ -0,0 +1,38 @@
+//go:build unix
+
+package platform
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+)
+
+// ListenUnix creates a Unix socket under a 0077 umask, so it is never
+// reachable by other users, not even before the caller chmods it. The
+// umask is process-wide; agepad only listens once, at startup.
+func ListenUnix(path string) (net.Listener, error) {
+	old := syscall.Umask(0o077)
+	defer syscall.Umask(old)
+	return net.Listen("unix", path)
+}
+
+// CheckSocketDir refuses a socket directory that belongs to another user
+// or that others may write to, since they could replace the socket.
+func CheckSocketDir(dir string) error {
+	info, err := os.Lstat(dir)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", dir)
+	}
+	if st, ok := info.Sys().(*syscall.Stat_t); ok && int(st.Uid) != os.Getuid() {
+		return fmt.Errorf("%s is owned by uid %d, not %d", dir, st.Uid, os.Getuid())
+	}
+	if perm := info.Mode().Perm(); perm&0o022 != 0 {
+		return fmt.Errorf("%s is writable by other users (mode %04o)", dir, perm)
+	}
+	return nil
+}
@@ -0,0 +1,146 @@
+package platform
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"time"
+)
+
+// ChildUsage is what a supervised child used.
+type ChildUsage struct {
+	Wall   time.Duration
+	User   time.Duration
+	System time.Duration
+	MaxRSS int64 // peak resident memory in bytes; 0 when the OS does not say
+}
+
+// Supervise runs path as a child of agepad on the standard streams,
+// passing on the signals agepad receives while it runs, and returns how
+// it ended and what it used. ExitLike then ends agepad the same way, so
+// callers see the child's exact status.
+func Supervise(path string, argv, env []string) (*os.ProcessState, ChildUsage, error) {
+	cmd := &exec.Cmd{
+		Path:   path,
+		Args:   argv,
+		Env:    env,
+		Stdin:  os.Stdin,
+		Stdout: os.Stdout,
+		Stderr: os.Stderr,
+	}
+	sigs := make(chan os.Signal, 8)
+	if len(caughtSignals) > 0 { // none would catch every signal
+		signal.Notify(sigs, caughtSignals...)
+		defer signal.Stop(sigs)
+	}
+
+	start := time.Now()
+	if err := cmd.Start(); err != nil {
+		return nil, ChildUsage{}, err
+	}
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case s := <-sigs:
+				if forward(s) {
+					_ = cmd.Process.Signal(s)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+	err := cmd.Wait()
+	close(done)
+	ps := cmd.ProcessState
+	if ps == nil {
+		return nil, ChildUsage{}, err
+	}
+	return ps, ChildUsage{
+		Wall:   time.Since(start),
+		User:   ps.UserTime(),
+		System: ps.SystemTime(),
+		MaxRSS: maxRSS(ps),
+	}, nil
+}
+
+// SuperviseRestarting runs path as Supervise does, and restarts it with
+// each environment restart delivers: the running child is asked to stop
+// (SIGTERM; on Windows it is killed), killed if it is still running after
+// grace, and started again. A child that exits on its own is started
+// again only with the next environment. It returns once a signal agepad
+// received has ended the child, or arrives while none runs, with the last
+// child's state for ExitLike. notice is told about each restart and exit.
+func SuperviseRestarting(path string, argv, env []string, restart <-chan []string, grace time.Duration, notice func(string)) (*os.ProcessState, error) {
+	sigs := make(chan os.Signal, 8)
+	if len(caughtSignals) > 0 {
+		signal.Notify(sigs, caughtSignals...)
+		defer signal.Stop(sigs)
+	}
+
+	var (
+		cmd      *exec.Cmd
+		exited   chan *os.ProcessState // nil while no child runs
+		last     *os.ProcessState
+		signaled bool
+	)
+	start := func(env []string) error {
+		c := &exec.Cmd{
+			Path:   path,
+			Args:   argv,
+			Env:    env,
+			Stdin:  os.Stdin,
+			Stdout: os.Stdout,
+			Stderr: os.Stderr,
+		}
+		if err := c.Start(); err != nil {
+			return err
+		}
+		ch := make(chan *os.ProcessState, 1)
+		go func() {
+			_ = c.Wait()
+			ch <- c.ProcessState
+		}()
+		cmd, exited = c, ch
+		return nil
+	}
+	if err := start(env); err != nil {
+		return nil, err
+	}
+	for {
+		select {
+		case env := <-restart:
+			if exited != nil {
+				_ = terminate(cmd.Process)
+				select {
+				case last = <-exited:
+				case <-time.After(grace):
+					notice(fmt.Sprintf("still running after %s; killing it", grace))
+					_ = cmd.Process.Kill()
+					last = <-exited
+				}
+				exited = nil
+			}
+			notice("restarting")
+			if err := start(env); err != nil {
+				return last, err
+			}
+		case ps := <-exited:
+			last, exited = ps, nil
+			if signaled {
+				return last, nil
+			}
+			notice(fmt.Sprintf("command ended (%s); it starts again on the next change", ps))
+		case s := <-sigs:
+			if exited == nil {
+				return last, nil
+			}
+			signaled = true
+			if forward(s) {
+				_ = cmd.Process.Signal(s)
+			}
+		}
+	}
+}
@@ -0,0 +1,24 @@
+//go:build !unix && !windows
+
+package platform
+
+import "os"
+
+const (
+	signalDetail = ""
+	rssDetail    = ""
+)
+
+var caughtSignals []os.Signal
+
+func forward(os.Signal) bool { return false }
+
+// terminate stops a child; there is no signal to ask it politely.
+func terminate(p *os.Process) error { return p.Kill() }
+
+func maxRSS(*os.ProcessState) int64 { return 0 }
+
+// ExitLike ends agepad with the child's exit code.
+func ExitLike(ps *os.ProcessState) {
+	os.Exit(ps.ExitCode())
+}
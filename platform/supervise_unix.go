@@ -0,0 +1,73 @@
+//go:build unix
+
+package platform
+
+import (
+	"os"
+	"os/signal"
+	"runtime"
+	"syscall"
+	"time"
+
+	"github.com/charmbracelet/x/term"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	signalDetail = "re-raised on agepad"
+	rssDetail    = "getrusage"
+)
+
+var caughtSignals = []os.Signal{
+	syscall.SIGINT, syscall.SIGQUIT, syscall.SIGTERM, syscall.SIGHUP, syscall.SIGUSR1, syscall.SIGUSR2,
+}
+
+// forward reports whether to pass s on to the child. Ctrl+C and Ctrl+\ at
+// a terminal already reach the whole foreground process group, child
+// included, and a second copy makes many programs give up their cleanup.
+func forward(s os.Signal) bool {
+	if s == syscall.SIGINT || s == syscall.SIGQUIT {
+		return !term.IsTerminal(os.Stdin.Fd())
+	}
+	return true
+}
+
+// terminate asks a child to stop.
+func terminate(p *os.Process) error { return p.Signal(syscall.SIGTERM) }
+
+func maxRSS(ps *os.ProcessState) int64 {
+	ru, ok := ps.SysUsage().(*syscall.Rusage)
+	if !ok {
+		return 0
+	}
+	if runtime.GOOS == "darwin" || runtime.GOOS == "ios" {
+		return int64(ru.Maxrss) // bytes
+	}
+	return int64(ru.Maxrss) * 1024 // KiB
+}
+
+// crashSignals are the ones the Go runtime answers with its own goroutine
+// dump even after signal.Reset, so agepad cannot die by them cleanly.
+var crashSignals = map[syscall.Signal]bool{
+	syscall.SIGQUIT: true, syscall.SIGILL: true, syscall.SIGTRAP: true, syscall.SIGABRT: true,
+	syscall.SIGBUS: true, syscall.SIGFPE: true, syscall.SIGSEGV: true, syscall.SIGSYS: true,
+}
+
+// ExitLike ends agepad as the child ended: with its exit code, or killed
+// by the same signal, so a shell or CI runner sees the real cause. Core
+// dumps are disabled first, since agepad's memory held the secrets. The
+// crash signals end agepad with 128+n instead, as a shell reports them.
+func ExitLike(ps *os.ProcessState) {
+	if ws, ok := ps.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+		sig := ws.Signal()
+		if crashSignals[sig] {
+			os.Exit(128 + int(sig))
+		}
+		_ = unix.Setrlimit(unix.RLIMIT_CORE, &unix.Rlimit{})
+		signal.Reset(sig)
+		_ = syscall.Kill(os.Getpid(), sig)
+		time.Sleep(100 * time.Millisecond) // delivery is asynchronous
+		os.Exit(128 + int(sig))
+	}
+	os.Exit(ps.ExitCode())
+}
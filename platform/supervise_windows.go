@@ -0,0 +1,27 @@
+//go:build windows
+
+package platform
+
+import "os"
+
+const (
+	signalDetail = ""
+	rssDetail    = ""
+)
+
+// Ctrl+C reaches every process on the console, the child included, and
+// Windows cannot pass signals on; agepad only keeps running until the
+// child has exited.
+var caughtSignals = []os.Signal{os.Interrupt}
+
+func forward(os.Signal) bool { return false }
+
+// terminate stops a child; there is no signal to ask it politely.
+func terminate(p *os.Process) error { return p.Kill() }
+
+func maxRSS(*os.ProcessState) int64 { return 0 }
+
+// ExitLike ends agepad with the child's exit code.
+func ExitLike(ps *os.ProcessState) {
+	os.Exit(ps.ExitCode())
+}
@@ -0,0 +1,202 @@
+// Package redact turns decrypted content into a catalog copy that keeps
+// its shape (key names, comments, nesting) and replaces every value with
+// Placeholder, so what secrets exist can be browsed without the secrets.
+package redact
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/andreweick/agepad/convert"
+	"github.com/andreweick/agepad/dotenv"
+	"gopkg.in/yaml.v3"
+)
+
+// Placeholder stands in for every value.
+const Placeholder = "<redacted>"
+
+// Redact returns content with every value replaced by Placeholder. It
+// keeps full-line comments and key order for env and YAML, key order for
+// JSON (which has no comments), and only the keys for TOML, which is
+// re-encoded. Unknown formats are refused rather than guessed at.
+func Redact(content, format string) (string, error) {
+	switch format {
+	case "env":
+		return redactEnv(content)
+	case "yaml":
+		return redactYAML(content)
+	case "json":
+		return redactJSON(content)
+	case "toml":
+		data, err := convert.Decode(content, convert.TOML)
+		if err != nil {
+			return "", err
+		}
+		return convert.Encode(redactValue(data).(map[string]any), convert.TOML)
+	}
+	return "", fmt.Errorf("cannot redact format %q", format)
+}
+
+// redactEnv rewrites each assignment as KEY=<redacted>, drops the
+// continuation lines of multiline values, and keeps blank and comment lines.
+// Inline comments are dropped with the value they follow.
+func redactEnv(content string) (string, error) {
+	es, err := dotenv.Parse(content)
+	if err != nil {
+		return "", err
+	}
+	lines := strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+	byLine := map[int]dotenv.Entry{}
+	for _, e := range es {
+		byLine[e.Line] = e
+	}
+	var b strings.Builder
+	for i := 0; i < len(lines); i++ {
+		e, ok := byLine[i+1]
+		if !ok {
+			// Parse accepted the file, so the rest are blank or comments.
+			b.WriteString(strings.TrimSuffix(lines[i], "\r") + "\n")
+			continue
+		}
+		if e.Export {
+			b.WriteString("export ")
+		}
+		b.WriteString(e.Key + "=" + Placeholder + "\n")
+		i = e.EndLine - 1
+	}
+	return b.String(), nil
+}
+
+// redactYAML replaces scalar values in the node tree, which keeps full-line
+// comments, key order, and anchors. Comments on a value's line and below
+// it go with the value, as in redactEnv, since they often describe it
+// ("was: ..."). Only the first document is read.
+func redactYAML(content string) (string, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte(content), &doc); err != nil {
+		return "", fmt.Errorf("YAML parse error: %w", err)
+	}
+	if len(doc.Content) == 0 {
+		return "", nil
+	}
+	// onLine reports whether n is written on its key's line: a scalar or a
+	// flow [...] or {...}.
+	onLine := func(n *yaml.Node) bool {
+		return n.Kind == yaml.ScalarNode || n.Style&yaml.FlowStyle != 0
+	}
+	var walk func(n *yaml.Node)
+	walk = func(n *yaml.Node) {
+		if onLine(n) {
+			n.LineComment, n.FootComment = "", ""
+		}
+		switch n.Kind {
+		case yaml.MappingNode:
+			for i := 0; i+1 < len(n.Content); i += 2 {
+				if k, v := n.Content[i], n.Content[i+1]; onLine(v) {
+					k.LineComment, k.FootComment = "", ""
+				}
+				walk(n.Content[i+1])
+			}
+		case yaml.SequenceNode:
+			for _, item := range n.Content {
+				walk(item)
+			}
+		case yaml.ScalarNode:
+			n.Value, n.Tag, n.Style = Placeholder, "!!str", 0
+		}
+	}
+	walk(doc.Content[0])
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(&doc); err != nil {
+		return "", err
+	}
+	return buf.String(), enc.Close()
+}
+
+// redactJSON re-emits the token stream with two-space indentation so keys
+// stay in file order, writing Placeholder for every scalar.
+func redactJSON(content string) (string, error) {
+	dec := json.NewDecoder(strings.NewReader(content))
+	dec.UseNumber()
+	var b strings.Builder
+	if err := jsonValue(dec, &b, ""); err != nil {
+		return "", fmt.Errorf("JSON parse error: %w", err)
+	}
+	if _, err := dec.Token(); !errors.Is(err, io.EOF) {
+		return "", errors.New("JSON parse error: trailing data after the top-level value")
+	}
+	b.WriteString("\n")
+	return b.String(), nil
+}
+
+func jsonValue(dec *json.Decoder, b *strings.Builder, indent string) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	d, ok := tok.(json.Delim)
+	if !ok {
+		b.WriteString(`"` + Placeholder + `"`)
+		return nil
+	}
+	closing := "]"
+	if d == '{' {
+		closing = "}"
+	}
+	b.WriteString(string(d))
+	n := 0
+	for ; dec.More(); n++ {
+		if n > 0 {
+			b.WriteString(",")
+		}
+		b.WriteString("\n" + indent + "  ")
+		if d == '{' {
+			key, err := dec.Token()
+			if err != nil {
+				return err
+			}
+			k, _ := json.Marshal(key)
+			b.Write(k)
+			b.WriteString(": ")
+		}
+		if err := jsonValue(dec, b, indent+"  "); err != nil {
+			return err
+		}
+	}
+	if n > 0 {
+		b.WriteString("\n" + indent)
+	}
+	if _, err := dec.Token(); err != nil { // the closing delimiter
+		return err
+	}
+	b.WriteString(closing)
+	return nil
+}
+
+// redactValue replaces the leaves of decoded data, keeping maps and lists.
+func redactValue(v any) any {
+	switch t := v.(type) {
+	case map[string]any:
+		for k, x := range t {
+			t[k] = redactValue(x)
+		}
+		return t
+	case []any:
+		for i, x := range t {
+			t[i] = redactValue(x)
+		}
+		return t
+	case []map[string]any:
+		for _, x := range t {
+			redactValue(x)
+		}
+		return t
+	}
+	return Placeholder
+}
@@ -0,0 +1,95 @@
+package redact
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRedact(t *testing.T) {
+	secrets := []string{"hunter2", "s3cret", "line two", "5432", "true"}
+	noSecrets := func(t *testing.T, out string) {
+		t.Helper()
+		for _, s := range secrets {
+			if strings.Contains(out, s) {
+				t.Errorf("value %q leaked:\n%s", s, out)
+			}
+		}
+	}
+
+	t.Run("keeps env keys and comments", func(t *testing.T) {
+		in := "# Database\nexport DB_PASSWORD=hunter2 # rotated monthly\n\nCERT=\"line one\nline two\"\nAPI_KEY='s3cret'\n"
+		out, err := Redact(in, "env")
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := "# Database\nexport DB_PASSWORD=<redacted>\n\nCERT=<redacted>\nAPI_KEY=<redacted>\n"
+		if out != want {
+			t.Errorf("got:\n%s\nwant:\n%s", out, want)
+		}
+	})
+
+	t.Run("keeps YAML comments and order", func(t *testing.T) {
+		in := "# Service config\ndb:\n  # Primary\n  port: 5432 # default\n  password: hunter2 # was: oldpass99\nflags: [true, s3cret] # s3cret\nlist:\n  - hunter2 # hunter2\n"
+		out, err := Redact(in, "yaml")
+		if err != nil {
+			t.Fatal(err)
+		}
+		noSecrets(t, out)
+		for _, want := range []string{"# Service config", "# Primary", "password: <redacted>\n", "flags: [<redacted>, <redacted>]\n"} {
+			if !strings.Contains(out, want) {
+				t.Errorf("expected %q in:\n%s", want, out)
+			}
+		}
+		for _, inline := range []string{"# default", "oldpass99"} {
+			if strings.Contains(out, inline) {
+				t.Errorf("inline comment %q kept:\n%s", inline, out)
+			}
+		}
+		if strings.Index(out, "port") > strings.Index(out, "password") {
+			t.Errorf("key order changed:\n%s", out)
+		}
+	})
+
+	t.Run("keeps JSON key order", func(t *testing.T) {
+		out, err := Redact(`{"z": {"password": "hunter2", "port": 5432}, "a": [true, "s3cret"], "empty": {}}`, "json")
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := `{
+  "z": {
+    "password": "<redacted>",
+    "port": "<redacted>"
+  },
+  "a": [
+    "<redacted>",
+    "<redacted>"
+  ],
+  "empty": {}
+}
+`
+		if out != want {
+			t.Errorf("got:\n%s\nwant:\n%s", out, want)
+		}
+	})
+
+	t.Run("keeps TOML keys", func(t *testing.T) {
+		out, err := Redact("[db]\npassword = \"hunter2\"\nport = 5432\n\n[[users]]\nname = \"s3cret\"\n", "toml")
+		if err != nil {
+			t.Fatal(err)
+		}
+		noSecrets(t, out)
+		for _, want := range []string{"[db]", "password = '<redacted>'", "[[users]]", "name = '<redacted>'"} {
+			if !strings.Contains(out, want) {
+				t.Errorf("expected %q in:\n%s", want, out)
+			}
+		}
+	})
+
+	t.Run("refuses content it cannot parse", func(t *testing.T) {
+		for format, in := range map[string]string{"env": "A=\"unterminated\n", "json": `{"a": 1} x`, "yaml": "a: [", "": "hunter2"} {
+			if out, err := Redact(in, format); err == nil {
+				t.Errorf("%s: expected an error, got:\n%s", format, out)
+			}
+		}
+	})
+}
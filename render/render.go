@@ -0,0 +1,79 @@
+// Package render fills Go text/template files with decrypted values, for
+// programs that read their settings from a config file rather than the
+// environment:
+//
+//	# app.conf.tmpl
+//	[database]
+//	host = {{ .db.host | json }}
+//	password = {{ .db.password | json }}
+//
+// The data is the decrypted file decoded as convert.Decode does: nested
+// maps for JSON, YAML, and TOML, and flat KEY strings for .env. A key the
+// template names but the data lacks is an error, not an empty string.
+package render
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// Funcs are the functions templates may call besides text/template's own:
+//
+//	json     the value as JSON (a quoted string for strings), for JSON, TOML, and YAML values
+//	base64   standard base64 of the value's text form, as Kubernetes Secret data wants
+//	indent   prefix every line but the first with n spaces, for multiline values in YAML
+//	default  the fallback when a value is empty: {{ .port | default 5432 }}
+//	required fail with a message when a value is empty
+var Funcs = template.FuncMap{
+	"json": func(v any) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+	"base64": func(v any) string {
+		return base64.StdEncoding.EncodeToString([]byte(fmt.Sprint(v)))
+	},
+	"indent": func(n int, s string) string {
+		return strings.ReplaceAll(s, "\n", "\n"+strings.Repeat(" ", n))
+	},
+	"default": func(def, v any) any {
+		if empty(v) {
+			return def
+		}
+		return v
+	},
+	"required": func(msg string, v any) (any, error) {
+		if empty(v) {
+			return nil, fmt.Errorf("%s", msg)
+		}
+		return v, nil
+	},
+}
+
+func empty(v any) bool {
+	switch v := v.(type) {
+	case nil:
+		return true
+	case string:
+		return v == ""
+	}
+	return false
+}
+
+// Execute renders the template text, named name in errors, with data. The
+// output is returned whole, so a failure part way leaves nothing to clean
+// up.
+func Execute(name, text string, data map[string]any) ([]byte, error) {
+	t, err := template.New(name).Funcs(Funcs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	var b bytes.Buffer
+	if err := t.Execute(&b, data); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
@@ -0,0 +1,47 @@
+package render
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExecute(t *testing.T) {
+	data := map[string]any{
+		"db":   map[string]any{"host": "db.internal", "password": `p"w`, "port": int64(5432)},
+		"cert": "line one\nline two",
+		"tls":  "",
+	}
+
+	t.Run("fills nested values", func(t *testing.T) {
+		out, err := Execute("app.conf.tmpl", "host = {{ .db.host | json }}\npassword = {{ .db.password | json }}\nport = {{ .db.port }}\n", data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := "host = \"db.internal\"\npassword = \"p\\\"w\"\nport = 5432\n"
+		if string(out) != want {
+			t.Errorf("got:\n%s\nwant:\n%s", out, want)
+		}
+	})
+
+	t.Run("has helpers for config files", func(t *testing.T) {
+		out, err := Execute("t", "cert: |\n  {{ .cert | indent 2 }}\npw: {{ .db.password | base64 }}\ntls: {{ .tls | default \"off\" }}", data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := "cert: |\n  line one\n  line two\npw: cCJ3\ntls: off"
+		if string(out) != want {
+			t.Errorf("got:\n%s\nwant:\n%s", out, want)
+		}
+	})
+
+	t.Run("fails on missing and empty required values", func(t *testing.T) {
+		for _, text := range []string{"{{ .db.user }}", "{{ .nope }}", `{{ .tls | required "tls is required" }}`} {
+			if out, err := Execute("t", text, data); err == nil {
+				t.Errorf("%s: expected an error, got %q", text, out)
+			}
+		}
+		if _, err := Execute("t", `{{ .tls | required "tls is required" }}`, data); err == nil || !strings.Contains(err.Error(), "tls is required") {
+			t.Errorf("expected the message, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,208 @@
+// Package review enforces two-person control on sensitive files: a
+// CODEOWNERS-style rules file names the recipients (by label) who must
+// acknowledge every version of a matching file, and each acknowledgement
+// is an SSH-signed record in the audit log.
+package review
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	agepkg "github.com/andreweick/agepad/age"
+	"github.com/andreweick/agepad/audit"
+	"golang.org/x/crypto/ssh"
+)
+
+// DefaultRulesFile is the rules file looked for at the top of a tree.
+const DefaultRulesFile = ".agepad-reviewers"
+
+// AckAction is the audit action of an acknowledgement.
+const AckAction = "ack"
+
+// Rule requires the recipients labeled Labels to acknowledge files
+// matching Pattern.
+type Rule struct {
+	Pattern string
+	Labels  []string
+	Line    int
+}
+
+// Rules is a parsed rules file; like CODEOWNERS, the last matching rule
+// wins.
+type Rules []Rule
+
+// LoadRules reads a rules file (see ParseRules).
+func LoadRules(path string) (Rules, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("review rules: %w", err)
+	}
+	defer f.Close()
+	rs, err := ParseRules(f)
+	if err != nil {
+		return nil, fmt.Errorf("review rules: %s: %w", path, err)
+	}
+	return rs, nil
+}
+
+// ParseRules reads one rule per line, a pattern followed by the labels of
+// the recipients that must acknowledge matching files:
+//
+//	# pattern              labels
+//	*.age                  alice
+//	prod/                  alice bob
+//	prod/payments.env.age  alice bob carol
+//
+// Paths are relative to the rules file's directory. A pattern ending in
+// "/" matches everything under that directory; one without a "/" matches
+// the base name anywhere; others are globs on the whole path. Blank lines
+// and "#" comments are skipped.
+func ParseRules(r io.Reader) (Rules, error) {
+	var rs Rules
+	sc := bufio.NewScanner(r)
+	for n := 1; sc.Scan(); n++ {
+		f := strings.Fields(sc.Text())
+		if len(f) == 0 || strings.HasPrefix(f[0], "#") {
+			continue
+		}
+		if len(f) < 2 {
+			return nil, fmt.Errorf("line %d: %s names no labels", n, f[0])
+		}
+		pattern := strings.TrimPrefix(f[0], "/")
+		if _, err := path.Match(strings.TrimSuffix(pattern, "/"), ""); err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		rs = append(rs, Rule{Pattern: pattern, Labels: f[1:], Line: n})
+	}
+	return rs, sc.Err()
+}
+
+// Required returns the labels that must acknowledge the file at rel (a
+// slash-separated path relative to the rules file), or nil.
+func (rs Rules) Required(rel string) []string {
+	for i := len(rs) - 1; i >= 0; i-- {
+		if matches(rs[i].Pattern, rel) {
+			return rs[i].Labels
+		}
+	}
+	return nil
+}
+
+func matches(pattern, rel string) bool {
+	switch {
+	case strings.HasSuffix(pattern, "/"):
+		return strings.HasPrefix(rel, pattern)
+	case !strings.Contains(pattern, "/"):
+		ok, _ := path.Match(pattern, path.Base(rel))
+		return ok
+	}
+	ok, _ := path.Match(pattern, rel)
+	return ok
+}
+
+// Digest identifies one version of a file by its ciphertext, so an ack
+// covers exactly what the reviewer decrypted.
+func Digest(cipher []byte) string {
+	sum := sha256.Sum256(cipher)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// payload is what an ack signs.
+func payload(file, label, digest string, t time.Time) []byte {
+	return fmt.Appendf(nil, "agepad-ack-v1\nfile=%s\nlabel=%s\ndigest=%s\ntime=%s\n",
+		file, label, digest, t.UTC().Format(time.RFC3339Nano))
+}
+
+// Ack returns the audit event recording that the holder of signer, listed
+// as label in the recipients file, has decrypted the version digest of
+// file.
+func Ack(signer ssh.Signer, file, label, digest string) (audit.Event, error) {
+	t := time.Now().UTC()
+	data := payload(file, label, digest, t)
+	var (
+		sig *ssh.Signature
+		err error
+	)
+	if as, ok := signer.(ssh.AlgorithmSigner); ok && signer.PublicKey().Type() == ssh.KeyAlgoRSA {
+		sig, err = as.SignWithAlgorithm(rand.Reader, data, ssh.KeyAlgoRSASHA512)
+	} else {
+		sig, err = signer.Sign(rand.Reader, data)
+	}
+	if err != nil {
+		return audit.Event{}, fmt.Errorf("sign ack: %w", err)
+	}
+	return audit.Event{
+		Time:   t,
+		Action: AckAction,
+		File:   file,
+		Details: map[string]string{
+			"label":  label,
+			"digest": digest,
+			"key":    ssh.FingerprintSHA256(signer.PublicKey()),
+			"sig":    base64.StdEncoding.EncodeToString(ssh.Marshal(sig)),
+		},
+	}, nil
+}
+
+// Verify checks that e is an ack signed by pub.
+func Verify(e audit.Event, pub ssh.PublicKey) error {
+	if e.Action != AckAction {
+		return errors.New("not an ack")
+	}
+	b, err := base64.StdEncoding.DecodeString(e.Details["sig"])
+	if err != nil {
+		return fmt.Errorf("ack signature: %w", err)
+	}
+	var sig ssh.Signature
+	if err := ssh.Unmarshal(b, &sig); err != nil {
+		return fmt.Errorf("ack signature: %w", err)
+	}
+	return pub.Verify(payload(e.File, e.Details["label"], e.Details["digest"], e.Time), &sig)
+}
+
+// SigningKeys maps the labels of SSH recipients to their keys; only they
+// can sign acks, as age X25519 keys cannot sign.
+func SigningKeys(ls []agepkg.LabeledRecipient) map[string]ssh.PublicKey {
+	keys := map[string]ssh.PublicKey{}
+	for _, l := range ls {
+		if l.Label == "" || !strings.HasPrefix(l.Key, "ssh-") {
+			continue
+		}
+		if pub, _, _, _, err := ssh.ParseAuthorizedKey([]byte(l.Key)); err == nil {
+			keys[l.Label] = pub
+		}
+	}
+	return keys
+}
+
+// Missing returns the labels in required that have no valid ack of the
+// version digest of file among events. keys maps each label to the SSH
+// key its acks must be signed with; a label without one can never ack.
+func Missing(required []string, file, digest string, events []audit.Event, keys map[string]ssh.PublicKey) []string {
+	var missing []string
+	for _, label := range required {
+		pub, ok := keys[label]
+		acked := false
+		for _, e := range events {
+			if ok && e.Action == AckAction && e.File == file &&
+				e.Details["label"] == label && e.Details["digest"] == digest && Verify(e, pub) == nil {
+				acked = true
+				break
+			}
+		}
+		if !acked {
+			missing = append(missing, label)
+		}
+	}
+	return missing
+}
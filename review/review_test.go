@@ -0,0 +1,88 @@
+package review
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"strings"
+	"testing"
+
+	agepkg "github.com/andreweick/agepad/age"
+	"github.com/andreweick/agepad/audit"
+	"golang.org/x/crypto/ssh"
+)
+
+func TestReview(t *testing.T) {
+	rules, err := ParseRules(strings.NewReader("# who reviews what\n*.age alice\n/prod/ alice bob\nprod/payments.env.age carol\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer := func() ssh.Signer {
+		_, key, _ := ed25519.GenerateKey(rand.Reader)
+		s, err := ssh.NewSignerFromKey(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+	alice, bob := signer(), signer()
+	keys := map[string]ssh.PublicKey{"alice": alice.PublicKey(), "bob": bob.PublicKey()}
+
+	t.Run("applies the last matching rule", func(t *testing.T) {
+		for rel, want := range map[string]string{
+			"dev/app.env.age":       "alice",
+			"prod/db.env.age":       "alice bob",
+			"prod/payments.env.age": "carol",
+			"a/b/c/deep.age":        "alice", // base-name patterns match anywhere
+			"other/notes.txt":       "",
+		} {
+			if got := strings.Join(rules.Required(rel), " "); got != want {
+				t.Errorf("%s: expected %q, got %q", rel, want, got)
+			}
+		}
+	})
+
+	t.Run("rejects rules without labels", func(t *testing.T) {
+		if _, err := ParseRules(strings.NewReader("prod/\n")); err == nil || !strings.Contains(err.Error(), "line 1") {
+			t.Errorf("expected a line 1 error, got %v", err)
+		}
+	})
+
+	t.Run("counts signed acks of the current version", func(t *testing.T) {
+		digest := Digest([]byte("ciphertext v2"))
+		e, err := Ack(alice, "prod/db.env.age", "alice", digest)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := Verify(e, alice.PublicKey()); err != nil {
+			t.Fatalf("Verify: %v", err)
+		}
+		old, _ := Ack(bob, "prod/db.env.age", "bob", Digest([]byte("ciphertext v1")))
+		missing := Missing([]string{"alice", "bob"}, "prod/db.env.age", digest, []audit.Event{e, old}, keys)
+		if strings.Join(missing, " ") != "bob" {
+			t.Errorf("expected bob's ack of an older version not to count, missing %v", missing)
+		}
+	})
+
+	t.Run("rejects forged and tampered acks", func(t *testing.T) {
+		digest := Digest([]byte("ciphertext"))
+		forged, _ := Ack(alice, "prod/db.env.age", "bob", digest) // alice signing as bob
+		moved, _ := Ack(bob, "dev/db.env.age", "bob", digest)
+		moved.File = "prod/db.env.age"
+		if m := Missing([]string{"bob"}, "prod/db.env.age", digest, []audit.Event{forged, moved}, keys); len(m) != 1 {
+			t.Errorf("expected bob still missing, got %v", m)
+		}
+	})
+
+	t.Run("takes signing keys from SSH recipients", func(t *testing.T) {
+		pub := strings.TrimSpace(string(ssh.MarshalAuthorizedKey(alice.PublicKey())))
+		ls, err := agepkg.ParseLabeledRecipients(strings.NewReader(
+			"age1ql3z7hjy54pw3hyww5ayyfg7zqgvc7w3j2elw8zmrj2kg5sfn9aqmcac8p # carol\n" + pub + " # alice\n"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := SigningKeys(ls)
+		if len(got) != 1 || got["alice"] == nil {
+			t.Errorf("expected only alice's key, got %v", got)
+		}
+	})
+}
@@ -0,0 +1,143 @@
+package rotation
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/andreweick/agepad/walk"
+)
+
+// CheckpointPath returns where a rotation under root records progress.
+func CheckpointPath(root string) string {
+	return filepath.Join(root, walk.CacheDir, "rotate.checkpoint")
+}
+
+// checkpointLine is one JSON line of a checkpoint: the first names the
+// recipient set, every later one a file rotated to it.
+type checkpointLine struct {
+	Recipients string `json:"recipients,omitempty"`
+	Path       string `json:"path,omitempty"`
+	SHA256     string `json:"sha256,omitempty"` // ciphertext written by the rotation
+}
+
+// Checkpoint records which files a rotation has finished, so an
+// interrupted run can resume. It holds paths and hashes only.
+type Checkpoint struct {
+	path string
+	set  string
+
+	mu   sync.Mutex
+	f    *os.File
+	done map[string]string
+}
+
+// recipientSet fingerprints the plan's recipients, so a checkpoint is never
+// resumed with a different set.
+func recipientSet(p *Plan) string {
+	sum := sha256.Sum256([]byte(strings.Join(p.Recipients, "\n")))
+	return hex.EncodeToString(sum[:])
+}
+
+// OpenCheckpoint starts a checkpoint at path for p. With resume, the files
+// an earlier run of the same recipient set finished are loaded and kept;
+// otherwise any earlier checkpoint is discarded.
+func OpenCheckpoint(path string, p *Plan, resume bool) (*Checkpoint, error) {
+	c := &Checkpoint{path: path, set: recipientSet(p), done: map[string]string{}}
+	if resume {
+		if err := c.load(); err != nil {
+			return nil, err
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, fmt.Errorf("checkpoint: %w", err)
+	}
+	flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if resume {
+		flags = os.O_WRONLY | os.O_CREATE | os.O_APPEND
+	}
+	f, err := os.OpenFile(path, flags, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("checkpoint: %w", err)
+	}
+	c.f = f
+	if !resume || len(c.done) == 0 {
+		if err := c.write(checkpointLine{Recipients: c.set}); err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+func (c *Checkpoint) load() error {
+	f, err := os.Open(c.path)
+	if os.IsNotExist(err) {
+		return fmt.Errorf("checkpoint: nothing to resume (%s does not exist)", c.path)
+	}
+	if err != nil {
+		return fmt.Errorf("checkpoint: %w", err)
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	first := true
+	for sc.Scan() {
+		var l checkpointLine
+		if err := json.Unmarshal(sc.Bytes(), &l); err != nil {
+			continue // a line torn by the interruption
+		}
+		if first {
+			if l.Recipients != c.set {
+				return fmt.Errorf("checkpoint: %s is for a different recipient set; rotate without --resume", c.path)
+			}
+			first = false
+			continue
+		}
+		c.done[l.Path] = l.SHA256
+	}
+	return sc.Err()
+}
+
+func (c *Checkpoint) write(l checkpointLine) error {
+	b, err := json.Marshal(l)
+	if err != nil {
+		return err
+	}
+	if _, err := c.f.Write(append(b, '\n')); err != nil {
+		return fmt.Errorf("checkpoint: %w", err)
+	}
+	return nil
+}
+
+// Done returns the ciphertext hash recorded for path by an earlier run.
+func (c *Checkpoint) Done(path string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	h, ok := c.done[path]
+	return h, ok
+}
+
+// Record notes that path was rotated and now has ciphertext hash h.
+func (c *Checkpoint) Record(path, h string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.done[path] = h
+	return c.write(checkpointLine{Path: path, SHA256: h})
+}
+
+// Close closes the checkpoint, removing it when the rotation completed.
+func (c *Checkpoint) Close(completed bool) error {
+	err := c.f.Close()
+	if completed {
+		if rerr := os.Remove(c.path); rerr != nil && err == nil {
+			err = rerr
+		}
+	}
+	return err
+}
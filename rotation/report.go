@@ -0,0 +1,74 @@
+package rotation
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+)
+
+// Report is the outcome of a rotation, file by file, for pipelines that
+// parse it (`rotate --report json`). Like a plan it holds no plaintext.
+type Report struct {
+	Version    int      `json:"version"`
+	Root       string   `json:"root"`
+	Recipients int      `json:"recipients"` // in the new set
+	Rotated    int      `json:"rotated"`
+	Skipped    int      `json:"skipped"`
+	Failed     int      `json:"failed"`
+	Files      []Result `json:"files"` // in plan order
+}
+
+// Result statuses.
+const (
+	StatusRotated = "rotated"
+	StatusSkipped = "skipped" // already rotated by an interrupted run (--resume)
+	StatusFailed  = "failed"
+)
+
+// Result is one file's outcome. A failed file is left as it was, so
+// RecipientsAfter is then its old count.
+type Result struct {
+	Path             string `json:"path"`
+	Status           string `json:"status"`
+	Error            string `json:"error,omitempty"`
+	RecipientsBefore int    `json:"recipients_before"`
+	RecipientsAfter  int    `json:"recipients_after"`
+	DurationMS       int64  `json:"duration_ms"`
+}
+
+// NewReport starts a report for p, rotating to recipients keys.
+func NewReport(p *Plan, recipients int) *Report {
+	return &Report{Version: version, Root: p.Root, Recipients: recipients}
+}
+
+// Add records f's outcome: err is nil for a rotated or skipped file.
+func (r *Report) Add(f File, skipped bool, err error, took time.Duration) {
+	res := Result{
+		Path:             f.Path,
+		Status:           StatusRotated,
+		RecipientsBefore: len(f.StanzasBefore),
+		RecipientsAfter:  r.Recipients,
+		DurationMS:       took.Milliseconds(),
+	}
+	switch {
+	case err != nil:
+		res.Status, res.Error, res.RecipientsAfter = StatusFailed, err.Error(), res.RecipientsBefore
+		r.Failed++
+	case skipped:
+		res.Status = StatusSkipped
+		r.Skipped++
+	default:
+		r.Rotated++
+	}
+	r.Files = append(r.Files, res)
+}
+
+// WriteJSON writes r as indented JSON.
+func (r *Report) WriteJSON(w io.Writer) error {
+	b, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(b, '\n'))
+	return err
+}
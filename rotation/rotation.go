@@ -0,0 +1,307 @@
+// Package rotation describes a recipients rotation as a plan that can be
+// reviewed and then applied verbatim (`rotate --plan` / `rotate --apply`).
+// A plan holds key material only in public form and never any plaintext.
+package rotation
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"filippo.io/age"
+	agepkg "github.com/andreweick/agepad/age"
+)
+
+// version is bumped when the plan format changes incompatibly.
+const version = 1
+
+// Plan is the exact set of changes a rotation makes.
+type Plan struct {
+	Version    int       `json:"version"`
+	CreatedAt  time.Time `json:"created_at"`
+	Root       string    `json:"root"`
+	From       string    `json:"from_recipients_file"`
+	To         string    `json:"to_recipients_file"`
+	Recipients []string  `json:"recipients"` // the new set, applied as written here
+	Added      []string  `json:"added"`
+	Removed    []string  `json:"removed"`
+	Prune      bool      `json:"prune"`
+	Files      []File    `json:"files"`
+
+	// UpdateRecipientsFile is set for a plan made from --add-recipient and
+	// --remove-recipient: once every file is rotated, the same change is
+	// made to the recipients file (To, which is also From).
+	UpdateRecipientsFile bool `json:"update_recipients_file,omitempty"`
+}
+
+// File is the planned change to one file. SHA256 pins the ciphertext the
+// plan was made against, so a file edited after review is not rewritten.
+type File struct {
+	Path          string   `json:"path"`
+	SHA256        string   `json:"sha256"`
+	StanzasBefore []string `json:"stanzas_before"` // stanza types in the current header
+	ArmoredBefore bool     `json:"armored_before"`
+	Armor         bool     `json:"armor"`
+}
+
+// FormatChange describes an armor change, or "" when there is none.
+func (f File) FormatChange() string {
+	switch {
+	case f.ArmoredBefore == f.Armor:
+		return ""
+	case f.Armor:
+		return "binary -> armored"
+	default:
+		return "armored -> binary"
+	}
+}
+
+// New plans re-encrypting files to the recipients in toFile. fromFile (may
+// be missing) is only compared against to report added and removed
+// recipients. armor sets the output format; nil keeps each file's own.
+func New(root, fromFile, toFile string, files []string, prune bool, armor *bool) (*Plan, error) {
+	to, err := lines(toFile)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := agepkg.LoadRecipients(toFile); err != nil {
+		return nil, err
+	}
+	from, _ := lines(fromFile)
+	return newPlan(root, fromFile, toFile, from, to, files, prune, armor)
+}
+
+// NewDelta plans re-encrypting files to the recipients in fromFile with
+// the add lines added and the recipients in remove (keys, labels
+// optional) taken out. The plan then makes the same change to fromFile.
+// Headers do not name X25519 keys, so the recipients file is the base.
+func NewDelta(root, fromFile string, add, remove []string, files []string, prune bool, armor *bool) (*Plan, error) {
+	from, err := lines(fromFile)
+	if err != nil {
+		return nil, err
+	}
+	to := slices.Clone(from)
+	for _, r := range remove {
+		i := slices.IndexFunc(to, func(l string) bool { return lineKey(l) == lineKey(r) })
+		if i < 0 {
+			return nil, fmt.Errorf("--remove-recipient %s: not in %s", lineKey(r), fromFile)
+		}
+		to = slices.Delete(to, i, i+1)
+	}
+	for _, r := range add {
+		r = strings.TrimSpace(r)
+		if _, err := agepkg.ParseRecipients(strings.NewReader(r)); err != nil {
+			return nil, fmt.Errorf("--add-recipient %s: %w", lineKey(r), err)
+		}
+		if slices.ContainsFunc(to, func(l string) bool { return lineKey(l) == lineKey(r) }) {
+			return nil, fmt.Errorf("--add-recipient %s: already in %s", lineKey(r), fromFile)
+		}
+		to = append(to, r)
+	}
+	if len(to) == 0 {
+		return nil, fmt.Errorf("removing every recipient in %s would leave no one able to decrypt", fromFile)
+	}
+	p, err := newPlan(root, fromFile, fromFile, from, to, files, prune, armor)
+	if err != nil {
+		return nil, err
+	}
+	p.UpdateRecipientsFile = true
+	return p, nil
+}
+
+func newPlan(root, fromFile, toFile string, from, to, files []string, prune bool, armor *bool) (*Plan, error) {
+	p := &Plan{
+		Version:    version,
+		CreatedAt:  time.Now().UTC(),
+		Root:       root,
+		From:       fromFile,
+		To:         toFile,
+		Recipients: to,
+		Added:      []string{},
+		Removed:    []string{},
+		Prune:      prune,
+	}
+	for _, r := range to {
+		if !slices.Contains(from, r) {
+			p.Added = append(p.Added, r)
+		}
+	}
+	for _, r := range from {
+		if !slices.Contains(to, r) {
+			p.Removed = append(p.Removed, r)
+		}
+	}
+	for _, f := range files {
+		b, err := os.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+		h, err := agepkg.InspectHeaderBytes(b)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", f, err)
+		}
+		out := h.Armored
+		if armor != nil {
+			out = *armor
+		}
+		p.Files = append(p.Files, File{
+			Path:          f,
+			SHA256:        Hash(b),
+			StanzasBefore: h.StanzaTypes(),
+			ArmoredBefore: h.Armored,
+			Armor:         out,
+		})
+	}
+	return p, nil
+}
+
+// Hash returns the ciphertext hash File.SHA256 is compared against.
+func Hash(ciphertext []byte) string {
+	sum := sha256.Sum256(ciphertext)
+	return hex.EncodeToString(sum[:])
+}
+
+// ParseRecipients parses the plan's recipient set.
+func (p *Plan) ParseRecipients() ([]age.Recipient, error) {
+	rs, err := agepkg.ParseRecipients(strings.NewReader(strings.Join(p.Recipients, "\n")))
+	if err != nil {
+		return nil, fmt.Errorf("plan recipients: %w", err)
+	}
+	if len(rs) == 0 {
+		return nil, fmt.Errorf("plan has no recipients")
+	}
+	return rs, nil
+}
+
+// Write saves p as indented JSON.
+func (p *Plan) Write(path string) error {
+	b, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(b, '\n'), 0o644)
+}
+
+// Read loads a plan written by Write.
+func Read(path string) (*Plan, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var p Plan
+	if err := json.Unmarshal(b, &p); err != nil {
+		return nil, fmt.Errorf("plan %s: %w", path, err)
+	}
+	if p.Version != version {
+		return nil, fmt.Errorf("plan %s: version %d is not supported (want %d)", path, p.Version, version)
+	}
+	return &p, nil
+}
+
+// WriteRecipientsFile makes the plan's change to its recipients file:
+// lines of removed recipients are dropped and added ones appended, keeping
+// comments and the file's permissions.
+func (p *Plan) WriteRecipientsFile() error {
+	info, err := os.Stat(p.To)
+	if err != nil {
+		return err
+	}
+	b, err := os.ReadFile(p.To)
+	if err != nil {
+		return err
+	}
+	var out []string
+	for _, l := range strings.SplitAfter(string(b), "\n") {
+		if !slices.ContainsFunc(p.Removed, func(r string) bool { return lineKey(r) == lineKey(l) }) {
+			out = append(out, l)
+		}
+	}
+	next := strings.Join(out, "")
+	if next != "" && !strings.HasSuffix(next, "\n") {
+		next += "\n"
+	}
+	for _, r := range p.Added {
+		next += r + "\n"
+	}
+	if err := agepkg.AtomicWrite(p.To, []byte(next)); err != nil {
+		return err
+	}
+	return os.Chmod(p.To, info.Mode().Perm())
+}
+
+// ReplaceFrom makes the plan's From file a copy of To, so the roster people
+// encrypt to matches the rotated files. The old From is first kept as
+// From.<UTC time>.bak, whose path is returned ("" if From did not exist).
+// To must still list exactly the plan's recipients; a roster edited since
+// the plan was made is refused.
+func (p *Plan) ReplaceFrom(now time.Time) (string, error) {
+	if p.From == p.To {
+		return "", fmt.Errorf("%s is already the recipients file", p.To)
+	}
+	to, err := lines(p.To)
+	if err != nil {
+		return "", err
+	}
+	if !slices.Equal(to, p.Recipients) {
+		return "", fmt.Errorf("%s changed after the plan was made; not copying it over %s", p.To, p.From)
+	}
+	b, err := os.ReadFile(p.To)
+	if err != nil {
+		return "", err
+	}
+	info, err := os.Stat(p.To)
+	if err != nil {
+		return "", err
+	}
+	bak := ""
+	if old, err := os.ReadFile(p.From); err == nil {
+		if info, err = os.Stat(p.From); err != nil {
+			return "", err
+		}
+		bak = p.From + "." + now.UTC().Format("20060102T150405Z") + ".bak"
+		if err := agepkg.AtomicWrite(bak, old); err != nil {
+			return "", err
+		}
+		if err := os.Chmod(bak, info.Mode().Perm()); err != nil {
+			return "", err
+		}
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return "", err
+	}
+	if err := agepkg.AtomicWrite(p.From, b); err != nil {
+		return bak, err
+	}
+	return bak, os.Chmod(p.From, info.Mode().Perm())
+}
+
+// lineKey is a recipients file line without its "# label" comment.
+func lineKey(l string) string {
+	l = strings.TrimSpace(l)
+	if i := strings.IndexByte(l, '#'); i > 0 && (l[i-1] == ' ' || l[i-1] == '\t') {
+		l = strings.TrimSpace(l[:i])
+	}
+	return l
+}
+
+// lines returns the recipient lines of a recipients file.
+func lines(path string) ([]string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var out []string
+	for _, l := range strings.Split(string(b), "\n") {
+		l = strings.TrimSpace(l)
+		if l != "" && !strings.HasPrefix(l, "#") {
+			out = append(out, l)
+		}
+	}
+	return out, nil
+}
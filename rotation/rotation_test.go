@@ -0,0 +1,258 @@
+package rotation
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"filippo.io/age"
+	agepkg "github.com/andreweick/agepad/age"
+)
+
+func TestPlan(t *testing.T) {
+	alice, _ := age.GenerateX25519Identity()
+	bob, _ := age.GenerateX25519Identity()
+	dir := t.TempDir()
+	from := filepath.Join(dir, "from")
+	to := filepath.Join(dir, "to")
+	write := func(path, content string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatalf("failed to write %s: %v", path, err)
+		}
+	}
+	write(from, alice.Recipient().String()+"\n"+bob.Recipient().String()+"\n")
+	write(to, "# team\n"+alice.Recipient().String()+"\n")
+	file := filepath.Join(dir, "app.env.age")
+	if err := agepkg.AtomicEncryptWrite(file, []byte("A=1\n"), []age.Recipient{alice.Recipient(), bob.Recipient()}, false); err != nil {
+		t.Fatalf("failed to encrypt: %v", err)
+	}
+
+	armor := true
+	p, err := New(dir, from, to, []string{file}, true, &armor)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	t.Run("lists added and removed recipients", func(t *testing.T) {
+		if len(p.Added) != 0 || len(p.Removed) != 1 || p.Removed[0] != bob.Recipient().String() {
+			t.Errorf("unexpected changes: +%v -%v", p.Added, p.Removed)
+		}
+		if len(p.Recipients) != 1 {
+			t.Errorf("expected comments to be skipped, got %v", p.Recipients)
+		}
+	})
+
+	t.Run("records each file's header and format change", func(t *testing.T) {
+		f := p.Files[0]
+		if len(f.StanzasBefore) != 2 || f.StanzasBefore[0] != "X25519" {
+			t.Errorf("unexpected stanzas: %v", f.StanzasBefore)
+		}
+		if f.FormatChange() != "binary -> armored" {
+			t.Errorf("unexpected format change: %q", f.FormatChange())
+		}
+		b, _ := os.ReadFile(file)
+		if f.SHA256 != Hash(b) {
+			t.Error("expected the plan to pin the current ciphertext")
+		}
+		kept, err := New(dir, from, to, []string{file}, false, nil)
+		if err != nil || kept.Files[0].Armor || kept.Files[0].FormatChange() != "" {
+			t.Errorf("expected a nil armor to keep the binary format, got %+v (%v)", kept.Files, err)
+		}
+	})
+
+	t.Run("round-trips through JSON", func(t *testing.T) {
+		path := filepath.Join(dir, "plan.json")
+		if err := p.Write(path); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+		raw, _ := os.ReadFile(path)
+		if strings.Contains(string(raw), "A=1") {
+			t.Error("plan must not contain plaintext")
+		}
+		got, err := Read(path)
+		if err != nil {
+			t.Fatalf("Read failed: %v", err)
+		}
+		rs, err := got.ParseRecipients()
+		if err != nil || len(rs) != 1 {
+			t.Errorf("expected 1 recipient, got %d (%v)", len(rs), err)
+		}
+	})
+
+	t.Run("rejects other plan versions", func(t *testing.T) {
+		path := filepath.Join(dir, "old.json")
+		write(path, `{"version": 99}`)
+		if _, err := Read(path); err == nil {
+			t.Error("expected a version error")
+		}
+	})
+
+	t.Run("requires a valid recipients file", func(t *testing.T) {
+		bad := filepath.Join(dir, "bad")
+		write(bad, "not-a-recipient\n")
+		if _, err := New(dir, from, bad, nil, false, nil); err == nil {
+			t.Error("expected an error for invalid recipients")
+		}
+	})
+
+	t.Run("plans a change to the recipients file", func(t *testing.T) {
+		carol, _ := age.GenerateX25519Identity()
+		recips := filepath.Join(dir, "recipients")
+		write(recips, "# team\n"+alice.Recipient().String()+" # alice\n"+bob.Recipient().String()+" # bob\n")
+		p, err := NewDelta(dir, recips, []string{carol.Recipient().String() + " # carol"}, []string{bob.Recipient().String()}, []string{file}, true, nil)
+		if err != nil {
+			t.Fatalf("NewDelta failed: %v", err)
+		}
+		if !p.UpdateRecipientsFile || p.To != recips || len(p.Recipients) != 2 || len(p.Added) != 1 || len(p.Removed) != 1 {
+			t.Fatalf("got plan %+v", p)
+		}
+		if err := p.WriteRecipientsFile(); err != nil {
+			t.Fatal(err)
+		}
+		b, _ := os.ReadFile(recips)
+		if want := "# team\n" + alice.Recipient().String() + " # alice\n" + carol.Recipient().String() + " # carol\n"; string(b) != want {
+			t.Errorf("got recipients file %q, want %q", b, want)
+		}
+		if info, _ := os.Stat(recips); info.Mode().Perm() != 0o600 {
+			t.Errorf("expected the mode kept, got %v", info.Mode().Perm())
+		}
+	})
+
+	t.Run("replaces the old roster with the new one", func(t *testing.T) {
+		roster := filepath.Join(dir, "roster")
+		old := alice.Recipient().String() + "\n" + bob.Recipient().String() + "\n"
+		write(roster, old)
+		p, err := New(dir, roster, to, []string{file}, false, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		bak, err := p.ReplaceFrom(time.Date(2026, 3, 1, 9, 30, 0, 0, time.UTC))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := roster + ".20260301T093000Z.bak"; bak != want {
+			t.Errorf("got backup %s, want %s", bak, want)
+		}
+		if b, _ := os.ReadFile(bak); string(b) != old {
+			t.Errorf("expected the old roster kept, got %q", b)
+		}
+		b, _ := os.ReadFile(roster)
+		if want, _ := os.ReadFile(to); !bytes.Equal(b, want) {
+			t.Errorf("expected a copy of %s, got %q", to, b)
+		}
+
+		write(to, bob.Recipient().String()+"\n")
+		defer write(to, "# team\n"+alice.Recipient().String()+"\n")
+		if _, err := p.ReplaceFrom(time.Now()); err == nil || !strings.Contains(err.Error(), "changed after the plan") {
+			t.Errorf("expected an edited roster to be refused, got %v", err)
+		}
+	})
+
+	t.Run("refuses deltas that cannot apply", func(t *testing.T) {
+		for name, c := range map[string][2][]string{
+			"removing a missing key": {nil, {"age1notthere"}},
+			"adding a present key":   {{alice.Recipient().String()}, nil},
+			"adding a malformed key": {{"not-a-recipient"}, nil},
+			"removing everyone":      {nil, {alice.Recipient().String(), bob.Recipient().String()}},
+		} {
+			if _, err := NewDelta(dir, from, c[0], c[1], nil, false, nil); err == nil {
+				t.Errorf("%s: expected an error", name)
+			}
+		}
+	})
+}
+
+func TestCheckpoint(t *testing.T) {
+	dir := t.TempDir()
+	path := CheckpointPath(dir)
+	p := &Plan{Recipients: []string{"age1a"}}
+
+	t.Run("resume needs an earlier run", func(t *testing.T) {
+		if _, err := OpenCheckpoint(path, p, true); err == nil {
+			t.Error("expected an error without a checkpoint")
+		}
+	})
+
+	t.Run("records and resumes completed files", func(t *testing.T) {
+		c, err := OpenCheckpoint(path, p, false)
+		if err != nil {
+			t.Fatalf("OpenCheckpoint failed: %v", err)
+		}
+		if err := c.Record("a.age", "h1"); err != nil {
+			t.Fatalf("Record failed: %v", err)
+		}
+		c.Close(false)
+		// Simulate a line torn by the interruption.
+		f, _ := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o600)
+		f.WriteString(`{"path":"b.age","sha`)
+		f.Close()
+
+		c, err = OpenCheckpoint(path, p, true)
+		if err != nil {
+			t.Fatalf("resume failed: %v", err)
+		}
+		if h, ok := c.Done("a.age"); !ok || h != "h1" {
+			t.Errorf("expected a.age to be done, got %q %v", h, ok)
+		}
+		if _, ok := c.Done("b.age"); ok {
+			t.Error("expected the torn line to be ignored")
+		}
+		if err := c.Close(true); err != nil {
+			t.Fatalf("Close failed: %v", err)
+		}
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Error("expected a completed checkpoint to be removed")
+		}
+	})
+
+	t.Run("refuses a different recipient set", func(t *testing.T) {
+		c, err := OpenCheckpoint(path, p, false)
+		if err != nil {
+			t.Fatalf("OpenCheckpoint failed: %v", err)
+		}
+		c.Close(false)
+		if _, err := OpenCheckpoint(path, &Plan{Recipients: []string{"age1b"}}, true); err == nil {
+			t.Error("expected a recipient set mismatch")
+		}
+	})
+}
+
+func TestReport(t *testing.T) {
+	p := &Plan{Root: "secrets", Files: []File{
+		{Path: "a.env.age", StanzasBefore: []string{"X25519"}},
+		{Path: "b.env.age", StanzasBefore: []string{"X25519", "X25519"}},
+		{Path: "c.env.age", StanzasBefore: []string{"X25519", "X25519"}},
+	}}
+	r := NewReport(p, 3)
+	r.Add(p.Files[0], false, nil, 1500*time.Millisecond)
+	r.Add(p.Files[1], false, errors.New("decrypt failed"), 0)
+	r.Add(p.Files[2], true, nil, 0)
+
+	var b bytes.Buffer
+	if err := r.WriteJSON(&b); err != nil {
+		t.Fatal(err)
+	}
+	var got Report
+	if err := json.Unmarshal(b.Bytes(), &got); err != nil {
+		t.Fatalf("report is not JSON: %v", err)
+	}
+	if got.Rotated != 1 || got.Failed != 1 || got.Skipped != 1 || len(got.Files) != 3 {
+		t.Fatalf("got %+v", got)
+	}
+	want := []Result{
+		{Path: "a.env.age", Status: StatusRotated, RecipientsBefore: 1, RecipientsAfter: 3, DurationMS: 1500},
+		{Path: "b.env.age", Status: StatusFailed, Error: "decrypt failed", RecipientsBefore: 2, RecipientsAfter: 2},
+		{Path: "c.env.age", Status: StatusSkipped, RecipientsBefore: 2, RecipientsAfter: 3},
+	}
+	for i, w := range want {
+		if got.Files[i] != w {
+			t.Errorf("file %d: got %+v, want %+v", i, got.Files[i], w)
+		}
+	}
+}
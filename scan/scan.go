@@ -0,0 +1,225 @@
+// Package scan looks for secrets about to be committed in plaintext:
+// private key blocks and age identities, credentials in .env files, and
+// long random-looking tokens anywhere else. It also checks that files named
+// .age really hold age ciphertext. Findings name the file, line, and rule,
+// never the value.
+//
+// A line containing "agepad:allow" is not scanned, for test fixtures and
+// documentation examples.
+package scan
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	agepkg "github.com/andreweick/agepad/age"
+	"github.com/andreweick/agepad/dotenv"
+)
+
+// Rules a Finding may report.
+const (
+	RuleNotEncrypted  = "not-encrypted"  // named .age but not age ciphertext
+	RuleDecryptedCopy = "decrypted-copy" // plaintext next to a tracked .age file of the same name
+	RulePrivateKey    = "private-key"    // PEM private key block or age identity
+	RuleEnvSecret     = "env-secret"     // .env assignment to a credential-like key
+	RuleEntropy       = "high-entropy"   // long token with random-looking characters
+)
+
+// Allow marks a line the scanner skips.
+const Allow = "agepad:allow"
+
+// Tokens of MinTokenLength characters or more are reported when their
+// entropy is at least MinRandomness of the most a token that long can have
+// (log2 of its length, up to 64 characters). Random base64 tokens score
+// above it 99 times in 100; words and identifiers, which repeat letters,
+// mostly score below.
+const (
+	MinTokenLength = 20
+	MinRandomness  = 0.84
+)
+
+// Finding is one suspected secret.
+type Finding struct {
+	Path   string
+	Line   int // 1-based; 0 for the whole file
+	Rule   string
+	Detail string
+}
+
+func (f Finding) String() string {
+	if f.Line == 0 {
+		return fmt.Sprintf("%s: %s: %s", f.Path, f.Rule, f.Detail)
+	}
+	return fmt.Sprintf("%s:%d: %s: %s", f.Path, f.Line, f.Rule, f.Detail)
+}
+
+// lockfiles are full of hashes by design.
+var lockfiles = []string{"go.sum", "package-lock.json", "yarn.lock", "pnpm-lock.yaml", "Cargo.lock", "poetry.lock", "Gemfile.lock", "composer.lock", "flake.lock"}
+
+// envKeyWords mark a .env key as holding a credential.
+var envKeyWords = []string{"PASSWORD", "PASSWD", "SECRET", "TOKEN", "API_KEY", "APIKEY", "PRIVATE_KEY", "ACCESS_KEY", "CREDENTIAL"}
+
+var (
+	privateKeyRE = regexp.MustCompile(`-----BEGIN ([A-Z0-9]+ )*PRIVATE KEY( BLOCK)?-----|AGE-SECRET-KEY-1[0-9A-Z]{20,}|AGE-PLUGIN-[0-9A-Z-]+-1[0-9A-Z]{20,}`)
+	pemBeginRE   = regexp.MustCompile(`^-----BEGIN [A-Z0-9 ]+-----`)
+	pemEndRE     = regexp.MustCompile(`^-----END [A-Z0-9 ]+-----`)
+	tokenRE      = regexp.MustCompile(fmt.Sprintf(`[A-Za-z0-9+/_=-]{%d,}`, MinTokenLength))
+	// cipherRE matches ciphertext inside text: the values of an
+	// `agepad encrypt --values` file and its data key block, which JSON
+	// holds on one line.
+	cipherRE = regexp.MustCompile(`ENC\[[a-z]+:[A-Za-z0-9+/]+=*\]|-----BEGIN AGE ENCRYPTED FILE-----.*?-----END AGE ENCRYPTED FILE-----`)
+)
+
+// File scans content, the version of path about to be committed. tracked
+// reports whether another path is in the repository, to spot a decrypted
+// copy beside its .age file; nil skips that check.
+func File(path string, content []byte, tracked func(path string) bool) []Finding {
+	_, err := agepkg.InspectHeaderBytes(content)
+	encrypted := err == nil
+	if strings.HasSuffix(path, ".age") {
+		if !encrypted {
+			return []Finding{{Path: path, Rule: RuleNotEncrypted, Detail: "named .age but not age ciphertext"}}
+		}
+		return nil
+	}
+	if encrypted || binary(content) {
+		// Ciphertext under another name (as git-clean stores it) or
+		// not text at all.
+		return nil
+	}
+
+	var out []Finding
+	if tracked != nil && tracked(path+".age") {
+		out = append(out, Finding{Path: path, Rule: RuleDecryptedCopy, Detail: "plaintext beside " + filepath.Base(path) + ".age"})
+	}
+	lines := strings.Split(string(content), "\n")
+	skip := map[int]bool{}
+	for i, l := range lines {
+		if strings.Contains(l, Allow) {
+			skip[i+1] = true
+		}
+	}
+	if isEnv(path) {
+		out = append(out, envSecrets(path, string(content), skip)...)
+	}
+	checkTokens := !slices.Contains(lockfiles, filepath.Base(path))
+	inPEM := false
+	for i, l := range lines {
+		n := i + 1
+		t := strings.TrimSpace(l)
+		if skip[n] {
+			inPEM = inPEM || pemBeginRE.MatchString(t)
+			continue
+		}
+		if m := privateKeyRE.FindString(l); m != "" {
+			detail := "private key block"
+			if strings.HasPrefix(m, "AGE-") {
+				detail = "age identity"
+			}
+			out = append(out, Finding{Path: path, Line: n, Rule: RulePrivateKey, Detail: detail})
+		}
+		switch {
+		case pemBeginRE.MatchString(t):
+			// Certificates and key bodies are base64; the BEGIN line
+			// already said what the block is.
+			inPEM = true
+			continue
+		case pemEndRE.MatchString(t):
+			inPEM = false
+			continue
+		}
+		if inPEM || !checkTokens {
+			continue
+		}
+		for _, tok := range tokenRE.FindAllString(cipherRE.ReplaceAllString(l, ""), -1) {
+			if e := entropy(tok); randomLooking(tok) && e >= MinRandomness*math.Log2(float64(min(len(tok), 64))) {
+				out = append(out, Finding{Path: path, Line: n, Rule: RuleEntropy, Detail: fmt.Sprintf("%d-character token, %.1f bits per character", len(tok), e)})
+				skip[n] = true
+				break
+			}
+		}
+	}
+	slices.SortStableFunc(out, func(a, b Finding) int { return a.Line - b.Line })
+	return out
+}
+
+// envSecrets reports credential-like keys assigned a literal value,
+// marking their lines in skip so the token check does not report them
+// again.
+func envSecrets(path, content string, skip map[int]bool) []Finding {
+	entries, err := dotenv.Parse(content)
+	if err != nil {
+		return nil
+	}
+	var out []Finding
+	for _, e := range entries {
+		if skip[e.Line] || !credentialKey(e.Key) || placeholder(e.Value) {
+			continue
+		}
+		out = append(out, Finding{Path: path, Line: e.Line, Rule: RuleEnvSecret, Detail: e.Key + " has a value"})
+		for n := e.Line; n <= e.EndLine; n++ {
+			skip[n] = true
+		}
+	}
+	return out
+}
+
+// isEnv reports whether path names a dotenv file: .env, .env.local,
+// app.env, and so on.
+func isEnv(path string) bool {
+	base := filepath.Base(path)
+	return base == ".env" || strings.HasPrefix(base, ".env.") || strings.HasSuffix(base, ".env")
+}
+
+func credentialKey(key string) bool {
+	key = strings.ToUpper(key)
+	return slices.ContainsFunc(envKeyWords, func(w string) bool { return strings.Contains(key, w) })
+}
+
+// placeholder reports values that are not secrets themselves: empty,
+// references to other variables, <fill me in>, and xxxx or ****.
+func placeholder(v string) bool {
+	v = strings.TrimSpace(v)
+	switch {
+	case v == "", strings.HasPrefix(v, "$"), strings.HasPrefix(v, "<") && strings.HasSuffix(v, ">"):
+		return true
+	}
+	return strings.Count(v, v[:1]) == len(v)
+}
+
+// randomLooking reports whether tok mixes digits with upper and lower case
+// letters, as generated tokens do and words and identifiers mostly do not.
+// SSH public keys (base64 starting AAAA) are public.
+func randomLooking(tok string) bool {
+	if strings.HasPrefix(tok, "AAAA") {
+		return false
+	}
+	return strings.ContainsAny(tok, "0123456789") &&
+		strings.ContainsAny(tok, "ABCDEFGHIJKLMNOPQRSTUVWXYZ") &&
+		strings.ContainsAny(tok, "abcdefghijklmnopqrstuvwxyz")
+}
+
+// entropy is the Shannon entropy of s in bits per character.
+func entropy(s string) float64 {
+	counts := map[rune]int{}
+	for _, r := range s {
+		counts[r]++
+	}
+	var h float64
+	for _, c := range counts {
+		p := float64(c) / float64(len(s))
+		h -= p * math.Log2(p)
+	}
+	return h
+}
+
+// binary reports content git would treat as binary: a NUL in the first
+// 8000 bytes.
+func binary(content []byte) bool {
+	return bytes.IndexByte(content[:min(len(content), 8000)], 0) >= 0
+}
@@ -0,0 +1,137 @@
+// Package sealed keeps text encrypted in RAM under an ephemeral, process-local
+// key so that a full plaintext copy only exists for as long as it is needed.
+//
+// Text is sealed line by line, which lets callers decrypt just the region
+// they are about to render or edit instead of the whole buffer.
+package sealed
+
+import (
+	"crypto/cipher"
+	"crypto/rand"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// Buffer holds line-sealed text. The zero value is not usable; use New.
+type Buffer struct {
+	aead  cipher.AEAD
+	lines [][]byte // nonce || ciphertext per line
+}
+
+// New creates an empty buffer with a freshly generated ephemeral key.
+// The key never leaves the process and is discarded with the buffer.
+func New() (*Buffer, error) {
+	key := make([]byte, chacha20poly1305.KeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("sealed: generate key: %w", err)
+	}
+	aead, err := chacha20poly1305.NewX(key)
+	wipe(key)
+	if err != nil {
+		return nil, fmt.Errorf("sealed: init cipher: %w", err)
+	}
+	return &Buffer{aead: aead}, nil
+}
+
+// Seal replaces the buffer contents with text.
+func (b *Buffer) Seal(text string) error {
+	lines, err := b.seal(strings.Split(text, "\n"))
+	if err != nil {
+		return err
+	}
+	b.lines = lines
+	return nil
+}
+
+// Splice replaces lines [start, end), clamped to the buffer bounds, with
+// lines. Only the new lines are sealed; the others stay as they are.
+func (b *Buffer) Splice(start, end int, lines []string) error {
+	start = min(max(start, 0), len(b.lines))
+	end = min(max(end, start), len(b.lines))
+	sealed, err := b.seal(lines)
+	if err != nil {
+		return err
+	}
+	b.lines = append(b.lines[:start:start], append(sealed, b.lines[end:]...)...)
+	return nil
+}
+
+// seal seals each line under a fresh nonce.
+func (b *Buffer) seal(src []string) ([][]byte, error) {
+	lines := make([][]byte, len(src))
+	for i, l := range src {
+		nonce := make([]byte, b.aead.NonceSize(), b.aead.NonceSize()+len(l)+b.aead.Overhead())
+		if _, err := rand.Read(nonce); err != nil {
+			return nil, fmt.Errorf("sealed: nonce: %w", err)
+		}
+		lines[i] = b.aead.Seal(nonce, nonce, []byte(l), nil)
+	}
+	return lines, nil
+}
+
+// Len returns the number of sealed lines.
+func (b *Buffer) Len() int {
+	return len(b.lines)
+}
+
+// Lines decrypts lines in the half-open range [start, end), clamped to the
+// buffer bounds.
+func (b *Buffer) Lines(start, end int) ([]string, error) {
+	if start < 0 {
+		start = 0
+	}
+	if end > len(b.lines) {
+		end = len(b.lines)
+	}
+	if start >= end {
+		return nil, nil
+	}
+	out := make([]string, 0, end-start)
+	ns := b.aead.NonceSize()
+	for i := start; i < end; i++ {
+		sl := b.lines[i]
+		plain, err := b.aead.Open(nil, sl[:ns], sl[ns:], nil)
+		if err != nil {
+			return nil, fmt.Errorf("sealed: open line %d: %w", i+1, err)
+		}
+		out = append(out, string(plain))
+		wipe(plain)
+	}
+	return out, nil
+}
+
+// String decrypts and returns the full text.
+func (b *Buffer) String() (string, error) {
+	lines, err := b.Lines(0, len(b.lines))
+	if err != nil {
+		return "", err
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// Equal reports whether the sealed text equals s without building a full
+// plaintext copy of the buffer.
+func (b *Buffer) Equal(s string) (bool, error) {
+	other := strings.Split(s, "\n")
+	if len(other) != len(b.lines) {
+		return false, nil
+	}
+	for i := range b.lines {
+		l, err := b.Lines(i, i+1)
+		if err != nil {
+			return false, err
+		}
+		if l[0] != other[i] {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func wipe(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
@@ -0,0 +1,137 @@
+package sealed
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestBuffer(t *testing.T) {
+	t.Run("round-trips sealed text", func(t *testing.T) {
+		b, err := New()
+		if err != nil {
+			t.Fatalf("failed to create buffer: %v", err)
+		}
+		text := "KEY=value\nOTHER=thing\n"
+		if err := b.Seal(text); err != nil {
+			t.Fatalf("seal failed: %v", err)
+		}
+
+		got, err := b.String()
+		if err != nil {
+			t.Fatalf("open failed: %v", err)
+		}
+		if got != text {
+			t.Errorf("expected %q, got %q", text, got)
+		}
+	})
+
+	t.Run("does not keep plaintext in sealed lines", func(t *testing.T) {
+		b, err := New()
+		if err != nil {
+			t.Fatalf("failed to create buffer: %v", err)
+		}
+		if err := b.Seal("SECRET=hunter2"); err != nil {
+			t.Fatalf("seal failed: %v", err)
+		}
+
+		for _, l := range b.lines {
+			if bytes.Contains(l, []byte("hunter2")) {
+				t.Error("expected sealed line to not contain plaintext")
+			}
+		}
+	})
+
+	t.Run("splices lines in place", func(t *testing.T) {
+		b, err := New()
+		if err != nil {
+			t.Fatalf("failed to create buffer: %v", err)
+		}
+		if err := b.Seal("a\nb\nc\nd"); err != nil {
+			t.Fatalf("seal failed: %v", err)
+		}
+		kept := b.lines[3]
+		if err := b.Splice(1, 3, []string{"x", "y", "z"}); err != nil {
+			t.Fatalf("splice failed: %v", err)
+		}
+		if got, _ := b.String(); got != "a\nx\ny\nz\nd" {
+			t.Errorf("expected a x y z d, got %q", got)
+		}
+		if !bytes.Equal(b.lines[4], kept) {
+			t.Error("expected lines outside the range to stay sealed as they were")
+		}
+		if err := b.Splice(0, 4, nil); err != nil {
+			t.Fatalf("splice failed: %v", err)
+		}
+		if got, _ := b.String(); got != "d" {
+			t.Errorf("expected d, got %q", got)
+		}
+	})
+
+	t.Run("decrypts only the requested range", func(t *testing.T) {
+		b, err := New()
+		if err != nil {
+			t.Fatalf("failed to create buffer: %v", err)
+		}
+		if err := b.Seal("a\nb\nc\nd"); err != nil {
+			t.Fatalf("seal failed: %v", err)
+		}
+
+		lines, err := b.Lines(1, 3)
+		if err != nil {
+			t.Fatalf("lines failed: %v", err)
+		}
+		if len(lines) != 2 || lines[0] != "b" || lines[1] != "c" {
+			t.Errorf("expected [b c], got %v", lines)
+		}
+	})
+
+	t.Run("clamps out-of-range requests", func(t *testing.T) {
+		b, err := New()
+		if err != nil {
+			t.Fatalf("failed to create buffer: %v", err)
+		}
+		if err := b.Seal("a\nb"); err != nil {
+			t.Fatalf("seal failed: %v", err)
+		}
+
+		lines, err := b.Lines(-5, 50)
+		if err != nil {
+			t.Fatalf("lines failed: %v", err)
+		}
+		if len(lines) != 2 {
+			t.Errorf("expected 2 lines, got %d", len(lines))
+		}
+	})
+
+	t.Run("compares against plaintext", func(t *testing.T) {
+		b, err := New()
+		if err != nil {
+			t.Fatalf("failed to create buffer: %v", err)
+		}
+		if err := b.Seal("one\ntwo"); err != nil {
+			t.Fatalf("seal failed: %v", err)
+		}
+
+		if eq, _ := b.Equal("one\ntwo"); !eq {
+			t.Error("expected identical text to compare equal")
+		}
+		if eq, _ := b.Equal("one\nthree"); eq {
+			t.Error("expected different text to compare unequal")
+		}
+	})
+
+	t.Run("detects tampering", func(t *testing.T) {
+		b, err := New()
+		if err != nil {
+			t.Fatalf("failed to create buffer: %v", err)
+		}
+		if err := b.Seal("value"); err != nil {
+			t.Fatalf("seal failed: %v", err)
+		}
+		b.lines[0][len(b.lines[0])-1] ^= 0xff
+
+		if _, err := b.String(); err == nil {
+			t.Error("expected error for tampered line")
+		}
+	})
+}
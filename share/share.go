@@ -0,0 +1,63 @@
+// Package share marks plaintext handed to a guest with an expiry that
+// agepad enforces when the guest opens it.
+//
+// The annotation travels inside the ciphertext, so it cannot be altered
+// without re-encrypting. It is a soft control: a guest who decrypts with the
+// plain age CLI sees the content regardless of the expiry.
+package share
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// marker starts the first line of shared plaintext.
+const marker = "# agepad:share "
+
+// Annotation describes a share.
+type Annotation struct {
+	Expires time.Time
+	Source  string // base name of the file the share was made from
+}
+
+// Wrap prefixes body with the annotation line.
+func Wrap(body string, a Annotation) string {
+	line := marker + "expires=" + a.Expires.UTC().Format(time.RFC3339)
+	if a.Source != "" {
+		line += " source=" + a.Source
+	}
+	return line + "\n" + body
+}
+
+// Parse splits shared plaintext into its annotation and body. ok is false
+// (and body is plain) when plain carries no annotation.
+func Parse(plain string) (a Annotation, body string, ok bool, err error) {
+	if !strings.HasPrefix(plain, marker) {
+		return Annotation{}, plain, false, nil
+	}
+	line, body, _ := strings.Cut(plain, "\n")
+	for _, f := range strings.Fields(strings.TrimPrefix(line, marker)) {
+		k, v, _ := strings.Cut(f, "=")
+		switch k {
+		case "expires":
+			if a.Expires, err = time.Parse(time.RFC3339, v); err != nil {
+				return Annotation{}, "", false, fmt.Errorf("share: bad expiry %q: %w", v, err)
+			}
+		case "source":
+			a.Source = v
+		}
+	}
+	if a.Expires.IsZero() {
+		return Annotation{}, "", false, fmt.Errorf("share: annotation without expiry")
+	}
+	return a, body, true, nil
+}
+
+// Check returns an error once the share has expired.
+func (a Annotation) Check(now time.Time) error {
+	if !now.Before(a.Expires) {
+		return fmt.Errorf("this share expired at %s; ask the owner for a new one", a.Expires.Local().Format(time.RFC1123))
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package share
+
+import (
+	"testing"
+	"time"
+)
+
+func TestShare(t *testing.T) {
+	t.Run("round-trips the annotation", func(t *testing.T) {
+		exp := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+		wrapped := Wrap("A=1\n", Annotation{Expires: exp, Source: "app.env.age"})
+
+		a, body, ok, err := Parse(wrapped)
+		if err != nil || !ok {
+			t.Fatalf("parse failed: ok=%v err=%v", ok, err)
+		}
+		if !a.Expires.Equal(exp) || a.Source != "app.env.age" || body != "A=1\n" {
+			t.Errorf("unexpected result: %+v %q", a, body)
+		}
+	})
+
+	t.Run("leaves ordinary plaintext alone", func(t *testing.T) {
+		_, body, ok, err := Parse("A=1")
+		if ok || err != nil || body != "A=1" {
+			t.Errorf("expected plain passthrough, got ok=%v err=%v body=%q", ok, err, body)
+		}
+	})
+
+	t.Run("rejects a malformed expiry", func(t *testing.T) {
+		if _, _, _, err := Parse("# agepad:share expires=soon\nA=1"); err == nil {
+			t.Error("expected error for malformed expiry")
+		}
+	})
+
+	t.Run("enforces expiry", func(t *testing.T) {
+		a := Annotation{Expires: time.Now().Add(-time.Minute)}
+		if err := a.Check(time.Now()); err == nil {
+			t.Error("expected expired share to fail")
+		}
+		a.Expires = time.Now().Add(time.Hour)
+		if err := a.Check(time.Now()); err != nil {
+			t.Errorf("expected live share to pass, got %v", err)
+		}
+	})
+}
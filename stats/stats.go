@@ -0,0 +1,182 @@
+// Package stats keeps an opt-in, local-only ledger of how often agepad is
+// used: counts of saves, rotations, and verifies per repository, by day.
+// It never records file names, keys, or values, and nothing in agepad
+// sends it anywhere; `agepad stats` prints it for the user to share.
+package stats
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/andreweick/agepad/platform"
+)
+
+// Actions counted in the ledger.
+const (
+	Save   = "save"
+	Rotate = "rotate"
+	Verify = "verify"
+)
+
+// NoRepo stands for work outside any git repository.
+const NoRepo = "(no repository)"
+
+// Entry is one ledger record: an action taken Count times (files saved,
+// rotated, or verified) in Repo on Date.
+type Entry struct {
+	Date   string `json:"date"` // YYYY-MM-DD, local time
+	Repo   string `json:"repo"`
+	Action string `json:"action"`
+	Count  int    `json:"count"`
+}
+
+// DefaultPath returns $AGEPAD_STATS_LEDGER, or stats.jsonl under
+// $XDG_STATE_HOME/agepad (default ~/.local/state/agepad; %LocalAppData%\agepad
+// on Windows).
+func DefaultPath() string {
+	if p := os.Getenv("AGEPAD_STATS_LEDGER"); p != "" {
+		return p
+	}
+	return filepath.Join(platform.StateDir(), "agepad", "stats.jsonl")
+}
+
+// Enabled reports whether counting is on: $AGEPAD_STATS ("1"/"on" or
+// "0"/"off") when set, else the stats setting, which defaults to off.
+func Enabled(setting *bool) bool {
+	switch strings.ToLower(os.Getenv("AGEPAD_STATS")) {
+	case "1", "on", "true", "yes":
+		return true
+	case "0", "off", "false", "no":
+		return false
+	}
+	return setting != nil && *setting
+}
+
+// RepoOf returns the top of the git repository holding dir (the nearest
+// directory with a .git), or NoRepo.
+func RepoOf(dir string) string {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return NoRepo
+	}
+	for {
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return NoRepo
+		}
+		dir = parent
+	}
+}
+
+// Ledger is an append-only JSON-lines file of entries.
+type Ledger struct {
+	Path string
+}
+
+// Record appends an entry for action, counted n times in the repository
+// holding dir. The ledger and its directory are private to the user.
+func (l Ledger) Record(action, dir string, n int) error {
+	if n <= 0 {
+		return nil
+	}
+	b, err := json.Marshal(Entry{
+		Date:   time.Now().Format(time.DateOnly),
+		Repo:   RepoOf(dir),
+		Action: action,
+		Count:  n,
+	})
+	if err != nil {
+		return fmt.Errorf("stats: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(l.Path), 0700); err != nil {
+		return fmt.Errorf("stats: %w", err)
+	}
+	f, err := os.OpenFile(l.Path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return fmt.Errorf("stats: %w", err)
+	}
+	if err := platform.LockFile(f); err == nil {
+		defer platform.UnlockFile(f)
+	}
+	if _, err := f.Write(append(b, '\n')); err != nil {
+		f.Close()
+		return fmt.Errorf("stats: %w", err)
+	}
+	return f.Close()
+}
+
+// Read returns all entries, oldest first. A missing ledger is empty.
+func (l Ledger) Read() ([]Entry, error) {
+	f, err := os.Open(l.Path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("stats: %w", err)
+	}
+	defer f.Close()
+
+	var out []Entry
+	sc := bufio.NewScanner(f)
+	for n := 1; sc.Scan(); n++ {
+		var e Entry
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("stats: %s line %d: %w", l.Path, n, err)
+		}
+		out = append(out, e)
+	}
+	return out, sc.Err()
+}
+
+// Repo totals one repository's entries.
+type Repo struct {
+	Repo   string         `json:"repo"`
+	First  string         `json:"first"`
+	Last   string         `json:"last"`
+	Days   int            `json:"active_days"`
+	Counts map[string]int `json:"counts"` // action -> total count
+	Runs   map[string]int `json:"runs"`   // action -> entries (sessions or commands)
+}
+
+// Summarize totals entries on or after since (YYYY-MM-DD; "" for all) per
+// repository, most recently active first.
+func Summarize(entries []Entry, since string) []Repo {
+	by := map[string]*Repo{}
+	days := map[string]map[string]bool{}
+	for _, e := range entries {
+		if e.Date < since {
+			continue
+		}
+		r := by[e.Repo]
+		if r == nil {
+			r = &Repo{Repo: e.Repo, First: e.Date, Counts: map[string]int{}, Runs: map[string]int{}}
+			by[e.Repo] = r
+			days[e.Repo] = map[string]bool{}
+		}
+		r.First, r.Last = min(r.First, e.Date), max(r.Last, e.Date)
+		r.Counts[e.Action] += e.Count
+		r.Runs[e.Action]++
+		days[e.Repo][e.Date] = true
+	}
+	out := make([]Repo, 0, len(by))
+	for name, r := range by {
+		r.Days = len(days[name])
+		out = append(out, *r)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Last != out[j].Last {
+			return out[i].Last > out[j].Last
+		}
+		return out[i].Repo < out[j].Repo
+	})
+	return out
+}
@@ -0,0 +1,88 @@
+package stats
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLedger(t *testing.T) {
+	t.Run("records counts by repository", func(t *testing.T) {
+		repo := t.TempDir()
+		if err := os.Mkdir(filepath.Join(repo, ".git"), 0700); err != nil {
+			t.Fatal(err)
+		}
+		sub := filepath.Join(repo, "secrets")
+		if err := os.Mkdir(sub, 0700); err != nil {
+			t.Fatal(err)
+		}
+		l := Ledger{Path: filepath.Join(t.TempDir(), "state", "stats.jsonl")}
+		for _, r := range []struct {
+			action string
+			n      int
+		}{{Save, 2}, {Rotate, 5}, {Save, 1}, {Verify, 0}} {
+			if err := l.Record(r.action, sub, r.n); err != nil {
+				t.Fatalf("record failed: %v", err)
+			}
+		}
+
+		entries, err := l.Read()
+		if err != nil {
+			t.Fatalf("read failed: %v", err)
+		}
+		if len(entries) != 3 || entries[0].Repo != repo {
+			t.Fatalf("unexpected entries: %+v", entries)
+		}
+		got := Summarize(entries, "")
+		if len(got) != 1 || got[0].Counts[Save] != 3 || got[0].Runs[Save] != 2 || got[0].Counts[Rotate] != 5 || got[0].Days != 1 {
+			t.Errorf("unexpected summary: %+v", got)
+		}
+
+		info, err := os.Stat(l.Path)
+		if err != nil {
+			t.Fatalf("stat failed: %v", err)
+		}
+		if info.Mode().Perm() != 0600 {
+			t.Errorf("expected 0600 ledger, got %v", info.Mode().Perm())
+		}
+	})
+
+	t.Run("treats a missing ledger as empty", func(t *testing.T) {
+		entries, err := Ledger{Path: filepath.Join(t.TempDir(), "none.jsonl")}.Read()
+		if err != nil || len(entries) != 0 {
+			t.Errorf("expected no entries, got %v (%v)", entries, err)
+		}
+	})
+}
+
+func TestSummarize(t *testing.T) {
+	entries := []Entry{
+		{Date: "2026-01-02", Repo: "/a", Action: Save, Count: 1},
+		{Date: "2026-03-01", Repo: "/b", Action: Verify, Count: 4},
+		{Date: "2026-03-05", Repo: "/a", Action: Save, Count: 2},
+	}
+	got := Summarize(entries, "")
+	if len(got) != 2 || got[0].Repo != "/a" || got[0].First != "2026-01-02" || got[0].Last != "2026-03-05" || got[0].Days != 2 {
+		t.Errorf("unexpected summary: %+v", got)
+	}
+	got = Summarize(entries, "2026-03-01")
+	if len(got) != 2 || got[0].Counts[Save] != 2 {
+		t.Errorf("--since should drop older entries: %+v", got)
+	}
+}
+
+func TestEnabled(t *testing.T) {
+	yes, no := true, false
+	t.Setenv("AGEPAD_STATS", "")
+	if Enabled(nil) || Enabled(&no) || !Enabled(&yes) {
+		t.Error("expected the setting to decide, off by default")
+	}
+	t.Setenv("AGEPAD_STATS", "off")
+	if Enabled(&yes) {
+		t.Error("AGEPAD_STATS=off should win over the setting")
+	}
+	t.Setenv("AGEPAD_STATS", "1")
+	if !Enabled(nil) {
+		t.Error("AGEPAD_STATS=1 should turn counting on")
+	}
+}
@@ -0,0 +1,194 @@
+package tui
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/andreweick/agepad/control"
+	"github.com/andreweick/agepad/diff"
+	"github.com/andreweick/agepad/dotenv"
+	"github.com/andreweick/agepad/keyops"
+	"github.com/andreweick/agepad/validator"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// controlTimeout bounds how long a control call waits for the editor,
+// which may itself be waiting on a plugin during a save.
+const controlTimeout = time.Minute
+
+// controlMsg carries a control socket call into the update loop, so it
+// sees and changes the model exactly as a key press would.
+type controlMsg struct {
+	method string
+	params json.RawMessage
+	reply  chan controlReply
+}
+
+type controlReply struct {
+	result any
+	err    error
+}
+
+// Status is the result of the control methods "status", "save", and
+// "insert". It carries key names but never values.
+type Status struct {
+	File           string   `json:"file"`
+	Modified       bool     `json:"modified"`
+	ChangedKeys    []string `json:"changed_keys"`
+	Lines          int      `json:"lines"`
+	Saves          int      `json:"saves"`
+	ViewOnly       bool     `json:"view_only"`
+	PendingConfirm bool     `json:"pending_confirm"` // the next save writes
+	Queued         bool     `json:"queued"`          // a failed write awaits a retry
+	Message        string   `json:"message"`         // first line of the status line
+	Error          string   `json:"error,omitempty"`
+}
+
+// InsertParams are the parameters of the "insert" method.
+type InsertParams struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// ControlHandler answers control socket calls by sending them to the
+// editor running in p:
+//
+//	status  the buffer's state (Status)
+//	save    the same as pressing Ctrl+S, confirmation step included
+//	insert  set KEY=VALUE in a .env buffer ({"key": ..., "value": ...})
+//	diff    the unsaved changes as a plain unified diff ({"diff": ...})
+func ControlHandler(p *tea.Program) control.Handler {
+	return func(method string, params json.RawMessage) (any, error) {
+		reply := make(chan controlReply, 1)
+		go p.Send(controlMsg{method: method, params: params, reply: reply})
+		select {
+		case r := <-reply:
+			return r.result, r.err
+		case <-time.After(controlTimeout):
+			return nil, errors.New("the editor did not answer in time")
+		}
+	}
+}
+
+// control answers one control socket call.
+func (m Model) control(c controlMsg) (tea.Model, tea.Cmd) {
+	var (
+		res any
+		err error
+		cmd tea.Cmd
+	)
+	switch c.method {
+	case "status":
+		res = m.controlStatus()
+	case "save":
+		prevErr := m.err
+		// Idle time counts from the last key, so a save requested while
+		// nobody is at the keyboard still re-authenticates.
+		var next tea.Model
+		next, cmd = m.save(time.Since(m.lastActivity))
+		m = next.(Model)
+		if m.err != nil && (prevErr == nil || !errors.Is(m.err, prevErr)) {
+			err = m.err
+		}
+		res = m.controlStatus()
+	case "insert":
+		var p InsertParams
+		if err = json.Unmarshal(c.params, &p); err != nil {
+			err = control.Errorf(control.CodeInvalidParams, "insert: %v", err)
+			break
+		}
+		if err = m.insert(p.Key, p.Value); err == nil {
+			res = m.controlStatus()
+		}
+	case "diff":
+		if m.cfg.Paranoid {
+			err = errors.New("diff is not served in paranoid mode")
+			break
+		}
+		var orig string
+		if orig, err = m.original(); err != nil {
+			break
+		}
+		name := filepath.Base(m.cfg.FilePath)
+		res = map[string]string{"diff": diff.Unified(orig, m.text(), diff.Options{
+			FromFile:  name + " (original)",
+			ToFile:    name + " (edited)",
+			Context:   3,
+			Algorithm: diff.Algorithm(m.cfg.DiffAlgorithm),
+		})}
+	default:
+		err = control.Errorf(control.CodeMethodNotFound, "unknown method %q", c.method)
+	}
+	if err != nil {
+		res = nil
+	}
+	c.reply <- controlReply{result: res, err: err}
+	return m, cmd
+}
+
+func (m Model) controlStatus() Status {
+	s := Status{
+		File:           m.cfg.FilePath,
+		Modified:       m.modified(),
+		Lines:          m.lineCount(),
+		Saves:          m.saves,
+		ViewOnly:       m.cfg.ViewOnly,
+		PendingConfirm: m.pendingConfirm,
+		Queued:         m.queue != nil,
+	}
+	orig, origErr := m.original()
+	if origErr == nil {
+		s.ChangedKeys = m.bufferChangedKeys(orig, m.text())
+	}
+	if s.ChangedKeys == nil {
+		s.ChangedKeys = []string{}
+	}
+	s.Message, _, _ = strings.Cut(m.status, "\n")
+	switch {
+	case m.err != nil:
+		s.Error = m.err.Error()
+	case origErr != nil:
+		s.Error = origErr.Error()
+	}
+	return s
+}
+
+// insert sets key to value in the buffer, replacing its first definition
+// or appending a line, and moves the cursor there. Only .env buffers have
+// a single obvious place for a key.
+func (m *Model) insert(key, value string) error {
+	buf := m.text()
+	switch {
+	case m.cfg.ViewOnly:
+		return errors.New("view-only mode: editing disabled")
+	case len(m.bundlePaths) > 0:
+		return errors.New("insert is not supported in bundle mode")
+	case strings.TrimSpace(buf) != "" && validator.Format(m.cfg.FilePath, buf, m.cfg.TypeRules) != "env":
+		return errors.New("insert only edits .env files")
+	}
+	if es, err := dotenv.Parse(key + "=x"); err != nil || len(es) != 1 || es[0].Key != key {
+		return control.Errorf(control.CodeInvalidParams, "invalid key %q", key)
+	}
+	next, existed := keyops.Set(buf, key, dotenv.Quote(value))
+	m.setText(next)
+	es, _ := dotenv.Parse(next)
+	for _, e := range es {
+		if e.Key == key {
+			m.gotoLine(e.Line)
+			break
+		}
+	}
+	m.changed = true
+	m.pendingConfirm = false
+	m.queue = nil
+	verb := "Added"
+	if existed {
+		verb = "Updated"
+	}
+	m.status = fmt.Sprintf("%s %s from the control socket. Ctrl+S saves.", verb, key)
+	return nil
+}
@@ -0,0 +1,138 @@
+package tui
+
+import (
+	"filippo.io/age"
+	"github.com/andreweick/agepad/model"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Validator checks the content about to be saved to path; an error blocks
+// the save and is shown in the editor.
+type Validator func(path, content string) error
+
+// EditorClosedMsg is sent by an embedded editor when the user quits it.
+// Saved reports whether anything was saved during the session.
+type EditorClosedMsg struct {
+	Saved bool
+}
+
+// EditorModel is agepad's editor as a component for other Bubble Tea
+// programs: the same in-RAM buffer, validation, recipient preflight,
+// confirm-before-save, and encrypted atomic write as the agepad binary.
+// The host owns the program, so quitting the editor sends EditorClosedMsg
+// instead of tea.Quit, and its size is set with WithSize or SetSize rather
+// than taken from window resizes.
+type EditorModel struct {
+	m       Model
+	blurred bool
+}
+
+// EditorOption configures an EditorModel.
+type EditorOption func(*Model)
+
+// WithKeyMap replaces the editor's key bindings.
+func WithKeyMap(k KeyMap) EditorOption {
+	return func(m *Model) { m.keys = k }
+}
+
+// WithSize sets the editor's width and height in cells, status line and
+// error line included.
+func WithSize(width, height int) EditorOption {
+	return func(m *Model) { m.setSize(width, height) }
+}
+
+// WithValidators adds checks that run after the format validation on every
+// save.
+func WithValidators(v ...Validator) EditorOption {
+	return func(m *Model) { m.validators = append(m.validators, v...) }
+}
+
+// OnSave replaces writing the file at path with save, which receives the
+// ciphertext that passed the recipient preflight. An error keeps the
+// ciphertext queued for a retry, as a failed file write would.
+func OnSave(save func(path string, cipher []byte) error) EditorOption {
+	return func(m *Model) { m.write = save }
+}
+
+// AfterSave runs fn after every file a save writes; see Model.WithAfterSave.
+func AfterSave(fn func(path, plain string) error) EditorOption {
+	return func(m *Model) { m.afterSave = fn }
+}
+
+// WithHead gives the editor the file's git HEAD version, for the
+// "[≠ HEAD]" badge and the HEAD diff.
+func WithHead(plain string) EditorOption {
+	return func(m *Model) { *m = m.WithHead(plain) }
+}
+
+// NewEditorModel returns an editor for plaintext that saves to cfg.FilePath
+// encrypted to recips; ids are used for the preflight decrypt.
+func NewEditorModel(cfg model.Config, plaintext string, ids []age.Identity, recips []age.Recipient, opts ...EditorOption) EditorModel {
+	m := NewModel(cfg, plaintext, ids, recips)
+	m.embedded = true
+	for _, o := range opts {
+		o(&m)
+	}
+	return EditorModel{m: m}
+}
+
+// setSize fits the textarea and log panes into width x height, leaving
+// room for the status and error lines.
+func (m *Model) setSize(width, height int) {
+	m.ta.SetWidth(width)
+	m.ta.SetHeight(max(height-4, 1))
+	m.errs.vp.Width, m.history.vp.Width = width, width
+	m.keepWindow()
+}
+
+// Init starts the editor's background ticks.
+func (e EditorModel) Init() tea.Cmd {
+	return e.m.Init()
+}
+
+// Update handles a message for the editor. Keys are ignored while it is
+// blurred.
+func (e EditorModel) Update(msg tea.Msg) (EditorModel, tea.Cmd) {
+	if _, ok := msg.(tea.KeyMsg); ok && e.blurred {
+		return e, nil
+	}
+	res, cmd := e.m.Update(msg)
+	e.m = res.(Model)
+	return e, cmd
+}
+
+// View renders the editor.
+func (e EditorModel) View() string {
+	return e.m.View()
+}
+
+// SetSize resizes the editor.
+func (e *EditorModel) SetSize(width, height int) {
+	e.m.setSize(width, height)
+}
+
+// Focus lets the editor take keys again after Blur.
+func (e *EditorModel) Focus() tea.Cmd {
+	e.blurred = false
+	if e.m.cfg.ViewOnly {
+		return nil
+	}
+	return e.m.ta.Focus()
+}
+
+// Blur stops the editor from taking keys, for hosts that move focus
+// between panes.
+func (e *EditorModel) Blur() {
+	e.blurred = true
+	e.m.ta.Blur()
+}
+
+// Modified reports whether the buffer differs from what was last saved.
+func (e EditorModel) Modified() bool {
+	return e.m.modified()
+}
+
+// Summary reports what happened during the session so far.
+func (e EditorModel) Summary() Summary {
+	return e.m.Summary()
+}
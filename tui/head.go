@@ -0,0 +1,89 @@
+package tui
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/andreweick/agepad/diff"
+	"github.com/andreweick/agepad/sealed"
+)
+
+// headBadge marks the status line while the buffer differs from the
+// decrypted committed version.
+const headBadge = "[≠ HEAD] "
+
+// WithHead records the decrypted content of the file at git HEAD, so the
+// editor can flag (and Ctrl+O can show) differences from what is
+// committed, including changes already on disk when the file was opened.
+// In paranoid mode the copy is kept sealed.
+func (m Model) WithHead(plain string) Model {
+	m.hasHead = true
+	if m.cfg.Paranoid && m.origSealed != nil {
+		b, err := sealed.New()
+		if err == nil {
+			err = b.Seal(plain)
+		}
+		if err != nil {
+			m.hasHead = false
+			m.err = fmt.Errorf("sealing the HEAD version: %w", err)
+			return m
+		}
+		m.headSealed = b
+	} else {
+		m.head = plain
+	}
+	orig, err := m.original()
+	if err != nil {
+		m.err = err
+	} else if plain != orig {
+		m.status = "The file on disk differs from git HEAD (uncommitted change). Ctrl+O: diff against HEAD\n" + m.status
+		m.history.add("info", m.status)
+	}
+	return m
+}
+
+// committed returns the HEAD version, opening it in paranoid mode.
+func (m Model) committed() (string, error) {
+	if m.headSealed == nil {
+		return m.head, nil
+	}
+	s, err := m.headSealed.String()
+	if err != nil {
+		return "", fmt.Errorf("opening the sealed HEAD version: %w", err)
+	}
+	return s, nil
+}
+
+// differsFromHead reports whether the buffer differs from the HEAD version.
+func (m Model) differsFromHead() bool {
+	if !m.hasHead {
+		return false
+	}
+	if m.headSealed == nil {
+		return m.ta.Value() != m.head
+	}
+	eq, err := m.sameAs(m.headSealed)
+	return err != nil || !eq
+}
+
+// headDiff shows the buffer against the HEAD version in the status line.
+func (m *Model) headDiff() {
+	if !m.hasHead {
+		m.status = "No committed version to compare with (not in git, or not committed yet)."
+		return
+	}
+	head, err := m.committed()
+	if err != nil {
+		m.err = err
+		m.status = "Cannot show the diff against git HEAD."
+		return
+	}
+	name := filepath.Base(m.cfg.FilePath)
+	diff := unifiedDiff(head, m.text(), name+" (HEAD)", name+" (buffer)", diff.Algorithm(m.cfg.DiffAlgorithm))
+	if strings.TrimSpace(diff) == "" {
+		m.status = "Buffer matches git HEAD."
+		return
+	}
+	m.status = "Diff against git HEAD (first 2000 chars):\n" + truncate(diff, 2000)
+}
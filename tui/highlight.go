@@ -0,0 +1,37 @@
+package tui
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/andreweick/agepad/highlight"
+)
+
+// sgrEnd finds the escape sequences the textarea styles its gutter with.
+var sgrEnd = regexp.MustCompile(`\x1b\[[0-9;]*m`)
+
+// highlightView colors the buffer text in a rendered textarea. The textarea
+// has no syntax hook, but its text style is empty, so on every row the text
+// is the unstyled run after the gutter's last escape. Rows whose text is
+// styled anyway (the cursor line) are left as they are, as is everything
+// when the terminal has no colors (no escapes at all).
+func highlightView(view string, theme highlight.Theme, format string) string {
+	if format == "" {
+		return view
+	}
+	rows := strings.Split(view, "\n")
+	for i, row := range rows {
+		locs := sgrEnd.FindAllStringIndex(row, -1)
+		if len(locs) == 0 {
+			continue
+		}
+		end := locs[len(locs)-1][1]
+		tail := row[end:]
+		text := strings.TrimRight(tail, " ")
+		if text == "" || strings.Contains(text, "\x1b") {
+			continue
+		}
+		rows[i] = row[:end] + highlight.Line(theme, format, text) + tail[len(text):]
+	}
+	return strings.Join(rows, "\n")
+}
@@ -0,0 +1,105 @@
+package tui
+
+import (
+	"strings"
+
+	"github.com/andreweick/agepad/validator"
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// indentUnit is one indent level for the structured formats: two spaces,
+// as YAML requires spaces and JSON and TOML files are usually written that
+// way. Other formats keep the plain textarea Enter and Tab.
+func indentUnit(format string) string {
+	switch format {
+	case "json", "yaml", "toml":
+		return "  "
+	}
+	return ""
+}
+
+// indentKey handles Enter and Tab in a structured buffer: Enter keeps the
+// current line's indentation and opens a level after a YAML "key:" or an
+// open bracket, and Tab inserts spaces up to the next level. It reports
+// whether it handled msg.
+func (m *Model) indentKey(msg tea.KeyMsg) bool {
+	if !m.ta.Focused() {
+		return false
+	}
+	format := validator.Format(m.cfg.FilePath, m.ta.Value(), m.cfg.TypeRules)
+	unit := indentUnit(format)
+	if unit == "" {
+		return false
+	}
+	line := []rune(m.currentLine())
+	info := m.ta.LineInfo()
+	col := min(info.StartColumn+info.ColumnOffset, len(line))
+	before, after := string(line[:col]), string(line[col:])
+
+	switch {
+	case msg.Type == tea.KeyTab:
+		m.ta.InsertString(strings.Repeat(" ", len(unit)-col%len(unit)))
+		return true
+
+	case key.Matches(msg, m.ta.KeyMap.InsertNewline):
+		indent, inner, closes := newlineIndent(format, before, after, unit)
+		if !closes {
+			m.ta.InsertString("\n" + inner)
+			return true
+		}
+		// Between a bracket pair, put the closer on its own line and the
+		// cursor on the line between.
+		m.ta.InsertString("\n" + inner + "\n" + indent)
+		m.ta.CursorUp()
+		m.ta.CursorEnd()
+		return true
+	}
+	return false
+}
+
+// newlineIndent returns the indentation of the current line, the one for
+// the new line, and whether the cursor sits between an open bracket and
+// its closer.
+func newlineIndent(format, before, after, unit string) (indent, inner string, closes bool) {
+	indent = before[:len(before)-len(strings.TrimLeft(before, " \t"))]
+	text := strings.TrimRight(before, " \t")
+	if format == "yaml" || format == "toml" {
+		text = strings.TrimRight(stripComment(text), " \t")
+	}
+	if text == "" {
+		return indent, indent, false
+	}
+	switch last := text[len(text)-1]; {
+	case last == '{' || last == '[':
+		closer := "}"
+		if last == '[' {
+			closer = "]"
+		}
+		return indent, indent + unit, strings.HasPrefix(strings.TrimSpace(after), closer)
+	case format == "yaml" && (last == ':' || last == '|' || last == '>'):
+		return indent, indent + unit, false
+	case format == "yaml" && strings.TrimSpace(text) == "-":
+		return indent, indent + unit, false
+	}
+	return indent, indent, false
+}
+
+// stripComment drops a trailing " # comment" from a YAML or TOML line. A
+// '#' inside quotes, or not after a space, is kept.
+func stripComment(s string) string {
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#' && (i == 0 || s[i-1] == ' ' || s[i-1] == '\t'):
+			return s[:i]
+		}
+	}
+	return s
+}
@@ -0,0 +1,126 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+)
+
+// KeyMap holds the editor's own key bindings. Keys not bound here go to
+// the textarea, whose editing keys (arrows, Ctrl+A/E/K/U, ...) are fixed.
+type KeyMap struct {
+	Save             key.Binding
+	SaveAs           key.Binding // only after a failed write
+	Diff             key.Binding
+	HeadDiff         key.Binding
+	Copy             key.Binding
+	Keys             key.Binding
+	Replace          key.Binding
+	Errors           key.Binding
+	History          key.Binding
+	ReloadRecipients key.Binding
+	Recipients       key.Binding
+	Snapshots        key.Binding
+	Lint             key.Binding
+	Select           key.Binding // also Shift+arrows
+	CopySelection    key.Binding // to the editor register
+	Cut              key.Binding
+	Paste            key.Binding // from the editor register
+	Quit             key.Binding
+	NextTab          key.Binding // with several files open
+	PrevTab          key.Binding
+}
+
+// DefaultKeyMap returns the bindings of the agepad binary.
+func DefaultKeyMap() KeyMap {
+	return KeyMap{
+		Save:             key.NewBinding(key.WithKeys("ctrl+s"), key.WithHelp("ctrl+s", "save")),
+		SaveAs:           key.NewBinding(key.WithKeys("ctrl+x"), key.WithHelp("ctrl+x", "save as")),
+		Diff:             key.NewBinding(key.WithKeys("ctrl+d"), key.WithHelp("ctrl+d", "diff")),
+		HeadDiff:         key.NewBinding(key.WithKeys("ctrl+o"), key.WithHelp("ctrl+o", "diff against HEAD")),
+		Copy:             key.NewBinding(key.WithKeys("ctrl+y"), key.WithHelp("ctrl+y", "copy value")),
+		Keys:             key.NewBinding(key.WithKeys("ctrl+t"), key.WithHelp("ctrl+t", "keys")),
+		Replace:          key.NewBinding(key.WithKeys("ctrl+f"), key.WithHelp("ctrl+f", "find and replace")),
+		Errors:           key.NewBinding(key.WithKeys("ctrl+g"), key.WithHelp("ctrl+g", "errors")),
+		History:          key.NewBinding(key.WithKeys("ctrl+l"), key.WithHelp("ctrl+l", "status history")),
+		ReloadRecipients: key.NewBinding(key.WithKeys("ctrl+r"), key.WithHelp("ctrl+r", "reload recipients")),
+		Recipients:       key.NewBinding(key.WithKeys("alt+r"), key.WithHelp("alt+r", "recipients")),
+		Snapshots:        key.NewBinding(key.WithKeys("alt+s"), key.WithHelp("alt+s", "snapshots")),
+		Lint:             key.NewBinding(key.WithKeys("alt+l"), key.WithHelp("alt+l", "lint")),
+		Select:           key.NewBinding(key.WithKeys("alt+v"), key.WithHelp("alt+v", "select")),
+		CopySelection:    key.NewBinding(key.WithKeys("alt+w"), key.WithHelp("alt+w", "copy selection")),
+		Cut:              key.NewBinding(key.WithKeys("alt+x"), key.WithHelp("alt+x", "cut")),
+		Paste:            key.NewBinding(key.WithKeys("alt+y"), key.WithHelp("alt+y", "paste")),
+		Quit:             key.NewBinding(key.WithKeys("ctrl+q", "esc"), key.WithHelp("ctrl+q", "quit")),
+		NextTab:          key.NewBinding(key.WithKeys("alt+n", "ctrl+pgdown"), key.WithHelp("alt+n", "next tab")),
+		PrevTab:          key.NewBinding(key.WithKeys("alt+p", "ctrl+pgup"), key.WithHelp("alt+p", "previous tab")),
+	}
+}
+
+// Rebind replaces bindings by action name (save, save_as, diff, head_diff,
+// copy, keys, replace, errors, history, reload_recipients, recipients,
+// snapshots, lint, select, copy_selection, cut, paste, quit, next_tab,
+// prev_tab), each
+// given as comma-separated keys such as "ctrl+s" or "ctrl+q,esc". On
+// error k is unchanged.
+func (k *KeyMap) Rebind(bindings map[string]string) error {
+	next := *k
+	actions := map[string]*key.Binding{
+		"save":              &next.Save,
+		"save_as":           &next.SaveAs,
+		"diff":              &next.Diff,
+		"head_diff":         &next.HeadDiff,
+		"copy":              &next.Copy,
+		"keys":              &next.Keys,
+		"replace":           &next.Replace,
+		"errors":            &next.Errors,
+		"history":           &next.History,
+		"reload_recipients": &next.ReloadRecipients,
+		"recipients":        &next.Recipients,
+		"snapshots":         &next.Snapshots,
+		"lint":              &next.Lint,
+		"select":            &next.Select,
+		"copy_selection":    &next.CopySelection,
+		"cut":               &next.Cut,
+		"paste":             &next.Paste,
+		"quit":              &next.Quit,
+		"next_tab":          &next.NextTab,
+		"prev_tab":          &next.PrevTab,
+	}
+	for action, spec := range bindings {
+		b, ok := actions[action]
+		if !ok {
+			return fmt.Errorf("unknown editor action %q", action)
+		}
+		var keys []string
+		for _, s := range strings.Split(spec, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				keys = append(keys, s)
+			}
+		}
+		if len(keys) == 0 {
+			return fmt.Errorf("%s: no keys given", action)
+		}
+		*b = key.NewBinding(key.WithKeys(keys...), key.WithHelp(keys[0], b.Help().Desc))
+	}
+	*k = next
+	return nil
+}
+
+// keyHint names a binding's first key the way status messages do: "Ctrl+S".
+func keyHint(b key.Binding) string {
+	k := b.Help().Key
+	parts := strings.Split(k, "+")
+	for i, p := range parts {
+		if p == "" {
+			continue
+		}
+		if len(p) > 1 || i < len(parts)-1 {
+			parts[i] = strings.ToUpper(p[:1]) + p[1:]
+		} else {
+			parts[i] = strings.ToUpper(p)
+		}
+	}
+	return strings.Join(parts, "+")
+}
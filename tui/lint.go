@@ -0,0 +1,128 @@
+package tui
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"strings"
+
+	"github.com/andreweick/agepad/bundle"
+	"github.com/andreweick/agepad/lint"
+	"github.com/andreweick/agepad/validator"
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// lintRows is how many findings the lint panel shows at once.
+const lintRows = 10
+
+// lintPanel is the list of lint findings (Alt+L, or a save with --lint),
+// with lines counted in the buffer.
+type lintPanel struct {
+	findings []lint.Finding
+	sel      int
+}
+
+// lintUnits lints each file a save writes. In bundle mode a section's
+// lines are shifted past its marker so they point into the buffer.
+func (m Model) lintUnits(buf string, units []saveUnit) []lint.Finding {
+	lines := strings.Split(buf, "\n")
+	var out []lint.Finding
+	for _, u := range units {
+		offset := 0
+		if len(m.bundlePaths) > 0 {
+			for i, l := range lines {
+				if l == bundle.Marker(u.path) {
+					offset = i + 1
+					break
+				}
+			}
+		}
+		for _, f := range lint.Check(u.content, validator.Format(u.path, u.content, m.cfg.TypeRules)) {
+			f.Line += offset
+			out = append(out, f)
+		}
+	}
+	return out
+}
+
+// openLint lints the buffer and lists the findings.
+func (m *Model) openLint() {
+	buf := m.text()
+	units, err := m.saveUnits(buf)
+	if err != nil {
+		m.err = err
+		m.status = "File sections are damaged; cannot lint."
+		return
+	}
+	fs := m.lintUnits(buf, units)
+	if len(fs) == 0 {
+		m.lintPanel = nil
+		m.status = "Lint: nothing suspicious found."
+		return
+	}
+	m.lintPanel = &lintPanel{findings: fs}
+	m.status = fmt.Sprintf("Lint: %d finding(s).", len(fs))
+}
+
+// lintSave is the --lint step of a save. The first save of a buffer with
+// findings stops and lists them; saving the same buffer again goes ahead,
+// returning a warning for the save status.
+func (m *Model) lintSave(buf string, units []saveUnit) (warning string, stop bool) {
+	fs := m.lintUnits(buf, units)
+	if len(fs) == 0 {
+		return "", false
+	}
+	if sum := sha256.Sum256([]byte(buf)); sum != m.lintAcked {
+		m.lintAcked = sum
+		m.lintPanel = &lintPanel{findings: fs}
+		for _, f := range fs {
+			m.errs.add("warning", "lint: "+f.String())
+		}
+		m.status = fmt.Sprintf("Lint: %d finding(s); not saved yet. Enter: jump to one. %s: save anyway.",
+			len(fs), keyHint(m.keys.Save))
+		return "", true
+	}
+	return fmt.Sprintf("Lint: %d finding(s) (%s: list).\n", len(fs), keyHint(m.keys.Lint)), false
+}
+
+// updateLint handles keys while the lint panel is open.
+func (m Model) updateLint(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	p := m.lintPanel
+	switch s := msg.String(); {
+	case s == "esc" || key.Matches(msg, m.keys.Lint):
+		m.lintPanel = nil
+		return m, nil
+	case key.Matches(msg, m.keys.Save):
+		m.lintPanel = nil
+		return m.save(0)
+	case s == "up" || s == "ctrl+p":
+		if p.sel > 0 {
+			p.sel--
+		}
+	case s == "down" || s == "ctrl+n":
+		if p.sel < len(p.findings)-1 {
+			p.sel++
+		}
+	case s == "enter":
+		f := p.findings[p.sel]
+		m.gotoLine(f.Line)
+		m.status = f.String()
+		m.lintPanel = nil
+	}
+	return m, nil
+}
+
+// View renders the visible window of findings.
+func (p lintPanel) View() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "── Lint (%d) ── ↑/↓: select  Enter: jump  Esc: close", len(p.findings))
+	start := max(0, p.sel-lintRows+1)
+	for i := start; i < len(p.findings) && i < start+lintRows; i++ {
+		cursor := "  "
+		if i == p.sel {
+			cursor = "> "
+		}
+		fmt.Fprintf(&b, "\n%s%s", cursor, p.findings[i])
+	}
+	return b.String()
+}
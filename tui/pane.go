@@ -0,0 +1,100 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/viewport"
+)
+
+// maxLogEntries caps how many messages a log pane keeps.
+const maxLogEntries = 100
+
+// logEntry is one timestamped message in a log pane.
+type logEntry struct {
+	at    time.Time
+	level string // "error", "warning", "info"
+	text  string
+}
+
+// logPane accumulates recent messages and renders them either collapsed to
+// a single line or, when open, as a scrollable viewport.
+type logPane struct {
+	title    string
+	entries  []logEntry
+	open     bool
+	copyable bool // Ctrl+Y copies the latest entry
+	vp       viewport.Model
+}
+
+func newLogPane(title string, width, height int) logPane {
+	return logPane{title: title, vp: viewport.New(width, height)}
+}
+
+// add appends a message, dropping the oldest beyond maxLogEntries, and keeps
+// the viewport scrolled to the newest entry.
+func (p *logPane) add(level, text string) {
+	p.entries = append(p.entries, logEntry{at: time.Now(), level: level, text: text})
+	if len(p.entries) > maxLogEntries {
+		p.entries = p.entries[len(p.entries)-maxLogEntries:]
+	}
+	p.vp.SetContent(p.content())
+	p.vp.GotoBottom()
+}
+
+// latest returns the newest entry.
+func (p logPane) latest() (logEntry, bool) {
+	if len(p.entries) == 0 {
+		return logEntry{}, false
+	}
+	return p.entries[len(p.entries)-1], true
+}
+
+// content renders all entries, oldest first, wrapped to the viewport width.
+func (p logPane) content() string {
+	var b strings.Builder
+	for i, e := range p.entries {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		head := fmt.Sprintf("%s %-7s ", e.at.Format("15:04:05"), strings.ToUpper(e.level))
+		indent := strings.Repeat(" ", len(head))
+		for j, line := range wrap(e.text, p.vp.Width-len(head)) {
+			if j == 0 {
+				b.WriteString(head + line)
+			} else {
+				b.WriteString("\n" + indent + line)
+			}
+		}
+	}
+	return b.String()
+}
+
+// View renders the open pane with a header line and the scrollable entries.
+func (p logPane) View() string {
+	keys := "↑/↓ PgUp/PgDn: scroll  "
+	if p.copyable {
+		keys += "Ctrl+Y: copy latest  "
+	}
+	header := fmt.Sprintf("── %s (%d) ── %sEsc: close", p.title, len(p.entries), keys)
+	return header + "\n" + p.vp.View()
+}
+
+// wrap hard-wraps s to lines of at most width runes, honoring existing
+// newlines.
+func wrap(s string, width int) []string {
+	if width < 10 {
+		width = 10
+	}
+	var out []string
+	for _, line := range strings.Split(s, "\n") {
+		r := []rune(line)
+		for len(r) > width {
+			out = append(out, string(r[:width]))
+			r = r[width:]
+		}
+		out = append(out, string(r))
+	}
+	return out
+}
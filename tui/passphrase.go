@@ -0,0 +1,75 @@
+package tui
+
+import (
+	"fmt"
+
+	"filippo.io/age"
+	agepkg "github.com/andreweick/agepad/age"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// passPrompt asks for the passphrase of a new passphrase-mode file, twice,
+// before its first save.
+type passPrompt struct {
+	input textinput.Model
+	first string // the first entry; the prompt then asks to confirm it
+}
+
+// askPassphrase opens the passphrase prompt.
+func (m *Model) askPassphrase() tea.Cmd {
+	m.passPrompt = &passPrompt{input: newSecretInput("Passphrase: ")}
+	m.pendingConfirm = false
+	m.status = "Choose a passphrase for " + m.cfg.FilePath + " (Enter: next, Esc: cancel)"
+	return m.passPrompt.input.Focus()
+}
+
+func newSecretInput(prompt string) textinput.Model {
+	in := textinput.New()
+	in.Prompt = prompt
+	in.EchoMode = textinput.EchoPassword
+	in.EchoCharacter = '•'
+	in.Width = 40
+	return in
+}
+
+// updatePassphrase handles keys while the passphrase prompt is open. Once
+// both entries match, the passphrase becomes the file's only recipient and
+// the save continues.
+func (m Model) updatePassphrase(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	p := m.passPrompt
+	switch msg.String() {
+	case "esc":
+		m.passPrompt = nil
+		m.status = "Save cancelled; no passphrase set."
+		return m, nil
+	case "enter":
+		entry := p.input.Value()
+		if entry == "" {
+			return m, nil
+		}
+		if p.first == "" {
+			p.first = entry
+			p.input = newSecretInput("Confirm passphrase: ")
+			m.status = "Type the passphrase again to confirm it."
+			return m, p.input.Focus()
+		}
+		if entry != p.first {
+			m.err = fmt.Errorf("passphrases do not match; try again")
+			return m, m.askPassphrase()
+		}
+		id, r, err := agepkg.Passphrase(entry)
+		if err != nil {
+			m.err = err
+			return m, m.askPassphrase()
+		}
+		m.passPrompt = nil
+		m.err = nil
+		m.recips = []age.Recipient{r}
+		m.identities = append(m.identities, id)
+		return m.save(0)
+	}
+	var cmd tea.Cmd
+	p.input, cmd = p.input.Update(msg)
+	return m, cmd
+}
@@ -0,0 +1,72 @@
+package tui
+
+import (
+	"sync"
+
+	"filippo.io/age/plugin"
+	agepkg "github.com/andreweick/agepad/age"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// handback tracks whether the terminal has been handed to an age plugin.
+// A plugin runs inside a save (or the re-authentication before one), which
+// blocks the editor until it returns, so the screen cannot show its
+// prompts. The terminal is released for the first prompt or touch notice
+// and taken back when the update that ran the plugin finishes.
+var handback struct {
+	sync.Mutex
+	p        *tea.Program
+	released bool
+}
+
+// PluginUI returns age plugin callbacks for use while p runs: the usual
+// terminal prompts (PIN, confirmation, "touch your key") shown on the
+// normal screen, after which the editor comes back as it was.
+func PluginUI(p *tea.Program) plugin.ClientUI {
+	handback.Lock()
+	handback.p = p
+	handback.Unlock()
+
+	term := agepkg.TerminalPluginUI()
+	return plugin.ClientUI{
+		DisplayMessage: func(name, message string) error {
+			releaseTerminal()
+			return term.DisplayMessage(name, message)
+		},
+		RequestValue: func(name, prompt string, secret bool) (string, error) {
+			releaseTerminal()
+			return term.RequestValue(name, prompt, secret)
+		},
+		Confirm: func(name, prompt, yes, no string) (bool, error) {
+			releaseTerminal()
+			return term.Confirm(name, prompt, yes, no)
+		},
+		// Runs on its own goroutine while the plugin waits.
+		WaitTimer: func(name string) {
+			releaseTerminal()
+			term.WaitTimer(name)
+		},
+	}
+}
+
+func releaseTerminal() {
+	handback.Lock()
+	defer handback.Unlock()
+	if handback.p == nil || handback.released {
+		return
+	}
+	if handback.p.ReleaseTerminal() == nil {
+		handback.released = true
+	}
+}
+
+// restoreTerminal takes the terminal back after a plugin had it.
+func restoreTerminal() {
+	handback.Lock()
+	defer handback.Unlock()
+	if !handback.released {
+		return
+	}
+	handback.released = false
+	_ = handback.p.RestoreTerminal()
+}
@@ -0,0 +1,201 @@
+package tui
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"filippo.io/age"
+	agepkg "github.com/andreweick/agepad/age"
+)
+
+// recipWatch notices when the recipients file changes during a session (a
+// teammate's key merged in, say), so a save doesn't silently encrypt to
+// the set loaded at startup.
+type recipWatch struct {
+	path    string
+	loaded  [sha256.Size]byte // file content the current recipients came from
+	seen    [sha256.Size]byte // last content polled
+	lines   []string
+	pending bool // differs from loaded and not reloaded yet
+	acked   bool // user chose to save with the loaded set anyway
+	added   int
+	removed int
+}
+
+// newRecipWatch starts watching path; an unreadable file is not watched.
+func newRecipWatch(path string) recipWatch {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return recipWatch{}
+	}
+	w := recipWatch{path: path}
+	w.reset(b)
+	return w
+}
+
+// reset records b as the content the current recipients came from.
+func (w *recipWatch) reset(b []byte) {
+	w.loaded = sha256.Sum256(b)
+	w.seen = w.loaded
+	w.lines = recipientLines(string(b))
+	w.pending, w.acked = false, false
+	w.added, w.removed = 0, 0
+}
+
+// poll re-reads the file and reports whether a new change appeared.
+func (w *recipWatch) poll() bool {
+	if w.path == "" {
+		return false
+	}
+	b, err := os.ReadFile(w.path)
+	if err != nil {
+		return false
+	}
+	sum := sha256.Sum256(b)
+	if sum == w.seen {
+		return false
+	}
+	w.seen = sum
+	w.pending = sum != w.loaded
+	w.acked = false
+	w.added, w.removed = diffLines(w.lines, recipientLines(string(b)))
+	return w.pending
+}
+
+// recipientLines returns the recipient lines of a recipients file.
+func recipientLines(s string) []string {
+	var out []string
+	for _, l := range strings.Split(s, "\n") {
+		l = strings.TrimSpace(l)
+		if l != "" && !strings.HasPrefix(l, "#") {
+			out = append(out, l)
+		}
+	}
+	return out
+}
+
+// diffLines counts lines only in new (added) and only in old (removed).
+func diffLines(old, new []string) (added, removed int) {
+	for _, l := range new {
+		if !slices.Contains(old, l) {
+			added++
+		}
+	}
+	for _, l := range old {
+		if !slices.Contains(new, l) {
+			removed++
+		}
+	}
+	return added, removed
+}
+
+// summary describes the pending change, e.g. "+1/-0 recipients".
+func (w recipWatch) summary() string {
+	return fmt.Sprintf("+%d/-%d recipients", w.added, w.removed)
+}
+
+// recipientsPreview lists the recipients a save of path encrypts to, with
+// their fingerprints, and compares next (the header the save writes) with
+// the header of the file on disk. changed reports a difference the header
+// can show: a different number or kind of stanzas, or an SSH key added or
+// removed. X25519 stanzas do not name their key, so swapping one age key
+// for another only shows in the list.
+func (m Model) recipientsPreview(path string, next agepkg.Header) (preview string, changed bool) {
+	var b strings.Builder
+	old, err := m.inspectHeader(path)
+	if err != nil {
+		old = agepkg.Header{}
+	}
+	var entries []agepkg.LabeledRecipient
+	if m.watch.path != "" {
+		entries, _, _ = agepkg.ParseLabeledRecipientsSkipping(strings.NewReader(strings.Join(m.watch.lines, "\n")))
+	}
+	fmt.Fprintf(&b, "Encrypting to %d recipient(s)", len(next.Stanzas))
+	if len(entries) == len(next.Stanzas) {
+		b.WriteString(":")
+		for _, e := range entries {
+			note := ""
+			if found, known := e.InHeader(old); known && !found && err == nil {
+				note = "  (new)"
+			}
+			fmt.Fprintf(&b, "\n  %-24s %s%s", recipName(e), e.Fingerprint(), note)
+		}
+	} else {
+		b.WriteString(".")
+	}
+	if err != nil {
+		return b.String() + "\nNew file: no current header to compare with.", false
+	}
+
+	var diffs []string
+	count := func(h agepkg.Header) map[string]int {
+		n := map[string]int{}
+		for _, t := range h.StanzaTypes() {
+			n[t]++
+		}
+		return n
+	}
+	was, now := count(old), count(next)
+	types := make([]string, 0, len(was)+len(now))
+	for t := range was {
+		types = append(types, t)
+	}
+	for t := range now {
+		if _, ok := was[t]; !ok {
+			types = append(types, t)
+		}
+	}
+	slices.Sort(types)
+	for _, t := range types {
+		if was[t] != now[t] {
+			diffs = append(diffs, fmt.Sprintf("%s %d -> %d", t, was[t], now[t]))
+		}
+	}
+	for _, e := range entries {
+		if found, known := e.InHeader(old); known && !found {
+			diffs = append(diffs, recipName(e)+" added")
+		}
+	}
+	if len(diffs) == 0 {
+		return b.String() + "\nSame number and kinds of recipients as the file on disk.", false
+	}
+	return b.String() + "\nDiffers from the file on disk: " + strings.Join(diffs, ", ") + ".", true
+}
+
+// loadRecipients loads the recipients file at path. With
+// --skip-invalid-recipients, lines that cannot be used are left out and
+// logged as warnings, and every save asks first, naming them.
+func (m *Model) loadRecipients(path string) ([]age.Recipient, error) {
+	if !m.cfg.SkipInvalidRecipients {
+		return agepkg.LoadRecipients(path)
+	}
+	recips, skipped, err := agepkg.LoadRecipientsSkipping(path)
+	if err != nil {
+		return nil, err
+	}
+	m.setSkipped(path, skipped)
+	return recips, nil
+}
+
+func (m *Model) setSkipped(path string, skipped []*agepkg.RecipientLineError) {
+	m.skipped = skipped
+	for _, s := range skipped {
+		m.errs.add("warning", fmt.Sprintf("%s: skipping %v; saves will NOT be encrypted to it", path, s))
+	}
+}
+
+// skippedWarning names the recipients file lines a save leaves out.
+func (m Model) skippedWarning() string {
+	if len(m.skipped) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "WARNING: NOT encrypting to %d skipped line(s) of %s:", len(m.skipped), m.watch.path)
+	for _, s := range m.skipped {
+		b.WriteString("\n  " + s.Error())
+	}
+	return b.String()
+}
@@ -0,0 +1,246 @@
+package tui
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+
+	agepkg "github.com/andreweick/agepad/age"
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// recipPanel is the Alt+R list of the recipients a save encrypts to. Lines
+// added or removed here are written to the recipients file at once, and
+// the next save uses them.
+type recipPanel struct {
+	entries []agepkg.LabeledRecipient
+	sel     int
+	input   *textinput.Model // the line being added, while open
+	remove  bool             // the selected line was asked to be removed once
+}
+
+// openRecipients lists the recipients file of the session. Sessions
+// without one (view-only, passphrase, directory mode) have no panel.
+func (m *Model) openRecipients() tea.Cmd {
+	if m.watch.path == "" || len(m.bundlePaths) > 0 {
+		m.status = "No recipients file in this session."
+		return nil
+	}
+	b, err := os.ReadFile(m.watch.path)
+	if err != nil {
+		m.err = err
+		return nil
+	}
+	entries, err := m.parseRecipients(b)
+	if err != nil {
+		m.err = fmt.Errorf("%s: %w", m.watch.path, err)
+		return nil
+	}
+	m.recipPanel = &recipPanel{entries: entries}
+	return nil
+}
+
+// updateRecipients handles keys while the recipients panel is open.
+func (m Model) updateRecipients(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	p := m.recipPanel
+	if p.input != nil {
+		switch msg.String() {
+		case "esc":
+			p.input = nil
+			return m, nil
+		case "enter":
+			line := strings.TrimSpace(p.input.Value())
+			p.input = nil
+			if line != "" {
+				m.addRecipient(line)
+			}
+			return m, nil
+		}
+		var cmd tea.Cmd
+		*p.input, cmd = p.input.Update(msg)
+		return m, cmd
+	}
+	switch s := msg.String(); {
+	case s == "esc" || key.Matches(msg, m.keys.Recipients):
+		m.recipPanel = nil
+		return m, nil
+	case s == "up" || s == "ctrl+p":
+		if p.sel > 0 {
+			p.sel--
+		}
+		p.remove = false
+	case s == "down" || s == "ctrl+n":
+		if p.sel < len(p.entries)-1 {
+			p.sel++
+		}
+		p.remove = false
+	case s == "a":
+		in := textinput.New()
+		in.Prompt = "Add: "
+		in.Placeholder = "age1... # name, or ssh-ed25519 AAAA... name"
+		in.Width = 80
+		p.input = &in
+		p.remove = false
+		return m, p.input.Focus()
+	case s == "d" || s == "delete":
+		if len(p.entries) == 0 {
+			return m, nil
+		}
+		if !p.remove {
+			p.remove = true
+			m.status = fmt.Sprintf("Remove %s from %s? Press %s again.", recipName(p.entries[p.sel]), m.watch.path, s)
+			return m, nil
+		}
+		p.remove = false
+		m.removeRecipient(p.entries[p.sel].Key)
+	}
+	return m, nil
+}
+
+// addRecipient appends line to the recipients file after checking that it
+// parses and is not already there.
+func (m *Model) addRecipient(line string) {
+	added, err := agepkg.ParseLabeledRecipients(strings.NewReader(line))
+	if err == nil && len(added) == 0 {
+		err = fmt.Errorf("not a recipient line")
+	}
+	if err != nil {
+		m.err = fmt.Errorf("add recipient: %w", err)
+		return
+	}
+	for _, e := range m.recipPanel.entries {
+		if e.Key == added[0].Key {
+			m.status = fmt.Sprintf("%s is already a recipient.", recipName(e))
+			return
+		}
+	}
+	m.rewriteRecipients(func(b []byte) []byte {
+		if len(b) > 0 && !bytes.HasSuffix(b, []byte("\n")) {
+			b = append(b, '\n')
+		}
+		return append(b, line+"\n"...)
+	}, "Added "+recipName(added[0]))
+}
+
+// removeRecipient drops the lines holding recipient k, keeping comments and
+// every other line as they were.
+func (m *Model) removeRecipient(k string) {
+	if len(m.recipPanel.entries) == 1 {
+		m.status = "Cannot remove the last recipient."
+		return
+	}
+	name := k
+	for _, e := range m.recipPanel.entries {
+		if e.Key == k {
+			name = recipName(e)
+		}
+	}
+	m.rewriteRecipients(func(b []byte) []byte {
+		var out []string
+		for _, l := range strings.SplitAfter(string(b), "\n") {
+			if lineKey(l) != k {
+				out = append(out, l)
+			}
+		}
+		return []byte(strings.Join(out, ""))
+	}, "Removed "+name)
+}
+
+// rewriteRecipients applies edit to the recipients file, writes it back
+// with its permissions, and reloads the recipients from it.
+func (m *Model) rewriteRecipients(edit func([]byte) []byte, done string) {
+	path := m.watch.path
+	fail := func(err error) {
+		m.err = fmt.Errorf("%s: %w", path, err)
+		m.status = "Recipients file not changed."
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		fail(err)
+		return
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		fail(err)
+		return
+	}
+	next := edit(b)
+	entries, err := m.parseRecipients(next)
+	if err != nil {
+		fail(err)
+		return
+	}
+	if err := agepkg.AtomicWrite(path, next); err != nil {
+		fail(err)
+		return
+	}
+	if err := os.Chmod(path, info.Mode().Perm()); err != nil {
+		m.errs.add("warning", fmt.Sprintf("%s: %v", path, err))
+	}
+	recips, err := m.loadRecipients(path)
+	if err != nil {
+		fail(err)
+		return
+	}
+	m.recips = recips
+	m.queue = nil // encrypted to the old set
+	m.valueKey = nil
+	m.watch.reset(next)
+	m.pendingConfirm = false
+	p := m.recipPanel
+	p.entries = entries
+	p.sel = min(p.sel, len(entries)-1)
+	m.status = fmt.Sprintf("%s in %s (%d recipients). %s re-encrypts this file to them.",
+		done, path, len(recips), keyHint(m.keys.Save))
+}
+
+// parseRecipients reads recipients file content, leaving out unusable
+// lines as loadRecipients does.
+func (m Model) parseRecipients(b []byte) ([]agepkg.LabeledRecipient, error) {
+	if !m.cfg.SkipInvalidRecipients {
+		return agepkg.ParseLabeledRecipients(bytes.NewReader(b))
+	}
+	entries, _, err := agepkg.ParseLabeledRecipientsSkipping(bytes.NewReader(b))
+	return entries, err
+}
+
+// lineKey is a recipients file line without its "# label" comment, as
+// ParseLabeledRecipients reads it.
+func lineKey(l string) string {
+	l = strings.TrimSpace(l)
+	if i := strings.IndexByte(l, '#'); i > 0 && (l[i-1] == ' ' || l[i-1] == '\t') {
+		l = strings.TrimSpace(l[:i])
+	}
+	return l
+}
+
+// recipName is a recipient's label, or its shortened key without one.
+func recipName(e agepkg.LabeledRecipient) string {
+	if e.Label != "" {
+		return e.Label
+	}
+	if len(e.Key) > 20 {
+		return e.Key[:16] + "…"
+	}
+	return e.Key
+}
+
+// View renders the recipients list, or the line being added.
+func (p recipPanel) View() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "── Recipients (%d) ── ↑/↓: select  a: add  d: remove  Esc: close", len(p.entries))
+	for i, e := range p.entries {
+		cursor := "  "
+		if i == p.sel {
+			cursor = "> "
+		}
+		fmt.Fprintf(&b, "\n%s%-24s %s", cursor, e.Label, e.Key)
+	}
+	if p.input != nil {
+		b.WriteString("\n" + p.input.View())
+	}
+	return b.String()
+}
@@ -0,0 +1,203 @@
+package tui
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// replacePanel is the Ctrl+F search-and-replace prompt. A pattern written
+// as /.../ is a regular expression whose replacement may use $1 or ${name};
+// anything else is matched and replaced literally.
+type replacePanel struct {
+	find, with textinput.Model
+	onWith     bool // the replacement field has focus
+
+	// Set once Enter starts stepping through matches.
+	re         *regexp.Regexp
+	literal    bool
+	pos        int   // byte offset the next search starts from
+	match      []int // submatch offsets of the current match in the buffer
+	done, skip int
+}
+
+// openReplace shows the find and replace fields.
+func (m *Model) openReplace() tea.Cmd {
+	if m.cfg.ViewOnly {
+		m.status = "View-only mode: editing disabled."
+		return nil
+	}
+	find, with := textinput.New(), textinput.New()
+	find.Prompt, with.Prompt = "Find:    ", "Replace: "
+	find.Placeholder = "text, or /regex/"
+	with.Placeholder = "replacement ($1 for regex groups)"
+	find.Width, with.Width = 60, 60
+	m.replace = &replacePanel{find: find, with: with}
+	m.pendingConfirm = false
+	return m.replace.find.Focus()
+}
+
+// updateReplace handles keys while the replace panel is open: first the
+// two fields, then y/n/a for each match.
+func (m Model) updateReplace(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	p := m.replace
+	if p.re != nil {
+		return m.stepReplace(msg.String())
+	}
+	switch s := msg.String(); {
+	case s == "esc" || key.Matches(msg, m.keys.Replace):
+		m.replace = nil
+		return m, nil
+	case s == "tab" || s == "shift+tab" || s == "up" || s == "down":
+		p.onWith = !p.onWith
+		if p.onWith {
+			p.find.Blur()
+			return m, p.with.Focus()
+		}
+		p.with.Blur()
+		return m, p.find.Focus()
+	case s == "enter":
+		if !p.onWith {
+			p.onWith = true
+			p.find.Blur()
+			return m, p.with.Focus()
+		}
+		if err := p.compile(); err != nil {
+			m.err = err
+			return m, nil
+		}
+		m.err = nil
+		if !m.nextMatch() {
+			m.replace = nil
+			m.status = "No matches."
+		}
+		return m, nil
+	}
+	var cmd tea.Cmd
+	if p.onWith {
+		p.with, cmd = p.with.Update(msg)
+	} else {
+		p.find, cmd = p.find.Update(msg)
+	}
+	return m, cmd
+}
+
+// compile reads the pattern. Patterns that match the empty string would
+// stop on every character and are refused.
+func (p *replacePanel) compile() error {
+	pat := p.find.Value()
+	expr := regexp.QuoteMeta(pat)
+	p.literal = true
+	if len(pat) > 2 && strings.HasPrefix(pat, "/") && strings.HasSuffix(pat, "/") {
+		expr, p.literal = pat[1:len(pat)-1], false
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return fmt.Errorf("replace: %w", err)
+	}
+	if re.MatchString("") {
+		return errors.New("replace: the pattern matches empty text")
+	}
+	p.re = re
+	return nil
+}
+
+// stepReplace answers the question for the current match.
+func (m Model) stepReplace(answer string) (tea.Model, tea.Cmd) {
+	p := m.replace
+	switch answer {
+	case "y", "enter":
+		m.replaceMatch()
+	case "n":
+		p.pos = p.match[1]
+		p.skip++
+	case "a":
+		for {
+			m.replaceMatch()
+			if !m.nextMatch() {
+				break
+			}
+		}
+		return m.finishReplace()
+	case "esc", "q":
+		return m.finishReplace()
+	default:
+		return m, nil
+	}
+	if !m.nextMatch() {
+		return m.finishReplace()
+	}
+	return m, nil
+}
+
+// nextMatch finds the next match from p.pos and puts the cursor on it.
+// The pattern runs over the whole buffer, so ^, \A, and \b see the text
+// before p.pos instead of anchoring at it.
+func (m *Model) nextMatch() bool {
+	p, buf := m.replace, m.text()
+	var loc []int
+	for _, l := range p.re.FindAllStringSubmatchIndex(buf, -1) {
+		if l[0] >= p.pos {
+			loc = l
+			break
+		}
+	}
+	if loc == nil {
+		return false
+	}
+	p.match = loc
+	before := buf[:loc[0]]
+	m.gotoLine(strings.Count(before, "\n") + 1)
+	m.ta.SetCursor(len([]rune(before[strings.LastIndex(before, "\n")+1:])))
+	m.status = fmt.Sprintf("Replace this match (line %d)? y: replace  n: skip  a: all remaining  Esc: stop",
+		m.line()+1)
+	return true
+}
+
+// replaceMatch replaces the current match and continues after the
+// inserted text, so a replacement is never matched again.
+func (m *Model) replaceMatch() {
+	p, buf := m.replace, m.text()
+	with := p.with.Value()
+	if !p.literal {
+		with = string(p.re.ExpandString(nil, with, buf, p.match))
+	}
+	m.setText(buf[:p.match[0]] + with + buf[p.match[1]:])
+	p.pos = p.match[0] + len(with)
+	p.done++
+	m.changed = true
+	m.pendingConfirm = false
+	m.queue = nil
+}
+
+// finishReplace closes the panel, leaving the cursor after the last
+// replacement.
+func (m Model) finishReplace() (tea.Model, tea.Cmd) {
+	p := m.replace
+	m.replace = nil
+	if p.done > 0 {
+		before := m.text()[:p.pos]
+		m.gotoLine(strings.Count(before, "\n") + 1)
+		m.ta.SetCursor(len([]rune(before[strings.LastIndex(before, "\n")+1:])))
+	}
+	m.status = fmt.Sprintf("Replaced %d, skipped %d.", p.done, p.skip)
+	if p.done > 0 {
+		m.status += " Ctrl+D shows the changes; Ctrl+S saves."
+	}
+	return m, nil
+}
+
+// View renders the fields, or the pattern being stepped through.
+func (p replacePanel) View() string {
+	if p.re != nil {
+		return fmt.Sprintf("── Replace %s with %q ── y: replace  n: skip  a: all  Esc: stop",
+			p.find.Value(), p.with.Value())
+	}
+	return "── Replace ── Tab: switch field  Enter: start  Esc: close\n" +
+		p.find.View() + "\n" + p.with.View()
+}
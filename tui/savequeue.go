@@ -0,0 +1,125 @@
+package tui
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	agepkg "github.com/andreweick/agepad/age"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// queuedWrite is one file's ciphertext, encrypted and preflight-checked,
+// waiting to be written.
+type queuedWrite struct {
+	path   string
+	cipher []byte
+}
+
+// saveQueue holds a save whose write failed. It keeps the ciphertext that
+// passed the preflight, so Ctrl+S retries (and Ctrl+X saves elsewhere)
+// without re-encrypting or re-confirming. Editing the buffer or reloading
+// recipients drops it.
+type saveQueue struct {
+	writes  []queuedWrite // not yet written, in order
+	total   int           // files in the save
+	warning string        // validation warnings to keep in the final status
+	armor   string        // "true", "false", or "kept" (each file's own, --armor=auto)
+}
+
+// flushQueue writes the queued files in order, stopping at the first
+// failure with the rest kept for a retry.
+func (m Model) flushQueue() (tea.Model, tea.Cmd) {
+	q := m.queue
+	m.pendingConfirm = false
+	for len(q.writes) > 0 {
+		w := q.writes[0]
+		write := agepkg.AtomicWrite
+		if m.write != nil {
+			write = m.write
+		}
+		if err := write(w.path, w.cipher); err != nil {
+			if len(m.bundlePaths) > 0 {
+				err = fmt.Errorf("%s: %w", w.path, err)
+			}
+			m.err = err
+			m.status = m.queueStatus(err)
+			return m, nil
+		}
+		q.writes = q.writes[1:]
+	}
+	m.queue = nil
+	m.err = nil
+	buf := m.text()
+	if orig, err := m.original(); err != nil {
+		m.errs.add("warning", "changed keys not counted: "+err.Error())
+	} else {
+		for _, k := range m.bufferChangedKeys(orig, buf) {
+			m.changedKeys[k] = true
+		}
+	}
+	if m.afterSave != nil {
+		units, _ := m.saveUnits(buf) // split cleanly before the write
+		for _, u := range units {
+			if err := m.afterSave(u.path, u.content); err != nil {
+				q.warning += "Warning: " + err.Error() + "\n"
+				m.errs.add("warning", err.Error())
+			}
+		}
+	}
+	m.saves++
+	m.savedAt = time.Now()
+	if len(m.bundlePaths) == 0 {
+		m.status = q.warning + fmt.Sprintf("Saved %s (armor=%s) at %s",
+			m.cfg.FilePath, q.armor, m.savedAt.Format(time.RFC3339))
+	} else {
+		m.status = q.warning + fmt.Sprintf("Saved %d changed of %d files under %s (armor=%s) at %s",
+			q.total, len(m.bundlePaths), m.cfg.FilePath, q.armor, m.savedAt.Format(time.RFC3339))
+	}
+	m.setOrig(buf)
+	m.changed = false
+	return m, m.notify("Saved " + filepath.Base(m.cfg.FilePath))
+}
+
+// WithAfterSave runs fn with the path and plaintext of each file a save
+// writes, once the writes succeed; agepad uses it to regenerate derived
+// files. An error is shown as a warning: the save itself stands.
+func (m Model) WithAfterSave(fn func(path, plain string) error) Model {
+	m.afterSave = fn
+	return m
+}
+
+// queueStatus explains a failed write and what can be done about it.
+func (m Model) queueStatus(err error) string {
+	s := "Save failed"
+	if done := m.queue.total - len(m.queue.writes); done > 0 {
+		s = fmt.Sprintf("Save failed after writing %d of %d files", done, m.queue.total)
+	}
+	if agepkg.IsTransient(err) {
+		s += " (the file is busy or locked; a retry may succeed)"
+	}
+	s += ". The encrypted copy is kept in RAM. Ctrl+S: retry"
+	if len(m.bundlePaths) == 0 {
+		s += "  Ctrl+X: save as"
+	}
+	return s
+}
+
+// saveAs writes the queued ciphertext to a new file, which becomes the
+// file later saves go to.
+func (m Model) saveAs(path string) (tea.Model, tea.Cmd) {
+	if _, err := os.Stat(path); err == nil {
+		m.err = fmt.Errorf("%s already exists; save as only writes a new file", path)
+		return m, nil
+	}
+	if fi, err := os.Stat(filepath.Dir(path)); err != nil || !fi.IsDir() {
+		m.err = fmt.Errorf("directory %s does not exist", filepath.Dir(path))
+		return m, nil
+	}
+	m.pathInput = nil
+	m.err = nil
+	m.queue.writes[0].path = path
+	m.cfg.FilePath = path
+	return m.flushQueue()
+}
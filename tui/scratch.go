@@ -0,0 +1,115 @@
+package tui
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"unsafe"
+
+	"filippo.io/age"
+	"github.com/andreweick/agepad/model"
+	"github.com/charmbracelet/bubbles/textarea"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// NewScratchModel creates an editor with an empty buffer and no backing
+// file. Ctrl+S asks for a path to encrypt to; quitting without saving
+// discards the buffer and zeroizes it.
+func NewScratchModel(cfg model.Config, ids []age.Identity, recips []age.Recipient) Model {
+	cfg.Scratch, cfg.FilePath = true, ""
+	m := NewModel(cfg, "", ids, recips)
+	m.ta.Placeholder = "Scratchpad: nothing here is written anywhere unless you save it…"
+	m.status = "Scratchpad (RAM, no file). Ctrl+S: encrypt to a file  Ctrl+Y: copy value  Ctrl+Q: discard"
+	m.history.entries = nil
+	m.history.add("info", m.status)
+	return m
+}
+
+// askPath opens the prompt for a new file's path: the file a scratchpad is
+// saved to, or where a queued write is saved as.
+func (m *Model) askPath(prompt, placeholder, status string) tea.Cmd {
+	in := textinput.New()
+	in.Prompt = prompt
+	in.Placeholder = placeholder
+	in.Width = 60
+	m.pathInput = &in
+	m.pendingConfirm = false
+	m.status = status
+	return in.Focus()
+}
+
+// updatePath handles keys while the path prompt is open. Enter names the
+// file and continues the save; it must not exist yet, so a scratchpad never
+// overwrites anything.
+func (m Model) updatePath(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "esc":
+		m.pathInput = nil
+		m.status = "Save cancelled; the scratchpad is still only in RAM."
+		if m.queue != nil {
+			m.status = "Save as cancelled; the encrypted copy is still queued. Ctrl+S: retry"
+		}
+		return m, nil
+	case "enter":
+		path := strings.TrimSpace(m.pathInput.Value())
+		if path == "" {
+			return m, nil
+		}
+		if m.queue != nil {
+			return m.saveAs(path)
+		}
+		if _, err := os.Stat(path); err == nil {
+			m.err = fmt.Errorf("%s already exists; a scratchpad only saves to a new file", path)
+			return m, nil
+		}
+		if fi, err := os.Stat(filepath.Dir(path)); err != nil || !fi.IsDir() {
+			m.err = fmt.Errorf("directory %s does not exist", filepath.Dir(path))
+			return m, nil
+		}
+		if len(m.recips) == 0 && !m.cfg.Passphrase {
+			m.err = fmt.Errorf("no recipients loaded from %s; restart with --recipients-file", m.cfg.RecipientsFile)
+			return m, nil
+		}
+		m.pathInput = nil
+		m.err = nil
+		m.cfg.FilePath = path
+		return m.save(0)
+	}
+	in, cmd := m.pathInput.Update(msg)
+	m.pathInput = &in
+	return m, cmd
+}
+
+// discard zeroizes the buffer and any copies the model keeps of it.
+func (m *Model) discard() {
+	zeroTextarea(&m.ta)
+	m.ta.Reset()
+	if m.doc != nil {
+		_ = m.doc.Seal("")
+		m.winStart, m.winLen = 0, 1
+	}
+	m.setOrig("")
+	m.setSnapshot("")
+	clear(m.register)
+	m.snapshots = nil
+}
+
+// zeroTextarea overwrites the textarea's rune storage in place. The
+// textarea keeps its lines in an unexported [][]rune, reached here through
+// reflection; it reports false when the field is not there. Copies made
+// while editing (rows reallocated as they grew, rendered views) are left to
+// the garbage collector.
+func zeroTextarea(ta *textarea.Model) bool {
+	v := reflect.ValueOf(ta).Elem().FieldByName("value")
+	if !v.IsValid() || v.Type() != reflect.TypeOf([][]rune(nil)) {
+		return false
+	}
+	rows := *(*[][]rune)(unsafe.Pointer(v.UnsafeAddr()))
+	for _, row := range rows {
+		clear(row[:cap(row)])
+	}
+	return true
+}
@@ -0,0 +1,183 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// selection is a span of the buffer between an anchor and the cursor.
+// Shift+arrows start one that plain movement ends; Alt+V starts a sticky
+// one that plain movement extends until it is used or cancelled.
+type selection struct {
+	row, col int // the anchor, in runes
+	sticky   bool
+}
+
+// unshifted maps the Shift+movement keys to the textarea's own movement.
+var unshifted = map[string]tea.KeyType{
+	"shift+left":  tea.KeyLeft,
+	"shift+right": tea.KeyRight,
+	"shift+up":    tea.KeyUp,
+	"shift+down":  tea.KeyDown,
+	"shift+home":  tea.KeyHome,
+	"shift+end":   tea.KeyEnd,
+}
+
+// selectionKey handles the selection and register keys, and movement
+// while a selection is open. Cut, copy, and paste use an editor register
+// that never leaves the process; only Copy (Ctrl+Y) puts a selection on
+// the system clipboard, where it is cleared like a copied value. It
+// reports whether it handled msg.
+func (m *Model) selectionKey(msg tea.KeyMsg) (bool, tea.Cmd) {
+	if !m.ta.Focused() {
+		return false, nil
+	}
+	if k, ok := unshifted[msg.String()]; ok {
+		if m.sel == nil {
+			m.startSelection(false)
+		}
+		m.updateTextarea(tea.KeyMsg{Type: k})
+		m.selectionStatus()
+		return true, nil
+	}
+	switch {
+	case key.Matches(msg, m.keys.Select):
+		if m.sel != nil {
+			m.sel = nil
+			m.status = "Selection cancelled."
+			return true, nil
+		}
+		m.startSelection(true)
+		m.selectionStatus()
+		return true, nil
+
+	case key.Matches(msg, m.keys.Paste):
+		if len(m.register) == 0 {
+			m.status = fmt.Sprintf("The register is empty; %s or %s fills it.", keyHint(m.keys.CopySelection), keyHint(m.keys.Cut))
+			return true, nil
+		}
+		if m.sel != nil {
+			m.cutSelection()
+		}
+		m.ta.InsertString(string(m.register))
+		m.edited()
+		m.status = fmt.Sprintf("Pasted %d characters.", len(m.register))
+		return true, nil
+
+	case m.sel == nil:
+		return false, nil
+
+	case msg.Type == tea.KeyEsc:
+		m.sel = nil
+		m.status = "Selection cancelled."
+		return true, nil
+
+	case key.Matches(msg, m.keys.CopySelection):
+		m.register = m.selected()
+		m.sel = nil
+		m.status = fmt.Sprintf("Copied %d characters to the register; %s pastes.", len(m.register), keyHint(m.keys.Paste))
+		return true, nil
+
+	case key.Matches(msg, m.keys.Cut):
+		m.register = m.cutSelection()
+		m.edited()
+		m.status = fmt.Sprintf("Cut %d characters to the register; %s pastes.", len(m.register), keyHint(m.keys.Paste))
+		return true, nil
+
+	case key.Matches(msg, m.keys.Copy):
+		text := string(m.selected())
+		m.sel = nil
+		return true, m.copy(text, "selection")
+
+	case m.sel.sticky && m.isMovement(msg):
+		m.updateTextarea(msg)
+		m.selectionStatus()
+		return true, nil
+	}
+	// Anything else (typing, plain movement after Shift) ends the selection
+	// and goes on as usual.
+	m.sel = nil
+	return false, nil
+}
+
+func (m *Model) startSelection(sticky bool) {
+	row, col := m.cursor()
+	m.sel = &selection{row: row, col: col, sticky: sticky}
+}
+
+func (m Model) isMovement(msg tea.KeyMsg) bool {
+	k := m.ta.KeyMap
+	return key.Matches(msg, k.CharacterForward, k.CharacterBackward, k.LineNext, k.LinePrevious,
+		k.WordForward, k.WordBackward, k.LineStart, k.LineEnd, k.InputBegin, k.InputEnd)
+}
+
+// cursor returns the cursor's line and rune column.
+func (m Model) cursor() (row, col int) {
+	info := m.ta.LineInfo()
+	return m.line(), info.StartColumn + info.ColumnOffset
+}
+
+// selectedRange returns the selection as rune offsets into buf, in order.
+func (m Model) selectedRange(buf []rune) (start, end int) {
+	offset := func(row, col int) int {
+		off := 0
+		for i, line := range strings.Split(string(buf), "\n") {
+			if i == row {
+				return min(off+col, len(buf))
+			}
+			off += len([]rune(line)) + 1
+		}
+		return len(buf)
+	}
+	row, col := m.cursor()
+	start, end = offset(m.sel.row, m.sel.col), offset(row, col)
+	if start > end {
+		start, end = end, start
+	}
+	return start, end
+}
+
+// selected returns a copy of the selected text.
+func (m Model) selected() []rune {
+	buf := []rune(m.text())
+	start, end := m.selectedRange(buf)
+	return append([]rune(nil), buf[start:end]...)
+}
+
+// cutSelection removes the selected text, leaves the cursor where it
+// was, and returns it.
+func (m *Model) cutSelection() []rune {
+	buf := []rune(m.text())
+	start, end := m.selectedRange(buf)
+	cut := append([]rune(nil), buf[start:end]...)
+	m.sel = nil
+	before := string(buf[:start])
+	m.setText(before + string(buf[end:]))
+	m.gotoLine(strings.Count(before, "\n") + 1)
+	m.ta.SetCursor(len([]rune(before[strings.LastIndex(before, "\n")+1:])))
+	return cut
+}
+
+// edited records a change made outside the textarea's own keys.
+func (m *Model) edited() {
+	m.changed = true
+	m.pendingConfirm = false
+	m.queue = nil
+}
+
+// selectionStatus describes the open selection and the keys that use it.
+func (m *Model) selectionStatus() {
+	buf := []rune(m.text())
+	start, end := m.selectedRange(buf)
+	row, _ := m.cursor()
+	first, last := min(m.sel.row, row)+1, max(m.sel.row, row)+1
+	lines := fmt.Sprintf("line %d", first)
+	if last > first {
+		lines = fmt.Sprintf("lines %d-%d", first, last)
+	}
+	m.status = fmt.Sprintf("Selected %d characters on %s. %s: copy  %s: cut  %s: to clipboard  Esc: cancel",
+		end-start, lines, keyHint(m.keys.CopySelection), keyHint(m.keys.Cut), keyHint(m.keys.Copy))
+}
@@ -0,0 +1,186 @@
+package tui
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/andreweick/agepad/diff"
+	"github.com/andreweick/agepad/sealed"
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// snapshot is a named copy of the buffer, kept in memory for the session
+// only. In paranoid mode the text is sealed like the last-saved copy.
+type snapshot struct {
+	name   string
+	at     time.Time
+	text   string
+	sealed *sealed.Buffer
+}
+
+// value returns the snapshot's text, opening it in paranoid mode.
+func (s snapshot) value() (string, error) {
+	if s.sealed == nil {
+		return s.text, nil
+	}
+	return s.sealed.String()
+}
+
+// snapPanel is the Alt+S list of the session's snapshots.
+type snapPanel struct {
+	sel     int
+	input   *textinput.Model // the name of a new snapshot, while open
+	restore bool             // the selected snapshot was asked to be restored once
+}
+
+// takeSnapshot stores the buffer under name.
+func (m *Model) takeSnapshot(name string) error {
+	s := snapshot{name: name, at: time.Now()}
+	if m.origSealed == nil {
+		s.text = m.text()
+	} else {
+		b, err := sealed.New()
+		if err != nil {
+			return err
+		}
+		if err := b.Seal(m.text()); err != nil {
+			return err
+		}
+		s.sealed = b
+	}
+	m.snapshots = append(m.snapshots, s)
+	return nil
+}
+
+// updateSnapshots handles keys while the snapshots panel is open.
+func (m Model) updateSnapshots(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	p := m.snapPanel
+	if p.input != nil {
+		switch msg.String() {
+		case "esc":
+			p.input = nil
+			return m, nil
+		case "enter":
+			name := strings.TrimSpace(p.input.Value())
+			p.input = nil
+			if name == "" {
+				name = fmt.Sprintf("snapshot %d", len(m.snapshots)+1)
+			}
+			if err := m.takeSnapshot(name); err != nil {
+				m.err = fmt.Errorf("snapshot: %w", err)
+				return m, nil
+			}
+			p.sel = len(m.snapshots) - 1
+			m.status = fmt.Sprintf("Took snapshot %q. d: diff against it  r: restore it", name)
+			return m, nil
+		}
+		var cmd tea.Cmd
+		*p.input, cmd = p.input.Update(msg)
+		return m, cmd
+	}
+	switch s := msg.String(); {
+	case s == "esc" || key.Matches(msg, m.keys.Snapshots):
+		m.snapPanel = nil
+		return m, nil
+	case s == "up" || s == "ctrl+p":
+		if p.sel > 0 {
+			p.sel--
+		}
+		p.restore = false
+	case s == "down" || s == "ctrl+n":
+		if p.sel < len(m.snapshots)-1 {
+			p.sel++
+		}
+		p.restore = false
+	case s == "n":
+		in := textinput.New()
+		in.Prompt = "Name: "
+		in.Placeholder = fmt.Sprintf("snapshot %d", len(m.snapshots)+1)
+		in.Width = 40
+		p.input = &in
+		p.restore = false
+		return m, p.input.Focus()
+	case len(m.snapshots) == 0:
+		return m, nil
+	case s == "d" || s == "enter":
+		m.snapshotDiff(m.snapshots[p.sel])
+		m.snapPanel = nil
+	case s == "r":
+		if m.cfg.ViewOnly {
+			m.status = "View-only mode: editing disabled."
+			return m, nil
+		}
+		if !p.restore {
+			p.restore = true
+			m.status = fmt.Sprintf("Replace the buffer with snapshot %q? Press r again.", m.snapshots[p.sel].name)
+			return m, nil
+		}
+		m.restoreSnapshot(m.snapshots[p.sel])
+		m.snapPanel = nil
+	}
+	return m, nil
+}
+
+// snapshotDiff shows the changes from snap to the buffer.
+func (m *Model) snapshotDiff(snap snapshot) {
+	text, err := snap.value()
+	if err != nil {
+		m.err = fmt.Errorf("snapshot %q: %w", snap.name, err)
+		return
+	}
+	name := filepath.Base(m.cfg.FilePath)
+	d := unifiedDiff(text, m.text(), fmt.Sprintf("%s (%s)", name, snap.name), name+" (buffer)", diff.Algorithm(m.cfg.DiffAlgorithm))
+	if strings.TrimSpace(d) == "" {
+		m.status = fmt.Sprintf("Buffer matches snapshot %q.", snap.name)
+		return
+	}
+	m.status = fmt.Sprintf("Diff against snapshot %q (first 2000 chars):\n", snap.name) + truncate(d, 2000)
+}
+
+// restoreSnapshot replaces the buffer with snap, first snapshotting the
+// buffer so the restore can be undone the same way.
+func (m *Model) restoreSnapshot(snap snapshot) {
+	text, err := snap.value()
+	if err != nil {
+		m.err = fmt.Errorf("snapshot %q: %w", snap.name, err)
+		return
+	}
+	if text == m.text() {
+		m.status = fmt.Sprintf("Buffer already matches snapshot %q.", snap.name)
+		return
+	}
+	undo := "before restoring " + snap.name
+	if err := m.takeSnapshot(undo); err != nil {
+		m.err = fmt.Errorf("snapshot: %w", err)
+		return
+	}
+	m.setText(text)
+	m.sel = nil
+	m.edited()
+	m.status = fmt.Sprintf("Restored snapshot %q; the previous buffer is snapshot %q. Ctrl+D shows the changes.", snap.name, undo)
+}
+
+// snapshotsView renders the snapshot list, or the name being typed.
+func (m Model) snapshotsView() string {
+	p := m.snapPanel
+	var b strings.Builder
+	fmt.Fprintf(&b, "── Snapshots (%d) ── n: new  ↑/↓: select  d: diff  r: restore  Esc: close", len(m.snapshots))
+	if len(m.snapshots) == 0 && p.input == nil {
+		b.WriteString("\n  No snapshots yet; n takes one of the buffer as it is now.")
+	}
+	for i, s := range m.snapshots {
+		cursor := "  "
+		if i == p.sel {
+			cursor = "> "
+		}
+		fmt.Fprintf(&b, "\n%s%s  %s", cursor, s.at.Format("15:04:05"), s.name)
+	}
+	if p.input != nil {
+		b.WriteString("\n" + p.input.View())
+	}
+	return b.String()
+}
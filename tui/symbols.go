@@ -0,0 +1,145 @@
+package tui
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/andreweick/agepad/outline"
+	"github.com/andreweick/agepad/validator"
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// symbolRows is how many matches the symbol panel shows at once.
+const symbolRows = 10
+
+// symbolPanel is the Ctrl+T list of keys in the buffer, narrowed by a
+// fuzzy filter.
+type symbolPanel struct {
+	input   textinput.Model
+	all     []outline.Symbol
+	matches []outline.Symbol
+	sel     int
+}
+
+// openSymbols lists the buffer's keys with the structural parser for its
+// format. A buffer that does not parse has no list.
+func (m *Model) openSymbols() tea.Cmd {
+	buf := m.text()
+	syms, err := outline.Symbols(buf, validator.Format(m.cfg.FilePath, buf, m.cfg.TypeRules))
+	if err != nil {
+		m.err = fmt.Errorf("symbol list: %w", err)
+		m.status = "Cannot list keys until the buffer parses."
+		return nil
+	}
+	if len(syms) == 0 {
+		m.status = "No keys in the buffer."
+		return nil
+	}
+	in := textinput.New()
+	in.Prompt = "Go to: "
+	in.Placeholder = "type to filter"
+	in.Width = 60
+	cmd := in.Focus()
+	m.symbols = &symbolPanel{input: in, all: syms, matches: syms}
+	return cmd
+}
+
+// updateSymbols handles keys while the symbol panel is open.
+func (m Model) updateSymbols(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	p := m.symbols
+	switch s := msg.String(); {
+	case s == "esc" || key.Matches(msg, m.keys.Keys):
+		m.symbols = nil
+		return m, nil
+	case s == "up" || s == "ctrl+p":
+		if p.sel > 0 {
+			p.sel--
+		}
+		return m, nil
+	case s == "down" || s == "ctrl+n":
+		if p.sel < len(p.matches)-1 {
+			p.sel++
+		}
+		return m, nil
+	case s == "enter":
+		if len(p.matches) > 0 {
+			s := p.matches[p.sel]
+			m.gotoLine(s.Line)
+			m.status = fmt.Sprintf("%s (line %d)", s.Name, s.Line)
+		}
+		m.symbols = nil
+		return m, nil
+	}
+	var cmd tea.Cmd
+	p.input, cmd = p.input.Update(msg)
+	p.matches = fuzzyFilter(p.all, p.input.Value())
+	p.sel = 0
+	return m, cmd
+}
+
+// View renders the filter line and the visible window of matches.
+func (p symbolPanel) View() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "── Keys (%d of %d) ── ↑/↓: select  Enter: jump  Esc: close\n%s",
+		len(p.matches), len(p.all), p.input.View())
+	start := max(0, p.sel-symbolRows+1)
+	for i := start; i < len(p.matches) && i < start+symbolRows; i++ {
+		cursor := "  "
+		if i == p.sel {
+			cursor = "> "
+		}
+		fmt.Fprintf(&b, "\n%s%-50s %5d", cursor, p.matches[i].Name, p.matches[i].Line)
+	}
+	return b.String()
+}
+
+// fuzzyFilter keeps the symbols whose name contains query's characters in
+// order (case-insensitive), best matches first: contiguous runs and word
+// starts score higher, then shorter names; ties keep document order.
+func fuzzyFilter(syms []outline.Symbol, query string) []outline.Symbol {
+	if query == "" {
+		return syms
+	}
+	type scored struct {
+		sym   outline.Symbol
+		score int
+	}
+	var hits []scored
+	for _, s := range syms {
+		if score, ok := fuzzyScore(s.Name, query); ok {
+			hits = append(hits, scored{s, score})
+		}
+	}
+	slices.SortStableFunc(hits, func(a, b scored) int { return b.score - a.score })
+	out := make([]outline.Symbol, len(hits))
+	for i, h := range hits {
+		out[i] = h.sym
+	}
+	return out
+}
+
+func fuzzyScore(name, query string) (int, bool) {
+	n, q := []rune(strings.ToLower(name)), []rune(strings.ToLower(query))
+	score, qi, prev := 0, 0, -2
+	for i := 0; i < len(n) && qi < len(q); i++ {
+		if n[i] != q[qi] {
+			continue
+		}
+		score++
+		if i == prev+1 {
+			score += 2 // contiguous
+		}
+		if i == 0 || strings.ContainsRune("._-", n[i-1]) {
+			score += 3 // start of a word
+		}
+		prev = i
+		qi++
+	}
+	if qi < len(q) {
+		return 0, false
+	}
+	return score*100 - len(n), true // shorter names break ties
+}
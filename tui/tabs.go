@@ -0,0 +1,180 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Tabs edits several files in one program, each in its own EditorModel
+// behind a tab bar. Every tab keeps its own buffer, validation, unsaved
+// changes, and save queue; quitting closes the current tab (with the
+// usual double press when it has unsaved changes), and the program ends
+// with the last one.
+type Tabs struct {
+	tabs   []tab
+	active int
+	keys   KeyMap
+	closed []Summary
+
+	quitting bool
+}
+
+type tab struct {
+	id   int
+	name string // the path as given, so same-named files stay apart
+	ed   EditorModel
+}
+
+// tabMsg carries a message back to the tab whose command produced it, so
+// each editor only sees its own ticks, toasts, and close.
+type tabMsg struct {
+	id  int
+	msg tea.Msg
+}
+
+// NewTabs returns a program model with one tab per editor, the first one
+// active. keys gives the tab switching bindings; the editors keep their
+// own.
+func NewTabs(keys KeyMap, editors ...EditorModel) Tabs {
+	t := Tabs{keys: keys}
+	for i, e := range editors {
+		if i > 0 {
+			e.Blur()
+		}
+		t.tabs = append(t.tabs, tab{id: i, name: e.m.cfg.FilePath, ed: e})
+	}
+	return t
+}
+
+// Init starts every editor's background ticks.
+func (t Tabs) Init() tea.Cmd {
+	var cmds []tea.Cmd
+	for _, tb := range t.tabs {
+		cmds = append(cmds, tagged(tb.id, tb.ed.Init()))
+	}
+	return tea.Batch(cmds...)
+}
+
+// Update switches tabs, routes keys to the active editor and every other
+// message to the editor it belongs to, and closes tabs whose editor quit.
+func (t Tabs) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		for i := range t.tabs {
+			t.tabs[i].ed.SetSize(msg.Width, msg.Height-1)
+		}
+		return t, nil
+
+	case tea.KeyMsg:
+		switch {
+		case len(t.tabs) > 1 && key.Matches(msg, t.keys.NextTab):
+			return t, t.switchTo((t.active + 1) % len(t.tabs))
+		case len(t.tabs) > 1 && key.Matches(msg, t.keys.PrevTab):
+			return t, t.switchTo((t.active + len(t.tabs) - 1) % len(t.tabs))
+		}
+		return t, t.updateTab(t.active, msg)
+
+	case tabMsg:
+		i := t.index(msg.id)
+		if i < 0 {
+			return t, nil // from a tab already closed
+		}
+		if _, ok := msg.msg.(EditorClosedMsg); ok {
+			return t.close(i)
+		}
+		return t, t.updateTab(i, msg.msg)
+	}
+	return t, t.updateTab(t.active, msg)
+}
+
+func (t *Tabs) updateTab(i int, msg tea.Msg) tea.Cmd {
+	var cmd tea.Cmd
+	t.tabs[i].ed, cmd = t.tabs[i].ed.Update(msg)
+	return tagged(t.tabs[i].id, cmd)
+}
+
+func (t *Tabs) switchTo(i int) tea.Cmd {
+	t.tabs[t.active].ed.Blur()
+	t.active = i
+	return tagged(t.tabs[i].id, t.tabs[i].ed.Focus())
+}
+
+func (t Tabs) close(i int) (tea.Model, tea.Cmd) {
+	t.closed = append(t.closed, t.tabs[i].ed.Summary())
+	t.tabs = append(t.tabs[:i:i], t.tabs[i+1:]...)
+	if len(t.tabs) == 0 {
+		t.quitting = true
+		return t, tea.Quit
+	}
+	if t.active >= i && t.active > 0 {
+		t.active--
+	}
+	return t, t.switchTo(t.active)
+}
+
+func (t Tabs) index(id int) int {
+	for i, tb := range t.tabs {
+		if tb.id == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// tagged wraps cmd so the messages it produces come back as tabMsg for
+// tab id. Batches are unpacked so each of their commands is tagged too.
+func tagged(id int, cmd tea.Cmd) tea.Cmd {
+	if cmd == nil {
+		return nil
+	}
+	return func() tea.Msg {
+		switch msg := cmd().(type) {
+		case nil:
+			return nil
+		case tea.BatchMsg:
+			cmds := make(tea.BatchMsg, len(msg))
+			for i, c := range msg {
+				cmds[i] = tagged(id, c)
+			}
+			return cmds
+		default:
+			return tabMsg{id: id, msg: msg}
+		}
+	}
+}
+
+// View renders the tab bar above the active editor. Tabs with unsaved
+// changes are marked with "*".
+func (t Tabs) View() string {
+	if t.quitting || len(t.tabs) == 0 {
+		return ""
+	}
+	var bar strings.Builder
+	for i, tb := range t.tabs {
+		name := tb.name
+		if tb.ed.Modified() {
+			name += "*"
+		}
+		if i == t.active {
+			fmt.Fprintf(&bar, "[%d %s] ", i+1, name)
+		} else {
+			fmt.Fprintf(&bar, " %d %s  ", i+1, name)
+		}
+	}
+	if len(t.tabs) > 1 {
+		fmt.Fprintf(&bar, "  %s/%s: switch", keyHint(t.keys.NextTab), keyHint(t.keys.PrevTab))
+	}
+	return strings.TrimRight(bar.String(), " ") + "\n" + t.tabs[t.active].ed.View()
+}
+
+// Summaries reports each file's session, closed tabs first.
+func (t Tabs) Summaries() []Summary {
+	out := append([]Summary(nil), t.closed...)
+	for _, tb := range t.tabs {
+		out = append(out, tb.ed.Summary())
+	}
+	return out
+}
@@ -1,20 +1,32 @@
 package tui
 
 import (
-	"bytes"
+	"errors"
 	"fmt"
 	"io"
+	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
 	"filippo.io/age"
-	"github.com/andreweick/agepad/model"
 	agepkg "github.com/andreweick/agepad/age"
+	"github.com/andreweick/agepad/bundle"
+	"github.com/andreweick/agepad/clipboard"
+	"github.com/andreweick/agepad/convert"
+	"github.com/andreweick/agepad/diff"
+	"github.com/andreweick/agepad/dotenv"
+	"github.com/andreweick/agepad/highlight"
+	"github.com/andreweick/agepad/model"
+	"github.com/andreweick/agepad/outline"
+	"github.com/andreweick/agepad/sealed"
 	"github.com/andreweick/agepad/validator"
+	"github.com/andreweick/agepad/valuecrypt"
+	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/bubbles/textarea"
+	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
-	"github.com/pmezard/go-difflib/difflib"
 )
 
 // Model represents the TUI editor state.
@@ -26,18 +38,128 @@ type Model struct {
 	err        error
 	identities []age.Identity
 	recips     []age.Recipient
+	skipped    []*agepkg.RecipientLineError // recipients file lines left out (--skip-invalid-recipients)
 	changed    bool
 	savedAt    time.Time
 
+	// Inactivity tracking for re-authentication before saves.
+	lastActivity time.Time
+
+	// Session summary (key names only, never values)
+	startedAt   time.Time
+	saves       int
+	changedKeys map[string]bool
+
 	// Crash guard (RAM only)
 	lastSnapshot string
 
+	// Named snapshots taken with Alt+S (RAM only, this session)
+	snapshots []snapshot
+	snapPanel *snapPanel
+
+	// Paranoid mode: orig and lastSnapshot live sealed under an ephemeral key
+	// and are only opened while computing a diff or comparing buffers. The
+	// document itself is sealed in doc, and the textarea holds only a
+	// window of it (see window.go).
+	origSealed *sealed.Buffer
+	snapSealed *sealed.Buffer
+	doc        *sealed.Buffer
+	winStart   int
+	winLen     int
+
 	// Save confirmation
 	pendingConfirm bool
+
+	// Set on quit so the final frame (which may linger on terminals without
+	// an alternate screen) carries no plaintext.
+	quitting bool
+
+	// Clipboard (auto-cleared after cfg.ClipboardClear)
+	clip    clipboard.Clipboard
+	clipSeq int
+
+	// Selection and the in-process register it is cut or copied to
+	sel      *selection
+	register []rune
+
+	// Recent errors and warnings (Ctrl+G), so long or repeated failures stay
+	// readable after the status line moves on.
+	errs logPane
+
+	// Past status messages (Ctrl+L), so a diff preview or save report can be
+	// found again after the status line has moved on.
+	history logPane
+
+	// Changes to the recipients file during the session (Ctrl+R reloads).
+	watch recipWatch
+
+	// Bundle mode: several files edited as one document (see package
+	// bundle); each section is saved to its own file and recipients.
+	bundlePaths  []string
+	bundleRecips map[string][]age.Recipient
+
+	// Decrypted git HEAD version of the file (sealed in paranoid mode), for
+	// the "[≠ HEAD]" badge and the Ctrl+O diff.
+	hasHead    bool
+	head       string
+	headSealed *sealed.Buffer
+
+	// Ctrl+T key list, while open.
+	symbols *symbolPanel
+
+	// Ctrl+F search and replace, while open.
+	replace *replacePanel
+
+	// Alt+R recipients list, while open.
+	recipPanel *recipPanel
+
+	// Lint findings (Alt+L, or a save with --lint), while open, and the
+	// hash of the buffer whose findings a save already showed.
+	lintPanel *lintPanel
+	lintAcked [32]byte
+
+	// Passphrase mode: the prompt for a new file's passphrase, while open.
+	passPrompt *passPrompt
+
+	// The prompt for the file to encrypt to (scratch mode) or to save a
+	// queued write as, while open.
+	pathInput *textinput.Model
+
+	// A save whose write failed, kept for Ctrl+S retry or Ctrl+X save-as.
+	queue *saveQueue
+
+	// Values mode: the file's data key (see WithValueKey).
+	valueKey *valuecrypt.Key
+
+	keys KeyMap
+
+	// Set by EditorModel: quitting reports EditorClosedMsg instead of
+	// ending the program, validators run after the format checks, and
+	// write (when set) replaces the atomic file write.
+	embedded   bool
+	validators []Validator
+	write      func(path string, cipher []byte) error
+
+	// Runs after each file a save writes (see WithAfterSave).
+	afterSave func(path, plain string) error
+
+	// Short-lived success notification; toastSeq ignores stale expiries.
+	toast    string
+	toastSeq int
 }
 
+// toastDuration is how long a success notification stays on screen.
+const toastDuration = 3 * time.Second
+
 type snapshotTick struct{}
 
+// toastExpiredMsg hides the toast it was scheduled for.
+type toastExpiredMsg struct{ seq int }
+
+// clipboardClearMsg fires when a copied value should be wiped; seq ignores
+// timers from earlier copies.
+type clipboardClearMsg struct{ seq int }
+
 // NewModel creates a new TUI model.
 func NewModel(cfg model.Config, plaintext string, ids []age.Identity, recips []age.Recipient) Model {
 	ta := textarea.New()
@@ -48,149 +170,918 @@ func NewModel(cfg model.Config, plaintext string, ids []age.Identity, recips []a
 	ta.CharLimit = 0
 	ta.SetWidth(100)
 	ta.SetHeight(30)
+	if cfg.Highlight {
+		// Keep blurred (view-only) text unstyled too, so highlightView
+		// can find it.
+		ta.BlurredStyle.Text = ta.FocusedStyle.Text
+	}
 	if cfg.ViewOnly {
 		ta.Blur()
 	}
 
+	keys := DefaultKeyMap()
+	_ = keys.Rebind(cfg.KeyBindings) // checked by the caller; on error the defaults stand
 	m := Model{
-		cfg:          cfg,
-		ta:           ta,
-		orig:         plaintext,
-		status:       fmt.Sprintf("Opened %s (RAM). Ctrl+D: diff  Ctrl+S: save  Ctrl+Q: quit", cfg.FilePath),
-		identities:   ids,
-		recips:       recips,
-		lastSnapshot: plaintext,
+		cfg: cfg,
+		ta:  ta,
+		status: fmt.Sprintf("Opened %s (RAM%s). %s: diff  %s: save  %s: keys  %s: copy value  %s: quit",
+			cfg.FilePath, formatNote(cfg, plaintext),
+			keyHint(keys.Diff), keyHint(keys.Save), keyHint(keys.Keys), keyHint(keys.Copy), keyHint(keys.Quit)),
+		identities: ids,
+		recips:     recips,
+		clip:       clipboard.New(cfg.OSC52, os.Stdout),
+		keys:       keys,
+
+		lastActivity: time.Now(),
+		startedAt:    time.Now(),
+		changedKeys:  map[string]bool{},
+		errs:         newLogPane("Errors & warnings", 100, 10),
+		history:      newLogPane("Status history", 100, 10),
+	}
+	m.errs.copyable = true
+	if cfg.RecipientsFile != "" && !cfg.ViewOnly {
+		m.watch = newRecipWatch(cfg.RecipientsFile)
+	}
+	if cfg.SkipInvalidRecipients && m.watch.path != "" {
+		if _, skipped, err := agepkg.LoadRecipientsSkipping(m.watch.path); err == nil && len(skipped) > 0 {
+			m.setSkipped(m.watch.path, skipped)
+			m.status = fmt.Sprintf("WARNING: skipped %d unusable line(s) of %s; saves leave them out (%s: details). ",
+				len(skipped), m.watch.path, keyHint(keys.Errors)) + m.status
+		}
+	}
+	if cfg.Paranoid {
+		var err error
+		if m.origSealed, err = sealed.New(); err == nil {
+			if m.snapSealed, err = sealed.New(); err == nil {
+				if m.doc, err = sealed.New(); err == nil {
+					err = m.doc.Seal(plaintext)
+				}
+			}
+		}
+		if err != nil {
+			m.origSealed, m.snapSealed, m.doc = nil, nil, nil
+			m.err = fmt.Errorf("paranoid mode unavailable, keeping plaintext copies: %w", err)
+			m.errs.add("error", m.err.Error())
+		} else {
+			// Five-digit line numbers, so renumber keeps the gutter's width.
+			m.ta.MaxHeight = 99999
+			m.openWindow(0, 0, true)
+		}
+	}
+	m.setOrig(plaintext)
+	m.setSnapshot(plaintext)
+	if cfg.OpenAt != "" {
+		m.openAt(plaintext)
 	}
+	m.history.add("info", m.status)
 	return m
 }
 
+// NewBundleModel creates a model that edits parts as one document with
+// marked sections; recips gives each part's recipients.
+func NewBundleModel(cfg model.Config, parts []bundle.Part, ids []age.Identity, recips map[string][]age.Recipient) Model {
+	m := NewModel(cfg, bundle.Join(parts), ids, nil)
+	for _, p := range parts {
+		m.bundlePaths = append(m.bundlePaths, p.Path)
+	}
+	m.bundleRecips = recips
+	m.status = fmt.Sprintf("Opened %d files under %s as one document (RAM). Keep the #### markers. Ctrl+S saves each file to its own recipients.",
+		len(parts), cfg.FilePath)
+	m.history.entries = nil
+	m.history.add("info", m.status)
+	return m
+}
+
+// saveUnit is one file written by a save: the whole buffer normally, or a
+// single section in bundle mode.
+type saveUnit struct {
+	path    string
+	content string
+	recips  []age.Recipient
+	armor   bool
+}
+
+// saveUnits splits buf into the files a save writes. In bundle mode,
+// sections that are unchanged since the last save are left out.
+func (m Model) saveUnits(buf string) ([]saveUnit, error) {
+	if len(m.bundlePaths) == 0 {
+		return []saveUnit{{path: m.cfg.FilePath, content: buf, recips: m.recips, armor: m.armorFor(m.cfg.FilePath)}}, nil
+	}
+	parts, err := bundle.Split(buf, m.bundlePaths)
+	if err != nil {
+		return nil, err
+	}
+	orig, err := m.original()
+	if err != nil {
+		return nil, err
+	}
+	before := map[string]string{}
+	if orig, err := bundle.Split(orig, m.bundlePaths); err == nil {
+		for _, p := range orig {
+			before[p.Path] = p.Content
+		}
+	}
+	var units []saveUnit
+	for _, p := range parts {
+		if old, ok := before[p.Path]; ok && old == p.Content {
+			continue
+		}
+		units = append(units, saveUnit{path: p.Path, content: p.Content, recips: m.bundleRecips[p.Path], armor: m.armorFor(p.Path)})
+	}
+	return units, nil
+}
+
+// armorFor returns whether a save to path is armored: in auto mode, as the
+// file on disk is, and otherwise (or for a new file) as configured.
+func (m Model) armorFor(path string) bool {
+	if !m.cfg.ArmorAuto {
+		return m.cfg.Armor
+	}
+	h, err := agepkg.InspectHeader(path)
+	if err != nil {
+		return m.cfg.Armor
+	}
+	return h.Armored
+}
+
+// openAt moves the cursor to the line defining cfg.OpenAt; the textarea
+// highlights the cursor line.
+func (m *Model) openAt(plaintext string) {
+	format := validator.Format(m.cfg.FilePath, plaintext, m.cfg.TypeRules)
+	line, err := outline.Find(plaintext, format, m.cfg.OpenAt)
+	if err != nil {
+		m.err = err
+		m.errs.add("error", err.Error())
+		return
+	}
+	m.gotoLine(line)
+	m.status = fmt.Sprintf("Opened %s at %s (line %d).", m.cfg.FilePath, m.cfg.OpenAt, line)
+}
+
+// gotoLine moves the cursor to the start of the 1-based line.
+func (m *Model) gotoLine(line int) {
+	if m.doc != nil {
+		if line-1 < m.winStart || line-1 >= m.winStart+m.ta.LineCount() {
+			m.moveWindow(line-1, 0, line-1 > m.line())
+			return
+		}
+		line -= m.winStart
+	}
+	for m.ta.Line() > line-1 {
+		m.ta.CursorUp()
+	}
+	for m.ta.Line() < line-1 {
+		m.ta.CursorDown()
+	}
+	m.ta.CursorStart()
+}
+
+// setOrig records the last-saved plaintext, sealing it in paranoid mode.
+func (m *Model) setOrig(s string) {
+	if m.origSealed == nil {
+		m.orig = s
+		return
+	}
+	if err := m.origSealed.Seal(s); err != nil {
+		m.err = err
+	}
+}
+
+// original returns the last-saved plaintext, opening it in paranoid mode.
+// An error means the comparison cannot be made; callers must not fall
+// back to an empty original, which would make everything look changed.
+func (m Model) original() (string, error) {
+	if m.origSealed == nil {
+		return m.orig, nil
+	}
+	s, err := m.origSealed.String()
+	if err != nil {
+		return "", fmt.Errorf("opening the sealed last-saved copy: %w", err)
+	}
+	return s, nil
+}
+
+// modified reports whether the buffer differs from the last-saved plaintext.
+func (m Model) modified() bool {
+	if m.origSealed == nil {
+		return m.ta.Value() != m.orig
+	}
+	eq, err := m.sameAs(m.origSealed)
+	return err != nil || !eq
+}
+
+func (m *Model) setSnapshot(s string) {
+	if m.snapSealed == nil {
+		m.lastSnapshot = s
+		return
+	}
+	if err := m.snapSealed.Seal(s); err != nil {
+		m.err = err
+	}
+}
+
+// editDiff diffs the last-saved plaintext against the buffer.
+func (m Model) editDiff() (string, error) {
+	orig, err := m.original()
+	if err != nil {
+		return "", err
+	}
+	name := filepath.Base(m.cfg.FilePath)
+	return unifiedDiff(orig, m.text(), name+" (original)", name+" (edited)", diff.Algorithm(m.cfg.DiffAlgorithm)), nil
+}
+
 // Init initializes the TUI model.
 func (m Model) Init() tea.Cmd {
 	// Periodic in-memory snapshot (no disk) for crash guard messaging.
 	return tea.Tick(2*time.Second, func(time.Time) tea.Msg { return snapshotTick{} })
 }
 
-// Update handles TUI events.
+// Update handles TUI events. Every new error is also recorded in the error
+// pane, and every new status message in the status history.
 func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	prevErr, prevStatus := m.err, m.status
+	res, cmd := m.update(msg)
+	restoreTerminal()
+	next := res.(Model)
+	next.keepWindow()
+	if next.err != nil && (prevErr == nil || !errors.Is(next.err, prevErr)) {
+		next.errs.add("error", next.err.Error())
+	}
+	if next.status != prevStatus {
+		status := next.status
+		if next.cfg.Paranoid {
+			// Multi-line statuses carry diff previews; keep no plaintext copies.
+			status, _, _ = strings.Cut(status, "\n")
+		}
+		next.history.add("info", status)
+	}
+	return next, cmd
+}
+
+// notify shows text as a toast and schedules it to disappear.
+func (m *Model) notify(text string) tea.Cmd {
+	m.toastSeq++
+	m.toast = text
+	seq := m.toastSeq
+	return tea.Tick(toastDuration, func(time.Time) tea.Msg { return toastExpiredMsg{seq: seq} })
+}
+
+// openPane returns the log pane that currently has the keyboard, if any.
+func (m *Model) openPane() *logPane {
+	switch {
+	case m.errs.open:
+		return &m.errs
+	case m.history.open:
+		return &m.history
+	}
+	return nil
+}
+
+func (m Model) update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch t := msg.(type) {
 	case snapshotTick:
-		m.lastSnapshot = m.ta.Value()
+		m.setSnapshot(m.text())
+		if m.watch.poll() {
+			m.status = fmt.Sprintf("Recipients file changed on disk (%s). Ctrl+R reloads it before you save.", m.watch.summary())
+		}
 		return m, tea.Tick(2*time.Second, func(time.Time) tea.Msg { return snapshotTick{} })
 
+	case controlMsg:
+		return m.control(t)
+
+	case toastExpiredMsg:
+		if t.seq == m.toastSeq {
+			m.toast = ""
+		}
+		return m, nil
+
+	case clipboardClearMsg:
+		if t.seq == m.clipSeq {
+			if err := m.clip.Clear(); err != nil {
+				m.err = err
+			} else {
+				m.status = "Clipboard cleared."
+				return m, m.notify("Clipboard cleared")
+			}
+		}
+		return m, nil
+
 	case tea.KeyMsg:
-		switch t.String() {
-		case "ctrl+q", "esc":
+		idle := time.Since(m.lastActivity)
+		m.lastActivity = time.Now()
+
+		if m.pathInput != nil {
+			return m.updatePath(t)
+		}
+		if m.passPrompt != nil {
+			return m.updatePassphrase(t)
+		}
+		if m.symbols != nil {
+			return m.updateSymbols(t)
+		}
+		if m.replace != nil {
+			return m.updateReplace(t)
+		}
+		if m.recipPanel != nil {
+			return m.updateRecipients(t)
+		}
+		if m.snapPanel != nil {
+			return m.updateSnapshots(t)
+		}
+		if m.lintPanel != nil {
+			return m.updateLint(t)
+		}
+
+		// An open log pane takes the keyboard until it is closed.
+		if p := m.openPane(); p != nil && (t.Type == tea.KeyEsc || !key.Matches(t, m.keys.Quit)) {
+			switch {
+			case t.Type == tea.KeyEsc || key.Matches(t, m.keys.Errors, m.keys.History):
+				p.open = false
+				return m, nil
+			case key.Matches(t, m.keys.Copy):
+				if p.copyable {
+					e, _ := p.latest()
+					return m, m.copy(e.text, "error text")
+				}
+				return m, nil
+			}
+			var cmd tea.Cmd
+			p.vp, cmd = p.vp.Update(msg)
+			return m, cmd
+		}
+
+		if ok, cmd := m.selectionKey(t); ok {
+			return m, cmd
+		}
+
+		switch {
+		case key.Matches(t, m.keys.Errors):
+			if len(m.errs.entries) == 0 {
+				m.status = "No errors or warnings this session."
+				return m, nil
+			}
+			m.errs.open = true
+			return m, nil
+
+		case key.Matches(t, m.keys.History):
+			m.history.open = true
+			return m, nil
+
+		case key.Matches(t, m.keys.Keys):
+			return m, m.openSymbols()
+
+		case key.Matches(t, m.keys.Replace):
+			return m, m.openReplace()
+
+		case key.Matches(t, m.keys.Recipients):
+			return m, m.openRecipients()
+
+		case key.Matches(t, m.keys.Snapshots):
+			m.snapPanel = &snapPanel{sel: max(0, len(m.snapshots)-1)}
+			return m, nil
+
+		case key.Matches(t, m.keys.Lint):
+			m.openLint()
+			return m, nil
+
+		case key.Matches(t, m.keys.SaveAs):
+			if m.queue != nil && len(m.bundlePaths) == 0 {
+				return m, m.askPath("Save as: ", filepath.Base(m.cfg.FilePath),
+					"Path of the new file for the encrypted copy (Enter: write, Esc: cancel)")
+			}
+
+		case key.Matches(t, m.keys.HeadDiff):
+			m.headDiff()
+			m.pendingConfirm = false
+			return m, nil
+
+		case key.Matches(t, m.keys.Quit):
 			// Double press protection if there are unsaved changes and not view-only
 			if m.changed && !m.cfg.ViewOnly && !m.pendingConfirm {
 				m.status = "Unsaved changes; press Ctrl+Q again to quit without saving"
+				if m.cfg.Scratch {
+					m.status = "Scratchpad not saved. Ctrl+S: encrypt to a file. Ctrl+Q again: discard and zeroize."
+				}
 				m.pendingConfirm = true
 				return m, nil
 			}
+			if m.cfg.Scratch {
+				m.discard()
+			}
+			m.quitting = true
+			if m.embedded {
+				saved := m.saves > 0
+				return m, func() tea.Msg { return EditorClosedMsg{Saved: saved} }
+			}
 			return m, tea.Quit
 
-		case "ctrl+d":
-			diff := unifiedDiff(m.orig, m.ta.Value(), filepath.Base(m.cfg.FilePath))
-			if strings.TrimSpace(diff) == "" {
+		case key.Matches(t, m.keys.Diff):
+			diff, err := m.editDiff()
+			switch {
+			case err != nil:
+				m.err = err
+				m.status = "Cannot show the diff."
+			case strings.TrimSpace(diff) == "":
 				m.status = "No changes to show (buffers identical)."
-			} else {
+			default:
 				m.status = "Diff preview (first 2000 chars):\n" + truncate(diff, 2000)
 			}
 			m.pendingConfirm = false
 			return m, nil
 
-		case "ctrl+s":
-			if m.cfg.ViewOnly {
-				m.status = "View-only mode: saving disabled."
+		case key.Matches(t, m.keys.Copy):
+			val := lineValue(m.currentLine())
+			if val == "" {
+				m.status = "Nothing to copy on this line."
 				return m, nil
 			}
-			buf := m.ta.Value()
+			return m, m.copy(val, "value")
 
-			// 1) Validate format (fail early before encryption)
-			if err := validator.ValidateByExt(m.cfg.FilePath, buf); err != nil {
-				m.err = err
-				m.status = "Validation failed; not saved."
-				m.pendingConfirm = false
+		case key.Matches(t, m.keys.ReloadRecipients):
+			if m.watch.path == "" {
 				return m, nil
 			}
-
-			// 2) Recipient health preflight: encrypt to memory, then decrypt with identities.
-			cipher, err := agepkg.EncryptToMemory([]byte(buf), m.recips, m.cfg.Armor)
+			recips, err := m.loadRecipients(m.watch.path)
 			if err != nil {
-				m.err = fmt.Errorf("preflight encrypt: %w", err)
-				m.status = "Save aborted."
-				m.pendingConfirm = false
+				m.err = err
+				m.status = "Reloading recipients failed; keeping the previous set."
 				return m, nil
 			}
-			r, err := age.Decrypt(bytes.NewReader(cipher), m.identities...)
+			b, err := os.ReadFile(m.watch.path)
 			if err != nil {
-				m.err = fmt.Errorf("preflight decrypt failed with current identities; "+
-					"you may lock yourself out: %w", err)
-				m.status = "Save aborted. Update recipients or identities."
-				m.pendingConfirm = false
-				return m, nil
-			}
-			_, _ = io.ReadAll(r) // Drain; we only care that decryption is possible.
-
-			// 3) Require explicit confirmation if content changed (double Ctrl+S).
-			if m.ta.Value() != m.orig && !m.pendingConfirm {
-				diff := unifiedDiff(m.orig, m.ta.Value(), filepath.Base(m.cfg.FilePath))
-				m.status = "About to save. Diff (first 2000 chars):\n" +
-					truncate(diff, 2000) + "\nPress Ctrl+S again to confirm."
-				m.pendingConfirm = true
-				return m, nil
-			}
-
-			// 4) Write atomically.
-			if err := agepkg.AtomicEncryptWrite(m.cfg.FilePath, []byte(buf), m.recips, m.cfg.Armor); err != nil {
 				m.err = err
-				m.status = "Save failed"
-			} else {
-				m.err = nil
-				m.savedAt = time.Now()
-				m.status = fmt.Sprintf("Saved %s (armor=%v) at %s",
-					m.cfg.FilePath, m.cfg.Armor, m.savedAt.Format(time.RFC3339))
-				m.orig = buf
-				m.changed = false
+				return m, nil
 			}
+			m.recips = recips
+			m.queue = nil // encrypted to the old set
+			m.valueKey = nil
+			m.watch.reset(b)
 			m.pendingConfirm = false
+			m.status = fmt.Sprintf("Reloaded %d recipient(s) from %s. Ctrl+S saves to them.", len(recips), m.watch.path)
 			return m, nil
+
+		case key.Matches(t, m.keys.Save):
+			return m.save(idle)
 		}
 	}
 
 	var cmd tea.Cmd
 	prev := m.ta.Value()
-	m.ta, cmd = m.ta.Update(msg)
+	if k, ok := msg.(tea.KeyMsg); !ok || !m.indentKey(k) {
+		cmd = m.updateTextarea(msg)
+	}
 	if prev != m.ta.Value() {
 		m.changed = true
 		m.pendingConfirm = false
+		m.queue = nil
 	}
 	return m, cmd
 }
 
+// save validates, preflights, confirms, and writes the buffer. idle is how
+// long the editor sat untouched before the key that asked for it.
+func (m Model) save(idle time.Duration) (tea.Model, tea.Cmd) {
+	if m.cfg.ViewOnly {
+		m.status = "View-only mode: saving disabled."
+		return m, nil
+	}
+	if m.queue != nil {
+		return m.flushQueue()
+	}
+	if m.cfg.Scratch && m.cfg.FilePath == "" {
+		if m.text() == "" {
+			m.status = "Scratchpad is empty; nothing to save."
+			return m, nil
+		}
+		return m, m.askPath("Encrypt to: ", "notes.txt.age",
+			"Path of the new encrypted file (Enter: continue, Esc: cancel)")
+	}
+	if m.cfg.Passphrase && len(m.recips) == 0 {
+		return m, m.askPassphrase()
+	}
+	buf := m.text()
+
+	// The recipients file changed since it was loaded: ask once
+	// before saving to the old set.
+	m.watch.poll()
+	if m.watch.pending && !m.watch.acked {
+		m.watch.acked = true
+		m.status = fmt.Sprintf("Recipients file changed on disk (%s). Ctrl+R: reload it first. Ctrl+S: save to the recipients loaded at start.",
+			m.watch.summary())
+		m.pendingConfirm = false
+		return m, nil
+	}
+
+	// 0) After a long idle period, reload identities so plugin/hardware
+	// identities demand a fresh touch/PIN during the preflight below.
+	if m.cfg.ReauthAfter > 0 && idle > m.cfg.ReauthAfter {
+		ids, err := agepkg.LoadIdentities(m.cfg.IdentitiesPath)
+		if err != nil {
+			m.err = fmt.Errorf("re-authentication after %s idle: %w", idle.Round(time.Second), err)
+			m.status = "Re-authentication failed; not saved."
+			m.pendingConfirm = false
+			return m, nil
+		}
+		m.identities = ids
+	}
+
+	// Everything below compares with the last-saved copy.
+	if _, err := m.original(); err != nil {
+		m.err = err
+		m.status = "Cannot compare with the last save; not saved."
+		m.pendingConfirm = false
+		return m, nil
+	}
+	units, err := m.saveUnits(buf)
+	if err != nil {
+		m.err = err
+		m.status = "File sections are damaged; not saved."
+		m.pendingConfirm = false
+		return m, nil
+	}
+	// In bundle mode errors name the section's file.
+	inFile := func(u saveUnit, err error) error {
+		if len(m.bundlePaths) == 0 {
+			return err
+		}
+		return fmt.Errorf("%s: %w", u.path, err)
+	}
+
+	// 1) Validate format, and JSON/YAML against a schema when there is
+	// one (fail early before encryption). Formats set to warn are
+	// reported but do not block the save.
+	warning := ""
+	for _, u := range units {
+		sev, err := validator.CheckWithSchema(u.path, u.content, m.cfg.Schema, m.cfg.Validation, m.cfg.TypeRules)
+		if err == nil {
+			continue
+		}
+		err = inFile(u, err)
+		if sev != model.SeverityWarn {
+			m.err = err
+			m.status = "Validation failed; not saved."
+			m.pendingConfirm = false
+			return m, nil
+		}
+		warning += "Validation warning: " + err.Error() + "\n"
+		m.errs.add("warning", err.Error())
+	}
+	for _, u := range units {
+		for _, v := range m.validators {
+			if err := v(u.path, u.content); err != nil {
+				m.err = inFile(u, err)
+				m.status = "Validation failed; not saved."
+				m.pendingConfirm = false
+				return m, nil
+			}
+		}
+	}
+
+	// 1b) With --lint, list suspicious content first. Findings only
+	// warn: saving the same buffer again goes ahead.
+	if m.cfg.Lint {
+		w, stop := m.lintSave(buf, units)
+		if stop {
+			m.pendingConfirm = false
+			return m, nil
+		}
+		warning += w
+	}
+
+	// 2) Recipient health preflight: encrypt to memory, then decrypt
+	// with identities. The checked ciphertext is what gets written.
+	queue := &saveQueue{total: len(units), warning: warning, armor: strconv.FormatBool(m.cfg.Armor)}
+	switch {
+	case len(m.bundlePaths) == 0 && len(units) == 1:
+		queue.armor = strconv.FormatBool(units[0].armor)
+	case m.cfg.ArmorAuto:
+		queue.armor = "kept"
+	}
+	if m.cfg.Values {
+		queue.armor = "true" // the data key block; values are not armored
+	}
+	var preview string
+	var recipsChanged bool
+	for _, u := range units {
+		cipher, keyBlock, err := m.encryptUnit(u)
+		if err != nil {
+			m.err = inFile(u, fmt.Errorf("preflight encrypt: %w", err))
+			m.status = "Save aborted."
+			m.pendingConfirm = false
+			return m, nil
+		}
+		h, err := agepkg.InspectHeaderBytes(keyBlock)
+		if err == nil {
+			err = agepkg.CheckStanzas(h, m.cfg.Stanzas)
+		}
+		if err != nil {
+			m.err = inFile(u, fmt.Errorf("recipients: %w", err))
+			m.status = "Save aborted. Recipients break the stanza policy."
+			m.pendingConfirm = false
+			return m, nil
+		}
+		if len(m.bundlePaths) == 0 {
+			preview, recipsChanged = m.recipientsPreview(u.path, h)
+		}
+		// Decrypted through the armor when --armor is on; the
+		// plaintext is discarded, only decryptability matters.
+		if err := m.decryptUnit(u, cipher); err != nil {
+			m.err = inFile(u, fmt.Errorf("preflight decrypt failed with current identities; "+
+				"you may lock yourself out: %w", err))
+			m.status = "Save aborted. Update recipients or identities."
+			m.pendingConfirm = false
+			return m, nil
+		}
+		queue.writes = append(queue.writes, queuedWrite{path: u.path, cipher: cipher})
+	}
+
+	// 3) Require explicit confirmation if content or the recipient set
+	// changed (double Ctrl+S), naming the recipients. Skipped recipients
+	// file lines always ask, so no save leaves them out unseen.
+	if w := m.skippedWarning(); w != "" {
+		preview = w + "\n" + preview
+	}
+	if (m.modified() || recipsChanged || len(m.skipped) > 0) && !m.pendingConfirm {
+		diff := "No content changes."
+		if m.modified() {
+			d, _ := m.editDiff() // opened above
+			diff = "Diff (first 2000 chars):\n" + truncate(d, 2000)
+		}
+		if preview != "" {
+			preview += "\n"
+		}
+		m.status = warning + "About to save. " + preview + diff + "\nPress Ctrl+S again to confirm."
+		m.pendingConfirm = true
+		if warning != "" {
+			return m, nil
+		}
+		return m, m.notify("Validated; recipients can decrypt")
+	}
+
+	// 4) Write atomically (each file on its own in bundle mode). A
+	// failed write stays queued for a retry or save-as.
+	m.queue = queue
+	return m.flushQueue()
+}
+
 // View renders the TUI.
 func (m Model) View() string {
+	if m.quitting {
+		return ""
+	}
+	toast := ""
+	if m.toast != "" {
+		toast = "✔ " + m.toast + "\n"
+	}
 	errLine := ""
-	if m.err != nil {
-		errLine = "\n[ERROR] " + m.err.Error()
+	switch {
+	case m.errs.open:
+		errLine = "\n" + m.errs.View()
+	case m.history.open:
+		errLine = "\n" + m.history.View()
+	case m.err != nil:
+		first, _, _ := strings.Cut(m.err.Error(), "\n")
+		if r := []rune(first); len(r) > 200 {
+			first = string(r[:200]) + "…"
+		}
+		errLine = "\n[ERROR] " + first + "  (Ctrl+G: details)"
+	}
+	if m.pathInput != nil {
+		errLine = "\n" + m.pathInput.View() + errLine
+	}
+	if m.passPrompt != nil {
+		errLine = "\n" + m.passPrompt.input.View() + errLine
+	}
+	if m.lintPanel != nil {
+		errLine = "\n" + m.lintPanel.View() + errLine
+	}
+	if m.symbols != nil {
+		errLine = "\n" + m.symbols.View() + errLine
+	}
+	if m.replace != nil {
+		errLine = "\n" + m.replace.View() + errLine
+	}
+	if m.recipPanel != nil {
+		errLine = "\n" + m.recipPanel.View() + errLine
 	}
-	return fmt.Sprintf("%s\n\n%s\n%s\n", m.status, m.ta.View(), errLine)
+	if m.snapPanel != nil {
+		errLine = "\n" + m.snapshotsView() + errLine
+	}
+	badge := ""
+	if m.differsFromHead() {
+		badge = headBadge
+	}
+	view := m.renumber(m.ta.View())
+	if m.cfg.Highlight && len(m.bundlePaths) == 0 {
+		theme, ok := highlight.Themes[m.cfg.Theme]
+		if !ok {
+			theme = highlight.Themes["default"]
+		}
+		view = highlightView(view, theme, validator.Format(m.cfg.FilePath, m.ta.Value(), m.cfg.TypeRules))
+	}
+	return fmt.Sprintf("%s%s%s\n\n%s\n%s\n", toast, badge, m.status, view, errLine)
+}
+
+// Summary describes an editing session without any plaintext, suitable for
+// pasting into change tickets.
+type Summary struct {
+	File        string
+	Duration    time.Duration
+	Saves       int
+	KeysChanged int
+	Recipients  int
+}
+
+// String renders the summary as a short multi-line report.
+func (s Summary) String() string {
+	saved := "no"
+	if s.Saves > 0 {
+		saved = fmt.Sprintf("yes (%d)", s.Saves)
+	}
+	return fmt.Sprintf("agepad session summary\n"+
+		"  file:         %s\n"+
+		"  duration:     %s\n"+
+		"  saved:        %s\n"+
+		"  keys changed: %d\n"+
+		"  recipients:   %d\n",
+		s.File, s.Duration.Round(time.Second), saved, s.KeysChanged, s.Recipients)
 }
 
-func unifiedDiff(a, b, filename string) string {
-	diff := difflib.UnifiedDiff{
-		A:        difflib.SplitLines(a),
-		B:        difflib.SplitLines(b),
-		FromFile: filename + " (original)",
-		ToFile:   filename + " (edited)",
-		Context:  3,
+// Summary reports what happened during the session so far.
+func (m Model) Summary() Summary {
+	file := m.cfg.FilePath
+	if file == "" && m.cfg.Scratch {
+		file = "(scratchpad, not saved)"
+	}
+	return Summary{
+		File:        file,
+		Duration:    time.Since(m.startedAt),
+		Saves:       m.saves,
+		KeysChanged: len(m.changedKeys),
+		Recipients:  len(m.recips),
+	}
+}
+
+// bufferChangedKeys returns the keys changed between the last-saved copy
+// orig and buf. In bundle mode each section is compared on its own, and
+// its keys are named path:key.
+func (m Model) bufferChangedKeys(orig, buf string) []string {
+	if len(m.bundlePaths) == 0 {
+		return changedKeys(orig, buf, validator.Format(m.cfg.FilePath, buf, m.cfg.TypeRules))
+	}
+	before := map[string]string{}
+	if parts, err := bundle.Split(orig, m.bundlePaths); err == nil {
+		for _, p := range parts {
+			before[p.Path] = p.Content
+		}
+	}
+	parts, err := bundle.Split(buf, m.bundlePaths)
+	if err != nil {
+		return nil
+	}
+	var keys []string
+	for _, p := range parts {
+		for _, k := range changedKeys(before[p.Path], p.Content, validator.Format(p.Path, p.Content, m.cfg.TypeRules)) {
+			keys = append(keys, p.Path+":"+k)
+		}
 	}
-	text, _ := difflib.GetUnifiedDiffString(diff)
-	return text
+	return keys
+}
+
+// changedKeys returns the keys added, removed, or modified between a and
+// b: .env names, read with the shared .env grammar, or for JSON, YAML, and
+// TOML the dotted paths of their values. Content that does not parse has
+// no keys to compare.
+func changedKeys(a, b, format string) []string {
+	before, err := keyValues(a, format)
+	if err != nil {
+		return nil
+	}
+	after, err := keyValues(b, format)
+	if err != nil {
+		return nil
+	}
+	var keys []string
+	for k, v := range before {
+		if w, ok := after[k]; !ok || w != v {
+			keys = append(keys, k)
+		}
+	}
+	for k := range after {
+		if _, ok := before[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	return keys
+}
+
+// keyValues maps the keys of content in format to their values.
+func keyValues(content, format string) (map[string]string, error) {
+	switch format {
+	case "json", "yaml", "toml":
+		data, err := convert.Decode(content, convert.Format(format))
+		if err != nil {
+			return nil, err
+		}
+		return convert.Flatten(data, convert.Options{Separator: "."}), nil
+	}
+	return dotenv.Map(content)
+}
+
+// WipeTerminal clears the alternate screen, resets the window title and
+// attributes, and shows the cursor, so nothing from the session survives in
+// the terminal once agepad exits.
+func WipeTerminal(w io.Writer) {
+	io.WriteString(w, "\x1b[?1049h\x1b[2J\x1b[H\x1b[?1049l"+ // clear the alternate screen
+		"\x1b]0;\x07"+ // reset window title
+		"\x1b[0m\x1b[?25h") // reset attributes, show cursor
+}
+
+// copy writes text to the clipboard and schedules its auto-clear; what names
+// the copied text in the status line.
+func (m *Model) copy(text, what string) tea.Cmd {
+	if err := m.clip.Write(text); err != nil {
+		m.err = err
+		m.status = "Copy failed."
+		return nil
+	}
+	m.clipSeq++
+	toast := m.notify("Copied " + what)
+	if m.cfg.ClipboardClear <= 0 {
+		m.status = fmt.Sprintf("Copied %s to clipboard.", what)
+		return toast
+	}
+	m.status = fmt.Sprintf("Copied %s to clipboard; clearing in %s.", what, m.cfg.ClipboardClear)
+	seq := m.clipSeq
+	return tea.Batch(toast, tea.Tick(m.cfg.ClipboardClear, func(time.Time) tea.Msg { return clipboardClearMsg{seq: seq} }))
+}
+
+// currentLine returns the buffer line under the cursor.
+func (m Model) currentLine() string {
+	lines := strings.Split(m.ta.Value(), "\n")
+	if i := m.ta.Line(); i >= 0 && i < len(lines) {
+		return lines[i]
+	}
+	return ""
+}
+
+// formatNote names the format the buffer is validated as, for the status line.
+func formatNote(cfg model.Config, content string) string {
+	if f := validator.Format(cfg.FilePath, content, cfg.TypeRules); f != "" {
+		switch {
+		case cfg.Redact:
+			return ", " + f + ", values redacted"
+		case cfg.Values:
+			return ", " + f + ", values encrypted"
+		}
+		return ", " + f
+	}
+	return ""
+}
+
+// lineValue extracts the value of a KEY=VALUE line (unquoted), or the whole
+// trimmed line for anything else. Comments yield nothing.
+func lineValue(line string) string {
+	if es, err := dotenv.Parse(line); err == nil && len(es) == 1 {
+		return es[0].Value
+	}
+	t := strings.TrimSpace(line)
+	if t == "" || strings.HasPrefix(t, "#") {
+		return ""
+	}
+	if _, v, ok := strings.Cut(t, "="); ok {
+		v = strings.TrimSpace(v)
+		if len(v) >= 2 && (v[0] == '"' || v[0] == '\'') && v[len(v)-1] == v[0] {
+			v = v[1 : len(v)-1]
+		}
+		return v
+	}
+	return t
+}
+
+// unifiedDiff renders a unified diff of a and b with the changed words of
+// modified lines highlighted; alg picks how lines are matched.
+func unifiedDiff(a, b, from, to string, alg diff.Algorithm) string {
+	return diff.Unified(a, b, diff.Options{
+		FromFile:  from,
+		ToFile:    to,
+		Context:   3,
+		Algorithm: alg,
+		Mark:      diff.ANSIMark,
+	})
 }
 
 func truncate(s string, n int) string {
 	if len(s) <= n {
 		return s
 	}
-	return s[:n] + "\n…(truncated)…"
+	s = s[:n]
+	// Don't leave half an escape sequence or the terminal in reverse video.
+	if i := strings.LastIndex(s, "\x1b"); i >= 0 && !strings.Contains(s[i:], "m") {
+		s = s[:i]
+	}
+	if strings.Count(s, "\x1b[7m") > strings.Count(s, "\x1b[27m") {
+		s += "\x1b[27m"
+	}
+	return s + "\n…(truncated)…"
 }
@@ -1,15 +1,47 @@
 package tui
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
 	"testing"
+	"time"
+	"unsafe"
 
 	"filippo.io/age"
+	agepkg "github.com/andreweick/agepad/age"
+	"github.com/andreweick/agepad/bundle"
+	"github.com/andreweick/agepad/control"
+	"github.com/andreweick/agepad/diff"
+	"github.com/andreweick/agepad/highlight"
 	"github.com/andreweick/agepad/model"
+	"github.com/andreweick/agepad/outline"
+	"github.com/andreweick/agepad/validator"
+	"github.com/andreweick/agepad/valuecrypt"
+	"github.com/charmbracelet/bubbles/key"
 	tea "github.com/charmbracelet/bubbletea"
 )
 
 func TestNewModel(t *testing.T) {
+	t.Run("names the inner format in the status line", func(t *testing.T) {
+		m := NewModel(model.Config{FilePath: "secrets/app.json.age"}, "{}", nil, nil)
+		if !contains(m.status, "(RAM, json)") {
+			t.Errorf("expected json format note, got: %s", m.status)
+		}
+	})
+
+	t.Run("notes a redacted view in the status line", func(t *testing.T) {
+		m := NewModel(model.Config{FilePath: "app.env.age", ViewOnly: true, Redact: true}, "A=<redacted>\n", nil, nil)
+		if !contains(m.status, "(RAM, env, values redacted)") {
+			t.Errorf("expected the redaction note, got: %s", m.status)
+		}
+	})
+
 	t.Run("creates model with provided configuration", func(t *testing.T) {
 		cfg := model.Config{
 			FilePath:       "/path/to/test.age",
@@ -160,106 +192,1987 @@ func TestModelUpdate(t *testing.T) {
 	})
 }
 
-func TestView(t *testing.T) {
-	t.Run("renders view with status and textarea", func(t *testing.T) {
-		cfg := model.Config{FilePath: "test.age"}
-		m := NewModel(cfg, "content", nil, nil)
+func TestParanoidMode(t *testing.T) {
+	t.Run("keeps original buffer sealed instead of in plaintext", func(t *testing.T) {
+		cfg := model.Config{FilePath: "test.age", Paranoid: true}
+		m := NewModel(cfg, "SECRET=hunter2", nil, nil)
 
-		view := m.View()
+		if m.orig != "" || m.lastSnapshot != "" {
+			t.Error("expected no plaintext copies of the buffer in paranoid mode")
+		}
+		if orig, err := m.original(); err != nil || orig != "SECRET=hunter2" {
+			t.Errorf("expected sealed original to open to plaintext, got %q, %v", orig, err)
+		}
+	})
 
-		if view == "" {
-			t.Error("expected non-empty view")
+	t.Run("diffs against sealed original", func(t *testing.T) {
+		cfg := model.Config{FilePath: "test.age", Paranoid: true}
+		m := NewModel(cfg, "KEY=old", nil, nil)
+		m.ta.SetValue("KEY=new")
+
+		result, _ := m.Update(tea.KeyMsg{Type: tea.KeyCtrlD})
+		m = result.(Model)
+
+		if !contains(m.status, "+KEY=\x1b[7mnew\x1b[27m") {
+			t.Errorf("expected diff to include edited line, got: %s", m.status)
 		}
-		if len(view) < 10 {
-			t.Error("expected view to contain substantial content")
+		if !m.modified() {
+			t.Error("expected buffer to be reported as modified")
 		}
 	})
 
-	t.Run("includes error in view when present", func(t *testing.T) {
-		cfg := model.Config{FilePath: "test.age"}
-		m := NewModel(cfg, "content", nil, nil)
-		m.err = fmt.Errorf("test error")
+	t.Run("seals snapshot on snapshot tick", func(t *testing.T) {
+		cfg := model.Config{FilePath: "test.age", Paranoid: true}
+		m := NewModel(cfg, "original", nil, nil)
+		m.ta.SetValue("new content")
 
-		view := m.View()
+		result, _ := m.Update(snapshotTick{})
+		m = result.(Model)
 
-		if !contains(view, "[ERROR]") {
-			t.Error("expected view to contain [ERROR] when error is present")
+		if m.lastSnapshot != "" {
+			t.Error("expected snapshot to stay sealed")
 		}
-		if !contains(view, "test error") {
-			t.Error("expected view to contain error message")
+		if snap, _ := m.snapSealed.String(); snap != "new content" {
+			t.Errorf("expected sealed snapshot to be updated, got %q", snap)
+		}
+	})
+
+	t.Run("keeps only a window of the buffer in the textarea", func(t *testing.T) {
+		var lines []string
+		for i := range 200 {
+			lines = append(lines, fmt.Sprintf("KEY%d=v%d", i, i))
+		}
+		text := strings.Join(lines, "\n")
+		m := NewModel(model.Config{FilePath: "test.env.age", Paranoid: true}, text, nil, nil)
+		m.setSize(80, 14) // 10 lines on screen, a 30-line window
+		press := func(k tea.KeyMsg, times int) {
+			for range times {
+				res, _ := m.Update(k)
+				m = res.(Model)
+			}
+		}
+
+		if n := m.ta.LineCount(); n > 30 || strings.Contains(m.ta.Value(), "KEY100=") {
+			t.Fatalf("expected a 30-line window, got %d lines", n)
+		}
+		press(tea.KeyMsg{Type: tea.KeyDown}, 150)
+		if m.line() != 150 || m.currentLine() != "KEY150=v150" {
+			t.Fatalf("expected the cursor on KEY150, got line %d %q", m.line(), m.currentLine())
+		}
+		if strings.Contains(m.ta.Value(), "KEY0=") || !strings.Contains(m.ta.Value(), "KEY150=") {
+			t.Errorf("expected the window to follow the cursor, got lines from %d", m.winStart)
+		}
+		if m.modified() {
+			t.Error("moving the window is not an edit")
+		}
+		if !strings.Contains(m.View(), "151 KEY150=v150") {
+			t.Error("expected the gutter to number lines of the whole buffer")
+		}
+
+		press(tea.KeyMsg{Type: tea.KeyEnd}, 1)
+		press(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("x")}, 1)
+		press(tea.KeyMsg{Type: tea.KeyCtrlHome}, 1)
+		if m.line() != 0 {
+			t.Errorf("expected Ctrl+Home to go to the top of the buffer, got line %d", m.line())
+		}
+		lines[150] += "x"
+		if got := m.text(); got != strings.Join(lines, "\n") {
+			t.Error("expected the edit to survive the window moving away")
+		}
+		if !m.modified() || m.lineCount() != 200 {
+			t.Errorf("expected a modified 200-line buffer, got %v, %d", m.modified(), m.lineCount())
+		}
+
+		m.gotoLine(180)
+		if m.currentLine() != "KEY179=v179" {
+			t.Errorf("expected gotoLine to open the window there, got %q", m.currentLine())
+		}
+		press(tea.KeyMsg{Type: tea.KeyUp}, 60)
+		if m.currentLine() != "KEY119=v119" || !strings.Contains(m.View(), "120 KEY119=v119") {
+			t.Errorf("expected the cursor on screen at KEY119, got %q", m.currentLine())
 		}
 	})
 }
 
-func TestUnifiedDiff(t *testing.T) {
-	t.Run("generates diff for different strings", func(t *testing.T) {
-		a := "line1\nline2\nline3"
-		b := "line1\nmodified\nline3"
+func TestReauthAfterInactivity(t *testing.T) {
+	t.Run("refuses to save when identities cannot be re-loaded after idling", func(t *testing.T) {
+		cfg := model.Config{
+			FilePath:       "test.age",
+			IdentitiesPath: "/nonexistent/key.txt",
+			ReauthAfter:    time.Minute,
+		}
+		m := NewModel(cfg, "KEY=value", nil, nil)
+		m.lastActivity = time.Now().Add(-2 * time.Minute)
 
-		diff := unifiedDiff(a, b, "test.txt")
+		result, _ := m.Update(tea.KeyMsg{Type: tea.KeyCtrlS})
+		m = result.(Model)
 
-		if diff == "" {
-			t.Error("expected non-empty diff")
+		if m.status != "Re-authentication failed; not saved." {
+			t.Errorf("expected re-authentication failure, got: %s", m.status)
 		}
-		if !contains(diff, "test.txt") {
-			t.Error("expected diff to contain filename")
+	})
+
+	t.Run("re-loads identities before saving after idling", func(t *testing.T) {
+		identity, err := age.GenerateX25519Identity()
+		if err != nil {
+			t.Fatalf("failed to generate identity: %v", err)
+		}
+		dir := t.TempDir()
+		keyPath := filepath.Join(dir, "key.txt")
+		if err := os.WriteFile(keyPath, []byte(identity.String()+"\n"), 0600); err != nil {
+			t.Fatalf("failed to write key: %v", err)
+		}
+		cfg := model.Config{
+			FilePath:       filepath.Join(dir, "test.age"),
+			IdentitiesPath: keyPath,
+			ReauthAfter:    time.Minute,
+		}
+		m := NewModel(cfg, "KEY=value", nil, []age.Recipient{identity.Recipient()})
+		m.lastActivity = time.Now().Add(-2 * time.Minute)
+
+		result, _ := m.Update(tea.KeyMsg{Type: tea.KeyCtrlS})
+		m = result.(Model)
+
+		if len(m.identities) != 1 {
+			t.Fatalf("expected identities to be re-loaded, got %d", len(m.identities))
+		}
+		if m.err != nil {
+			t.Errorf("expected save to succeed with fresh identities, got: %v", m.err)
 		}
 	})
 
-	t.Run("generates empty diff for identical strings", func(t *testing.T) {
-		a := "same content"
-		b := "same content"
+	t.Run("does not re-authenticate while active", func(t *testing.T) {
+		cfg := model.Config{
+			FilePath:       "test.age",
+			IdentitiesPath: "/nonexistent/key.txt",
+			ReauthAfter:    time.Minute,
+		}
+		m := NewModel(cfg, "KEY=value", nil, nil)
 
-		diff := unifiedDiff(a, b, "test.txt")
+		result, _ := m.Update(tea.KeyMsg{Type: tea.KeyCtrlS})
+		m = result.(Model)
 
-		if diff != "" {
-			t.Errorf("expected empty diff for identical strings, got: %s", diff)
+		if m.status == "Re-authentication failed; not saved." {
+			t.Error("expected no re-authentication for an active session")
 		}
 	})
 }
 
-func TestTruncate(t *testing.T) {
-	t.Run("truncates string longer than limit", func(t *testing.T) {
-		s := "this is a very long string that should be truncated"
-		truncated := truncate(s, 10)
+func TestValidationSeverity(t *testing.T) {
+	newModel := func(t *testing.T, sev model.Severity) Model {
+		identity, err := age.GenerateX25519Identity()
+		if err != nil {
+			t.Fatalf("failed to generate identity: %v", err)
+		}
+		cfg := model.Config{
+			FilePath:   filepath.Join(t.TempDir(), "data.json"),
+			Validation: map[string]model.Severity{"json": sev},
+		}
+		return NewModel(cfg, "not json", []age.Identity{identity}, []age.Recipient{identity.Recipient()})
+	}
 
-		if len(truncated) > 30 {
-			t.Errorf("expected truncated string to be around 10 chars + marker, got %d", len(truncated))
+	t.Run("blocks invalid content by default", func(t *testing.T) {
+		m := newModel(t, model.SeverityError)
+		result, _ := m.Update(tea.KeyMsg{Type: tea.KeyCtrlS})
+		m = result.(Model)
+		if m.status != "Validation failed; not saved." {
+			t.Errorf("expected validation failure, got: %s", m.status)
 		}
-		if !contains(truncated, "truncated") {
-			t.Error("expected truncation marker in output")
+	})
+
+	t.Run("saves with a warning when set to warn", func(t *testing.T) {
+		m := newModel(t, model.SeverityWarn)
+		result, _ := m.Update(tea.KeyMsg{Type: tea.KeyCtrlS})
+		m = result.(Model)
+		if m.err != nil {
+			t.Fatalf("expected save to succeed, got: %v", m.err)
+		}
+		if !contains(m.status, "Validation warning") || !contains(m.status, "Saved") {
+			t.Errorf("expected saved status with warning, got: %s", m.status)
 		}
 	})
 
-	t.Run("does not truncate string shorter than limit", func(t *testing.T) {
-		s := "short"
-		truncated := truncate(s, 100)
+	t.Run("saves silently when set to off", func(t *testing.T) {
+		m := newModel(t, model.SeverityOff)
+		result, _ := m.Update(tea.KeyMsg{Type: tea.KeyCtrlS})
+		m = result.(Model)
+		if m.err != nil || contains(m.status, "Validation warning") {
+			t.Errorf("expected plain save, got status %q err %v", m.status, m.err)
+		}
+	})
 
-		if truncated != s {
-			t.Errorf("expected string to remain unchanged, got %q", truncated)
+	t.Run("checks the schema next to the file", func(t *testing.T) {
+		m := newModel(t, model.SeverityError)
+		schema := filepath.Join(filepath.Dir(m.cfg.FilePath), validator.SchemaFile)
+		if err := os.WriteFile(schema, []byte(`{"required": ["token"]}`), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		m.ta.SetValue(`{"user": "svc"}`)
+		result, _ := m.Update(tea.KeyMsg{Type: tea.KeyCtrlS})
+		m = result.(Model)
+		if m.status != "Validation failed; not saved." || m.err == nil || !contains(m.err.Error(), `missing required key "token"`) {
+			t.Errorf("expected a schema failure, got status %q err %v", m.status, m.err)
 		}
 	})
+}
 
-	t.Run("does not truncate string equal to limit", func(t *testing.T) {
-		s := "exactly10c"
-		truncated := truncate(s, 10)
+func TestOpenAt(t *testing.T) {
+	t.Run("places the cursor on an env key", func(t *testing.T) {
+		cfg := model.Config{FilePath: "app.env.age", OpenAt: "DB_PASSWORD"}
+		m := NewModel(cfg, "A=1\nB=2\nDB_PASSWORD=x\nC=3", nil, nil)
+		if m.ta.Line() != 2 {
+			t.Errorf("expected cursor on line index 2, got %d", m.ta.Line())
+		}
+		if !contains(m.status, "DB_PASSWORD (line 3)") {
+			t.Errorf("unexpected status: %s", m.status)
+		}
+	})
 
-		if truncated != s {
-			t.Errorf("expected string to remain unchanged, got %q", truncated)
+	t.Run("places the cursor on a YAML path", func(t *testing.T) {
+		cfg := model.Config{FilePath: "app.yaml.age", OpenAt: "db.password"}
+		m := NewModel(cfg, "db:\n  user: u\n  password: p\nother: 1", nil, nil)
+		if m.ta.Line() != 2 {
+			t.Errorf("expected cursor on line index 2, got %d", m.ta.Line())
+		}
+	})
+
+	t.Run("reports a missing key", func(t *testing.T) {
+		cfg := model.Config{FilePath: "app.env.age", OpenAt: "NOPE"}
+		m := NewModel(cfg, "A=1", nil, nil)
+		if m.err == nil || len(m.errs.entries) != 1 {
+			t.Errorf("expected a recorded error, got %v", m.err)
 		}
 	})
 }
 
-// Helper function to check if a string contains a substring
-func contains(s, substr string) bool {
-	return len(s) >= len(substr) && (s == substr || len(s) > len(substr) && indexOf(s, substr) >= 0)
+func TestErrorPane(t *testing.T) {
+	failingSave := func(t *testing.T) Model {
+		cfg := model.Config{FilePath: "data.json"}
+		m := NewModel(cfg, "{", nil, nil)
+		result, _ := m.Update(tea.KeyMsg{Type: tea.KeyCtrlS})
+		return result.(Model)
+	}
+
+	t.Run("records errors with their full text", func(t *testing.T) {
+		m := failingSave(t)
+		e, ok := m.errs.latest()
+		if !ok || e.level != "error" || !contains(e.text, "JSON parse error") {
+			t.Errorf("expected JSON error in pane, got %+v", e)
+		}
+	})
+
+	t.Run("does not record the same error twice", func(t *testing.T) {
+		m := failingSave(t)
+		result, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("x")})
+		m = result.(Model)
+		if len(m.errs.entries) != 1 {
+			t.Errorf("expected 1 entry, got %d", len(m.errs.entries))
+		}
+	})
+
+	t.Run("opens, copies, and closes", func(t *testing.T) {
+		m := failingSave(t)
+		clip := &fakeClipboard{}
+		m.clip = clip
+
+		result, _ := m.Update(tea.KeyMsg{Type: tea.KeyCtrlG})
+		m = result.(Model)
+		if !m.errs.open || !contains(m.View(), "Errors & warnings (1)") {
+			t.Fatalf("expected open pane, got view:\n%s", m.View())
+		}
+
+		result, _ = m.Update(tea.KeyMsg{Type: tea.KeyCtrlY})
+		m = result.(Model)
+		if !contains(clip.text, "JSON parse error") {
+			t.Errorf("expected error text on clipboard, got %q", clip.text)
+		}
+
+		result, _ = m.Update(tea.KeyMsg{Type: tea.KeyEsc})
+		m = result.(Model)
+		if m.errs.open || m.quitting {
+			t.Error("expected Esc to close the pane without quitting")
+		}
+	})
+
+	t.Run("reports when there is nothing to show", func(t *testing.T) {
+		m := NewModel(model.Config{FilePath: "x.age"}, "", nil, nil)
+		result, _ := m.Update(tea.KeyMsg{Type: tea.KeyCtrlG})
+		m = result.(Model)
+		if m.errs.open {
+			t.Error("expected pane to stay closed without entries")
+		}
+	})
 }
 
-func indexOf(s, substr string) int {
-	for i := 0; i <= len(s)-len(substr); i++ {
-		if s[i:i+len(substr)] == substr {
-			return i
+func TestStatusHistory(t *testing.T) {
+	t.Run("keeps a diff preview after the status moves on", func(t *testing.T) {
+		m := NewModel(model.Config{FilePath: "test.env.age"}, "A=1", nil, nil)
+		m.ta.SetValue("A=2")
+		result, _ := m.Update(tea.KeyMsg{Type: tea.KeyCtrlD})
+		m = result.(Model)
+		result, _ = m.Update(tea.KeyMsg{Type: tea.KeyCtrlG})
+		m = result.(Model)
+
+		found := false
+		for _, e := range m.history.entries {
+			if contains(e.text, "+A=\x1b[7m2\x1b[27m") {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("expected diff preview in history, got %+v", m.history.entries)
+		}
+	})
+
+	t.Run("keeps only first lines in paranoid mode", func(t *testing.T) {
+		m := NewModel(model.Config{FilePath: "test.env.age", Paranoid: true}, "A=1", nil, nil)
+		m.ta.SetValue("A=secret")
+		result, _ := m.Update(tea.KeyMsg{Type: tea.KeyCtrlD})
+		m = result.(Model)
+		for _, e := range m.history.entries {
+			if contains(e.text, "secret") {
+				t.Errorf("expected no plaintext in paranoid history, got %q", e.text)
+			}
+		}
+	})
+
+	t.Run("opens and closes with Ctrl+L", func(t *testing.T) {
+		m := NewModel(model.Config{FilePath: "test.age"}, "", nil, nil)
+		result, _ := m.Update(tea.KeyMsg{Type: tea.KeyCtrlL})
+		m = result.(Model)
+		if !m.history.open || !contains(m.View(), "Status history") {
+			t.Fatal("expected history pane to open")
+		}
+		result, _ = m.Update(tea.KeyMsg{Type: tea.KeyCtrlL})
+		m = result.(Model)
+		if m.history.open {
+			t.Error("expected Ctrl+L to close the history pane")
+		}
+	})
+}
+
+func TestToast(t *testing.T) {
+	t.Run("shows a toast after saving and hides it on expiry", func(t *testing.T) {
+		identity, err := age.GenerateX25519Identity()
+		if err != nil {
+			t.Fatalf("failed to generate identity: %v", err)
+		}
+		cfg := model.Config{FilePath: filepath.Join(t.TempDir(), "app.env.age")}
+		m := NewModel(cfg, "A=1", []age.Identity{identity}, []age.Recipient{identity.Recipient()})
+
+		result, _ := m.Update(tea.KeyMsg{Type: tea.KeyCtrlS})
+		m = result.(Model)
+		if m.toast != "Saved app.env.age" || !contains(m.View(), "✔ Saved app.env.age") {
+			t.Fatalf("expected saved toast, got %q", m.toast)
 		}
+
+		result, _ = m.Update(toastExpiredMsg{seq: m.toastSeq - 1})
+		m = result.(Model)
+		if m.toast == "" {
+			t.Error("expected a stale expiry to leave the toast")
+		}
+		result, _ = m.Update(toastExpiredMsg{seq: m.toastSeq})
+		m = result.(Model)
+		if m.toast != "" {
+			t.Error("expected the toast to expire")
+		}
+	})
+}
+
+func TestBundle(t *testing.T) {
+	a, _ := age.GenerateX25519Identity()
+	b, _ := age.GenerateX25519Identity()
+	dir := t.TempDir()
+	pathA, pathB := filepath.Join(dir, "a.env.age"), filepath.Join(dir, "b.env.age")
+	parts := []bundle.Part{{Path: pathA, Content: "A=1\n"}, {Path: pathB, Content: "B=1\n"}}
+	recips := map[string][]age.Recipient{pathA: {a.Recipient()}, pathB: {b.Recipient()}}
+	newModel := func() Model {
+		return NewBundleModel(model.Config{FilePath: dir}, parts, []age.Identity{a, b}, recips)
 	}
-	return -1
+	save := func(m Model) Model {
+		for i := 0; i < 2; i++ { // second press confirms
+			result, _ := m.Update(tea.KeyMsg{Type: tea.KeyCtrlS})
+			m = result.(Model)
+		}
+		return m
+	}
+
+	t.Run("writes only changed sections, each to its own recipients", func(t *testing.T) {
+		m := newModel()
+		m.ta.SetValue(strings.Replace(m.ta.Value(), "B=1", "B=2", 1))
+		m = save(m)
+		if m.err != nil {
+			t.Fatalf("save failed: %v", m.err)
+		}
+		if _, err := os.Stat(pathA); !os.IsNotExist(err) {
+			t.Errorf("expected unchanged %s not to be written, stat err: %v", pathA, err)
+		}
+		got, err := agepkg.DecryptToMemory(pathB, []age.Identity{b})
+		if err != nil {
+			t.Fatalf("expected b's recipient to decrypt: %v", err)
+		}
+		if got != "B=2\n" {
+			t.Errorf("unexpected content: %q", got)
+		}
+		if _, err := agepkg.DecryptToMemory(pathB, []age.Identity{a}); err == nil {
+			t.Error("expected a's identity not to decrypt b's file")
+		}
+	})
+
+	t.Run("refuses to save when a marker is removed", func(t *testing.T) {
+		m := newModel()
+		m.ta.SetValue(strings.Replace(m.ta.Value(), bundle.Marker(pathB), "", 1))
+		m = save(m)
+		if m.err == nil || !contains(m.status, "not saved") {
+			t.Errorf("expected save to be refused, got status %q err %v", m.status, m.err)
+		}
+	})
+
+	t.Run("names the file in validation errors", func(t *testing.T) {
+		m := newModel()
+		m.ta.SetValue(strings.Replace(m.ta.Value(), "A=1", "not valid", 1))
+		m = save(m)
+		if m.err == nil || !contains(m.err.Error(), pathA) {
+			t.Errorf("expected a validation error naming %s, got %v", pathA, m.err)
+		}
+	})
+}
+
+func TestRecipientsWatch(t *testing.T) {
+	alice, _ := age.GenerateX25519Identity()
+	bob, _ := age.GenerateX25519Identity()
+	dir := t.TempDir()
+	recipFile := filepath.Join(dir, ".age-recipients")
+	if err := os.WriteFile(recipFile, []byte(alice.Recipient().String()+"\n"), 0600); err != nil {
+		t.Fatalf("failed to write recipients: %v", err)
+	}
+	cfg := model.Config{FilePath: filepath.Join(dir, "app.env.age"), RecipientsFile: recipFile}
+	m := NewModel(cfg, "KEY=value", []age.Identity{alice}, []age.Recipient{alice.Recipient()})
+	press := func(k tea.KeyType) {
+		result, _ := m.Update(tea.KeyMsg{Type: k})
+		m = result.(Model)
+	}
+
+	added := alice.Recipient().String() + "\n" + bob.Recipient().String() + "\n"
+	if err := os.WriteFile(recipFile, []byte(added), 0600); err != nil {
+		t.Fatalf("failed to write recipients: %v", err)
+	}
+
+	t.Run("notices the change while editing", func(t *testing.T) {
+		result, _ := m.Update(snapshotTick{})
+		m = result.(Model)
+		if !contains(m.status, "Recipients file changed on disk (+1/-0") {
+			t.Errorf("expected a change notice, got: %s", m.status)
+		}
+	})
+
+	t.Run("asks before saving to the old set", func(t *testing.T) {
+		press(tea.KeyCtrlS)
+		if !contains(m.status, "Ctrl+R: reload") {
+			t.Errorf("expected a reload prompt, got: %s", m.status)
+		}
+		if _, err := os.Stat(cfg.FilePath); !os.IsNotExist(err) {
+			t.Error("expected nothing to be written yet")
+		}
+	})
+
+	t.Run("reloads and saves to the new set", func(t *testing.T) {
+		press(tea.KeyCtrlR)
+		if len(m.recips) != 2 {
+			t.Fatalf("expected 2 recipients after reload, got %d", len(m.recips))
+		}
+		press(tea.KeyCtrlS)
+		if m.err != nil {
+			t.Fatalf("save failed: %v", m.err)
+		}
+		if _, err := agepkg.DecryptToMemory(cfg.FilePath, []age.Identity{bob}); err != nil {
+			t.Errorf("expected the new member to decrypt: %v", err)
+		}
+	})
+
+	t.Run("saves to the old set when asked twice", func(t *testing.T) {
+		if err := os.WriteFile(recipFile, []byte(alice.Recipient().String()+"\n"), 0600); err != nil {
+			t.Fatalf("failed to write recipients: %v", err)
+		}
+		m.ta.SetValue("KEY=other")
+		press(tea.KeyCtrlS)
+		if !contains(m.status, "+0/-1") {
+			t.Fatalf("expected a removal prompt, got: %s", m.status)
+		}
+		press(tea.KeyCtrlS)
+		press(tea.KeyCtrlS) // confirm the changed content
+		if m.err != nil || !contains(m.status, "Saved") {
+			t.Fatalf("expected a save, got status %q err %v", m.status, m.err)
+		}
+		if _, err := agepkg.DecryptToMemory(cfg.FilePath, []age.Identity{bob}); err != nil {
+			t.Errorf("expected the recipients loaded before to be kept: %v", err)
+		}
+	})
+}
+
+func TestRecipientsPanel(t *testing.T) {
+	alice, _ := age.GenerateX25519Identity()
+	bob, _ := age.GenerateX25519Identity()
+	dir := t.TempDir()
+	recipFile := filepath.Join(dir, ".age-recipients")
+	initial := "# team\n" + alice.Recipient().String() + " # alice\n"
+	if err := os.WriteFile(recipFile, []byte(initial), 0644); err != nil {
+		t.Fatalf("failed to write recipients: %v", err)
+	}
+	cfg := model.Config{FilePath: filepath.Join(dir, "app.env.age"), RecipientsFile: recipFile}
+	m := NewModel(cfg, "KEY=value", []age.Identity{alice}, []age.Recipient{alice.Recipient()})
+	send := func(msgs ...tea.KeyMsg) {
+		for _, msg := range msgs {
+			result, _ := m.Update(msg)
+			m = result.(Model)
+		}
+	}
+	runes := func(s string) tea.KeyMsg { return tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(s)} }
+	altR := tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("r"), Alt: true}
+
+	t.Run("lists the recipients with labels", func(t *testing.T) {
+		send(altR)
+		if m.recipPanel == nil || len(m.recipPanel.entries) != 1 {
+			t.Fatalf("expected the panel with one recipient, got %+v", m.recipPanel)
+		}
+		if !contains(m.View(), "alice") {
+			t.Error("expected the label in the panel")
+		}
+	})
+
+	t.Run("adds a line and saves to the new set", func(t *testing.T) {
+		send(runes("a"), runes(bob.Recipient().String()+" # bob"), tea.KeyMsg{Type: tea.KeyEnter})
+		if m.err != nil || len(m.recips) != 2 || len(m.recipPanel.entries) != 2 {
+			t.Fatalf("expected two recipients, got %d (err %v, status %q)", len(m.recips), m.err, m.status)
+		}
+		b, _ := os.ReadFile(recipFile)
+		if want := initial + bob.Recipient().String() + " # bob\n"; string(b) != want {
+			t.Errorf("got file %q, want %q", b, want)
+		}
+		if info, _ := os.Stat(recipFile); info.Mode().Perm() != 0644 {
+			t.Errorf("expected the file mode kept, got %v", info.Mode().Perm())
+		}
+		send(runes("a"), runes(alice.Recipient().String()), tea.KeyMsg{Type: tea.KeyEnter})
+		if !contains(m.status, "already a recipient") {
+			t.Errorf("expected a duplicate to be refused, got %q", m.status)
+		}
+		send(tea.KeyMsg{Type: tea.KeyEsc}, tea.KeyMsg{Type: tea.KeyCtrlS})
+		if _, err := agepkg.DecryptToMemory(cfg.FilePath, []age.Identity{bob}); err != nil {
+			t.Errorf("expected the new member to decrypt: %v", err)
+		}
+	})
+
+	t.Run("refuses lines that do not parse", func(t *testing.T) {
+		send(altR, runes("a"), runes("age1nope"), tea.KeyMsg{Type: tea.KeyEnter})
+		if m.err == nil || len(m.recips) != 2 {
+			t.Errorf("expected an error and no change, got %v", m.err)
+		}
+	})
+
+	t.Run("removes a line after a second press", func(t *testing.T) {
+		send(tea.KeyMsg{Type: tea.KeyDown}, runes("d"))
+		if len(m.recips) != 2 || !contains(m.status, "Remove bob") {
+			t.Fatalf("expected a prompt first, got %q", m.status)
+		}
+		send(runes("d"))
+		b, _ := os.ReadFile(recipFile)
+		if string(b) != initial || len(m.recips) != 1 {
+			t.Errorf("expected bob removed, got %q", b)
+		}
+		send(runes("d"), runes("d"))
+		if !contains(m.status, "last recipient") {
+			t.Errorf("expected the last recipient kept, got %q", m.status)
+		}
+	})
+}
+
+func TestAutoIndent(t *testing.T) {
+	enter := tea.KeyMsg{Type: tea.KeyEnter}
+	tab := tea.KeyMsg{Type: tea.KeyTab}
+	runes := func(s string) tea.KeyMsg { return tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(s)} }
+	typed := func(path string, msgs ...tea.KeyMsg) string {
+		m := NewModel(model.Config{FilePath: path}, "", nil, nil)
+		for _, msg := range msgs {
+			result, _ := m.Update(msg)
+			m = result.(Model)
+		}
+		return m.ta.Value()
+	}
+	cases := []struct {
+		name string
+		path string
+		keys []tea.KeyMsg
+		want string
+	}{
+		{"yaml key opens a level", "app.yaml.age", []tea.KeyMsg{runes("db:"), enter, runes("host: x"), enter, runes("port: 1")}, "db:\n  host: x\n  port: 1"},
+		{"yaml comment after a key", "app.yaml.age", []tea.KeyMsg{runes("db: # main"), enter, runes("a: 1")}, "db: # main\n  a: 1"},
+		{"yaml value keeps the level", "app.yaml.age", []tea.KeyMsg{runes("a: 1"), enter, runes("b: 2")}, "a: 1\nb: 2"},
+		{"json brace pair", "app.json.age", []tea.KeyMsg{runes("{}"), {Type: tea.KeyLeft}, enter, runes(`"a": 1`)}, "{\n  \"a\": 1\n}"},
+		{"json nested bracket", "app.json.age", []tea.KeyMsg{runes("{"), enter, runes(`"a": [`), enter, runes("1")}, "{\n  \"a\": [\n    1"},
+		{"toml inline array", "app.toml.age", []tea.KeyMsg{runes("[db]"), enter, runes("hosts = ["), enter, runes(`"a",`), enter, runes(`"b"`)}, "[db]\nhosts = [\n  \"a\",\n  \"b\""},
+		{"tab inserts spaces to the next level", "app.yaml.age", []tea.KeyMsg{runes("a:"), enter, tab, runes("b")}, "a:\n    b"},
+		{"tab aligns to the level", "app.json.age", []tea.KeyMsg{runes(" "), tab, runes("x")}, "  x"},
+		{"env keeps the plain textarea", "app.env.age", []tea.KeyMsg{runes("A=1"), enter, tab, runes("B=2")}, "A=1\nB=2"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := typed(c.path, c.keys...); got != c.want {
+				t.Errorf("got %q, want %q", got, c.want)
+			}
+		})
+	}
+}
+
+func TestSelection(t *testing.T) {
+	key := func(s string) tea.KeyMsg {
+		switch s {
+		case "shift+right":
+			return tea.KeyMsg{Type: tea.KeyShiftRight}
+		case "shift+down":
+			return tea.KeyMsg{Type: tea.KeyShiftDown}
+		case "right":
+			return tea.KeyMsg{Type: tea.KeyRight}
+		case "down":
+			return tea.KeyMsg{Type: tea.KeyDown}
+		case "end":
+			return tea.KeyMsg{Type: tea.KeyEnd}
+		case "esc":
+			return tea.KeyMsg{Type: tea.KeyEsc}
+		case "ctrl+y":
+			return tea.KeyMsg{Type: tea.KeyCtrlY}
+		}
+		alt := strings.HasPrefix(s, "alt+")
+		return tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(strings.TrimPrefix(s, "alt+")), Alt: alt}
+	}
+	newModel := func(text string) Model {
+		m := NewModel(model.Config{FilePath: "app.env.age"}, text, nil, nil)
+		m.gotoLine(1)
+		return m
+	}
+	send := func(m Model, keys ...string) Model {
+		for _, k := range keys {
+			result, _ := m.Update(key(k))
+			m = result.(Model)
+		}
+		return m
+	}
+
+	t.Run("shift+arrows select and cut to the register", func(t *testing.T) {
+		m := send(newModel("A=1\nB=2\nC=3"), "shift+down", "alt+x")
+		if got := m.ta.Value(); got != "B=2\nC=3" {
+			t.Fatalf("got %q after cut", got)
+		}
+		if string(m.register) != "A=1\n" || !m.changed {
+			t.Errorf("expected the line in the register and the buffer changed, got %q", string(m.register))
+		}
+		m = send(m, "down", "alt+y")
+		if got := m.ta.Value(); got != "B=2\nA=1\nC=3" {
+			t.Errorf("got %q after paste", got)
+		}
+	})
+
+	t.Run("plain movement ends a shift selection", func(t *testing.T) {
+		m := send(newModel("A=1\nB=2"), "shift+right", "right")
+		if m.sel != nil {
+			t.Error("expected the selection to end")
+		}
+	})
+
+	t.Run("alt+v selects with plain movement and copies", func(t *testing.T) {
+		m := send(newModel("KEY=value\nB=2"), "alt+v", "right", "right", "right", "alt+w")
+		if string(m.register) != "KEY" || m.sel != nil || m.changed {
+			t.Errorf("expected KEY in the register and the buffer unchanged, got %q", string(m.register))
+		}
+		m = send(m, "end", "alt+y")
+		if got := m.ta.Value(); got != "KEY=valueKEY\nB=2" {
+			t.Errorf("got %q after paste", got)
+		}
+	})
+
+	t.Run("paste replaces the selection", func(t *testing.T) {
+		m := newModel("A=1")
+		m.register = []rune("B")
+		m = send(m, "shift+right", "alt+y")
+		if got := m.ta.Value(); got != "B=1" {
+			t.Errorf("got %q", got)
+		}
+	})
+
+	t.Run("esc cancels the selection without quitting", func(t *testing.T) {
+		m := send(newModel("A=1"), "alt+v", "right", "esc")
+		if m.sel != nil || m.quitting {
+			t.Error("expected the selection cancelled and the editor open")
+		}
+	})
+
+	t.Run("copy puts the selection on the clipboard", func(t *testing.T) {
+		clip := &fakeClipboard{}
+		m := newModel("TOKEN=abc")
+		m.clip = clip
+		m = send(m, "alt+v", "end", "ctrl+y")
+		if clip.text != "TOKEN=abc" || m.sel != nil {
+			t.Errorf("expected the selection on the clipboard, got %q", clip.text)
+		}
+	})
+}
+
+func TestSnapshots(t *testing.T) {
+	runes := func(s string) tea.KeyMsg { return tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(s)} }
+	altS := tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("s"), Alt: true}
+	enter := tea.KeyMsg{Type: tea.KeyEnter}
+	for _, paranoid := range []bool{false, true} {
+		t.Run(fmt.Sprintf("paranoid=%v", paranoid), func(t *testing.T) {
+			m := NewModel(model.Config{FilePath: "app.env.age", Paranoid: paranoid}, "A=1", nil, nil)
+			send := func(msgs ...tea.KeyMsg) {
+				for _, msg := range msgs {
+					result, _ := m.Update(msg)
+					m = result.(Model)
+				}
+			}
+			send(altS, runes("n"), runes("before refactor"), enter, altS)
+			if len(m.snapshots) != 1 || m.snapshots[0].name != "before refactor" || m.snapPanel != nil {
+				t.Fatalf("expected one named snapshot and the panel closed, got %+v", m.snapshots)
+			}
+			if paranoid && (m.snapshots[0].text != "" || m.snapshots[0].sealed == nil) {
+				t.Error("expected the snapshot sealed in paranoid mode")
+			}
+
+			m.ta.SetValue("A=2")
+			send(altS, runes("d"))
+			if !contains(m.status, "@@ -1 +1 @@") || !contains(m.status, "(before refactor)") {
+				t.Errorf("expected a diff against the snapshot, got %q", m.status)
+			}
+
+			send(altS, runes("r"))
+			if m.ta.Value() != "A=2" {
+				t.Fatal("expected a single r to only ask")
+			}
+			send(runes("r"))
+			if m.ta.Value() != "A=1" || !m.changed {
+				t.Fatalf("expected the snapshot restored, got %q", m.ta.Value())
+			}
+			if len(m.snapshots) != 2 || m.snapshots[1].name != "before restoring before refactor" {
+				t.Fatalf("expected the replaced buffer kept as a snapshot, got %d", len(m.snapshots))
+			}
+			if v, _ := m.snapshots[1].value(); v != "A=2" {
+				t.Errorf("got %q in the undo snapshot", v)
+			}
+		})
+	}
+}
+
+func TestSaveRecipientsPreview(t *testing.T) {
+	alice, _ := age.GenerateX25519Identity()
+	bob, _ := age.GenerateX25519Identity()
+	dir := t.TempDir()
+	recipFile := filepath.Join(dir, ".age-recipients")
+	if err := os.WriteFile(recipFile, []byte(alice.Recipient().String()+" # alice\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "app.env.age")
+	cipher, err := agepkg.EncryptToMemory([]byte("A=1"), []age.Recipient{alice.Recipient()}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, cipher, 0600); err != nil {
+		t.Fatal(err)
+	}
+	cfg := model.Config{FilePath: path, RecipientsFile: recipFile, Armor: true}
+	m := NewModel(cfg, "A=1", []age.Identity{alice}, []age.Recipient{alice.Recipient()})
+	send := func(msgs ...tea.KeyMsg) {
+		for _, msg := range msgs {
+			result, _ := m.Update(msg)
+			m = result.(Model)
+		}
+	}
+	save := tea.KeyMsg{Type: tea.KeyCtrlS}
+
+	t.Run("lists the recipients with fingerprints", func(t *testing.T) {
+		m.ta.SetValue("A=2")
+		send(save)
+		fp := agepkg.LabeledRecipient{Key: alice.Recipient().String()}.Fingerprint()
+		if !m.pendingConfirm || !contains(m.status, "alice") || !contains(m.status, fp) {
+			t.Fatalf("expected alice and her fingerprint in the confirmation, got %q", m.status)
+		}
+		if !contains(m.status, "Same number and kinds") {
+			t.Errorf("expected the header unchanged, got %q", m.status)
+		}
+		send(save)
+		if m.changed {
+			t.Fatalf("expected the second Ctrl+S to save, got %q", m.status)
+		}
+	})
+
+	t.Run("asks before a recipients-only change", func(t *testing.T) {
+		if err := os.WriteFile(recipFile, []byte(alice.Recipient().String()+" # alice\n"+bob.Recipient().String()+" # bob\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		send(tea.KeyMsg{Type: tea.KeyCtrlR}, save)
+		if !m.pendingConfirm || !contains(m.status, "X25519 1 -> 2") || !contains(m.status, "No content changes.") {
+			t.Fatalf("expected a confirmation naming the change, got %q", m.status)
+		}
+		send(save)
+		h, err := agepkg.InspectHeader(path)
+		if err != nil || len(h.Stanzas) != 2 {
+			t.Errorf("expected the file re-encrypted to two recipients, got %v (%v)", h.StanzaTypes(), err)
+		}
+	})
+}
+
+func TestSkipInvalidRecipients(t *testing.T) {
+	alice, _ := age.GenerateX25519Identity()
+	dir := t.TempDir()
+	recipFile := filepath.Join(dir, ".age-recipients")
+	if err := os.WriteFile(recipFile, []byte(alice.Recipient().String()+" # alice\nage1notakey # carol\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "app.env.age")
+	cfg := model.Config{FilePath: path, RecipientsFile: recipFile, SkipInvalidRecipients: true}
+	m := NewModel(cfg, "A=1", []age.Identity{alice}, []age.Recipient{alice.Recipient()})
+	if !contains(m.status, "WARNING: skipped 1 unusable line(s)") {
+		t.Fatalf("expected a warning on open, got %q", m.status)
+	}
+	if len(m.errs.entries) != 1 || !contains(m.errs.entries[0].text, "line 2 (age1notakey)") {
+		t.Errorf("expected the skipped line in the errors pane, got %v", m.errs.entries)
+	}
+
+	save := tea.KeyMsg{Type: tea.KeyCtrlS}
+	result, _ := m.Update(save)
+	m = result.(Model)
+	if !m.pendingConfirm || !contains(m.status, "NOT encrypting to 1 skipped line(s)") || !contains(m.status, "alice") {
+		t.Fatalf("expected an unmodified save to ask, naming the skipped line, got %q", m.status)
+	}
+	result, _ = m.Update(save)
+	m = result.(Model)
+	h, err := agepkg.InspectHeader(path)
+	if err != nil || len(h.Stanzas) != 1 {
+		t.Errorf("expected the file saved to the one usable recipient, got %v (%v)", h.StanzaTypes(), err)
+	}
+}
+
+func TestStanzaPolicy(t *testing.T) {
+	id, _ := age.GenerateX25519Identity()
+	scrypt, err := age.NewScryptRecipient("passphrase")
+	if err != nil {
+		t.Fatalf("scrypt recipient failed: %v", err)
+	}
+	scrypt.SetWorkFactor(10)
+	path := filepath.Join(t.TempDir(), "app.env.age")
+	cfg := model.Config{FilePath: path, Stanzas: model.StanzaPolicy{ForbidScrypt: true}}
+
+	t.Run("refuses to save to disallowed stanza types", func(t *testing.T) {
+		m := NewModel(cfg, "KEY=value", []age.Identity{id}, []age.Recipient{scrypt})
+		m.ta.SetValue("KEY=changed")
+		result, _ := m.Update(tea.KeyMsg{Type: tea.KeyCtrlS})
+		m = result.(Model)
+		if m.err == nil || !contains(m.err.Error(), "scrypt") {
+			t.Errorf("expected a stanza policy error, got %v", m.err)
+		}
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Error("expected nothing to be written")
+		}
+	})
+
+	t.Run("saves when the recipients comply", func(t *testing.T) {
+		m := NewModel(cfg, "KEY=value", []age.Identity{id}, []age.Recipient{id.Recipient()})
+		m.ta.SetValue("KEY=changed")
+		for range 2 {
+			result, _ := m.Update(tea.KeyMsg{Type: tea.KeyCtrlS})
+			m = result.(Model)
+		}
+		if m.err != nil {
+			t.Fatalf("save failed: %v", m.err)
+		}
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("expected the file to be written: %v", err)
+		}
+	})
+}
+
+func TestScratch(t *testing.T) {
+	id, _ := age.GenerateX25519Identity()
+	dir := t.TempDir()
+	existing := filepath.Join(dir, "taken.age")
+	if err := os.WriteFile(existing, []byte("x"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	newModel := func() Model {
+		m := NewScratchModel(model.Config{Armor: true}, []age.Identity{id}, []age.Recipient{id.Recipient()})
+		m.ta.SetValue("draft: the new root password is hunter2")
+		m.changed = true
+		return m
+	}
+	send := func(m Model, msgs ...tea.Msg) Model {
+		for _, msg := range msgs {
+			result, _ := m.Update(msg)
+			m = result.(Model)
+		}
+		return m
+	}
+	typed := func(s string) tea.Msg { return tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(s)} }
+
+	t.Run("asks for a path and refuses existing files", func(t *testing.T) {
+		m := send(newModel(), tea.KeyMsg{Type: tea.KeyCtrlS})
+		if m.pathInput == nil {
+			t.Fatal("expected the path prompt to open")
+		}
+		m = send(m, typed(existing), tea.KeyMsg{Type: tea.KeyEnter})
+		if m.err == nil || !contains(m.err.Error(), "already exists") {
+			t.Errorf("expected an existing-file error, got %v", m.err)
+		}
+		if m.pathInput == nil {
+			t.Error("expected the prompt to stay open")
+		}
+	})
+
+	t.Run("encrypts to the chosen path after confirmation", func(t *testing.T) {
+		path := filepath.Join(dir, "notes.txt.age")
+		m := send(newModel(), tea.KeyMsg{Type: tea.KeyCtrlS}, typed(path), tea.KeyMsg{Type: tea.KeyEnter})
+		if !m.pendingConfirm {
+			t.Fatalf("expected a confirmation prompt, got status: %s (err %v)", m.status, m.err)
+		}
+		m = send(m, tea.KeyMsg{Type: tea.KeyCtrlS})
+		plain, err := agepkg.DecryptToMemory(path, []age.Identity{id})
+		if err != nil || !contains(plain, "hunter2") {
+			t.Errorf("expected the scratchpad in %s, got %q (err %v)", path, plain, err)
+		}
+	})
+
+	t.Run("discarding zeroizes the buffer", func(t *testing.T) {
+		m := newModel()
+		rows := *(*[][]rune)(unsafe.Pointer(reflect.ValueOf(&m.ta).Elem().FieldByName("value").UnsafeAddr()))
+		row := rows[0]
+		m = send(m, tea.KeyMsg{Type: tea.KeyCtrlQ})
+		if !contains(m.status, "discard and zeroize") {
+			t.Fatalf("expected a discard prompt, got: %s", m.status)
+		}
+		m = send(m, tea.KeyMsg{Type: tea.KeyCtrlQ})
+		if !m.quitting {
+			t.Fatal("expected the second Ctrl+Q to quit")
+		}
+		for _, r := range row[:cap(row)] {
+			if r != 0 {
+				t.Fatalf("expected the buffer to be zeroed, found %q", string(row))
+			}
+		}
+		if m.ta.Value() != "" {
+			t.Errorf("expected an empty buffer, got %q", m.ta.Value())
+		}
+	})
+}
+
+func TestSymbolPanel(t *testing.T) {
+	content := "APP_NAME=demo\nDB_HOST=localhost\nDB_PASSWORD=secret\nREDIS_URL=redis://\n"
+	m := NewModel(model.Config{FilePath: "app.env.age"}, content, nil, nil)
+	send := func(msgs ...tea.Msg) {
+		for _, msg := range msgs {
+			result, _ := m.Update(msg)
+			m = result.(Model)
+		}
+	}
+
+	t.Run("lists every key", func(t *testing.T) {
+		send(tea.KeyMsg{Type: tea.KeyCtrlT})
+		if m.symbols == nil || len(m.symbols.matches) != 4 {
+			t.Fatalf("expected 4 keys, got %+v", m.symbols)
+		}
+	})
+
+	t.Run("filters fuzzily and jumps to the choice", func(t *testing.T) {
+		send(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("dbpw")})
+		if got := m.symbols.matches; len(got) != 1 || got[0].Name != "DB_PASSWORD" {
+			t.Fatalf("expected only DB_PASSWORD, got %+v", got)
+		}
+		send(tea.KeyMsg{Type: tea.KeyEnter})
+		if m.symbols != nil || m.ta.Line() != 2 {
+			t.Errorf("expected the panel closed and the cursor on line 3, got line %d", m.ta.Line()+1)
+		}
+	})
+
+	t.Run("refuses buffers that do not parse", func(t *testing.T) {
+		bad := NewModel(model.Config{FilePath: "app.json.age"}, "{\"a\": [", nil, nil)
+		result, _ := bad.Update(tea.KeyMsg{Type: tea.KeyCtrlT})
+		bad = result.(Model)
+		if bad.symbols != nil || bad.err == nil {
+			t.Errorf("expected a parse error and no panel, got err %v", bad.err)
+		}
+	})
+}
+
+func TestFuzzyFilter(t *testing.T) {
+	syms := []outline.Symbol{{Name: "db.password_hint"}, {Name: "cache.ttl"}, {Name: "db.password"}}
+	got := fuzzyFilter(syms, "db.pass")
+	if len(got) != 2 || got[0].Name != "db.password" {
+		t.Errorf("expected db.password first of two, got %+v", got)
+	}
+	if got := fuzzyFilter(syms, "zzz"); len(got) != 0 {
+		t.Errorf("expected no matches, got %+v", got)
+	}
+}
+
+func TestReplace(t *testing.T) {
+	content := "DB_HOST=db1.old.internal\nCACHE_HOST=cache.old.internal\nAPI_URL=https://api.old.internal\n"
+	open := func(cfg model.Config) (*Model, func(...string)) {
+		m := NewModel(cfg, content, nil, nil)
+		send := func(keys ...string) {
+			for _, k := range keys {
+				msg := tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(k)}
+				switch k {
+				case "ctrl+f":
+					msg = tea.KeyMsg{Type: tea.KeyCtrlF}
+				case "enter":
+					msg = tea.KeyMsg{Type: tea.KeyEnter}
+				case "esc":
+					msg = tea.KeyMsg{Type: tea.KeyEsc}
+				}
+				result, _ := m.Update(msg)
+				m = result.(Model)
+			}
+		}
+		return &m, send
+	}
+
+	t.Run("confirms each match", func(t *testing.T) {
+		m, send := open(model.Config{FilePath: "app.env.age"})
+		send("ctrl+f", ".old.", "enter", ".new.", "enter")
+		if m.replace == nil || m.ta.Line() != 0 {
+			t.Fatalf("expected the first match on line 1, got panel %v, line %d", m.replace, m.ta.Line()+1)
+		}
+		send("y", "n")
+		if m.ta.Line() != 2 {
+			t.Errorf("expected the third match on line 3, got line %d", m.ta.Line()+1)
+		}
+		send("y")
+		want := "DB_HOST=db1.new.internal\nCACHE_HOST=cache.old.internal\nAPI_URL=https://api.new.internal\n"
+		if m.replace != nil || m.ta.Value() != want || !m.changed {
+			t.Errorf("got %q, want %q", m.ta.Value(), want)
+		}
+		if !contains(m.status, "Replaced 2, skipped 1") {
+			t.Errorf("unexpected status: %s", m.status)
+		}
+	})
+
+	t.Run("replaces all with a regex", func(t *testing.T) {
+		m, send := open(model.Config{FilePath: "app.env.age"})
+		send("ctrl+f", `/(\w+)\.old\.internal/`, "enter", "${1}.prod.example.com", "enter", "a")
+		want := "DB_HOST=db1.prod.example.com\nCACHE_HOST=cache.prod.example.com\nAPI_URL=https://api.prod.example.com\n"
+		if m.ta.Value() != want {
+			t.Errorf("got %q, want %q", m.ta.Value(), want)
+		}
+	})
+
+	t.Run("does not match its own replacements", func(t *testing.T) {
+		m, send := open(model.Config{FilePath: "app.env.age"})
+		send("ctrl+f", "old", "enter", "oldest", "enter", "a")
+		if got := strings.Count(m.ta.Value(), "oldest"); got != 3 {
+			t.Errorf("expected 3 replacements, got %d in %q", got, m.ta.Value())
+		}
+	})
+
+	t.Run("keeps anchors on the whole buffer when replacing all", func(t *testing.T) {
+		for _, tc := range []struct{ in, find, want string }{
+			{"aaa\nx", "/^a/", "baa\nx"},
+			{"abab ab", `/\bab/`, "bab b"},
+		} {
+			m, send := open(model.Config{FilePath: "app.env.age"})
+			m.ta.SetValue(tc.in)
+			send("ctrl+f", tc.find, "enter", "b", "enter", "a")
+			if m.ta.Value() != tc.want {
+				t.Errorf("%s on %q: got %q, want %q", tc.find, tc.in, m.ta.Value(), tc.want)
+			}
+		}
+	})
+
+	t.Run("refuses bad patterns and view-only buffers", func(t *testing.T) {
+		m, send := open(model.Config{FilePath: "app.env.age"})
+		send("ctrl+f", "/x*/", "enter", "y", "enter")
+		if m.err == nil || m.ta.Value() != content {
+			t.Errorf("expected an empty-match error, got %v", m.err)
+		}
+		send("esc")
+		m, send = open(model.Config{FilePath: "app.env.age", ViewOnly: true})
+		send("ctrl+f")
+		if m.replace != nil {
+			t.Error("expected no replace panel in view-only mode")
+		}
+	})
+}
+
+func TestHeadBadge(t *testing.T) {
+	cfg := model.Config{FilePath: "app.env.age"}
+
+	t.Run("flags a file already changed on disk", func(t *testing.T) {
+		m := NewModel(cfg, "A=2\n", nil, nil).WithHead("A=1\n")
+		if !contains(m.View(), headBadge) || !contains(m.status, "differs from git HEAD") {
+			t.Errorf("expected the HEAD badge and notice, got:\n%s", m.View())
+		}
+		result, _ := m.Update(tea.KeyMsg{Type: tea.KeyCtrlO})
+		m = result.(Model)
+		if !contains(m.status, "-A=\x1b[7m1\x1b[27m") || !contains(m.status, "+A=\x1b[7m2\x1b[27m") {
+			t.Errorf("expected a diff against HEAD, got: %s", m.status)
+		}
+	})
+
+	t.Run("follows edits back to the committed content", func(t *testing.T) {
+		for _, paranoid := range []bool{false, true} {
+			cfg := cfg
+			cfg.Paranoid = paranoid
+			m := NewModel(cfg, "A=1\n", nil, nil).WithHead("A=1\n")
+			if contains(m.View(), headBadge) {
+				t.Errorf("paranoid=%v: expected no badge for committed content", paranoid)
+			}
+			m.ta.SetValue("A=3\n")
+			if !contains(m.View(), headBadge) {
+				t.Errorf("paranoid=%v: expected the badge after an edit", paranoid)
+			}
+		}
+	})
+
+	t.Run("explains when there is no committed version", func(t *testing.T) {
+		m := NewModel(cfg, "A=1\n", nil, nil)
+		result, _ := m.Update(tea.KeyMsg{Type: tea.KeyCtrlO})
+		if m = result.(Model); !contains(m.status, "No committed version") {
+			t.Errorf("unexpected status: %s", m.status)
+		}
+	})
+}
+
+func TestPassphraseMode(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "new.env.age")
+	m := NewModel(model.Config{FilePath: path, Passphrase: true}, "", nil, nil)
+	m.ta.SetValue("KEY=value")
+	send := func(msgs ...tea.Msg) {
+		for _, msg := range msgs {
+			result, _ := m.Update(msg)
+			m = result.(Model)
+		}
+	}
+	typed := func(s string) tea.Msg { return tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(s)} }
+	enter := tea.KeyMsg{Type: tea.KeyEnter}
+
+	t.Run("asks for the passphrase twice and rejects a mismatch", func(t *testing.T) {
+		send(tea.KeyMsg{Type: tea.KeyCtrlS})
+		if m.passPrompt == nil {
+			t.Fatal("expected the passphrase prompt")
+		}
+		send(typed("correct horse"), enter, typed("correct hose"), enter)
+		if m.err == nil || !contains(m.err.Error(), "do not match") || m.passPrompt == nil {
+			t.Fatalf("expected a mismatch error and a fresh prompt, got %v", m.err)
+		}
+		if contains(m.View(), "correct") {
+			t.Error("expected the passphrase to be masked")
+		}
+	})
+
+	t.Run("saves to the confirmed passphrase", func(t *testing.T) {
+		send(typed("correct horse"), enter, typed("correct horse"), enter)
+		if !m.pendingConfirm {
+			t.Fatalf("expected the save confirmation, got status %q (err %v)", m.status, m.err)
+		}
+		send(tea.KeyMsg{Type: tea.KeyCtrlS})
+		id, _, err := agepkg.Passphrase("correct horse")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if plain, err := agepkg.DecryptToMemory(path, []age.Identity{id}); err != nil || plain != "KEY=value" {
+			t.Errorf("expected KEY=value under the passphrase, got %q (%v)", plain, err)
+		}
+	})
+}
+
+func TestSaveQueue(t *testing.T) {
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatalf("failed to generate identity: %v", err)
+	}
+	// A directory that disappears after opening makes the write fail after
+	// the preflight has passed.
+	failing := func(t *testing.T) (Model, string) {
+		dir := filepath.Join(t.TempDir(), "mount")
+		if err := os.Mkdir(dir, 0700); err != nil {
+			t.Fatal(err)
+		}
+		cfg := model.Config{FilePath: filepath.Join(dir, "app.env.age")}
+		m := NewModel(cfg, "A=1", []age.Identity{identity}, []age.Recipient{identity.Recipient()})
+		if err := os.Remove(dir); err != nil {
+			t.Fatal(err)
+		}
+		result, _ := m.Update(tea.KeyMsg{Type: tea.KeyCtrlS})
+		m = result.(Model)
+		if m.queue == nil || m.err == nil || !contains(m.status, "Ctrl+S: retry") {
+			t.Fatalf("expected the write to be queued, got status %q err %v", m.status, m.err)
+		}
+		return m, dir
+	}
+
+	t.Run("retries the kept ciphertext", func(t *testing.T) {
+		m, dir := failing(t)
+		if err := os.Mkdir(dir, 0700); err != nil {
+			t.Fatal(err)
+		}
+		result, _ := m.Update(tea.KeyMsg{Type: tea.KeyCtrlS})
+		m = result.(Model)
+		if m.queue != nil || m.err != nil || m.saves != 1 {
+			t.Fatalf("expected the retry to save, got status %q err %v", m.status, m.err)
+		}
+		plain, err := agepkg.DecryptToMemory(m.cfg.FilePath, []age.Identity{identity})
+		if err != nil || plain != "A=1" {
+			t.Errorf("expected the saved file to decrypt to the buffer, got %q, %v", plain, err)
+		}
+	})
+
+	t.Run("saves as a new file", func(t *testing.T) {
+		m, _ := failing(t)
+		result, _ := m.Update(tea.KeyMsg{Type: tea.KeyCtrlX})
+		m = result.(Model)
+		if m.pathInput == nil {
+			t.Fatal("expected the save-as prompt")
+		}
+		path := filepath.Join(t.TempDir(), "elsewhere.env.age")
+		result, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(path)})
+		m = result.(Model)
+		result, _ = m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+		m = result.(Model)
+		if m.queue != nil || m.cfg.FilePath != path {
+			t.Fatalf("expected a save to %s, got status %q err %v", path, m.status, m.err)
+		}
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("expected %s to be written: %v", path, err)
+		}
+	})
+
+	t.Run("drops the queue when the buffer changes", func(t *testing.T) {
+		m, _ := failing(t)
+		result, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("x")})
+		m = result.(Model)
+		if m.queue != nil {
+			t.Error("expected an edit to drop the stale ciphertext")
+		}
+	})
+}
+
+func TestEditorModel(t *testing.T) {
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatalf("failed to generate identity: %v", err)
+	}
+	ids, recips := []age.Identity{identity}, []age.Recipient{identity.Recipient()}
+	cfg := model.Config{FilePath: "vault://app.env"}
+	ctrlS := tea.KeyMsg{Type: tea.KeyCtrlS}
+
+	t.Run("hands the ciphertext to the save callback", func(t *testing.T) {
+		var got []byte
+		e := NewEditorModel(cfg, "A=1", ids, recips, OnSave(func(path string, cipher []byte) error {
+			if path != cfg.FilePath {
+				t.Errorf("expected path %s, got %s", cfg.FilePath, path)
+			}
+			got = cipher
+			return nil
+		}))
+		e, _ = e.Update(ctrlS)
+		plain, err := agepkg.Decrypt(got, ids)
+		if err != nil || plain != "A=1" {
+			t.Errorf("expected ciphertext of the buffer, got %q, %v", plain, err)
+		}
+	})
+
+	t.Run("queues the ciphertext when the callback fails", func(t *testing.T) {
+		fail := true
+		e := NewEditorModel(cfg, "A=1", ids, recips, OnSave(func(string, []byte) error {
+			if fail {
+				return fmt.Errorf("vault unavailable")
+			}
+			return nil
+		}))
+		e, _ = e.Update(ctrlS)
+		if e.m.queue == nil {
+			t.Fatal("expected the failed save to be queued")
+		}
+		fail = false
+		e, _ = e.Update(ctrlS)
+		if e.m.queue != nil || e.Summary().Saves != 1 {
+			t.Errorf("expected the retry to save, got %q", e.m.status)
+		}
+	})
+
+	t.Run("runs extra validators", func(t *testing.T) {
+		saved := false
+		e := NewEditorModel(cfg, "A=1", ids, recips,
+			WithValidators(func(path, content string) error {
+				if strings.Contains(content, "A=") {
+					return fmt.Errorf("A is reserved")
+				}
+				return nil
+			}),
+			OnSave(func(string, []byte) error { saved = true; return nil }))
+		e, _ = e.Update(ctrlS)
+		if saved || e.m.err == nil || !contains(e.m.err.Error(), "A is reserved") {
+			t.Errorf("expected the validator to block the save, got err %v", e.m.err)
+		}
+	})
+
+	t.Run("runs the after-save hook without failing the save", func(t *testing.T) {
+		var got []string
+		e := NewEditorModel(cfg, "A=1", ids, recips,
+			OnSave(func(string, []byte) error { return nil }),
+			AfterSave(func(path, plain string) error {
+				got = append(got, path+" "+plain)
+				return fmt.Errorf("derived SHA256SUMS: disk full")
+			}))
+		e, _ = e.Update(ctrlS)
+		if len(got) != 1 || got[0] != cfg.FilePath+" A=1" {
+			t.Errorf("expected one call with the saved plaintext, got %q", got)
+		}
+		if e.Summary().Saves != 1 || !contains(e.m.status, "Warning: derived SHA256SUMS: disk full") {
+			t.Errorf("expected a save with a warning, got %q", e.m.status)
+		}
+	})
+
+	t.Run("uses the host's key map", func(t *testing.T) {
+		keys := DefaultKeyMap()
+		keys.Save = key.NewBinding(key.WithKeys("f2"))
+		saves := 0
+		e := NewEditorModel(cfg, "A=1", ids, recips, WithKeyMap(keys),
+			OnSave(func(string, []byte) error { saves++; return nil }))
+		e, _ = e.Update(ctrlS)
+		e, _ = e.Update(tea.KeyMsg{Type: tea.KeyF2})
+		if saves != 1 {
+			t.Errorf("expected only F2 to save, got %d saves", saves)
+		}
+	})
+
+	t.Run("rebinds keys by action name", func(t *testing.T) {
+		keys := DefaultKeyMap()
+		if err := keys.Rebind(map[string]string{"save": "f2, ctrl+w"}); err != nil {
+			t.Fatal(err)
+		}
+		if !key.Matches(tea.KeyMsg{Type: tea.KeyCtrlW}, keys.Save) || key.Matches(ctrlS, keys.Save) {
+			t.Errorf("expected F2 and Ctrl+W to save, got %v", keys.Save.Keys())
+		}
+		if keyHint(keys.Save) != "F2" || keyHint(keys.Quit) != "Ctrl+Q" {
+			t.Errorf("unexpected hints %q, %q", keyHint(keys.Save), keyHint(keys.Quit))
+		}
+		before := keys
+		if err := keys.Rebind(map[string]string{"quit": "ctrl+x", "sav": "f3"}); err == nil {
+			t.Error("expected an error for an unknown action")
+		}
+		if keys.Quit.Keys()[0] != before.Quit.Keys()[0] {
+			t.Error("expected a failed rebind to change nothing")
+		}
+		m := NewModel(model.Config{FilePath: "a.env.age", KeyBindings: map[string]string{"diff": "f5"}}, "A=1", nil, nil)
+		if !contains(m.status, "F5: diff") {
+			t.Errorf("expected the status to name the new key, got %q", m.status)
+		}
+	})
+
+	t.Run("reports closing instead of quitting the program", func(t *testing.T) {
+		e := NewEditorModel(cfg, "A=1", ids, recips)
+		e, cmd := e.Update(tea.KeyMsg{Type: tea.KeyCtrlQ})
+		if cmd == nil {
+			t.Fatal("expected a command")
+		}
+		if msg, ok := cmd().(EditorClosedMsg); !ok || msg.Saved {
+			t.Errorf("expected EditorClosedMsg{Saved: false}, got %#v", msg)
+		}
+	})
+
+	t.Run("ignores keys while blurred and fits its size", func(t *testing.T) {
+		e := NewEditorModel(cfg, "", ids, recips, WithSize(60, 20))
+		// The textarea's own width leaves out the line numbers.
+		if e.m.ta.Height() != 16 || e.m.ta.Width() > 60 {
+			t.Errorf("expected a textarea within 60x16, got %dx%d", e.m.ta.Width(), e.m.ta.Height())
+		}
+		e.Blur()
+		e, _ = e.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("x")})
+		if e.Modified() {
+			t.Error("expected a blurred editor to ignore typing")
+		}
+		e.Focus()
+		e, _ = e.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("x")})
+		if !e.Modified() {
+			t.Error("expected a focused editor to take typing")
+		}
+	})
+}
+
+func TestTabs(t *testing.T) {
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatalf("failed to generate identity: %v", err)
+	}
+	ids, recips := []age.Identity{identity}, []age.Recipient{identity.Recipient()}
+	open := func() Tabs {
+		return NewTabs(DefaultKeyMap(),
+			NewEditorModel(model.Config{FilePath: "secrets/app.env.age"}, "A=1", ids, recips),
+			NewEditorModel(model.Config{FilePath: "infra/values.yaml.age"}, "a: 1", ids, recips))
+	}
+	send := func(tabs Tabs, msg tea.Msg) (Tabs, tea.Cmd) {
+		res, cmd := tabs.Update(msg)
+		return res.(Tabs), cmd
+	}
+	typeX := tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("x")}
+	altN := tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("n"), Alt: true}
+
+	t.Run("switches tabs and keeps each buffer's dirty state", func(t *testing.T) {
+		tabs := open()
+		tabs, _ = send(tabs, typeX)
+		tabs, _ = send(tabs, altN)
+		if tabs.active != 1 {
+			t.Fatalf("expected the second tab, got %d", tabs.active)
+		}
+		if !tabs.tabs[0].ed.Modified() || tabs.tabs[1].ed.Modified() {
+			t.Error("expected only the first tab to be modified")
+		}
+		view := tabs.View()
+		if !contains(view, " 1 secrets/app.env.age* ") || !contains(view, "[2 infra/values.yaml.age]") {
+			t.Errorf("unexpected tab bar: %q", strings.SplitN(view, "\n", 2)[0])
+		}
+	})
+
+	t.Run("routes tagged messages to their tab", func(t *testing.T) {
+		tabs := open()
+		tabs.tabs[1].ed.m.toastSeq, tabs.tabs[1].ed.m.toast = 1, "Saved"
+		tabs, _ = send(tabs, tabMsg{id: 1, msg: toastExpiredMsg{seq: 1}})
+		if tabs.tabs[1].ed.m.toast != "" {
+			t.Error("expected the second tab's toast to expire")
+		}
+		cmd := tagged(0, tea.Batch(func() tea.Msg { return snapshotTick{} }, func() tea.Msg { return snapshotTick{} }))
+		batch, ok := cmd().(tea.BatchMsg)
+		if !ok || len(batch) != 2 {
+			t.Fatalf("expected a batch of two, got %#v", batch)
+		}
+		if msg, ok := batch[0]().(tabMsg); !ok || msg.id != 0 {
+			t.Errorf("expected a tagged message, got %#v", msg)
+		}
+	})
+
+	t.Run("closes a tab on quit and ends with the last", func(t *testing.T) {
+		tabs := open()
+		_, cmd := send(tabs, tea.KeyMsg{Type: tea.KeyCtrlQ})
+		tabs, _ = send(tabs, cmd())
+		if len(tabs.tabs) != 1 || tabs.tabs[0].name != "infra/values.yaml.age" || tabs.active != 0 {
+			t.Fatalf("expected the first tab closed, got %+v", tabs.tabs)
+		}
+		_, cmd = send(tabs, tea.KeyMsg{Type: tea.KeyCtrlQ})
+		tabs, cmd = send(tabs, cmd())
+		if _, ok := cmd().(tea.QuitMsg); !ok || tabs.View() != "" {
+			t.Error("expected closing the last tab to quit")
+		}
+		if got := tabs.Summaries(); len(got) != 2 || got[0].File != "secrets/app.env.age" {
+			t.Errorf("unexpected summaries: %+v", got)
+		}
+	})
+}
+
+func TestControl(t *testing.T) {
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatalf("failed to generate identity: %v", err)
+	}
+	open := func(t *testing.T, name, plain string) Model {
+		cfg := model.Config{FilePath: filepath.Join(t.TempDir(), name)}
+		return NewModel(cfg, plain, []age.Identity{identity}, []age.Recipient{identity.Recipient()})
+	}
+	call := func(m Model, method, params string) (Model, controlReply) {
+		reply := make(chan controlReply, 1)
+		result, _ := m.Update(controlMsg{method: method, params: json.RawMessage(params), reply: reply})
+		return result.(Model), <-reply
+	}
+
+	t.Run("inserts keys and reports names only", func(t *testing.T) {
+		m := open(t, "app.env.age", "A=1\nB=2\n")
+		m, r := call(m, "insert", `{"key":"B","value":"two words"}`)
+		if r.err != nil {
+			t.Fatalf("insert failed: %v", r.err)
+		}
+		m, r = call(m, "insert", `{"key":"C","value":"3"}`)
+		if r.err != nil {
+			t.Fatalf("insert failed: %v", r.err)
+		}
+		if got := m.ta.Value(); got != "A=1\nB=\"two words\"\nC=3\n" {
+			t.Errorf("unexpected buffer %q", got)
+		}
+		_, r = call(m, "status", "")
+		st := r.result.(Status)
+		if !st.Modified || !reflect.DeepEqual(st.ChangedKeys, []string{"B", "C"}) {
+			t.Errorf("unexpected status %+v", st)
+		}
+		b, _ := json.Marshal(st)
+		if contains(string(b), "two words") {
+			t.Errorf("status leaked a value: %s", b)
+		}
+	})
+
+	t.Run("saves after confirmation", func(t *testing.T) {
+		m := open(t, "app.env.age", "A=1")
+		m, _ = call(m, "insert", `{"key":"A","value":"2"}`)
+		m, r := call(m, "save", "")
+		if r.err != nil || !r.result.(Status).PendingConfirm {
+			t.Fatalf("expected the first save to ask for confirmation, got %+v, %v", r.result, r.err)
+		}
+		m, r = call(m, "save", "")
+		if r.err != nil || r.result.(Status).Saves != 1 {
+			t.Fatalf("expected the second save to write, got %+v, %v", r.result, r.err)
+		}
+		plain, err := agepkg.DecryptToMemory(m.cfg.FilePath, []age.Identity{identity})
+		if err != nil || plain != "A=2" {
+			t.Errorf("expected the saved file to hold the insert, got %q, %v", plain, err)
+		}
+	})
+
+	t.Run("reports a failed save as an error", func(t *testing.T) {
+		m := open(t, "app.json.age", `{"a":1}`)
+		m.ta.SetValue(`{"a":`)
+		m.changed = true
+		if _, r := call(m, "save", ""); r.err == nil || r.result != nil {
+			t.Errorf("expected a validation error, got %+v", r.result)
+		}
+	})
+
+	t.Run("serves a plain diff", func(t *testing.T) {
+		m := open(t, "app.env.age", "A=1\n")
+		m, _ = call(m, "insert", `{"key":"A","value":"2"}`)
+		_, r := call(m, "diff", "")
+		d := r.result.(map[string]string)["diff"]
+		if !contains(d, "-A=1\n+A=2\n") || contains(d, "\x1b") {
+			t.Errorf("unexpected diff %q", d)
+		}
+
+		m.cfg.Paranoid = true
+		if _, r := call(m, "diff", ""); r.err == nil {
+			t.Error("expected paranoid mode to refuse the diff")
+		}
+	})
+
+	t.Run("refuses what it cannot do", func(t *testing.T) {
+		m := open(t, "app.json.age", `{"a":1}`)
+		if _, r := call(m, "insert", `{"key":"B","value":"2"}`); r.err == nil {
+			t.Error("expected insert into JSON to fail")
+		}
+		m = open(t, "app.env.age", "A=1")
+		var e *control.Error
+		if _, r := call(m, "insert", `{"key":"BAD KEY","value":"2"}`); !errors.As(r.err, &e) || e.Code != control.CodeInvalidParams {
+			t.Errorf("expected an invalid params error, got %v", r.err)
+		}
+		if _, r := call(m, "type", ""); !errors.As(r.err, &e) || e.Code != control.CodeMethodNotFound {
+			t.Errorf("expected method not found, got %v", r.err)
+		}
+	})
+}
+
+func TestWrap(t *testing.T) {
+	t.Run("wraps long lines and keeps newlines", func(t *testing.T) {
+		got := wrap("abcdefghijklmnop\nxy", 10)
+		if len(got) != 3 || got[0] != "abcdefghij" || got[1] != "klmnop" || got[2] != "xy" {
+			t.Errorf("unexpected wrap: %q", got)
+		}
+	})
+}
+
+func TestSummary(t *testing.T) {
+	t.Run("counts saves and changed keys without values", func(t *testing.T) {
+		identity, err := age.GenerateX25519Identity()
+		if err != nil {
+			t.Fatalf("failed to generate identity: %v", err)
+		}
+		cfg := model.Config{FilePath: filepath.Join(t.TempDir(), "app.age")}
+		m := NewModel(cfg, "A=1\nB=2", []age.Identity{identity}, []age.Recipient{identity.Recipient()})
+		m.ta.SetValue("A=1\nB=3\nC=4")
+
+		for i := 0; i < 2; i++ { // double Ctrl+S to confirm
+			result, _ := m.Update(tea.KeyMsg{Type: tea.KeyCtrlS})
+			m = result.(Model)
+		}
+
+		sum := m.Summary()
+		if sum.Saves != 1 {
+			t.Errorf("expected 1 save, got %d", sum.Saves)
+		}
+		if sum.KeysChanged != 2 {
+			t.Errorf("expected 2 changed keys, got %d", sum.KeysChanged)
+		}
+		if sum.Recipients != 1 {
+			t.Errorf("expected 1 recipient, got %d", sum.Recipients)
+		}
+		if contains(sum.String(), "=3") {
+			t.Error("expected summary to contain no values")
+		}
+	})
+
+	t.Run("reports unsaved sessions", func(t *testing.T) {
+		m := NewModel(model.Config{FilePath: "test.age"}, "A=1", nil, nil)
+
+		if !contains(m.Summary().String(), "saved:        no") {
+			t.Errorf("expected unsaved session, got: %s", m.Summary().String())
+		}
+	})
+}
+
+func TestChangedKeys(t *testing.T) {
+	t.Run("reports added, removed, and modified keys", func(t *testing.T) {
+		keys := changedKeys("A=1\nB=2\nC=3", "A=1\nB=20\nD=4", "env")
+		got := map[string]bool{}
+		for _, k := range keys {
+			got[k] = true
+		}
+		if len(got) != 3 || !got["B"] || !got["C"] || !got["D"] {
+			t.Errorf("expected B, C, D, got %v", keys)
+		}
+	})
+
+	t.Run("reads export, quotes, and multiline values like every other command", func(t *testing.T) {
+		a := "export A=1\nB=\"x # y\"\nC=\"line one\nline two\"\n"
+		b := "A=1\nB='x # y'\nC=\"line one\nline 2\"\n"
+		if keys := changedKeys(a, b, "env"); len(keys) != 1 || keys[0] != "C" {
+			t.Errorf("expected only C, got %v", keys)
+		}
+	})
+
+	t.Run("compares dotted paths in structured formats", func(t *testing.T) {
+		for format, tc := range map[string][2]string{
+			"json": {`{"db": {"host": "a", "password": "x"}, "port": 1}`, `{"db": {"host": "a", "password": "y"}, "port": 1, "tls": true}`},
+			"yaml": {"db:\n  host: a\n  password: x\nport: 1\n", "db:\n  host: a\n  password: y\nport: 1\ntls: true\n"},
+			"toml": {"port = 1\n[db]\nhost = \"a\"\npassword = \"x\"\n", "port = 1\ntls = true\n[db]\nhost = \"a\"\npassword = \"y\"\n"},
+		} {
+			keys := changedKeys(tc[0], tc[1], format)
+			slices.Sort(keys)
+			if !slices.Equal(keys, []string{"db.password", "tls"}) {
+				t.Errorf("%s: expected db.password and tls, got %v", format, keys)
+			}
+		}
+	})
+}
+
+type fakeClipboard struct {
+	text    string
+	cleared bool
+}
+
+func (f *fakeClipboard) Write(text string) error { f.text = text; return nil }
+func (f *fakeClipboard) Clear() error            { f.text, f.cleared = "", true; return nil }
+
+func TestClipboardCopy(t *testing.T) {
+	t.Run("copies the value under the cursor and schedules a clear", func(t *testing.T) {
+		cfg := model.Config{FilePath: "test.env.age", ClipboardClear: time.Second}
+		m := NewModel(cfg, "DB_PASSWORD=\"hunter2\"", nil, nil)
+		clip := &fakeClipboard{}
+		m.clip = clip
+
+		result, cmd := m.Update(tea.KeyMsg{Type: tea.KeyCtrlY})
+		m = result.(Model)
+
+		if clip.text != "hunter2" {
+			t.Errorf("expected unquoted value to be copied, got %q", clip.text)
+		}
+		if cmd == nil {
+			t.Error("expected a clear timer to be scheduled")
+		}
+	})
+
+	t.Run("clears the clipboard when the latest timer fires", func(t *testing.T) {
+		cfg := model.Config{FilePath: "test.env.age", ClipboardClear: time.Second}
+		m := NewModel(cfg, "KEY=value", nil, nil)
+		clip := &fakeClipboard{}
+		m.clip = clip
+
+		result, _ := m.Update(tea.KeyMsg{Type: tea.KeyCtrlY})
+		m = result.(Model)
+		result, _ = m.Update(clipboardClearMsg{seq: m.clipSeq})
+		m = result.(Model)
+
+		if !clip.cleared {
+			t.Error("expected clipboard to be cleared")
+		}
+	})
+
+	t.Run("ignores stale clear timers", func(t *testing.T) {
+		cfg := model.Config{FilePath: "test.env.age", ClipboardClear: time.Second}
+		m := NewModel(cfg, "KEY=value", nil, nil)
+		clip := &fakeClipboard{}
+		m.clip = clip
+
+		result, _ := m.Update(tea.KeyMsg{Type: tea.KeyCtrlY})
+		m = result.(Model)
+		result, _ = m.Update(clipboardClearMsg{seq: m.clipSeq - 1})
+		m = result.(Model)
+
+		if clip.cleared {
+			t.Error("expected stale timer to leave the clipboard alone")
+		}
+	})
+}
+
+func TestLineValue(t *testing.T) {
+	t.Run("extracts env values and skips comments", func(t *testing.T) {
+		cases := map[string]string{
+			"KEY=value":       "value",
+			"KEY='quoted'":    "quoted",
+			"  # comment":     "",
+			"plain text line": "plain text line",
+		}
+		for in, want := range cases {
+			if got := lineValue(in); got != want {
+				t.Errorf("lineValue(%q) = %q, want %q", in, got, want)
+			}
+		}
+	})
+}
+
+func TestView(t *testing.T) {
+	t.Run("renders view with status and textarea", func(t *testing.T) {
+		cfg := model.Config{FilePath: "test.age"}
+		m := NewModel(cfg, "content", nil, nil)
+
+		view := m.View()
+
+		if view == "" {
+			t.Error("expected non-empty view")
+		}
+		if len(view) < 10 {
+			t.Error("expected view to contain substantial content")
+		}
+	})
+
+	t.Run("renders a blank final frame when quitting", func(t *testing.T) {
+		cfg := model.Config{FilePath: "test.age"}
+		m := NewModel(cfg, "SECRET=value", nil, nil)
+
+		result, _ := m.Update(tea.KeyMsg{Type: tea.KeyCtrlQ})
+		m = result.(Model)
+
+		if m.View() != "" {
+			t.Error("expected no plaintext in the final frame")
+		}
+	})
+
+	t.Run("includes error in view when present", func(t *testing.T) {
+		cfg := model.Config{FilePath: "test.age"}
+		m := NewModel(cfg, "content", nil, nil)
+		m.err = fmt.Errorf("test error")
+
+		view := m.View()
+
+		if !contains(view, "[ERROR]") {
+			t.Error("expected view to contain [ERROR] when error is present")
+		}
+		if !contains(view, "test error") {
+			t.Error("expected view to contain error message")
+		}
+	})
+}
+
+func TestHighlightView(t *testing.T) {
+	t.Run("colors the unstyled text after the gutter", func(t *testing.T) {
+		view := "\x1b[37m 1 \x1b[0mDB_HOST=db1   \n\x1b[37m 2 \x1b[0m\x1b[40mAPI=x\x1b[0m"
+		got := strings.Split(highlightView(view, highlight.Themes["default"], "env"), "\n")
+		if want := "\x1b[37m 1 \x1b[0m" + highlight.Line(highlight.Themes["default"], "env", "DB_HOST=db1") + "   "; got[0] != want {
+			t.Errorf("got %q, want %q", got[0], want)
+		}
+		if got[1] != "\x1b[37m 2 \x1b[0m\x1b[40mAPI=x\x1b[0m" {
+			t.Errorf("expected the styled cursor line unchanged, got %q", got[1])
+		}
+	})
+
+	t.Run("leaves colorless views and unknown formats alone", func(t *testing.T) {
+		if got := highlightView(" 1 DB_HOST=db1", highlight.Themes["default"], "env"); got != " 1 DB_HOST=db1" {
+			t.Errorf("got %q", got)
+		}
+		view := "\x1b[37m 1 \x1b[0mDB_HOST=db1"
+		if got := highlightView(view, highlight.Themes["default"], ""); got != view {
+			t.Errorf("got %q", got)
+		}
+	})
+}
+
+func TestWipeTerminal(t *testing.T) {
+	t.Run("clears alt screen and resets title", func(t *testing.T) {
+		var buf strings.Builder
+		WipeTerminal(&buf)
+
+		if !contains(buf.String(), "\x1b[2J") {
+			t.Error("expected screen clear sequence")
+		}
+		if !contains(buf.String(), "\x1b]0;\x07") {
+			t.Error("expected title reset sequence")
+		}
+	})
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	t.Run("generates diff for different strings", func(t *testing.T) {
+		a := "line1\nline2\nline3"
+		b := "line1\nmodified\nline3"
+
+		diff := unifiedDiff(a, b, "test.txt (original)", "test.txt (edited)", diff.Myers)
+
+		if diff == "" {
+			t.Error("expected non-empty diff")
+		}
+		if !contains(diff, "test.txt") {
+			t.Error("expected diff to contain filename")
+		}
+		if !contains(diff, "-\x1b[7mline2\x1b[27m") || !contains(diff, "+\x1b[7mmodified\x1b[27m") {
+			t.Errorf("expected the changed word highlighted, got: %q", diff)
+		}
+	})
+
+	t.Run("generates empty diff for identical strings", func(t *testing.T) {
+		a := "same content"
+		b := "same content"
+
+		diff := unifiedDiff(a, b, "test.txt (original)", "test.txt (edited)", diff.Myers)
+
+		if diff != "" {
+			t.Errorf("expected empty diff for identical strings, got: %s", diff)
+		}
+	})
+}
+
+func TestTruncate(t *testing.T) {
+	t.Run("truncates string longer than limit", func(t *testing.T) {
+		s := "this is a very long string that should be truncated"
+		truncated := truncate(s, 10)
+
+		if len(truncated) > 30 {
+			t.Errorf("expected truncated string to be around 10 chars + marker, got %d", len(truncated))
+		}
+		if !contains(truncated, "truncated") {
+			t.Error("expected truncation marker in output")
+		}
+	})
+
+	t.Run("does not truncate string shorter than limit", func(t *testing.T) {
+		s := "short"
+		truncated := truncate(s, 100)
+
+		if truncated != s {
+			t.Errorf("expected string to remain unchanged, got %q", truncated)
+		}
+	})
+
+	t.Run("does not truncate string equal to limit", func(t *testing.T) {
+		s := "exactly10c"
+		truncated := truncate(s, 10)
+
+		if truncated != s {
+			t.Errorf("expected string to remain unchanged, got %q", truncated)
+		}
+	})
+
+	t.Run("closes highlighting cut mid-word", func(t *testing.T) {
+		s := "+A=\x1b[7mlongvalue\x1b[27m"
+		for n := 4; n < len(s); n++ {
+			got := truncate(s, n)
+			if strings.Count(got, "\x1b[7m") != strings.Count(got, "\x1b[27m") {
+				t.Errorf("truncate(%d) left reverse video open: %q", n, got)
+			}
+		}
+	})
+}
+
+// Helper function to check if a string contains a substring
+func contains(s, substr string) bool {
+	return len(s) >= len(substr) && (s == substr || len(s) > len(substr) && indexOf(s, substr) >= 0)
+}
+
+func indexOf(s, substr string) int {
+	for i := 0; i <= len(s)-len(substr); i++ {
+		if s[i:i+len(substr)] == substr {
+			return i
+		}
+	}
+	return -1
+}
+
+func TestSaveValues(t *testing.T) {
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids, recips := []age.Identity{identity}, []age.Recipient{identity.Recipient()}
+	path := filepath.Join(t.TempDir(), "app.yaml")
+	enc, err := valuecrypt.Encrypt("user: admin\npassword: hunter2\n", "yaml", recips, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(enc), 0600); err != nil {
+		t.Fatal(err)
+	}
+	plain, key, err := valuecrypt.Decrypt(enc, "yaml", ids)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := NewModel(model.Config{FilePath: path, Values: true}, plain, ids, recips).WithValueKey(key)
+	if !contains(m.status, "yaml, values encrypted") {
+		t.Errorf("expected the status to name values mode, got: %s", m.status)
+	}
+	m.ta.SetValue("user: admin\npassword: correct-horse\n")
+	for range 2 { // save, then confirm
+		result, _ := m.Update(tea.KeyMsg{Type: tea.KeyCtrlS})
+		m = result.(Model)
+	}
+	if m.saves != 1 || m.err != nil {
+		t.Fatalf("expected a save, got status %q err %v", m.status, m.err)
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved := string(b)
+	if first := strings.SplitN(enc, "\n", 2)[0]; !strings.HasPrefix(saved, first+"\n") {
+		t.Errorf("unchanged user value was re-encrypted:\n%s", saved)
+	}
+	if strings.Contains(saved, "correct-horse") {
+		t.Errorf("new value written in plaintext:\n%s", saved)
+	}
+	if got, _, err := valuecrypt.Decrypt(saved, "yaml", ids); err != nil || got != "user: admin\npassword: correct-horse\n" {
+		t.Errorf("got %q, %v", got, err)
+	}
+}
+
+func TestLint(t *testing.T) {
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "app.env.age")
+	m := NewModel(model.Config{FilePath: path, Lint: true}, "A=1\n", []age.Identity{identity}, []age.Recipient{identity.Recipient()})
+	m.ta.SetValue("HOST=db\nDB_PASSWORD=changeme\n")
+	press := func(k tea.KeyMsg) {
+		t.Helper()
+		result, _ := m.Update(k)
+		m = result.(Model)
+	}
+
+	press(tea.KeyMsg{Type: tea.KeyCtrlS})
+	if m.lintPanel == nil || len(m.lintPanel.findings) != 1 || !contains(m.status, "not saved yet") {
+		t.Fatalf("expected the save to stop at the findings, got status %q", m.status)
+	}
+	if v := m.View(); !contains(v, "line 2: DB_PASSWORD: placeholder") || contains(v, "changeme\n>") {
+		t.Errorf("expected the finding listed, got:\n%s", v)
+	}
+	press(tea.KeyMsg{Type: tea.KeyEnter})
+	if m.lintPanel != nil || m.ta.Line() != 1 {
+		t.Errorf("expected Enter to jump to line 2, at line %d", m.ta.Line()+1)
+	}
+
+	// The same buffer saves on the next Ctrl+S, with a warning.
+	press(tea.KeyMsg{Type: tea.KeyCtrlS})
+	if m.lintPanel != nil || !contains(m.status, "Lint: 1 finding(s)") || !m.pendingConfirm {
+		t.Fatalf("expected the save to go on to the confirmation, got status %q", m.status)
+	}
+	press(tea.KeyMsg{Type: tea.KeyCtrlS})
+	if m.saves != 1 {
+		t.Fatalf("expected a save, got status %q err %v", m.status, m.err)
+	}
+
+	t.Run("Alt+L lints on demand", func(t *testing.T) {
+		m := NewModel(model.Config{FilePath: "app.env.age"}, "DB_PASSWORD=correct-horse-battery\n", nil, nil)
+		result, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("l"), Alt: true})
+		m = result.(Model)
+		if m.lintPanel != nil || m.status != "Lint: nothing suspicious found." {
+			t.Errorf("got status %q", m.status)
+		}
+	})
 }
@@ -0,0 +1,69 @@
+package tui
+
+import (
+	"fmt"
+	"os"
+
+	agepkg "github.com/andreweick/agepad/age"
+	"github.com/andreweick/agepad/validator"
+	"github.com/andreweick/agepad/valuecrypt"
+)
+
+// WithValueKey records the data key of a value-encrypted file (cfg.Values),
+// so saves keep the ciphertext of values that did not change and a diff of
+// the file shows only the edited keys. Reloading the recipients drops it,
+// and the next save wraps a fresh key to them.
+func (m Model) WithValueKey(k *valuecrypt.Key) Model {
+	m.valueKey = k
+	return m
+}
+
+// encryptUnit encrypts one file of a save: as an age file, or with only
+// its values encrypted in cfg.Values mode. keyBlock is the age file whose
+// header lists the recipients, the ciphertext itself except in values mode.
+func (m Model) encryptUnit(u saveUnit) (cipher, keyBlock []byte, err error) {
+	if !m.cfg.Values {
+		cipher, err = agepkg.EncryptToMemory([]byte(u.content), u.recips, u.armor)
+		return cipher, cipher, err
+	}
+	format := validator.Format(u.path, u.content, m.cfg.TypeRules)
+	enc, err := valuecrypt.Encrypt(u.content, format, u.recips, m.valueKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	keyBlock, err = valuecrypt.KeyBlock(enc, format)
+	return []byte(enc), keyBlock, err
+}
+
+// decryptUnit is the preflight decrypt of what encryptUnit returned. In
+// values mode the key it yields is kept for the next save.
+func (m *Model) decryptUnit(u saveUnit, cipher []byte) error {
+	if !m.cfg.Values {
+		_, err := agepkg.Decrypt(cipher, m.identities)
+		return err
+	}
+	format := validator.Format(u.path, u.content, m.cfg.TypeRules)
+	_, k, err := valuecrypt.Decrypt(string(cipher), format, m.identities)
+	if err == nil {
+		m.valueKey = k
+	}
+	return err
+}
+
+// inspectHeader reads the age header of the file at path, or of its data
+// key block in values mode.
+func (m Model) inspectHeader(path string) (agepkg.Header, error) {
+	if !m.cfg.Values {
+		return agepkg.InspectHeader(path)
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return agepkg.Header{}, fmt.Errorf("open ciphertext: %w", err)
+	}
+	format := validator.Format(path, "", m.cfg.TypeRules)
+	block, err := valuecrypt.KeyBlock(string(b), format)
+	if err != nil {
+		return agepkg.Header{}, err
+	}
+	return agepkg.InspectHeaderBytes(block)
+}
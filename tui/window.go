@@ -0,0 +1,241 @@
+package tui
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/andreweick/agepad/sealed"
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textarea"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// In paranoid mode the document lives in m.doc, sealed line by line, and
+// the textarea holds only a window of it: the lines on screen and a
+// screenful either side. The window stands in for doc lines
+// [winStart, winStart+winLen); edits happen there, and when the cursor
+// nears an edge the window is sealed back and the next one opened.
+// Whole-document work (saving, diffs, validation, search) opens the rest
+// for as long as it runs.
+
+// windowScreens is the window size in screen heights; the cursor is kept
+// at least one screen from either edge.
+const windowScreens = 3
+
+// text returns the whole buffer. In paranoid mode it opens the sealed
+// lines around the window; they were sealed by this process, so failing
+// to open one means its memory was corrupted, and it panics rather than
+// save or show a truncated document.
+func (m Model) text() string {
+	if m.doc == nil {
+		return m.ta.Value()
+	}
+	above, err := m.doc.Lines(0, m.winStart)
+	if err != nil {
+		panic(fmt.Sprintf("paranoid buffer: %v", err))
+	}
+	below, err := m.doc.Lines(m.winStart+m.winLen, m.doc.Len())
+	if err != nil {
+		panic(fmt.Sprintf("paranoid buffer: %v", err))
+	}
+	return strings.Join(slices.Concat(above, []string{m.ta.Value()}, below), "\n")
+}
+
+// setText replaces the whole buffer and puts the cursor at its start.
+func (m *Model) setText(s string) {
+	if m.doc == nil {
+		m.ta.SetValue(s)
+		return
+	}
+	if err := m.doc.Seal(s); err != nil {
+		m.err = err
+		return
+	}
+	m.openWindow(0, 0, true)
+}
+
+// line returns the cursor's 0-based line in the whole buffer.
+func (m Model) line() int {
+	return m.winStart + m.ta.Line()
+}
+
+// lineCount returns the number of lines in the whole buffer.
+func (m Model) lineCount() int {
+	if m.doc == nil {
+		return m.ta.LineCount()
+	}
+	return m.doc.Len() - m.winLen + m.ta.LineCount()
+}
+
+// windowSize returns how many lines a window holds and how close to its
+// edge the cursor may come.
+func (m Model) windowSize() (size, margin int) {
+	h := max(m.ta.Height(), 1)
+	return windowScreens * h, h
+}
+
+// flushWindow seals the window back into the document.
+func (m *Model) flushWindow() error {
+	lines := strings.Split(m.ta.Value(), "\n")
+	if err := m.doc.Splice(m.winStart, m.winStart+m.winLen, lines); err != nil {
+		return err
+	}
+	m.winLen = len(lines)
+	return nil
+}
+
+// openWindow fills the textarea with the lines around line, which must
+// not be held in the window (the document is flushed), and puts the
+// cursor at col on it. down says the cursor came from above: the
+// textarea scrolls to it from the top, so it lands at the bottom of the
+// screen, and otherwise from the end, so it lands at the top, as it
+// would have without the window.
+func (m *Model) openWindow(line, col int, down bool) {
+	size, _ := m.windowSize()
+	start := min(max(line-size/2, 0), max(m.doc.Len()-size, 0))
+	lines, err := m.doc.Lines(start, start+size)
+	if err != nil {
+		m.err = fmt.Errorf("paranoid buffer: %w", err)
+		return
+	}
+	zeroTextarea(&m.ta)
+	m.ta.SetValue(strings.Join(lines, "\n")) // scrolled to the top, cursor at the end
+	m.winStart, m.winLen = start, len(lines)
+	if !down {
+		m.scrollToCursor()
+	}
+	for m.ta.Line() > line-start {
+		m.ta.CursorUp()
+	}
+	for m.ta.Line() < line-start {
+		m.ta.CursorDown()
+	}
+	m.ta.SetCursor(col)
+	m.scrollToCursor()
+}
+
+// scrollToCursor scrolls the textarea as little as it takes to show the
+// cursor, which it otherwise only does while handling a message. It
+// renders first: the textarea bounds scrolling by the text it last
+// rendered, which is the old window's.
+func (m *Model) scrollToCursor() {
+	focused := m.ta.Focused()
+	if !focused {
+		m.ta.Focus()
+	}
+	_ = m.ta.View()
+	m.ta, _ = m.ta.Update(nil)
+	if !focused {
+		m.ta.Blur()
+	}
+}
+
+// moveWindow seals the window and opens the one around line.
+func (m *Model) moveWindow(line, col int, down bool) {
+	if err := m.flushWindow(); err != nil {
+		m.err = fmt.Errorf("paranoid buffer: %w", err)
+		return
+	}
+	m.openWindow(line, col, down)
+}
+
+// keepWindow moves the window when the cursor is within a screen of an
+// edge that has more of the document beyond it, or when a paste has left
+// far more than a window in the textarea.
+func (m *Model) keepWindow() {
+	if m.doc == nil {
+		return
+	}
+	size, margin := m.windowSize()
+	row, n := m.ta.Line(), m.ta.LineCount()
+	up := row < margin && m.winStart > 0
+	down := row >= n-margin && m.winStart+m.winLen < m.doc.Len()
+	if !up && !down && n <= 2*size {
+		return
+	}
+	info := m.ta.LineInfo()
+	m.moveWindow(m.winStart+row, info.StartColumn+info.ColumnOffset, !up)
+}
+
+// updateTextarea passes msg to the textarea. In paranoid mode the keys
+// for the start and end of the input go to those of the whole buffer,
+// not of the window.
+func (m *Model) updateTextarea(msg tea.Msg) tea.Cmd {
+	if k, ok := msg.(tea.KeyMsg); ok && m.doc != nil {
+		switch {
+		case key.Matches(k, m.ta.KeyMap.InputBegin):
+			m.gotoLine(1)
+			return nil
+		case key.Matches(k, m.ta.KeyMap.InputEnd):
+			m.gotoLine(m.lineCount())
+			m.ta.CursorEnd()
+			return nil
+		}
+	}
+	var cmd tea.Cmd
+	m.ta, cmd = m.ta.Update(msg)
+	return cmd
+}
+
+// sameAs reports whether the buffer equals the text sealed in b, opening
+// one line of each at a time.
+func (m Model) sameAs(b *sealed.Buffer) (bool, error) {
+	if m.doc == nil {
+		return b.Equal(m.ta.Value())
+	}
+	win := strings.Split(m.ta.Value(), "\n")
+	n := m.doc.Len() - m.winLen + len(win)
+	if b.Len() != n {
+		return false, nil
+	}
+	for i := range n {
+		var line string
+		switch {
+		case i >= m.winStart && i < m.winStart+len(win):
+			line = win[i-m.winStart]
+		default:
+			j := i
+			if i >= m.winStart {
+				j = i - len(win) + m.winLen
+			}
+			l, err := m.doc.Lines(j, j+1)
+			if err != nil {
+				return false, err
+			}
+			line = l[0]
+		}
+		other, err := b.Lines(i, i+1)
+		if err != nil {
+			return false, err
+		}
+		if other[0] != line {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// lineNumber matches the textarea's line number after the prompt, with
+// any styling around them.
+var lineNumber = regexp.MustCompile(`^((?:\x1b\[[0-9;]*m)*` + regexp.QuoteMeta(textarea.New().Prompt) + `(?:\x1b\[[0-9;]*m)*)( *)(\d+) `)
+
+// renumber shifts the window's line numbers in view to the document's.
+func (m Model) renumber(view string) string {
+	if m.doc == nil || m.winStart == 0 || !m.ta.ShowLineNumbers {
+		return view
+	}
+	rows := strings.Split(view, "\n")
+	for i, row := range rows {
+		loc := lineNumber.FindStringSubmatchIndex(row)
+		if loc == nil {
+			continue
+		}
+		n, _ := strconv.Atoi(row[loc[6]:loc[7]])
+		width := loc[7] - loc[4]
+		rows[i] = row[:loc[4]] + fmt.Sprintf("%*d", width, n+m.winStart) + row[loc[7]:]
+	}
+	return strings.Join(rows, "\n")
+}
@@ -0,0 +1,463 @@
+package validator
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/andreweick/agepad/model"
+	"github.com/andreweick/agepad/outline"
+	"gopkg.in/yaml.v3"
+)
+
+// SchemaFile is the JSON Schema a JSON or YAML file is checked against
+// when it sits in the same directory and no --schema is given.
+const SchemaFile = ".agepad-schema.json"
+
+// Schema is a parsed JSON Schema. It supports the keywords that describe
+// the shape of a secrets file: type, properties, required,
+// additionalProperties, items, enum, const, pattern, minLength,
+// maxLength, minimum, maximum, minItems, and maxItems. Annotations
+// (title, description, $schema, ...) are ignored; any other keyword is
+// refused when the schema is loaded rather than silently not enforced.
+type Schema struct {
+	never bool // the schema false: no value matches
+
+	types                []string
+	properties           map[string]*Schema
+	required             []string
+	additionalProperties *Schema // nil allows any extra key
+	items                *Schema
+	enum                 []any
+	pattern              *regexp.Regexp
+	minLength, maxLength int // -1 when unset
+	minItems, maxItems   int // -1 when unset
+	minimum, maximum     *float64
+}
+
+// annotations are keywords that do not constrain a value.
+var annotations = []string{
+	"$schema", "$id", "$comment", "title", "description", "default",
+	"examples", "format", "deprecated", "readOnly", "writeOnly",
+}
+
+var schemaTypes = []string{"null", "boolean", "integer", "number", "string", "array", "object"}
+
+// LoadSchema reads and parses the JSON Schema at path.
+func LoadSchema(path string) (*Schema, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("schema: %w", err)
+	}
+	s, err := ParseSchema(b)
+	if err != nil {
+		return nil, fmt.Errorf("schema %s: %w", path, err)
+	}
+	return s, nil
+}
+
+// ParseSchema parses a JSON Schema document.
+func ParseSchema(b []byte) (*Schema, error) {
+	var v any
+	dec := json.NewDecoder(strings.NewReader(string(b)))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil {
+		return nil, fmt.Errorf("JSON parse error: %w", err)
+	}
+	return parseSchema(v, "")
+}
+
+// SchemaFor returns the schema content of the given format, read from
+// filename, is checked against: schemaPath when set, else SchemaFile next
+// to filename when it exists. Formats other than JSON and YAML have none.
+func SchemaFor(filename, format, schemaPath string) string {
+	if format != "json" && format != "yaml" {
+		return ""
+	}
+	if schemaPath != "" {
+		return schemaPath
+	}
+	found := filepath.Join(filepath.Dir(filename), SchemaFile)
+	if _, err := os.Stat(found); err != nil {
+		return ""
+	}
+	return found
+}
+
+// CheckWithSchema is Check followed, for JSON and YAML content that
+// parses, by a check against the schema SchemaFor names. Schema failures
+// take the format's severity; a schema that cannot be loaded is an error
+// whatever the severity.
+func CheckWithSchema(filename, content, schemaPath string, severities map[string]model.Severity, rules []model.TypeRule) (model.Severity, error) {
+	sev, err := Check(filename, content, severities, rules)
+	if err != nil || sev == model.SeverityOff {
+		return sev, err
+	}
+	format := Format(filename, content, rules)
+	path := SchemaFor(filename, format, schemaPath)
+	if path == "" {
+		return sev, nil
+	}
+	s, err := LoadSchema(path)
+	if err != nil {
+		return model.SeverityError, err
+	}
+	if err := s.Validate(content, format); err != nil {
+		return sev, fmt.Errorf("schema %s: %w", path, err)
+	}
+	return sev, nil
+}
+
+// Validate checks JSON or YAML content against the schema. The error
+// lists every failure by line and dotted path (as outline.Find takes
+// them), never the value.
+func (s *Schema) Validate(content, format string) error {
+	var doc any
+	switch format {
+	case "json":
+		dec := json.NewDecoder(strings.NewReader(content))
+		dec.UseNumber()
+		if err := dec.Decode(&doc); err != nil {
+			return fmt.Errorf("JSON parse error: %w", err)
+		}
+	case "yaml":
+		if err := yaml.Unmarshal([]byte(content), &doc); err != nil {
+			return fmt.Errorf("YAML parse error: %w", err)
+		}
+	default:
+		return fmt.Errorf("schemas apply to JSON and YAML, not %q", format)
+	}
+
+	var problems []problem
+	s.check(normalize(doc), nil, &problems)
+	if len(problems) == 0 {
+		return nil
+	}
+	for i, p := range problems {
+		if len(p.path) > 0 {
+			problems[i].line, _ = outline.Find(content, format, strings.Join(p.path, "."))
+		}
+	}
+	slices.SortStableFunc(problems, func(a, b problem) int { return a.line - b.line })
+	msgs := make([]string, len(problems))
+	for i, p := range problems {
+		msgs[i] = p.String()
+	}
+	return errors.New(strings.Join(msgs, "; "))
+}
+
+// problem is one schema failure at a path in the document.
+type problem struct {
+	path []string
+	line int // 0 when unknown
+	msg  string
+}
+
+func (p problem) String() string {
+	where := "top level"
+	if len(p.path) > 0 {
+		where = strings.Join(p.path, ".")
+	}
+	if p.line > 0 {
+		return fmt.Sprintf("line %d: %s: %s", p.line, where, p.msg)
+	}
+	return where + ": " + p.msg
+}
+
+func (s *Schema) check(v any, path []string, out *[]problem) {
+	fail := func(format string, args ...any) {
+		*out = append(*out, problem{path: slices.Clone(path), msg: fmt.Sprintf(format, args...)})
+	}
+	if s.never {
+		fail("not allowed")
+		return
+	}
+	t := typeOf(v)
+	if len(s.types) > 0 && !slices.Contains(s.types, t) && !(t == "integer" && slices.Contains(s.types, "number")) {
+		fail("want %s, got %s", strings.Join(s.types, " or "), t)
+		return
+	}
+	if s.enum != nil && !slices.ContainsFunc(s.enum, func(e any) bool { return reflect.DeepEqual(e, v) }) {
+		fail("not one of the allowed values")
+	}
+
+	switch v := v.(type) {
+	case string:
+		n := utf8.RuneCountInString(v)
+		if s.minLength >= 0 && n < s.minLength {
+			fail("shorter than %d characters", s.minLength)
+		}
+		if s.maxLength >= 0 && n > s.maxLength {
+			fail("longer than %d characters", s.maxLength)
+		}
+		if s.pattern != nil && !s.pattern.MatchString(v) {
+			fail("does not match %s", s.pattern)
+		}
+	case float64:
+		if s.minimum != nil && v < *s.minimum {
+			fail("less than %s", formatNumber(*s.minimum))
+		}
+		if s.maximum != nil && v > *s.maximum {
+			fail("greater than %s", formatNumber(*s.maximum))
+		}
+	case []any:
+		if s.minItems >= 0 && len(v) < s.minItems {
+			fail("fewer than %d items", s.minItems)
+		}
+		if s.maxItems >= 0 && len(v) > s.maxItems {
+			fail("more than %d items", s.maxItems)
+		}
+		if s.items != nil {
+			for i, item := range v {
+				s.items.check(item, append(path, strconv.Itoa(i)), out)
+			}
+		}
+	case map[string]any:
+		for _, k := range s.required {
+			if _, ok := v[k]; !ok {
+				fail("missing required key %q", k)
+			}
+		}
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		slices.Sort(keys)
+		for _, k := range keys {
+			child := append(path, k)
+			switch sub, ok := s.properties[k]; {
+			case ok:
+				sub.check(v[k], child, out)
+			case s.additionalProperties != nil && s.additionalProperties.never:
+				*out = append(*out, problem{path: slices.Clone(child), msg: "key not allowed by the schema"})
+			case s.additionalProperties != nil:
+				s.additionalProperties.check(v[k], child, out)
+			}
+		}
+	}
+}
+
+// typeOf names v's JSON Schema type; whole numbers are "integer".
+func typeOf(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		if v == math.Trunc(v) {
+			return "integer"
+		}
+		return "number"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+// normalize turns decoded JSON or YAML into nil, bool, float64, string,
+// []any, and map[string]any, so documents and enum values compare alike.
+func normalize(v any) any {
+	switch v := v.(type) {
+	case json.Number:
+		f, _ := v.Float64()
+		return f
+	case int:
+		return float64(v)
+	case int64:
+		return float64(v)
+	case uint64:
+		return float64(v)
+	case float64:
+		return v
+	case []any:
+		out := make([]any, len(v))
+		for i, e := range v {
+			out[i] = normalize(e)
+		}
+		return out
+	case map[string]any:
+		out := make(map[string]any, len(v))
+		for k, e := range v {
+			out[k] = normalize(e)
+		}
+		return out
+	case map[any]any:
+		out := make(map[string]any, len(v))
+		for k, e := range v {
+			out[fmt.Sprint(k)] = normalize(e)
+		}
+		return out
+	}
+	return v
+}
+
+func formatNumber(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// parseSchema builds a Schema from a decoded schema document; at names
+// the keyword path for errors.
+func parseSchema(v any, at string) (*Schema, error) {
+	if b, ok := v.(bool); ok {
+		if b {
+			return &Schema{minLength: -1, maxLength: -1, minItems: -1, maxItems: -1}, nil
+		}
+		return &Schema{never: true}, nil
+	}
+	obj, ok := v.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("%sa schema must be an object or a boolean", prefix(at))
+	}
+	s := &Schema{minLength: -1, maxLength: -1, minItems: -1, maxItems: -1}
+	keys := make([]string, 0, len(obj))
+	for k := range obj {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	for _, k := range keys {
+		val, kat := obj[k], at+"/"+k
+		var err error
+		switch k {
+		case "type":
+			s.types, err = parseTypes(val)
+		case "properties":
+			props, ok := val.(map[string]any)
+			if !ok {
+				err = errors.New("want an object")
+				break
+			}
+			s.properties = map[string]*Schema{}
+			for name, sub := range props {
+				if s.properties[name], err = parseSchema(sub, kat+"/"+name); err != nil {
+					return nil, err
+				}
+			}
+		case "required":
+			s.required, err = parseStrings(val)
+		case "additionalProperties":
+			s.additionalProperties, err = parseSchema(val, kat)
+			if err != nil {
+				return nil, err
+			}
+		case "items":
+			if s.items, err = parseSchema(val, kat); err != nil {
+				return nil, err
+			}
+		case "enum":
+			list, ok := val.([]any)
+			if !ok {
+				err = errors.New("want an array")
+				break
+			}
+			s.enum = normalize(list).([]any)
+		case "const":
+			s.enum = []any{normalize(val)}
+		case "pattern":
+			str, ok := val.(string)
+			if !ok {
+				err = errors.New("want a string")
+				break
+			}
+			s.pattern, err = regexp.Compile(str)
+		case "minLength":
+			s.minLength, err = parseCount(val)
+		case "maxLength":
+			s.maxLength, err = parseCount(val)
+		case "minItems":
+			s.minItems, err = parseCount(val)
+		case "maxItems":
+			s.maxItems, err = parseCount(val)
+		case "minimum":
+			s.minimum, err = parseNumber(val)
+		case "maximum":
+			s.maximum, err = parseNumber(val)
+		default:
+			if !slices.Contains(annotations, k) {
+				err = errors.New("unsupported keyword")
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", kat, err)
+		}
+	}
+	return s, nil
+}
+
+func prefix(at string) string {
+	if at == "" {
+		return ""
+	}
+	return at + ": "
+}
+
+func parseTypes(v any) ([]string, error) {
+	var types []string
+	if s, ok := v.(string); ok {
+		types = []string{s}
+	} else {
+		var err error
+		if types, err = parseStrings(v); err != nil {
+			return nil, err
+		}
+	}
+	for _, t := range types {
+		if !slices.Contains(schemaTypes, t) {
+			return nil, fmt.Errorf("unknown type %q (want %s)", t, strings.Join(schemaTypes, ", "))
+		}
+	}
+	return types, nil
+}
+
+func parseStrings(v any) ([]string, error) {
+	list, ok := v.([]any)
+	if !ok {
+		return nil, errors.New("want an array of strings")
+	}
+	out := make([]string, len(list))
+	for i, e := range list {
+		s, ok := e.(string)
+		if !ok {
+			return nil, errors.New("want an array of strings")
+		}
+		out[i] = s
+	}
+	return out, nil
+}
+
+func parseCount(v any) (int, error) {
+	n, ok := v.(json.Number)
+	if !ok {
+		return 0, errors.New("want a non-negative integer")
+	}
+	i, err := n.Int64()
+	if err != nil || i < 0 {
+		return 0, errors.New("want a non-negative integer")
+	}
+	return int(i), nil
+}
+
+func parseNumber(v any) (*float64, error) {
+	n, ok := v.(json.Number)
+	if !ok {
+		return nil, errors.New("want a number")
+	}
+	f, err := n.Float64()
+	if err != nil {
+		return nil, errors.New("want a number")
+	}
+	return &f, nil
+}
@@ -4,28 +4,92 @@ import (
 	"bufio"
 	"encoding/json"
 	"fmt"
-	"path/filepath"
+	"slices"
 	"strings"
 
+	"github.com/andreweick/agepad/dotenv"
+	"github.com/andreweick/agepad/filetype"
+	"github.com/andreweick/agepad/model"
 	"github.com/pelletier/go-toml/v2"
 	"gopkg.in/yaml.v3"
 )
 
-// ValidateByExt validates content based on file extension.
+// Format names the format content is checked as, or "" when it is not
+// validated at all. The name decides first (see filetype.FromName); files
+// it cannot place are treated as .env when they look like one.
+func Format(filename, content string, rules []model.TypeRule) string {
+	if f := filetype.FromName(filename, rules); f != "" {
+		return f
+	}
+	if looksLikeDotEnv(content) {
+		return "env"
+	}
+	return ""
+}
+
+// Check validates content and applies the severity configured for its
+// format. A non-nil error comes with the severity that applies to it:
+// SeverityError should block a save, SeverityWarn should only be reported.
+// Formats switched off are not parsed at all.
+func Check(filename, content string, severities map[string]model.Severity, rules []model.TypeRule) (model.Severity, error) {
+	format := Format(filename, content, rules)
+	sev, ok := severities[format]
+	if !ok {
+		sev = model.SeverityError
+	}
+	if sev == model.SeverityOff {
+		return sev, nil
+	}
+	return sev, validateFormat(format, content)
+}
+
+// ParseSeverities parses "format=severity" pairs such as "json=warn" or
+// "yaml=off". The format "all" applies to every format.
+func ParseSeverities(pairs []string) (map[string]model.Severity, error) {
+	out := map[string]model.Severity{}
+	for _, p := range pairs {
+		f, s, ok := strings.Cut(strings.TrimSpace(p), "=")
+		if !ok {
+			return nil, fmt.Errorf("validation severity %q: expected FORMAT=error|warn|off", p)
+		}
+		f = strings.ToLower(strings.TrimSpace(f))
+		sev := model.Severity(strings.ToLower(strings.TrimSpace(s)))
+		switch sev {
+		case model.SeverityError, model.SeverityWarn, model.SeverityOff:
+		default:
+			return nil, fmt.Errorf("validation severity %q: unknown severity %q (want error, warn, or off)", p, s)
+		}
+		switch {
+		case f == "all":
+			for _, name := range filetype.Formats {
+				out[name] = sev
+			}
+		case slices.Contains(filetype.Formats, f):
+			out[f] = sev
+		default:
+			return nil, fmt.Errorf("validation severity %q: unknown format %q (want %s, or all)", p, f, strings.Join(filetype.Formats, ", "))
+		}
+	}
+	return out, nil
+}
+
+// ValidateByExt validates content based on file extension, looking past a
+// trailing .age suffix.
 func ValidateByExt(filename string, content string) error {
-	ext := strings.ToLower(filepath.Ext(filename))
-	switch ext {
-	case ".json":
+	return validateFormat(Format(filename, content, nil), content)
+}
+
+func validateFormat(format, content string) error {
+	switch format {
+	case "json":
 		return validateJSON(content)
-	case ".yaml", ".yml":
+	case "yaml":
 		return validateYAML(content)
-	case ".toml":
+	case "toml":
 		return validateTOML(content)
+	case "env":
+		return validateDotEnv(content)
 	default:
-		// If it looks like .env, validate basic KEY=VAL lines; otherwise accept.
-		if looksLikeDotEnv(content) {
-			return validateDotEnv(content)
-		}
 		return nil
 	}
 }
@@ -73,23 +137,6 @@ func looksLikeDotEnv(s string) bool {
 }
 
 func validateDotEnv(s string) error {
-	sc := bufio.NewScanner(strings.NewReader(s))
-	lineNo := 0
-	for sc.Scan() {
-		lineNo++
-		line := sc.Text()
-		t := strings.TrimSpace(line)
-		if t == "" || strings.HasPrefix(t, "#") {
-			continue
-		}
-		if !strings.Contains(t, "=") || strings.HasPrefix(t, "=") {
-			return fmt.Errorf(".env parse error on line %d: expected KEY=VALUE", lineNo)
-		}
-		kv := strings.SplitN(t, "=", 2)
-		key := strings.TrimSpace(kv[0])
-		if key == "" || strings.ContainsAny(key, " \t\"'") {
-			return fmt.Errorf(".env invalid key on line %d", lineNo)
-		}
-	}
-	return nil
+	_, err := dotenv.Parse(s)
+	return err
 }
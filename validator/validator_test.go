@@ -1,8 +1,12 @@
 package validator
 
 import (
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+
+	"github.com/andreweick/agepad/model"
 )
 
 func TestValidateJSON(t *testing.T) {
@@ -182,6 +186,19 @@ KEY_WITHOUT_EQUALS`
 		}
 	})
 
+	t.Run("validates quoted, exported, and multiline values", func(t *testing.T) {
+		content := "export A=\"x y\"\nB='z'\nCERT=\"-----BEGIN-----\nabc\n-----END-----\"\n"
+		if err := ValidateByExt("test.env", content); err != nil {
+			t.Errorf("expected valid .env, got error: %v", err)
+		}
+	})
+
+	t.Run("rejects unterminated quoted values", func(t *testing.T) {
+		if err := ValidateByExt("test.env", "A=\"open\nB=2"); err == nil {
+			t.Error("expected unterminated quote to fail validation")
+		}
+	})
+
 	t.Run("validates .env with only comments and blank lines", func(t *testing.T) {
 		content := `
 # Just comments
@@ -251,3 +268,205 @@ func TestLooksLikeDotEnv(t *testing.T) {
 		}
 	})
 }
+
+func TestCheck(t *testing.T) {
+	t.Run("defaults to error severity", func(t *testing.T) {
+		sev, err := Check("a.json", "{", nil, nil)
+		if err == nil || sev != model.SeverityError {
+			t.Errorf("expected error severity with error, got %q %v", sev, err)
+		}
+	})
+
+	t.Run("reports warn severity with the error", func(t *testing.T) {
+		sev, err := Check("a.yaml", "a: [", map[string]model.Severity{"yaml": model.SeverityWarn}, nil)
+		if err == nil || sev != model.SeverityWarn {
+			t.Errorf("expected warn severity with error, got %q %v", sev, err)
+		}
+	})
+
+	t.Run("skips formats that are off", func(t *testing.T) {
+		if _, err := Check("a.toml", "= broken", map[string]model.Severity{"toml": model.SeverityOff}, nil); err != nil {
+			t.Errorf("expected no error when off, got %v", err)
+		}
+	})
+}
+
+func TestFormat(t *testing.T) {
+	t.Run("uses the extension before .age", func(t *testing.T) {
+		if err := ValidateByExt("secrets/app.json.age", "{"); err == nil {
+			t.Error("expected JSON validation for .json.age")
+		}
+	})
+
+	t.Run("applies type rules", func(t *testing.T) {
+		rules := []model.TypeRule{{Pattern: "*.cfg", Format: "toml"}}
+		if got := Format("app.cfg.age", "", rules); got != "toml" {
+			t.Errorf("expected toml, got %q", got)
+		}
+		if _, err := Check("app.cfg.age", "= broken", nil, rules); err == nil {
+			t.Error("expected TOML validation via type rule")
+		}
+	})
+}
+
+func TestParseSeverities(t *testing.T) {
+	t.Run("parses pairs and all", func(t *testing.T) {
+		got, err := ParseSeverities([]string{"all=warn", "json=off"})
+		if err != nil {
+			t.Fatalf("parse failed: %v", err)
+		}
+		if got["json"] != model.SeverityOff || got["env"] != model.SeverityWarn {
+			t.Errorf("unexpected severities: %v", got)
+		}
+	})
+
+	t.Run("rejects unknown formats and severities", func(t *testing.T) {
+		for _, in := range []string{"xml=warn", "json=maybe", "json"} {
+			if _, err := ParseSeverities([]string{in}); err == nil {
+				t.Errorf("expected error for %q", in)
+			}
+		}
+	})
+}
+
+func TestSchema(t *testing.T) {
+	const schema = `{
+  "$schema": "https://json-schema.org/draft/2020-12/schema",
+  "type": "object",
+  "required": ["db", "env"],
+  "additionalProperties": false,
+  "properties": {
+    "env": {"enum": ["staging", "production"]},
+    "db": {
+      "type": "object",
+      "required": ["host", "password"],
+      "properties": {
+        "host": {"type": "string", "pattern": "^[a-z0-9.-]+$"},
+        "port": {"type": "integer", "minimum": 1, "maximum": 65535},
+        "password": {"type": "string", "minLength": 16}
+      }
+    },
+    "replicas": {"type": "array", "items": {"type": "string"}}
+  }
+}`
+	s, err := ParseSchema([]byte(schema))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+
+	t.Run("passes matching JSON and YAML", func(t *testing.T) {
+		if err := s.Validate(`{"env": "production", "db": {"host": "db.internal", "port": 5432, "password": "correct horse battery"}}`, "json"); err != nil {
+			t.Errorf("json: %v", err)
+		}
+		if err := s.Validate("env: staging\ndb:\n  host: db.internal\n  port: 5432\n  password: correct horse battery\nreplicas: [a, b]\n", "yaml"); err != nil {
+			t.Errorf("yaml: %v", err)
+		}
+	})
+
+	t.Run("lists failures by line and path, without values", func(t *testing.T) {
+		in := "env: dev\n" +
+			"db:\n" +
+			"  host: DB_HOST\n" +
+			"  port: \"5432\"\n" +
+			"  password: hunter2\n" +
+			"replicas:\n" +
+			"  - 3\n" +
+			"extra: true\n"
+		err := s.Validate(in, "yaml")
+		if err == nil {
+			t.Fatal("expected failures")
+		}
+		want := strings.Join([]string{
+			"line 1: env: not one of the allowed values",
+			"line 3: db.host: does not match ^[a-z0-9.-]+$",
+			"line 4: db.port: want integer, got string",
+			"line 5: db.password: shorter than 16 characters",
+			"line 7: replicas.0: want string, got integer",
+			"line 8: extra: key not allowed by the schema",
+		}, "; ")
+		if err.Error() != want {
+			t.Errorf("got:\n%v\nwant:\n%s", err, want)
+		}
+		for _, v := range []string{"hunter2", "dev"} {
+			if strings.Contains(err.Error(), v) {
+				t.Errorf("value %q in error", v)
+			}
+		}
+	})
+
+	t.Run("reports missing required keys", func(t *testing.T) {
+		err := s.Validate(`{"db": {"host": "db"}}`, "json")
+		want := `top level: missing required key "env"; line 1: db: missing required key "password"`
+		if err == nil || err.Error() != want {
+			t.Errorf("got %v, want %s", err, want)
+		}
+	})
+
+	t.Run("refuses keywords it does not enforce", func(t *testing.T) {
+		for _, in := range []string{
+			`{"anyOf": [{"type": "string"}]}`,
+			`{"properties": {"a": {"$ref": "#/$defs/a"}}}`,
+			`{"type": "text"}`,
+			`{"minLength": -1}`,
+			`[]`,
+		} {
+			if _, err := ParseSchema([]byte(in)); err == nil {
+				t.Errorf("expected an error for %s", in)
+			}
+		}
+	})
+}
+
+func TestCheckWithSchema(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.json.age")
+	write := func(name, content string) string {
+		t.Helper()
+		p := filepath.Join(dir, name)
+		if err := os.WriteFile(p, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+
+	t.Run("checks nothing without a schema", func(t *testing.T) {
+		if _, err := CheckWithSchema(path, `{}`, "", nil, nil); err != nil {
+			t.Errorf("got %v", err)
+		}
+	})
+
+	t.Run("finds the schema next to the file", func(t *testing.T) {
+		found := write(SchemaFile, `{"required": ["token"]}`)
+		if got := SchemaFor(path, "json", ""); got != found {
+			t.Errorf("SchemaFor = %q, want %q", got, found)
+		}
+		if got := SchemaFor(filepath.Join(dir, "app.env.age"), "env", ""); got != "" {
+			t.Errorf("expected no schema for env, got %q", got)
+		}
+		sev, err := CheckWithSchema(path, `{}`, "", nil, nil)
+		if err == nil || sev != model.SeverityError || !strings.Contains(err.Error(), `missing required key "token"`) {
+			t.Errorf("got %q %v", sev, err)
+		}
+	})
+
+	t.Run("prefers --schema and keeps the format's severity", func(t *testing.T) {
+		explicit := write("other.json", `{"properties": {"token": {"type": "string"}}}`)
+		warn := map[string]model.Severity{"json": model.SeverityWarn}
+		sev, err := CheckWithSchema(path, `{"token": 1}`, explicit, warn, nil)
+		if err == nil || sev != model.SeverityWarn || !strings.HasPrefix(err.Error(), "schema "+explicit+": ") {
+			t.Errorf("got %q %v", sev, err)
+		}
+		off := map[string]model.Severity{"json": model.SeverityOff}
+		if _, err := CheckWithSchema(path, `{"token": 1}`, explicit, off, nil); err != nil {
+			t.Errorf("expected no check when off, got %v", err)
+		}
+	})
+
+	t.Run("a broken schema is an error even at warn", func(t *testing.T) {
+		broken := write("broken.json", `{"oneOf": []}`)
+		sev, err := CheckWithSchema(path, `{}`, broken, map[string]model.Severity{"json": model.SeverityWarn}, nil)
+		if err == nil || sev != model.SeverityError {
+			t.Errorf("got %q %v", sev, err)
+		}
+	})
+}
@@ -0,0 +1,373 @@
+// Package valuecrypt encrypts the values of a JSON or YAML document one by
+// one and leaves its keys, nesting, and YAML comments in plaintext, so a
+// diff of the encrypted file shows which keys changed without showing
+// what they changed to.
+//
+// A random data key seals each scalar with XChaCha20-Poly1305, bound to
+// its key path and type, and is itself age-encrypted to the recipients in
+// the document's MetaKey entry. A MAC over every path and value, sealed
+// under the same key, makes adding, dropping, or swapping values fail to
+// decrypt.
+package valuecrypt
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"strings"
+
+	"filippo.io/age"
+	agepkg "github.com/andreweick/agepad/age"
+	"golang.org/x/crypto/chacha20poly1305"
+	"gopkg.in/yaml.v3"
+)
+
+// MetaKey is the top-level key holding the wrapped data key and the MAC.
+const MetaKey = "_agepad"
+
+const version = "1"
+
+// ErrNotEncrypted is returned by Decrypt for a document without MetaKey.
+var ErrNotEncrypted = errors.New("not a value-encrypted document (no " + MetaKey + " entry)")
+
+// Key is a document's data key, returned by Decrypt. Handed back to
+// Encrypt it keeps the wrapped key and the ciphertext of every value that
+// did not change, so only edited values differ on disk.
+type Key struct {
+	data    []byte
+	wrapped string
+	values  map[string]string // path -> marker as read
+	mac     string
+}
+
+// IsEncrypted reports whether content is a value-encrypted format document.
+func IsEncrypted(content, format string) bool {
+	doc, err := parse(content, format)
+	if err != nil {
+		return false
+	}
+	_, meta := metaEntry(doc)
+	return meta != nil
+}
+
+// KeyBlock returns the armored age file holding content's data key, whose
+// header names the recipients the document is encrypted to.
+func KeyBlock(content, format string) ([]byte, error) {
+	doc, err := parse(content, format)
+	if err != nil {
+		return nil, err
+	}
+	wrapped, _, err := readMeta(doc)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(wrapped), nil
+}
+
+// Encrypt returns plain with every scalar value encrypted, in the same
+// format. With prev (from Decrypt of the current file) the data key and
+// its recipients are kept and unchanged values keep their ciphertext;
+// recips is then unused. Pass nil after the recipients change, so a fresh
+// key is wrapped to the new set.
+func Encrypt(plain, format string, recips []age.Recipient, prev *Key) (string, error) {
+	doc, err := parse(plain, format)
+	if err != nil {
+		return "", err
+	}
+	if _, meta := metaEntry(doc); meta != nil {
+		return "", fmt.Errorf("already value-encrypted: remove the %s entry or decrypt first", MetaKey)
+	}
+	k := prev
+	if k == nil {
+		data := make([]byte, chacha20poly1305.KeySize)
+		if _, err := rand.Read(data); err != nil {
+			return "", fmt.Errorf("generate data key: %w", err)
+		}
+		wrapped, err := agepkg.EncryptToMemory(data, recips, true)
+		if err != nil {
+			return "", fmt.Errorf("wrap data key: %w", err)
+		}
+		k = &Key{data: data, wrapped: string(wrapped)}
+	}
+	aead, err := chacha20poly1305.NewX(k.data)
+	if err != nil {
+		return "", err
+	}
+	mac := sha256.New()
+	err = eachScalar(doc, func(path string, n *yaml.Node) error {
+		typ := strings.TrimPrefix(n.ShortTag(), "!!")
+		macAdd(mac, path, typ, n.Value)
+		if old, ok := k.values[path]; ok {
+			if t, v, err := open(aead, path, old); err == nil && t == typ && v == n.Value {
+				setMarker(n, old)
+				return nil
+			}
+		}
+		m, err := seal(aead, path, typ, n.Value)
+		if err != nil {
+			return err
+		}
+		setMarker(n, m)
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	sum := mac.Sum(nil)
+	macMarker := ""
+	if k.mac != "" {
+		if t, v, err := open(aead, MetaKey, k.mac); err == nil && t == "mac" && v == string(sum) {
+			macMarker = k.mac
+		}
+	}
+	if macMarker == "" {
+		if macMarker, err = seal(aead, MetaKey, "mac", string(sum)); err != nil {
+			return "", err
+		}
+	}
+	str := func(v string) *yaml.Node { return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: v} }
+	meta := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map", Content: []*yaml.Node{
+		str("version"), str(version),
+		str("key"), {Kind: yaml.ScalarNode, Tag: "!!str", Value: k.wrapped, Style: yaml.LiteralStyle},
+		str("mac"), str(macMarker),
+	}}
+	root := doc.Content[0]
+	root.Content = append(root.Content, str(MetaKey), meta)
+	return render(doc, format)
+}
+
+// Decrypt unwraps content's data key with ids and returns the plaintext
+// document, without MetaKey, and the key for a later Encrypt.
+func Decrypt(content, format string, ids []age.Identity) (string, *Key, error) {
+	doc, err := parse(content, format)
+	if err != nil {
+		return "", nil, err
+	}
+	wrapped, macMarker, err := readMeta(doc)
+	if err != nil {
+		return "", nil, err
+	}
+	data, err := agepkg.Decrypt([]byte(wrapped), ids)
+	if err != nil {
+		return "", nil, fmt.Errorf("unwrap data key: %w", err)
+	}
+	if len(data) != chacha20poly1305.KeySize {
+		return "", nil, errors.New("unwrap data key: wrong key size")
+	}
+	k := &Key{data: []byte(data), wrapped: wrapped, values: map[string]string{}, mac: macMarker}
+	aead, err := chacha20poly1305.NewX(k.data)
+	if err != nil {
+		return "", nil, err
+	}
+	i, _ := metaEntry(doc)
+	root := doc.Content[0]
+	root.Content = append(root.Content[:i], root.Content[i+2:]...)
+	mac := sha256.New()
+	err = eachScalar(doc, func(path string, n *yaml.Node) error {
+		typ, v, err := open(aead, path, n.Value)
+		if err != nil {
+			return err
+		}
+		k.values[path] = n.Value
+		macAdd(mac, path, typ, v)
+		n.Tag, n.Value, n.Style = "!!"+typ, v, 0
+		return nil
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	if _, sum, err := open(aead, MetaKey, macMarker); err != nil || sum != string(mac.Sum(nil)) {
+		return "", nil, errors.New("MAC mismatch: values were added, removed, or replaced outside agepad")
+	}
+	plain, err := render(doc, format)
+	if err != nil {
+		return "", nil, err
+	}
+	return plain, k, nil
+}
+
+// parse reads content as a single document whose top level is a mapping,
+// returned as doc.Content[0]. JSON is read with the YAML parser, which
+// keeps key order.
+func parse(content, format string) (*yaml.Node, error) {
+	if format != "json" && format != "yaml" {
+		return nil, fmt.Errorf("value encryption supports json and yaml, not %q", format)
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte(content), &doc); err != nil {
+		return nil, fmt.Errorf("%s parse error: %w", strings.ToUpper(format), err)
+	}
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return nil, errors.New("value encryption needs a top-level mapping of keys")
+	}
+	return &doc, nil
+}
+
+// metaEntry returns the index of the MetaKey key node in doc and its value.
+func metaEntry(doc *yaml.Node) (int, *yaml.Node) {
+	root := doc.Content[0]
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		if root.Content[i].Value == MetaKey {
+			return i, root.Content[i+1]
+		}
+	}
+	return -1, nil
+}
+
+func readMeta(doc *yaml.Node) (wrapped, mac string, err error) {
+	_, meta := metaEntry(doc)
+	if meta == nil {
+		return "", "", ErrNotEncrypted
+	}
+	fields := map[string]string{}
+	for i := 0; i+1 < len(meta.Content); i += 2 {
+		fields[meta.Content[i].Value] = meta.Content[i+1].Value
+	}
+	if fields["version"] != version {
+		return "", "", fmt.Errorf("%s: unsupported version %q", MetaKey, fields["version"])
+	}
+	if fields["key"] == "" || fields["mac"] == "" {
+		return "", "", fmt.Errorf("%s: missing key or mac", MetaKey)
+	}
+	return fields["key"], fields["mac"], nil
+}
+
+// eachScalar calls fn for every scalar value under doc with its path;
+// mapping keys and aliases are left alone.
+func eachScalar(doc *yaml.Node, fn func(path string, n *yaml.Node) error) error {
+	var walk func(n *yaml.Node, path []any) error
+	walk = func(n *yaml.Node, path []any) error {
+		switch n.Kind {
+		case yaml.MappingNode:
+			for i := 0; i+1 < len(n.Content); i += 2 {
+				if err := walk(n.Content[i+1], append(path, n.Content[i].Value)); err != nil {
+					return err
+				}
+			}
+		case yaml.SequenceNode:
+			for i, item := range n.Content {
+				if err := walk(item, append(path, i)); err != nil {
+					return err
+				}
+			}
+		case yaml.ScalarNode:
+			p, _ := json.Marshal(path)
+			return fn(string(p), n)
+		}
+		return nil
+	}
+	return walk(doc.Content[0], nil)
+}
+
+// A marker is ENC[<type>:<base64 of nonce and ciphertext>], sealed with
+// the path and type as additional data so it only opens where it was
+// written.
+func seal(aead cipher.AEAD, path, typ, value string) (string, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("nonce: %w", err)
+	}
+	ct := aead.Seal(nonce, nonce, []byte(value), []byte(path+"\x00"+typ))
+	return "ENC[" + typ + ":" + base64.StdEncoding.EncodeToString(ct) + "]", nil
+}
+
+func open(aead cipher.AEAD, path, marker string) (typ, value string, err error) {
+	body, ok := strings.CutPrefix(marker, "ENC[")
+	if body, ok = strings.CutSuffix(body, "]"); !ok {
+		return "", "", fmt.Errorf("value at %s is not encrypted", path)
+	}
+	typ, b64, _ := strings.Cut(body, ":")
+	ct, err := base64.StdEncoding.DecodeString(b64)
+	if err != nil || len(ct) < aead.NonceSize() {
+		return "", "", fmt.Errorf("value at %s is malformed", path)
+	}
+	ns := aead.NonceSize()
+	plain, err := aead.Open(nil, ct[:ns], ct[ns:], []byte(path+"\x00"+typ))
+	if err != nil {
+		return "", "", fmt.Errorf("value at %s does not decrypt (edited, or moved from another key)", path)
+	}
+	return typ, string(plain), nil
+}
+
+func setMarker(n *yaml.Node, marker string) {
+	n.Tag, n.Value, n.Style = "!!str", marker, 0
+}
+
+// macAdd feeds one value to the MAC, length-prefixing each field.
+func macAdd(mac hash.Hash, fields ...string) {
+	for _, f := range fields {
+		var n [8]byte
+		binary.BigEndian.PutUint64(n[:], uint64(len(f)))
+		mac.Write(n[:])
+		mac.Write([]byte(f))
+	}
+}
+
+// render writes doc back out: YAML through the encoder, which keeps
+// comments, and JSON with two-space indentation in document order.
+func render(doc *yaml.Node, format string) (string, error) {
+	if format == "json" {
+		var b strings.Builder
+		if err := writeJSON(&b, doc.Content[0], ""); err != nil {
+			return "", err
+		}
+		b.WriteString("\n")
+		return b.String(), nil
+	}
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(doc); err != nil {
+		return "", err
+	}
+	return buf.String(), enc.Close()
+}
+
+func writeJSON(b *strings.Builder, n *yaml.Node, indent string) error {
+	switch n.Kind {
+	case yaml.MappingNode, yaml.SequenceNode:
+		open, closing, step := "[", "]", 1
+		if n.Kind == yaml.MappingNode {
+			open, closing, step = "{", "}", 2
+		}
+		b.WriteString(open)
+		for i := 0; i < len(n.Content); i += step {
+			if i > 0 {
+				b.WriteString(",")
+			}
+			b.WriteString("\n" + indent + "  ")
+			v := n.Content[i]
+			if step == 2 {
+				k, _ := json.Marshal(v.Value)
+				b.Write(k)
+				b.WriteString(": ")
+				v = n.Content[i+1]
+			}
+			if err := writeJSON(b, v, indent+"  "); err != nil {
+				return err
+			}
+		}
+		if len(n.Content) > 0 {
+			b.WriteString("\n" + indent)
+		}
+		b.WriteString(closing)
+	case yaml.ScalarNode:
+		switch n.ShortTag() {
+		case "!!int", "!!float", "!!bool", "!!null":
+			b.WriteString(n.Value)
+		default:
+			s, _ := json.Marshal(n.Value)
+			b.Write(s)
+		}
+	default:
+		return fmt.Errorf("JSON cannot hold YAML node at line %d", n.Line)
+	}
+	return nil
+}
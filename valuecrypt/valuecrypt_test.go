@@ -0,0 +1,136 @@
+package valuecrypt
+
+import (
+	"strings"
+	"testing"
+
+	"filippo.io/age"
+)
+
+func TestRoundTrip(t *testing.T) {
+	id, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	recips := []age.Recipient{id.Recipient()}
+	ids := []age.Identity{id}
+
+	t.Run("yaml keeps keys, comments, and types", func(t *testing.T) {
+		plain := "# Service config\ndb:\n  password: hunter2 # rotated monthly\n  port: 5432\n  tls: true\nhosts:\n  - a.example\n  - \"true\"\n"
+		enc, err := Encrypt(plain, "yaml", recips, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, leak := range []string{"hunter2", "5432", "a.example"} {
+			if strings.Contains(enc, leak) {
+				t.Errorf("value %q leaked:\n%s", leak, enc)
+			}
+		}
+		for _, want := range []string{"# Service config", "# rotated monthly", "password: ENC[str:", "port: ENC[int:", MetaKey + ":"} {
+			if !strings.Contains(enc, want) {
+				t.Errorf("expected %q in:\n%s", want, enc)
+			}
+		}
+		if !IsEncrypted(enc, "yaml") || IsEncrypted(plain, "yaml") {
+			t.Error("IsEncrypted does not tell the two apart")
+		}
+		got, _, err := Decrypt(enc, "yaml", ids)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != plain {
+			t.Errorf("got:\n%s\nwant:\n%s", got, plain)
+		}
+	})
+
+	t.Run("json keeps key order and types", func(t *testing.T) {
+		plain := "{\n  \"z\": {\n    \"password\": \"hunter2\",\n    \"port\": 5432\n  },\n  \"a\": [\n    true,\n    null\n  ]\n}\n"
+		enc, err := Encrypt(plain, "json", recips, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(enc, "hunter2") || !strings.Contains(enc, `"password": "ENC[str:`) {
+			t.Errorf("unexpected encryption:\n%s", enc)
+		}
+		got, _, err := Decrypt(enc, "json", ids)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != plain {
+			t.Errorf("got:\n%s\nwant:\n%s", got, plain)
+		}
+	})
+
+	t.Run("unchanged values keep their ciphertext", func(t *testing.T) {
+		enc, err := Encrypt("a: one\nb: two\n", "yaml", recips, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, key, err := Decrypt(enc, "yaml", ids)
+		if err != nil {
+			t.Fatal(err)
+		}
+		again, err := Encrypt("a: one\nb: three\n", "yaml", nil, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		line := func(s, prefix string) string {
+			for _, l := range strings.Split(s, "\n") {
+				if strings.HasPrefix(l, prefix) {
+					return l
+				}
+			}
+			return ""
+		}
+		if line(enc, "a:") != line(again, "a:") {
+			t.Error("unchanged value a was re-encrypted")
+		}
+		if line(enc, "b:") == line(again, "b:") {
+			t.Error("changed value b kept its old ciphertext")
+		}
+		if got, _, err := Decrypt(again, "yaml", ids); err != nil || got != "a: one\nb: three\n" {
+			t.Errorf("got %q, %v", got, err)
+		}
+	})
+
+	t.Run("tampering is refused", func(t *testing.T) {
+		enc, err := Encrypt("a: one\nb: two\n", "yaml", recips, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines := strings.Split(enc, "\n")
+		a, b := strings.TrimPrefix(lines[0], "a: "), strings.TrimPrefix(lines[1], "b: ")
+		swapped := strings.Replace(strings.Replace(enc, a, "X", 1), b, a, 1)
+		swapped = strings.Replace(swapped, "X", b, 1)
+		if _, _, err := Decrypt(swapped, "yaml", ids); err == nil {
+			t.Error("values swapped between keys decrypted")
+		}
+		if _, _, err := Decrypt(strings.Join(lines[1:], "\n"), "yaml", ids); err == nil || !strings.Contains(err.Error(), "MAC") {
+			t.Errorf("dropped value: got %v, want a MAC mismatch", err)
+		}
+	})
+
+	t.Run("other identities cannot decrypt", func(t *testing.T) {
+		other, _ := age.GenerateX25519Identity()
+		enc, err := Encrypt("a: one\n", "yaml", recips, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, _, err := Decrypt(enc, "yaml", []age.Identity{other}); err == nil {
+			t.Error("decrypted without a matching identity")
+		}
+	})
+
+	t.Run("refuses other formats and encrypted input", func(t *testing.T) {
+		if _, err := Encrypt("A=1\n", "env", recips, nil); err == nil {
+			t.Error("env accepted")
+		}
+		enc, _ := Encrypt("a: one\n", "yaml", recips, nil)
+		if _, err := Encrypt(enc, "yaml", recips, nil); err == nil {
+			t.Error("encrypted twice")
+		}
+		if _, _, err := Decrypt("a: one\n", "yaml", ids); err != ErrNotEncrypted {
+			t.Errorf("got %v, want ErrNotEncrypted", err)
+		}
+	})
+}
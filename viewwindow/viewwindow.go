@@ -0,0 +1,143 @@
+// Package viewwindow limits when a file is meant to be opened, for
+// regulated environments where secrets are only read in business hours:
+//
+//	# agepad:view-window=09:00-18:00 mon-fri
+//
+// The annotation travels inside the ciphertext, and a window can also be
+// set for every file by flag or config. Like share expiry it is a soft
+// control: agepad asks for an extra confirmation and records the session
+// in the audit log, but the plain age CLI ignores it.
+package viewwindow
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// annotation starts a view window line in plaintext.
+const annotation = "# agepad:view-window="
+
+var dayNames = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+// Window is a daily span of local time, optionally on some weekdays only.
+// From after To wraps past midnight (22:00-06:00).
+type Window struct {
+	From, To time.Duration // since midnight
+	Days     [7]bool       // indexed by time.Weekday; all false means every day
+	spec     string
+}
+
+// Parse reads "HH:MM-HH:MM", optionally followed by days: "mon-fri",
+// "sat,sun", or a single day.
+func Parse(spec string) (Window, error) {
+	w := Window{spec: strings.TrimSpace(spec)}
+	fields := strings.Fields(spec)
+	if len(fields) == 0 || len(fields) > 2 {
+		return w, fmt.Errorf("view window %q: want HH:MM-HH:MM, optionally followed by days such as mon-fri", spec)
+	}
+	from, to, ok := strings.Cut(fields[0], "-")
+	if !ok {
+		return w, fmt.Errorf("view window %q: want HH:MM-HH:MM", spec)
+	}
+	var err error
+	if w.From, err = clock(from); err != nil {
+		return w, fmt.Errorf("view window %q: %w", spec, err)
+	}
+	if w.To, err = clock(to); err != nil {
+		return w, fmt.Errorf("view window %q: %w", spec, err)
+	}
+	if w.From == w.To {
+		return w, fmt.Errorf("view window %q: empty span", spec)
+	}
+	if len(fields) == 2 {
+		for _, part := range strings.Split(fields[1], ",") {
+			first, last, isRange := strings.Cut(part, "-")
+			a, b := day(first), day(first)
+			if isRange {
+				b = day(last)
+			}
+			if a < 0 || b < 0 {
+				return w, fmt.Errorf("view window %q: unknown day in %q (want sun..sat)", spec, part)
+			}
+			for d := a; ; d = (d + 1) % 7 {
+				w.Days[d] = true
+				if d == b {
+					break
+				}
+			}
+		}
+	}
+	return w, nil
+}
+
+func clock(s string) (time.Duration, error) {
+	h, m, ok := strings.Cut(s, ":")
+	hh, err1 := strconv.Atoi(h)
+	mm, err2 := strconv.Atoi(m)
+	if !ok || err1 != nil || err2 != nil || hh < 0 || hh > 24 || mm < 0 || mm > 59 || (hh == 24 && mm != 0) {
+		return 0, fmt.Errorf("bad time %q (want HH:MM)", s)
+	}
+	return time.Duration(hh)*time.Hour + time.Duration(mm)*time.Minute, nil
+}
+
+func day(s string) int {
+	for i, name := range dayNames {
+		if strings.EqualFold(s, name) {
+			return i
+		}
+	}
+	return -1
+}
+
+// Find returns the windows annotated in plain.
+func Find(plain string) ([]Window, error) {
+	var out []Window
+	for _, line := range strings.Split(plain, "\n") {
+		spec, ok := strings.CutPrefix(strings.TrimSpace(line), annotation)
+		if !ok {
+			continue
+		}
+		w, err := Parse(spec)
+		if err != nil {
+			return out, err
+		}
+		out = append(out, w)
+	}
+	return out, nil
+}
+
+// Contains reports whether t falls inside the window, in t's location.
+// A span that wraps past midnight belongs to the day it starts on.
+func (w Window) Contains(t time.Time) bool {
+	since := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute
+	started := t.Weekday()
+	switch {
+	case w.From < w.To:
+		if since < w.From || since >= w.To {
+			return false
+		}
+	case since >= w.From:
+	case since < w.To:
+		started = (started + 6) % 7
+	default:
+		return false
+	}
+	return w.Days == [7]bool{} || w.Days[started]
+}
+
+// String returns the window as it was written.
+func (w Window) String() string {
+	return w.spec
+}
+
+// Outside returns the first of windows that t falls outside of, if any.
+func Outside(windows []Window, t time.Time) (Window, bool) {
+	for _, w := range windows {
+		if !w.Contains(t) {
+			return w, true
+		}
+	}
+	return Window{}, false
+}
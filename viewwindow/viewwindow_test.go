@@ -0,0 +1,73 @@
+package viewwindow
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWindow(t *testing.T) {
+	// 2026-10-12 is a Monday.
+	at := func(day int, clock string) time.Time {
+		c, err := time.Parse("15:04", clock)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return time.Date(2026, 10, 12+day, c.Hour(), c.Minute(), 0, 0, time.UTC)
+	}
+	cases := []struct {
+		spec string
+		at   time.Time
+		want bool
+	}{
+		{"09:00-18:00", at(0, "09:00"), true},
+		{"09:00-18:00", at(0, "18:00"), false},
+		{"09:00-18:00", at(0, "08:59"), false},
+		{"09:00-18:00 mon-fri", at(4, "12:00"), true},
+		{"09:00-18:00 mon-fri", at(5, "12:00"), false},
+		{"09:00-18:00 sat,sun", at(6, "12:00"), true},
+		{"09:00-18:00 fri-mon", at(6, "12:00"), true},
+		{"09:00-18:00 fri-mon", at(2, "12:00"), false},
+		{"22:00-06:00", at(0, "23:30"), true},
+		{"22:00-06:00", at(0, "05:59"), true},
+		{"22:00-06:00", at(0, "12:00"), false},
+		// The night of Friday to Saturday starts on Friday.
+		{"22:00-06:00 fri", at(5, "02:00"), true},
+		{"22:00-06:00 fri", at(4, "02:00"), false},
+		{"00:00-24:00", at(3, "23:59"), true},
+	}
+	for _, c := range cases {
+		t.Run(c.spec+" "+c.at.Format("Mon 15:04"), func(t *testing.T) {
+			w, err := Parse(c.spec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := w.Contains(c.at); got != c.want {
+				t.Errorf("Contains = %v, want %v", got, c.want)
+			}
+		})
+	}
+
+	t.Run("refuses malformed windows", func(t *testing.T) {
+		for _, spec := range []string{"", "9-5", "09:00", "09:00-09:00", "25:00-26:00", "09:00-18:00 weekdays", "09:00-18:00 mon fri"} {
+			if _, err := Parse(spec); err == nil {
+				t.Errorf("expected an error for %q", spec)
+			}
+		}
+	})
+
+	t.Run("finds annotations", func(t *testing.T) {
+		windows, err := Find("A=1\n  # agepad:view-window=09:00-18:00 mon-fri\n# agepad:ttl=60s\n")
+		if err != nil || len(windows) != 1 || windows[0].String() != "09:00-18:00 mon-fri" {
+			t.Fatalf("got %v, %v", windows, err)
+		}
+		if _, out := Outside(windows, at(0, "10:00")); out {
+			t.Error("expected Monday morning inside the window")
+		}
+		if w, out := Outside(windows, at(0, "20:00")); !out || w.String() != "09:00-18:00 mon-fri" {
+			t.Error("expected Monday evening outside the window")
+		}
+		if _, err := Find("# agepad:view-window=soon\n"); err == nil {
+			t.Error("expected an error for a malformed annotation")
+		}
+	})
+}
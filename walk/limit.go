@@ -0,0 +1,84 @@
+package walk
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Limiter paces IO to a byte rate shared by all workers. A nil Limiter
+// never waits.
+type Limiter struct {
+	rate int64 // bytes per second
+
+	mu   sync.Mutex
+	next time.Time // when the next reservation may start
+}
+
+// NewLimiter returns a limiter for bytesPerSec, or nil (unlimited) when it
+// is not positive.
+func NewLimiter(bytesPerSec int64) *Limiter {
+	if bytesPerSec <= 0 {
+		return nil
+	}
+	return &Limiter{rate: bytesPerSec}
+}
+
+// Wait blocks until n more bytes fit in the rate, or ctx is done.
+// Reservations queue up, so one large file delays the ones after it rather
+// than exceeding the rate.
+func (l *Limiter) Wait(ctx context.Context, n int64) error {
+	if l == nil || n <= 0 {
+		return nil
+	}
+	l.mu.Lock()
+	now := time.Now()
+	start := l.next
+	if start.Before(now) {
+		start = now
+	}
+	l.next = start.Add(time.Duration(n) * time.Second / time.Duration(l.rate))
+	l.mu.Unlock()
+
+	delay := time.Until(start)
+	if delay <= 0 {
+		return nil
+	}
+	t := time.NewTimer(delay)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// ParseRate parses a byte rate such as "20M", "512KiB/s", or "1048576";
+// K, M, and G are powers of 1024.
+func ParseRate(s string) (int64, error) {
+	t := strings.ToUpper(strings.TrimSpace(s))
+	t = strings.TrimSuffix(t, "/S")
+	t = strings.TrimSuffix(t, "B")
+	t = strings.TrimSuffix(t, "I")
+	mult := int64(1)
+	switch {
+	case strings.HasSuffix(t, "K"):
+		mult = 1 << 10
+	case strings.HasSuffix(t, "M"):
+		mult = 1 << 20
+	case strings.HasSuffix(t, "G"):
+		mult = 1 << 30
+	}
+	if mult > 1 {
+		t = t[:len(t)-1]
+	}
+	n, err := strconv.ParseInt(t, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid rate %q: use bytes per second, e.g. 20M or 512K", s)
+	}
+	return n * mult, nil
+}
@@ -0,0 +1,10 @@
+//go:build !unix
+
+package walk
+
+import "errors"
+
+// SetNice is not supported on this platform.
+func SetNice(n int) error {
+	return errors.New("--nice is not supported on this platform")
+}
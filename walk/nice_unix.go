@@ -0,0 +1,10 @@
+//go:build unix
+
+package walk
+
+import "golang.org/x/sys/unix"
+
+// SetNice sets this process's scheduling priority (higher is nicer).
+func SetNice(n int) error {
+	return unix.Setpriority(unix.PRIO_PROCESS, 0, n)
+}
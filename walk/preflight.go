@@ -0,0 +1,102 @@
+package walk
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/andreweick/agepad/platform"
+)
+
+// Headroom is the free space a batch job leaves on every file system it
+// writes to, so a rotation never fills a disk other programs share.
+const Headroom = 1 << 20
+
+// Write is a file a batch job is about to replace, with an estimate of
+// its new size.
+type Write struct {
+	Path string
+	Size int64
+}
+
+// Preflight checks, before a batch job writes anything, that every
+// directory it writes to takes an atomic replace (a temp file renamed over
+// another) and has room for the temp copies up to workers writes hold at
+// once, plus Headroom. Those copies can all land on one file system, so
+// each directory is checked for the largest of them. It is much better to
+// stop here than hundreds of files in.
+func Preflight(writes []Write, workers int) error {
+	if len(writes) == 0 {
+		return nil
+	}
+	sizes := make([]int64, len(writes))
+	dirs := map[string]bool{}
+	for i, w := range writes {
+		sizes[i] = w.Size
+		dirs[filepath.Dir(w.Path)] = true
+	}
+	slices.Sort(sizes)
+	var inFlight int64
+	for _, n := range sizes[max(0, len(sizes)-max(1, workers)):] {
+		inFlight += n
+	}
+	sorted := make([]string, 0, len(dirs))
+	for d := range dirs {
+		sorted = append(sorted, d)
+	}
+	slices.Sort(sorted)
+	for _, dir := range sorted {
+		if err := checkReplace(dir); err != nil {
+			return err
+		}
+		free, err := platform.FreeSpace(dir)
+		if errors.Is(err, platform.ErrUnsupported) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if need := uint64(inFlight + Headroom); free < need {
+			return fmt.Errorf("not enough space in %s: %s free, need %s for temp copies plus %s headroom",
+				dir, FormatSize(int64(free)), FormatSize(inFlight), FormatSize(Headroom))
+		}
+	}
+	return nil
+}
+
+// checkReplace renames a temp file over another in dir, as an atomic
+// write does.
+func checkReplace(dir string) error {
+	var paths [2]string
+	for i := range paths {
+		f, err := os.CreateTemp(dir, ".agepad-tmp-*")
+		if err != nil {
+			return fmt.Errorf("cannot write to %s: %w", dir, err)
+		}
+		paths[i] = f.Name()
+		defer os.Remove(paths[i])
+		if err := f.Close(); err != nil {
+			return fmt.Errorf("cannot write to %s: %w", dir, err)
+		}
+	}
+	if err := os.Rename(paths[0], paths[1]); err != nil {
+		return fmt.Errorf("%s does not support atomic replace (rename over a file): %w", dir, err)
+	}
+	return nil
+}
+
+// FormatSize renders a byte count with a binary unit, as ParseRate reads.
+func FormatSize(n int64) string {
+	const unit = 1 << 10
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
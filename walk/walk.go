@@ -0,0 +1,66 @@
+// Package walk is the tree-walk engine shared by subcommands that work on
+// every encrypted file under a directory: it finds the files, and runs work
+// over them with a bounded worker pool and an optional IO rate limit, so a
+// rotation of tens of thousands of files doesn't saturate a shared disk.
+package walk
+
+import (
+	"context"
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// CacheDir is agepad's own cache directory (see package index); it is
+// never walked.
+const CacheDir = ".agepad"
+
+// AgeFiles returns every *.age file under root, in walk (lexical) order.
+func AgeFiles(root string) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() && d.Name() == CacheDir {
+			return fs.SkipDir
+		}
+		if !d.IsDir() && strings.HasSuffix(strings.ToLower(d.Name()), ".age") {
+			files = append(files, path)
+		}
+		return nil
+	})
+	return files, err
+}
+
+// Each calls fn for every item on up to workers goroutines (at least one)
+// and returns the errors by item index. Items not yet started when ctx is
+// done get ctx.Err().
+func Each[T any](ctx context.Context, items []T, workers int, fn func(T) error) []error {
+	errs := make([]error, len(items))
+	if workers < 1 {
+		workers = 1
+	}
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < min(workers, len(items)); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				if err := ctx.Err(); err != nil {
+					errs[i] = err
+					continue
+				}
+				errs[i] = fn(items[i])
+			}
+		}()
+	}
+	for i := range items {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+	return errs
+}
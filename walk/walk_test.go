@@ -0,0 +1,168 @@
+package walk
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestAgeFiles(t *testing.T) {
+	root := t.TempDir()
+	for _, p := range []string{"b.env.age", "a/c.JSON.AGE", "notes.txt", ".agepad/index.age"} {
+		path := filepath.Join(root, p)
+		if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+			t.Fatalf("mkdir failed: %v", err)
+		}
+		if err := os.WriteFile(path, nil, 0o600); err != nil {
+			t.Fatalf("write failed: %v", err)
+		}
+	}
+	files, err := AgeFiles(root)
+	if err != nil {
+		t.Fatalf("AgeFiles failed: %v", err)
+	}
+	want := []string{filepath.Join(root, "a/c.JSON.AGE"), filepath.Join(root, "b.env.age")}
+	if len(files) != len(want) || files[0] != want[0] || files[1] != want[1] {
+		t.Errorf("AgeFiles = %v, want %v (cache dir skipped)", files, want)
+	}
+}
+
+func TestEach(t *testing.T) {
+	t.Run("returns errors by index", func(t *testing.T) {
+		errs := Each(context.Background(), []int{1, 2, 3}, 2, func(n int) error {
+			if n == 2 {
+				return errors.New("two")
+			}
+			return nil
+		})
+		if errs[0] != nil || errs[1] == nil || errs[2] != nil {
+			t.Errorf("unexpected errors: %v", errs)
+		}
+	})
+
+	t.Run("never exceeds the worker count", func(t *testing.T) {
+		var running, peak atomic.Int32
+		Each(context.Background(), make([]int, 20), 3, func(int) error {
+			n := running.Add(1)
+			for {
+				p := peak.Load()
+				if n <= p || peak.CompareAndSwap(p, n) {
+					break
+				}
+			}
+			time.Sleep(5 * time.Millisecond)
+			running.Add(-1)
+			return nil
+		})
+		if peak.Load() > 3 {
+			t.Errorf("expected at most 3 concurrent workers, saw %d", peak.Load())
+		}
+	})
+
+	t.Run("skips items after cancellation", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		called := false
+		errs := Each(ctx, []int{1}, 1, func(int) error { called = true; return nil })
+		if called || !errors.Is(errs[0], context.Canceled) {
+			t.Errorf("expected the item to be skipped, got called=%v err=%v", called, errs[0])
+		}
+	})
+}
+
+func TestLimiter(t *testing.T) {
+	t.Run("paces reservations to the rate", func(t *testing.T) {
+		l := NewLimiter(1000) // bytes per second
+		start := time.Now()
+		for i := 0; i < 3; i++ {
+			if err := l.Wait(context.Background(), 50); err != nil {
+				t.Fatalf("Wait failed: %v", err)
+			}
+		}
+		// The first reservation starts at once; the third waits 100ms.
+		if d := time.Since(start); d < 90*time.Millisecond {
+			t.Errorf("expected about 100ms of pacing, took %v", d)
+		}
+	})
+
+	t.Run("nil is unlimited", func(t *testing.T) {
+		var l *Limiter = NewLimiter(0)
+		if l != nil || l.Wait(context.Background(), 1<<30) != nil {
+			t.Error("expected a nil limiter that never waits")
+		}
+	})
+}
+
+func TestParseRate(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int64
+	}{
+		{"1048576", 1 << 20},
+		{"20M", 20 << 20},
+		{"512K", 512 << 10},
+		{"512KiB/s", 512 << 10},
+		{"2GB", 2 << 30},
+		{"10mb/s", 10 << 20},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseRate(tt.in)
+			if err != nil || got != tt.want {
+				t.Errorf("ParseRate(%q) = %d, %v; want %d", tt.in, got, err, tt.want)
+			}
+		})
+	}
+	for _, bad := range []string{"", "fast", "-5M"} {
+		if _, err := ParseRate(bad); err == nil {
+			t.Errorf("expected an error for %q", bad)
+		}
+	}
+}
+
+func TestPreflight(t *testing.T) {
+	t.Run("passes a writable directory with room", func(t *testing.T) {
+		dir := t.TempDir()
+		writes := []Write{{filepath.Join(dir, "a.age"), 1 << 10}, {filepath.Join(dir, "b.age"), 2 << 10}}
+		if err := Preflight(writes, 4); err != nil {
+			t.Fatal(err)
+		}
+		if left, _ := filepath.Glob(filepath.Join(dir, ".agepad-tmp-*")); len(left) > 0 {
+			t.Errorf("probe files left behind: %v", left)
+		}
+	})
+
+	t.Run("refuses when the temp copies would not fit", func(t *testing.T) {
+		dir := t.TempDir()
+		err := Preflight([]Write{{filepath.Join(dir, "huge.age"), 1 << 60}}, 1)
+		if err == nil || !strings.Contains(err.Error(), "not enough space in "+dir) {
+			t.Errorf("expected a space error, got %v", err)
+		}
+	})
+
+	t.Run("refuses a directory it cannot write", func(t *testing.T) {
+		if os.Geteuid() == 0 {
+			t.Skip("root ignores directory permissions")
+		}
+		dir := filepath.Join(t.TempDir(), "ro")
+		if err := os.Mkdir(dir, 0500); err != nil {
+			t.Fatal(err)
+		}
+		if err := Preflight([]Write{{filepath.Join(dir, "a.age"), 1}}, 1); err == nil {
+			t.Error("expected an error for a read-only directory")
+		}
+	})
+
+	t.Run("formats sizes", func(t *testing.T) {
+		for n, want := range map[int64]string{512: "512 B", 1536: "1.5 KiB", 16 << 20: "16.0 MiB", 3 << 30: "3.0 GiB"} {
+			if got := FormatSize(n); got != want {
+				t.Errorf("FormatSize(%d) = %q, want %q", n, got, want)
+			}
+		}
+	})
+}
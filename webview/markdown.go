@@ -0,0 +1,528 @@
+package webview
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Markdown renders the common subset of CommonMark and GitHub Markdown
+// that runbooks use: headings, paragraphs, block quotes, nested lists and
+// task lists, fenced and indented code, pipe tables, rules, and inline
+// code, emphasis, strikethrough, links, and autolinks. Raw HTML is shown
+// as text, and links other than http, https, mailto, and relative ones are
+// shown without their target, so a document cannot run script.
+func Markdown(src string) string {
+	r := &renderer{ids: map[string]int{}}
+	lines := strings.Split(strings.ReplaceAll(strings.ReplaceAll(src, "\r\n", "\n"), "\t", "    "), "\n")
+	r.blocks(lines, false)
+	return r.out.String()
+}
+
+type renderer struct {
+	out strings.Builder
+	ids map[string]int // heading anchors handed out so far
+}
+
+var (
+	headingRE = regexp.MustCompile(`^ {0,3}(#{1,6})(?:[ \t]+(.*?))?(?:[ \t]+#+)?[ \t]*$`)
+	ruleRE    = regexp.MustCompile(`^ {0,3}(?:(?:\*[ \t]*){3,}|(?:-[ \t]*){3,}|(?:_[ \t]*){3,})$`)
+	fenceRE   = regexp.MustCompile("^( {0,3})(`{3,}|~{3,})[ \t]*([^`\\s]*)")
+	bulletRE  = regexp.MustCompile(`^( {0,3})([-*+])( +|$)`)
+	orderedRE = regexp.MustCompile(`^( {0,3})(\d{1,9})([.)])( +|$)`)
+	delimRE   = regexp.MustCompile(`^ *\|? *:?-+:? *(\| *:?-+:? *)*\|? *$`)
+)
+
+// blocks renders lines as block elements. In a tight list item, paragraphs
+// are written without <p>.
+func (r *renderer) blocks(lines []string, tight bool) {
+	for i := 0; i < len(lines); {
+		line := lines[i]
+		switch {
+		case strings.TrimSpace(line) == "":
+			i++
+		case fenceRE.MatchString(line):
+			i = r.fenced(lines, i)
+		case headingRE.MatchString(line):
+			m := headingRE.FindStringSubmatch(line)
+			n := len(m[1])
+			fmt.Fprintf(&r.out, "<h%d id=\"%s\">%s</h%d>\n", n, r.anchor(m[2]), inline(m[2]), n)
+			i++
+		case ruleRE.MatchString(line):
+			r.out.WriteString("<hr>\n")
+			i++
+		case strings.HasPrefix(strings.TrimLeft(line, " "), ">"):
+			var quote []string
+			for ; i < len(lines) && strings.HasPrefix(strings.TrimLeft(lines[i], " "), ">"); i++ {
+				q := strings.TrimPrefix(strings.TrimLeft(lines[i], " "), ">")
+				quote = append(quote, strings.TrimPrefix(q, " "))
+			}
+			r.out.WriteString("<blockquote>\n")
+			r.blocks(quote, false)
+			r.out.WriteString("</blockquote>\n")
+		case bulletRE.MatchString(line) || orderedRE.MatchString(line):
+			i = r.list(lines, i)
+		case strings.HasPrefix(line, "    "):
+			var code []string
+			for ; i < len(lines) && (strings.HasPrefix(lines[i], "    ") || strings.TrimSpace(lines[i]) == ""); i++ {
+				code = append(code, strings.TrimPrefix(lines[i], "    "))
+			}
+			for len(code) > 0 && strings.TrimSpace(code[len(code)-1]) == "" {
+				code = code[:len(code)-1]
+			}
+			fmt.Fprintf(&r.out, "<pre><code>%s\n</code></pre>\n", html.EscapeString(strings.Join(code, "\n")))
+		case isTable(lines, i):
+			i = r.table(lines, i)
+		default:
+			var para []string
+			for ; i < len(lines) && strings.TrimSpace(lines[i]) != "" && (len(para) == 0 || !interrupts(lines[i])); i++ {
+				para = append(para, strings.TrimSpace(lines[i]))
+			}
+			text := inline(strings.Join(para, "\n"))
+			if tight {
+				r.out.WriteString(text + "\n")
+			} else {
+				r.out.WriteString("<p>" + text + "</p>\n")
+			}
+		}
+	}
+}
+
+// isTable reports whether lines[i] is a table header: a row of cells over
+// a delimiter row with as many.
+func isTable(lines []string, i int) bool {
+	return strings.Contains(lines[i], "|") && i+1 < len(lines) && delimRE.MatchString(lines[i+1]) &&
+		len(cells(lines[i])) == len(cells(lines[i+1]))
+}
+
+// interrupts reports whether line starts a block that ends a paragraph.
+func interrupts(line string) bool {
+	return fenceRE.MatchString(line) || headingRE.MatchString(line) || ruleRE.MatchString(line) ||
+		strings.HasPrefix(strings.TrimLeft(line, " "), ">") || bulletRE.MatchString(line) || orderedRE.MatchString(line)
+}
+
+// fenced renders the code block opening at lines[i] and returns the index
+// after it. An unclosed fence runs to the end of the document.
+func (r *renderer) fenced(lines []string, i int) int {
+	m := fenceRE.FindStringSubmatch(lines[i])
+	indent, fence, lang := len(m[1]), m[2], m[3]
+	var code []string
+	for i++; i < len(lines); i++ {
+		if t := strings.TrimSpace(lines[i]); strings.HasPrefix(t, fence) && strings.Trim(t, fence[:1]) == "" {
+			i++
+			break
+		}
+		l := lines[i]
+		for n := 0; n < indent && strings.HasPrefix(l, " "); n++ {
+			l = l[1:]
+		}
+		code = append(code, l)
+	}
+	class := ""
+	if lang != "" {
+		class = ` class="language-` + html.EscapeString(lang) + `"`
+	}
+	body := html.EscapeString(strings.Join(code, "\n"))
+	if len(code) > 0 {
+		body += "\n"
+	}
+	fmt.Fprintf(&r.out, "<pre><code%s>%s</code></pre>\n", class, body)
+	return i
+}
+
+// list renders the list starting at lines[i] and returns the index after
+// it. An item runs on over lines indented past its marker; a blank line
+// between items makes the list loose, with each item in paragraphs.
+func (r *renderer) list(lines []string, i int) int {
+	ordered := orderedRE.MatchString(lines[i])
+	marker := func(line string) (width int, ok bool) {
+		if ordered {
+			m := orderedRE.FindStringSubmatch(line)
+			if m == nil {
+				return 0, false
+			}
+			return len(m[0]), true
+		}
+		m := bulletRE.FindStringSubmatch(line)
+		if m == nil || ruleRE.MatchString(line) {
+			return 0, false
+		}
+		return len(m[0]), true
+	}
+
+	var items [][]string
+	loose := false
+	start := 1
+	if ordered {
+		start, _ = strconv.Atoi(orderedRE.FindStringSubmatch(lines[i])[2])
+	}
+	for i < len(lines) {
+		w, ok := marker(lines[i])
+		if !ok {
+			break
+		}
+		if w == len(lines[i]) || strings.TrimSpace(lines[i][w:]) == "" {
+			w = len(strings.TrimRight(lines[i], " ")) + 1
+		}
+		first := ""
+		if w < len(lines[i]) {
+			first = lines[i][w:]
+		}
+		item := []string{first}
+		for i++; i < len(lines); i++ {
+			l := lines[i]
+			if strings.TrimSpace(l) == "" {
+				// A blank line continues the item only if more of it follows.
+				if i+1 < len(lines) && indentOf(lines[i+1]) >= w {
+					item = append(item, "")
+					loose = true
+					continue
+				}
+				break
+			}
+			if indentOf(l) >= w {
+				item = append(item, l[w:])
+				continue
+			}
+			if _, next := marker(l); next || interrupts(l) {
+				break
+			}
+			item = append(item, strings.TrimSpace(l)) // lazy continuation
+		}
+		items = append(items, item)
+		if i+1 < len(lines) && strings.TrimSpace(lines[i]) == "" {
+			if _, next := marker(lines[i+1]); next {
+				loose = true
+				i++
+			}
+		}
+	}
+
+	switch {
+	case !ordered:
+		r.out.WriteString("<ul>\n")
+	case start != 1:
+		fmt.Fprintf(&r.out, "<ol start=\"%d\">\n", start)
+	default:
+		r.out.WriteString("<ol>\n")
+	}
+	for _, item := range items {
+		r.out.WriteString("<li>")
+		if box, rest, ok := taskBox(item[0]); ok {
+			r.out.WriteString(box)
+			item[0] = rest
+		}
+		r.blocks(item, !loose)
+		r.out.WriteString("</li>\n")
+	}
+	if ordered {
+		r.out.WriteString("</ol>\n")
+	} else {
+		r.out.WriteString("</ul>\n")
+	}
+	return i
+}
+
+// taskBox turns a task list item's "[ ]" or "[x]" into a checkbox.
+func taskBox(first string) (box, rest string, ok bool) {
+	switch {
+	case strings.HasPrefix(first, "[ ] "):
+		return `<input type="checkbox" disabled> `, first[4:], true
+	case strings.HasPrefix(first, "[x] "), strings.HasPrefix(first, "[X] "):
+		return `<input type="checkbox" checked disabled> `, first[4:], true
+	}
+	return "", first, false
+}
+
+func indentOf(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " "))
+}
+
+// table renders the pipe table whose header is lines[i] and returns the
+// index after it.
+func (r *renderer) table(lines []string, i int) int {
+	head := cells(lines[i])
+	var align []string
+	for _, d := range cells(lines[i+1]) {
+		switch l, rt := strings.HasPrefix(d, ":"), strings.HasSuffix(d, ":"); {
+		case l && rt:
+			align = append(align, ` style="text-align:center"`)
+		case rt:
+			align = append(align, ` style="text-align:right"`)
+		case l:
+			align = append(align, ` style="text-align:left"`)
+		default:
+			align = append(align, "")
+		}
+	}
+	row := func(tag string, cs []string) {
+		r.out.WriteString("<tr>")
+		for j := range head {
+			a, c := "", ""
+			if j < len(align) {
+				a = align[j]
+			}
+			if j < len(cs) {
+				c = cs[j]
+			}
+			fmt.Fprintf(&r.out, "<%s%s>%s</%s>", tag, a, inline(c), tag)
+		}
+		r.out.WriteString("</tr>\n")
+	}
+	r.out.WriteString("<table>\n<thead>\n")
+	row("th", head)
+	r.out.WriteString("</thead>\n<tbody>\n")
+	for i += 2; i < len(lines) && strings.TrimSpace(lines[i]) != "" && strings.Contains(lines[i], "|"); i++ {
+		row("td", cells(lines[i]))
+	}
+	r.out.WriteString("</tbody>\n</table>\n")
+	return i
+}
+
+// cells splits a table row at pipes that are not escaped or in code.
+func cells(line string) []string {
+	line = strings.TrimSpace(line)
+	line = strings.TrimPrefix(line, "|")
+	if strings.HasSuffix(line, "|") && !strings.HasSuffix(line, `\|`) {
+		line = line[:len(line)-1]
+	}
+	var out []string
+	var cur strings.Builder
+	code := false
+	for k := 0; k < len(line); k++ {
+		switch c := line[k]; {
+		case c == '\\' && k+1 < len(line) && line[k+1] == '|':
+			cur.WriteByte('|')
+			k++
+		case c == '`':
+			code = !code
+			cur.WriteByte(c)
+		case c == '|' && !code:
+			out = append(out, strings.TrimSpace(cur.String()))
+			cur.Reset()
+		default:
+			cur.WriteByte(c)
+		}
+	}
+	return append(out, strings.TrimSpace(cur.String()))
+}
+
+// anchor returns a unique id for a heading, as GitHub forms them.
+func (r *renderer) anchor(text string) string {
+	var b strings.Builder
+	for _, c := range strings.ToLower(text) {
+		switch {
+		case c >= 'a' && c <= 'z', c >= '0' && c <= '9', c == '-', c == '_', c > 127:
+			b.WriteRune(c)
+		case c == ' ':
+			b.WriteByte('-')
+		}
+	}
+	id := b.String()
+	if n := r.ids[id]; n > 0 {
+		r.ids[id] = n + 1
+		id = fmt.Sprintf("%s-%d", id, n)
+	} else {
+		r.ids[id] = 1
+	}
+	return html.EscapeString(id)
+}
+
+// inline renders the spans of one block's text.
+func inline(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == '\\' && i+1 < len(s) && strings.IndexByte("\\`*_{}[]()#+-.!|~<>\"'", s[i+1]) >= 0:
+			b.WriteString(html.EscapeString(s[i+1 : i+2]))
+			i += 2
+		case c == '\\' && i+1 < len(s) && s[i+1] == '\n':
+			b.WriteString("<br>\n")
+			i += 2
+		case c == '`':
+			n := runOf(s[i:], '`')
+			if end := closingRun(s[i+n:], '`', n); end >= 0 {
+				code := strings.ReplaceAll(s[i+n:i+n+end], "\n", " ")
+				if len(code) > 2 && code[0] == ' ' && code[len(code)-1] == ' ' {
+					code = code[1 : len(code)-1]
+				}
+				b.WriteString("<code>" + html.EscapeString(code) + "</code>")
+				i += n + end + n
+			} else {
+				b.WriteString(s[i : i+n])
+				i += n
+			}
+		case c == '!' && strings.HasPrefix(s[i+1:], "["):
+			if text, dest, n, ok := link(s[i+1:]); ok {
+				// Images are linked rather than loaded: the page loads
+				// nothing from elsewhere.
+				b.WriteString(anchorTag(dest, "image: "+html.EscapeString(text)))
+				i += 1 + n
+			} else {
+				b.WriteByte('!')
+				i++
+			}
+		case c == '[':
+			if text, dest, n, ok := link(s[i:]); ok {
+				b.WriteString(anchorTag(dest, inline(text)))
+				i += n
+			} else {
+				b.WriteString("[")
+				i++
+			}
+		case c == '<':
+			if end := strings.IndexByte(s[i:], '>'); end > 0 && autolinkRE.MatchString(s[i+1:i+end]) {
+				u := s[i+1 : i+end]
+				b.WriteString(anchorTag(u, html.EscapeString(u)))
+				i += end + 1
+			} else {
+				b.WriteString("&lt;")
+				i++
+			}
+		case c == '*' || c == '_' || c == '~':
+			n, out := emphasis(s, i)
+			if n == 0 {
+				b.WriteString(html.EscapeString(s[i : i+1]))
+				i++
+				continue
+			}
+			b.WriteString(out)
+			i += n
+		case c == ' ' && strings.HasPrefix(s[i:], "  \n"):
+			b.WriteString("<br>\n")
+			i += 3
+		default:
+			j := i + 1
+			for j < len(s) && strings.IndexByte("\\`![<*_~ ", s[j]) < 0 {
+				j++
+			}
+			b.WriteString(html.EscapeString(s[i:j]))
+			i = j
+		}
+	}
+	return b.String()
+}
+
+var autolinkRE = regexp.MustCompile(`^(?i:https?://|mailto:)[^\s<>]+$`)
+
+// runOf counts the leading run of c in s.
+func runOf(s string, c byte) int {
+	n := 0
+	for n < len(s) && s[n] == c {
+		n++
+	}
+	return n
+}
+
+// closingRun finds a run of exactly n c's in s.
+func closingRun(s string, c byte, n int) int {
+	for i := 0; i < len(s); {
+		if s[i] != c {
+			i++
+			continue
+		}
+		m := runOf(s[i:], c)
+		if m == n {
+			return i
+		}
+		i += m
+	}
+	return -1
+}
+
+// emphasis renders the *em*, **strong**, _em_, __strong__, or ~~del~~ span
+// opening at s[i], returning how much of s it used (0 if none opens).
+func emphasis(s string, i int) (int, string) {
+	c := s[i]
+	n := min(runOf(s[i:], c), 2)
+	if c == '~' && n != 2 {
+		return 0, ""
+	}
+	if c == '_' && i > 0 && isWord(s[i-1]) {
+		return 0, "" // snake_case stays as written
+	}
+	delim := s[i : i+n]
+	body := i + n
+	if body >= len(s) || s[body] == ' ' || s[body] == '\n' {
+		return 0, ""
+	}
+	for j := body + 1; j+n <= len(s); j++ {
+		if s[j-1] == '\\' || s[j:j+n] != delim || s[j-1] == ' ' || s[j-1] == '\n' {
+			continue
+		}
+		if n == 1 && j+1 < len(s) && s[j+1] == c {
+			j++ // part of a ** inside this *...*
+			continue
+		}
+		if c == '_' && j+n < len(s) && isWord(s[j+n]) {
+			continue
+		}
+		tag := map[int]string{1: "em", 2: "strong"}[n]
+		if c == '~' {
+			tag = "del"
+		}
+		return j + n - i, "<" + tag + ">" + inline(s[body:j]) + "</" + tag + ">"
+	}
+	return 0, ""
+}
+
+func isWord(c byte) bool {
+	return c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// link parses "[text](dest "title")" at the start of s.
+func link(s string) (text, dest string, n int, ok bool) {
+	depth := 0
+	end := -1
+	for k := 0; k < len(s) && end < 0; k++ {
+		switch s[k] {
+		case '\\':
+			k++
+		case '[':
+			depth++
+		case ']':
+			if depth--; depth == 0 {
+				end = k
+			}
+		}
+	}
+	if end < 0 || end+1 >= len(s) || s[end+1] != '(' {
+		return "", "", 0, false
+	}
+	paren := -1 // the ")" closing the destination; inner parentheses pair up
+	for k, depth := end+2, 0; k < len(s) && paren < 0; k++ {
+		switch s[k] {
+		case '(':
+			depth++
+		case ')':
+			if depth == 0 {
+				paren = k - end - 1
+			}
+			depth--
+		}
+	}
+	if paren < 0 {
+		return "", "", 0, false
+	}
+	inner := strings.TrimSpace(s[end+2 : end+1+paren])
+	if sp := strings.IndexAny(inner, " \n"); sp >= 0 {
+		inner = inner[:sp] // drop a "title"
+	}
+	inner = strings.TrimSuffix(strings.TrimPrefix(inner, "<"), ">")
+	return s[1:end], inner, end + 2 + paren, true
+}
+
+// anchorTag links body to dest, or leaves body unlinked when dest is not
+// a web, mail, or relative address.
+func anchorTag(dest, body string) string {
+	lower := strings.ToLower(dest)
+	if scheme, _, found := strings.Cut(lower, ":"); found && !strings.ContainsAny(scheme, "/?#") {
+		if scheme != "http" && scheme != "https" && scheme != "mailto" {
+			return body
+		}
+	}
+	return `<a href="` + html.EscapeString(dest) + `" rel="noreferrer noopener">` + body + "</a>"
+}
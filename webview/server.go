@@ -0,0 +1,200 @@
+// Package webview serves one decrypted document, rendered read-only, to a
+// browser on this machine. The page only ever lives in memory; it is
+// reached through a one-time link, and the server stops serving it once
+// it expires.
+package webview
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"html/template"
+	"net"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// cookieName holds the session a one-time link is exchanged for.
+const cookieName = "agepad-web"
+
+// csp forbids scripts, frames, forms, and loading anything from elsewhere,
+// HTML documents included.
+const csp = "default-src 'none'; style-src 'unsafe-inline'; img-src data:; form-action 'none'; frame-ancestors 'none'; base-uri 'none'"
+
+// Server serves one page. Open the URL it returns once: the first visit
+// trades its token for a session cookie, so the link cannot be reused,
+// and a reload keeps working in that browser until the page expires.
+type Server struct {
+	mu      sync.Mutex
+	page    []byte
+	token   string // "" once used
+	session string // "" until the token is used
+	expires time.Time
+	done    chan struct{}
+}
+
+// New returns a Server for page, which it clears when it expires or on
+// Close.
+func New(page []byte, ttl time.Duration) *Server {
+	s := &Server{
+		page:    page,
+		token:   randomToken(),
+		expires: time.Now().Add(ttl),
+		done:    make(chan struct{}),
+	}
+	time.AfterFunc(ttl, s.Close)
+	return s
+}
+
+func randomToken() string {
+	b := make([]byte, 32)
+	_, _ = rand.Read(b) // never fails
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// URL returns the one-time link for a server listening on addr.
+func (s *Server) URL(addr net.Addr) string {
+	return "http://" + addr.String() + "/open/" + s.token
+}
+
+// Expires returns when the page stops being served.
+func (s *Server) Expires() time.Time { return s.expires }
+
+// Done is closed once the page has expired or Close was called.
+func (s *Server) Done() <-chan struct{} { return s.done }
+
+// Close clears the page and stops serving it.
+func (s *Server) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.page == nil {
+		return
+	}
+	clear(s.page)
+	s.page, s.token, s.session = nil, "", ""
+	close(s.done)
+}
+
+// ServeHTTP answers GET /open/<token> once with a session cookie and a
+// redirect to /, and GET / for that session with the page. Requests that
+// name another host are refused, so a page on some other site cannot
+// reach the server through DNS rebinding.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h := w.Header()
+	h.Set("Content-Security-Policy", csp)
+	h.Set("Cache-Control", "no-store")
+	h.Set("Referrer-Policy", "no-referrer")
+	h.Set("X-Content-Type-Options", "nosniff")
+	h.Set("X-Frame-Options", "DENY")
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "read-only", http.StatusMethodNotAllowed)
+		return
+	}
+	if !loopbackHost(r.Host) {
+		http.Error(w, "unexpected host", http.StatusForbidden)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.page == nil {
+		http.Error(w, "this page has expired", http.StatusGone)
+		return
+	}
+	switch {
+	case strings.HasPrefix(r.URL.Path, "/open/"):
+		token := strings.TrimPrefix(r.URL.Path, "/open/")
+		if s.token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) != 1 {
+			http.Error(w, "this link was already used or is not valid; run agepad web again for a new one", http.StatusForbidden)
+			return
+		}
+		s.token = ""
+		s.session = randomToken()
+		http.SetCookie(w, &http.Cookie{
+			Name:     cookieName,
+			Value:    s.session,
+			Path:     "/",
+			MaxAge:   int(time.Until(s.expires).Seconds()) + 1,
+			HttpOnly: true,
+			SameSite: http.SameSiteStrictMode,
+		})
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+	case r.URL.Path == "/":
+		c, err := r.Cookie(cookieName)
+		if err != nil || s.session == "" || subtle.ConstantTimeCompare([]byte(c.Value), []byte(s.session)) != 1 {
+			http.Error(w, "open the one-time link agepad web printed", http.StatusForbidden)
+			return
+		}
+		h.Set("Content-Type", "text/html; charset=utf-8")
+		_, _ = w.Write(s.page)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// loopbackHost reports whether a Host header names this machine.
+func loopbackHost(host string) bool {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(strings.Trim(host, "[]"))
+	return ip != nil && ip.IsLoopback()
+}
+
+// Page renders plain, decrypted from the file name, as a standalone HTML
+// page: Markdown by its extension before .age, HTML as written (the
+// server's policy keeps it from running script or loading anything), and
+// anything else as preformatted text.
+func Page(name, plain string, expires time.Time) []byte {
+	base := strings.TrimSuffix(filepath.Base(name), ".age")
+	switch strings.ToLower(filepath.Ext(base)) {
+	case ".html", ".htm":
+		return []byte(plain)
+	case ".md", ".markdown":
+		return shell(base, template.HTML(Markdown(plain)), expires)
+	}
+	return shell(base, template.HTML("<pre>"+template.HTMLEscapeString(plain)+"</pre>"), expires)
+}
+
+func shell(title string, body template.HTML, expires time.Time) []byte {
+	var b strings.Builder
+	_ = pageTemplate.Execute(&b, struct {
+		Title   string
+		Body    template.HTML
+		Expires string
+	}{title, body, expires.Local().Format("15:04")})
+	return []byte(b.String())
+}
+
+var pageTemplate = template.Must(template.New("page").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex">
+<title>{{.Title}} (agepad)</title>
+<style>
+body { max-width: 60rem; margin: 0 auto; padding: 1rem 2rem; font: 16px/1.5 system-ui, sans-serif; color: #1f2328; }
+header { font-size: .85rem; color: #59636e; border-bottom: 1px solid #d1d9e0; padding-bottom: .5rem; }
+pre { background: #f6f8fa; padding: .75rem; overflow: auto; }
+code { font: .9em ui-monospace, monospace; background: #f6f8fa; padding: .1em .3em; }
+pre code { padding: 0; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #d1d9e0; padding: .3rem .6rem; }
+blockquote { margin-left: 0; padding-left: 1rem; border-left: .25rem solid #d1d9e0; color: #59636e; }
+</style>
+</head>
+<body>
+<header>{{.Title}}: read-only, served from memory by agepad until {{.Expires}}</header>
+<main>
+{{.Body}}
+</main>
+</body>
+</html>
+`))
@@ -0,0 +1,107 @@
+package webview
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMarkdown(t *testing.T) {
+	for _, tc := range []struct {
+		name, in string
+		want     []string
+	}{
+		{"headings get anchors", "# Fail over\n## Fail over", []string{`<h1 id="fail-over">Fail over</h1>`, `<h2 id="fail-over-1">`}},
+		{"inline spans", "**b** *i* ~~d~~ `x<y` snake_case_name", []string{"<strong>b</strong>", "<em>i</em>", "<del>d</del>", "<code>x&lt;y</code>", "snake_case_name"}},
+		{"escapes raw HTML", "<script>alert(1)</script>", []string{"&lt;script&gt;alert(1)&lt;/script&gt;"}},
+		{"links", "[a](https://x.test/p_(1)) <https://y.test>", []string{`<a href="https://x.test/p_(1)" rel="noreferrer noopener">a</a>`, `<a href="https://y.test"`}},
+		{"drops script links", "[click](javascript:alert(1))", []string{"<p>click</p>"}},
+		{"links images", "![diagram](https://x.test/d.png)", []string{`<a href="https://x.test/d.png" rel="noreferrer noopener">image: diagram</a>`}},
+		{"fenced code", "```sh\necho \"$A\" && <b>\n```", []string{`<pre><code class="language-sh">echo &#34;$A&#34; &amp;&amp; &lt;b&gt;` + "\n</code></pre>"}},
+		{"tables", "| a | b |\n|:--|--:|\n| 1 | `x|y` |", []string{`<th style="text-align:left">a</th>`, `<td style="text-align:right"><code>x|y</code></td>`}},
+		{"nested and task lists", "1. one\n2. two\n   - [ ] sub\n", []string{"<ol>\n<li>one\n</li>", "<ul>\n<li><input type=\"checkbox\" disabled> sub"}},
+		{"loose lists", "- a\n\n- b", []string{"<li><p>a</p>"}},
+		{"quotes and rules", "> quoted\n\n---", []string{"<blockquote>\n<p>quoted</p>\n</blockquote>", "<hr>"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got := Markdown(tc.in)
+			for _, w := range tc.want {
+				if !strings.Contains(got, w) {
+					t.Errorf("expected %q in:\n%s", w, got)
+				}
+			}
+		})
+	}
+}
+
+func TestServer(t *testing.T) {
+	get := func(s *Server, host, path string, cookies ...*http.Cookie) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, path, nil)
+		r.Host = host
+		for _, c := range cookies {
+			r.AddCookie(c)
+		}
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, r)
+		return w
+	}
+	addr := &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 8080}
+
+	t.Run("trades the one-time link for a session", func(t *testing.T) {
+		s := New([]byte("secret page"), time.Minute)
+		defer s.Close()
+		open := strings.TrimPrefix(s.URL(addr), "http://127.0.0.1:8080")
+		w := get(s, "127.0.0.1:8080", open)
+		if w.Code != http.StatusSeeOther || len(w.Result().Cookies()) != 1 {
+			t.Fatalf("open: %d %v", w.Code, w.Result().Cookies())
+		}
+		cookie := w.Result().Cookies()[0]
+		if w := get(s, "127.0.0.1:8080", "/", cookie); w.Code != http.StatusOK || w.Body.String() != "secret page" {
+			t.Errorf("page: %d %q", w.Code, w.Body)
+		}
+		if w.Header().Get("Content-Security-Policy") == "" || w.Header().Get("Cache-Control") != "no-store" {
+			t.Errorf("missing headers: %v", w.Header())
+		}
+		if w := get(s, "127.0.0.1:8080", open); w.Code != http.StatusForbidden {
+			t.Errorf("expected a used link to be refused, got %d", w.Code)
+		}
+		if w := get(s, "127.0.0.1:8080", "/"); w.Code != http.StatusForbidden {
+			t.Errorf("expected no page without the session, got %d", w.Code)
+		}
+		if w := get(s, "attacker.test:8080", "/", cookie); w.Code != http.StatusForbidden {
+			t.Errorf("expected another host name to be refused, got %d", w.Code)
+		}
+	})
+
+	t.Run("clears the page when it expires", func(t *testing.T) {
+		page := []byte("secret page")
+		s := New(page, 10*time.Millisecond)
+		select {
+		case <-s.Done():
+		case <-time.After(time.Second):
+			t.Fatal("page did not expire")
+		}
+		if string(page) == "secret page" {
+			t.Error("expected the page to be cleared")
+		}
+		if w := get(s, "127.0.0.1:8080", "/"); w.Code != http.StatusGone {
+			t.Errorf("expected 410 after expiry, got %d", w.Code)
+		}
+	})
+
+	t.Run("renders by file type", func(t *testing.T) {
+		exp := time.Now()
+		if p := string(Page("runbook.md.age", "# Hi", exp)); !strings.Contains(p, `<h1 id="hi">Hi</h1>`) {
+			t.Errorf("markdown page: %s", p)
+		}
+		if p := string(Page("notes.txt.age", "a<b", exp)); !strings.Contains(p, "<pre>a&lt;b</pre>") {
+			t.Errorf("text page: %s", p)
+		}
+		if p := string(Page("page.html.age", "<p>x</p>", exp)); p != "<p>x</p>" {
+			t.Errorf("html page: %s", p)
+		}
+	})
+}
@@ -0,0 +1,187 @@
+// Package workspace loads named bundles of agepad settings from
+// workspace.yaml and remembers which one is active, so switching between
+// environments is one command instead of a set of flags per invocation:
+//
+//	workspaces:
+//	  prod:
+//	    root: ~/infra/prod
+//	    recipients_file: ~/infra/prod/.age-recipients
+//	    identities: ~/.config/age/prod.txt
+//	    armor: true
+//	    validate: [json=warn]
+//	    type_map: ["*.secrets=env"]
+//	    forbid_scrypt: true
+//	    diff_algorithm: patience
+//	    notify: desktop
+//	    notify_after: 1m
+package workspace
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/andreweick/agepad/platform"
+	"gopkg.in/yaml.v3"
+)
+
+// FileName is the name agepad looks for in the working directory before
+// falling back to the user config directory.
+const FileName = "workspace.yaml"
+
+// Workspace is one named bundle of settings. Empty fields leave the
+// corresponding flag at its default.
+type Workspace struct {
+	Root           string   `yaml:"root"`
+	RecipientsFile string   `yaml:"recipients_file"`
+	Identities     string   `yaml:"identities"`
+	Armor          *bool    `yaml:"armor"`
+	Validate       []string `yaml:"validate"`
+	TypeMap        []string `yaml:"type_map"`
+	RequireX25519  *bool    `yaml:"require_x25519"`
+	ForbidScrypt   *bool    `yaml:"forbid_scrypt"`
+	DiffAlgorithm  string   `yaml:"diff_algorithm"`
+	Notify         string   `yaml:"notify"`
+	NotifyAfter    string   `yaml:"notify_after"`
+}
+
+// File is the parsed workspace.yaml.
+type File struct {
+	Workspaces map[string]Workspace `yaml:"workspaces"`
+}
+
+// Names returns the workspace names in sorted order.
+func (f File) Names() []string {
+	names := make([]string, 0, len(f.Workspaces))
+	for n := range f.Workspaces {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Get returns the named workspace.
+func (f File) Get(name string) (Workspace, error) {
+	w, ok := f.Workspaces[name]
+	if !ok {
+		return Workspace{}, fmt.Errorf("workspace %q is not defined (have: %s)", name, strings.Join(f.Names(), ", "))
+	}
+	return w, nil
+}
+
+// DefaultPath returns ./workspace.yaml when it exists, otherwise
+// workspace.yaml under $XDG_CONFIG_HOME/agepad (default ~/.config/agepad;
+// %AppData%\agepad on Windows).
+func DefaultPath() string {
+	if _, err := os.Stat(FileName); err == nil {
+		return FileName
+	}
+	return filepath.Join(platform.ConfigDir(), "agepad", FileName)
+}
+
+// Load parses the workspace file at path. A missing file has no workspaces.
+func Load(path string) (File, error) {
+	var f File
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return f, nil
+	}
+	if err != nil {
+		return f, fmt.Errorf("workspace: %w", err)
+	}
+	if err := yaml.Unmarshal(b, &f); err != nil {
+		return f, fmt.Errorf("workspace: %s: %w", path, err)
+	}
+	return f, nil
+}
+
+// statePath is where the active workspace name is remembered.
+func statePath() string {
+	return filepath.Join(platform.StateDir(), "agepad", "workspace")
+}
+
+// Active returns the active workspace name: $AGEPAD_WORKSPACE, else the one
+// last chosen with Use, else "".
+func Active() (string, error) {
+	if name := os.Getenv("AGEPAD_WORKSPACE"); name != "" {
+		return name, nil
+	}
+	b, err := os.ReadFile(statePath())
+	if errors.Is(err, os.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("workspace: %w", err)
+	}
+	return strings.TrimSpace(string(b)), nil
+}
+
+// Use remembers name as the active workspace; "" clears it.
+func Use(name string) error {
+	p := statePath()
+	if name == "" {
+		if err := os.Remove(p); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("workspace: %w", err)
+		}
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0700); err != nil {
+		return fmt.Errorf("workspace: %w", err)
+	}
+	if err := os.WriteFile(p, []byte(name+"\n"), 0600); err != nil {
+		return fmt.Errorf("workspace: %w", err)
+	}
+	return nil
+}
+
+// Flags maps the workspace onto agepad flag names and values, with "~/"
+// expanded in paths. List settings yield one value per element.
+func (w Workspace) Flags() map[string][]string {
+	out := map[string][]string{}
+	for name, v := range map[string]string{
+		"root":            w.Root,
+		"recipients-file": w.RecipientsFile,
+		"identities":      w.Identities,
+	} {
+		if v != "" {
+			out[name] = []string{expand(v)}
+		}
+	}
+	for name, v := range map[string]string{
+		"diff-algorithm": w.DiffAlgorithm,
+		"notify":         w.Notify,
+		"notify-after":   w.NotifyAfter,
+	} {
+		if v != "" {
+			out[name] = []string{v}
+		}
+	}
+	for name, v := range map[string]*bool{
+		"armor":          w.Armor,
+		"require-x25519": w.RequireX25519,
+		"forbid-scrypt":  w.ForbidScrypt,
+	} {
+		if v != nil {
+			out[name] = []string{strconv.FormatBool(*v)}
+		}
+	}
+	if len(w.Validate) > 0 {
+		out["validate"] = w.Validate
+	}
+	if len(w.TypeMap) > 0 {
+		out["type-map"] = w.TypeMap
+	}
+	return out
+}
+
+func expand(p string) string {
+	if p == "~" || strings.HasPrefix(p, "~/") {
+		home, _ := os.UserHomeDir()
+		return filepath.Join(home, strings.TrimPrefix(p, "~"))
+	}
+	return p
+}
@@ -0,0 +1,94 @@
+package workspace
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoad(t *testing.T) {
+	t.Run("parses workspaces and maps them to flags", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), FileName)
+		content := `workspaces:
+  prod:
+    root: /srv/prod
+    identities: ~/keys/prod.txt
+    armor: false
+    validate: [json=warn]
+    notify: desktop
+    notify_after: 2m
+  staging:
+    recipients_file: staging.recipients
+`
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatalf("write failed: %v", err)
+		}
+
+		f, err := Load(path)
+		if err != nil {
+			t.Fatalf("load failed: %v", err)
+		}
+		if names := f.Names(); len(names) != 2 || names[0] != "prod" {
+			t.Errorf("unexpected names: %v", names)
+		}
+
+		w, err := f.Get("prod")
+		if err != nil {
+			t.Fatalf("get failed: %v", err)
+		}
+		flags := w.Flags()
+		home, _ := os.UserHomeDir()
+		if flags["root"][0] != "/srv/prod" || flags["identities"][0] != filepath.Join(home, "keys/prod.txt") {
+			t.Errorf("unexpected path flags: %v", flags)
+		}
+		if flags["armor"][0] != "false" || flags["validate"][0] != "json=warn" {
+			t.Errorf("unexpected flags: %v", flags)
+		}
+		if flags["notify"][0] != "desktop" || flags["notify-after"][0] != "2m" {
+			t.Errorf("unexpected notify flags: %v", flags)
+		}
+		if _, ok := flags["recipients-file"]; ok {
+			t.Error("expected unset fields to be left out")
+		}
+	})
+
+	t.Run("treats a missing file as empty", func(t *testing.T) {
+		f, err := Load(filepath.Join(t.TempDir(), "none.yaml"))
+		if err != nil || len(f.Workspaces) != 0 {
+			t.Errorf("expected empty file, got %+v (%v)", f, err)
+		}
+	})
+
+	t.Run("reports unknown workspaces", func(t *testing.T) {
+		if _, err := (File{}).Get("nope"); err == nil {
+			t.Error("expected error for unknown workspace")
+		}
+	})
+}
+
+func TestActive(t *testing.T) {
+	t.Run("remembers and clears the active workspace", func(t *testing.T) {
+		t.Setenv("XDG_STATE_HOME", t.TempDir())
+		t.Setenv("AGEPAD_WORKSPACE", "")
+
+		if err := Use("prod"); err != nil {
+			t.Fatalf("use failed: %v", err)
+		}
+		if name, _ := Active(); name != "prod" {
+			t.Errorf("expected prod, got %q", name)
+		}
+		if err := Use(""); err != nil {
+			t.Fatalf("clear failed: %v", err)
+		}
+		if name, _ := Active(); name != "" {
+			t.Errorf("expected no workspace, got %q", name)
+		}
+	})
+
+	t.Run("prefers AGEPAD_WORKSPACE", func(t *testing.T) {
+		t.Setenv("AGEPAD_WORKSPACE", "staging")
+		if name, _ := Active(); name != "staging" {
+			t.Errorf("expected staging, got %q", name)
+		}
+	})
+}